@@ -11,13 +11,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/payram/payram-updater/internal/autoupdate"
 	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/cli"
 	"github.com/payram/payram-updater/internal/config"
 	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/dockerexec"
 	"github.com/payram/payram-updater/internal/history"
 	"github.com/payram/payram-updater/internal/jobs"
 	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/supervisor"
 )
 
 func runBackup() {
@@ -29,21 +32,30 @@ Subcommands:
   create    Create a new database backup
   list      List all available backups
   restore   Restore from a backup file
+  schedule  Configure periodic backups taken by the daemon
+  pin       Exempt a backup from pruning
+  unpin     Let a pinned backup be pruned again
+  diff      Compare two backups' metadata and objects
 
 Examples:
   payram-updater backup create
+  payram-updater backup create --dump-format directory
+  payram-updater backup create --quiesce
   payram-updater backup list
-  payram-updater backup restore --file /path/to/backup.dump --yes`)
+  payram-updater backup restore --file /path/to/backup.dump --yes
+  payram-updater backup restore --job-id job-1700000000 --full-recovery
+  payram-updater backup schedule --interval 24h
+  payram-updater backup pin --file /path/to/backup.dump
+  payram-updater backup diff --a /path/to/old.dump --b /path/to/new.dump`)
 		os.Exit(1)
 	}
 
 	subcommand := os.Args[2]
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
 	}
 
 	// Create backup manager (works without daemon)
@@ -60,75 +72,101 @@ Examples:
 		PGDB:                cfg.Backup.PGDB,
 		PGUser:              cfg.Backup.PGUser,
 		PGPassword:          cfg.Backup.PGPassword,
+		PGSuperUser:         cfg.Backup.PGSuperUser,
+		PGSuperPassword:     cfg.Backup.PGSuperPassword,
 		ImagePattern:        imagePattern,
 		TargetContainerName: cfg.TargetContainerName,
+		DumpFormat:          cfg.Backup.DumpFormat,
+		PerDatabaseDirs:     cfg.Backup.PerDatabaseDirs,
+		DatabaseRetention:   cfg.Backup.DatabaseRetention,
+		RequireMount:        cfg.Backup.RequireMount,
+		Snapshot: backup.SnapshotConfig{
+			Strategy:        cfg.Backup.Strategy,
+			Command:         cfg.Backup.SnapshotCommand,
+			RollbackCommand: cfg.Backup.SnapshotRollbackCommand,
+		},
 	}
 	mgr := backup.NewManager(backupCfg, &backup.RealExecutor{}, log.Default())
 
 	switch subcommand {
 	case "create":
-		runBackupCreate(mgr)
+		runBackupCreate(mgr, cfg)
 	case "list":
 		runBackupList(mgr)
 	case "restore":
 		runBackupRestore(mgr)
+	case "schedule":
+		runBackupSchedule()
+	case "pin", "unpin":
+		runBackupPin(mgr, subcommand)
+	case "diff":
+		runBackupDiff(mgr)
 	default:
+		if cli.Std.JSONErrors {
+			cli.Std.Failf(cli.CodeUsage, "Available subcommands: create, list, restore, schedule, pin, unpin, diff", "Unknown backup subcommand: %s", subcommand)
+		}
 		fmt.Fprintf(os.Stderr, "Unknown backup subcommand: %s\n", subcommand)
-		fmt.Println("Available subcommands: create, list, restore")
+		fmt.Println("Available subcommands: create, list, restore, schedule, pin, unpin, diff")
 		os.Exit(1)
 	}
 }
 
-func runBackupCreate(mgr *backup.Manager) {
-	// Backups are always enabled
-	fmt.Fprintln(os.Stderr, "Creating database backup...")
-
-	var historyStore *history.Store
-	if cfg, err := config.Load(); err == nil {
-		historyStore = history.NewStore(cfg.StateDir)
+func runBackupCreate(mgr *backup.Manager, cfg *config.Config) {
+	createFlags := flag.NewFlagSet("create", flag.ContinueOnError)
+	dumpFormat := createFlags.String("dump-format", mgr.Config.DumpFormat, "pg_dump format: custom (.dump), plain (.sql) or directory (.dir)")
+	quiesce := createFlags.Bool("quiesce", false, "Stop the container's supervisor programs (SUPERVISOR_INCLUDE/SUPERVISOR_EXCLUDE) during the backup, as before an upgrade, and restart them afterward")
+	parseFlags(createFlags, os.Args[3:])
+	if !backup.ValidDumpFormat(*dumpFormat) {
+		cli.Std.Failf(cli.CodeUsage, "", "Error: --dump-format must be custom, plain or directory, got %q", *dumpFormat)
 	}
+	mgr.Config.DumpFormat = *dumpFormat
 
+	historyStore := history.NewStore(cfg.StateDir)
 	ctx := context.Background()
-	info, err := mgr.CreateBackup(ctx, backup.BackupMeta{
+	meta := backup.BackupMeta{
 		FromVersion:   "manual",
 		TargetVersion: "manual",
 		JobID:         fmt.Sprintf("manual-%d", time.Now().Unix()),
-	})
-	if err != nil {
-		if historyStore != nil {
-			_ = historyStore.Append(history.Event{
-				Type:    "backup",
-				Status:  "failed",
-				Message: err.Error(),
-				Data: map[string]string{
-					"fromVersion":   "manual",
-					"targetVersion": "manual",
-				},
-			})
-		}
-		errResp := map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
-		jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-		fmt.Println(string(jsonOut))
-		os.Exit(1)
 	}
 
-	if historyStore != nil {
-		data := map[string]string{
-			"fromVersion":   "manual",
-			"targetVersion": "manual",
-			"backupPath":    info.Path,
-			"sizeBytes":     fmt.Sprintf("%d", info.Size),
-		}
+	// Backups are always enabled
+	var info *backup.BackupInfo
+	var quiesced []string
+	var err error
+	if *quiesce {
+		info, quiesced, err = createQuiescedBackup(ctx, cfg, mgr, meta)
+	} else {
+		cli.Std.Infof("Creating database backup...\n")
+		info, err = mgr.CreateBackup(ctx, meta)
+	}
+	if err != nil {
 		_ = historyStore.Append(history.Event{
 			Type:    "backup",
-			Status:  "succeeded",
-			Message: "Backup completed",
-			Data:    data,
+			Status:  "failed",
+			Message: err.Error(),
+			Data: map[string]string{
+				"fromVersion":   "manual",
+				"targetVersion": "manual",
+			},
 		})
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Backup failed: %v", err)
+	}
+
+	data := map[string]string{
+		"fromVersion":   "manual",
+		"targetVersion": "manual",
+		"backupPath":    info.Path,
+		"sizeBytes":     fmt.Sprintf("%d", info.Size),
 	}
+	if *quiesce {
+		data["quiescedPrograms"] = strings.Join(quiesced, ",")
+	}
+	_ = historyStore.Append(history.Event{
+		Type:    "backup",
+		Status:  "succeeded",
+		Message: "Backup completed",
+		Data:    data,
+	})
 
 	// Prune old backups
 	pruned, _ := mgr.PruneBackups(mgr.Config.Retention)
@@ -137,24 +175,175 @@ func runBackupCreate(mgr *backup.Manager) {
 		"success": true,
 		"backup":  info,
 	}
+	if *quiesce {
+		response["quiesced_programs"] = append([]string{}, quiesced...)
+	}
 	if len(pruned) > 0 {
 		response["pruned_count"] = len(pruned)
 	}
 
 	jsonOut, _ := json.MarshalIndent(response, "", "  ")
-	fmt.Println(string(jsonOut))
+	cli.Std.PrintJSON(jsonOut)
+}
+
+// createQuiescedBackup takes a manual backup with the Payram container's
+// supervisor programs stopped, as the pre-upgrade backup does, and restarts
+// them afterward. It refuses to run while an upgrade job is active, since the
+// upgrade manages those programs itself, and holds the restore lock
+// (Manager.LockOperation) until the programs are back. It also returns the
+// programs it stopped.
+func createQuiescedBackup(ctx context.Context, cfg *config.Config, mgr *backup.Manager, meta backup.BackupMeta) (*backup.BackupInfo, []string, error) {
+	if job, err := newJobStore(cfg).LoadLatest(); err == nil && job != nil && isJobActive(job) {
+		return nil, nil, fmt.Errorf("active job in progress (%s, state %s); a quiesced backup is blocked until it finishes", job.JobID, job.State)
+	}
+	// Hold the restore lock throughout, so no upgrade, restart or restore
+	// starts while the programs are stopped or the dump runs
+	lock, err := mgr.LockOperation("quiesced backup")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer lock.Release()
+
+	containerName := cfg.TargetContainerName
+	if containerName == "" {
+		imagePattern := "payramapp/payram:"
+		if cfg.ImageRepoOverride != "" {
+			imagePattern = cfg.ImageRepoOverride + ":"
+		}
+		discovered, err := container.NewDiscoverer(cfg.DockerBin, imagePattern, log.Default()).DiscoverPayramContainer(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover the Payram container to quiesce: %w", err)
+		}
+		containerName = discovered.Name
+	}
+
+	var info *backup.BackupInfo
+	runner := &dockerexec.Runner{DockerBin: cfg.DockerBin}
+	stopped, quiesced, err := supervisor.Around(ctx, runner, containerName, cfg.SupervisorInclude, cfg.SupervisorExclude, func() error {
+		cli.Std.Infof("Creating database backup with supervisor programs in %s stopped...\n", containerName)
+		var backupErr error
+		info, backupErr = mgr.CreateBackup(ctx, meta)
+		return backupErr
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !quiesced {
+		cli.Std.Warnf("supervisorctl is not available in %s; the backup was taken without quiescing\n", containerName)
+	} else if len(stopped) > 0 {
+		cli.Std.Infof("Supervisor programs restarted: %s\n", strings.Join(stopped, ", "))
+	}
+	return info, stopped, nil
+}
+
+// runBackupSchedule shows or changes the daemon's backup schedule in
+// updater-config.json. The daemon reads it at startup.
+func runBackupSchedule() {
+	scheduleFlags := flag.NewFlagSet("schedule", flag.ContinueOnError)
+	interval := scheduleFlags.Duration("interval", 0, "Take a backup this often, e.g. 24h (at least 1h)")
+	disable := scheduleFlags.Bool("disable", false, "Stop taking scheduled backups")
+	parseFlags(scheduleFlags, os.Args[3:])
+	if *interval != 0 && *disable {
+		cli.Std.Failf(cli.CodeUsage, "", "Error: --interval and --disable cannot be used together")
+	}
+
+	settingsPath, err := autoupdate.DefaultPath()
+	if err != nil {
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to resolve updater config path: %v", err)
+	}
+	settings, err := autoupdate.Load(settingsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "Updater is not initialized. Run 'payram-updater init' first.")
+		}
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to read updater config: %v", err)
+	}
+
+	switch {
+	case *disable:
+		settings.BackupScheduleEnabled = false
+	case *interval != 0:
+		if *interval%time.Minute != 0 {
+			cli.Std.Failf(cli.CodeUsage, "", "Error: --interval must be a whole number of minutes, got %s", *interval)
+		}
+		settings.BackupScheduleEnabled = true
+		settings.BackupScheduleIntervalMinutes = int(*interval / time.Minute)
+	default:
+		if settings.BackupScheduleEnabled {
+			fmt.Printf("Scheduled backups: every %s\n", time.Duration(settings.BackupScheduleIntervalMinutes)*time.Minute)
+		} else {
+			fmt.Println("Scheduled backups: disabled")
+		}
+		return
+	}
+
+	if err := autoupdate.Save(settingsPath, settings); err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to write updater config: %v", err)
+	}
+
+	if settings.BackupScheduleEnabled {
+		fmt.Printf("Scheduled backups enabled: every %s, keeping the newest BACKUP_RETENTION backups.\n", *interval)
+	} else {
+		fmt.Println("Scheduled backups disabled.")
+	}
+	fmt.Println("Restart the daemon to apply: payram-updater restart")
+}
+
+// runBackupPin pins or unpins a backup, depending on subcommand. Pinned
+// backups are kept by every prune, whatever BACKUP_RETENTION is.
+func runBackupPin(mgr *backup.Manager, subcommand string) {
+	pinFlags := flag.NewFlagSet(subcommand, flag.ContinueOnError)
+	filePath := pinFlags.String("file", "", "Path to backup file (required)")
+	parseFlags(pinFlags, os.Args[3:])
+	if *filePath == "" {
+		cli.Std.Failf(cli.CodeUsage, fmt.Sprintf("Usage: payram-updater backup %s --file /path/to/backup.dump", subcommand), "Error: --file is required")
+	}
+
+	pin := mgr.PinBackup
+	if subcommand == "unpin" {
+		pin = mgr.UnpinBackup
+	}
+	item, err := pin(*filePath)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to %s backup: %v", subcommand, err)
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"backup":  item,
+	}
+	jsonOut, _ := json.MarshalIndent(response, "", "  ")
+	cli.Std.PrintJSON(jsonOut)
+}
+
+// runBackupDiff compares two backups: their metadata and, for custom and
+// directory format backups, the objects added and removed between them.
+func runBackupDiff(mgr *backup.Manager) {
+	diffFlags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fileA := diffFlags.String("a", "", "Path to the older backup file (required)")
+	fileB := diffFlags.String("b", "", "Path to the newer backup file (required)")
+	parseFlags(diffFlags, os.Args[3:])
+	if *fileA == "" || *fileB == "" {
+		cli.Std.Failf(cli.CodeUsage, "Usage: payram-updater backup diff --a /path/to/old.dump --b /path/to/new.dump", "Error: --a and --b are required")
+	}
+
+	diff, err := mgr.DiffBackups(context.Background(), *fileA, *fileB)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to compare backups: %v", err)
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"diff":    diff,
+	}
+	jsonOut, _ := json.MarshalIndent(response, "", "  ")
+	cli.Std.PrintJSON(jsonOut)
 }
 
 func runBackupList(mgr *backup.Manager) {
 	backups, err := mgr.ListBackups()
 	if err != nil {
-		errResp := map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
-		jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-		fmt.Println(string(jsonOut))
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to list backups: %v", err)
 	}
 
 	// Return JSON matching spec
@@ -165,11 +354,11 @@ func runBackupList(mgr *backup.Manager) {
 	}
 
 	jsonOut, _ := json.MarshalIndent(response, "", "  ")
-	fmt.Println(string(jsonOut))
+	cli.Std.PrintJSON(jsonOut)
 }
 
 // parseBackupFilename extracts version metadata from a backup filename.
-// Expected format: payram-backup-YYYYMMDD-HHMMSS-fromVer-to-toVer.(sql|dump)
+// Expected format: payram-backup-YYYYMMDD-HHMMSS-fromVer-to-toVer.(sql|dump|dir|snapshot)
 func parseBackupFilename(filename string) struct {
 	FromVersion string
 	ToVersion   string
@@ -186,6 +375,8 @@ func parseBackupFilename(filename string) struct {
 	name := strings.TrimPrefix(filename, "payram-backup-")
 	name = strings.TrimSuffix(name, ".sql")
 	name = strings.TrimSuffix(name, ".dump")
+	name = strings.TrimSuffix(name, ".dir")
+	name = strings.TrimSuffix(name, ".snapshot")
 
 	// Split by '-'
 	parts := strings.Split(name, "-")
@@ -217,7 +408,7 @@ func parseBackupFilename(filename string) struct {
 // 5. Verifies the container is running
 func performContainerRollback(ctx context.Context, targetVersion string) error {
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -305,49 +496,103 @@ func performContainerRollback(ctx context.Context, targetVersion string) error {
 	return nil
 }
 
+// restoreUsage is printed when backup restore is missing --file.
+const restoreUsage = `Usage: payram-updater backup restore --file /path/to/backup.dump [--yes] [--full-recovery] [--compare-checksum]
+       payram-updater backup restore --job-id JOB_ID [--yes] [--full-recovery] [--compare-checksum]
+       payram-updater backup restore --file /path/to/backup.dump --bootstrap --image repo:tag [--port ...] [--volume ...] [--env-file ...]
+       payram-updater backup restore --file /path/to/backup.dump --into-new-version VERSION [--image repo:tag] [--env ...] [--env-file ...]`
+
 func runBackupRestore(mgr *backup.Manager) {
 	// Parse restore flags
-	restoreFlags := flag.NewFlagSet("restore", flag.ExitOnError)
-	filePath := restoreFlags.String("file", "", "Path to backup file (required)")
+	restoreFlags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	filePath := restoreFlags.String("file", "", "Path to backup file (required unless --job-id is given)")
+	jobID := restoreFlags.String("job-id", "", "Restore the pre-upgrade backup created by this upgrade job")
 	confirmed := restoreFlags.Bool("yes", false, "Skip confirmation prompt")
 	fullRecovery := restoreFlags.Bool("full-recovery", false, "Perform full recovery (DB restore + container rollback) without prompt")
-
-	if err := restoreFlags.Parse(os.Args[3:]); err != nil {
-		os.Exit(1)
+	compareChecksum := restoreFlags.Bool("compare-checksum", false, "After restoring a custom or directory format backup, compare its object counts with the database and warn on differences")
+	bootstrapMode := restoreFlags.Bool("bootstrap", false, "Create a new container (no existing container required) and restore into it")
+	intoVersion := restoreFlags.String("into-new-version", "", "Restore into a throwaway container running this version to check its migrations, then remove it")
+	image := restoreFlags.String("image", "", "Image repo:tag for --bootstrap or --into-new-version")
+	containerName := restoreFlags.String("name", "", "Container name for --bootstrap (default: manifest container name)")
+	var ports, volumes, envVars stringListFlag
+	restoreFlags.Var(&ports, "port", "Port mapping for --bootstrap, [hostIP:]hostPort:containerPort[/proto] (repeatable)")
+	restoreFlags.Var(&volumes, "volume", "Volume for --bootstrap, source:destination[:ro] (repeatable)")
+	restoreFlags.Var(&envVars, "env", "Environment variable for --bootstrap or --into-new-version, KEY=VALUE (repeatable)")
+	envFile := restoreFlags.String("env-file", "", "File of KEY=VALUE environment variables for --bootstrap or --into-new-version")
+
+	parseFlags(restoreFlags, os.Args[3:])
+
+	if *jobID != "" {
+		if *filePath != "" {
+			cli.Std.Failf(cli.CodeUsage, "", "Error: --file and --job-id cannot be combined")
+		}
+		*filePath = resolveJobBackup(*jobID)
 	}
-
 	if *filePath == "" {
-		fmt.Fprintln(os.Stderr, "Error: --file is required")
-		fmt.Fprintln(os.Stderr, "Usage: payram-updater backup restore --file /path/to/backup.dump [--yes] [--full-recovery]")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeUsage, restoreUsage, "Error: --file or --job-id is required")
 	}
 
 	// Verify the file exists
 	if err := mgr.VerifyBackupFile(*filePath); err != nil {
-		errResp := map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
-		jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-		fmt.Println(string(jsonOut))
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Invalid backup file: %v", err)
 	}
 
-	// Parse backup metadata to determine if recovery is needed
-	ctx := context.Background()
-	filename := filepath.Base(*filePath)
-	metadata := parseBackupFilename(filename)
-	needsRecovery := metadata.FromVersion != "unknown" && metadata.ToVersion != "unknown"
+	// A migration check never touches the production container or database,
+	// so it is not blocked by an active job
+	if *intoVersion != "" {
+		if *bootstrapMode || *fullRecovery {
+			cli.Std.Failf(cli.CodeUsage, "", "Error: --into-new-version cannot be combined with --bootstrap or --full-recovery")
+		}
+		runMigrationCheck(mgr, migrationCheckOptions{
+			filePath:  *filePath,
+			version:   *intoVersion,
+			image:     *image,
+			env:       envVars,
+			envFile:   *envFile,
+			confirmed: *confirmed,
+		})
+		return
+	}
 
 	var historyStore *history.Store
 	var latestJob *jobs.Job
-	if cfg, err := config.Load(); err == nil {
+	confirmPhrase := cli.DefaultConfirmPhrase
+	if cfg, err := loadConfig(); err == nil {
 		historyStore = history.NewStore(cfg.StateDir)
-		if job, loadErr := jobs.NewStore(cfg.StateDir).LoadLatest(); loadErr == nil {
+		confirmPhrase = cfg.RestoreConfirmPhrase
+		if job, loadErr := newJobStore(cfg).LoadLatest(); loadErr == nil {
 			latestJob = job
 		}
 	}
 
+	// A restore must not race an upgrade that is still writing to the database
+	if latestJob != nil && isJobActive(latestJob) {
+		cli.Std.Failf(cli.CodeJobActive, "", "Active job in progress (%s, state %s). Restore is blocked until it finishes.", latestJob.JobID, latestJob.State)
+	}
+
+	if *bootstrapMode {
+		if *fullRecovery {
+			cli.Std.Failf(cli.CodeUsage, "", "Error: --bootstrap and --full-recovery cannot be combined")
+		}
+		runBootstrapRestore(mgr, bootstrapRestoreOptions{
+			filePath:      *filePath,
+			image:         *image,
+			containerName: *containerName,
+			ports:         ports,
+			volumes:       volumes,
+			env:           envVars,
+			envFile:       *envFile,
+			confirmed:     *confirmed,
+		})
+		return
+	}
+
+	// Parse backup metadata to determine if recovery is needed
+	ctx := context.Background()
+	filename := filepath.Base(*filePath)
+	metadata := parseBackupFilename(filename)
+	needsRecovery := metadata.FromVersion != "unknown" && metadata.ToVersion != "unknown"
+
 	// Determine if full recovery will be performed
 	doFullRecovery := *fullRecovery
 	var rollbackContainerName string
@@ -370,9 +615,12 @@ func runBackupRestore(mgr *backup.Manager) {
 			// Default to option 2
 			doFullRecovery = true
 			// User has explicitly chosen full recovery - this counts as confirmation
-			// for the subsequent database restore (no redundant prompt needed)
-			*confirmed = true
-			fmt.Fprintln(os.Stderr, "\n✓ Full recovery mode selected - container rollback + database restore")
+			// for the subsequent database restore (no redundant prompt needed),
+			// unless a confirmation phrase is configured: Enter alone selects it
+			if confirmPhrase == cli.DefaultConfirmPhrase {
+				*confirmed = true
+			}
+			cli.Std.Infof("\n✓ Full recovery mode selected - container rollback + database restore\n")
 		}
 	}
 
@@ -381,46 +629,59 @@ func runBackupRestore(mgr *backup.Manager) {
 		*confirmed = true
 	}
 
+	// Interactive confirmation if --yes not provided, before anything is
+	// changed: a full recovery replaces the container before the restore
+	// (Full recovery users already confirmed via recovery mode selection)
+	if !*confirmed {
+		fmt.Println("\nWARNING: This will restore the database from backup.")
+		fmt.Println("All current data will be REPLACED with backup contents.")
+		fmt.Printf("\nBackup file: %s\n", *filePath)
+		if doFullRecovery && needsRecovery {
+			fmt.Printf("Target: Rollback container (version %s), which replaces the current container first\n", metadata.FromVersion)
+		} else {
+			fmt.Printf("Target database: %s@%s:%d/%s\n",
+				mgr.Config.PGUser, mgr.Config.PGHost, mgr.Config.PGPort, mgr.Config.PGDB)
+		}
+		if !cli.NewConfirmer().ConfirmPhrase(confirmPhrase) {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "Restore cancelled. Nothing was changed.")
+		}
+		*confirmed = true
+	} else if doFullRecovery && needsRecovery {
+		// Log why confirmation was skipped for full recovery
+		cli.Std.Infof("✓ Skipping redundant confirmation (already confirmed via recovery mode selection)\n")
+	}
+
+	// Hold the restore lock across the container rollback as well, so a
+	// second restore cannot start between the rollback and the DB restore.
+	// A lock left behind by os.Exit below is stale once this process ends.
+	restoreLock, err := mgr.LockRestore(*filePath)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Restore is blocked: %v", err)
+	}
+	defer restoreLock.Release()
+
 	// CRITICAL SEQUENCING FIX: If full recovery is requested, roll back container FIRST
 	// This ensures database restore happens inside the rollback container, not the failed one
 	if doFullRecovery && needsRecovery {
 		if isSuccessfulUpgradeJob(latestJob) {
-			errResp := map[string]interface{}{
-				"success": false,
-				"error":   "Rollback is blocked because the latest upgrade completed successfully. Re-run restore in database-only mode.",
-			}
-			jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-			fmt.Println(string(jsonOut))
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "Re-run restore in database-only mode.", "Rollback is blocked because the latest upgrade completed successfully.")
 		}
 
-		fmt.Fprintln(os.Stderr, "\n⚠️  Full recovery mode: Rolling back container BEFORE database restore...")
-		fmt.Fprintf(os.Stderr, "This ensures database restore happens inside the rollback container (version %s)\n\n", metadata.FromVersion)
+		cli.Std.Infof("\n⚠️  Full recovery mode: Rolling back container BEFORE database restore...\n")
+		cli.Std.Infof("This ensures database restore happens inside the rollback container (version %s)\n\n", metadata.FromVersion)
 
 		if err := performContainerRollback(ctx, metadata.FromVersion); err != nil {
-			errResp := map[string]interface{}{
-				"success": false,
-				"error":   fmt.Sprintf("❌ Container rollback failed: %v\nDatabase NOT restored.", err),
-			}
-			jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-			fmt.Println(string(jsonOut))
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "Database NOT restored.", "❌ Container rollback failed: %v", err)
 		}
 
-		fmt.Fprintf(os.Stderr, "✅ Container rolled back to version %s\n", metadata.FromVersion)
-		fmt.Fprintln(os.Stderr, "Waiting for database readiness...")
+		cli.Std.Infof("✅ Container rolled back to version %s\n", metadata.FromVersion)
+		cli.Std.Infof("Waiting for database readiness...\n")
 		time.Sleep(5 * time.Second)
 
 		// Get the container name for restore
-		cfg, err := config.Load()
+		cfg, err := loadConfig()
 		if err != nil {
-			errResp := map[string]interface{}{
-				"success": false,
-				"error":   fmt.Sprintf("Failed to load config: %v", err),
-			}
-			jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-			fmt.Println(string(jsonOut))
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
 		}
 
 		imagePattern := "payramapp/payram:"
@@ -430,59 +691,29 @@ func runBackupRestore(mgr *backup.Manager) {
 
 		if cfg.TargetContainerName != "" {
 			rollbackContainerName = cfg.TargetContainerName
-			fmt.Fprintf(os.Stderr, "Rollback container ready: %s\n", rollbackContainerName)
+			cli.Std.Infof("Rollback container ready: %s\n", rollbackContainerName)
 		} else {
 			discoverer := container.NewDiscoverer(cfg.DockerBin, imagePattern, log.Default())
 			discovered, err := discoverer.DiscoverPayramContainer(ctx)
 			if err != nil {
-				errResp := map[string]interface{}{
-					"success": false,
-					"error":   fmt.Sprintf("Failed to discover rollback container: %v", err),
-				}
-				jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-				fmt.Println(string(jsonOut))
-				os.Exit(1)
+				cli.Std.Failf(cli.CodeContainerUnresolved, "", "Failed to discover rollback container: %v", err)
 			}
 			rollbackContainerName = discovered.Name
-			fmt.Fprintf(os.Stderr, "Rollback container ready: %s\n", rollbackContainerName)
-		}
-	}
-
-	// Interactive confirmation if --yes not provided
-	// (Full recovery users already confirmed via recovery mode selection)
-	if !*confirmed {
-		fmt.Println("\nWARNING: This will restore the database from backup.")
-		fmt.Println("All current data will be REPLACED with backup contents.")
-		fmt.Printf("\nBackup file: %s\n", *filePath)
-		if doFullRecovery && needsRecovery {
-			fmt.Printf("Target: Rollback container (version %s)\n", metadata.FromVersion)
-		} else {
-			fmt.Printf("Target database: %s@%s:%d/%s\n",
-				mgr.Config.PGUser, mgr.Config.PGHost, mgr.Config.PGPort, mgr.Config.PGDB)
-		}
-		fmt.Print("\nType 'yes' to confirm: ")
-
-		var input string
-		fmt.Scanln(&input)
-		if strings.ToLower(strings.TrimSpace(input)) != "yes" {
-			fmt.Println("Restore cancelled.")
-			os.Exit(0)
+			cli.Std.Infof("Rollback container ready: %s\n", rollbackContainerName)
 		}
-		*confirmed = true
-	} else if doFullRecovery && needsRecovery {
-		// Log why confirmation was skipped for full recovery
-		fmt.Fprintln(os.Stderr, "✓ Skipping redundant confirmation (already confirmed via recovery mode selection)")
 	}
 
-	fmt.Fprintln(os.Stderr, "\nRestoring database from backup...")
+	cli.Std.Infof("\nRestoring database from backup...\n")
 	if doFullRecovery && needsRecovery {
-		fmt.Fprintf(os.Stderr, "Executing restore inside rollback container (version %s)...\n", metadata.FromVersion)
+		cli.Std.Infof("Executing restore inside rollback container (version %s)...\n", metadata.FromVersion)
 	}
 
 	result, err := mgr.RestoreBackup(ctx, *filePath, backup.RestoreOptions{
-		Confirmed:     *confirmed,
-		ContainerName: rollbackContainerName, // Use rollback container if full recovery
-		FullRecovery:  doFullRecovery,
+		Confirmed:       *confirmed,
+		ContainerName:   rollbackContainerName, // Use rollback container if full recovery
+		FullRecovery:    doFullRecovery,
+		Locked:          true,
+		CompareChecksum: *compareChecksum,
 	})
 	if err != nil {
 		if historyStore != nil {
@@ -498,13 +729,7 @@ func runBackupRestore(mgr *backup.Manager) {
 				},
 			})
 		}
-		errResp := map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
-		jsonOut, _ := json.MarshalIndent(errResp, "", "  ")
-		fmt.Println(string(jsonOut))
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Restore failed: %v", err)
 	}
 
 	if historyStore != nil {
@@ -521,11 +746,15 @@ func runBackupRestore(mgr *backup.Manager) {
 		})
 	}
 
-	fmt.Fprintln(os.Stderr, "\n✅ Database restored successfully.")
+	cli.Std.Infof("\n✅ Database restored successfully.\n")
+	for _, warning := range result.Warnings {
+		cli.Std.Warnf("%s\n", warning)
+	}
 
 	if doFullRecovery && needsRecovery {
-		fmt.Fprintf(os.Stderr, "\n✅ Full recovery completed successfully.\n")
-		fmt.Fprintf(os.Stderr, "Service restored to version %s with database from backup.\n", metadata.FromVersion)
+		cli.Std.Infof("\n✅ Full recovery completed successfully.\n")
+		cli.Std.Infof("Service restored to version %s with database from backup.\n", metadata.FromVersion)
+		reconcileRestoredVersion(latestJob, metadata.FromVersion, *filePath)
 	}
 
 	response := map[string]interface{}{
@@ -536,10 +765,67 @@ func runBackupRestore(mgr *backup.Manager) {
 		"toVersion":    result.ToVersion,
 		"fullRecovery": doFullRecovery,
 	}
+	if len(result.Warnings) > 0 {
+		response["warnings"] = result.Warnings
+	}
 	jsonOut, _ := json.MarshalIndent(response, "", "  ")
 	fmt.Println(string(jsonOut))
 }
 
+// resolveJobBackup returns the backup created by the upgrade job jobID: the
+// BackupPath of the latest job, or for an older job the newest successful
+// backup the history recorded for it.
+func resolveJobBackup(jobID string) string {
+	cfg, err := loadConfig()
+	if err != nil {
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
+	}
+
+	path := ""
+	if job, err := newJobStore(cfg).LoadLatest(); err == nil && job != nil && job.JobID == jobID {
+		path = job.BackupPath
+	}
+	if path == "" {
+		path, err = history.NewStore(cfg.StateDir).JobBackupPath(jobID)
+		if err != nil {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to read the upgrade history: %v", err)
+		}
+	}
+	if path == "" {
+		cli.Std.Failf(cli.CodeOperationFailed, "Use 'payram-updater backup list' to pick a backup and pass it with --file.",
+			"No backup recorded for job %s", jobID)
+	}
+
+	cli.Std.Infof("Job %s created backup %s\n", jobID, path)
+	return path
+}
+
+// reconcileRestoredVersion records the version a full recovery rolled back
+// to as the tracked job state, so inspect no longer reports the failed
+// upgrade target. Failures only warn: the restore itself has succeeded.
+func reconcileRestoredVersion(previous *jobs.Job, version, backupFile string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		cli.Std.Warnf("failed to update internal state: %v\n", err)
+		return
+	}
+	previousVersion := "unknown"
+	if previous != nil {
+		previousVersion = previous.ResolvedTarget
+	}
+
+	jobStore := newJobStore(cfg)
+	message := fmt.Sprintf("Reconciled after full-recovery restore (was %s, now %s)", previousVersion, version)
+	if _, err := jobStore.Reconcile("restore", version, message); err != nil {
+		cli.Std.Warnf("failed to update internal state: %v\n", err)
+		return
+	}
+	if err := jobStore.AppendLog(fmt.Sprintf("RESTORE: Full recovery from %s. Running version: %s (was: %s)", backupFile, version, previousVersion)); err != nil {
+		cli.Std.Warnf("failed to write log: %v\n", err)
+	}
+	cli.Std.Infof("Internal state updated to version %s.\n", version)
+}
+
 func isSuccessfulUpgradeJob(job *jobs.Job) bool {
 	if job == nil {
 		return false
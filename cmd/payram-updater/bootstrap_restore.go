@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/bootstrap"
+	"github.com/payram/payram-updater/internal/cli"
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/coreclient"
+	"github.com/payram/payram-updater/internal/dockerexec"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+// bootstrapRestoreOptions holds the `backup restore --bootstrap` flags.
+type bootstrapRestoreOptions struct {
+	filePath      string
+	image         string
+	containerName string
+	ports         []string
+	volumes       []string
+	env           []string
+	envFile       string
+	confirmed     bool
+}
+
+// runBootstrapRestore creates a new Payram container on a host that has none
+// and restores the backup into it.
+func runBootstrapRestore(mgr *backup.Manager, opts bootstrapRestoreOptions) {
+	if opts.image == "" {
+		cli.Std.Failf(cli.CodeUsage, "", "Error: --image repo:tag is required with --bootstrap")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
+	}
+
+	spec := bootstrap.Spec{Image: opts.image, ContainerName: opts.containerName}
+	if spec.ContainerName == "" {
+		spec.ContainerName = cfg.TargetContainerName
+	}
+	for _, value := range opts.ports {
+		port, err := bootstrap.ParsePort(value)
+		if err != nil {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "%v", err)
+		}
+		spec.Ports = append(spec.Ports, port)
+	}
+	for _, value := range opts.volumes {
+		mount, err := bootstrap.ParseMount(value)
+		if err != nil {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "%v", err)
+		}
+		spec.Mounts = append(spec.Mounts, mount)
+	}
+	if opts.envFile != "" {
+		env, err := bootstrap.ReadEnvFile(opts.envFile)
+		if err != nil {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "%v", err)
+		}
+		spec.Env = append(spec.Env, env...)
+	}
+	spec.Env = append(spec.Env, opts.env...)
+
+	ctx := context.Background()
+
+	// Manifest defaults fill in ports/volumes not given explicitly
+	if cfg.RuntimeManifestURL != "" {
+		client := manifest.NewClient(time.Duration(cfg.FetchTimeoutSeconds) * time.Second)
+		if manifestData, err := client.Fetch(ctx, cfg.RuntimeManifestURL); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: Failed to fetch manifest (%v); using explicit flags only\n", err)
+		} else {
+			spec.Manifest = manifestData
+		}
+	}
+
+	dockerArgs, containerName, err := bootstrap.BuildRunArgs(spec)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "%v", err)
+	}
+
+	if !opts.confirmed {
+		fmt.Println("\nWARNING: This will create a new Payram container and restore the database from backup.")
+		fmt.Printf("\nBackup file: %s\n", opts.filePath)
+		fmt.Printf("Container:   %s\n", containerName)
+		fmt.Printf("Command:     %s\n", container.FormatRunCommand(dockerArgs))
+		if !cli.NewConfirmer().ConfirmPhrase(cfg.RestoreConfirmPhrase) {
+			fmt.Println("Restore cancelled.")
+			os.Exit(0)
+		}
+	}
+
+	runner := &dockerexec.Runner{DockerBin: cfg.DockerBin, Logger: log.Default()}
+	healthCheck := func(ctx context.Context) error {
+		baseURL := discoverCoreBaseURLWithContainer(ctx, cfg, containerName)
+		healthCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		resp, err := coreclient.NewClient(baseURL).Health(healthCtx)
+		if err != nil {
+			return err
+		}
+		if resp.Status != "ok" {
+			return fmt.Errorf("health status %q", resp.Status)
+		}
+		return nil
+	}
+
+	cli.Std.Infof("\nCreating container %s from %s...\n", containerName, opts.image)
+	bootstrapper := bootstrap.NewBootstrapper(runner, mgr, healthCheck, log.New(cli.Std.Writer(cli.VerbosityNormal), "", 0))
+	result, err := bootstrapper.Run(ctx, spec, opts.filePath)
+
+	historyStore := history.NewStore(cfg.StateDir)
+	eventData := map[string]string{
+		"backupFile": opts.filePath,
+		"image":      opts.image,
+		"container":  containerName,
+		"bootstrap":  "true",
+	}
+	if err != nil {
+		_ = historyStore.Append(history.Event{
+			Type:    "restore",
+			Status:  "failed",
+			Message: err.Error(),
+			Data:    eventData,
+		})
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Bootstrap restore failed: %v", err)
+	}
+	_ = historyStore.Append(history.Event{
+		Type:    "restore",
+		Status:  "succeeded",
+		Message: fmt.Sprintf("Bootstrapped %s and restored database", containerName),
+		Data:    eventData,
+	})
+
+	cli.Std.Infof("\n✅ Container created and database restored successfully.\n")
+
+	response := map[string]interface{}{
+		"success":    true,
+		"message":    "Container bootstrapped and database restored successfully",
+		"backupFile": opts.filePath,
+		"container":  result.ContainerName,
+		"image":      result.Image,
+		"bootstrap":  true,
+	}
+	jsonOut, _ := json.MarshalIndent(response, "", "  ")
+	fmt.Println(string(jsonOut))
+}
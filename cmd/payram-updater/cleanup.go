@@ -6,14 +6,12 @@ import (
 	"os"
 	"strings"
 
-	"github.com/payram/payram-updater/internal/config"
-	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/cli"
 )
 
 func runCleanup() {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: payram-updater cleanup <state|backups> [--yes]")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeUsage, "", "Usage: payram-updater cleanup <state|backups> [--yes]")
 	}
 
 	subcommand := os.Args[2]
@@ -25,22 +23,19 @@ func runCleanup() {
 	}
 
 	if subcommand != "state" && subcommand != "backups" {
-		fmt.Fprintln(os.Stderr, "Invalid cleanup target. Use 'state' or 'backups'.")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeUsage, "", "Invalid cleanup target. Use 'state' or 'backups'.")
 	}
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
 	}
 
 	// Block cleanup if a job is active
-	jobStore := jobs.NewStore(cfg.StateDir)
+	jobStore := newJobStore(cfg)
 	if job, err := jobStore.LoadLatest(); err == nil && job != nil && isJobActive(job) {
-		fmt.Fprintln(os.Stderr, "Active job in progress. Cleanup is blocked.")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeJobActive, "", "Active job in progress. Cleanup is blocked.")
 	}
 
 	// Require confirmation unless --yes was provided
@@ -49,8 +44,7 @@ func runCleanup() {
 		reader := bufio.NewReader(os.Stdin)
 		input, _ := reader.ReadString('\n')
 		if strings.ToLower(strings.TrimSpace(input)) != "yes" {
-			fmt.Fprintln(os.Stderr, "Cleanup cancelled.")
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "", "Cleanup cancelled.")
 		}
 	}
 
@@ -62,12 +56,10 @@ func runCleanup() {
 	}
 
 	if err := os.RemoveAll(targetDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to remove %s directory: %v\n", subcommand, err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to remove %s directory: %v", subcommand, err)
 	}
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to recreate %s directory: %v\n", subcommand, err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to recreate %s directory: %v", subcommand, err)
 	}
 
 	fmt.Printf("Cleanup complete: %s\n", subcommand)
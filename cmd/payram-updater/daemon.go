@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
@@ -13,11 +14,13 @@ import (
 	"time"
 
 	"github.com/payram/payram-updater/internal/autoupdate"
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/cli"
 	"github.com/payram/payram-updater/internal/config"
 	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/dockerexec"
 	internalhttp "github.com/payram/payram-updater/internal/http"
-	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/httpclient"
 	"github.com/payram/payram-updater/internal/logger"
 	"github.com/payram/payram-updater/internal/network"
 )
@@ -30,6 +33,13 @@ func runServe() {
 		logger.Error("Daemon", "runServe", err)
 		os.Exit(1)
 	}
+	if err := httpclient.Configure(cfg.TLSCAFile); err != nil {
+		logger.Error("Daemon", "runServe", err)
+		os.Exit(1)
+	}
+	for _, warning := range cfg.PermissionWarnings {
+		logger.Warnf("Daemon", "runServe", "%s", warning)
+	}
 
 	settingsPath, err := autoupdate.DefaultPath()
 	if err != nil {
@@ -52,6 +62,11 @@ func runServe() {
 
 	cfg.AutoUpdateEnabled = settings.AutoUpdateEnabled
 	cfg.AutoUpdateInterval = settings.AutoUpdateIntervalHours
+	if settings.AutoUpdateMaxAttempts > 0 {
+		cfg.AutoUpdateMaxAttempts = settings.AutoUpdateMaxAttempts
+	}
+	cfg.BackupScheduleEnabled = settings.BackupScheduleEnabled
+	cfg.BackupScheduleInterval = settings.BackupScheduleIntervalMinutes
 
 	logger.Infof("Daemon", "runServe", "payram-updater starting with config:")
 	logger.Infof("Daemon", "runServe", "Port: %d", cfg.Port)
@@ -64,9 +79,26 @@ func runServe() {
 	logger.Infof("Daemon", "runServe", "DockerBin: %s", cfg.DockerBin)
 	logger.Infof("Daemon", "runServe", "AutoUpdateEnabled: %v", cfg.AutoUpdateEnabled)
 	logger.Infof("Daemon", "runServe", "AutoUpdateIntervalHours: %d", cfg.AutoUpdateInterval)
+	logger.Infof("Daemon", "runServe", "AutoUpdateMaxAttempts: %d", cfg.AutoUpdateMaxAttempts)
+	logger.Infof("Daemon", "runServe", "BackupScheduleEnabled: %v", cfg.BackupScheduleEnabled)
+	if cfg.BackupScheduleEnabled {
+		logger.Infof("Daemon", "runServe", "BackupScheduleIntervalMinutes: %d", cfg.BackupScheduleInterval)
+	}
+
+	// Refuse to start with a DOCKER_BIN that is not docker: every operation
+	// would fail later with a confusing exec error
+	if err := dockerexec.CheckBinary(context.Background(), cfg.DockerBin); err != nil {
+		logger.ErrorMsg("Daemon", "runServe", fmt.Sprintf("%v. %s", err, dockerexec.BinaryFixHint))
+		os.Exit(1)
+	}
+
+	// Surface socket permission problems at startup rather than on the first upgrade
+	if err := backup.CheckDockerDaemon(context.Background(), cfg.DockerBin); errors.Is(err, backup.ErrDockerPermissionDenied) {
+		logger.Warnf("Daemon", "runServe", "Cannot access Docker: %v. Run the updater as root or add its user to the docker group.", err)
+	}
 
 	// Create job store
-	jobStore := jobs.NewStore(cfg.StateDir)
+	jobStore := newJobStore(cfg)
 
 	// Create and start the HTTP server
 	server := internalhttp.New(cfg, jobStore)
@@ -77,26 +109,27 @@ func runServe() {
 }
 
 func runInit() {
-	initCmd := flag.NewFlagSet("init", flag.ExitOnError)
+	initCmd := flag.NewFlagSet("init", flag.ContinueOnError)
 	noAutoUpdate := initCmd.Bool("no-autoupdate", false, "Disable auto-updates without prompting")
-	initCmd.Parse(os.Args[2:])
+	maxAttempts := initCmd.Int("max-attempts", config.DefaultAutoUpdateMaxAttempts, "Failed auto-update attempts at one target version before auto-update skips it")
+	parseFlags(initCmd, os.Args[2:])
+	if *maxAttempts < 1 {
+		cli.Std.Failf(cli.CodeUsage, "", "--max-attempts must be at least 1, got %d", *maxAttempts)
+	}
 
 	reader := bufio.NewReader(os.Stdin)
 
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load config: %v", err)
 	}
 
 	if err := checkPayramContainer(cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "Init check failed: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Init check failed: %v", err)
 	}
 
 	if err := checkUpdaterPortExposure(cfg.Port); err != nil {
-		fmt.Fprintf(os.Stderr, "Init check failed: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Init check failed: %v", err)
 	}
 
 	var autoUpdateEnabled bool
@@ -118,23 +151,21 @@ func runInit() {
 	settings := &autoupdate.Settings{
 		AutoUpdateEnabled:       autoUpdateEnabled,
 		AutoUpdateIntervalHours: autoUpdateInterval,
+		AutoUpdateMaxAttempts:   *maxAttempts,
 		Initialized:             true,
 	}
 
 	settingsPath, err := autoupdate.DefaultPath()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to resolve auto update config path: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to resolve auto update config path: %v", err)
 	}
 
 	if err := autoupdate.Save(settingsPath, settings); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to write auto update config: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to write auto update config: %v", err)
 	}
 
-	if err := ensureSupervisorEnvConfig("/etc/payram/updater.env"); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to update supervisor config in updater.env: %v\n", err)
-		os.Exit(1)
+	if err := ensureSupervisorEnvConfig(config.FilePath()); err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to update supervisor config in updater.env: %v", err)
 	}
 
 	fmt.Printf("Initialization complete. Updated %s\n", settingsPath)
@@ -148,8 +179,7 @@ func runRestart() {
 	if _, err := os.Stat(systemctlPath); os.IsNotExist(err) {
 		systemctlPath = "/bin/systemctl"
 		if _, err := os.Stat(systemctlPath); os.IsNotExist(err) {
-			fmt.Fprintln(os.Stderr, "Error: systemctl not found. This command requires systemd.")
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "", "Error: systemctl not found. This command requires systemd.")
 		}
 	}
 
@@ -157,11 +187,11 @@ func runRestart() {
 	cmd := exec.Command("sudo", systemctlPath, "restart", "payram-updater")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to restart service: %v\n", err)
+		hint := ""
 		if len(output) > 0 {
-			fmt.Fprintf(os.Stderr, "Output: %s\n", string(output))
+			hint = fmt.Sprintf("Output: %s", string(output))
 		}
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, hint, "Failed to restart service: %v", err)
 	}
 
 	fmt.Println("Service restarted successfully.")
@@ -256,7 +286,7 @@ func ensureSupervisorEnvConfig(path string) error {
 	contentBytes, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Warning: %s not found; supervisor config not persisted\n", path)
+			cli.Std.Warnf("%s not found; supervisor config not persisted\n", path)
 			return nil
 		}
 		return err
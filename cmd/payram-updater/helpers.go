@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -10,8 +11,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/payram/payram-updater/internal/cli"
 	"github.com/payram/payram-updater/internal/config"
 	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/httpclient"
 	"github.com/payram/payram-updater/internal/jobs"
 )
 
@@ -25,6 +28,7 @@ func discoverCoreBaseURLOrDefault(ctx context.Context, cfg *config.Config) strin
 func discoverCoreBaseURLWithContainer(ctx context.Context, cfg *config.Config, containerNameOverride string) string {
 	// 1. Use explicit CORE_BASE_URL if set
 	if cfg.CoreBaseURL != "" {
+		cli.Std.Debugf("Payram Core URL from CORE_BASE_URL: %s\n", cfg.CoreBaseURL)
 		return cfg.CoreBaseURL
 	}
 
@@ -54,8 +58,7 @@ func discoverCoreBaseURLWithContainer(ctx context.Context, cfg *config.Config, c
 				return fmt.Sprintf("%s://127.0.0.1:%s", identifiedPort.Scheme, identifiedPort.HostPort)
 			}
 		}
-		fmt.Fprintf(os.Stderr, "WARNING: Failed to identify port for container %s\n", cfg.TargetContainerName)
-		fmt.Fprintf(os.Stderr, "Falling back to http://127.0.0.1:8080\n")
+		cli.Std.Warnf("Failed to identify port for container %s; falling back to http://127.0.0.1:8080\n", cfg.TargetContainerName)
 		return "http://127.0.0.1:8080"
 	}
 
@@ -68,28 +71,26 @@ func discoverCoreBaseURLWithContainer(ctx context.Context, cfg *config.Config, c
 	discoverer := container.NewDiscoverer(cfg.DockerBin, imagePattern, nullLogger)
 	discovered, err := discoverer.DiscoverPayramContainer(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "WARNING: Failed to discover Payram container: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Falling back to http://127.0.0.1:8080\n")
+		cli.Std.Warnf("Failed to discover Payram container: %v; falling back to http://127.0.0.1:8080\n", err)
 		return "http://127.0.0.1:8080"
 	}
 
 	// Extract runtime state to get ports
 	runtimeState, err := inspector.ExtractRuntimeState(ctx, discovered.Name)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "WARNING: Failed to extract runtime state: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Falling back to http://127.0.0.1:8080\n")
+		cli.Std.Warnf("Failed to extract runtime state: %v; falling back to http://127.0.0.1:8080\n", err)
 		return "http://127.0.0.1:8080"
 	}
 
 	// Identify which port serves Payram Core
 	identifiedPort, err := identifier.IdentifyPayramCorePort(ctx, runtimeState)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "WARNING: Failed to identify Payram Core port: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Falling back to http://127.0.0.1:8080\n")
+		cli.Std.Warnf("Failed to identify Payram Core port: %v; falling back to http://127.0.0.1:8080\n", err)
 		return "http://127.0.0.1:8080"
 	}
 
 	coreBaseURL := fmt.Sprintf("%s://127.0.0.1:%s", identifiedPort.Scheme, identifiedPort.HostPort)
+	cli.Std.Debugf("Payram Core URL discovered from container %s: %s\n", discovered.Name, coreBaseURL)
 	return coreBaseURL
 }
 
@@ -133,23 +134,56 @@ func promptInt(reader *bufio.Reader, prompt string, defaultValue int) int {
 	}
 }
 
-func getPort() int {
-	// Load config the same way as daemon (env vars first, then /etc/payram/updater.env)
+// configWarned records that loadConfig has reported the config permission
+// warnings, so a command that loads the config twice warns once.
+var configWarned bool
+
+// loadConfig loads the configuration with config.Load and reports its
+// permission warnings on stderr, keeping stdout clean for JSON output.
+func loadConfig() (*config.Config, error) {
 	cfg, err := config.Load()
+	if err == nil {
+		if err := httpclient.Configure(cfg.TLSCAFile); err != nil {
+			return nil, err
+		}
+	}
+	if err == nil && !configWarned {
+		configWarned = true
+		for _, warning := range cfg.PermissionWarnings {
+			cli.Std.Warnf("%s\n", warning)
+		}
+	}
+	return cfg, err
+}
+
+func getPort() int {
+	// Load config the same way as daemon (env vars first, then the updater env file)
+	cfg, err := loadConfig()
 	if err != nil {
 		// If config loading fails, fall back to reading UPDATER_PORT directly
 		if portStr := os.Getenv("UPDATER_PORT"); portStr != "" {
 			var port int
 			if _, err := fmt.Sscanf(portStr, "%d", &port); err == nil {
+				cli.Std.Debugf("Config not loaded; using UPDATER_PORT=%d\n", port)
 				return port
 			}
 		}
 		// Default port
+		cli.Std.Debugf("Config not loaded (%v); using default daemon port 2359\n", err)
 		return 2359
 	}
+	cli.Std.Debugf("Daemon port: %d\n", cfg.Port)
 	return cfg.Port
 }
 
+// newJobStore returns the job store for cfg with its log rotation limits.
+func newJobStore(cfg *config.Config) *jobs.Store {
+	store := jobs.NewStore(cfg.StateDir)
+	store.LogMaxBytes = int64(cfg.JobLogMaxSizeMB) * 1024 * 1024
+	store.LogMaxFiles = cfg.JobLogMaxFiles
+	return store
+}
+
 func isJobActive(job *jobs.Job) bool {
 	return job.State == jobs.JobStatePolicyFetching ||
 		job.State == jobs.JobStateManifestFetching ||
@@ -157,3 +191,44 @@ func isJobActive(job *jobs.Job) bool {
 		job.State == jobs.JobStateVerifying ||
 		job.State == jobs.JobStateBackingUp
 }
+
+// stringListFlag collects a repeatable string flag (e.g. --port a --port b).
+type stringListFlag []string
+
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringListFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseFlags parses args into fs, which must use flag.ContinueOnError. It
+// behaves like flag.ExitOnError, exiting 0 for -h and 2 for a bad flag,
+// except that with --json-errors the parse error is reported as JSON.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if cli.Std.JSONErrors {
+		fs.SetOutput(io.Discard)
+	}
+	err := fs.Parse(args)
+	if err == nil {
+		return
+	}
+	if err == flag.ErrHelp {
+		if cli.Std.JSONErrors {
+			fs.SetOutput(os.Stderr)
+			fs.Usage()
+		}
+		os.Exit(0)
+	}
+	if !cli.Std.JSONErrors {
+		os.Exit(2)
+	}
+	cli.Std.Fail(cli.CommandError{
+		Message:  err.Error(),
+		Code:     cli.CodeUsage,
+		Hint:     fmt.Sprintf("Run 'payram-updater %s -h' for usage.", fs.Name()),
+		ExitCode: 2,
+	})
+}
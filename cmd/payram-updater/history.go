@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+
+	"github.com/payram/payram-updater/internal/cli"
+	"github.com/payram/payram-updater/internal/history"
+)
+
+func runHistory() {
+	if len(os.Args) < 3 || os.Args[2] != "export" {
+		cli.Std.Failf(cli.CodeUsage, "", "Usage: payram-updater history export [--type TYPE] [--status STATUS]")
+	}
+
+	exportFlags := flag.NewFlagSet("history export", flag.ContinueOnError)
+	typeFilter := exportFlags.String("type", "", "Only export events of this type (upgrade, backup, restore, ...)")
+	statusFilter := exportFlags.String("status", "", "Only export events with this status (started, succeeded, failed)")
+	parseFlags(exportFlags, os.Args[3:])
+
+	cfg, err := loadConfig()
+	if err != nil {
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	_, exportErr := history.NewStore(cfg.StateDir).Export(out, *typeFilter, *statusFilter)
+	if err := out.Flush(); err != nil && exportErr == nil {
+		exportErr = err
+	}
+	if exportErr != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to export history: %v", exportErr)
+	}
+}
@@ -3,13 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/cli"
 	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/coreclient"
 	"github.com/payram/payram-updater/internal/corecompat"
@@ -24,14 +26,13 @@ import (
 
 func runInspect() {
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
 	}
 
 	// Initialize job store (read-only)
-	jobStore := jobs.NewStore(cfg.StateDir)
+	jobStore := newJobStore(cfg)
 
 	// Resolve container name
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -54,17 +55,13 @@ func runInspect() {
 			discoverer := container.NewDiscoverer(cfg.DockerBin, imagePattern, log.Default())
 			discovered, discoverErr := discoverer.DiscoverPayramContainer(ctx)
 			if discoverErr != nil {
-				fmt.Fprintf(os.Stderr, "Failed to resolve target container: %v\n", err)
-				fmt.Fprintf(os.Stderr, "Set TARGET_CONTAINER_NAME environment variable or ensure manifest has container_name\n")
-				os.Exit(1)
+				cli.Std.Failf(cli.CodeContainerUnresolved, "Set TARGET_CONTAINER_NAME environment variable or ensure manifest has container_name", "Failed to resolve target container: %v", err)
 			}
 			containerName := discovered.Name
 			fmt.Printf("Target container discovered as: %s\n\n", containerName)
 			resolved = &container.ResolvedContainer{Name: containerName}
 		} else {
-			fmt.Fprintf(os.Stderr, "Failed to resolve target container: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Set TARGET_CONTAINER_NAME environment variable or ensure manifest has container_name\n")
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeContainerUnresolved, "Set TARGET_CONTAINER_NAME environment variable or ensure manifest has container_name", "Failed to resolve target container: %v", err)
 		}
 	}
 	containerName := resolved.Name
@@ -84,22 +81,25 @@ func runInspect() {
 		cfg.RuntimeManifestURL,
 		cfg.DebugVersionMode,
 	)
+	backupMgr := backup.NewManager(backup.Config{Dir: cfg.Backup.Dir, PerDatabaseDirs: cfg.Backup.PerDatabaseDirs}, &backup.RealExecutor{}, log.Default())
+	inspector.SetBackupCheck(backupMgr, time.Duration(cfg.Backup.MaxAgeHours)*time.Hour)
+	inspector.SetBackupLocationCheck(cfg.Backup.Dir)
+	inspector.SetNameCheck(resolved, container.NewDiscoverer(cfg.DockerBin, imagePattern, log.Default()))
 
 	result := inspector.Run(ctx)
 
 	// Output as JSON
 	output, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to format output: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to format output: %v", err)
 	}
 	fmt.Println(string(output))
 
 	// Print human-readable summary
 	fmt.Println()
-	fmt.Println(strings.Repeat("=", 60))
+	cli.Std.Separator()
 	fmt.Printf("OVERALL STATE: %s\n", result.OverallState)
-	fmt.Println(strings.Repeat("=", 60))
+	cli.Std.Separator()
 
 	if len(result.Issues) > 0 {
 		fmt.Println("\nISSUES:")
@@ -129,7 +129,7 @@ func runInspect() {
 		}
 	}
 
-	fmt.Println(strings.Repeat("=", 60))
+	cli.Std.Separator()
 
 	// Exit with non-zero if BROKEN
 	if result.OverallState == inspect.StateBroken {
@@ -138,21 +138,30 @@ func runInspect() {
 }
 
 func runRecover() {
+	recoverFlags := flag.NewFlagSet("recover", flag.ContinueOnError)
+	retries := recoverFlags.Int("retries", 0, "Extra attempts to bring the container up and verify health")
+	dryRun := recoverFlags.Bool("dry-run", false, "Report the recovery action without changing the container")
+	parseFlags(recoverFlags, os.Args[2:])
+	if *retries < 0 {
+		cli.Std.Failf(cli.CodeUsage, "", "Error: --retries must not be negative")
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
 	}
 
 	// Initialize job store
-	jobStore := jobs.NewStore(cfg.StateDir)
+	jobStore := newJobStore(cfg)
 
 	// Create docker runner
 	runner := &dockerexec.Runner{DockerBin: cfg.DockerBin, Logger: log.Default()}
 
-	// Resolve container name
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	// Resolve container name; each retry may wait up to the maximum backoff
+	// plus a health check
+	timeout := 60*time.Second + time.Duration(*retries)*(recover.MaxRetryBackoff+coreclient.DefaultTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	// Fetch manifest to get container name if not set in env
@@ -162,9 +171,7 @@ func runRecover() {
 	resolver := container.NewResolver(cfg.TargetContainerName, cfg.DockerBin, log.Default())
 	resolved, err := resolver.Resolve(manifestData)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to resolve target container: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Set TARGET_CONTAINER_NAME environment variable or ensure manifest has container_name\n")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeContainerUnresolved, "Set TARGET_CONTAINER_NAME environment variable or ensure manifest has container_name", "Failed to resolve target container: %v", err)
 	}
 	containerName := resolved.Name
 	fmt.Printf("Target container resolved as: %s\\n\\n", containerName)
@@ -179,42 +186,54 @@ func runRecover() {
 		containerName,
 		coreBaseURL, // Use resolved CoreBaseURL
 	)
+	recoverer.Retries = *retries
+	recoverer.DryRun = *dryRun
 
 	// Run recovery (reuse the context from container resolution)
 	result, err := recoverer.Run(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Recovery failed: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Recovery failed: %v", err)
 	}
 
 	// Output as JSON
 	output, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to format output: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to format output: %v", err)
 	}
 	fmt.Println(string(output))
 
 	// Print human-readable summary
 	fmt.Println()
-	fmt.Println(strings.Repeat("=", 60))
-	if result.Success {
-		fmt.Println("✅ RECOVERY SUCCESSFUL")
+	cli.Std.Separator()
+	if result.DryRun && result.Success {
+		cli.Std.Println("🔍 RECOVERY DRY RUN (nothing was changed)")
+	} else if result.Success {
+		cli.Std.Println("✅ RECOVERY SUCCESSFUL")
 	} else {
-		fmt.Println("❌ RECOVERY REFUSED/FAILED")
+		cli.Std.Println("❌ RECOVERY REFUSED/FAILED")
 	}
-	fmt.Println(strings.Repeat("=", 60))
+	cli.Std.Separator()
 	fmt.Printf("\nMessage: %s\n", result.Message)
 
 	if result.Refusals != "" {
 		fmt.Printf("\nReason: %s\n", result.Refusals)
 	}
 
-	if result.Action != "" {
+	if result.Action != "" && result.DryRun {
+		fmt.Printf("\nAction planned: %s\n", result.Action)
+	} else if result.Action != "" {
 		fmt.Printf("\nAction taken: %s\n", result.Action)
 	}
 
-	fmt.Println(strings.Repeat("=", 60))
+	for _, attempt := range result.Attempts {
+		status := "healthy"
+		if !attempt.Healthy {
+			status = "not healthy: " + attempt.Error
+		}
+		fmt.Printf("Attempt %d (%s): %s\n", attempt.Attempt, attempt.Action, status)
+	}
+
+	cli.Std.Separator()
 
 	// Exit with non-zero if recovery failed
 	if !result.Success {
@@ -223,11 +242,14 @@ func runRecover() {
 }
 
 func runSync() {
+	syncFlags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	dryRun := syncFlags.Bool("dry-run", false, "Report the version that would be recorded without updating state")
+	parseFlags(syncFlags, os.Args[2:])
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
 	}
 
 	// Create context with timeout
@@ -242,8 +264,7 @@ func runSync() {
 	resolver := container.NewResolver(cfg.TargetContainerName, cfg.DockerBin, log.Default())
 	resolved, err := resolver.Resolve(manifestData)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to resolve target container: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeContainerUnresolved, "", "Failed to resolve target container: %v", err)
 	}
 	containerName := resolved.Name
 	fmt.Printf("Target container resolved as: %s\n\n", containerName)
@@ -269,9 +290,7 @@ func runSync() {
 	} else {
 		labelVersion, labelErr := corecompat.VersionFromLabels(ctx, cfg.DockerBin, containerName)
 		if labelErr != nil {
-			fmt.Fprintf(os.Stderr, "Failed to get running version: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Is the container running and healthy?\n")
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "Is the container running and healthy?", "Failed to get running version: %v", err)
 		}
 		currentVersion = labelVersion
 	}
@@ -288,31 +307,25 @@ func runSync() {
 	healthDB := ""
 	if useLegacy {
 		if err := corecompat.LegacyHealth(ctx, coreBaseURL); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to verify health: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Cannot sync state when health check fails.\n")
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "Cannot sync state when health check fails.", "Failed to verify health: %v", err)
 		}
 		healthStatus = "ok"
 		healthDB = "unknown"
 	} else {
 		healthResp, err := coreClient.Health(ctx)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to verify health: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Cannot sync state when health check fails.\n")
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "Cannot sync state when health check fails.", "Failed to verify health: %v", err)
 		}
 
 		if healthResp.Status != "ok" || (healthResp.DB != "" && healthResp.DB != "ok") {
-			fmt.Fprintf(os.Stderr, "Health check not OK (status=%s, db=%s)\n", healthResp.Status, healthResp.DB)
-			fmt.Fprintf(os.Stderr, "Cannot sync state when system is unhealthy.\n")
-			os.Exit(1)
+			cli.Std.Failf(cli.CodeOperationFailed, "Cannot sync state when system is unhealthy.", "Health check not OK (status=%s, db=%s)", healthResp.Status, healthResp.DB)
 		}
 		healthStatus = healthResp.Status
 		healthDB = healthResp.DB
 	}
 
 	// Load existing job to check if sync is needed
-	jobStore := jobs.NewStore(cfg.StateDir)
+	jobStore := newJobStore(cfg)
 	existingJob, _ := jobStore.LoadLatest()
 
 	if existingJob != nil && existingJob.State == jobs.JobStateReady && existingJob.ResolvedTarget == currentVersion {
@@ -326,18 +339,23 @@ func runSync() {
 		previousVersion = existingJob.ResolvedTarget
 	}
 
-	// Create a synthetic job to reflect the external upgrade
-	// Generate a unique job ID
-	jobID := fmt.Sprintf("sync-%d", time.Now().UnixNano())
-	syncJob := jobs.NewJob(jobID, jobs.JobModeManual, currentVersion)
-	syncJob.ResolvedTarget = currentVersion
-	syncJob.State = jobs.JobStateReady
-	syncJob.Message = fmt.Sprintf("Synced from external upgrade (was %s, now %s)", previousVersion, versionResp.Version)
-
-	// Save the synthetic job
-	if err := jobStore.Save(syncJob); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to save sync job: %v\n", err)
-		os.Exit(1)
+	if *dryRun {
+		cli.Std.Separator()
+		cli.Std.Println("🔍 SYNC DRY RUN (nothing was changed)")
+		cli.Std.Separator()
+		fmt.Printf("\nPrevious tracked version: %s\n", previousVersion)
+		fmt.Printf("Current running version:  %s\n", currentVersion)
+		fmt.Printf("Health status:            OK (status=%s, db=%s)\n", healthStatus, healthDB)
+		fmt.Printf("\nWould record a synced job for %s in %s.\n", currentVersion, cfg.StateDir)
+		fmt.Println("Run 'payram-updater sync' without --dry-run to apply.")
+		cli.Std.Separator()
+		return
+	}
+
+	// Record a synthetic job to reflect the external upgrade
+	message := fmt.Sprintf("Synced from external upgrade (was %s, now %s)", previousVersion, currentVersion)
+	if _, err := jobStore.Reconcile("sync", currentVersion, message); err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to save sync job: %v", err)
 	}
 
 	// Log the sync
@@ -347,13 +365,13 @@ func runSync() {
 	}
 
 	// Output success
-	fmt.Println(strings.Repeat("=", 60))
-	fmt.Println("✅ SYNC SUCCESSFUL")
-	fmt.Println(strings.Repeat("=", 60))
+	cli.Std.Separator()
+	cli.Std.Println("✅ SYNC SUCCESSFUL")
+	cli.Std.Separator()
 	fmt.Printf("\nPrevious tracked version: %s\n", previousVersion)
 	fmt.Printf("Current running version:  %s\n", currentVersion)
 	fmt.Printf("Health status:            OK (status=%s, db=%s)\n", healthStatus, healthDB)
 	fmt.Println("\nInternal state has been updated to match the running version.")
 	fmt.Println("Run 'payram-updater inspect' to verify.")
-	fmt.Println(strings.Repeat("=", 60))
+	cli.Std.Separator()
 }
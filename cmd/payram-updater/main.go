@@ -2,10 +2,51 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/cli"
+	"github.com/payram/payram-updater/internal/config"
 )
 
 func main() {
+	args, jsonErrors := cli.ExtractJSONErrorsFlag(os.Args)
+	cli.Std.JSONErrors = jsonErrors
+	args, configPath, err := extractGlobalFlag(args, "config", "a file path")
+	if err != nil {
+		cli.Std.Failf(cli.CodeUsage, "", "Error: %v", err)
+	}
+	if configPath != "" {
+		// config.Load reads the file through UPDATER_CONFIG_FILE
+		os.Setenv("UPDATER_CONFIG_FILE", configPath)
+	}
+	args, profile, err := extractGlobalFlag(args, "profile", "a profile name")
+	if err != nil {
+		cli.Std.Failf(cli.CodeUsage, "", "Error: %v", err)
+	}
+	if profile != "" {
+		os.Setenv(config.ProfileEnvVar, profile)
+	}
+	args, verbosity, err := cli.ExtractVerbosityFlags(args)
+	if err != nil {
+		cli.Std.Failf(cli.CodeUsage, "", "Error: %v", err)
+	}
+	cli.Std.Verbosity = verbosity
+	args, noColor := cli.ExtractNoColorFlag(args)
+	cli.Std.Plain = cli.IsPlain(noColor, os.Stdout)
+	// Components log progress through the standard logger; treat it as
+	// secondary output so --quiet hides it too.
+	log.SetOutput(cli.Std.Writer(cli.VerbosityNormal))
+	if verbosity == cli.VerbosityVerbose && os.Getenv("LOG_LEVEL") == "" {
+		os.Setenv("LOG_LEVEL", "debug")
+	}
+	cli.Std.Debugf("Config file: %s\n", config.FilePath())
+	if profile := os.Getenv(config.ProfileEnvVar); profile != "" {
+		cli.Std.Debugf("Profile: %s\n", profile)
+	}
+	os.Args = args
+
 	if len(os.Args) < 2 {
 		// Default command is "serve"
 		runServe()
@@ -38,13 +79,24 @@ func main() {
 		runInspect()
 	case "recover":
 		runRecover()
+	case "rollback":
+		runRollback()
 	case "backup":
 		runBackup()
+	case "history":
+		runHistory()
+	case "playbook":
+		runPlaybook()
 	case "cleanup":
 		runCleanup()
 	case "sync":
 		runSync()
+	case "version":
+		runVersion()
 	default:
+		if cli.Std.JSONErrors {
+			cli.Std.Failf(cli.CodeUsage, "Run 'payram-updater help' for usage.", "Unknown command: %s", command)
+		}
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
 		printHelp()
 		os.Exit(1)
@@ -55,7 +107,18 @@ func printHelp() {
 	fmt.Print(`payram-updater - Payram runtime upgrade manager
 
 USAGE:
-  payram-updater [COMMAND]
+  payram-updater [--config PATH] [--profile NAME] [--quiet | --verbose] [--no-color]
+                 [--json-errors] [COMMAND]
+
+GLOBAL FLAGS:
+  --config PATH    Read configuration from PATH instead of /etc/payram/updater.env
+  --profile NAME   Apply the PROFILE_<NAME>_* settings from the configuration
+  -q, --quiet      Print only primary output (JSON, tables) and errors
+  -v, --verbose    Also print diagnostics
+  --no-color       Print without emoji and separators (automatic when stdout
+                   is not a terminal or NO_COLOR is set)
+  --json-errors    Print errors to stderr as JSON objects with error, code,
+                   hint and exitCode fields (or set UPDATER_JSON_ERRORS=true)
 
 COMMANDS:
 	init             Initialize updater configuration
@@ -67,14 +130,24 @@ COMMANDS:
   run              Execute an upgrade via the daemon
   inspect          Read-only system diagnostics
   recover          Attempt automated recovery from a failed upgrade
+  rollback         Undo the last successful upgrade (container + database)
   sync             Sync internal state after external upgrade
   backup           Manage database backups (create, list, restore)
+  history          Export upgrade/backup history as JSON Lines
+  playbook         List or show recovery playbooks for failure codes
 	cleanup          Cleanup local state or backups (requires confirmation)
+  version          Print the updater's own version, commit, build date and Go version
   help             Show this help message
 
 DRY-RUN FLAGS:
   --mode string    Upgrade mode: 'dashboard' or 'manual' (default: manual)
-  --to string      Target version (required)
+  --to string      Target version, or latest, latest-patch or latest-dashboard
+                   (manual mode; see LATEST_STRATEGY) (required)
+  --print-run-command
+                   Print only the docker run command the upgrade would use,
+                   with env values redacted
+  --image-repo string
+                   Validate against this image repo instead of the manifest's
 
 RESTART:
   Restarts the payram-updater systemd service. Useful when:
@@ -86,20 +159,91 @@ RESTART:
 
 RUN FLAGS:
   --mode string    Upgrade mode: 'dashboard' or 'manual' (default: manual)
-  --to string      Target version (required)
+  --to string      Target version, or latest, latest-patch or latest-dashboard
+                   (manual mode; see LATEST_STRATEGY) (required)
   --yes            Skip confirmation prompt (default: false)
+  --force          Upgrade even if MIN_UPGRADE_INTERVAL_MINUTES has not passed
+                   since the last successful upgrade, or if the target is the
+                   version already running (reinstalls it)
+  --restart-only   Restart the running container on its current version, then
+                   verify its health; no --to needed. Nothing is pulled or
+                   recreated
+  --skip-verify    Skip the post-upgrade health and version checks (manual mode
+                   only). For recovery when the version endpoint is known to
+                   be broken: the job succeeds with an UNVERIFIED warning
+  --image-repo string
+                   Pull this image repo instead of the manifest's, e.g. to test
+                   a fork (must still be in ALLOWED_IMAGE_REPOS when set)
+  --synchronous    Run the upgrade in this process, without the daemon, and
+                   wait for it to finish. Exits 0 on success, 1 on failure,
+                   2 if confirmation is needed, 3 if cancelled (SIGINT/SIGTERM
+                   before the container is stopped), 5 on a retryable failure
+                   (nothing changed), 6 on a failure that may have changed
+                   the container or data
+  --wait           Start the job in the daemon, then stream its log and block
+                   until it finishes. Exits like --synchronous, or 4 if
+                   --wait-timeout expires first (the job keeps running)
+  --wait-timeout duration
+                   Stop waiting after this long, e.g. 30m (default: no limit)
+
+RECOVER FLAGS:
+  --retries int    Extra attempts to bring the container up and verify health,
+                   with backoff between them (default: 0). Also lets recover
+                   restart a container that failed its health check
+  --dry-run        Report the recovery action without changing the container
+
+SYNC FLAGS:
+  --dry-run        Report the version sync would record without updating state
+
+ROLLBACK FLAGS:
+  --yes            Skip confirmation prompt (type "yes" otherwise)
+  Rolls back to the version recorded before the last successful upgrade
+  and restores its pre-upgrade backup. One-shot: the marker is cleared
+  after a successful rollback.
 
 LOGS FLAGS:
 	-f, --follow     Follow logs (like tail -f)
 
 BACKUP SUBCOMMANDS:
   backup create           Create a new database backup manually
+                          (--dump-format custom|plain|directory, default BACKUP_DUMP_FORMAT)
+                          (--quiesce stops the container's supervisor programs during it)
   backup list             List all available backups
   backup restore --file   Restore from a backup (requires --yes to confirm)
+  backup restore --file --bootstrap --image repo:tag
+                          Create a new container (fresh host) and restore into it
+  backup restore --file --into-new-version VERSION
+                          Check VERSION's migrations on the backup in a throwaway container
+  backup schedule         Show or set the daemon's periodic backups (--interval 24h, --disable)
+  backup pin --file       Exempt a backup from pruning
+  backup unpin --file     Let a pinned backup be pruned again
+  backup diff --a --b     Compare two backups' metadata and, for custom or
+                          directory format, the objects added and removed
 
 BACKUP FLAGS:
   --file string    Path to backup file (for restore)
   --yes            Skip confirmation prompt (for restore)
+  --compare-checksum
+                   After restore, compare the backup's object counts with the database
+  --bootstrap      Create the container from scratch before restoring (with --image)
+  --port, --volume, --env, --env-file
+                   Container settings for --bootstrap (default: manifest)
+  --into-new-version string
+                   Restore into a throwaway container running this version, then remove it
+
+HISTORY SUBCOMMANDS:
+  history export          Write history events to stdout, one JSON object per line (oldest first)
+
+HISTORY FLAGS:
+  --type string    Only export events of this type (e.g. upgrade, backup)
+  --status string  Only export events with this status (e.g. failed)
+
+PLAYBOOK SUBCOMMANDS:
+  playbook list           List all failure codes with severity, data risk and title
+  playbook show <code>    Show the full recovery playbook for a failure code
+
+PLAYBOOK FLAGS:
+  --container string  Container name to substitute into the recovery steps (for show)
 
 CLEANUP SUBCOMMANDS:
 	cleanup state      Clear updater state (status/logs/history)
@@ -118,15 +262,25 @@ EXAMPLES:
 	payram-updater logs -f
 	payram-updater dry-run --to latest
 	payram-updater dry-run --mode dashboard --to 1.7.0
+	payram-updater dry-run --to latest --print-run-command
 	payram-updater run --to latest
+	payram-updater run --to latest-patch
 	payram-updater run --to 1.2.3 --yes
 	payram-updater run --mode dashboard --to latest
+	payram-updater run --to 1.2.3 --yes --synchronous
+	payram-updater run --to 1.2.3 --yes --wait --wait-timeout 30m
+	payram-updater run --restart-only --wait
   payram-updater inspect
   payram-updater recover
+  payram-updater recover --retries 3
+  payram-updater recover --dry-run
+  payram-updater rollback
   payram-updater sync
   payram-updater backup create
   payram-updater backup list
   payram-updater backup restore --file /path/to/backup.dump --yes
+  payram-updater backup schedule --interval 24h
+  payram-updater history export --type upgrade > history.jsonl
 
   payram-updater cleanup state
   payram-updater cleanup backups --yes
@@ -134,6 +288,37 @@ EXAMPLES:
 CONFIG:
   Configuration is loaded from environment variables first,
   then from /etc/payram/updater.env if it exists.
+  --config PATH (or UPDATER_CONFIG_FILE) reads PATH instead;
+  environment variables still take precedence over the file.
+  --profile NAME (or UPDATER_PROFILE) applies every PROFILE_<NAME>_<KEY>
+  setting as KEY, overriding the shared file values but not the environment.
 
 `)
 }
+
+// extractGlobalFlag removes a global flag that takes a value, such as
+// --config PATH, from args wherever it appears, so subcommand flag sets never
+// see it. valueName describes the value in error messages.
+func extractGlobalFlag(args []string, name, valueName string) ([]string, string, error) {
+	out := make([]string, 0, len(args))
+	var value string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--"+name || arg == "-"+name:
+			if i+1 >= len(args) || args[i+1] == "" {
+				return nil, "", fmt.Errorf("--%s requires %s", name, valueName)
+			}
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--"+name+"=") || strings.HasPrefix(arg, "-"+name+"="):
+			value = arg[strings.Index(arg, "=")+1:]
+			if value == "" {
+				return nil, "", fmt.Errorf("--%s requires %s", name, valueName)
+			}
+		default:
+			out = append(out, arg)
+		}
+	}
+	return out, value, nil
+}
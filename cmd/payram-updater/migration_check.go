@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/bootstrap"
+	"github.com/payram/payram-updater/internal/cli"
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/coreclient"
+	"github.com/payram/payram-updater/internal/dockerexec"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+// migrationCheckContainer is the name of the throwaway container.
+const migrationCheckContainer = "payram-migration-check"
+
+// migrationCheckOptions holds the `backup restore --into-new-version` flags.
+type migrationCheckOptions struct {
+	filePath  string
+	version   string
+	image     string // overrides the image derived from version
+	env       []string
+	envFile   string
+	confirmed bool
+}
+
+// runMigrationCheck restores the backup into a throwaway container running
+// the new version, lets its migrations run, reports the outcome and removes
+// the container. Production is not touched.
+func runMigrationCheck(mgr *backup.Manager, opts migrationCheckOptions) {
+	cfg, err := loadConfig()
+	if err != nil {
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
+	}
+
+	ctx := context.Background()
+	var manifestData *manifest.Manifest
+	if cfg.RuntimeManifestURL != "" {
+		client := manifest.NewClient(time.Duration(cfg.FetchTimeoutSeconds) * time.Second)
+		if manifestData, err = client.Fetch(ctx, cfg.RuntimeManifestURL); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: Failed to fetch manifest (%v); using default image and port\n", err)
+			manifestData = nil
+		}
+	}
+
+	image := opts.image
+	if image == "" {
+		repo := "payramapp/payram"
+		if cfg.ImageRepoOverride != "" {
+			repo = cfg.ImageRepoOverride
+		} else if manifestData != nil && manifestData.Image.Repo != "" {
+			repo = manifestData.Image.Repo
+		}
+		tag := config.TagTemplate{Template: cfg.ImageTagTemplate, Env: cfg.ImageEnv}.Expand(opts.version)
+		image = repo + ":" + tag
+	}
+
+	ports := []manifest.Port{{Container: 8080}}
+	if manifestData != nil && len(manifestData.Defaults.Ports) > 0 {
+		ports = manifestData.Defaults.Ports
+	}
+	env := opts.env
+	if opts.envFile != "" {
+		fileEnv, err := bootstrap.ReadEnvFile(opts.envFile)
+		if err != nil {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "%v", err)
+		}
+		env = append(fileEnv, opts.env...)
+	}
+	for _, kv := range env {
+		if host, ok := strings.CutPrefix(kv, "POSTGRES_HOST="); ok && host != "127.0.0.1" && host != "localhost" {
+			cli.Std.Warnf("POSTGRES_HOST=%s replaced by 127.0.0.1: the check always migrates the throwaway container's own database\n", host)
+		}
+	}
+	spec, err := bootstrap.ScratchSpec(image, migrationCheckContainer, ports, env)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "%v", err)
+	}
+	dockerArgs, _, err := bootstrap.BuildRunArgs(spec)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "%v", err)
+	}
+
+	if !opts.confirmed {
+		fmt.Println("\nThis will restore the backup into a throwaway container to check that the new version's migrations succeed.")
+		fmt.Println("The production container and database are not touched; the throwaway container is removed afterwards.")
+		fmt.Printf("\nBackup file: %s\n", opts.filePath)
+		fmt.Printf("Container:   %s\n", migrationCheckContainer)
+		fmt.Printf("Command:     %s\n", container.FormatRunCommand(dockerArgs))
+		fmt.Print("\nType 'yes' to confirm: ")
+
+		var input string
+		fmt.Scanln(&input)
+		if strings.ToLower(strings.TrimSpace(input)) != "yes" {
+			fmt.Println("Migration check cancelled.")
+			os.Exit(0)
+		}
+	}
+
+	runner := &dockerexec.Runner{DockerBin: cfg.DockerBin, Logger: log.Default()}
+	healthCheck := func(ctx context.Context) error {
+		// Never CORE_BASE_URL: that is production. Probe the throwaway
+		// container's own loopback ports.
+		nullLogger := log.New(io.Discard, "", 0)
+		state, err := container.NewInspector(cfg.DockerBin, nullLogger).ExtractRuntimeState(ctx, migrationCheckContainer)
+		if err != nil {
+			return err
+		}
+		port, err := container.NewPortIdentifier(nullLogger).IdentifyPayramCorePort(ctx, state)
+		if err != nil {
+			return err
+		}
+		healthCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		resp, err := coreclient.NewClient(fmt.Sprintf("%s://127.0.0.1:%s", port.Scheme, port.HostPort)).Health(healthCtx)
+		if err != nil {
+			return err
+		}
+		if resp.Status != "ok" {
+			return fmt.Errorf("health status %q", resp.Status)
+		}
+		return nil
+	}
+
+	cli.Std.Infof("\nChecking migrations of %s against %s...\n", image, opts.filePath)
+	checker := bootstrap.NewMigrationChecker(runner, mgr, healthCheck, log.New(cli.Std.Writer(cli.VerbosityNormal), "", 0))
+	result, err := checker.Run(ctx, spec, opts.filePath)
+
+	eventData := map[string]string{
+		"backupFile":     opts.filePath,
+		"image":          image,
+		"container":      migrationCheckContainer,
+		"migrationCheck": "true",
+	}
+	historyStore := history.NewStore(cfg.StateDir)
+	if err != nil {
+		_ = historyStore.Append(history.Event{
+			Type:    "restore",
+			Status:  "failed",
+			Message: err.Error(),
+			Data:    eventData,
+		})
+		hint := ""
+		if result != nil && !result.CleanedUp {
+			hint = fmt.Sprintf("The throwaway container was not removed: docker rm -f -v %s", migrationCheckContainer)
+		}
+		cli.Std.Failf(cli.CodeOperationFailed, hint, "Migration check failed: %v", err)
+	}
+	_ = historyStore.Append(history.Event{
+		Type:    "restore",
+		Status:  "succeeded",
+		Message: fmt.Sprintf("Migrations of %s succeeded on %s", image, opts.filePath),
+		Data:    eventData,
+	})
+
+	cli.Std.Infof("\n✅ %s migrated the restored backup and came up healthy. Throwaway container removed.\n", image)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Migrations of %s succeeded on the restored backup", image),
+		"result":  result,
+	}
+	jsonOut, _ := json.MarshalIndent(response, "", "  ")
+	fmt.Println(string(jsonOut))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/cli"
+	"github.com/payram/payram-updater/internal/recovery"
+)
+
+func runPlaybook() {
+	if len(os.Args) < 3 {
+		failPlaybookUsage("")
+	}
+
+	switch os.Args[2] {
+	case "list":
+		if err := recovery.WriteList(os.Stdout); err != nil {
+			cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to list playbooks: %v", err)
+		}
+	case "show":
+		runPlaybookShow(os.Args[3:])
+	default:
+		failPlaybookUsage(fmt.Sprintf("Unknown playbook subcommand: %s", os.Args[2]))
+	}
+}
+
+func runPlaybookShow(args []string) {
+	showFlags := flag.NewFlagSet("playbook show", flag.ContinueOnError)
+	containerName := showFlags.String("container", "", "Container name to substitute into the recovery steps")
+	parseFlags(showFlags, args)
+
+	// Accept flags on either side of the code
+	if showFlags.NArg() == 0 {
+		failPlaybookUsage("")
+	}
+	code := strings.ToUpper(showFlags.Arg(0))
+	parseFlags(showFlags, showFlags.Args()[1:])
+	if showFlags.NArg() > 0 {
+		failPlaybookUsage("")
+	}
+
+	if !recovery.IsKnownCode(code) {
+		cli.Std.Failf(cli.CodeUsage, "Run 'payram-updater playbook list' to see all codes.", "Unknown failure code: %s", code)
+	}
+
+	playbook := recovery.RenderPlaybook(code, recovery.PlaybookContext{ContainerName: *containerName})
+	recovery.WritePlaybook(cli.Std.StdoutWriter(), playbook)
+}
+
+const playbookUsage = `Usage:
+  payram-updater playbook list
+  payram-updater playbook show <code> [--container NAME]
+`
+
+// failPlaybookUsage reports message, if any, followed by the playbook usage.
+func failPlaybookUsage(message string) {
+	text := playbookUsage
+	if message != "" {
+		text = message + "\n\n" + playbookUsage
+	} else {
+		message = "missing or extra playbook arguments"
+	}
+	cli.Std.Fail(cli.CommandError{Message: message, Code: cli.CodeUsage, Hint: "Run 'payram-updater playbook list' or 'payram-updater playbook show <code>'.", Text: text})
+}
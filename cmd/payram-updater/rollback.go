@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/cli"
+	"github.com/payram/payram-updater/internal/dockerexec"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/rollback"
+)
+
+func runRollback() {
+	rollbackFlags := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	confirmed := rollbackFlags.Bool("yes", false, "Skip confirmation prompt")
+	parseFlags(rollbackFlags, os.Args[2:])
+
+	cfg, err := loadConfig()
+	if err != nil {
+		cli.Std.Failf(cli.CodeConfig, "", "Failed to load configuration: %v", err)
+	}
+
+	jobStore := newJobStore(cfg)
+	if job, err := jobStore.LoadLatest(); err == nil && job != nil && isJobActive(job) {
+		cli.Std.Failf(cli.CodeJobActive, "", "Active job in progress. Rollback is blocked.")
+	}
+
+	markerStore := rollback.NewStore(cfg.StateDir)
+	marker, err := markerStore.Load()
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to load last known good marker: %v", err)
+	}
+	if marker == nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "No last known good version recorded. A marker is written after each successful upgrade.")
+	}
+	if marker.RuntimeState == nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Last known good marker is incomplete (no runtime state recorded). Rollback is not possible.")
+	}
+
+	if !*confirmed {
+		fmt.Println("\nWARNING: This will roll back the last successful upgrade.")
+		fmt.Printf("  Container:        %s\n", marker.RuntimeState.Name)
+		fmt.Printf("  Current version:  %s\n", marker.UpgradedTo)
+		fmt.Printf("  Rollback to:      %s\n", marker.PreviousVersion)
+		if marker.BackupPath != "" {
+			fmt.Printf("  Restore backup:   %s\n", marker.BackupPath)
+			fmt.Println("\nAll data written since the upgrade will be REPLACED with backup contents.")
+		} else {
+			fmt.Println("  Restore backup:   none recorded (database left as-is)")
+		}
+		fmt.Print("\nType 'yes' to confirm: ")
+
+		reader := bufio.NewReader(os.Stdin)
+		input, _ := reader.ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(input)) != "yes" {
+			fmt.Println("Rollback cancelled.")
+			os.Exit(0)
+		}
+	}
+
+	imagePattern := "payramapp/payram:"
+	if cfg.ImageRepoOverride != "" {
+		imagePattern = cfg.ImageRepoOverride + ":"
+	}
+	mgr := backup.NewManager(backup.Config{
+		Dir:                 cfg.Backup.Dir,
+		Retention:           cfg.Backup.Retention,
+		PGHost:              cfg.Backup.PGHost,
+		PGPort:              cfg.Backup.PGPort,
+		PGDB:                cfg.Backup.PGDB,
+		PGUser:              cfg.Backup.PGUser,
+		PGPassword:          cfg.Backup.PGPassword,
+		PGSuperUser:         cfg.Backup.PGSuperUser,
+		PGSuperPassword:     cfg.Backup.PGSuperPassword,
+		ImagePattern:        imagePattern,
+		TargetContainerName: cfg.TargetContainerName,
+		PerDatabaseDirs:     cfg.Backup.PerDatabaseDirs,
+		DatabaseRetention:   cfg.Backup.DatabaseRetention,
+		Snapshot: backup.SnapshotConfig{
+			Strategy:        cfg.Backup.Strategy,
+			Command:         cfg.Backup.SnapshotCommand,
+			RollbackCommand: cfg.Backup.SnapshotRollbackCommand,
+		},
+	}, &backup.RealExecutor{}, log.Default())
+	runner := &dockerexec.Runner{DockerBin: cfg.DockerBin, Logger: log.Default()}
+	rollbacker := rollback.NewRollbacker(markerStore, runner, mgr, log.Default())
+
+	// Hold the restore lock across the container rollback as well, so no
+	// restore, and no upgrade or restart by the daemon, runs meanwhile
+	restoreLock, err := mgr.LockOperation("rollback to " + marker.PreviousVersion)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Rollback is blocked: %v", err)
+	}
+	defer restoreLock.Release()
+	rollbacker.RestoreLocked = true
+
+	cli.Std.Infof("\nRolling back %s to %s...\n", marker.UpgradedTo, marker.PreviousVersion)
+	ctx := context.Background()
+	result, err := rollbacker.Run(ctx, marker)
+
+	historyStore := history.NewStore(cfg.StateDir)
+	historyData := map[string]string{
+		"jobId":           marker.JobID,
+		"previousVersion": marker.PreviousVersion,
+		"rolledBackFrom":  marker.UpgradedTo,
+		"backupPath":      marker.BackupPath,
+	}
+	if err != nil {
+		_ = historyStore.Append(history.Event{
+			Type:    "rollback",
+			Status:  "failed",
+			Message: err.Error(),
+			Data:    historyData,
+		})
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Rollback failed: %v", err)
+	}
+
+	_ = historyStore.Append(history.Event{
+		Type:    "rollback",
+		Status:  "succeeded",
+		Message: fmt.Sprintf("Rolled back to %s", result.PreviousVersion),
+		Data:    historyData,
+	})
+
+	// Reflect the rollback in the tracked job so status/inspect report the
+	// version that is actually running.
+	rollbackJob := jobs.NewJob(fmt.Sprintf("rollback-%d", time.Now().UnixNano()), jobs.JobModeManual, result.PreviousVersion)
+	rollbackJob.ResolvedTarget = result.PreviousVersion
+	rollbackJob.State = jobs.JobStateReady
+	rollbackJob.Message = fmt.Sprintf("Rolled back from %s to %s", result.RolledBackFrom, result.PreviousVersion)
+	if err := jobStore.Save(rollbackJob); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save rollback job: %v\n", err)
+	}
+	_ = jobStore.AppendLog(fmt.Sprintf("ROLLBACK: %s", rollbackJob.Message))
+
+	cli.Std.Infof("\n✅ Rolled back to version %s.\n", result.PreviousVersion)
+
+	response := map[string]interface{}{
+		"success":  true,
+		"message":  rollbackJob.Message,
+		"rollback": result,
+	}
+	jsonOut, _ := json.MarshalIndent(response, "", "  ")
+	fmt.Println(string(jsonOut))
+}
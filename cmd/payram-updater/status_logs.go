@@ -2,15 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"strings"
-	"time"
 
+	"github.com/payram/payram-updater/internal/cli"
 	"github.com/payram/payram-updater/internal/recovery"
 )
 
@@ -20,16 +20,13 @@ func runStatus() {
 
 	resp, err := http.Get(url)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Is the payram-updater daemon running?\n")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonUnreachable, "Is the payram-updater daemon running?", "Failed to connect to daemon: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read response: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonResponse, "", "Failed to read response: %v", err)
 	}
 
 	// Parse response to check for recovery playbook
@@ -49,8 +46,7 @@ func runStatus() {
 	// Pretty-print JSON (no playbook or parsing failed)
 	var prettyJSON bytes.Buffer
 	if err := json.Indent(&prettyJSON, body, "", "  "); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to format JSON: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to format JSON: %v", err)
 	}
 
 	fmt.Println(prettyJSON.String())
@@ -67,96 +63,40 @@ func printStatusWithPlaybook(body []byte, playbook *recovery.Playbook) {
 	}
 
 	// Then print formatted recovery instructions
-	fmt.Println("\n" + strings.Repeat("=", 60))
-	fmt.Printf("⚠️  RECOVERY: %s\n", playbook.Title)
-	fmt.Println(strings.Repeat("=", 60))
-	fmt.Printf("\nSeverity: %s\n", playbook.Severity)
-	fmt.Printf("Data Risk: %s\n", playbook.DataRisk)
-	fmt.Printf("\n%s\n", playbook.UserMessage)
-	fmt.Println("\n--- Recovery Steps (SSH) ---")
-	for _, step := range playbook.SSHSteps {
-		fmt.Printf("  %s\n", step)
-	}
-	if playbook.DocsURL != "" {
-		fmt.Printf("\nDocumentation: %s\n", playbook.DocsURL)
-	}
-	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println()
+	recovery.WritePlaybook(cli.Std.StdoutWriter(), *playbook)
 }
 
 func runLogs() {
-	logsCmd := flag.NewFlagSet("logs", flag.ExitOnError)
+	logsCmd := flag.NewFlagSet("logs", flag.ContinueOnError)
 	followShort := logsCmd.Bool("f", false, "Follow logs (like tail -f)")
 	followLong := logsCmd.Bool("follow", false, "Follow logs (like tail -f)")
-	logsCmd.Parse(os.Args[2:])
+	parseFlags(logsCmd, os.Args[2:])
 
 	follow := *followShort || *followLong
 
-	port := getPort()
-	url := fmt.Sprintf("http://127.0.0.1:%d/upgrade/logs", port)
-
-	fetchLogs := func() (string, int, error) {
-		resp, err := http.Get(url)
-		if err != nil {
-			return "", 0, err
-		}
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", resp.StatusCode, err
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", getPort())
+	if follow {
+		follower := &cli.LogFollower{BaseURL: baseURL}
+		if err := follower.Follow(context.Background()); err != nil {
+			cli.Std.Failf(cli.CodeDaemonUnreachable, "Is the payram-updater daemon running?", "Failed to follow logs: %v", err)
 		}
-		return string(body), resp.StatusCode, nil
-	}
-
-	if !follow {
-		body, status, err := fetchLogs()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Is the payram-updater daemon running?\n")
-			os.Exit(1)
-		}
-		if status != http.StatusOK {
-			fmt.Fprintf(os.Stderr, "Failed to read logs: HTTP %d\n", status)
-			os.Exit(1)
-		}
-
-		// Print plain text logs directly
-		fmt.Print(body)
 		return
 	}
 
-	lastSize := 0
-	first := true
-	for {
-		body, status, err := fetchLogs()
-		if err != nil {
-			if first {
-				fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
-				fmt.Fprintf(os.Stderr, "Is the payram-updater daemon running?\n")
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Warning: failed to fetch logs: %v\n", err)
-			time.Sleep(1 * time.Second)
-			continue
-		}
-		if status != http.StatusOK {
-			if first {
-				fmt.Fprintf(os.Stderr, "Failed to read logs: HTTP %d\n", status)
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Warning: failed to read logs: HTTP %d\n", status)
-			time.Sleep(1 * time.Second)
-			continue
-		}
-
-		if len(body) < lastSize {
-			fmt.Print(body)
-			lastSize = len(body)
-		} else if len(body) > lastSize {
-			fmt.Print(body[lastSize:])
-			lastSize = len(body)
-		}
-
-		first = false
-		time.Sleep(1 * time.Second)
+	resp, err := http.Get(baseURL + "/upgrade/logs")
+	if err != nil {
+		cli.Std.Failf(cli.CodeDaemonUnreachable, "Is the payram-updater daemon running?", "Failed to connect to daemon: %v", err)
 	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to read logs: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to read logs: HTTP %d", resp.StatusCode)
+	}
+
+	// Print plain text logs directly
+	fmt.Print(string(body))
 }
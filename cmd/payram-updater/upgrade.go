@@ -2,60 +2,72 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/payram/payram-updater/internal/cli"
+	internalhttp "github.com/payram/payram-updater/internal/http"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
 )
 
 func runDryRun() {
 	// Parse flags for dry-run command
-	dryRunCmd := flag.NewFlagSet("dry-run", flag.ExitOnError)
+	dryRunCmd := flag.NewFlagSet("dry-run", flag.ContinueOnError)
 	mode := dryRunCmd.String("mode", "manual", "Upgrade mode (dashboard or manual)")
 	to := dryRunCmd.String("to", "", "Target version")
+	printRunCommand := dryRunCmd.Bool("print-run-command", false, "Print only the docker run command the upgrade would use (env values redacted)")
+	imageRepo := dryRunCmd.String("image-repo", "", "Use this image repo instead of the manifest's (for testing a fork or private build)")
 
 	// Parse arguments after "dry-run"
-	dryRunCmd.Parse(os.Args[2:])
+	parseFlags(dryRunCmd, os.Args[2:])
 
 	// Use shared validation
 	req, err := cli.ParseUpgradeRequest(*mode, *to)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeUsage, "", "Error: %v", err)
 	}
 
 	port := getPort()
 	url := fmt.Sprintf("http://127.0.0.1:%d/upgrade/plan", port)
 
 	// Create request payload
-	payload := map[string]string{
+	payload := map[string]interface{}{
 		"mode":            string(req.Mode),
 		"requestedTarget": req.RequestedTarget,
 		"source":          "CLI",
+		"printRunCommand": *printRunCommand,
+		"imageRepo":       *imageRepo,
 	}
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create request: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to create request: %v", err)
 	}
 
 	// Send POST request
 	resp, err := http.Post(url, "application/json", bytes.NewReader(payloadBytes))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Is the payram-updater daemon running?\n")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonUnreachable, "Is the payram-updater daemon running?", "Failed to connect to daemon: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read response: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonResponse, "", "Failed to read response: %v", err)
+	}
+
+	warnImageRepoOverride(*imageRepo)
+	if *printRunCommand {
+		os.Exit(printPlannedRunCommand(body))
 	}
 
 	// Pretty-print JSON
@@ -75,10 +87,16 @@ func runDryRun() {
 	var planResp struct {
 		State       string `json:"state"`
 		FailureCode string `json:"failureCode"`
+		Message     string `json:"message"`
 	}
 	if err := json.Unmarshal(body, &planResp); err == nil {
 		if planResp.State == "FAILED" {
-			os.Exit(1)
+			// The plan on stdout already says why; only JSON errors repeat it.
+			// Either way the exit code is the failure code's.
+			if cli.Std.JSONErrors {
+				cli.Std.Fail(cli.CommandError{Message: planResp.Message, Code: planResp.FailureCode})
+			}
+			os.Exit(cli.ExitCode(planResp.FailureCode))
 		}
 	}
 
@@ -87,21 +105,91 @@ func runDryRun() {
 	}
 }
 
+// warnSkipVerify warns, loudly, that --skip-verify leaves the upgrade
+// unchecked.
+func warnSkipVerify() {
+	cli.Std.Warnf("--skip-verify: the new version's health and version will NOT be checked after the upgrade.\n")
+	cli.Std.Warnf("A broken upgrade will be reported as succeeded and will not be rolled back. Use only for recovery.\n")
+}
+
+// warnImageRepoOverride reminds the operator on stderr that --image-repo
+// replaces the image repo the manifest names.
+func warnImageRepoOverride(imageRepo string) {
+	if imageRepo != "" {
+		cli.Std.Warnf("using image repo %s instead of the manifest's (--image-repo)\n", imageRepo)
+	}
+}
+
+// printPlannedRunCommand prints the docker run command from a plan response
+// and returns the exit code: 1 if planning failed or no command was built.
+func printPlannedRunCommand(body []byte) int {
+	var planResp struct {
+		State           string `json:"state"`
+		FailureCode     string `json:"failureCode"`
+		Message         string `json:"message"`
+		RunCommand      string `json:"runCommand"`
+		RunCommandError string `json:"runCommandError"`
+	}
+	if err := json.Unmarshal(body, &planResp); err != nil {
+		cli.Std.WriteError(cli.CommandError{Message: fmt.Sprintf("Failed to parse plan response: %v", err), Code: cli.CodeDaemonResponse})
+		return 1
+	}
+	switch {
+	case planResp.State == "FAILED" || planResp.FailureCode != "":
+		cli.Std.WriteError(cli.FailureError("Upgrade validation failed:", planResp.FailureCode, planResp.Message, ""))
+		return 1
+	case planResp.RunCommandError != "":
+		cli.Std.WriteError(cli.CommandError{Message: fmt.Sprintf("Failed to build the docker run command: %s", planResp.RunCommandError)})
+		return 1
+	case planResp.RunCommand == "":
+		cli.Std.WriteError(cli.CommandError{Message: "The daemon did not return a docker run command; is it up to date?", Code: cli.CodeDaemonResponse})
+		return 1
+	}
+	fmt.Println(planResp.RunCommand)
+	return 0
+}
+
 func runRun() {
 	// Parse flags for run command
-	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
+	runCmd := flag.NewFlagSet("run", flag.ContinueOnError)
 	mode := runCmd.String("mode", "manual", "Upgrade mode (dashboard or manual)")
 	to := runCmd.String("to", "", "Target version")
 	yes := runCmd.Bool("yes", false, "Skip confirmation prompt")
+	synchronous := runCmd.Bool("synchronous", false, "Run the upgrade in this process and wait for it to finish (no daemon)")
+	force := runCmd.Bool("force", false, "Upgrade even if MIN_UPGRADE_INTERVAL_MINUTES has not passed since the last upgrade")
+	imageRepo := runCmd.String("image-repo", "", "Use this image repo instead of the manifest's (for testing a fork or private build)")
+	wait := runCmd.Bool("wait", false, "Block until the job finishes, streaming its log, and exit with its outcome")
+	waitTimeout := runCmd.Duration("wait-timeout", 0, "Give up waiting after this long, e.g. 30m (default: wait forever)")
+	restartOnly := runCmd.Bool("restart-only", false, "Restart the running container on its current version instead of upgrading")
+	skipVerify := runCmd.Bool("skip-verify", false, "Skip post-upgrade health and version verification (manual mode only; recovery use)")
 
 	// Parse arguments after "run"
-	runCmd.Parse(os.Args[2:])
+	parseFlags(runCmd, os.Args[2:])
+
+	if *restartOnly {
+		if *synchronous || *to != "" || *imageRepo != "" || *skipVerify {
+			cli.Std.Failf(cli.CodeUsage, "", "Error: --restart-only cannot be combined with --to, --image-repo, --synchronous or --skip-verify")
+		}
+		runRestartOnly(*wait, *waitTimeout)
+		return
+	}
 
 	// Use shared validation
 	req, err := cli.ParseUpgradeRequest(*mode, *to)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeUsage, "", "Error: %v", err)
+	}
+
+	if *skipVerify {
+		if req.Mode != cli.ModeManual {
+			cli.Std.Failf(cli.CodeUsage, "", "Error: --skip-verify is only allowed with --mode manual")
+		}
+		warnSkipVerify()
+	}
+
+	warnImageRepoOverride(*imageRepo)
+	if *synchronous {
+		os.Exit(runSynchronous(req, *imageRepo, *yes, *force, *skipVerify))
 	}
 
 	port := getPort()
@@ -112,87 +200,139 @@ func runRun() {
 		"mode":            string(req.Mode),
 		"requestedTarget": req.RequestedTarget,
 		"source":          "CLI",
+		"imageRepo":       *imageRepo,
 	}
 	planPayloadBytes, err := json.Marshal(planPayload)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create request: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to create request: %v", err)
 	}
 
 	planResp, err := http.Post(planURL, "application/json", bytes.NewReader(planPayloadBytes))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Is the payram-updater daemon running?\n")
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonUnreachable, "Is the payram-updater daemon running?", "Failed to connect to daemon: %v", err)
 	}
 	defer planResp.Body.Close()
 
 	planBody, err := io.ReadAll(planResp.Body)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read response: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonResponse, "", "Failed to read response: %v", err)
 	}
 
 	// Parse plan response
 	var plan struct {
-		State           string `json:"state"`
-		Mode            string `json:"mode"`
-		RequestedTarget string `json:"requestedTarget"`
-		ResolvedTarget  string `json:"resolvedTarget"`
-		FailureCode     string `json:"failureCode"`
-		Message         string `json:"message"`
-		ImageRepo       string `json:"imageRepo"`
-		ContainerName   string `json:"containerName"`
+		State             string   `json:"state"`
+		Mode              string   `json:"mode"`
+		RequestedTarget   string   `json:"requestedTarget"`
+		ResolvedTarget    string   `json:"resolvedTarget"`
+		FailureCode       string   `json:"failureCode"`
+		Message           string   `json:"message"`
+		ImageRepo         string   `json:"imageRepo"`
+		ImageRepoOverride bool     `json:"imageRepoOverride"`
+		ContainerName     string   `json:"containerName"`
+		AlreadyOnTarget   bool     `json:"alreadyOnTarget"`
+		Warnings          []string `json:"warnings"`
 	}
 	if err := json.Unmarshal(planBody, &plan); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to parse plan response: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonResponse, "", "Failed to parse plan response: %v", err)
 	}
 
 	// Step 2: If planning failed, show the error and exit (no prompt)
 	if plan.State == "FAILED" {
-		fmt.Fprintf(os.Stderr, "Upgrade validation failed:\n")
-		fmt.Fprintf(os.Stderr, "  Code: %s\n", plan.FailureCode)
-		fmt.Fprintf(os.Stderr, "  Message: %s\n", plan.Message)
-		os.Exit(1)
+		cli.Std.Fail(cli.FailureError("Upgrade validation failed:", plan.FailureCode, plan.Message, ""))
+	}
+	if plan.AlreadyOnTarget && !*force {
+		fmt.Println(plan.Message)
+		return
 	}
 
 	// Step 3: Planning succeeded - prompt for confirmation
+	for _, warning := range plan.Warnings {
+		cli.Std.Warnf("%s\n", warning)
+	}
 	summary := &cli.UpgradeSummary{
-		Mode:            plan.Mode,
-		RequestedTarget: plan.RequestedTarget,
-		ResolvedTarget:  plan.ResolvedTarget,
-		ImageRepo:       plan.ImageRepo,
-		ContainerName:   plan.ContainerName,
+		Mode:              plan.Mode,
+		RequestedTarget:   plan.RequestedTarget,
+		ResolvedTarget:    plan.ResolvedTarget,
+		ImageRepo:         plan.ImageRepo,
+		ImageRepoOverride: plan.ImageRepoOverride,
+		ContainerName:     plan.ContainerName,
 	}
 
 	confirmer := cli.NewConfirmer()
 	confirmer.ConfirmOrExit(summary, *yes)
 
 	// Step 4: User confirmed - call /upgrade/run to start the job
-	runURL := fmt.Sprintf("http://127.0.0.1:%d/upgrade/run", port)
-	runPayload := map[string]string{
+	runResult := startDaemonJob(port, map[string]interface{}{
 		"mode":            string(req.Mode),
 		"requestedTarget": req.RequestedTarget,
 		"source":          "CLI",
+		"force":           *force,
+		"imageRepo":       *imageRepo,
+		"skipVerify":      *skipVerify,
+	})
+	if runResult.AlreadyOnTarget {
+		fmt.Println(runResult.Message)
+		return
+	}
+
+	// Success - print job info
+	fmt.Printf("Started upgrade job %s (state=%s).\n", runResult.JobID, runResult.State)
+	if !*wait {
+		fmt.Println("Use 'payram-updater status' to check progress and 'payram-updater logs' for details.")
+		return
+	}
+	os.Exit(waitForJob(port, runResult.JobID, *waitTimeout))
+}
+
+// runRestartOnly asks the daemon to restart the running container on its
+// current version, without planning, pulling or recreating it.
+func runRestartOnly(wait bool, waitTimeout time.Duration) {
+	port := getPort()
+	runResult := startDaemonJob(port, map[string]interface{}{
+		"mode":        "manual",
+		"source":      "CLI",
+		"restartOnly": true,
+	})
+
+	fmt.Printf("Started restart job %s (state=%s).\n", runResult.JobID, runResult.State)
+	if !wait {
+		fmt.Println("Use 'payram-updater status' to check progress and 'payram-updater logs' for details.")
+		return
 	}
-	runPayloadBytes, err := json.Marshal(runPayload)
+	os.Exit(waitForJob(port, runResult.JobID, waitTimeout))
+}
+
+// runResponse is the daemon's reply to POST /upgrade/run.
+type runResponse struct {
+	JobID           string `json:"jobId"`
+	State           string `json:"state"`
+	Mode            string `json:"mode"`
+	RequestedTarget string `json:"requestedTarget"`
+	ResolvedTarget  string `json:"resolvedTarget"`
+	FailureCode     string `json:"failureCode"`
+	Message         string `json:"message"`
+	AlreadyOnTarget bool   `json:"alreadyOnTarget"`
+}
+
+// startDaemonJob posts payload to /upgrade/run and returns the daemon's
+// reply. It exits if the request fails, another job is active, or the job
+// failed to start.
+func startDaemonJob(port int, payload map[string]interface{}) *runResponse {
+	runURL := fmt.Sprintf("http://127.0.0.1:%d/upgrade/run", port)
+	runPayloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create request: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeOperationFailed, "", "Failed to create request: %v", err)
 	}
 
 	runResp, err := http.Post(runURL, "application/json", bytes.NewReader(runPayloadBytes))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonUnreachable, "", "Failed to connect to daemon: %v", err)
 	}
 	defer runResp.Body.Close()
 
 	runBody, err := io.ReadAll(runResp.Body)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to read response: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonResponse, "", "Failed to read response: %v", err)
 	}
 
 	// Handle conflict (409)
@@ -203,39 +343,137 @@ func runRun() {
 			State string `json:"state"`
 		}
 		if err := json.Unmarshal(runBody, &conflictResp); err == nil {
-			fmt.Fprintf(os.Stderr, "Error: %s\n", conflictResp.Error)
-			fmt.Fprintf(os.Stderr, "Active job: %s (state=%s)\n", conflictResp.JobID, conflictResp.State)
-			fmt.Fprintf(os.Stderr, "Use 'payram-updater status' to check the current job.\n")
-		} else {
-			fmt.Fprintf(os.Stderr, "An upgrade job is already running.\n")
+			cli.Std.Fail(cli.CommandError{
+				Message: fmt.Sprintf("%s (active job %s, state=%s)", conflictResp.Error, conflictResp.JobID, conflictResp.State),
+				Code:    cli.CodeJobActive,
+				Hint:    "Use 'payram-updater status' to check the current job.",
+				Text: fmt.Sprintf("Error: %s\nActive job: %s (state=%s)\nUse 'payram-updater status' to check the current job.\n",
+					conflictResp.Error, conflictResp.JobID, conflictResp.State),
+			})
 		}
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeJobActive, "", "An upgrade job is already running.")
 	}
 
 	// Parse run response
-	var runResult struct {
-		JobID           string `json:"jobId"`
-		State           string `json:"state"`
-		Mode            string `json:"mode"`
-		RequestedTarget string `json:"requestedTarget"`
-		ResolvedTarget  string `json:"resolvedTarget"`
-		FailureCode     string `json:"failureCode"`
-		Message         string `json:"message"`
-	}
+	var runResult runResponse
 	if err := json.Unmarshal(runBody, &runResult); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to parse run response: %v\n", err)
-		os.Exit(1)
+		cli.Std.Failf(cli.CodeDaemonResponse, "", "Failed to parse run response: %v", err)
 	}
 
 	// Check if run failed immediately (e.g., policy fetch failed after plan)
 	if runResult.State == "FAILED" {
-		fmt.Fprintf(os.Stderr, "Upgrade failed to start:\n")
-		fmt.Fprintf(os.Stderr, "  Code: %s\n", runResult.FailureCode)
-		fmt.Fprintf(os.Stderr, "  Message: %s\n", runResult.Message)
-		os.Exit(1)
+		cli.Std.Fail(cli.FailureError("Upgrade failed to start:", runResult.FailureCode, runResult.Message, ""))
 	}
+	return &runResult
+}
 
-	// Success - print job info
-	fmt.Printf("Started upgrade job %s (state=%s).\n", runResult.JobID, runResult.State)
-	fmt.Println("Use 'payram-updater status' to check progress and 'payram-updater logs' for details.")
+// waitForJob blocks until the daemon's job finishes and returns the exit code
+// for its outcome, the same codes as run --synchronous.
+func waitForJob(port int, jobID string, timeout time.Duration) int {
+	waiter := &cli.JobWaiter{
+		BaseURL: fmt.Sprintf("http://127.0.0.1:%d", port),
+		Timeout: timeout,
+	}
+	job, err := waiter.Wait(jobID)
+	if errors.Is(err, cli.ErrWaitTimeout) {
+		cli.Std.WriteError(cli.CommandError{
+			Message: fmt.Sprintf("Upgrade job %s still running after %s; it continues in the daemon.", jobID, timeout),
+			Code:    cli.CodeWaitTimeout,
+			Hint:    "Use 'payram-updater status' to check progress.",
+		})
+		return cli.ExitWaitTimeout
+	}
+	if err != nil {
+		cli.Std.WriteError(cli.CommandError{Message: fmt.Sprintf("Error: %v", err)})
+		return cli.ExitUpgradeFailed
+	}
+
+	switch job.State {
+	case jobs.JobStateReady:
+		fmt.Printf("Upgrade job %s completed: %s\n", job.JobID, job.Message)
+	case jobs.JobStateCancelled:
+		cli.Std.WriteError(cli.CommandError{Message: fmt.Sprintf("Upgrade job %s cancelled: %s", job.JobID, job.Message), Code: cli.CodeUpgradeCancelled})
+	default:
+		cli.Std.WriteError(cli.FailureError(fmt.Sprintf("Upgrade job %s failed:", job.JobID), job.FailureCode, job.Message,
+			"Use 'payram-updater status' for the recovery playbook and 'payram-updater recover' to attempt recovery."))
+	}
+	return cli.JobExitCode(job)
+}
+
+// runSynchronous executes the upgrade in this process, without a daemon, and
+// returns the exit code for its outcome. SIGINT and SIGTERM cancel it the way
+// 'POST /upgrade/cancel' does: only before the container is stopped.
+func runSynchronous(req *cli.UpgradeRequest, imageRepo string, yes, force, skipVerify bool) int {
+	logger.Init()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		cli.Std.WriteError(cli.CommandError{Message: fmt.Sprintf("Failed to load config: %v", err), Code: cli.CodeConfig})
+		return cli.ExitUpgradeFailed
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	confirmer := cli.NewConfirmer()
+	confirmResult := cli.ConfirmYes
+	confirm := func(plan *internalhttp.UpgradePlan) bool {
+		for _, warning := range plan.Warnings {
+			cli.Std.Warnf("%s\n", warning)
+		}
+		summary := &cli.UpgradeSummary{
+			Mode:            string(plan.Mode),
+			RequestedTarget: plan.RequestedTarget,
+			ResolvedTarget:  plan.ResolvedTarget,
+			ContainerName:   cfg.TargetContainerName,
+		}
+		if plan.Manifest != nil {
+			summary.ImageRepo = plan.Manifest.Image.Repo
+			summary.ImageRepoOverride = plan.ImageRepoOverride != ""
+		}
+		confirmResult = confirmer.Confirm(summary, yes)
+		return confirmResult == cli.ConfirmYes
+	}
+
+	server := internalhttp.New(cfg, newJobStore(cfg))
+	plan, job, err := server.RunUpgradeSync(ctx, jobs.JobMode(req.Mode), req.RequestedTarget, imageRepo, force, skipVerify, confirm)
+	if err != nil {
+		cli.Std.WriteError(cli.CommandError{Message: fmt.Sprintf("Error: %v", err)})
+		return cli.ExitUpgradeFailed
+	}
+
+	if plan.Resumed {
+		cli.Std.Warnf("Resumed interrupted upgrade job %s to %s instead of starting the requested upgrade. Run the command again for the requested upgrade.\n", job.JobID, job.ResolvedTarget)
+	}
+
+	switch {
+	case plan.AlreadyOnTarget:
+		fmt.Println(plan.Message)
+		return cli.ExitUpgradeSucceeded
+	case plan.State == jobs.JobStateFailed:
+		cli.Std.WriteError(cli.FailureError("Upgrade validation failed:", plan.FailureCode, plan.Message, ""))
+		return cli.FailureExitCode(plan.FailureCode)
+	case confirmResult == cli.ConfirmNo:
+		fmt.Println("Aborted by user.")
+		return cli.ExitUpgradeSucceeded
+	case confirmResult == cli.ConfirmNonInteractive:
+		cli.Std.WriteError(cli.CommandError{
+			Message: "ERROR: refusing to run without confirmation in non-interactive mode. Re-run with --yes.",
+			Code:    cli.CodeConfirmationNeeded,
+		})
+		return cli.ExitNeedsConfirm
+	}
+
+	switch job.State {
+	case jobs.JobStateReady:
+		fmt.Printf("Upgrade job %s completed: %s\n", job.JobID, job.Message)
+		return cli.ExitUpgradeSucceeded
+	case jobs.JobStateCancelled:
+		cli.Std.WriteError(cli.CommandError{Message: fmt.Sprintf("Upgrade job %s cancelled: %s", job.JobID, job.Message), Code: cli.CodeUpgradeCancelled})
+		return cli.ExitUpgradeCancelled
+	default:
+		cli.Std.WriteError(cli.FailureError(fmt.Sprintf("Upgrade job %s failed:", job.JobID), job.FailureCode, job.Message,
+			"Use 'payram-updater logs' for details and 'payram-updater recover' to attempt recovery."))
+		return cli.JobExitCode(job)
+	}
 }
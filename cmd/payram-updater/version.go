@@ -0,0 +1,15 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/payram/payram-updater/internal/buildinfo"
+	"github.com/payram/payram-updater/internal/cli"
+)
+
+// runVersion prints the updater's own build information. It needs neither
+// the configuration nor the daemon.
+func runVersion() {
+	jsonOut, _ := json.MarshalIndent(buildinfo.Get(), "", "  ")
+	cli.Std.PrintJSON(jsonOut)
+}
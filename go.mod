@@ -7,6 +7,7 @@ toolchain go1.24.12
 require (
 	github.com/hashicorp/go-version v1.8.0
 	github.com/sirupsen/logrus v1.9.3
-	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
+	golang.org/x/term v0.39.0
 )
+
+require golang.org/x/sys v0.40.0 // indirect
@@ -0,0 +1,74 @@
+package autoupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Attempts counts the failed auto-update jobs at one target version. It is
+// kept across restarts so a broken release is not retried forever.
+type Attempts struct {
+	Target          string    `json:"target"`
+	Failures        int       `json:"failures"`
+	LastFailureCode string    `json:"lastFailureCode,omitempty"`
+	GaveUpAt        time.Time `json:"gaveUpAt,omitempty"` // set once Failures reached the maximum
+}
+
+// AttemptsPath returns the path of the attempt counter in stateDir.
+func AttemptsPath(stateDir string) string {
+	return filepath.Join(stateDir, "auto-update-attempts.json")
+}
+
+// LoadAttempts reads the attempt counter at path. A missing file is an empty
+// counter.
+func LoadAttempts(path string) (*Attempts, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Attempts{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auto update attempts: %w", err)
+	}
+
+	var attempts Attempts
+	if err := json.Unmarshal(data, &attempts); err != nil {
+		return nil, fmt.Errorf("failed to parse auto update attempts: %w", err)
+	}
+	return &attempts, nil
+}
+
+// SaveAttempts writes the attempt counter to path.
+func SaveAttempts(path string, attempts *Attempts) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(attempts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode auto update attempts: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write auto update attempts: %w", err)
+	}
+	return nil
+}
+
+// For returns the counter for target: the same counter if it already
+// tracks target, otherwise a fresh one, as a new target starts over.
+func (a *Attempts) For(target string) *Attempts {
+	if a.Target == target {
+		return a
+	}
+	return &Attempts{Target: target}
+}
+
+// Exhausted reports whether maxAttempts failures were reached. A
+// maxAttempts of 0 never gives up.
+func (a *Attempts) Exhausted(maxAttempts int) bool {
+	return maxAttempts > 0 && a.Failures >= maxAttempts
+}
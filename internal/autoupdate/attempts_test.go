@@ -0,0 +1,45 @@
+package autoupdate
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAttempts_SaveAndLoad(t *testing.T) {
+	path := AttemptsPath(t.TempDir())
+
+	empty, err := LoadAttempts(path)
+	if err != nil || empty.Target != "" || empty.Failures != 0 {
+		t.Fatalf("expected an empty counter without a file, got %+v (%v)", empty, err)
+	}
+
+	if err := SaveAttempts(path, &Attempts{Target: "1.8.0", Failures: 2, LastFailureCode: "DOCKER_PULL_FAILED"}); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	loaded, err := LoadAttempts(path)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if loaded.Target != "1.8.0" || loaded.Failures != 2 || loaded.LastFailureCode != "DOCKER_PULL_FAILED" {
+		t.Errorf("unexpected counter: %+v", loaded)
+	}
+	if filepath.Base(path) != "auto-update-attempts.json" {
+		t.Errorf("unexpected path %s", path)
+	}
+}
+
+func TestAttempts_ForAndExhausted(t *testing.T) {
+	attempts := &Attempts{Target: "1.8.0", Failures: 3}
+	if !attempts.For("1.8.0").Exhausted(3) {
+		t.Error("expected 3 failures to exhaust 3 attempts")
+	}
+	if attempts.For("1.8.0").Exhausted(4) {
+		t.Error("expected 3 failures to leave a fourth attempt")
+	}
+	if next := attempts.For("1.8.1"); next.Failures != 0 || next.Target != "1.8.1" {
+		t.Errorf("expected a new target to start over, got %+v", next)
+	}
+	if attempts.Exhausted(0) {
+		t.Error("expected 0 to never give up")
+	}
+}
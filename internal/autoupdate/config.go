@@ -12,8 +12,20 @@ type Settings struct {
 	AutoUpdateEnabled       bool `json:"autoUpdateEnabled"`
 	AutoUpdateIntervalHours int  `json:"autoUpdateIntervalHours"`
 	Initialized             bool `json:"initialized"`
+
+	// AutoUpdateMaxAttempts is how many failed auto-update jobs a target
+	// version gets before auto-update stops trying it. 0 uses the default.
+	AutoUpdateMaxAttempts int `json:"autoUpdateMaxAttempts,omitempty"`
+
+	// Scheduled backups, configured with `payram-updater backup schedule`
+	BackupScheduleEnabled         bool `json:"backupScheduleEnabled,omitempty"`
+	BackupScheduleIntervalMinutes int  `json:"backupScheduleIntervalMinutes,omitempty"`
 }
 
+// MinBackupScheduleIntervalMinutes is the shortest allowed interval between
+// scheduled backups.
+const MinBackupScheduleIntervalMinutes = 60
+
 // DefaultStateDir is the default location for updater state.
 const DefaultStateDir = "/var/lib/payram-updater"
 
@@ -48,6 +60,12 @@ func Save(path string, settings *Settings) error {
 	if settings.AutoUpdateEnabled && settings.AutoUpdateIntervalHours < 1 {
 		return fmt.Errorf("auto_update_interval_hours must be at least 1 when auto updates are enabled")
 	}
+	if settings.AutoUpdateMaxAttempts < 0 {
+		return fmt.Errorf("auto update max attempts must be at least 1, got %d", settings.AutoUpdateMaxAttempts)
+	}
+	if settings.BackupScheduleEnabled && settings.BackupScheduleIntervalMinutes < MinBackupScheduleIntervalMinutes {
+		return fmt.Errorf("backup schedule interval must be at least 1h, got %dm", settings.BackupScheduleIntervalMinutes)
+	}
 
 	// Ensure parent directory exists
 	dir := filepath.Dir(path)
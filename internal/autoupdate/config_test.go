@@ -54,3 +54,24 @@ func TestSave_Validation(t *testing.T) {
 		t.Fatalf("expected no file written, got err=%v", err)
 	}
 }
+
+func TestSave_BackupSchedule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	settings := &Settings{Initialized: true, BackupScheduleEnabled: true, BackupScheduleIntervalMinutes: 24 * 60}
+	if err := Save(path, settings); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if !loaded.BackupScheduleEnabled || loaded.BackupScheduleIntervalMinutes != 24*60 {
+		t.Errorf("expected the backup schedule to round-trip, got %+v", loaded)
+	}
+
+	settings.BackupScheduleIntervalMinutes = 30
+	if err := Save(path, settings); err == nil {
+		t.Error("expected error for a backup schedule interval under an hour")
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -15,6 +17,7 @@ import (
 	"time"
 
 	"github.com/payram/payram-updater/internal/dbexec"
+	"github.com/payram/payram-updater/internal/statelock"
 )
 
 // BackupInfo contains metadata about a backup.
@@ -31,17 +34,27 @@ type BackupInfo struct {
 	Database      string    `json:"database"`
 	Host          string    `json:"host"`
 	Port          int       `json:"port"`
+	SnapshotID    string    `json:"snapshotId,omitempty"` // Set when a volume snapshot was taken
 }
 
 // BackupListItem contains metadata for a backup file discovered from filesystem.
 type BackupListItem struct {
 	File        string `json:"file"`        // Full path
 	Filename    string `json:"filename"`    // Basename
-	Format      string `json:"format"`      // "sql" or "dump"
+	Format      string `json:"format"`      // "sql", "dump" or "snapshot"
 	FromVersion string `json:"fromVersion"` // Parsed or "unknown"
 	ToVersion   string `json:"toVersion"`   // Parsed or "unknown"
 	CreatedAt   string `json:"createdAt"`   // RFC3339 if parseable, else empty
 	SizeBytes   int64  `json:"sizeBytes"`
+	SnapshotID  string `json:"snapshotId,omitempty"` // snapshot backups only
+	Pinned      bool   `json:"pinned"`               // exempt from pruning
+	Database    string `json:"database,omitempty"`   // per-database subdirectory (Config.PerDatabaseDirs) or empty
+
+	// TimestampSkewed is set when the filename timestamp is implausible
+	// (see timestampSkewTolerance); CreatedAt is then the file's mtime and
+	// FilenameCreatedAt the timestamp the filename claims.
+	TimestampSkewed   bool   `json:"timestampSkewed,omitempty"`
+	FilenameCreatedAt string `json:"filenameCreatedAt,omitempty"`
 }
 
 // BackupMeta contains metadata to pass when creating a backup.
@@ -78,11 +91,57 @@ type Config struct {
 	PGDB                string
 	PGUser              string
 	PGPassword          string
-	PGDumpBin           string // Path to pg_dump binary, default "pg_dump"
-	ImagePattern        string // Image pattern for container discovery, default "payramapp/payram:"
-	TargetContainerName string // Optional: explicit container name, bypasses semver discovery
+	PGSuperUser         string        // Optional: role RestoreBackup connects as instead of the discovered app user
+	PGSuperPassword     string        // PGSuperUser's password; unused for in-container databases, reached over the local socket
+	PGDumpBin           string        // Path to pg_dump binary, default "pg_dump"
+	ImagePattern        string        // Image pattern for container discovery, default "payramapp/payram:"
+	TargetContainerName string        // Optional: explicit container name, bypasses semver discovery
+	RestoreAttempts     int           // Max restore attempts while the DB is unreachable, default 3
+	RestoreCooldown     time.Duration // Wait between restore attempts, default 5s
+	DumpFormat          string        // pg_dump format of CreateBackup: DumpFormatCustom (default), DumpFormatPlain or DumpFormatDirectory
+	RequireMount        bool          // Fail CreateBackup with BACKUP_MOUNT_MISSING when Dir is on the root filesystem
+	Snapshot            SnapshotConfig
+
+	// PerDatabaseDirs writes each dump to a <Dir>/<database>/ subdirectory
+	// named after the dumped database, so several databases can share Dir.
+	// Backups directly in Dir are still listed and restorable.
+	PerDatabaseDirs bool
+	// DatabaseRetention overrides Retention for the backups of one database
+	// subdirectory (PerDatabaseDirs only).
+	DatabaseRetention map[string]int
 }
 
+// Dump formats for Config.DumpFormat.
+const (
+	DumpFormatCustom    = "custom"    // pg_dump -Fc, a .dump file restored with pg_restore
+	DumpFormatPlain     = "plain"     // pg_dump -Fp, a .sql file restored with psql
+	DumpFormatDirectory = "directory" // pg_dump -Fd, a .dir directory restored with pg_restore
+)
+
+// dirExt is the extension of a directory-format backup. Unlike the other
+// backups it is a directory holding pg_dump's toc.dat and data files.
+const dirExt = ".dir"
+
+// dumpFormats maps each dump format to its backup extension and the format
+// detectBackupFormat reports for that extension.
+var dumpFormats = map[string]struct{ ext, format string }{
+	DumpFormatCustom:    {".dump", "dump"},
+	DumpFormatPlain:     {".sql", "sql"},
+	DumpFormatDirectory: {dirExt, dbexec.FormatDirectory},
+}
+
+// ValidDumpFormat reports whether format is a supported Config.DumpFormat.
+// The empty string selects DumpFormatCustom.
+func ValidDumpFormat(format string) bool {
+	_, ok := dumpFormats[format]
+	return ok || format == ""
+}
+
+const (
+	defaultRestoreAttempts = 3
+	defaultRestoreCooldown = 5 * time.Second
+)
+
 // Manager handles backup operations.
 type Manager struct {
 	Config   Config
@@ -95,6 +154,12 @@ func NewManager(cfg Config, executor CommandExecutor, logger Logger) *Manager {
 	if cfg.PGDumpBin == "" {
 		cfg.PGDumpBin = "pg_dump"
 	}
+	if cfg.RestoreAttempts == 0 {
+		cfg.RestoreAttempts = defaultRestoreAttempts
+	}
+	if cfg.RestoreCooldown == 0 {
+		cfg.RestoreCooldown = defaultRestoreCooldown
+	}
 	return &Manager{
 		Config:   cfg,
 		Executor: executor,
@@ -102,6 +167,36 @@ func NewManager(cfg Config, executor CommandExecutor, logger Logger) *Manager {
 	}
 }
 
+// EnsureDir makes sure dir can hold backups: it is created (0755) if
+// missing, and it is an error for the path to be a file or not writable, or
+// to go through a dangling symlink (see CheckMount).
+// The daemon calls it at startup so a bad BACKUP_DIR fails immediately
+// rather than in the middle of the first upgrade.
+func EnsureDir(dir string) error {
+	if err := CheckMount(dir, false); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create backup directory %s: %w", dir, err)
+		}
+	case err != nil:
+		return fmt.Errorf("cannot access backup directory %s: %w", dir, err)
+	case !info.IsDir():
+		return fmt.Errorf("backup directory %s exists but is not a directory", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("backup directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
 // CreateBackup creates a new database backup using pg_dump.
 // Returns BackupInfo with metadata, or an error.
 // Backups are always enabled.
@@ -109,12 +204,59 @@ func NewManager(cfg Config, executor CommandExecutor, logger Logger) *Manager {
 // BACKUP LOCATION MODES (matches restore logic):
 // 1. If POSTGRES_HOST is localhost/127.0.0.1 → IN_CONTAINER_DB (run pg_dump via docker exec)
 // 2. Else → EXTERNAL_DB (run pg_dump from host)
+//
+// With a snapshot strategy configured, a volume snapshot is taken first; the
+// "snapshot" strategy then returns without running pg_dump at all.
+// Config.DumpFormat selects the pg_dump format and the backup's extension.
 func (m *Manager) CreateBackup(ctx context.Context, meta BackupMeta) (*BackupInfo, error) {
-	// Ensure backup directory exists
+	dumpFormat := m.Config.DumpFormat
+	if dumpFormat == "" {
+		dumpFormat = DumpFormatCustom
+	}
+	format, ok := dumpFormats[dumpFormat]
+	if !ok {
+		return nil, fmt.Errorf("BACKUP_FAILED: unsupported dump format %q (must be custom, plain or directory)", dumpFormat)
+	}
+
+	// Ensure backup directory exists, and is not a missing mount
+	if err := CheckMount(m.Config.Dir, m.Config.RequireMount); err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(m.Config.Dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
+	timestamp := time.Now().UTC().Format("20060102-150405")
+	fromVer := sanitizeVersion(meta.FromVersion)
+
+	var snapshot *SnapshotMeta
+	if strategy := m.Config.Snapshot.effectiveStrategy(); strategy != StrategyDump {
+		snapshotPath, snap, err := m.Config.Snapshot.createSnapshot(ctx, m.Config.Dir, timestamp, meta, m.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("SNAPSHOT_FAILED: %w", err)
+		}
+		if strategy == StrategySnapshot {
+			info := &BackupInfo{
+				ID:            fmt.Sprintf("%s-%s", timestamp, fromVer),
+				Path:          snapshotPath,
+				Filename:      filepath.Base(snapshotPath),
+				CreatedAt:     snap.CreatedAt,
+				FromVersion:   meta.FromVersion,
+				TargetVersion: meta.TargetVersion,
+				JobID:         meta.JobID,
+				SnapshotID:    snap.SnapshotID,
+			}
+			if fileInfo, err := os.Stat(snapshotPath); err == nil {
+				info.Size = fileInfo.Size()
+			}
+			if err := updateLatestLink(snapshotPath); err != nil {
+				m.Logger.Printf("Warning: failed to update the latest backup link: %v", err)
+			}
+			return info, nil
+		}
+		snapshot = snap
+	}
+
 	// Create executor wrapper for dbexec
 	executor := &executorWrapper{executor: m.Executor}
 
@@ -135,13 +277,19 @@ func (m *Manager) CreateBackup(ctx context.Context, meta BackupMeta) (*BackupInf
 
 	m.Logger.Printf("Backup mode: %s, credential source: %s", dbCtx.Mode, dbCtx.CredSource)
 
-	// Generate filename: payram-backup-<timestamp>-<fromVersion>-to-<toVersion>.dump
-	timestamp := time.Now().UTC().Format("20060102-150405")
-	fromVer := sanitizeVersion(meta.FromVersion)
+	// Generate filename: payram-backup-<timestamp>-<fromVersion>-to-<toVersion>.<ext>,
+	// with the extension of the configured dump format
 	toVer := sanitizeVersion(meta.TargetVersion)
 
-	filename := fmt.Sprintf("payram-backup-%s-%s-to-%s.dump", timestamp, fromVer, toVer)
-	backupPath := filepath.Join(m.Config.Dir, filename)
+	filename := fmt.Sprintf("payram-backup-%s-%s-to-%s%s", timestamp, fromVer, toVer, format.ext)
+	backupDir := m.Config.Dir
+	if m.Config.PerDatabaseDirs {
+		backupDir = filepath.Join(m.Config.Dir, DatabaseDirName(dbCtx.Creds.Database))
+		if err := os.MkdirAll(backupDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create backup directory: %w", err)
+		}
+	}
+	backupPath := filepath.Join(backupDir, filename)
 
 	m.Logger.Printf("Creating backup: %s", backupPath)
 
@@ -174,7 +322,7 @@ func (m *Manager) CreateBackup(ctx context.Context, meta BackupMeta) (*BackupInf
 	}
 
 	// Execute backup
-	err = pgExec.Dump(ctx, dbCtx, backupPath, "custom")
+	err = pgExec.Dump(ctx, dbCtx, backupPath, format.format)
 	if err != nil {
 		return nil, err
 	}
@@ -202,13 +350,13 @@ func (m *Manager) CreateBackup(ctx context.Context, meta BackupMeta) (*BackupInf
 	}
 
 	// Get file info
-	fileInfo, err := os.Stat(backupPath)
+	size, err := backupSize(backupPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat backup file: %w", err)
 	}
 
 	// Calculate checksum
-	checksum, err := calculateChecksum(backupPath)
+	checksum, err := FileChecksum(backupPath)
 	if err != nil {
 		m.Logger.Printf("Warning: failed to calculate checksum: %v", err)
 		checksum = ""
@@ -219,7 +367,7 @@ func (m *Manager) CreateBackup(ctx context.Context, meta BackupMeta) (*BackupInf
 		ID:            fmt.Sprintf("%s-%s", timestamp, fromVer),
 		Path:          backupPath,
 		Filename:      filename,
-		Size:          fileInfo.Size(),
+		Size:          size,
 		Checksum:      checksum,
 		CreatedAt:     time.Now().UTC(),
 		FromVersion:   meta.FromVersion,
@@ -229,9 +377,16 @@ func (m *Manager) CreateBackup(ctx context.Context, meta BackupMeta) (*BackupInf
 		Host:          dbCtx.Creds.Host,
 		Port:          mustParsePort(dbCtx.Creds.Port),
 	}
+	if snapshot != nil {
+		info.SnapshotID = snapshot.SnapshotID
+	}
 
 	// No index file needed - backups are discovered via filesystem scan
 
+	if err := updateLatestLink(backupPath); err != nil {
+		m.Logger.Printf("Warning: failed to update the latest backup link: %v", err)
+	}
+
 	return info, nil
 }
 
@@ -245,7 +400,9 @@ func mustParsePort(portStr string) int {
 }
 
 // ListBackups returns all backups by scanning the filesystem.
-// Scans BACKUP_DIR for payram-backup-*.sql and payram-backup-*.dump files.
+// Scans BACKUP_DIR for payram-backup-*.sql, *.dump and *.snapshot files and
+// *.dir directories. With PerDatabaseDirs it also scans each <dir>/<database>/
+// subdirectory, setting Database on the backups found there.
 // Parses metadata from filenames when possible.
 // Returns sorted by timestamp DESC (parseable) or file modtime DESC (fallback).
 func (m *Manager) ListBackups() ([]BackupListItem, error) {
@@ -254,39 +411,87 @@ func (m *Manager) ListBackups() ([]BackupListItem, error) {
 		return nil, fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
-	entries, err := os.ReadDir(m.Config.Dir)
+	backups, err := m.scanBackupDir(m.Config.Dir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Config.PerDatabaseDirs {
+		entries, err := os.ReadDir(m.Config.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup directory: %w", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() || strings.HasPrefix(name, "payram-backup-") || strings.HasPrefix(name, ".") {
+				continue
+			}
+			dbBackups, err := m.scanBackupDir(filepath.Join(m.Config.Dir, name), name)
+			if err != nil {
+				m.Logger.Printf("Warning: %v", err)
+				continue
+			}
+			backups = append(backups, dbBackups...)
+		}
+	}
+
+	// Sort by timestamp (parsed or modtime) descending
+	sort.Slice(backups, func(i, j int) bool {
+		// Try to parse timestamps
+		tiI, errI := time.Parse(time.RFC3339, backups[i].CreatedAt)
+		tiJ, errJ := time.Parse(time.RFC3339, backups[j].CreatedAt)
+
+		if errI == nil && errJ == nil {
+			return tiI.After(tiJ)
+		}
+
+		// Fallback: compare by modtime
+		infoI, errI := os.Stat(backups[i].File)
+		infoJ, errJ := os.Stat(backups[j].File)
+		if errI == nil && errJ == nil {
+			return infoI.ModTime().After(infoJ.ModTime())
+		}
+
+		// Last resort: lexicographic by filename (descending)
+		return backups[i].Filename > backups[j].Filename
+	})
+
+	return backups, nil
+}
+
+// scanBackupDir returns the backups directly in dir, unsorted, with
+// Database set to database.
+func (m *Manager) scanBackupDir(dir, database string) ([]BackupListItem, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read backup directory: %w", err)
 	}
 
 	var backups []BackupListItem
 	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
 		filename := entry.Name()
-		// Match payram-backup-*.sql or payram-backup-*.dump
-		if !strings.HasPrefix(filename, "payram-backup-") {
+		// Match payram-backup-*.sql, payram-backup-*.dump, payram-backup-*.snapshot
+		// or a payram-backup-*.dir directory. Symlinks, such as the latest
+		// link, are skipped so no backup is listed twice.
+		if !strings.HasPrefix(filename, "payram-backup-") || entry.Type()&os.ModeSymlink != 0 {
 			continue
 		}
-		if !strings.HasSuffix(filename, ".sql") && !strings.HasSuffix(filename, ".dump") {
+		format := detectBackupFormat(filename)
+		if format == "unknown" || entry.IsDir() != (format == dbexec.FormatDirectory) {
 			continue
 		}
 
-		fullPath := filepath.Join(m.Config.Dir, filename)
-		info, err := os.Stat(fullPath)
+		fullPath := filepath.Join(dir, filename)
+		size, err := backupSize(fullPath)
 		if err != nil {
 			m.Logger.Printf("Warning: failed to stat backup %s: %v", filename, err)
 			continue
 		}
 
-		// Determine format
-		format := "unknown"
-		if strings.HasSuffix(filename, ".sql") {
-			format = "sql"
-		} else if strings.HasSuffix(filename, ".dump") {
-			format = "dump"
+		info, err := entry.Info()
+		if err != nil {
+			m.Logger.Printf("Warning: failed to stat backup %s: %v", filename, err)
+			continue
 		}
 
 		// Parse metadata from filename
@@ -299,38 +504,55 @@ func (m *Manager) ListBackups() ([]BackupListItem, error) {
 			FromVersion: meta.FromVersion,
 			ToVersion:   meta.ToVersion,
 			CreatedAt:   meta.CreatedAt,
-			SizeBytes:   info.Size(),
+			SizeBytes:   size,
+			Pinned:      isPinned(fullPath),
+			Database:    database,
 		}
-
-		backups = append(backups, backup)
-	}
-
-	// Sort by timestamp (parsed or modtime) descending
-	sort.Slice(backups, func(i, j int) bool {
-		// Try to parse timestamps
-		tiI, errI := time.Parse(time.RFC3339, backups[i].CreatedAt)
-		tiJ, errJ := time.Parse(time.RFC3339, backups[j].CreatedAt)
-
-		if errI == nil && errJ == nil {
-			return tiI.After(tiJ)
+		if format == "snapshot" {
+			snapshot, err := ReadSnapshotMeta(fullPath)
+			if err != nil {
+				m.Logger.Printf("Warning: %v", err)
+				continue
+			}
+			backup.SnapshotID = snapshot.SnapshotID
 		}
-
-		// Fallback: compare by modtime
-		infoI, errI := os.Stat(backups[i].File)
-		infoJ, errJ := os.Stat(backups[j].File)
-		if errI == nil && errJ == nil {
-			return infoI.ModTime().After(infoJ.ModTime())
+		if createdAt, skewed := checkTimestampSkew(meta.CreatedAt, info.ModTime(), time.Now()); skewed {
+			m.Logger.Printf("Warning: backup %s claims to be created at %s but was last modified at %s (was the clock wrong?); sorting it by modification time", filename, meta.CreatedAt, createdAt)
+			backup.TimestampSkewed = true
+			backup.FilenameCreatedAt = meta.CreatedAt
+			backup.CreatedAt = createdAt
 		}
 
-		// Last resort: lexicographic by filename (descending)
-		return backups[i].Filename > backups[j].Filename
-	})
+		backups = append(backups, backup)
+	}
 
 	return backups, nil
 }
 
+// timestampSkewTolerance is how far a backup's filename timestamp may lie
+// after its mtime, or after the current time, before it is considered wrong.
+// A backup is named when it starts and written afterwards, so its mtime
+// should never be earlier than its name; copying a backup only makes the
+// mtime later.
+const timestampSkewTolerance = time.Hour
+
+// checkTimestampSkew reports whether filenameCreatedAt (RFC3339) is
+// implausible for a backup last modified at modTime, as when the backup was
+// created while the host clock was wrong. If so it returns modTime as the
+// RFC3339 creation time to use instead.
+func checkTimestampSkew(filenameCreatedAt string, modTime, now time.Time) (string, bool) {
+	claimed, err := time.Parse(time.RFC3339, filenameCreatedAt)
+	if err != nil {
+		return "", false
+	}
+	if claimed.Sub(modTime) <= timestampSkewTolerance && claimed.Sub(now) <= timestampSkewTolerance {
+		return "", false
+	}
+	return modTime.UTC().Format(time.RFC3339), true
+}
+
 // parseBackupFilename extracts metadata from backup filename.
-// Expected format: payram-backup-YYYYMMDD-HHMMSS-fromVer-to-toVer.{sql|dump}
+// Expected format: payram-backup-YYYYMMDD-HHMMSS-fromVer-to-toVer.{sql|dump|dir|snapshot}
 // Returns "unknown" for fields that cannot be parsed.
 func parseBackupFilename(filename string) struct {
 	FromVersion string
@@ -351,6 +573,8 @@ func parseBackupFilename(filename string) struct {
 	name := strings.TrimPrefix(filename, "payram-backup-")
 	name = strings.TrimSuffix(name, ".sql")
 	name = strings.TrimSuffix(name, ".dump")
+	name = strings.TrimSuffix(name, dirExt)
+	name = strings.TrimSuffix(name, snapshotExt)
 
 	// Split by '-'
 	parts := strings.Split(name, "-")
@@ -384,41 +608,164 @@ func parseBackupFilename(filename string) struct {
 }
 
 // PruneBackups removes old backups, keeping only the specified retention count.
-// Returns the list of pruned backups.
+// With PerDatabaseDirs each database subdirectory is pruned on its own,
+// keeping Config.DatabaseRetention[database] backups, or retention when the
+// database has no override; backups directly in Dir form their own group.
+// Pinned backups are never pruned and do not count toward retention.
+// Returns the list of pruned backups. For snapshot backups only the metadata
+// file is removed; the volume snapshot itself is left to the volume manager.
 func (m *Manager) PruneBackups(retention int) ([]BackupListItem, error) {
+	return m.PruneBackupsWhere(retention, nil)
+}
+
+// PruneBackupsWhere prunes like PruneBackups, among the backups match
+// reports true for only: the others are neither removed nor counted toward
+// retention. A nil match selects every backup.
+func (m *Manager) PruneBackupsWhere(retention int, match func(BackupListItem) bool) ([]BackupListItem, error) {
 	if retention < 1 {
 		return nil, fmt.Errorf("retention must be at least 1")
 	}
 
-	backups, err := m.ListBackups()
+	listed, err := m.ListBackups()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(backups) <= retention {
-		m.Logger.Printf("No backups to prune (have %d, retention %d)", len(backups), retention)
-		return nil, nil
+	// Group by database, keeping each group sorted newest first
+	groups := map[string][]BackupListItem{}
+	var databases []string
+	for _, backup := range listed {
+		if match != nil && !match(backup) {
+			continue
+		}
+		if backup.Pinned {
+			m.Logger.Printf("Keeping pinned backup: %s", backup.Filename)
+			continue
+		}
+		if _, ok := groups[backup.Database]; !ok {
+			databases = append(databases, backup.Database)
+		}
+		groups[backup.Database] = append(groups[backup.Database], backup)
 	}
 
-	// Backups are sorted newest first, so keep the first `retention` and remove the rest
-	toRemove := backups[retention:]
-
 	var pruned []BackupListItem
-	for _, backup := range toRemove {
-		// Remove the file
-		if err := os.Remove(backup.File); err != nil {
-			if !os.IsNotExist(err) {
-				m.Logger.Printf("Warning: failed to remove backup file %s: %v", backup.File, err)
-				continue
+	for _, database := range databases {
+		backups := groups[database]
+		keep := retention
+		if n, ok := m.Config.DatabaseRetention[database]; ok && database != "" {
+			keep = n
+		}
+
+		if len(backups) <= keep {
+			if database == "" {
+				m.Logger.Printf("No backups to prune (have %d, retention %d)", len(backups), keep)
+			} else {
+				m.Logger.Printf("No %s backups to prune (have %d, retention %d)", database, len(backups), keep)
 			}
+			continue
+		}
+
+		// Backups are sorted newest first, so keep the first `keep` and remove the rest
+		for _, backup := range backups[keep:] {
+			// Remove the file, or the whole directory of a directory-format backup
+			remove := os.Remove
+			if backup.Format == dbexec.FormatDirectory {
+				remove = os.RemoveAll
+			}
+			if err := remove(backup.File); err != nil {
+				if !os.IsNotExist(err) {
+					m.Logger.Printf("Warning: failed to remove backup file %s: %v", backup.File, err)
+					continue
+				}
+			}
+			os.Remove(backup.File + databaseExt)
+			m.Logger.Printf("Pruned backup: %s", backup.Filename)
+			pruned = append(pruned, backup)
 		}
-		m.Logger.Printf("Pruned backup: %s", backup.Filename)
-		pruned = append(pruned, backup)
 	}
 
 	return pruned, nil
 }
 
+// DatabaseDirName returns the subdirectory of Dir holding database's backups
+// with PerDatabaseDirs. Backups are listed and pruned under this name, so
+// Config.DatabaseRetention is keyed by it too.
+func DatabaseDirName(database string) string {
+	name := sanitizeVersion(database)
+	if name == "" || strings.HasPrefix(name, ".") {
+		return "default"
+	}
+	return name
+}
+
+// pinnedExt is the suffix of the empty marker file that pins a backup: a
+// backup at path is pinned while path+pinnedExt exists.
+const pinnedExt = ".pinned"
+
+func isPinned(path string) bool {
+	_, err := os.Stat(path + pinnedExt)
+	return err == nil
+}
+
+// databaseExt is the suffix of the file recording which database a backup
+// was dumped from: path+databaseExt holds the database name. RestoreBackup
+// restores into that database; a backup without one restores into the
+// container's own database.
+const databaseExt = ".database"
+
+// recordedDatabase returns the database recorded for the backup at path, if
+// any.
+func recordedDatabase(path string) (string, bool) {
+	data, err := os.ReadFile(path + databaseExt)
+	if err != nil {
+		return "", false
+	}
+	database := strings.TrimSpace(string(data))
+	return database, database != ""
+}
+
+// recordDatabase records database as the one the backup at path was dumped
+// from.
+func recordDatabase(path, database string) error {
+	return os.WriteFile(path+databaseExt, []byte(database+"\n"), 0644)
+}
+
+// PinBackup marks the backup at path as pinned, so PruneBackups never
+// removes it. Pinning an already pinned backup is a no-op.
+func (m *Manager) PinBackup(path string) (*BackupListItem, error) {
+	item, err := m.GetBackupByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("backup not found in %s: %s", m.Config.Dir, path)
+	}
+
+	if err := os.WriteFile(item.File+pinnedExt, nil, 0644); err != nil {
+		return nil, fmt.Errorf("failed to pin backup: %w", err)
+	}
+	item.Pinned = true
+	return item, nil
+}
+
+// UnpinBackup removes the pin from the backup at path, so it is pruned
+// like any other backup. Unpinning a backup that is not pinned is a no-op.
+func (m *Manager) UnpinBackup(path string) (*BackupListItem, error) {
+	item, err := m.GetBackupByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("backup not found in %s: %s", m.Config.Dir, path)
+	}
+
+	if err := os.Remove(item.File + pinnedExt); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to unpin backup: %w", err)
+	}
+	item.Pinned = false
+	return item, nil
+}
+
 // indexPath returns the path to the backups.json index file.
 func (m *Manager) indexPath() string {
 	return filepath.Join(m.Config.Dir, "backups.json")
@@ -490,8 +837,44 @@ func sanitizeVersion(v string) string {
 	return replacer.Replace(v)
 }
 
-// calculateChecksum computes SHA256 checksum of a file.
-func calculateChecksum(path string) (string, error) {
+// FileChecksum computes the hex-encoded SHA256 checksum of a backup. For a
+// directory-format backup it is the SHA256 of a sha256sum-style listing of
+// its files, one "<sha256>  <name>" line each in name order, so a change to
+// any file, or a file added or removed, changes it.
+func FileChecksum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return fileSHA256(path)
+	}
+
+	// WalkDir visits entries in lexical order, so the listing is sorted
+	h := sha256.New()
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		sum, err := fileSHA256(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s  %s\n", sum, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileSHA256 computes the hex-encoded SHA256 checksum of a file.
+func fileSHA256(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", err
@@ -506,6 +889,84 @@ func calculateChecksum(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// latestLinkName is the base name of the link to the newest backup kept in
+// each backup directory for external tooling such as offsite sync scripts.
+// The backup's extension is appended, so a custom-format backup is linked as
+// latest.dump and a plain one as latest.sql.
+const latestLinkName = "latest"
+
+// latestLinkExts are the extensions a latest link can have. Updating the
+// link removes those of the other formats, so none points at an older backup.
+var latestLinkExts = []string{".dump", ".sql", dirExt, snapshotExt}
+
+// updateLatestLink points the latest link in the backup's directory at
+// backupPath. The link is relative, so it survives the directory being
+// mounted elsewhere, and replaced atomically. Where the filesystem does not
+// support symlinks, a backup file is copied instead.
+func updateLatestLink(backupPath string) error {
+	dir := filepath.Dir(backupPath)
+	ext := filepath.Ext(backupPath)
+	link := filepath.Join(dir, latestLinkName+ext)
+	tmp := link + ".tmp"
+
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(backupPath), tmp); err != nil {
+		info, statErr := os.Stat(backupPath)
+		if statErr != nil || info.IsDir() {
+			return fmt.Errorf("failed to link %s: %w", link, err)
+		}
+		if err := copyBackupFile(backupPath, tmp); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to copy %s to %s: %w", backupPath, link, err)
+		}
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", link, err)
+	}
+
+	// The link carries the backup's recorded database along, so restoring
+	// it targets the same database as restoring the backup itself
+	if database, ok := recordedDatabase(backupPath); ok {
+		if err := recordDatabase(link, database); err != nil {
+			return fmt.Errorf("failed to record the database of %s: %w", link, err)
+		}
+	} else {
+		os.Remove(link + databaseExt)
+	}
+
+	for _, other := range latestLinkExts {
+		if other == ext {
+			continue
+		}
+		stale := filepath.Join(dir, latestLinkName+other)
+		if info, err := os.Lstat(stale); err == nil && !info.IsDir() {
+			os.Remove(stale)
+			os.Remove(stale + databaseExt)
+		}
+	}
+	return nil
+}
+
+// copyBackupFile copies the backup file src to dst.
+func copyBackupFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // GetLatestBackup returns the most recent backup, or nil if none exist.
 func (m *Manager) GetLatestBackup() (*BackupListItem, error) {
 	backups, err := m.ListBackups()
@@ -540,6 +1001,38 @@ func (m *Manager) GetBackupByPath(path string) (*BackupListItem, error) {
 	return nil, nil
 }
 
+// restoreLockFile is the lock file, in the backup directory, held while a
+// restore or rollback runs, and by the daemon while it upgrades, restarts or
+// backs up Payram, so that none of them overlaps a restore in another
+// process.
+const restoreLockFile = ".restore.lock"
+
+// LockRestore takes the restore lock for restoring backupPath (see
+// LockOperation).
+func (m *Manager) LockRestore(backupPath string) (*statelock.Lock, error) {
+	return m.LockOperation("restore of " + backupPath)
+}
+
+// LockOperation takes the restore lock for the operation described by what,
+// e.g. "upgrade job-1". It fails with RESTORE_IN_PROGRESS, naming the running
+// operation, if another process holds it. The caller must Release the
+// returned lock when the operation is done.
+func (m *Manager) LockOperation(what string) (*statelock.Lock, error) {
+	if err := EnsureDir(m.Config.Dir); err != nil {
+		return nil, err
+	}
+	detail := fmt.Sprintf("%s (started %s)", what, time.Now().UTC().Format(time.RFC3339))
+	lock, err := statelock.AcquireFile(filepath.Join(m.Config.Dir, restoreLockFile), detail)
+	var held *statelock.HeldError
+	if errors.As(err, &held) {
+		return nil, fmt.Errorf("RESTORE_IN_PROGRESS: another process (PID %d) holds the restore lock for the %s; wait for it to finish", held.PID, held.Detail)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take restore lock: %w", err)
+	}
+	return lock, nil
+}
+
 // RestoreOptions contains options for restore operation.
 type RestoreOptions struct {
 	// Confirmed indicates user has explicitly confirmed the restore.
@@ -549,8 +1042,23 @@ type RestoreOptions struct {
 	// If empty, will attempt to discover the Payram container automatically.
 	ContainerName string
 	// FullRecovery indicates whether to perform full recovery (DB restore + container rollback).
-	// If true, skips the interactive recovery prompt.
+	// If true, skips the interactive recovery prompt, and once the database is
+	// restored ContainerName must be able to connect to it with its own
+	// credentials, or the restore fails with POST_RESTORE_CONNECT_FAILED
+	// (returning the result, as the database was restored).
 	FullRecovery bool
+	// Locked indicates the caller already holds the restore lock (see
+	// LockRestore), so RestoreBackup does not take it again.
+	Locked bool
+	// CompareChecksum compares the object counts listed in a custom or
+	// directory format backup with those in the restored database, and
+	// reports any difference as a warning.
+	CompareChecksum bool
+	// Scratch restores into the database inside ContainerName, a throwaway
+	// container: its credentials are discovered from that container, and a
+	// snapshot or an external database is refused so the restore can never
+	// reach a real database.
+	Scratch bool
 }
 
 // RestoreResult contains the result of a restore operation.
@@ -563,12 +1071,15 @@ type RestoreResult struct {
 	ToVersion string
 	// NeedsRecovery indicates if the backup was taken during an upgrade
 	NeedsRecovery bool
+	// Warnings are non-fatal findings, e.g. post-restore verification discrepancies
+	Warnings []string
 }
 
 // RestoreBackup restores a database from a backup file.
 // Detects format based on file extension:
 // - .sql files use psql
-// - .dump files use pg_restore
+// - .dump files and .dir directories use pg_restore
+// - .snapshot files run the configured snapshot rollback command
 // Requires explicit confirmation via opts.Confirmed = true.
 // Returns RestoreResult containing backup metadata for potential container rollback.
 //
@@ -588,6 +1099,15 @@ func (m *Manager) RestoreBackup(ctx context.Context, backupPath string, opts Res
 		return nil, fmt.Errorf("backup verification failed: %w", err)
 	}
 
+	// Only one restore may write to the database at a time
+	if !opts.Locked {
+		lock, err := m.LockRestore(backupPath)
+		if err != nil {
+			return nil, err
+		}
+		defer lock.Release()
+	}
+
 	// Extract backup metadata from filename
 	filename := filepath.Base(backupPath)
 	metadata := parseBackupFilename(filename)
@@ -595,7 +1115,24 @@ func (m *Manager) RestoreBackup(ctx context.Context, backupPath string, opts Res
 	// Detect format
 	format := detectBackupFormat(backupPath)
 	if format == "unknown" {
-		return nil, fmt.Errorf("INVALID_BACKUP_FORMAT: unsupported file extension (must be .sql or .dump)")
+		return nil, fmt.Errorf("INVALID_BACKUP_FORMAT: unsupported file extension (must be .sql, .dump, .dir or .snapshot)")
+	}
+
+	if opts.Scratch && (format == "snapshot" || opts.ContainerName == "") {
+		return nil, fmt.Errorf("RESTORE_FAILED: a scratch restore needs a dump backup and a container (got %s format)", format)
+	}
+
+	if format == "snapshot" {
+		// A snapshot rollback replaces the volume; no database credentials are involved.
+		if _, err := m.Config.Snapshot.rollbackSnapshot(ctx, backupPath, m.Logger); err != nil {
+			return nil, err
+		}
+		return &RestoreResult{
+			DBRestored:    true,
+			FromVersion:   metadata.FromVersion,
+			ToVersion:     metadata.ToVersion,
+			NeedsRecovery: metadata.FromVersion != "unknown" && metadata.ToVersion != "unknown",
+		}, nil
 	}
 
 	m.Logger.Printf("Restoring database from: %s (format: %s)", backupPath, format)
@@ -603,8 +1140,12 @@ func (m *Manager) RestoreBackup(ctx context.Context, backupPath string, opts Res
 	// STRICT CREDENTIAL RESOLUTION using shared dbexec package
 	executor := &executorWrapper{executor: m.Executor}
 
+	discoverName := m.Config.TargetContainerName
+	if opts.Scratch {
+		discoverName = opts.ContainerName
+	}
 	dbCtx, err := dbexec.DiscoverDBContext(ctx, executor, dbexec.DiscoverOpts{
-		ContainerName: m.Config.TargetContainerName,
+		ContainerName: discoverName,
 		ImagePattern:  m.Config.ImagePattern,
 		BackupDir:     m.Config.Dir,
 		Logger:        m.Logger,
@@ -618,6 +1159,29 @@ func (m *Manager) RestoreBackup(ctx context.Context, backupPath string, opts Res
 	}
 
 	m.Logger.Printf("Credential source: %s", dbCtx.CredSource)
+	if opts.Scratch && dbCtx.Mode != dbexec.DBModeInContainer {
+		return nil, fmt.Errorf("RESTORE_FAILED: scratch restore refused: the database is external (%s:%s), not inside container %s", dbCtx.Creds.Host, dbCtx.Creds.Port, opts.ContainerName)
+	}
+
+	// A backup of another database than the container's (BACKUP_DATABASE)
+	// restores into that database, not over the container's own. A scratch
+	// database is thrown away, so it takes the dump whatever its source.
+	if database, ok := recordedDatabase(backupPath); ok && database != dbCtx.Creds.Database && !opts.Scratch {
+		m.Logger.Printf("Restoring into database %s, which the backup was taken from (the container uses %s)", database, dbCtx.Creds.Database)
+		dbCtx.Creds.Database = database
+	}
+
+	// pg_restore --clean drops and recreates objects the app user may not
+	// own, so restore as the superuser when one is configured. A scratch
+	// database belongs to the app user, and may not have the superuser.
+	appUser := dbCtx.Creds.Username
+	if m.Config.PGSuperUser != "" && !opts.Scratch {
+		dbCtx.Creds.Username = m.Config.PGSuperUser
+		dbCtx.Creds.Password = m.Config.PGSuperPassword
+		m.Logger.Printf("Restoring as database role %s (PG_SUPERUSER)", dbCtx.Creds.Username)
+	} else {
+		m.Logger.Printf("Restoring as database role %s (app user)", dbCtx.Creds.Username)
+	}
 
 	// Select executor based on mode
 	var pgExec dbexec.PGExecutor
@@ -653,10 +1217,15 @@ func (m *Manager) RestoreBackup(ctx context.Context, backupPath string, opts Res
 	}
 
 	// Execute restore
-	err = pgExec.Restore(ctx, dbCtx, backupPath, format)
+	err = m.restoreWithRetry(ctx, pgExec, dbCtx, backupPath, format)
 	if err != nil {
 		return nil, err
 	}
+	if dbCtx.Creds.Username != appUser {
+		if err := m.reassignOwnership(ctx, pgExec, dbCtx, appUser); err != nil {
+			return nil, fmt.Errorf("RESTORE_FAILED: the database was restored as %s, but its objects could not be handed to %s, which Payram connects as: %w", dbCtx.Creds.Username, appUser, err)
+		}
+	}
 
 	// Build restore result with backup metadata
 	result := &RestoreResult{
@@ -665,23 +1234,113 @@ func (m *Manager) RestoreBackup(ctx context.Context, backupPath string, opts Res
 		ToVersion:     metadata.ToVersion,
 		NeedsRecovery: metadata.FromVersion != "unknown" && metadata.ToVersion != "unknown",
 	}
+	if opts.CompareChecksum {
+		result.Warnings = m.verifyRestoredObjects(ctx, pgExec, dbCtx, backupPath, format)
+	}
+
+	// A full recovery is only done once the rollback container itself can
+	// use the restored database, with its own credentials.
+	if opts.FullRecovery && opts.ContainerName != "" {
+		m.Logger.Printf("Checking that %s can connect to the restored database...", opts.ContainerName)
+		if err := dbexec.CheckContainerConnection(ctx, executor, opts.ContainerName); err != nil {
+			return result, fmt.Errorf("%w (the database itself was restored)", err)
+		}
+	}
 
 	return result, nil
 }
 
-// detectBackupFormat returns "sql", "dump", or "unknown" based on file extension.
+// verifyRestoredObjects runs the post-restore object count comparison and
+// returns its discrepancies as warnings. The restore has already succeeded,
+// so a comparison that cannot run is a warning too.
+func (m *Manager) verifyRestoredObjects(ctx context.Context, pgExec dbexec.PGExecutor, dbCtx dbexec.DBContext, backupPath, format string) []string {
+	if format == "sql" {
+		return []string{"Post-restore verification skipped: only custom and directory format backups list their objects"}
+	}
+
+	m.Logger.Printf("Comparing restored object counts with the backup's table of contents...")
+	discrepancies, err := dbexec.VerifyRestoredObjects(ctx, pgExec, dbCtx, backupPath, format)
+	if err != nil {
+		return []string{fmt.Sprintf("Post-restore verification could not run: %v", err)}
+	}
+	warnings := make([]string, 0, len(discrepancies))
+	for _, d := range discrepancies {
+		warnings = append(warnings, "Post-restore verification: "+d)
+	}
+	if len(warnings) == 0 {
+		m.Logger.Printf("Post-restore verification passed: object counts match the backup")
+	}
+	return warnings
+}
+
+// restoreWithRetry runs the restore, retrying after a cooldown only while the
+// database cannot be reached at all (e.g. its container is still starting).
+// Nothing has been applied in that case, so a retry is safe; any other error,
+// including a connection lost mid-restore, is returned immediately.
+func (m *Manager) restoreWithRetry(ctx context.Context, pgExec dbexec.PGExecutor, dbCtx dbexec.DBContext, backupPath, format string) error {
+	attempts := max(m.Config.RestoreAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := pgExec.Restore(ctx, dbCtx, backupPath, format)
+		if err == nil || attempt >= attempts || !isTransientRestoreError(err) {
+			return err
+		}
+		m.Logger.Printf("Restore attempt %d/%d could not reach the database, retrying in %s: %v", attempt, attempts, m.Config.RestoreCooldown, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(m.Config.RestoreCooldown):
+		}
+	}
+}
+
+// transientRestoreMarkers are client-side messages meaning the database never
+// accepted the connection.
+var transientRestoreMarkers = []string{
+	"connection refused",
+	"could not connect to server",
+	"is the server running",
+	"the database system is starting up",
+}
+
+// isTransientRestoreError reports whether a restore failed before reaching
+// the database. Output carrying a server "ERROR:" means statements ran, so it
+// is never treated as transient.
+func isTransientRestoreError(err error) bool {
+	msg := err.Error()
+	if strings.Contains(msg, "ERROR:") {
+		return false
+	}
+	lower := strings.ToLower(msg)
+	for _, marker := range transientRestoreMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectBackupFormat returns "sql", "dump", "directory", "snapshot", or
+// "unknown" based on file extension.
 func detectBackupFormat(path string) string {
+	path = strings.TrimSuffix(path, string(filepath.Separator))
 	if strings.HasSuffix(path, ".sql") {
 		return "sql"
 	}
 	if strings.HasSuffix(path, ".dump") {
 		return "dump"
 	}
+	if strings.HasSuffix(path, dirExt) {
+		return dbexec.FormatDirectory
+	}
+	if strings.HasSuffix(path, snapshotExt) {
+		return "snapshot"
+	}
 	return "unknown"
 }
 
 // VerifyBackupFile checks that a backup file is valid for restore.
-// Checks: file exists, non-zero size, readable.
+// Checks: file exists, non-zero size, readable. A directory-format backup
+// must be a directory holding pg_dump's table of contents (toc.dat).
 func (m *Manager) VerifyBackupFile(path string) error {
 	// Check file exists
 	info, err := os.Stat(path)
@@ -692,6 +1351,13 @@ func (m *Manager) VerifyBackupFile(path string) error {
 		return fmt.Errorf("cannot stat backup file: %w", err)
 	}
 
+	if detectBackupFormat(path) == dbexec.FormatDirectory {
+		if !info.IsDir() {
+			return fmt.Errorf("directory-format backup is not a directory: %s", path)
+		}
+		return m.VerifyBackupFile(filepath.Join(path, "toc.dat"))
+	}
+
 	// Check it's a regular file
 	if info.IsDir() {
 		return fmt.Errorf("backup path is a directory, not a file: %s", path)
@@ -712,6 +1378,32 @@ func (m *Manager) VerifyBackupFile(path string) error {
 	return nil
 }
 
+// backupSize returns the size of a backup file, or the total size of the
+// files in a directory-format backup.
+func backupSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+
+	var total int64
+	err = filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += fi.Size()
+		return nil
+	})
+	return total, err
+}
+
 // executorWrapper wraps a backup.CommandExecutor to satisfy dbexec.CommandExecutor
 type executorWrapper struct {
 	executor CommandExecutor
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -76,6 +77,8 @@ func newTestManager(t *testing.T, executor *mockExecutor) (*Manager, string) {
 		PGUser:     "testuser",
 		PGPassword: "testpass",
 		PGDumpBin:  "pg_dump",
+		// Keep restore retries fast
+		RestoreCooldown: time.Millisecond,
 	}
 
 	return NewManager(cfg, executor, &mockLogger{}), tmpDir
@@ -341,6 +344,229 @@ func TestPruneBackups_RemovesOld(t *testing.T) {
 	}
 }
 
+func TestPruneBackups_KeepsPinned(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+
+	// Create 5 backup files and pin the two oldest
+	var files []string
+	for i := 1; i <= 5; i++ {
+		fname := fmt.Sprintf("payram-backup-2026010%d-100000-1.0.0-to-1.1.0.dump", i)
+		files = append(files, filepath.Join(tmpDir, "backups", fname))
+		if err := os.WriteFile(files[i-1], []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files[:2] {
+		if _, err := mgr.PinBackup(file); err != nil {
+			t.Fatalf("PinBackup failed: %v", err)
+		}
+	}
+
+	// Prune with the smallest retention - pinned backups must survive
+	pruned, err := mgr.PruneBackups(1)
+	if err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+	if len(pruned) != 2 {
+		t.Fatalf("expected 2 pruned backups, got %d", len(pruned))
+	}
+	for _, backup := range pruned {
+		if backup.Pinned {
+			t.Errorf("pinned backup %s was pruned", backup.Filename)
+		}
+	}
+
+	remaining, _ := mgr.ListBackups()
+	if len(remaining) != 3 {
+		t.Fatalf("expected 3 remaining backups, got %d", len(remaining))
+	}
+	if remaining[0].File != files[4] || remaining[0].Pinned {
+		t.Errorf("expected the newest unpinned backup to be kept, got %+v", remaining[0])
+	}
+	for _, backup := range remaining[1:] {
+		if !backup.Pinned {
+			t.Errorf("expected %s to be pinned", backup.Filename)
+		}
+	}
+}
+
+// writePerDatabaseBackups creates count dumps for each database in its
+// <backups>/<database>/ subdirectory, the newest last.
+func writePerDatabaseBackups(t *testing.T, tmpDir string, count int, databases ...string) {
+	t.Helper()
+	for _, database := range databases {
+		dir := filepath.Join(tmpDir, "backups", database)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= count; i++ {
+			fname := fmt.Sprintf("payram-backup-2026010%d-100000-1.0.0-to-1.1.0.dump", i)
+			if err := os.WriteFile(filepath.Join(dir, fname), []byte("data"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestListBackups_PerDatabaseDirs(t *testing.T) {
+	mgr, tmpDir := newTestManager(t, &mockExecutor{})
+	mgr.Config.PerDatabaseDirs = true
+
+	writePerDatabaseBackups(t, tmpDir, 2, "payram", "analytics")
+	flat := filepath.Join(tmpDir, "backups", "payram-backup-20260109-100000-1.0.0-to-1.1.0.dump")
+	if err := os.WriteFile(flat, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := mgr.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 5 {
+		t.Fatalf("expected 5 backups, got %d", len(backups))
+	}
+	if backups[0].File != flat || backups[0].Database != "" {
+		t.Errorf("expected the flat backup first without a database, got %+v", backups[0])
+	}
+
+	counts := map[string]int{}
+	for _, backup := range backups[1:] {
+		if filepath.Base(filepath.Dir(backup.File)) != backup.Database {
+			t.Errorf("backup %s listed with database %q", backup.File, backup.Database)
+		}
+		counts[backup.Database]++
+	}
+	if counts["payram"] != 2 || counts["analytics"] != 2 {
+		t.Errorf("expected 2 backups per database, got %v", counts)
+	}
+}
+
+func TestListBackups_IgnoresSubdirsWithoutPerDatabaseDirs(t *testing.T) {
+	mgr, tmpDir := newTestManager(t, &mockExecutor{})
+
+	writePerDatabaseBackups(t, tmpDir, 2, "payram")
+
+	backups, err := mgr.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected subdirectory backups to be ignored, got %d", len(backups))
+	}
+}
+
+func TestPruneBackups_PerDatabaseRetention(t *testing.T) {
+	mgr, tmpDir := newTestManager(t, &mockExecutor{})
+	mgr.Config.PerDatabaseDirs = true
+	mgr.Config.DatabaseRetention = map[string]int{"analytics": 1}
+
+	writePerDatabaseBackups(t, tmpDir, 4, "payram", "analytics")
+
+	// payram keeps the default retention of 2, analytics its override of 1
+	pruned, err := mgr.PruneBackups(2)
+	if err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+	if len(pruned) != 5 {
+		t.Fatalf("expected 5 pruned backups, got %d", len(pruned))
+	}
+
+	remaining, _ := mgr.ListBackups()
+	counts := map[string]int{}
+	for _, backup := range remaining {
+		counts[backup.Database]++
+		if !strings.HasPrefix(backup.Filename, "payram-backup-20260104") && !strings.HasPrefix(backup.Filename, "payram-backup-20260103") {
+			t.Errorf("expected only the newest backups to be kept, got %s", backup.File)
+		}
+	}
+	if counts["payram"] != 2 || counts["analytics"] != 1 {
+		t.Errorf("expected 2 payram and 1 analytics backups to remain, got %v", counts)
+	}
+}
+
+func TestUnpinBackup_MakesBackupPrunable(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+
+	oldest := filepath.Join(tmpDir, "backups", "payram-backup-20260101-100000-1.0.0-to-1.1.0.dump")
+	newest := filepath.Join(tmpDir, "backups", "payram-backup-20260102-100000-1.1.0-to-1.2.0.dump")
+	for _, file := range []string{oldest, newest} {
+		if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := mgr.PinBackup(oldest); err != nil {
+		t.Fatalf("PinBackup failed: %v", err)
+	}
+
+	item, err := mgr.UnpinBackup(oldest)
+	if err != nil {
+		t.Fatalf("UnpinBackup failed: %v", err)
+	}
+	if item.Pinned {
+		t.Error("expected the backup to be unpinned")
+	}
+	// Unpinning twice is a no-op
+	if _, err := mgr.UnpinBackup(oldest); err != nil {
+		t.Fatalf("second UnpinBackup failed: %v", err)
+	}
+
+	pruned, err := mgr.PruneBackups(1)
+	if err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].File != oldest {
+		t.Errorf("expected the unpinned oldest backup to be pruned, got %+v", pruned)
+	}
+}
+
+func TestPinBackup_UnknownFile(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+
+	if _, err := mgr.PinBackup(filepath.Join(tmpDir, "backups", "payram-backup-missing.dump")); err == nil {
+		t.Error("expected an error pinning a backup that does not exist")
+	}
+}
+
+func TestPruneBackupsWhere_OnlyCountsMatchingBackups(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+
+	// Two old upgrade backups and three newer scheduled ones
+	names := []string{
+		"payram-backup-20260101-100000-1.0.0-to-1.1.0.dump",
+		"payram-backup-20260102-100000-1.1.0-to-1.2.0.dump",
+		"payram-backup-20260103-100000-scheduled-to-scheduled.dump",
+		"payram-backup-20260104-100000-scheduled-to-scheduled.dump",
+		"payram-backup-20260105-100000-scheduled-to-scheduled.dump",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(tmpDir, "backups", name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := mgr.PruneBackupsWhere(1, func(b BackupListItem) bool { return b.FromVersion == "scheduled" })
+	if err != nil {
+		t.Fatalf("PruneBackupsWhere failed: %v", err)
+	}
+	if len(pruned) != 2 {
+		t.Fatalf("expected the 2 older scheduled backups pruned, got %d", len(pruned))
+	}
+	for _, b := range pruned {
+		if b.FromVersion != "scheduled" {
+			t.Errorf("expected only scheduled backups pruned, got %s", b.Filename)
+		}
+	}
+	for _, name := range names[:2] {
+		if _, err := os.Stat(filepath.Join(tmpDir, "backups", name)); err != nil {
+			t.Errorf("expected upgrade backup %s to be kept: %v", name, err)
+		}
+	}
+}
+
 func TestPruneBackups_InvalidRetention(t *testing.T) {
 	executor := &mockExecutor{}
 	mgr, _ := newTestManager(t, executor)
@@ -585,6 +811,256 @@ POSTGRES_PASSWORD=testpass
 	}
 }
 
+func TestRestoreBackup_ScratchRefusesExternalDatabase(t *testing.T) {
+	// The container's environment points at a database outside it
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			if name == "docker" && len(args) > 1 && args[0] == "inspect" {
+				return []byte(`["POSTGRES_HOST=db.internal.example","POSTGRES_PORT=5432","POSTGRES_DATABASE=payram","POSTGRES_USERNAME=payram","POSTGRES_PASSWORD=secret"]`), nil
+			}
+			return []byte("success"), nil
+		},
+	}
+	mgr, tmpDir := newTestManager(t, executor)
+	backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+	os.WriteFile(backupPath, []byte("backup data"), 0644)
+
+	_, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "payram-migration-check", Scratch: true})
+
+	if err == nil || !strings.Contains(err.Error(), "scratch restore refused") {
+		t.Fatalf("expected an external database to be refused, got %v", err)
+	}
+	for _, call := range executor.calls {
+		if strings.Contains(strings.Join(call.Args, " "), "pg_restore") {
+			t.Errorf("expected no restore to run, got %s %v", call.Name, call.Args)
+		}
+	}
+}
+
+// restoreRoleCall restores a dump with PG_SUPERUSER set to superUser and
+// returns the restore command run: pg_restore for an external database, the
+// docker exec shell command otherwise.
+func restoreRoleCall(t *testing.T, postgresHost, superUser string, opts RestoreOptions) mockCall {
+	t.Helper()
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			if name == "docker" && len(args) > 1 && args[0] == "inspect" {
+				return []byte(`["POSTGRES_HOST=` + postgresHost + `","POSTGRES_PORT=5432","POSTGRES_DATABASE=payram","POSTGRES_USERNAME=payram","POSTGRES_PASSWORD=secret"]`), nil
+			}
+			return []byte("success"), nil
+		},
+	}
+	mgr, tmpDir := newTestManager(t, executor)
+	mgr.Config.TargetContainerName = "payram-core"
+	mgr.Config.PGSuperUser = superUser
+	mgr.Config.PGSuperPassword = "supersecret"
+	backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+	os.WriteFile(backupPath, []byte("backup data"), 0644)
+
+	opts.Confirmed = true
+	if _, err := mgr.RestoreBackup(context.Background(), backupPath, opts); err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	for _, call := range executor.calls {
+		if strings.Contains(call.Name+" "+strings.Join(call.Args, " "), "pg_restore") {
+			return call
+		}
+	}
+	t.Fatal("expected a pg_restore call")
+	return mockCall{}
+}
+
+func TestRestoreBackup_UsesSuperUserWhenConfigured(t *testing.T) {
+	// External database: pg_restore runs on the host with the role's password
+	call := restoreRoleCall(t, "db.internal.example", "postgres", RestoreOptions{})
+	if args := strings.Join(call.Args, " "); !strings.Contains(args, "-U postgres") {
+		t.Errorf("expected the restore to connect as the superuser, got %v", call.Args)
+	}
+	if !slices.Contains(call.Env, "PGPASSWORD=supersecret") || slices.Contains(call.Env, "PGPASSWORD=secret") {
+		t.Errorf("expected the superuser's password, got env %v", call.Env)
+	}
+
+	// In-container database: pg_restore runs in the container as the role
+	call = restoreRoleCall(t, "localhost", "postgres", RestoreOptions{})
+	if command := strings.Join(call.Args, " "); !strings.Contains(command, "pg_restore --clean --if-exists --no-owner --no-privileges -U postgres -d payram") {
+		t.Errorf("expected the in-container restore to connect as the superuser, got %s", command)
+	}
+}
+
+func TestRestoreBackup_RestoresIntoRecordedDatabase(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts RestoreOptions
+		want string
+	}{
+		{"recorded database", RestoreOptions{}, "-d analytics"},
+		{"scratch keeps its own database", RestoreOptions{ContainerName: "payram-migration-check", Scratch: true}, "-d payram"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &mockExecutor{
+				executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+					if name == "docker" && len(args) > 1 && args[0] == "inspect" {
+						return []byte(`["POSTGRES_HOST=localhost","POSTGRES_PORT=5432","POSTGRES_DATABASE=payram","POSTGRES_USERNAME=payram","POSTGRES_PASSWORD=secret"]`), nil
+					}
+					return []byte("success"), nil
+				},
+			}
+			mgr, tmpDir := newTestManager(t, executor)
+			mgr.Config.TargetContainerName = "payram-core"
+			backupPath := filepath.Join(tmpDir, "backups", "test.sql")
+			os.WriteFile(backupPath, []byte("backup data"), 0644)
+			if err := recordDatabase(backupPath, "analytics"); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := tt.opts
+			opts.Confirmed = true
+			if _, err := mgr.RestoreBackup(context.Background(), backupPath, opts); err != nil {
+				t.Fatalf("RestoreBackup failed: %v", err)
+			}
+			var restored bool
+			for _, call := range executor.calls {
+				if command := strings.Join(call.Args, " "); strings.Contains(command, "psql") {
+					restored = true
+					if !strings.Contains(command, tt.want) {
+						t.Errorf("expected the restore to target %q, got %s", tt.want, command)
+					}
+				}
+			}
+			if !restored {
+				t.Fatal("expected a psql restore call")
+			}
+		})
+	}
+}
+
+func TestRestoreBackup_SuperUserHandsObjectsToAppUser(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		superUser  string
+		ownerErr   error
+		wantOwner  bool
+		wantErrMsg string
+	}{
+		{"superuser", "postgres", nil, true, ""},
+		{"app user", "", nil, false, ""},
+		{"ownership fails", "postgres", fmt.Errorf("permission denied"), true, "could not be handed to payram"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var ownerSQL string
+			executor := &mockExecutor{
+				executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+					if name == "docker" && len(args) > 1 && args[0] == "inspect" {
+						return []byte(`["POSTGRES_HOST=db.internal.example","POSTGRES_PORT=5432","POSTGRES_DATABASE=payram","POSTGRES_USERNAME=payram","POSTGRES_PASSWORD=secret"]`), nil
+					}
+					if sql := strings.Join(args, " "); strings.Contains(sql, "OWNER TO") {
+						ownerSQL = sql
+						return nil, tt.ownerErr
+					}
+					return []byte("success"), nil
+				},
+			}
+			mgr, tmpDir := newTestManager(t, executor)
+			mgr.Config.TargetContainerName = "payram-core"
+			mgr.Config.PGSuperUser = tt.superUser
+			backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+			os.WriteFile(backupPath, []byte("backup data"), 0644)
+
+			_, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true})
+			if tt.wantErrMsg == "" && err != nil {
+				t.Fatalf("RestoreBackup failed: %v", err)
+			}
+			if tt.wantErrMsg != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErrMsg)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErrMsg, err)
+			}
+			if !tt.wantOwner {
+				if ownerSQL != "" {
+					t.Errorf("expected no ownership change for an app user restore, got %s", ownerSQL)
+				}
+				return
+			}
+			if !strings.Contains(ownerSQL, "app text := 'payram'") || !strings.Contains(ownerSQL, "-U postgres") {
+				t.Errorf("expected the superuser to hand the restored objects to payram, got %q", ownerSQL)
+			}
+		})
+	}
+}
+
+func TestRestoreBackup_FallsBackToAppUser(t *testing.T) {
+	call := restoreRoleCall(t, "db.internal.example", "", RestoreOptions{})
+	if args := strings.Join(call.Args, " "); !strings.Contains(args, "-U payram") {
+		t.Errorf("expected the restore to connect as the app user, got %v", call.Args)
+	}
+	if !slices.Contains(call.Env, "PGPASSWORD=secret") {
+		t.Errorf("expected the app user's password, got env %v", call.Env)
+	}
+
+	// A scratch database belongs to the app user, whatever PG_SUPERUSER says
+	call = restoreRoleCall(t, "localhost", "postgres", RestoreOptions{ContainerName: "payram-migration-check", Scratch: true})
+	if command := strings.Join(call.Args, " "); !strings.Contains(command, "-U payram -d payram") {
+		t.Errorf("expected a scratch restore to connect as the app user, got %s", command)
+	}
+}
+
+func TestRestoreBackup_ScratchRefusesSnapshot(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+	backupPath := filepath.Join(tmpDir, "backups", "test.snapshot")
+	os.WriteFile(backupPath, []byte("snapshot"), 0644)
+
+	_, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "payram-migration-check", Scratch: true})
+
+	if err == nil || !strings.Contains(err.Error(), "snapshot format") {
+		t.Fatalf("expected a snapshot to be refused, got %v", err)
+	}
+	if len(executor.calls) != 0 {
+		t.Errorf("expected no commands, got %+v", executor.calls)
+	}
+}
+
+func TestRestoreBackup_RejectedWhileAnotherRestoreRuns(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+	backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+	os.WriteFile(backupPath, []byte("backup data"), 0644)
+
+	running, err := mgr.LockRestore(backupPath)
+	if err != nil {
+		t.Fatalf("LockRestore failed: %v", err)
+	}
+	defer running.Release()
+
+	_, err = mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock"})
+	if err == nil {
+		t.Fatal("expected a second restore to be rejected")
+	}
+	if !strings.Contains(err.Error(), "RESTORE_IN_PROGRESS") || !strings.Contains(err.Error(), backupPath) {
+		t.Errorf("expected RESTORE_IN_PROGRESS naming the running restore, got: %v", err)
+	}
+	if len(executor.calls) != 0 {
+		t.Errorf("expected no commands while another restore runs, got %d", len(executor.calls))
+	}
+}
+
+func TestRestoreBackup_ReleasesRestoreLock(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+	backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+	os.WriteFile(backupPath, []byte("backup data"), 0644)
+
+	// The restore fails after taking the lock; the lock must still be freed
+	mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock"})
+
+	if _, err := os.Stat(filepath.Join(mgr.Config.Dir, restoreLockFile)); !os.IsNotExist(err) {
+		t.Errorf("expected restore lock to be released, stat err=%v", err)
+	}
+	lock, err := mgr.LockRestore(backupPath)
+	if err != nil {
+		t.Fatalf("expected restore lock to be free, got %v", err)
+	}
+	lock.Release()
+}
+
 func TestRestoreBackup_FileNotFound(t *testing.T) {
 	executor := &mockExecutor{}
 	mgr, _ := newTestManager(t, executor)
@@ -653,6 +1129,188 @@ func TestRestoreBackup_PgRestoreFails(t *testing.T) {
 	}
 }
 
+// newRestoreRetryManager returns a manager with persisted in-container DB
+// credentials and a backup file, whose restore command is handled by restore.
+func newRestoreRetryManager(t *testing.T, restore func(call int) ([]byte, error)) (*Manager, string, *int) {
+	t.Helper()
+	calls := 0
+	executor := mockDockerInspectExecutor(func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+		if name == "sh" {
+			calls++
+			return restore(calls)
+		}
+		return []byte("success"), nil
+	})
+	mgr, tmpDir := newTestManager(t, executor)
+
+	stateDir := filepath.Join(tmpDir, "state")
+	os.MkdirAll(stateDir, 0755)
+	dbEnvContent := "POSTGRES_HOST=localhost\nPOSTGRES_PORT=5432\nPOSTGRES_DATABASE=testdb\nPOSTGRES_USERNAME=testuser\nPOSTGRES_PASSWORD=testpass\n"
+	os.WriteFile(filepath.Join(stateDir, "db.env"), []byte(dbEnvContent), 0600)
+
+	backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+	os.WriteFile(backupPath, []byte("backup data"), 0644)
+	return mgr, backupPath, &calls
+}
+
+func TestRestoreBackup_RetriesTransientConnectionError(t *testing.T) {
+	mgr, backupPath, calls := newRestoreRetryManager(t, func(call int) ([]byte, error) {
+		if call == 1 {
+			return []byte(`pg_restore: error: connection to server on socket "/var/run/postgresql/.s.PGSQL.5432" failed: Connection refused`), &mockError{msg: "exit status 1"}
+		}
+		return []byte("restore complete"), nil
+	})
+
+	result, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock"})
+	if err != nil {
+		t.Fatalf("expected restore to succeed after a retry, got: %v", err)
+	}
+	if !result.DBRestored {
+		t.Error("expected DBRestored to be true")
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 restore attempts, got %d", *calls)
+	}
+}
+
+func TestRestoreBackup_DataErrorNotRetried(t *testing.T) {
+	mgr, backupPath, calls := newRestoreRetryManager(t, func(call int) ([]byte, error) {
+		return []byte(`pg_restore: error: could not execute query: ERROR:  duplicate key value violates unique constraint "users_pkey"`), &mockError{msg: "exit status 1"}
+	})
+
+	if _, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock"}); err == nil {
+		t.Fatal("expected restore to fail")
+	}
+	if *calls != 1 {
+		t.Errorf("expected a data error not to be retried, got %d attempts", *calls)
+	}
+}
+
+func TestRestoreBackup_RetryCapReached(t *testing.T) {
+	mgr, backupPath, calls := newRestoreRetryManager(t, func(call int) ([]byte, error) {
+		return []byte("psql: error: could not connect to server: Connection refused"), &mockError{msg: "exit status 2"}
+	})
+
+	_, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock"})
+	if err == nil || !strings.Contains(err.Error(), "RESTORE_FAILED") {
+		t.Fatalf("expected RESTORE_FAILED after the retry cap, got: %v", err)
+	}
+	if *calls != defaultRestoreAttempts {
+		t.Errorf("expected %d restore attempts, got %d", defaultRestoreAttempts, *calls)
+	}
+}
+
+func TestRestoreBackup_CompareChecksumReportsDiscrepancies(t *testing.T) {
+	list := "215; 1259 16400 TABLE public users payram\n216; 1259 16410 TABLE public payments payram\n3340; 0 16400 TABLE DATA public users payram\n"
+	executor := mockDockerInspectExecutor(func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+		if name == "sh" && strings.Contains(args[1], "pg_restore --list") {
+			return []byte(list), nil
+		}
+		if name == "docker" && len(args) > 2 && args[2] == "psql" {
+			return []byte("TABLE|1\n"), nil
+		}
+		return []byte("restore complete"), nil
+	})
+	mgr, tmpDir := newTestManager(t, executor)
+	stateDir := filepath.Join(tmpDir, "state")
+	os.MkdirAll(stateDir, 0755)
+	os.WriteFile(filepath.Join(stateDir, "db.env"), []byte("POSTGRES_HOST=localhost\nPOSTGRES_PORT=5432\nPOSTGRES_DATABASE=testdb\nPOSTGRES_USERNAME=testuser\nPOSTGRES_PASSWORD=testpass\n"), 0600)
+	backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+	os.WriteFile(backupPath, []byte("backup data"), 0644)
+
+	result, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock", CompareChecksum: true})
+	if err != nil {
+		t.Fatalf("expected the restore to succeed despite discrepancies, got: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "TABLE: backup has 2, database has 1") {
+		t.Errorf("expected a missing-table warning, got %v", result.Warnings)
+	}
+
+	// Without the option no verification runs
+	result, err = mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock"})
+	if err != nil || len(result.Warnings) != 0 {
+		t.Errorf("expected no verification warnings by default, got %v (err=%v)", result.Warnings, err)
+	}
+}
+
+func TestRestoreBackup_CompareChecksumSkipsPlainSQL(t *testing.T) {
+	mgr, backupPath, _ := newRestoreRetryManager(t, func(call int) ([]byte, error) {
+		return []byte("restore complete"), nil
+	})
+	sqlPath := strings.TrimSuffix(backupPath, ".dump") + ".sql"
+	if err := os.Rename(backupPath, sqlPath); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mgr.RestoreBackup(context.Background(), sqlPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock", CompareChecksum: true})
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "skipped") {
+		t.Errorf("expected a skipped-verification warning for a plain SQL backup, got %v", result.Warnings)
+	}
+}
+
+func TestRestoreBackup_FullRecoveryChecksContainerConnection(t *testing.T) {
+	var connectOutput []byte
+	var connectErr error
+	executor := mockDockerInspectExecutor(func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+		if name == "docker" && len(args) > 3 && args[0] == "exec" && args[2] == "sh" {
+			return connectOutput, connectErr
+		}
+		return []byte("restore complete"), nil
+	})
+	mgr, tmpDir := newTestManager(t, executor)
+	stateDir := filepath.Join(tmpDir, "state")
+	os.MkdirAll(stateDir, 0755)
+	os.WriteFile(filepath.Join(stateDir, "db.env"), []byte("POSTGRES_HOST=localhost\nPOSTGRES_PORT=5432\nPOSTGRES_DATABASE=testdb\nPOSTGRES_USERNAME=testuser\nPOSTGRES_PASSWORD=testpass\n"), 0600)
+	backupPath := filepath.Join(tmpDir, "backups", "test.dump")
+	os.WriteFile(backupPath, []byte("backup data"), 0644)
+	opts := RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock", FullRecovery: true}
+
+	connectOutput = []byte("1\n")
+	if _, err := mgr.RestoreBackup(context.Background(), backupPath, opts); err != nil {
+		t.Fatalf("expected the full recovery to succeed, got: %v", err)
+	}
+
+	connectOutput, connectErr = []byte(`psql: error: FATAL:  password authentication failed for user "payram"`), &mockError{msg: "exit status 2"}
+	result, err := mgr.RestoreBackup(context.Background(), backupPath, opts)
+	if err == nil || !strings.Contains(err.Error(), "POST_RESTORE_CONNECT_FAILED") {
+		t.Fatalf("expected POST_RESTORE_CONNECT_FAILED, got: %v", err)
+	}
+	if result == nil || !result.DBRestored {
+		t.Error("expected the result to report the database as restored")
+	}
+
+	// A database-only restore does not check the container
+	opts.FullRecovery = false
+	if _, err := mgr.RestoreBackup(context.Background(), backupPath, opts); err != nil {
+		t.Errorf("expected a database-only restore to skip the connection check, got: %v", err)
+	}
+}
+
+func TestIsTransientRestoreError(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want bool
+	}{
+		{"connection refused", "connection to server at \"db\" (10.0.0.2), port 5432 failed: Connection refused", true},
+		{"starting up", "FATAL:  the database system is starting up", true},
+		{"socket missing", "Is the server running locally and accepting connections on that socket?", true},
+		{"data error", "ERROR:  relation \"users\" already exists", false},
+		{"connection lost mid-restore", "server closed the connection unexpectedly", false},
+		{"no such container", "Error response from daemon: No such container: payram", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientRestoreError(&mockError{msg: tt.msg}); got != tt.want {
+				t.Errorf("isTransientRestoreError(%q) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestVerifyBackupFile_Valid(t *testing.T) {
 	mgr, tmpDir := newTestManager(t, &mockExecutor{})
 
@@ -850,6 +1508,8 @@ func TestDetectBackupFormat(t *testing.T) {
 		{"backup.txt", "unknown"},
 		{"/path/to/backup.sql", "sql"},
 		{"/path/to/backup.dump", "dump"},
+		{"/path/to/backup.dir", "directory"},
+		{"/path/to/backup.dir/", "directory"},
 	}
 
 	for _, tt := range tests {
@@ -935,6 +1595,290 @@ POSTGRES_PASSWORD=testpass
 	}
 }
 
+// newDirectoryBackup creates a directory-format backup holding a toc.dat.
+func newDirectoryBackup(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "toc.dat"), []byte("PGDMP"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "3001.dat.gz"), []byte("table data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateBackup_DumpFormats(t *testing.T) {
+	os.Setenv("POSTGRES_HOST", "external-db.example.com")
+	os.Setenv("POSTGRES_DATABASE", "testdb")
+	os.Setenv("POSTGRES_USER", "testuser")
+	defer func() {
+		os.Unsetenv("POSTGRES_HOST")
+		os.Unsetenv("POSTGRES_DATABASE")
+		os.Unsetenv("POSTGRES_USER")
+	}()
+
+	tests := []struct {
+		dumpFormat string
+		flag       string
+		ext        string
+		listed     string
+	}{
+		{"", "-Fc", ".dump", "dump"},
+		{DumpFormatCustom, "-Fc", ".dump", "dump"},
+		{DumpFormatPlain, "-Fp", ".sql", "sql"},
+		{DumpFormatDirectory, "-Fd", ".dir", "directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dumpFormat, func(t *testing.T) {
+			executor := &mockExecutor{
+				executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+					out := args[slicesIndex(args, "-f")+1]
+					if containsArg(args, "-Fd") {
+						newDirectoryBackup(t, out)
+						return nil, nil
+					}
+					return nil, os.WriteFile(out, []byte("fake backup data"), 0644)
+				},
+			}
+			mgr, _ := newTestManager(t, executor)
+			mgr.Config.DumpFormat = tt.dumpFormat
+
+			info, err := mgr.CreateBackup(context.Background(), BackupMeta{FromVersion: "manual", TargetVersion: "manual"})
+			if err != nil {
+				t.Fatalf("CreateBackup failed: %v", err)
+			}
+
+			if !containsArg(executor.calls[0].Args, tt.flag) {
+				t.Errorf("expected pg_dump %s, got %v", tt.flag, executor.calls[0].Args)
+			}
+			if !strings.HasSuffix(info.Filename, tt.ext) || info.Size == 0 {
+				t.Errorf("expected a non-empty %s backup, got %s (%d bytes)", tt.ext, info.Filename, info.Size)
+			}
+			backups, err := mgr.ListBackups()
+			if err != nil || len(backups) != 1 {
+				t.Fatalf("expected the backup to be listed, got %v (err %v)", backups, err)
+			}
+			if backups[0].Format != tt.listed || backups[0].SizeBytes != info.Size {
+				t.Errorf("expected a %s backup of %d bytes, got %+v", tt.listed, info.Size, backups[0])
+			}
+			if err := mgr.VerifyBackupFile(info.Path); err != nil {
+				t.Errorf("expected the backup to verify, got %v", err)
+			}
+			if target, err := os.Readlink(filepath.Join(mgr.Config.Dir, "latest"+tt.ext)); err != nil || target != info.Filename {
+				t.Errorf("expected latest%s to link to %s, got %q (err %v)", tt.ext, info.Filename, target, err)
+			}
+		})
+	}
+}
+
+func TestCreateBackup_UpdatesLatestLink(t *testing.T) {
+	os.Setenv("POSTGRES_HOST", "external-db.example.com")
+	os.Setenv("POSTGRES_DATABASE", "testdb")
+	os.Setenv("POSTGRES_USER", "testuser")
+	defer func() {
+		os.Unsetenv("POSTGRES_HOST")
+		os.Unsetenv("POSTGRES_DATABASE")
+		os.Unsetenv("POSTGRES_USER")
+	}()
+
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return nil, os.WriteFile(args[slicesIndex(args, "-f")+1], []byte("fake backup data"), 0644)
+		},
+	}
+	mgr, _ := newTestManager(t, executor)
+	link := filepath.Join(mgr.Config.Dir, "latest.dump")
+
+	for i, target := range []string{"1.7.9", "1.8.0"} {
+		info, err := mgr.CreateBackup(context.Background(), BackupMeta{FromVersion: "1.7.8", TargetVersion: target})
+		if err != nil {
+			t.Fatalf("CreateBackup failed: %v", err)
+		}
+		if got, err := os.Readlink(link); err != nil || got != info.Filename {
+			t.Fatalf("expected latest.dump to link to %s, got %q (err %v)", info.Filename, got, err)
+		}
+
+		backups, err := mgr.ListBackups()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(backups) != i+1 {
+			t.Fatalf("expected %d backups without the link, got %d", i+1, len(backups))
+		}
+		for _, b := range backups {
+			if b.Filename == "latest.dump" {
+				t.Errorf("expected the latest link not to be listed, got %+v", b)
+			}
+		}
+	}
+}
+
+func TestUpdateLatestLink_RemovesOtherFormats(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "payram-backup-20260101-120000-1.7.8-to-1.7.9.sql")
+	custom := filepath.Join(dir, "payram-backup-20260102-120000-1.7.9-to-1.8.0.dump")
+	for _, path := range []string{plain, custom} {
+		if err := os.WriteFile(path, []byte("fake backup data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := updateLatestLink(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateLatestLink(custom); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, "latest.sql")); !os.IsNotExist(err) {
+		t.Errorf("expected latest.sql to be removed, got %v", err)
+	}
+	if got, err := os.Readlink(filepath.Join(dir, "latest.dump")); err != nil || got != filepath.Base(custom) {
+		t.Errorf("expected latest.dump to link to %s, got %q (err %v)", filepath.Base(custom), got, err)
+	}
+}
+
+func TestUpdateLatestLink_CarriesRecordedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	selected := filepath.Join(dir, "payram-backup-20260101-120000-1.7.8-to-1.7.9.sql")
+	unrecorded := filepath.Join(dir, "payram-backup-20260102-120000-1.7.9-to-1.8.0.sql")
+	for _, path := range []string{selected, unrecorded} {
+		if err := os.WriteFile(path, []byte("fake backup data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recordDatabase(selected, "analytics"); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "latest.sql")
+	if err := updateLatestLink(selected); err != nil {
+		t.Fatal(err)
+	}
+	if database, ok := recordedDatabase(link); !ok || database != "analytics" {
+		t.Errorf("expected latest.sql to record database analytics, got %q", database)
+	}
+	if err := updateLatestLink(unrecorded); err != nil {
+		t.Fatal(err)
+	}
+	if database, ok := recordedDatabase(link); ok {
+		t.Errorf("expected latest.sql to record no database, got %q", database)
+	}
+}
+
+func TestCreateBackup_UnknownDumpFormat(t *testing.T) {
+	os.Setenv("POSTGRES_HOST", "external-db.example.com")
+	defer os.Unsetenv("POSTGRES_HOST")
+	executor := &mockExecutor{}
+	mgr, _ := newTestManager(t, executor)
+	mgr.Config.DumpFormat = "tar"
+
+	if _, err := mgr.CreateBackup(context.Background(), BackupMeta{}); err == nil || !strings.Contains(err.Error(), "unsupported dump format") {
+		t.Fatalf("expected an unsupported dump format error, got %v", err)
+	}
+	if len(executor.calls) != 0 {
+		t.Errorf("expected pg_dump not to run, got %v", executor.calls)
+	}
+}
+
+func TestRestoreBackup_DirectoryFormat(t *testing.T) {
+	executor := mockDockerInspectExecutor(nil)
+	mgr, tmpDir := newTestManager(t, executor)
+	stateDir := filepath.Join(tmpDir, "state")
+	os.MkdirAll(stateDir, 0755)
+	os.WriteFile(filepath.Join(stateDir, "db.env"), []byte("POSTGRES_HOST=localhost\nPOSTGRES_DATABASE=testdb\nPOSTGRES_USERNAME=testuser\n"), 0600)
+	backupPath := filepath.Join(tmpDir, "backups", "payram-backup-20260301-120000-1.0.0-to-1.1.0.dir")
+	newDirectoryBackup(t, backupPath)
+
+	result, err := mgr.RestoreBackup(context.Background(), backupPath, RestoreOptions{Confirmed: true, ContainerName: "test-payram-mock"})
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if result.FromVersion != "1.0.0" || result.ToVersion != "1.1.0" {
+		t.Errorf("expected versions parsed from the directory name, got %+v", result)
+	}
+
+	restore := executor.calls[len(executor.calls)-1]
+	cmd := strings.Join(restore.Args, " ")
+	if !strings.Contains(cmd, "docker cp "+backupPath+" test-payram-mock:") || !strings.Contains(cmd, "pg_restore") {
+		t.Errorf("expected the directory copied into the container and restored with pg_restore, got %s %s", restore.Name, cmd)
+	}
+}
+
+func TestVerifyBackupFile_DirectoryFormat(t *testing.T) {
+	mgr, tmpDir := newTestManager(t, &mockExecutor{})
+
+	valid := filepath.Join(tmpDir, "valid.dir")
+	newDirectoryBackup(t, valid)
+	if err := mgr.VerifyBackupFile(valid); err != nil {
+		t.Errorf("expected a directory backup with toc.dat to verify, got %v", err)
+	}
+
+	noTOC := filepath.Join(tmpDir, "no-toc.dir")
+	os.MkdirAll(noTOC, 0755)
+	if err := mgr.VerifyBackupFile(noTOC); err == nil || !strings.Contains(err.Error(), "toc.dat") {
+		t.Errorf("expected an error naming toc.dat, got %v", err)
+	}
+
+	file := filepath.Join(tmpDir, "file.dir")
+	os.WriteFile(file, []byte("data"), 0644)
+	if err := mgr.VerifyBackupFile(file); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("expected a not-a-directory error, got %v", err)
+	}
+}
+
+func TestFileChecksum_DirectoryBackup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backup.dir")
+	newDirectoryBackup(t, dir)
+
+	first, err := FileChecksum(dir)
+	if err != nil || len(first) != 64 {
+		t.Fatalf("expected a SHA256 of the directory backup, got %q (%v)", first, err)
+	}
+	if again, _ := FileChecksum(dir); again != first {
+		t.Errorf("expected the checksum to be stable, got %s then %s", first, again)
+	}
+
+	os.WriteFile(filepath.Join(dir, "3002.dat.gz"), []byte("more data"), 0644)
+	if added, _ := FileChecksum(dir); added == first {
+		t.Error("expected an added file to change the checksum")
+	}
+	os.Remove(filepath.Join(dir, "3002.dat.gz"))
+	os.WriteFile(filepath.Join(dir, "toc.dat"), []byte("changed"), 0644)
+	if changed, _ := FileChecksum(dir); changed == first {
+		t.Error("expected a changed toc.dat to change the checksum")
+	}
+}
+
+func TestPruneBackups_RemovesDirectoryBackup(t *testing.T) {
+	mgr, _ := newTestManager(t, &mockExecutor{})
+	old := filepath.Join(mgr.Config.Dir, "payram-backup-20260101-120000-1.0.0-to-1.1.0.dir")
+	newDirectoryBackup(t, old)
+	os.WriteFile(filepath.Join(mgr.Config.Dir, "payram-backup-20260201-120000-1.1.0-to-1.2.0.dump"), []byte("data"), 0644)
+
+	pruned, err := mgr.PruneBackups(1)
+	if err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].File != old {
+		t.Fatalf("expected the directory backup to be pruned, got %+v", pruned)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expected the backup directory to be removed, got %v", err)
+	}
+}
+
+func slicesIndex(slice []string, item string) int {
+	for i, s := range slice {
+		if s == item {
+			return i
+		}
+	}
+	return -1
+}
+
 // Test RestoreBackup with psql args
 func TestRestoreBackup_PsqlArgs(t *testing.T) {
 	executor := mockDockerInspectExecutor(func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
@@ -1068,3 +2012,115 @@ func TestListBackups_Sorting(t *testing.T) {
 		t.Errorf("expected 20260130 backup third, got %s", backups[2].Filename)
 	}
 }
+
+func TestListBackups_FutureDatedFilenameSortsByMtime(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+
+	now := time.Now()
+	future := now.Add(30 * 24 * time.Hour).UTC()
+	recent := now.Add(-time.Hour).UTC()
+	skewedName := "payram-backup-" + future.Format("20060102-150405") + "-1.0.0-to-1.1.0.dump"
+	realName := "payram-backup-" + recent.Format("20060102-150405") + "-1.1.0-to-1.2.0.dump"
+
+	// The skewed backup was written two hours ago, before the real one
+	skewedPath := filepath.Join(tmpDir, "backups", skewedName)
+	realPath := filepath.Join(tmpDir, "backups", realName)
+	for _, path := range []string{skewedPath, realPath} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+	}
+	skewedMtime := now.Add(-2 * time.Hour)
+	if err := os.Chtimes(skewedPath, skewedMtime, skewedMtime); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := mgr.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(backups))
+	}
+	if backups[0].Filename != realName {
+		t.Errorf("expected the real newest backup first, got %s", backups[0].Filename)
+	}
+
+	skewed := backups[1]
+	if skewed.Filename != skewedName || !skewed.TimestampSkewed {
+		t.Fatalf("expected the future-dated backup flagged and sorted second, got %+v", skewed)
+	}
+	if skewed.FilenameCreatedAt != future.Format(time.RFC3339) {
+		t.Errorf("expected filenameCreatedAt %s, got %s", future.Format(time.RFC3339), skewed.FilenameCreatedAt)
+	}
+	if skewed.CreatedAt != skewedMtime.UTC().Format(time.RFC3339) {
+		t.Errorf("expected createdAt corrected to the mtime %s, got %s", skewedMtime.UTC().Format(time.RFC3339), skewed.CreatedAt)
+	}
+	if backups[0].TimestampSkewed || backups[0].FilenameCreatedAt != "" {
+		t.Errorf("expected the real backup not to be flagged, got %+v", backups[0])
+	}
+}
+
+func TestCheckTimestampSkew(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		claimed  string
+		modTime  time.Time
+		expected bool
+	}{
+		{"written after it was named", "2026-03-01T10:00:00Z", now.Add(-time.Hour), false},
+		{"copied long after", "2026-01-01T10:00:00Z", now, false},
+		{"named within the tolerance after its mtime", "2026-03-01T11:30:00Z", now.Add(-time.Hour), false},
+		{"named a day after its mtime", "2026-03-02T10:00:00Z", now.Add(-2 * time.Hour), true},
+		{"named in the future with a future mtime", "2026-06-01T10:00:00Z", time.Date(2026, 6, 1, 11, 0, 0, 0, time.UTC), true},
+		{"unparseable filename timestamp", "", now, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			createdAt, skewed := checkTimestampSkew(tc.claimed, tc.modTime, now)
+			if skewed != tc.expected {
+				t.Fatalf("expected skewed=%v, got %v", tc.expected, skewed)
+			}
+			if skewed && createdAt != tc.modTime.UTC().Format(time.RFC3339) {
+				t.Errorf("expected the mtime as creation time, got %s", createdAt)
+			}
+		})
+	}
+}
+
+func TestEnsureDir_CreatesMissingDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data", "backups")
+
+	if err := EnsureDir(dir); err != nil {
+		t.Fatalf("EnsureDir failed: %v", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("expected backup directory to be created: %v", err)
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0755 {
+		t.Errorf("expected a 0755 directory, got %s", info.Mode())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("expected the write check to leave nothing behind, found %d entries", len(entries))
+	}
+}
+
+func TestEnsureDir_PathIsAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backups")
+	if err := os.WriteFile(path, []byte("not a directory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := EnsureDir(path)
+	if err == nil {
+		t.Fatal("expected an error when the backup path is a file")
+	}
+	if !strings.Contains(err.Error(), "is not a directory") {
+		t.Errorf("expected a clear not-a-directory error, got %v", err)
+	}
+}
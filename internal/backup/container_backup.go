@@ -3,6 +3,7 @@ package backup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +12,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/payram/payram-updater/internal/dbexec"
 )
 
 // ContainerBackupExecutor handles pg_dump backups with container-sourced credentials.
@@ -18,10 +21,69 @@ import (
 type ContainerBackupExecutor struct {
 	DockerBin       string
 	PGDumpBin       string // Used for external DB backups
+	PSQLBin         string // Used for the connectivity probe against external DBs
 	BackupDir       string
 	BackupTimeout   time.Duration
 	Logger          Logger
 	DockerInspector *DockerInspector
+
+	// PreBackupHook and PostBackupHook are optional shell commands or http(s)
+	// URLs run around each backup. The post-hook always runs, even when the
+	// pre-hook or the backup itself fails, so it can undo whatever the
+	// pre-hook paused.
+	PreBackupHook  string
+	PostBackupHook string
+	HookTimeout    time.Duration
+
+	// Snapshot optionally takes a volume snapshot instead of, or as well as,
+	// the pg_dump.
+	Snapshot SnapshotConfig
+
+	// Selection optionally narrows what the pg_dump backs up.
+	Selection DumpSelection
+
+	// PerDatabaseDirs writes the dump to a BackupDir/<database>/
+	// subdirectory, as Config.PerDatabaseDirs does.
+	PerDatabaseDirs bool
+
+	// RequireMount fails the backup with BACKUP_MOUNT_MISSING when BackupDir
+	// is on the root filesystem (see CheckMount).
+	RequireMount bool
+
+	// VerifyAfterCreate checks that the finished dump is restorable (see
+	// VerifyRestorable) and fails the backup with BACKUP_VERIFY_FAILED when
+	// it is not, before the upgrade touches the container.
+	VerifyAfterCreate bool
+
+	// DumpFormat is the pg_dump format, one of the Config.DumpFormat values.
+	// Empty dumps plain SQL.
+	DumpFormat string
+}
+
+// DumpSelection narrows the pre-upgrade pg_dump to one database and a subset
+// of its schemas. The zero value dumps the container's configured database in
+// full.
+type DumpSelection struct {
+	Database       string   // Dumped instead of the container's POSTGRES_DATABASE (pg_dump -d)
+	IncludeSchemas []string // Only these schemas are dumped (pg_dump -n)
+	ExcludeSchemas []string // These schemas are skipped (pg_dump -N)
+}
+
+// filtersSchemas reports whether the selection dumps only part of a database.
+func (s DumpSelection) filtersSchemas() bool {
+	return len(s.IncludeSchemas) > 0 || len(s.ExcludeSchemas) > 0
+}
+
+// pgDumpArgs returns the pg_dump schema flags of the selection.
+func (s DumpSelection) pgDumpArgs() []string {
+	var args []string
+	for _, schema := range s.IncludeSchemas {
+		args = append(args, "-n", schema)
+	}
+	for _, schema := range s.ExcludeSchemas {
+		args = append(args, "-N", schema)
+	}
+	return args
 }
 
 // NewContainerBackupExecutor creates a new ContainerBackupExecutor.
@@ -35,10 +97,12 @@ func NewContainerBackupExecutor(dockerBin, pgDumpBin, backupDir string, logger L
 	return &ContainerBackupExecutor{
 		DockerBin:       dockerBin,
 		PGDumpBin:       pgDumpBin,
+		PSQLBin:         "psql",
 		BackupDir:       backupDir,
 		BackupTimeout:   60 * time.Second,
 		Logger:          logger,
 		DockerInspector: NewDockerInspector(dockerBin, nil),
+		HookTimeout:     60 * time.Second,
 	}
 }
 
@@ -51,6 +115,7 @@ type BackupResult struct {
 	FailureCode  string
 	ErrorMessage string
 	DBConfig     *ContainerDBConfig // For metadata purposes
+	SnapshotID   string             // Set when a volume snapshot was taken
 }
 
 // ExecuteBackup performs a database backup from the specified container.
@@ -60,7 +125,74 @@ type BackupResult struct {
 // Database credentials are extracted from the running container's environment
 // variables (POSTGRES_HOST, POSTGRES_PORT, POSTGRES_DATABASE, POSTGRES_USERNAME,
 // POSTGRES_PASSWORD, POSTGRES_SSLMODE).
+//
+// If configured, PreBackupHook runs first and a failure aborts the backup
+// with PRE_BACKUP_HOOK_FAILED. PostBackupHook runs last regardless of outcome.
+// A dangling or unmounted BackupDir fails first, with BACKUP_MOUNT_MISSING.
 func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerName string, meta BackupMeta) *BackupResult {
+	// A missing mount fails before the hooks run: nothing has been paused yet
+	if err := CheckMount(e.BackupDir, e.RequireMount); err != nil {
+		code, message := mountFailure(err)
+		return &BackupResult{
+			Success:      false,
+			FailureCode:  code,
+			ErrorMessage: message,
+		}
+	}
+
+	event := HookEvent{
+		ContainerName: containerName,
+		FromVersion:   meta.FromVersion,
+		TargetVersion: meta.TargetVersion,
+		JobID:         meta.JobID,
+	}
+
+	var result *BackupResult
+	defer func() {
+		if e.PostBackupHook == "" {
+			return
+		}
+		post := event
+		post.Phase = "post"
+		post.Success = result.Success
+		post.BackupPath = result.Path
+		post.FailureCode = result.FailureCode
+		e.Logger.Printf("Running post-backup hook...")
+		if err := e.runHook(ctx, e.PostBackupHook, post); err != nil {
+			e.Logger.Printf("Warning: post-backup hook failed: %v", err)
+		}
+	}()
+
+	if e.PreBackupHook != "" {
+		pre := event
+		pre.Phase = "pre"
+		e.Logger.Printf("Running pre-backup hook...")
+		if err := e.runHook(ctx, e.PreBackupHook, pre); err != nil {
+			result = &BackupResult{
+				Success:      false,
+				FailureCode:  "PRE_BACKUP_HOOK_FAILED",
+				ErrorMessage: fmt.Sprintf("Pre-backup hook failed: %v", err),
+			}
+			return result
+		}
+	}
+
+	result = e.executeBackup(ctx, containerName, meta)
+	return result
+}
+
+// runHook runs a single hook bounded by HookTimeout.
+func (e *ContainerBackupExecutor) runHook(ctx context.Context, hook string, event HookEvent) error {
+	if e.HookTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.HookTimeout)
+		defer cancel()
+	}
+	return runHook(ctx, hook, event)
+}
+
+// executeBackup performs the backup itself, without hooks.
+func (e *ContainerBackupExecutor) executeBackup(ctx context.Context, containerName string, meta BackupMeta) *BackupResult {
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(ctx, e.BackupTimeout)
 	defer cancel()
@@ -68,6 +200,13 @@ func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerNa
 	// Step 1: Verify Docker daemon is running
 	e.Logger.Printf("Checking Docker daemon...")
 	if err := e.DockerInspector.CheckDaemon(ctx); err != nil {
+		if errors.Is(err, ErrDockerPermissionDenied) {
+			return &BackupResult{
+				Success:      false,
+				FailureCode:  "DOCKER_PERMISSION_DENIED",
+				ErrorMessage: fmt.Sprintf("Cannot access Docker: %v", err),
+			}
+		}
 		return &BackupResult{
 			Success:      false,
 			FailureCode:  "DOCKER_DAEMON_DOWN",
@@ -107,8 +246,47 @@ func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerNa
 	e.Logger.Printf("Database config: host=%s, port=%s, database=%s, user=%s",
 		dbConfig.Host, dbConfig.Port, dbConfig.Database, dbConfig.Username)
 
+	// Step 3b: Probe connectivity so a broken connection fails fast instead of deep inside pg_dump
+	e.Logger.Printf("Checking database connectivity...")
+	if err := e.probeDB(ctx, containerName, dbConfig); err != nil {
+		return &BackupResult{
+			Success:      false,
+			FailureCode:  "DB_UNREACHABLE",
+			ErrorMessage: fmt.Sprintf("Database %s@%s:%s is unreachable: %v", dbConfig.Database, dbConfig.Host, dbConfig.Port, err),
+			DBConfig:     dbConfig,
+		}
+	}
+
+	// Step 3c: Check the configured database and schemas exist before dumping them
+	dbConfig, err = e.applySelection(ctx, containerName, dbConfig)
+	if err != nil {
+		return &BackupResult{
+			Success:      false,
+			FailureCode:  "BACKUP_SELECTION_INVALID",
+			ErrorMessage: fmt.Sprintf("Invalid backup selection: %v", err),
+			DBConfig:     dbConfig,
+		}
+	}
+
+	dumpFormat := e.DumpFormat
+	if dumpFormat == "" {
+		dumpFormat = DumpFormatPlain
+	}
+	format, ok := dumpFormats[dumpFormat]
+	if !ok {
+		return &BackupResult{
+			Success:      false,
+			FailureCode:  "BACKUP_FAILED",
+			ErrorMessage: fmt.Sprintf("Unsupported dump format %q (must be custom, plain or directory)", dumpFormat),
+		}
+	}
+
 	// Step 4: Ensure backup directory exists
-	if err := os.MkdirAll(e.BackupDir, 0755); err != nil {
+	dumpDir := e.BackupDir
+	if e.PerDatabaseDirs {
+		dumpDir = filepath.Join(e.BackupDir, DatabaseDirName(dbConfig.Database))
+	}
+	if err := os.MkdirAll(dumpDir, 0755); err != nil {
 		return &BackupResult{
 			Success:      false,
 			FailureCode:  "BACKUP_FAILED",
@@ -120,25 +298,68 @@ func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerNa
 	timestamp := time.Now().UTC().Format("20060102-150405")
 	fromVer := sanitizeVersion(meta.FromVersion)
 	toVer := sanitizeVersion(meta.TargetVersion)
-	filename := fmt.Sprintf("payram-backup-%s-%s-to-%s.sql", timestamp, fromVer, toVer)
-	backupPath := filepath.Join(e.BackupDir, filename)
+	filename := fmt.Sprintf("payram-backup-%s-%s-to-%s%s", timestamp, fromVer, toVer, format.ext)
+	backupPath := filepath.Join(dumpDir, filename)
+
+	// Step 5a: Take a volume snapshot if configured; with the snapshot
+	// strategy alone it is the whole backup.
+	var snapshotID string
+	if strategy := e.Snapshot.effectiveStrategy(); strategy != StrategyDump {
+		snapshotPath, snapshot, err := e.Snapshot.createSnapshot(ctx, e.BackupDir, timestamp, meta, e.Logger)
+		if err != nil {
+			return &BackupResult{
+				Success:      false,
+				FailureCode:  "SNAPSHOT_FAILED",
+				ErrorMessage: fmt.Sprintf("Volume snapshot failed: %v", err),
+				DBConfig:     dbConfig,
+			}
+		}
+		if strategy == StrategySnapshot {
+			result := &BackupResult{
+				Success:    true,
+				Path:       snapshotPath,
+				Filename:   filepath.Base(snapshotPath),
+				DBConfig:   dbConfig,
+				SnapshotID: snapshot.SnapshotID,
+			}
+			if fileInfo, err := os.Stat(snapshotPath); err == nil {
+				result.Size = fileInfo.Size()
+			}
+			if err := updateLatestLink(snapshotPath); err != nil {
+				e.Logger.Printf("Warning: failed to update the latest backup link: %v", err)
+			}
+			return result
+		}
+		snapshotID = snapshot.SnapshotID
+	}
 
 	e.Logger.Printf("Creating backup: %s", backupPath)
 
+	// Step 5b: Record the database size for the freshness check (best effort)
+	// A schema-filtered dump is legitimately much smaller than the database.
+	var dbSize int64
+	if e.Selection.filtersSchemas() {
+		e.Logger.Printf("Backing up selected schemas only, skipping backup size check")
+	} else if dbSize, err = e.databaseSize(ctx, containerName, dbConfig); err != nil {
+		e.Logger.Printf("Warning: could not query database size, skipping backup size check: %v", err)
+		dbSize = 0
+	}
+
 	// Step 6: Execute backup based on database location
+	dumpStart := time.Now()
 	var execErr error
 	if dbConfig.IsLocalDB() {
 		e.Logger.Printf("Database is local - executing pg_dump inside container")
-		execErr = e.executeContainerBackup(ctx, containerName, dbConfig, backupPath)
+		execErr = e.executeContainerBackup(ctx, containerName, dbConfig, backupPath, dumpFormat)
 	} else {
 		e.Logger.Printf("Database is external - executing pg_dump on host")
-		execErr = e.executeHostBackup(ctx, dbConfig, backupPath)
+		execErr = e.executeHostBackup(ctx, dbConfig, backupPath, dumpFormat)
 	}
 
 	// Check for context timeout
 	if ctx.Err() == context.DeadlineExceeded {
 		// Clean up partial backup file
-		os.Remove(backupPath)
+		os.RemoveAll(backupPath)
 		return &BackupResult{
 			Success:      false,
 			FailureCode:  "BACKUP_TIMEOUT",
@@ -148,7 +369,7 @@ func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerNa
 
 	if execErr != nil {
 		// Clean up partial backup file
-		os.Remove(backupPath)
+		os.RemoveAll(backupPath)
 		return &BackupResult{
 			Success:      false,
 			FailureCode:  "BACKUP_FAILED",
@@ -156,8 +377,13 @@ func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerNa
 		}
 	}
 
-	// Step 7: Validate backup file
-	fileInfo, err := os.Stat(backupPath)
+	// Step 7: Validate backup file. A directory dump is checked through its
+	// table of contents, which pg_dump writes last.
+	checkedPath := backupPath
+	if format.format == dbexec.FormatDirectory {
+		checkedPath = filepath.Join(backupPath, "toc.dat")
+	}
+	fileInfo, err := os.Stat(checkedPath)
 	if err != nil {
 		return &BackupResult{
 			Success:      false,
@@ -167,7 +393,7 @@ func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerNa
 	}
 
 	if fileInfo.Size() == 0 {
-		os.Remove(backupPath)
+		os.RemoveAll(backupPath)
 		return &BackupResult{
 			Success:      false,
 			FailureCode:  "BACKUP_FAILED",
@@ -175,25 +401,231 @@ func (e *ContainerBackupExecutor) ExecuteBackup(ctx context.Context, containerNa
 		}
 	}
 
-	e.Logger.Printf("Backup completed successfully: %s (%.2f MB)", filename, float64(fileInfo.Size())/(1024*1024))
+	size, err := backupSize(backupPath)
+	if err != nil {
+		return &BackupResult{
+			Success:      false,
+			FailureCode:  "BACKUP_FAILED",
+			ErrorMessage: fmt.Sprintf("Failed to size backup: %v", err),
+		}
+	}
+	if err := checkBackupFreshness(fileInfo, size, dumpStart, dbSize); err != nil {
+		os.RemoveAll(backupPath)
+		return &BackupResult{
+			Success:      false,
+			FailureCode:  "BACKUP_SUSPICIOUSLY_SMALL",
+			ErrorMessage: fmt.Sprintf("Backup file failed freshness check: %v", err),
+			DBConfig:     dbConfig,
+		}
+	}
+
+	if e.VerifyAfterCreate {
+		if err := VerifyRestorable(ctx, backupPath, e.archiveLister(containerName, dbConfig)); err != nil {
+			os.RemoveAll(backupPath)
+			return &BackupResult{
+				Success:      false,
+				FailureCode:  "BACKUP_VERIFY_FAILED",
+				ErrorMessage: fmt.Sprintf("Backup failed verification: %v", err),
+				DBConfig:     dbConfig,
+			}
+		}
+		e.Logger.Printf("Backup verified as restorable: %s", filename)
+	}
+
+	// Record the dumped database, which BACKUP_DATABASE may have changed, so
+	// a restore writes to it rather than to the container's database
+	if err := recordDatabase(backupPath, dbConfig.Database); err != nil {
+		os.RemoveAll(backupPath)
+		return &BackupResult{
+			Success:      false,
+			FailureCode:  "BACKUP_FAILED",
+			ErrorMessage: fmt.Sprintf("Failed to record the backup's database: %v", err),
+			DBConfig:     dbConfig,
+		}
+	}
+
+	e.Logger.Printf("Backup completed successfully: %s (%.2f MB)", filename, float64(size)/(1024*1024))
+	if err := updateLatestLink(backupPath); err != nil {
+		e.Logger.Printf("Warning: failed to update the latest backup link: %v", err)
+	}
 
 	return &BackupResult{
-		Success:  true,
-		Path:     backupPath,
-		Filename: filename,
-		Size:     fileInfo.Size(),
-		DBConfig: dbConfig,
+		Success:    true,
+		Path:       backupPath,
+		Filename:   filename,
+		Size:       size,
+		DBConfig:   dbConfig,
+		SnapshotID: snapshotID,
+	}
+}
+
+// applySelection validates the configured dump selection against the
+// database server and returns the connection config to dump with: dbConfig
+// itself, or a copy pointing at the selected database.
+func (e *ContainerBackupExecutor) applySelection(ctx context.Context, containerName string, dbConfig *ContainerDBConfig) (*ContainerDBConfig, error) {
+	sel := e.Selection
+	if sel.Database != "" && sel.Database != dbConfig.Database {
+		output, err := e.queryDB(ctx, containerName, dbConfig, "SELECT datname FROM pg_database WHERE NOT datistemplate")
+		if err != nil {
+			return dbConfig, fmt.Errorf("failed to list databases: %w", err)
+		}
+		if missing := missingNames(output, []string{sel.Database}); len(missing) > 0 {
+			return dbConfig, fmt.Errorf("database %s does not exist", sel.Database)
+		}
+		selected := *dbConfig
+		selected.Database = sel.Database
+		dbConfig = &selected
+		e.Logger.Printf("Backing up database %s (BACKUP_DATABASE)", dbConfig.Database)
+	}
+
+	if !sel.filtersSchemas() {
+		return dbConfig, nil
+	}
+	output, err := e.queryDB(ctx, containerName, dbConfig, "SELECT nspname FROM pg_namespace")
+	if err != nil {
+		return dbConfig, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	if missing := missingNames(output, append(append([]string{}, sel.IncludeSchemas...), sel.ExcludeSchemas...)); len(missing) > 0 {
+		return dbConfig, fmt.Errorf("schema(s) %s do not exist in database %s", strings.Join(missing, ", "), dbConfig.Database)
+	}
+	e.Logger.Printf("Schema selection: include=%v exclude=%v", sel.IncludeSchemas, sel.ExcludeSchemas)
+	return dbConfig, nil
+}
+
+// missingNames returns the names absent from output, which lists one name
+// per line as printed by psql -tA.
+func missingNames(output string, names []string) []string {
+	present := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// shellQuote quotes s for use as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dbProbeTimeout bounds the connectivity probe; a reachable database answers well within it.
+const dbProbeTimeout = 10 * time.Second
+
+// probeDB runs `SELECT 1` with the resolved credentials, inside the container
+// for local databases and from the host for external ones, mirroring where
+// pg_dump will run.
+func (e *ContainerBackupExecutor) probeDB(ctx context.Context, containerName string, dbConfig *ContainerDBConfig) error {
+	_, err := e.queryDB(ctx, containerName, dbConfig, "SELECT 1")
+	return err
+}
+
+// databaseSize returns pg_database_size of the configured database in bytes.
+func (e *ContainerBackupExecutor) databaseSize(ctx context.Context, containerName string, dbConfig *ContainerDBConfig) (int64, error) {
+	output, err := e.queryDB(ctx, containerName, dbConfig, "SELECT pg_database_size(current_database())")
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(output, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected database size output %q", output)
+	}
+	return size, nil
+}
+
+// queryDB runs a single-value query with psql where pg_dump will run and
+// returns the trimmed output.
+func (e *ContainerBackupExecutor) queryDB(ctx context.Context, containerName string, dbConfig *ContainerDBConfig, query string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbProbeTimeout)
+	defer cancel()
+
+	psqlArgs := []string{
+		"-h", dbConfig.Host,
+		"-p", dbConfig.Port,
+		"-U", dbConfig.Username,
+		"-d", dbConfig.Database,
+		"-tAc", query,
+	}
+
+	var output []byte
+	var err error
+	if dbConfig.IsLocalDB() {
+		args := []string{"exec", "-e", "PGCONNECT_TIMEOUT=5"}
+		if dbConfig.Password != "" {
+			args = append(args, "-e", fmt.Sprintf("PGPASSWORD=%s", dbConfig.Password))
+		}
+		args = append(args, containerName, "psql")
+		args = append(args, psqlArgs...)
+		output, err = e.DockerInspector.Executor.Execute(ctx, e.DockerBin, args, nil)
+	} else {
+		env := append(os.Environ(), "PGCONNECT_TIMEOUT=5")
+		if dbConfig.Password != "" {
+			env = append(env, fmt.Sprintf("PGPASSWORD=%s", dbConfig.Password))
+		}
+		if dbConfig.SSLMode != "" {
+			env = append(env, fmt.Sprintf("PGSSLMODE=%s", dbConfig.SSLMode))
+		}
+		output, err = e.DockerInspector.Executor.Execute(ctx, e.PSQLBin, psqlArgs, env)
 	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("no response within %v", dbProbeTimeout)
+		}
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Thresholds for the backup freshness check. A plain-text dump omits index
+// and bloat pages, so it is routinely much smaller than pg_database_size, but
+// never by two orders of magnitude once the database holds real data.
+const (
+	minDBSizeForRatioCheck = 64 * 1024 * 1024
+	minBackupToDBRatio     = 0.01
+	backupMtimeSlack       = 2 * time.Second
+)
+
+// checkBackupFreshness guards against registering a file pg_dump did not
+// actually write: the file must have been modified after the dump started,
+// and must not be implausibly small for the database it claims to hold.
+// size is the backup's total size, info the file pg_dump wrote last.
+// dbSize <= 0 means the size is unknown and skips the size comparison.
+func checkBackupFreshness(info os.FileInfo, size int64, dumpStart time.Time, dbSize int64) error {
+	if info.ModTime().Before(dumpStart.Add(-backupMtimeSlack)) {
+		return fmt.Errorf("backup file was last modified at %s, before the dump started at %s",
+			info.ModTime().UTC().Format(time.RFC3339), dumpStart.UTC().Format(time.RFC3339))
+	}
+	if dbSize >= minDBSizeForRatioCheck && float64(size) < float64(dbSize)*minBackupToDBRatio {
+		return fmt.Errorf("backup file is %d bytes but the database is %d bytes", size, dbSize)
+	}
+	return nil
 }
 
 // executeContainerBackup runs pg_dump inside the container and streams output to host.
-func (e *ContainerBackupExecutor) executeContainerBackup(ctx context.Context, containerName string, dbConfig *ContainerDBConfig, backupPath string) error {
+// A directory dump cannot be streamed: it is written to the container's /tmp,
+// copied out with docker cp, and the container copy removed.
+func (e *ContainerBackupExecutor) executeContainerBackup(ctx context.Context, containerName string, dbConfig *ContainerDBConfig, backupPath, dumpFormat string) error {
 	// Build the pg_dump command to run inside the container
-	// We use plain text format and stream to stdout, then capture to file on host
+	// The dump is streamed to stdout, then captured to file on host
 	pgDumpCmd := fmt.Sprintf(
-		"pg_dump -h %s -p %s -U %s -d %s --no-owner --no-acl",
-		dbConfig.Host, dbConfig.Port, dbConfig.Username, dbConfig.Database,
+		"pg_dump %s -h %s -p %s -U %s -d %s --no-owner --no-acl",
+		dbexec.PGDumpFormatFlag(dumpFormats[dumpFormat].format), shellQuote(dbConfig.Host), shellQuote(dbConfig.Port), shellQuote(dbConfig.Username), shellQuote(dbConfig.Database),
 	)
+	selArgs := e.Selection.pgDumpArgs()
+	for i := 0; i < len(selArgs); i += 2 {
+		pgDumpCmd += " " + selArgs[i] + " " + shellQuote(selArgs[i+1])
+	}
+	tmpDir := "/tmp/" + filepath.Base(backupPath)
+	if dumpFormat == DumpFormatDirectory {
+		pgDumpCmd += " -f " + shellQuote(tmpDir)
+	}
 
 	// Build docker exec command
 	args := []string{
@@ -211,6 +643,20 @@ func (e *ContainerBackupExecutor) executeContainerBackup(ctx context.Context, co
 
 	cmd := exec.CommandContext(ctx, e.DockerBin, args...)
 
+	if dumpFormat == DumpFormatDirectory {
+		defer func() {
+			_ = exec.Command(e.DockerBin, "exec", containerName, "rm", "-rf", tmpDir).Run()
+		}()
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, string(output))
+		}
+		if output, err := exec.CommandContext(ctx, e.DockerBin, "cp", containerName+":"+tmpDir, backupPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to copy the dump out of the container: %w: %s", err, string(output))
+		}
+		return nil
+	}
+
 	// Create backup file
 	outFile, err := os.Create(backupPath)
 	if err != nil {
@@ -249,8 +695,29 @@ func (e *ContainerBackupExecutor) executeContainerBackup(ctx context.Context, co
 	return nil
 }
 
+// archiveLister lists an archive with pg_restore from where the dump was
+// taken: inside the container for a local database, on the host (next to
+// PGDumpBin) for an external one.
+func (e *ContainerBackupExecutor) archiveLister(containerName string, dbConfig *ContainerDBConfig) ArchiveLister {
+	executor := &executorWrapper{executor: &RealExecutor{}}
+	if e.DockerInspector != nil && e.DockerInspector.Executor != nil {
+		executor.executor = e.DockerInspector.Executor
+	}
+	return func(ctx context.Context, path, format string) (string, error) {
+		if dbConfig.IsLocalDB() {
+			db := dbexec.DBContext{Mode: dbexec.DBModeInContainer, ContainerName: containerName}
+			return dbexec.NewDockerPGExecutor(executor, e.Logger).ListArchive(ctx, db, path, format)
+		}
+		hostExec := dbexec.NewHostPGExecutor(executor, e.Logger)
+		if e.PGDumpBin != "" {
+			hostExec.PGRestoreBin = filepath.Join(filepath.Dir(e.PGDumpBin), "pg_restore")
+		}
+		return hostExec.ListArchive(ctx, dbexec.DBContext{}, path, format)
+	}
+}
+
 // executeHostBackup runs pg_dump on the host with credentials from the container.
-func (e *ContainerBackupExecutor) executeHostBackup(ctx context.Context, dbConfig *ContainerDBConfig, backupPath string) error {
+func (e *ContainerBackupExecutor) executeHostBackup(ctx context.Context, dbConfig *ContainerDBConfig, backupPath, dumpFormat string) error {
 	// Convert port to int for validation
 	port, err := strconv.Atoi(dbConfig.Port)
 	if err != nil {
@@ -259,6 +726,7 @@ func (e *ContainerBackupExecutor) executeHostBackup(ctx context.Context, dbConfi
 
 	// Build pg_dump arguments
 	args := []string{
+		dbexec.PGDumpFormatFlag(dumpFormats[dumpFormat].format),
 		"-h", dbConfig.Host,
 		"-p", strconv.Itoa(port),
 		"-U", dbConfig.Username,
@@ -267,6 +735,7 @@ func (e *ContainerBackupExecutor) executeHostBackup(ctx context.Context, dbConfi
 		"--no-acl",
 		"-f", backupPath,
 	}
+	args = append(args, e.Selection.pgDumpArgs()...)
 
 	e.Logger.Printf("Executing: %s %s", e.PGDumpBin, strings.Join(args, " "))
 
@@ -0,0 +1,354 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
+)
+
+// newProbeTestExecutor returns an executor whose docker calls are served by a
+// mock: daemon and container checks succeed, DB credentials come from dbEnv,
+// and psql calls are answered by probe.
+func newProbeTestExecutor(t *testing.T, dbEnv string, probe func(name string, args []string) ([]byte, error)) (*ContainerBackupExecutor, *mockExecutor) {
+	t.Helper()
+	mock := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			if name == "docker" && len(args) > 0 && args[0] == "inspect" && len(args) > 2 {
+				return []byte(dbEnv), nil
+			}
+			if name == "psql" || (len(args) > 0 && args[0] == "exec") {
+				return probe(name, args)
+			}
+			return nil, nil
+		},
+	}
+	exec := NewContainerBackupExecutor("docker", "pg_dump", filepath.Join(t.TempDir(), "backups"), &mockLogger{})
+	exec.DockerInspector = NewDockerInspector("docker", mock)
+	return exec, mock
+}
+
+const localDBEnv = `["POSTGRES_HOST=localhost","POSTGRES_PORT=5432","POSTGRES_DATABASE=payram","POSTGRES_USERNAME=payram","POSTGRES_PASSWORD=secret"]`
+
+func TestExecuteBackup_DBUnreachableFailsEarly(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, func(name string, args []string) ([]byte, error) {
+		return []byte("psql: error: connection to server at \"localhost\" (127.0.0.1), port 5432 failed: Connection refused"), errors.New("exit status 2")
+	})
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+
+	if result.Success {
+		t.Fatal("expected backup to fail when the database is unreachable")
+	}
+	if result.FailureCode != "DB_UNREACHABLE" {
+		t.Errorf("expected failure code DB_UNREACHABLE, got %s", result.FailureCode)
+	}
+	if !strings.Contains(result.ErrorMessage, "Connection refused") {
+		t.Errorf("expected the specific connection error in the message, got %q", result.ErrorMessage)
+	}
+	if _, err := os.Stat(exec.BackupDir); !os.IsNotExist(err) {
+		t.Error("expected backup not to start (backup directory should not be created)")
+	}
+}
+
+func TestProbeDB_LocalRunsInsideContainer(t *testing.T) {
+	var probeArgs []string
+	exec, _ := newProbeTestExecutor(t, localDBEnv, func(name string, args []string) ([]byte, error) {
+		probeArgs = args
+		return []byte("1"), nil
+	})
+
+	dbConfig := &ContainerDBConfig{Host: "localhost", Port: "5432", Database: "payram", Username: "payram", Password: "secret"}
+	if err := exec.probeDB(context.Background(), "payram", dbConfig); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+
+	joined := strings.Join(probeArgs, " ")
+	for _, want := range []string{"exec", "PGPASSWORD=secret", "payram psql", "-U payram", "SELECT 1"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected probe args to contain %q, got %q", want, joined)
+		}
+	}
+}
+
+func TestProbeDB_ExternalRunsOnHost(t *testing.T) {
+	var probeName string
+	exec, mock := newProbeTestExecutor(t, localDBEnv, func(name string, args []string) ([]byte, error) {
+		probeName = name
+		return nil, errors.New("exit status 2")
+	})
+
+	dbConfig := &ContainerDBConfig{Host: "db.example.com", Port: "5432", Database: "payram", Username: "payram", Password: "secret", SSLMode: "require"}
+	err := exec.probeDB(context.Background(), "payram", dbConfig)
+	if err == nil {
+		t.Fatal("expected probe error")
+	}
+	if probeName != "psql" {
+		t.Errorf("expected host psql for external DB, got %s", probeName)
+	}
+	probeEnv := mock.calls[len(mock.calls)-1].Env
+	joined := strings.Join(probeEnv, " ")
+	if !strings.Contains(joined, "PGPASSWORD=secret") || !strings.Contains(joined, "PGSSLMODE=require") {
+		t.Errorf("expected password and sslmode in probe env, got %q", joined)
+	}
+}
+
+func TestExecuteBackup_TinyDumpForLargeDBFails(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, func(name string, args []string) ([]byte, error) {
+		if strings.Contains(strings.Join(args, " "), "pg_database_size") {
+			return []byte("2147483648\n"), nil
+		}
+		return []byte("1"), nil
+	})
+
+	// pg_dump "succeeds" but writes only a header
+	stub := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(stub, []byte("#!/bin/sh\necho '-- PostgreSQL database dump'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	exec.DockerBin = stub
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+
+	if result.Success {
+		t.Fatal("expected backup to fail for a tiny dump of a large database")
+	}
+	if result.FailureCode != "BACKUP_SUSPICIOUSLY_SMALL" {
+		t.Errorf("expected failure code BACKUP_SUSPICIOUSLY_SMALL, got %s (%s)", result.FailureCode, result.ErrorMessage)
+	}
+	entries, _ := os.ReadDir(exec.BackupDir)
+	if len(entries) != 0 {
+		t.Errorf("expected the suspicious backup file to be removed, found %d file(s)", len(entries))
+	}
+}
+
+// selectionProbe answers the probe, size and selection queries of a server
+// holding the payram and analytics databases and the public and audit schemas.
+func selectionProbe(name string, args []string) ([]byte, error) {
+	joined := strings.Join(args, " ")
+	switch {
+	case strings.Contains(joined, "pg_database WHERE"):
+		return []byte("postgres\npayram\nanalytics\n"), nil
+	case strings.Contains(joined, "pg_namespace"):
+		return []byte("pg_catalog\ninformation_schema\npublic\naudit\n"), nil
+	case strings.Contains(joined, "pg_database_size"):
+		return []byte("1024\n"), nil
+	}
+	return []byte("1"), nil
+}
+
+// recordingDockerStub returns a docker stub that records its arguments in the
+// returned file and prints a small dump.
+func recordingDockerStub(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	stub := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\necho '-- PostgreSQL database dump'\n"
+	if err := os.WriteFile(stub, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return stub, argsFile
+}
+
+func TestExecuteBackup_PassesSchemaSelectionToPgDump(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, selectionProbe)
+	stub, argsFile := recordingDockerStub(t)
+	exec.DockerBin = stub
+	exec.Selection = DumpSelection{Database: "analytics", IncludeSchemas: []string{"public"}, ExcludeSchemas: []string{"audit"}}
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+	if !result.Success {
+		t.Fatalf("expected backup to succeed, got %s (%s)", result.FailureCode, result.ErrorMessage)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("pg_dump was not run: %v", err)
+	}
+	for _, want := range []string{"-d 'analytics'", "-n 'public'", "-N 'audit'"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected pg_dump args to contain %q, got %q", want, data)
+		}
+	}
+	if result.DBConfig.Database != "analytics" {
+		t.Errorf("expected the result to record database analytics, got %s", result.DBConfig.Database)
+	}
+	if database, ok := recordedDatabase(result.Path); !ok || database != "analytics" {
+		t.Errorf("expected the backup to record database analytics, got %q", database)
+	}
+}
+
+func TestExecuteBackup_DefaultSelectionDumpsWholeDatabase(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, selectionProbe)
+	stub, argsFile := recordingDockerStub(t)
+	exec.DockerBin = stub
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+	if !result.Success {
+		t.Fatalf("expected backup to succeed, got %s (%s)", result.FailureCode, result.ErrorMessage)
+	}
+
+	data, _ := os.ReadFile(argsFile)
+	args := string(data)
+	if !strings.Contains(args, "-d 'payram'") || strings.Contains(args, " -n ") || strings.Contains(args, " -N ") {
+		t.Errorf("expected a full dump of payram, got %q", args)
+	}
+}
+
+func TestExecuteBackup_DumpFormatSelectsFlagAndExtension(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, selectionProbe)
+	stub, argsFile := recordingDockerStub(t)
+	exec.DockerBin = stub
+	exec.DumpFormat = DumpFormatCustom
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+	if !result.Success {
+		t.Fatalf("expected backup to succeed, got %s (%s)", result.FailureCode, result.ErrorMessage)
+	}
+	if !strings.HasSuffix(result.Filename, ".dump") {
+		t.Errorf("expected a .dump backup, got %s", result.Filename)
+	}
+	data, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(data), "pg_dump -Fc ") {
+		t.Errorf("expected pg_dump -Fc, got %q", data)
+	}
+}
+
+func TestExecuteBackup_InvalidSelectionFailsBeforeDump(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection DumpSelection
+		want      string
+	}{
+		{"unknown database", DumpSelection{Database: "missing"}, "database missing does not exist"},
+		{"unknown included schema", DumpSelection{IncludeSchemas: []string{"public", "billing"}}, "billing"},
+		{"unknown excluded schema", DumpSelection{ExcludeSchemas: []string{"archive"}}, "archive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, _ := newProbeTestExecutor(t, localDBEnv, selectionProbe)
+			stub, argsFile := recordingDockerStub(t)
+			exec.DockerBin = stub
+			exec.Selection = tt.selection
+
+			result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+
+			if result.Success || result.FailureCode != "BACKUP_SELECTION_INVALID" {
+				t.Fatalf("expected BACKUP_SELECTION_INVALID, got success=%v code=%s", result.Success, result.FailureCode)
+			}
+			if !strings.Contains(result.ErrorMessage, tt.want) {
+				t.Errorf("expected message to mention %q, got %q", tt.want, result.ErrorMessage)
+			}
+			if _, err := os.Stat(argsFile); !os.IsNotExist(err) {
+				t.Error("expected pg_dump not to run")
+			}
+		})
+	}
+}
+
+func TestDumpSelection_PgDumpArgs(t *testing.T) {
+	sel := DumpSelection{IncludeSchemas: []string{"public", "app"}, ExcludeSchemas: []string{"audit"}}
+	got := strings.Join(sel.pgDumpArgs(), " ")
+	if got != "-n public -n app -N audit" {
+		t.Errorf("unexpected pg_dump args %q", got)
+	}
+	if args := (DumpSelection{Database: "payram"}).pgDumpArgs(); len(args) != 0 {
+		t.Errorf("expected no schema args without schemas, got %v", args)
+	}
+}
+
+type fakeFileInfo struct {
+	os.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (f fakeFileInfo) Size() int64        { return f.size }
+func (f fakeFileInfo) ModTime() time.Time { return f.modTime }
+
+func TestCheckBackupFreshness(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	const gb = 1024 * 1024 * 1024
+
+	tests := []struct {
+		name    string
+		info    fakeFileInfo
+		dbSize  int64
+		wantErr bool
+	}{
+		{"fresh and proportionate", fakeFileInfo{size: 300 * 1024 * 1024, modTime: start.Add(time.Minute)}, gb, false},
+		{"tiny dump of large database", fakeFileInfo{size: 4096, modTime: start.Add(time.Minute)}, gb, true},
+		{"small database skips ratio", fakeFileInfo{size: 4096, modTime: start.Add(time.Second)}, 8 * 1024 * 1024, false},
+		{"unknown database size skips ratio", fakeFileInfo{size: 4096, modTime: start.Add(time.Second)}, 0, false},
+		{"stale file predates dump", fakeFileInfo{size: 300 * 1024 * 1024, modTime: start.Add(-time.Hour)}, gb, true},
+		{"mtime within slack", fakeFileInfo{size: 4096, modTime: start.Add(-time.Second)}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBackupFreshness(tt.info, tt.info.Size(), start, tt.dbSize)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkBackupFreshness() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+const dockerSocketPermissionDenied = "permission denied while trying to connect to the Docker daemon socket at unix:///var/run/docker.sock: Get \"http://%2Fvar%2Frun%2Fdocker.sock/v1.24/info\": dial unix /var/run/docker.sock: connect: permission denied"
+
+func TestCheckDaemon_PermissionDenied(t *testing.T) {
+	mock := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return []byte(dockerSocketPermissionDenied), errors.New("exit status 1")
+		},
+	}
+
+	err := NewDockerInspector("docker", mock).CheckDaemon(context.Background())
+	if !errors.Is(err, ErrDockerPermissionDenied) {
+		t.Fatalf("expected ErrDockerPermissionDenied, got %v", err)
+	}
+	if code := DaemonFailureCode(err); code != "DOCKER_PERMISSION_DENIED" {
+		t.Errorf("expected DOCKER_PERMISSION_DENIED, got %s", code)
+	}
+}
+
+func TestCheckDaemon_DaemonDown(t *testing.T) {
+	mock := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return []byte("Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?"), errors.New("exit status 1")
+		},
+	}
+
+	err := NewDockerInspector("docker", mock).CheckDaemon(context.Background())
+	if !errors.Is(err, dockerexec.ErrDaemonDown) || errors.Is(err, ErrDockerPermissionDenied) {
+		t.Fatalf("expected a daemon-down error, got %v", err)
+	}
+	if code := DaemonFailureCode(err); code != "DOCKER_DAEMON_DOWN" {
+		t.Errorf("expected DOCKER_DAEMON_DOWN, got %s", code)
+	}
+}
+
+func TestExecuteBackup_DockerPermissionDenied(t *testing.T) {
+	mock := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return []byte(dockerSocketPermissionDenied), errors.New("exit status 1")
+		},
+	}
+	exec := NewContainerBackupExecutor("docker", "pg_dump", t.TempDir(), &mockLogger{})
+	exec.DockerInspector = NewDockerInspector("docker", mock)
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+
+	if result.Success {
+		t.Fatal("expected backup to fail without docker socket access")
+	}
+	if result.FailureCode != "DOCKER_PERMISSION_DENIED" {
+		t.Errorf("expected failure code DOCKER_PERMISSION_DENIED, got %s", result.FailureCode)
+	}
+}
@@ -4,10 +4,13 @@ package backup
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
 )
 
 const (
@@ -77,16 +80,37 @@ func NewDockerInspector(dockerBin string, executor CommandExecutor) *DockerInspe
 	}
 }
 
+// ErrDockerPermissionDenied is returned by CheckDaemon when the daemon is
+// reachable but the current user may not use the Docker socket.
+var ErrDockerPermissionDenied = errors.New("permission denied on docker socket")
+
 // CheckDaemon verifies that the Docker daemon is running.
-// Returns nil if running, error otherwise.
+// Returns nil if running, error otherwise. A socket permission problem is
+// reported as ErrDockerPermissionDenied; any other failure matches
+// dockerexec.ErrDaemonDown.
 func (d *DockerInspector) CheckDaemon(ctx context.Context) error {
 	output, err := d.Executor.Execute(ctx, d.DockerBin, []string{"info"}, nil)
 	if err != nil {
-		return fmt.Errorf("docker daemon not running: %w: %s", err, string(output))
+		cmdErr := dockerexec.NewCommandError("info", output, err)
+		if errors.Is(cmdErr, dockerexec.ErrPermissionDenied) || errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w: %s", ErrDockerPermissionDenied, cmdErr.Output)
+		}
+		if errors.Is(cmdErr, dockerexec.ErrDaemonDown) {
+			return cmdErr
+		}
+		return fmt.Errorf("%w: %w", dockerexec.ErrDaemonDown, cmdErr)
 	}
 	return nil
 }
 
+// DaemonFailureCode maps a CheckDaemon error to its failure code.
+func DaemonFailureCode(err error) string {
+	if errors.Is(err, ErrDockerPermissionDenied) {
+		return "DOCKER_PERMISSION_DENIED"
+	}
+	return "DOCKER_DAEMON_DOWN"
+}
+
 // ContainerExists checks if a container exists (running or stopped).
 func (d *DockerInspector) ContainerExists(ctx context.Context, container string) (bool, error) {
 	_, err := d.Executor.Execute(ctx, d.DockerBin, []string{"inspect", container}, nil)
@@ -103,12 +127,10 @@ func (d *DockerInspector) GetContainerEnv(ctx context.Context, container string)
 	output, err := d.Executor.Execute(ctx, d.DockerBin,
 		[]string{"inspect", "--format", "{{json .Config.Env}}", container}, nil)
 	if err != nil {
-		outputStr := string(output)
-		if strings.Contains(outputStr, "No such object") ||
-			strings.Contains(outputStr, "No such container") {
+		if errors.Is(dockerexec.NewCommandError("inspect", output, err), dockerexec.ErrContainerNotFound) {
 			return nil, fmt.Errorf("container not found: %s", container)
 		}
-		return nil, fmt.Errorf("failed to inspect container: %w: %s", err, outputStr)
+		return nil, fmt.Errorf("failed to inspect container: %w: %s", err, output)
 	}
 
 	// Parse JSON array of "KEY=VALUE" strings
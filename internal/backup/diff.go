@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/dbexec"
+)
+
+// DiffSide is the metadata of one backup in a BackupDiff.
+type DiffSide struct {
+	File        string `json:"file"`
+	Format      string `json:"format"`
+	FromVersion string `json:"fromVersion"` // Parsed from the filename or "unknown"
+	ToVersion   string `json:"toVersion"`   // Parsed from the filename or "unknown"
+	CreatedAt   string `json:"createdAt"`   // RFC3339 if parseable, else empty
+	SizeBytes   int64  `json:"sizeBytes"`
+}
+
+// BackupDiff compares backup A with backup B. Added objects are only in B,
+// removed objects only in A.
+type BackupDiff struct {
+	A              DiffSide `json:"a"`
+	B              DiffSide `json:"b"`
+	SizeDeltaBytes int64    `json:"sizeDeltaBytes"` // B's size minus A's
+
+	// ObjectsCompared is false when either backup does not list its objects
+	// (plain SQL and snapshot backups); ObjectsSkipped then says why.
+	ObjectsCompared bool     `json:"objectsCompared"`
+	ObjectsSkipped  string   `json:"objectsSkipped,omitempty"`
+	Added           []string `json:"added"`
+	Removed         []string `json:"removed"`
+}
+
+// DiffBackups compares the metadata of the backups at pathA and pathB and,
+// when both are custom or directory format, the objects their pg_restore
+// --list tables of contents hold.
+func (m *Manager) DiffBackups(ctx context.Context, pathA, pathB string) (*BackupDiff, error) {
+	diff := &BackupDiff{Added: []string{}, Removed: []string{}}
+	for _, side := range []struct {
+		path string
+		out  *DiffSide
+	}{{pathA, &diff.A}, {pathB, &diff.B}} {
+		if err := m.VerifyBackupFile(side.path); err != nil {
+			return nil, err
+		}
+		size, err := backupSize(side.path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot stat backup file: %w", err)
+		}
+		metadata := parseBackupFilename(filepath.Base(strings.TrimSuffix(side.path, string(filepath.Separator))))
+		*side.out = DiffSide{
+			File:        side.path,
+			Format:      detectBackupFormat(side.path),
+			FromVersion: metadata.FromVersion,
+			ToVersion:   metadata.ToVersion,
+			CreatedAt:   metadata.CreatedAt,
+			SizeBytes:   size,
+		}
+	}
+	diff.SizeDeltaBytes = diff.B.SizeBytes - diff.A.SizeBytes
+
+	for _, side := range []DiffSide{diff.A, diff.B} {
+		if side.Format != "dump" && side.Format != dbexec.FormatDirectory {
+			diff.ObjectsSkipped = fmt.Sprintf("%s is a %s backup; only custom and directory format backups list their objects", side.File, side.Format)
+			return diff, nil
+		}
+	}
+
+	listA, err := m.ListArchive(ctx, diff.A.File, diff.A.Format)
+	if err != nil {
+		return nil, err
+	}
+	listB, err := m.ListArchive(ctx, diff.B.File, diff.B.Format)
+	if err != nil {
+		return nil, err
+	}
+	diff.ObjectsCompared = true
+	diff.Added, diff.Removed = DiffObjects(ParseArchiveObjects(listA), ParseArchiveObjects(listB))
+	return diff, nil
+}
+
+// ListArchive returns the pg_restore --list output of a custom or directory
+// format backup, using pg_restore in the Payram container when the database
+// runs there and the host's otherwise, as RestoreBackup does. No database
+// connection is needed.
+func (m *Manager) ListArchive(ctx context.Context, path, format string) (string, error) {
+	executor := &executorWrapper{executor: m.Executor}
+	dbCtx, err := dbexec.DiscoverDBContext(ctx, executor, dbexec.DiscoverOpts{
+		ContainerName: m.Config.TargetContainerName,
+		ImagePattern:  m.Config.ImagePattern,
+		BackupDir:     m.Config.Dir,
+		Logger:        m.Logger,
+	})
+	if err == nil && dbCtx.Mode == dbexec.DBModeInContainer {
+		return dbexec.NewDockerPGExecutor(executor, m.Logger).ListArchive(ctx, dbCtx, path, format)
+	}
+
+	hostExec := dbexec.NewHostPGExecutor(executor, m.Logger)
+	if m.Config.PGDumpBin != "" {
+		hostExec.PGRestoreBin = filepath.Join(filepath.Dir(m.Config.PGDumpBin), "pg_restore")
+	}
+	return hostExec.ListArchive(ctx, dbexec.DBContext{}, path, format)
+}
+
+// ParseArchiveObjects returns the sorted, distinct objects in pg_restore
+// --list output. Each object is an entry without its dump ID, OIDs and owner
+// ("TABLE public users"), so the same object matches across backups.
+func ParseArchiveObjects(list string) []string {
+	seen := map[string]bool{}
+	for _, entry := range dbexec.ParseRestoreEntries(list) {
+		// An entry with only a type names no object
+		if strings.Contains(entry.Object, " ") {
+			seen[entry.Object] = true
+		}
+	}
+
+	objects := make([]string, 0, len(seen))
+	for object := range seen {
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+// DiffObjects returns the objects only in b (added) and only in a (removed),
+// sorted.
+func DiffObjects(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, object := range a {
+		inA[object] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, object := range b {
+		inB[object] = true
+	}
+
+	added, removed = []string{}, []string{}
+	for object := range inB {
+		if !inA[object] {
+			added = append(added, object)
+		}
+	}
+	for object := range inA {
+		if !inB[object] {
+			removed = append(removed, object)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
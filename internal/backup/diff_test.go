@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const archiveListA = `;
+; Archive created at 2026-01-10 02:00:00 UTC
+;     dbname: payram
+;
+; Selected TOC Entries:
+;
+4; 3079 16385 EXTENSION - pgcrypto 
+215; 1259 16386 TABLE public users payram
+216; 1259 16390 TABLE public payments payram
+217; 1259 16395 TABLE public legacy_tokens payram
+3120; 0 16386 TABLE DATA public users payram
+3121; 0 16390 TABLE DATA public payments payram
+3122; 0 16395 TABLE DATA public legacy_tokens payram
+2950; 2606 16400 CONSTRAINT public users users_pkey payram
+`
+
+// archiveListB drops legacy_tokens, adds refunds and an index, and changes
+// the owner of users, which is not a change of objects.
+const archiveListB = `;
+; Archive created at 2026-02-10 02:00:00 UTC
+;
+4; 3079 16385 EXTENSION - pgcrypto 
+215; 1259 16386 TABLE public users payram_admin
+216; 1259 16390 TABLE public payments payram
+218; 1259 16420 TABLE public refunds payram
+3120; 0 16386 TABLE DATA public users payram_admin
+3121; 0 16390 TABLE DATA public payments payram
+3123; 0 16420 TABLE DATA public refunds payram
+2950; 2606 16400 CONSTRAINT public users users_pkey payram
+2960; 1259 16430 INDEX public payments_created_at_idx payram
+`
+
+func TestParseArchiveObjects(t *testing.T) {
+	got := ParseArchiveObjects(archiveListA)
+	want := []string{
+		"CONSTRAINT public users users_pkey",
+		"EXTENSION - pgcrypto",
+		"TABLE DATA public legacy_tokens",
+		"TABLE DATA public payments",
+		"TABLE DATA public users",
+		"TABLE public legacy_tokens",
+		"TABLE public payments",
+		"TABLE public users",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseArchiveObjects:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	added, removed := DiffObjects(ParseArchiveObjects(archiveListA), ParseArchiveObjects(archiveListB))
+	wantAdded := []string{"INDEX public payments_created_at_idx", "TABLE DATA public refunds", "TABLE public refunds"}
+	wantRemoved := []string{"TABLE DATA public legacy_tokens", "TABLE public legacy_tokens"}
+	if !reflect.DeepEqual(added, wantAdded) {
+		t.Errorf("added:\n got %q\nwant %q", added, wantAdded)
+	}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("removed:\n got %q\nwant %q", removed, wantRemoved)
+	}
+
+	added, removed = DiffObjects(ParseArchiveObjects(archiveListA), ParseArchiveObjects(archiveListA))
+	if len(added) != 0 || len(removed) != 0 {
+		t.Errorf("expected no differences between identical lists, got +%q -%q", added, removed)
+	}
+}
+
+func TestDiffBackups(t *testing.T) {
+	dir := t.TempDir()
+	pathA := writeBackupFile(t, "payram-backup-20260110-020000-1.7.0-to-1.8.0.dump", "PGDMP a")
+	pathB := writeBackupFile(t, "payram-backup-20260210-020000-1.8.0-to-1.9.0.dump", "PGDMP bigger b")
+	mock := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			if name != "pg_restore" {
+				return nil, errors.New("no such container")
+			}
+			if filepath.Base(args[len(args)-1]) == filepath.Base(pathA) {
+				return []byte(archiveListA), nil
+			}
+			return []byte(archiveListB), nil
+		},
+	}
+	mgr := NewManager(Config{Dir: dir}, mock, &mockLogger{})
+
+	diff, err := mgr.DiffBackups(context.Background(), pathA, pathB)
+	if err != nil {
+		t.Fatalf("DiffBackups: %v", err)
+	}
+	if diff.A.FromVersion != "1.7.0" || diff.B.ToVersion != "1.9.0" || diff.A.CreatedAt != "2026-01-10T02:00:00Z" {
+		t.Errorf("unexpected metadata: a=%+v b=%+v", diff.A, diff.B)
+	}
+	if diff.SizeDeltaBytes != 7 {
+		t.Errorf("expected a size delta of 7 bytes, got %d", diff.SizeDeltaBytes)
+	}
+	if !diff.ObjectsCompared || len(diff.Added) != 3 || len(diff.Removed) != 2 {
+		t.Errorf("expected 3 added and 2 removed objects, got %+v", diff)
+	}
+}
+
+func TestDiffBackups_PlainSQLSkipsObjects(t *testing.T) {
+	pathA := writeBackupFile(t, "a.sql", completeDump)
+	pathB := writeBackupFile(t, "b.dump", "PGDMP")
+	mgr := NewManager(Config{Dir: t.TempDir()}, &mockExecutor{}, &mockLogger{})
+
+	diff, err := mgr.DiffBackups(context.Background(), pathA, pathB)
+	if err != nil {
+		t.Fatalf("DiffBackups: %v", err)
+	}
+	if diff.ObjectsCompared || diff.ObjectsSkipped == "" {
+		t.Errorf("expected objects not to be compared for a plain SQL backup, got %+v", diff)
+	}
+}
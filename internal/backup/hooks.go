@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/httpclient"
+)
+
+// HookEvent describes the backup being wrapped by a pre/post hook.
+// It is passed to command hooks as PAYRAM_BACKUP_* environment variables
+// and to URL hooks as a JSON request body.
+type HookEvent struct {
+	Phase         string `json:"phase"` // "pre" or "post"
+	ContainerName string `json:"containerName"`
+	FromVersion   string `json:"fromVersion"`
+	TargetVersion string `json:"targetVersion"`
+	JobID         string `json:"jobId"`
+	Success       bool   `json:"success"`               // post only
+	BackupPath    string `json:"backupPath,omitempty"`  // post only
+	FailureCode   string `json:"failureCode,omitempty"` // post only
+}
+
+// isURLHook reports whether a hook should be invoked over HTTP rather than as a shell command.
+func isURLHook(hook string) bool {
+	return strings.HasPrefix(hook, "http://") || strings.HasPrefix(hook, "https://")
+}
+
+// runHook executes a backup hook. URL hooks receive a POST with the event as
+// JSON and must answer 2xx; anything else is run with `sh -c` and must exit 0.
+func runHook(ctx context.Context, hook string, event HookEvent) error {
+	if isURLHook(hook) {
+		return runURLHook(ctx, hook, event)
+	}
+	return runCommandHook(ctx, hook, event)
+}
+
+func runCommandHook(ctx context.Context, hook string, event HookEvent) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+	cmd.Env = append(os.Environ(),
+		"PAYRAM_BACKUP_PHASE="+event.Phase,
+		"PAYRAM_BACKUP_CONTAINER="+event.ContainerName,
+		"PAYRAM_BACKUP_FROM_VERSION="+event.FromVersion,
+		"PAYRAM_BACKUP_TARGET_VERSION="+event.TargetVersion,
+		"PAYRAM_BACKUP_JOB_ID="+event.JobID,
+		fmt.Sprintf("PAYRAM_BACKUP_SUCCESS=%t", event.Success),
+		"PAYRAM_BACKUP_PATH="+event.BackupPath,
+		"PAYRAM_BACKUP_FAILURE_CODE="+event.FailureCode,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hook command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func runURLHook(ctx context.Context, url string, event HookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create hook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpclient.New(0).Do(req)
+	if err != nil {
+		return fmt.Errorf("hook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("hook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
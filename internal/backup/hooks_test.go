@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newHookTestExecutor returns an executor whose docker binary is a stub that
+// records "backup" in the order log and fails, so the backup never succeeds.
+func newHookTestExecutor(t *testing.T) (*ContainerBackupExecutor, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	orderLog := filepath.Join(tmpDir, "order.log")
+
+	dockerStub := filepath.Join(tmpDir, "docker")
+	script := "#!/bin/sh\necho backup >> " + orderLog + "\nexit 1\n"
+	if err := os.WriteFile(dockerStub, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write docker stub: %v", err)
+	}
+
+	exec := NewContainerBackupExecutor(dockerStub, "pg_dump", filepath.Join(tmpDir, "backups"), &mockLogger{})
+	return exec, orderLog
+}
+
+func readOrder(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read order log: %v", err)
+	}
+	return strings.Fields(string(data))
+}
+
+func TestExecuteBackup_HooksRunInOrder(t *testing.T) {
+	exec, orderLog := newHookTestExecutor(t)
+	exec.PreBackupHook = "echo pre >> " + orderLog
+	exec.PostBackupHook = "echo post-$PAYRAM_BACKUP_SUCCESS >> " + orderLog
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+	if result.Success {
+		t.Fatal("expected backup to fail against docker stub")
+	}
+
+	order := readOrder(t, orderLog)
+	expected := []string{"pre", "backup", "post-false"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected hook order %v, got %v", expected, order)
+	}
+}
+
+func TestExecuteBackup_PreHookFailureAborts(t *testing.T) {
+	exec, orderLog := newHookTestExecutor(t)
+	exec.PreBackupHook = "echo pre >> " + orderLog + "; exit 1"
+	exec.PostBackupHook = "echo post >> " + orderLog
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{})
+	if result.Success {
+		t.Fatal("expected backup to fail when pre-hook fails")
+	}
+	if result.FailureCode != "PRE_BACKUP_HOOK_FAILED" {
+		t.Errorf("expected failure code PRE_BACKUP_HOOK_FAILED, got %s", result.FailureCode)
+	}
+
+	order := readOrder(t, orderLog)
+	expected := []string{"pre", "post"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected backup to be skipped and post-hook to run, got %v", order)
+	}
+}
+
+func TestExecuteBackup_PostHookFailureDoesNotChangeResult(t *testing.T) {
+	exec, _ := newHookTestExecutor(t)
+	exec.PostBackupHook = "exit 1"
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{})
+	if result.FailureCode != "DOCKER_DAEMON_DOWN" {
+		t.Errorf("expected original failure code DOCKER_DAEMON_DOWN, got %s", result.FailureCode)
+	}
+}
+
+func TestExecuteBackup_URLHooks(t *testing.T) {
+	var phases []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event HookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("failed to decode hook payload: %v", err)
+		}
+		if event.ContainerName != "payram" {
+			t.Errorf("expected container payram, got %s", event.ContainerName)
+		}
+		phases = append(phases, event.Phase)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	exec, _ := newHookTestExecutor(t)
+	exec.PreBackupHook = server.URL + "/pre"
+	exec.PostBackupHook = server.URL + "/post"
+
+	exec.ExecuteBackup(context.Background(), "payram", BackupMeta{})
+
+	if strings.Join(phases, ",") != "pre,post" {
+		t.Errorf("expected URL hooks pre,post, got %v", phases)
+	}
+}
+
+func TestExecuteBackup_URLPreHookNon2xxAborts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "workers busy", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exec, orderLog := newHookTestExecutor(t)
+	exec.PreBackupHook = server.URL
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{})
+	if result.FailureCode != "PRE_BACKUP_HOOK_FAILED" {
+		t.Errorf("expected failure code PRE_BACKUP_HOOK_FAILED, got %s", result.FailureCode)
+	}
+	if !strings.Contains(result.ErrorMessage, "503") {
+		t.Errorf("expected error message to include status code, got %q", result.ErrorMessage)
+	}
+	if _, err := os.Stat(orderLog); !os.IsNotExist(err) {
+		t.Error("expected backup not to run after pre-hook failure")
+	}
+}
@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/diskspace"
+)
+
+// ErrMountMissing marks a backup directory whose storage is not there. Its
+// text is the BACKUP_MOUNT_MISSING failure code.
+var ErrMountMissing = errors.New("BACKUP_MOUNT_MISSING")
+
+// CheckMount fails with ErrMountMissing when dir, or one of its parents, is a
+// symlink whose target does not exist, typically because the filesystem it
+// points into is not mounted. With requireMount it also fails when dir would
+// resolve onto the root filesystem, which is where an unmounted bind mount
+// or network share leaves it. Backups written there would fill / and vanish
+// from view once the mount comes back.
+func CheckMount(dir string, requireMount bool) error {
+	return checkMount(dir, requireMount, "/", diskspace.PathDevice)
+}
+
+func checkMount(dir string, requireMount bool, root string, device diskspace.DeviceFunc) error {
+	// Walk up to the nearest existing directory; MkdirAll would create the
+	// rest there, so a dangling symlink on the way is fatal.
+	existing := filepath.Clean(dir)
+	for {
+		if info, err := os.Lstat(existing); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(existing); os.IsNotExist(err) {
+				target, _ := os.Readlink(existing)
+				return fmt.Errorf("%w: backup directory %s goes through the symlink %s, whose target %s does not exist; mount the backup storage and retry", ErrMountMissing, dir, existing, target)
+			}
+		}
+		if _, err := os.Stat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return nil
+		}
+		existing = parent
+	}
+
+	if !requireMount {
+		return nil
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return fmt.Errorf("cannot resolve backup directory %s: %w", dir, err)
+	}
+	onRoot, err := diskspace.SameFilesystem(device, resolved, root)
+	if err != nil {
+		return fmt.Errorf("cannot check the filesystem of backup directory %s: %w", dir, err)
+	}
+	if onRoot {
+		return fmt.Errorf("%w: backup directory %s (%s) is on the root filesystem, but BACKUP_REQUIRE_MOUNT expects a mounted one; mount the backup storage and retry", ErrMountMissing, dir, resolved)
+	}
+	return nil
+}
+
+// mountFailure returns the failure code and message of a CheckMount error.
+func mountFailure(err error) (code, message string) {
+	if errors.Is(err, ErrMountMissing) {
+		return ErrMountMissing.Error(), strings.TrimPrefix(err.Error(), ErrMountMissing.Error()+": ")
+	}
+	return "BACKUP_FAILED", err.Error()
+}
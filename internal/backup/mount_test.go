@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// danglingBackupDir returns a backup directory that is a symlink into an
+// unmounted share: the link's target does not exist.
+func danglingBackupDir(t *testing.T) (link, target string) {
+	t.Helper()
+	dir := t.TempDir()
+	target = filepath.Join(dir, "mnt", "nas", "backups")
+	link = filepath.Join(dir, "backups")
+	if err := os.MkdirAll(filepath.Join(dir, "mnt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	return link, target
+}
+
+func TestCheckMount_DanglingSymlink(t *testing.T) {
+	link, _ := danglingBackupDir(t)
+
+	for _, dir := range []string{link, filepath.Join(link, "payram")} {
+		if err := CheckMount(dir, false); !errors.Is(err, ErrMountMissing) {
+			t.Errorf("expected BACKUP_MOUNT_MISSING for %s, got %v", dir, err)
+		}
+	}
+}
+
+func TestCheckMount_ExistingAndMissingDirs(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckMount(dir, false); err != nil {
+		t.Errorf("expected an existing directory to pass, got %v", err)
+	}
+	if err := CheckMount(filepath.Join(dir, "not", "yet", "created"), false); err != nil {
+		t.Errorf("expected a directory still to be created to pass, got %v", err)
+	}
+
+	target := filepath.Join(dir, "share")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckMount(filepath.Join(dir, "link"), false); err != nil {
+		t.Errorf("expected a symlink to an existing directory to pass, got %v", err)
+	}
+}
+
+func TestCheckMount_RequireMount(t *testing.T) {
+	dir := t.TempDir()
+	onRoot := func(path string) (uint64, error) { return 1, nil }
+	if err := checkMount(dir, true, "/", onRoot); !errors.Is(err, ErrMountMissing) {
+		t.Errorf("expected BACKUP_MOUNT_MISSING for a directory on the root filesystem, got %v", err)
+	}
+	if err := checkMount(dir, false, "/", onRoot); err != nil {
+		t.Errorf("expected no mount check without requireMount, got %v", err)
+	}
+
+	mounted := func(path string) (uint64, error) {
+		if path == "/" {
+			return 1, nil
+		}
+		return 2, nil
+	}
+	if err := checkMount(filepath.Join(dir, "payram"), true, "/", mounted); err != nil {
+		t.Errorf("expected a mounted directory to pass, got %v", err)
+	}
+}
+
+func TestEnsureDir_DanglingSymlinkNotCreated(t *testing.T) {
+	link, target := danglingBackupDir(t)
+
+	if err := EnsureDir(link); !errors.Is(err, ErrMountMissing) {
+		t.Fatalf("expected BACKUP_MOUNT_MISSING, got %v", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("expected the missing mount's target not to be created, got %v", err)
+	}
+}
+
+func TestExecuteBackup_DanglingBackupDirFailsBeforeHooks(t *testing.T) {
+	exec, orderLog := newHookTestExecutor(t)
+	exec.BackupDir, _ = danglingBackupDir(t)
+	exec.PreBackupHook = "echo pre >> " + orderLog
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+	if result.Success || result.FailureCode != "BACKUP_MOUNT_MISSING" {
+		t.Fatalf("expected BACKUP_MOUNT_MISSING, got %s (%s)", result.FailureCode, result.ErrorMessage)
+	}
+	if _, err := os.Stat(orderLog); !os.IsNotExist(err) {
+		t.Errorf("expected neither the hook nor docker to run, got %v", readOrder(t, orderLog))
+	}
+}
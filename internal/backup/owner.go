@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/dbexec"
+)
+
+// reassignOwnerSQL hands the objects a restore created as the connected role
+// over to appUser. Dumps are taken with --no-owner and restored with
+// --no-owner/--no-privileges, so a restore as PG_SUPERUSER leaves every
+// restored object owned by the superuser and out of the app user's reach.
+//
+// Only objects in user schemas are touched: extension members keep their
+// owner, and sequences and types that belong to another object follow it.
+// REASSIGN OWNED is not used as it fails for the bootstrap superuser
+// (postgres), which owns the system catalogs.
+const reassignOwnerSQL = `DO $$
+DECLARE
+	app text := %s;
+	me oid := (SELECT oid FROM pg_roles WHERE rolname = current_user);
+	r record;
+BEGIN
+	FOR r IN
+		SELECT format('ALTER SCHEMA %%I OWNER TO %%I', n.nspname, app) AS stmt, 1 AS pass
+		FROM pg_namespace n
+		WHERE n.nspowner = me AND n.nspname !~ '^pg_' AND n.nspname <> 'information_schema'
+		UNION ALL
+		SELECT format('ALTER %%s %%s OWNER TO %%I',
+			CASE c.relkind WHEN 'v' THEN 'VIEW' WHEN 'm' THEN 'MATERIALIZED VIEW' WHEN 'S' THEN 'SEQUENCE' WHEN 'f' THEN 'FOREIGN TABLE' ELSE 'TABLE' END,
+			c.oid::regclass, app), 2
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relowner = me AND c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f')
+			AND n.nspname !~ '^pg_' AND n.nspname <> 'information_schema'
+			AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype IN ('a', 'i', 'e'))
+		UNION ALL
+		SELECT format('ALTER %%s %%s OWNER TO %%I',
+			CASE p.prokind WHEN 'a' THEN 'AGGREGATE' WHEN 'p' THEN 'PROCEDURE' ELSE 'FUNCTION' END,
+			p.oid::regprocedure, app), 3
+		FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE p.proowner = me AND n.nspname !~ '^pg_' AND n.nspname <> 'information_schema'
+			AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e')
+		UNION ALL
+		SELECT format('ALTER %%s %%s OWNER TO %%I', CASE t.typtype WHEN 'd' THEN 'DOMAIN' ELSE 'TYPE' END, t.oid::regtype, app), 3
+		FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE t.typowner = me AND t.typtype IN ('c', 'd', 'e', 'r')
+			AND (t.typrelid = 0 OR (SELECT c.relkind FROM pg_class c WHERE c.oid = t.typrelid) = 'c')
+			AND n.nspname !~ '^pg_' AND n.nspname <> 'information_schema'
+			AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_type'::regclass AND d.objid = t.oid AND d.deptype IN ('i', 'e'))
+		ORDER BY pass
+	LOOP
+		EXECUTE r.stmt;
+	END LOOP;
+END
+$$`
+
+// reassignOwnership hands the objects restored as dbCtx's role to appUser,
+// so Payram can use them once the restore is done.
+func (m *Manager) reassignOwnership(ctx context.Context, pgExec dbexec.PGExecutor, dbCtx dbexec.DBContext, appUser string) error {
+	m.Logger.Printf("Handing the restored objects over from %s to %s...", dbCtx.Creds.Username, appUser)
+	if _, err := pgExec.Query(ctx, dbCtx, fmt.Sprintf(reassignOwnerSQL, quoteLiteral(appUser))); err != nil {
+		return err
+	}
+	return nil
+}
+
+// quoteLiteral quotes s as an SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Backup strategies. A snapshot backup asks the volume manager (LVM, btrfs,
+// ...) for a snapshot of the database volume instead of dumping it, which is
+// much faster for large databases; "both" takes a snapshot and a dump.
+const (
+	StrategyDump     = "dump"
+	StrategySnapshot = "snapshot"
+	StrategyBoth     = "both"
+)
+
+// snapshotExt is the extension of the metadata file recording a snapshot
+// backup. It sits in the backup directory next to .sql/.dump files so that
+// listing, pruning and restore treat snapshots like any other backup.
+const snapshotExt = ".snapshot"
+
+// SnapshotConfig configures snapshot backups.
+//
+// Command is run with `sh -c` after replacing {name} with a unique snapshot
+// name (payram-<timestamp>). If the last line of its standard output is a bare
+// identifier (e.g. a btrfs snapshot path), that is recorded as the snapshot
+// ID; otherwise the name is (lvcreate, for one, prints a sentence).
+// RollbackCommand is run the same way to restore a snapshot backup, with {id}
+// replaced by that ID. Both commands also see the values as
+// PAYRAM_SNAPSHOT_NAME/PAYRAM_SNAPSHOT_ID.
+type SnapshotConfig struct {
+	Strategy        string // StrategyDump (default), StrategySnapshot or StrategyBoth
+	Command         string
+	RollbackCommand string
+}
+
+// effectiveStrategy returns the strategy in force; snapshots are only taken
+// when a snapshot command is configured.
+func (c SnapshotConfig) effectiveStrategy() string {
+	if c.Command == "" || (c.Strategy != StrategySnapshot && c.Strategy != StrategyBoth) {
+		return StrategyDump
+	}
+	return c.Strategy
+}
+
+// SnapshotMeta is the content of a .snapshot metadata file.
+type SnapshotMeta struct {
+	SnapshotID    string    `json:"snapshotId"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"createdAt"`
+	FromVersion   string    `json:"fromVersion,omitempty"`
+	TargetVersion string    `json:"targetVersion,omitempty"`
+	JobID         string    `json:"jobId,omitempty"`
+}
+
+// snapshotIDPattern restricts IDs taken from command output to characters
+// that are safe to substitute into the rollback shell command.
+var snapshotIDPattern = regexp.MustCompile(`^[A-Za-z0-9._/@:+-]+$`)
+
+// createSnapshot runs the snapshot command and writes the metadata file for
+// it into dir, named like a dump taken at timestamp. It returns the metadata
+// file path.
+func (c SnapshotConfig) createSnapshot(ctx context.Context, dir, timestamp string, meta BackupMeta, logger Logger) (string, *SnapshotMeta, error) {
+	name := "payram-" + timestamp
+	logger.Printf("Creating volume snapshot %s...", name)
+
+	output, err := runSnapshotCommand(ctx, strings.ReplaceAll(c.Command, "{name}", name), "PAYRAM_SNAPSHOT_NAME="+name)
+	if err != nil {
+		return "", nil, fmt.Errorf("snapshot command failed: %w", err)
+	}
+
+	id := name
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); snapshotIDPattern.MatchString(last) {
+		id = last
+	}
+
+	snapshot := &SnapshotMeta{
+		SnapshotID:    id,
+		Name:          name,
+		CreatedAt:     time.Now().UTC(),
+		FromVersion:   meta.FromVersion,
+		TargetVersion: meta.TargetVersion,
+		JobID:         meta.JobID,
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal snapshot metadata: %w", err)
+	}
+	filename := fmt.Sprintf("payram-backup-%s-%s-to-%s%s", timestamp, sanitizeVersion(meta.FromVersion), sanitizeVersion(meta.TargetVersion), snapshotExt)
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", nil, fmt.Errorf("snapshot %s was created but its metadata could not be written: %w", id, err)
+	}
+
+	logger.Printf("Volume snapshot created: %s", id)
+	return path, snapshot, nil
+}
+
+// rollbackSnapshot restores the snapshot recorded in the metadata file at path.
+func (c SnapshotConfig) rollbackSnapshot(ctx context.Context, path string, logger Logger) (*SnapshotMeta, error) {
+	if c.RollbackCommand == "" {
+		return nil, fmt.Errorf("SNAPSHOT_ROLLBACK_UNAVAILABLE: %s is a snapshot backup but no snapshot rollback command is configured", filepath.Base(path))
+	}
+	snapshot, err := ReadSnapshotMeta(path)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Printf("Rolling back volume snapshot %s...", snapshot.SnapshotID)
+	command := strings.ReplaceAll(c.RollbackCommand, "{id}", snapshot.SnapshotID)
+	if _, err := runSnapshotCommand(ctx, command, "PAYRAM_SNAPSHOT_ID="+snapshot.SnapshotID, "PAYRAM_SNAPSHOT_NAME="+snapshot.Name); err != nil {
+		return nil, fmt.Errorf("RESTORE_FAILED: snapshot rollback command failed: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ReadSnapshotMeta reads and validates a .snapshot metadata file.
+func ReadSnapshotMeta(path string) (*SnapshotMeta, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot metadata: %w", err)
+	}
+	var snapshot SnapshotMeta
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot metadata %s: %w", filepath.Base(path), err)
+	}
+	if !snapshotIDPattern.MatchString(snapshot.SnapshotID) {
+		return nil, fmt.Errorf("snapshot metadata %s has an invalid snapshot ID %q", filepath.Base(path), snapshot.SnapshotID)
+	}
+	return &snapshot, nil
+}
+
+func runSnapshotCommand(ctx context.Context, command string, env ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()+string(output)))
+	}
+	return string(output), nil
+}
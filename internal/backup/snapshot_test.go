@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEffectiveStrategy(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SnapshotConfig
+		want   string
+	}{
+		{"default", SnapshotConfig{}, StrategyDump},
+		{"snapshot", SnapshotConfig{Strategy: StrategySnapshot, Command: "true"}, StrategySnapshot},
+		{"both", SnapshotConfig{Strategy: StrategyBoth, Command: "true"}, StrategyBoth},
+		{"snapshot without command", SnapshotConfig{Strategy: StrategySnapshot}, StrategyDump},
+		{"command without strategy", SnapshotConfig{Command: "true"}, StrategyDump},
+		{"unknown strategy", SnapshotConfig{Strategy: "zfs", Command: "true"}, StrategyDump},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.effectiveStrategy(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCreateBackup_SnapshotStrategySkipsDump(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+	nameFile := filepath.Join(tmpDir, "name")
+	mgr.Config.Snapshot = SnapshotConfig{
+		Strategy: StrategySnapshot,
+		Command:  `echo "$PAYRAM_SNAPSHOT_NAME" > ` + nameFile + `; echo "Create a snapshot of 'db' in '/snapshots/{name}'" >&2; echo /snapshots/{name}`,
+	}
+
+	info, err := mgr.CreateBackup(context.Background(), BackupMeta{FromVersion: "1.7.8", TargetVersion: "1.7.9", JobID: "job-123"})
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+
+	if len(executor.calls) != 0 {
+		t.Errorf("expected no pg_dump or credential discovery for a snapshot-only backup, got %d call(s)", len(executor.calls))
+	}
+	name, _ := os.ReadFile(nameFile)
+	wantID := "/snapshots/" + strings.TrimSpace(string(name))
+	if !strings.HasPrefix(string(name), "payram-") || info.SnapshotID != wantID {
+		t.Errorf("expected snapshot ID %q from command output, got %q", wantID, info.SnapshotID)
+	}
+	if !strings.HasSuffix(info.Filename, "-1.7.8-to-1.7.9.snapshot") {
+		t.Errorf("expected a .snapshot metadata file named after the versions, got %s", info.Filename)
+	}
+
+	snapshot, err := ReadSnapshotMeta(info.Path)
+	if err != nil {
+		t.Fatalf("failed to read persisted snapshot metadata: %v", err)
+	}
+	if snapshot.SnapshotID != wantID || snapshot.FromVersion != "1.7.8" || snapshot.TargetVersion != "1.7.9" || snapshot.JobID != "job-123" {
+		t.Errorf("unexpected snapshot metadata: %+v", snapshot)
+	}
+
+	backups, err := mgr.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Format != "snapshot" || backups[0].SnapshotID != wantID || backups[0].FromVersion != "1.7.8" {
+		t.Errorf("expected the snapshot backup to be listed with its ID, got %+v", backups)
+	}
+}
+
+func TestCreateBackup_SnapshotIDDefaultsToName(t *testing.T) {
+	mgr, _ := newTestManager(t, &mockExecutor{})
+	mgr.Config.Snapshot = SnapshotConfig{
+		Strategy: StrategySnapshot,
+		Command:  `echo '  Logical volume "{name}" created.'`,
+	}
+
+	info, err := mgr.CreateBackup(context.Background(), BackupMeta{FromVersion: "1.7.8", TargetVersion: "1.7.9"})
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+	if !strings.HasPrefix(info.SnapshotID, "payram-") || strings.Contains(info.SnapshotID, " ") {
+		t.Errorf("expected the snapshot name as ID when output is not an identifier, got %q", info.SnapshotID)
+	}
+}
+
+func TestCreateBackup_SnapshotCommandFails(t *testing.T) {
+	mgr, _ := newTestManager(t, &mockExecutor{})
+	mgr.Config.Snapshot = SnapshotConfig{
+		Strategy: StrategyBoth,
+		Command:  `echo "Insufficient free space" >&2; exit 5`,
+	}
+
+	_, err := mgr.CreateBackup(context.Background(), BackupMeta{FromVersion: "1.7.8", TargetVersion: "1.7.9"})
+	if err == nil {
+		t.Fatal("expected an error when the snapshot command fails")
+	}
+	if !strings.HasPrefix(err.Error(), "SNAPSHOT_FAILED") || !strings.Contains(err.Error(), "Insufficient free space") {
+		t.Errorf("expected SNAPSHOT_FAILED with the command output, got %v", err)
+	}
+	if backups, _ := mgr.ListBackups(); len(backups) != 0 {
+		t.Errorf("expected no backup to be recorded, got %+v", backups)
+	}
+}
+
+// writeSnapshotMeta writes a .snapshot metadata file into dir.
+func writeSnapshotMeta(t *testing.T, dir string, snapshot SnapshotMeta) string {
+	t.Helper()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "payram-backup-20240115-120000-1.7.8-to-1.7.9.snapshot")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRestoreBackup_SnapshotRunsRollbackCommand(t *testing.T) {
+	executor := &mockExecutor{}
+	mgr, tmpDir := newTestManager(t, executor)
+	outFile := filepath.Join(tmpDir, "rollback")
+	mgr.Config.Snapshot = SnapshotConfig{RollbackCommand: `echo "{id} $PAYRAM_SNAPSHOT_NAME" > ` + outFile}
+	path := writeSnapshotMeta(t, mgr.Config.Dir, SnapshotMeta{SnapshotID: "vg0/payram-20240115-120000", Name: "payram-20240115-120000"})
+
+	result, err := mgr.RestoreBackup(context.Background(), path, RestoreOptions{Confirmed: true})
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+
+	out, _ := os.ReadFile(outFile)
+	if got := strings.TrimSpace(string(out)); got != "vg0/payram-20240115-120000 payram-20240115-120000" {
+		t.Errorf("expected the rollback command to receive the snapshot ID and name, got %q", got)
+	}
+	if len(executor.calls) != 0 {
+		t.Errorf("expected no pg_restore or credential discovery for a snapshot restore, got %d call(s)", len(executor.calls))
+	}
+	if !result.DBRestored || result.FromVersion != "1.7.8" || result.ToVersion != "1.7.9" {
+		t.Errorf("unexpected restore result: %+v", result)
+	}
+}
+
+func TestRestoreBackup_SnapshotWithoutRollbackCommand(t *testing.T) {
+	mgr, _ := newTestManager(t, &mockExecutor{})
+	path := writeSnapshotMeta(t, mgr.Config.Dir, SnapshotMeta{SnapshotID: "vg0/snap", Name: "snap"})
+
+	_, err := mgr.RestoreBackup(context.Background(), path, RestoreOptions{Confirmed: true})
+	if err == nil || !strings.HasPrefix(err.Error(), "SNAPSHOT_ROLLBACK_UNAVAILABLE") {
+		t.Errorf("expected SNAPSHOT_ROLLBACK_UNAVAILABLE, got %v", err)
+	}
+}
+
+func TestReadSnapshotMeta_RejectsUnsafeID(t *testing.T) {
+	path := writeSnapshotMeta(t, t.TempDir(), SnapshotMeta{SnapshotID: "snap; rm -rf /", Name: "snap"})
+
+	if _, err := ReadSnapshotMeta(path); err == nil {
+		t.Error("expected an error for a snapshot ID that is unsafe to pass to the rollback command")
+	}
+}
+
+func TestExecuteBackup_SnapshotStrategy(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, func(name string, args []string) ([]byte, error) {
+		return []byte("1"), nil
+	})
+	// pg_dump runs through DockerBin; a missing binary proves it is never called.
+	exec.DockerBin = filepath.Join(t.TempDir(), "missing-docker")
+	exec.Snapshot = SnapshotConfig{Strategy: StrategySnapshot, Command: "echo snap-42"}
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+
+	if !result.Success {
+		t.Fatalf("expected snapshot backup to succeed, got %s: %s", result.FailureCode, result.ErrorMessage)
+	}
+	if result.SnapshotID != "snap-42" || !strings.HasSuffix(result.Path, ".snapshot") {
+		t.Errorf("expected snapshot metadata path and ID, got %s (%s)", result.Path, result.SnapshotID)
+	}
+	entries, _ := os.ReadDir(exec.BackupDir)
+	files := 0
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			files++
+		}
+	}
+	if files != 1 {
+		t.Errorf("expected only the snapshot metadata file, found %d file(s)", files)
+	}
+	if target, err := os.Readlink(filepath.Join(exec.BackupDir, "latest.snapshot")); err != nil || target != result.Filename {
+		t.Errorf("expected latest.snapshot to link to %s, got %q (err %v)", result.Filename, target, err)
+	}
+}
+
+func TestExecuteBackup_SnapshotFailureCode(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, func(name string, args []string) ([]byte, error) {
+		return []byte("1"), nil
+	})
+	exec.Snapshot = SnapshotConfig{Strategy: StrategyBoth, Command: "exit 1"}
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+
+	if result.Success || result.FailureCode != "SNAPSHOT_FAILED" {
+		t.Errorf("expected SNAPSHOT_FAILED, got success=%v code=%s", result.Success, result.FailureCode)
+	}
+}
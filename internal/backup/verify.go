@@ -0,0 +1,118 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/dbexec"
+)
+
+// pg_dump markers checked by VerifyRestorable. A plain dump opens with
+// plainDumpHeader and pg_dump writes plainDumpTrailer only once the dump is
+// complete; custom and directory archives start with archiveMagic.
+var (
+	plainDumpHeader  = []byte("-- PostgreSQL database dump")
+	plainDumpTrailer = []byte("-- PostgreSQL database dump complete")
+	archiveMagic     = []byte("PGDMP")
+)
+
+// verifyWindow is how much of each end of a backup VerifyRestorable reads.
+const verifyWindow = 4096
+
+// ArchiveLister returns the pg_restore --list output of a custom or directory
+// format backup, as Manager.ListArchive does.
+type ArchiveLister func(ctx context.Context, path, format string) (string, error)
+
+// VerifyRestorable is a check, without a database, that a backup could be
+// restored: on top of VerifyBackupFile's checks, a plain SQL dump must carry
+// pg_dump's header and completion trailer (a truncated dump has no trailer),
+// and a custom or directory archive must start with pg_dump's archive header
+// and its table of contents must list, via list, with at least one entry. A
+// truncated archive keeps its header, so only pg_restore --list catches it.
+func VerifyRestorable(ctx context.Context, path string, list ArchiveLister) error {
+	if err := (&Manager{}).VerifyBackupFile(path); err != nil {
+		return err
+	}
+
+	switch format := detectBackupFormat(path); format {
+	case "sql":
+		head, tail, err := readEnds(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(head, plainDumpHeader) {
+			return fmt.Errorf("%s is not a pg_dump SQL dump (no %q header)", path, plainDumpHeader)
+		}
+		if !bytes.Contains(tail, plainDumpTrailer) {
+			return fmt.Errorf("%s is incomplete: pg_dump's %q trailer is missing, so the dump was cut short", path, plainDumpTrailer)
+		}
+	case "dump", dbexec.FormatDirectory:
+		archive := path
+		if format == dbexec.FormatDirectory {
+			archive = filepath.Join(path, "toc.dat")
+		}
+		head, _, err := readEnds(archive)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(head, archiveMagic) {
+			return fmt.Errorf("%s is not a pg_dump archive (no %s header)", archive, archiveMagic)
+		}
+		toc, err := list(ctx, path, format)
+		if err != nil {
+			return fmt.Errorf("%s is not readable by pg_restore: %w", path, err)
+		}
+		if tocEntries(toc) == 0 {
+			return fmt.Errorf("%s is incomplete: pg_restore --list found no entries", path)
+		}
+	}
+	return nil
+}
+
+// tocEntries counts the entries in pg_restore --list output, skipping its
+// ";" comment lines.
+func tocEntries(list string) int {
+	n := 0
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, ";") {
+			n++
+		}
+	}
+	return n
+}
+
+// readEnds returns up to verifyWindow bytes from the start and the end of
+// the file at path.
+func readEnds(path string) (head, tail []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("backup file is not readable: %w", err)
+	}
+	defer f.Close()
+
+	head = make([]byte, verifyWindow)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, nil, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	head = head[:n]
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot stat backup file: %w", err)
+	}
+	if info.Size() <= verifyWindow {
+		return head, head, nil
+	}
+	tail = make([]byte, verifyWindow)
+	if _, err := f.ReadAt(tail, info.Size()-verifyWindow); err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	return head, tail, nil
+}
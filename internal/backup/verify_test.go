@@ -0,0 +1,106 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const completeDump = "--\n-- PostgreSQL database dump\n--\n\nCOPY public.payments (id) FROM stdin;\n1\n\\.\n\n--\n-- PostgreSQL database dump complete\n--\n\n"
+
+func writeBackupFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tocLister is an ArchiveLister that lists toc whatever the archive, or
+// fails with err, as pg_restore --list on a corrupt archive does.
+func tocLister(toc string, err error) ArchiveLister {
+	return func(ctx context.Context, path, format string) (string, error) {
+		return toc, err
+	}
+}
+
+const archiveTOC = ";\n; Archive created at 2024-05-01 10:00:00 UTC\n;\n215; 1259 16386 TABLE public payments payram\n"
+
+func TestVerifyRestorable(t *testing.T) {
+	padded := "--\n-- PostgreSQL database dump\n--\n" + strings.Repeat("INSERT INTO t VALUES (1);\n", 1000) + "--\n-- PostgreSQL database dump complete\n--\n"
+	tests := []struct {
+		name, file, content string
+		list                ArchiveLister
+		wantErr             string
+	}{
+		{"complete dump", "ok.sql", completeDump, nil, ""},
+		{"large complete dump", "large.sql", padded, nil, ""},
+		{"truncated dump", "cut.sql", "--\n-- PostgreSQL database dump\n--\n\nCOPY public.payments (id) FROM stdin;\n1\n", nil, "incomplete"},
+		{"not a dump", "junk.sql", "<html>502 Bad Gateway</html>\n", nil, "not a pg_dump SQL dump"},
+		{"empty", "empty.sql", "", nil, "empty"},
+		{"custom archive", "ok.dump", "PGDMP\x01\x0e\x00", tocLister(archiveTOC, nil), ""},
+		{"corrupt custom archive", "bad.dump", "garbage", tocLister(archiveTOC, nil), "not a pg_dump archive"},
+		{"truncated custom archive", "cut.dump", "PGDMP\x01\x0e\x00", tocLister("", errors.New("pg_restore: error: could not read from input file: end of file")), "not readable by pg_restore"},
+		{"custom archive without entries", "hollow.dump", "PGDMP\x01\x0e\x00", tocLister(";\n; Archive created at 2024-05-01 10:00:00 UTC\n;\n", nil), "no entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyRestorable(context.Background(), writeBackupFile(t, tt.file, tt.content), tt.list)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected a valid backup, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// dumpingDockerStub returns a docker stub whose pg_dump prints dump.
+func dumpingDockerStub(t *testing.T, dump string) string {
+	t.Helper()
+	dumpFile := writeBackupFile(t, "dump.out", dump)
+	stub := filepath.Join(filepath.Dir(dumpFile), "docker")
+	if err := os.WriteFile(stub, []byte("#!/bin/sh\ncat "+dumpFile+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return stub
+}
+
+func TestExecuteBackup_VerifyAfterCreateRejectsTruncatedDump(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, selectionProbe)
+	exec.DockerBin = dumpingDockerStub(t, "--\n-- PostgreSQL database dump\n--\n\nCOPY public.payments (id) FROM stdin;\n1\n")
+	exec.VerifyAfterCreate = true
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+	if result.Success {
+		t.Fatal("expected a truncated dump to fail verification")
+	}
+	if result.FailureCode != "BACKUP_VERIFY_FAILED" {
+		t.Errorf("expected BACKUP_VERIFY_FAILED, got %s (%s)", result.FailureCode, result.ErrorMessage)
+	}
+	entries, _ := os.ReadDir(exec.BackupDir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".sql") {
+			t.Errorf("expected the unverified backup to be removed, found %s", e.Name())
+		}
+	}
+}
+
+func TestExecuteBackup_VerifyAfterCreateAcceptsCompleteDump(t *testing.T) {
+	exec, _ := newProbeTestExecutor(t, localDBEnv, selectionProbe)
+	exec.DockerBin = dumpingDockerStub(t, completeDump)
+	exec.VerifyAfterCreate = true
+
+	result := exec.ExecuteBackup(context.Background(), "payram", BackupMeta{FromVersion: "1.0.0", TargetVersion: "1.1.0"})
+	if !result.Success {
+		t.Fatalf("expected a complete dump to pass verification, got %s (%s)", result.FailureCode, result.ErrorMessage)
+	}
+}
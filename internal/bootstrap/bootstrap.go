@@ -0,0 +1,243 @@
+// Package bootstrap recreates a Payram container from scratch and restores a
+// database backup into it, for disaster recovery on a host that has no
+// existing container to roll back.
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+// DockerRunner is the subset of dockerexec.Runner used for bootstrapping.
+type DockerRunner interface {
+	Pull(ctx context.Context, image string) error
+	Run(ctx context.Context, args []string) error
+	InspectRunning(ctx context.Context, container string) (bool, error)
+}
+
+// Restorer restores a database backup into a named container.
+type Restorer interface {
+	RestoreBackup(ctx context.Context, backupPath string, opts backup.RestoreOptions) (*backup.RestoreResult, error)
+}
+
+// Logger is the logging interface used by the bootstrapper.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// HealthCheckFunc reports whether the new container is ready to accept a restore.
+type HealthCheckFunc func(ctx context.Context) error
+
+// Spec describes the container to create.
+//
+// Ports, Mounts and Env are treated like an inspected container: the manifest
+// defaults are then layered on top (additively) by DockerRunBuilder, so explicit
+// flags win and the manifest fills in anything left unspecified.
+type Spec struct {
+	Image         string // repo:tag
+	ContainerName string // defaults to the manifest container name, then "payram"
+	RestartPolicy string // defaults to the manifest restart policy, then "unless-stopped"
+	Ports         []container.PortMapping
+	Mounts        []container.Mount
+	Env           []string
+	Labels        map[string]string  // docker labels, e.g. ThrowawayLabel
+	Manifest      *manifest.Manifest // optional
+}
+
+// Result describes the outcome of a bootstrap restore.
+type Result struct {
+	ContainerName string `json:"containerName"`
+	Image         string `json:"image"`
+	BackupPath    string `json:"backupPath"`
+	DBRestored    bool   `json:"dbRestored"`
+}
+
+// Bootstrapper creates a new Payram container and restores a backup into it.
+type Bootstrapper struct {
+	runner      DockerRunner
+	restorer    Restorer
+	healthCheck HealthCheckFunc
+	logger      Logger
+
+	// ReadyTimeout bounds how long to wait for the new container to be
+	// running and healthy; PollInterval is the delay between checks.
+	ReadyTimeout time.Duration
+	PollInterval time.Duration
+}
+
+// NewBootstrapper creates a new bootstrapper. healthCheck may be nil, in
+// which case the container only has to be running before the restore.
+func NewBootstrapper(runner DockerRunner, restorer Restorer, healthCheck HealthCheckFunc, logger Logger) *Bootstrapper {
+	return &Bootstrapper{
+		runner:       runner,
+		restorer:     restorer,
+		healthCheck:  healthCheck,
+		logger:       logger,
+		ReadyTimeout: 3 * time.Minute,
+		PollInterval: 5 * time.Second,
+	}
+}
+
+// Run creates the container described by spec, waits until it is healthy and
+// restores backupPath into it. It refuses to replace a running container; a
+// stopped container with the same name makes docker run fail.
+func (b *Bootstrapper) Run(ctx context.Context, spec Spec, backupPath string) (*Result, error) {
+	dockerArgs, name, err := BuildRunArgs(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := b.runner.InspectRunning(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing container %s: %w", name, err)
+	}
+	if running {
+		return nil, fmt.Errorf("container %s is already running (use restore --full-recovery instead)", name)
+	}
+
+	b.logf("Pulling image: %s", spec.Image)
+	if err := b.runner.Pull(ctx, spec.Image); err != nil {
+		return nil, fmt.Errorf("failed to pull image: %w", err)
+	}
+
+	b.logf("Creating container %s from %s", name, spec.Image)
+	if err := b.runner.Run(ctx, dockerArgs); err != nil {
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+
+	if err := b.waitReady(ctx, name); err != nil {
+		return nil, fmt.Errorf("container %s was created but did not become healthy; database NOT restored: %w", name, err)
+	}
+
+	result := &Result{
+		ContainerName: name,
+		Image:         spec.Image,
+		BackupPath:    backupPath,
+	}
+
+	b.logf("Restoring backup into %s: %s", name, backupPath)
+	if _, err := b.restorer.RestoreBackup(ctx, backupPath, backup.RestoreOptions{
+		Confirmed:     true,
+		ContainerName: name,
+	}); err != nil {
+		return result, fmt.Errorf("container %s created but database restore failed: %w", name, err)
+	}
+	result.DBRestored = true
+
+	return result, nil
+}
+
+// BuildRunArgs turns spec into docker run arguments via DockerRunBuilder and
+// returns them with the resolved container name.
+func BuildRunArgs(spec Spec) ([]string, string, error) {
+	repo, tag, ok := splitImage(spec.Image)
+	if !ok {
+		return nil, "", fmt.Errorf("image must be in repo:tag form, got %q", spec.Image)
+	}
+
+	manifestData := &manifest.Manifest{}
+	if spec.Manifest != nil {
+		copied := *spec.Manifest
+		manifestData = &copied
+	}
+	manifestData.Image.Repo = repo
+
+	name := spec.ContainerName
+	if name == "" {
+		name = manifestData.Defaults.ContainerName
+	}
+	if name == "" {
+		name = "payram"
+	}
+
+	restartPolicy := spec.RestartPolicy
+	if restartPolicy == "" {
+		restartPolicy = manifestData.Defaults.RestartPolicy
+	}
+	if restartPolicy == "" {
+		restartPolicy = "unless-stopped"
+	}
+
+	state := &container.RuntimeState{
+		Name:          name,
+		Image:         spec.Image,
+		ImageTag:      tag,
+		Ports:         spec.Ports,
+		Mounts:        spec.Mounts,
+		Env:           spec.Env,
+		RestartPolicy: container.RestartPolicy{Name: restartPolicy},
+	}
+
+	args, err := container.NewDockerRunBuilder(nil).BuildUpgradeArgs(state, manifestData, tag)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build docker run args: %w", err)
+	}
+	if len(spec.Labels) > 0 {
+		keys := make([]string, 0, len(spec.Labels))
+		for key := range spec.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		// After "run -d", ahead of the image at the end
+		labeled := append([]string{}, args[:2]...)
+		for _, key := range keys {
+			labeled = append(labeled, "--label", key+"="+spec.Labels[key])
+		}
+		args = append(labeled, args[2:]...)
+	}
+	return args, name, nil
+}
+
+// waitReady polls until the container is running and, if configured, healthy.
+func (b *Bootstrapper) waitReady(ctx context.Context, name string) error {
+	deadline := time.Now().Add(b.ReadyTimeout)
+	var lastErr error
+	for {
+		running, err := b.runner.InspectRunning(ctx, name)
+		switch {
+		case err != nil:
+			lastErr = err
+		case !running:
+			lastErr = fmt.Errorf("container is not running")
+		case b.healthCheck == nil:
+			return nil
+		default:
+			if lastErr = b.healthCheck(ctx); lastErr == nil {
+				return nil
+			}
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("timed out after %v: %w", b.ReadyTimeout, lastErr)
+		}
+		b.logf("Waiting for container %s: %v", name, lastErr)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.PollInterval):
+		}
+	}
+}
+
+// splitImage splits "repo:tag" on the last colon, ignoring colons that are
+// part of a registry host:port.
+func splitImage(image string) (string, string, bool) {
+	idx := strings.LastIndex(image, ":")
+	if idx <= 0 || idx == len(image)-1 || strings.Contains(image[idx+1:], "/") {
+		return "", "", false
+	}
+	return image[:idx], image[idx+1:], true
+}
+
+func (b *Bootstrapper) logf(format string, v ...interface{}) {
+	if b.logger != nil {
+		b.logger.Printf(format, v...)
+	}
+}
@@ -0,0 +1,215 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+type fakeRunner struct {
+	calls   []string
+	runArgs []string
+	// runningAfter is the number of InspectRunning calls after docker run
+	// that report "not running" before the container comes up.
+	runningAfter int
+	created      bool
+	existing     bool
+	pullErr      error
+}
+
+func (f *fakeRunner) Pull(ctx context.Context, image string) error {
+	f.calls = append(f.calls, "pull:"+image)
+	return f.pullErr
+}
+
+func (f *fakeRunner) Run(ctx context.Context, args []string) error {
+	f.calls = append(f.calls, "run")
+	f.runArgs = args
+	f.created = true
+	return nil
+}
+
+func (f *fakeRunner) InspectRunning(ctx context.Context, name string) (bool, error) {
+	f.calls = append(f.calls, "inspect:"+name)
+	if !f.created {
+		return f.existing, nil
+	}
+	if f.runningAfter > 0 {
+		f.runningAfter--
+		return false, nil
+	}
+	return true, nil
+}
+
+type fakeRestorer struct {
+	path string
+	opts backup.RestoreOptions
+	err  error
+}
+
+func (f *fakeRestorer) RestoreBackup(ctx context.Context, backupPath string, opts backup.RestoreOptions) (*backup.RestoreResult, error) {
+	f.path = backupPath
+	f.opts = opts
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &backup.RestoreResult{DBRestored: true}, nil
+}
+
+func newTestBootstrapper(runner DockerRunner, restorer Restorer, health HealthCheckFunc) *Bootstrapper {
+	b := NewBootstrapper(runner, restorer, health, log.New(io.Discard, "", 0))
+	b.PollInterval = time.Millisecond
+	b.ReadyTimeout = time.Second
+	return b
+}
+
+func testManifest() *manifest.Manifest {
+	return &manifest.Manifest{
+		Image: manifest.Image{Repo: "payramapp/payram"},
+		Defaults: manifest.Defaults{
+			ContainerName: "payram",
+			RestartPolicy: "unless-stopped",
+			Ports:         []manifest.Port{{Container: 8080, Host: 8080}},
+			Volumes:       []manifest.Volume{{Source: "/var/lib/payram", Destination: "/data"}},
+		},
+	}
+}
+
+func TestBootstrapper_Run_CreatesThenRestores(t *testing.T) {
+	runner := &fakeRunner{runningAfter: 2}
+	restorer := &fakeRestorer{}
+	healthChecks := 0
+	health := func(ctx context.Context) error {
+		healthChecks++
+		if healthChecks < 2 {
+			return errors.New("not ready")
+		}
+		return nil
+	}
+
+	spec := Spec{Image: "payramapp/payram:1.8.0", Manifest: testManifest(), Env: []string{"AES_KEY=secret"}}
+	result, err := newTestBootstrapper(runner, restorer, health).Run(context.Background(), spec, "/backups/payram-backup.dump")
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	if runner.calls[0] != "inspect:payram" || runner.calls[1] != "pull:payramapp/payram:1.8.0" || runner.calls[2] != "run" {
+		t.Errorf("expected existence check, pull, run; got %v", runner.calls)
+	}
+	if healthChecks != 2 {
+		t.Errorf("expected restore to wait for health, got %d health checks", healthChecks)
+	}
+	if restorer.path != "/backups/payram-backup.dump" || restorer.opts.ContainerName != "payram" || !restorer.opts.Confirmed {
+		t.Errorf("expected confirmed restore into payram, got path=%s opts=%+v", restorer.path, restorer.opts)
+	}
+	if !result.DBRestored || result.ContainerName != "payram" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	joined := strings.Join(runner.runArgs, " ")
+	for _, want := range []string{"--name payram", "-p 8080:8080/tcp", "-v /var/lib/payram:/data", "-e AES_KEY=secret"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected run args to contain %q, got %q", want, joined)
+		}
+	}
+	if runner.runArgs[len(runner.runArgs)-1] != "payramapp/payram:1.8.0" {
+		t.Errorf("expected image last, got %q", joined)
+	}
+}
+
+func TestBootstrapper_Run_ExistingContainerBlocked(t *testing.T) {
+	runner := &fakeRunner{existing: true}
+	restorer := &fakeRestorer{}
+
+	_, err := newTestBootstrapper(runner, restorer, nil).Run(context.Background(), Spec{Image: "payramapp/payram:1.8.0"}, "/backups/b.dump")
+	if err == nil {
+		t.Fatal("expected error when container is already running")
+	}
+	if runner.created || restorer.path != "" {
+		t.Error("expected no container creation or restore")
+	}
+}
+
+func TestBootstrapper_Run_UnhealthySkipsRestore(t *testing.T) {
+	runner := &fakeRunner{}
+	restorer := &fakeRestorer{}
+	health := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	b := newTestBootstrapper(runner, restorer, health)
+	b.ReadyTimeout = 10 * time.Millisecond
+	_, err := b.Run(context.Background(), Spec{Image: "payramapp/payram:1.8.0"}, "/backups/b.dump")
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected health timeout error, got %v", err)
+	}
+	if restorer.path != "" {
+		t.Error("expected no restore when container never became healthy")
+	}
+}
+
+func TestBootstrapper_Run_RestoreFailure(t *testing.T) {
+	runner := &fakeRunner{}
+	restorer := &fakeRestorer{err: errors.New("pg_restore failed")}
+
+	result, err := newTestBootstrapper(runner, restorer, nil).Run(context.Background(), Spec{Image: "payramapp/payram:1.8.0"}, "/backups/b.dump")
+	if err == nil {
+		t.Fatal("expected error when restore fails")
+	}
+	if result == nil || result.DBRestored {
+		t.Errorf("expected partial result without DB restore, got %+v", result)
+	}
+}
+
+func TestBootstrapper_Run_PullFailure(t *testing.T) {
+	runner := &fakeRunner{pullErr: errors.New("manifest unknown")}
+
+	_, err := newTestBootstrapper(runner, &fakeRestorer{}, nil).Run(context.Background(), Spec{Image: "payramapp/payram:9.9.9"}, "/backups/b.dump")
+	if err == nil {
+		t.Fatal("expected error when pull fails")
+	}
+	if runner.created {
+		t.Error("expected no container creation after pull failure")
+	}
+}
+
+func TestBuildRunArgs_ExplicitFlagsWinOverManifest(t *testing.T) {
+	spec := Spec{
+		Image:         "registry.example.com:5000/payram:1.8.0",
+		ContainerName: "payram-dr",
+		Ports:         []container.PortMapping{{HostPort: "9090", ContainerPort: "8080", Protocol: "tcp"}},
+		Manifest:      testManifest(),
+	}
+
+	args, name, err := BuildRunArgs(spec)
+	if err != nil {
+		t.Fatalf("BuildRunArgs failed: %v", err)
+	}
+	if name != "payram-dr" {
+		t.Errorf("expected explicit container name, got %s", name)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "-p 9090:8080/tcp") || strings.Contains(joined, "8080:8080") {
+		t.Errorf("expected explicit port mapping to replace manifest default, got %q", joined)
+	}
+	if args[len(args)-1] != "registry.example.com:5000/payram:1.8.0" {
+		t.Errorf("expected registry image preserved, got %q", args[len(args)-1])
+	}
+	if spec.Manifest.Image.Repo != "payramapp/payram" {
+		t.Error("expected caller's manifest not to be modified")
+	}
+}
+
+func TestBuildRunArgs_InvalidImage(t *testing.T) {
+	for _, image := range []string{"", "payramapp/payram", "registry.example.com:5000/payram", "payram:"} {
+		if _, _, err := BuildRunArgs(Spec{Image: image}); err == nil {
+			t.Errorf("expected error for image %q", image)
+		}
+	}
+}
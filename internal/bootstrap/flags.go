@@ -0,0 +1,91 @@
+package bootstrap
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/container"
+)
+
+// ParsePort parses a docker-style port flag: [hostIP:]hostPort:containerPort[/protocol].
+func ParsePort(value string) (container.PortMapping, error) {
+	spec, protocol := value, "tcp"
+	if idx := strings.LastIndex(spec, "/"); idx >= 0 {
+		spec, protocol = spec[:idx], spec[idx+1:]
+	}
+
+	parts := strings.Split(spec, ":")
+	var mapping container.PortMapping
+	switch len(parts) {
+	case 2:
+		mapping = container.PortMapping{HostPort: parts[0], ContainerPort: parts[1]}
+	case 3:
+		mapping = container.PortMapping{HostIP: parts[0], HostPort: parts[1], ContainerPort: parts[2]}
+	default:
+		return container.PortMapping{}, fmt.Errorf("invalid port %q: expected [hostIP:]hostPort:containerPort[/protocol]", value)
+	}
+	for _, port := range []string{mapping.HostPort, mapping.ContainerPort} {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return container.PortMapping{}, fmt.Errorf("invalid port %q: %q is not a port number", value, port)
+		}
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return container.PortMapping{}, fmt.Errorf("invalid port %q: protocol must be tcp or udp", value)
+	}
+	mapping.Protocol = protocol
+	return mapping, nil
+}
+
+// ParseMount parses a docker-style volume flag: source:destination[:ro|rw].
+// Sources starting with "/" are bind mounts; anything else is a named volume.
+func ParseMount(value string) (container.Mount, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return container.Mount{}, fmt.Errorf("invalid volume %q: expected source:destination[:ro|rw]", value)
+	}
+
+	mount := container.Mount{Type: "volume", Source: parts[0], Destination: parts[1], RW: true}
+	if strings.HasPrefix(mount.Source, "/") {
+		mount.Type = "bind"
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			mount.Mode, mount.RW = "ro", false
+		case "rw":
+			mount.Mode = "rw"
+		default:
+			return container.Mount{}, fmt.Errorf("invalid volume %q: mode must be ro or rw", value)
+		}
+	}
+	return mount, nil
+}
+
+// ReadEnvFile reads KEY=VALUE lines from path, skipping blanks and # comments.
+func ReadEnvFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer file.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, "=") {
+			return nil, fmt.Errorf("invalid env file line %d: expected KEY=VALUE", lineNo)
+		}
+		env = append(env, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return env, nil
+}
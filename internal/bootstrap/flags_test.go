@@ -0,0 +1,86 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string // hostIP|hostPort|containerPort|protocol
+		wantErr bool
+	}{
+		{value: "8080:8080", want: "|8080|8080|tcp"},
+		{value: "127.0.0.1:9443:8443/tcp", want: "127.0.0.1|9443|8443|tcp"},
+		{value: "5353:53/udp", want: "|5353|53|udp"},
+		{value: "8080", wantErr: true},
+		{value: "http:8080", wantErr: true},
+		{value: "8080:8080/sctp", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePort(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePort(%q): expected error, got %+v", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePort(%q): unexpected error %v", tt.value, err)
+			continue
+		}
+		if s := got.HostIP + "|" + got.HostPort + "|" + got.ContainerPort + "|" + got.Protocol; s != tt.want {
+			t.Errorf("ParsePort(%q) = %s, want %s", tt.value, s, tt.want)
+		}
+	}
+}
+
+func TestParseMount(t *testing.T) {
+	bind, err := ParseMount("/var/lib/payram:/data:ro")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bind.Type != "bind" || bind.Source != "/var/lib/payram" || bind.Destination != "/data" || bind.Mode != "ro" || bind.RW {
+		t.Errorf("unexpected bind mount: %+v", bind)
+	}
+
+	volume, err := ParseMount("payram-data:/data")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if volume.Type != "volume" || !volume.RW {
+		t.Errorf("unexpected volume mount: %+v", volume)
+	}
+
+	for _, value := range []string{"/data", ":/data", "/a:/b:rx"} {
+		if _, err := ParseMount(value); err == nil {
+			t.Errorf("ParseMount(%q): expected error", value)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payram.env")
+	content := "# payram secrets\nAES_KEY=abc=\n\nPOSTGRES_HOST=db.example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	env, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("ReadEnvFile failed: %v", err)
+	}
+	if len(env) != 2 || env[0] != "AES_KEY=abc=" || env[1] != "POSTGRES_HOST=db.example.com" {
+		t.Errorf("unexpected env: %v", env)
+	}
+
+	if err := os.WriteFile(path, []byte("NOT_AN_ASSIGNMENT\n"), 0600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if _, err := ReadEnvFile(path); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
@@ -0,0 +1,189 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+// ScratchRunner is the DockerRunner a migration check needs: it also restarts
+// the throwaway container and removes it with its volumes.
+type ScratchRunner interface {
+	DockerRunner
+	Restart(ctx context.Context, container string) error
+	RemoveWithVolumes(ctx context.Context, container string) error
+}
+
+// MigrationCheckResult describes the outcome of a migration check.
+type MigrationCheckResult struct {
+	ContainerName string `json:"containerName"`
+	Image         string `json:"image"`
+	BackupPath    string `json:"backupPath"`
+	DBRestored    bool   `json:"dbRestored"`
+	Migrated      bool   `json:"migrated"`  // healthy again after restarting on the restored data
+	CleanedUp     bool   `json:"cleanedUp"` // the throwaway container and its volumes were removed
+}
+
+// MigrationChecker restores a backup into a throwaway container running a
+// new version, to confirm that version's migrations succeed on real data
+// before upgrading production. The container is always removed afterwards.
+type MigrationChecker struct {
+	*Bootstrapper
+	runner ScratchRunner
+
+	// CleanupTimeout bounds the teardown, which runs even when ctx is done.
+	CleanupTimeout time.Duration
+}
+
+// NewMigrationChecker creates a new migration checker. healthCheck should
+// report whether Payram Core in the throwaway container is healthy.
+func NewMigrationChecker(runner ScratchRunner, restorer Restorer, healthCheck HealthCheckFunc, logger Logger) *MigrationChecker {
+	return &MigrationChecker{
+		Bootstrapper:   NewBootstrapper(runner, restorer, healthCheck, logger),
+		runner:         runner,
+		CleanupTimeout: 2 * time.Minute,
+	}
+}
+
+// ScratchSpec describes a throwaway container for image. It mounts nothing,
+// so its database starts empty and disappears with it, and each of ports is
+// published on 127.0.0.1 at a free host port so it cannot collide with the
+// production container. No manifest is attached: manifest volumes would
+// mount production data. The container carries ThrowawayLabel, and
+// ThrowawayCreatorLabel naming this process, so SweepThrowaway removes it if
+// this process dies before removing it.
+// POSTGRES_HOST and POSTGRES_PORT are always set to the container's own
+// database (see scratchEnv), whatever env holds.
+func ScratchSpec(image, name string, ports []manifest.Port, env []string) (Spec, error) {
+	spec := Spec{Image: image, ContainerName: name, RestartPolicy: "no", Env: scratchEnv(env), Labels: map[string]string{
+		ThrowawayLabel:        "true",
+		ThrowawayCreatorLabel: strconv.Itoa(os.Getpid()),
+	}}
+	for _, port := range ports {
+		hostPort, err := freeLoopbackPort()
+		if err != nil {
+			return Spec{}, fmt.Errorf("failed to reserve a host port for container port %d: %w", port.Container, err)
+		}
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		spec.Ports = append(spec.Ports, container.PortMapping{
+			HostIP:        "127.0.0.1",
+			HostPort:      hostPort,
+			ContainerPort: strconv.Itoa(port.Container),
+			Protocol:      protocol,
+		})
+	}
+	return spec, nil
+}
+
+// scratchEnv returns env with POSTGRES_HOST and POSTGRES_PORT pointing at
+// the database inside the throwaway container. The env is often copied from
+// production, and with an external POSTGRES_HOST the new version's migrations
+// would otherwise run against the production database.
+func scratchEnv(env []string) []string {
+	out := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if key == "POSTGRES_HOST" || key == "POSTGRES_PORT" {
+			continue
+		}
+		out = append(out, kv)
+	}
+	return append(out, "POSTGRES_HOST=127.0.0.1", "POSTGRES_PORT=5432")
+}
+
+// freeLoopbackPort returns a TCP port on 127.0.0.1 that is free right now.
+func freeLoopbackPort() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port), nil
+}
+
+// Run creates the throwaway container described by spec, waits until it is
+// healthy on its empty database, restores backupPath into it and restarts it
+// so the new version migrates the restored data. The check passes when the
+// container is healthy again. The container is removed with its volumes
+// however the check ends; a failed removal is reported as an error.
+func (c *MigrationChecker) Run(ctx context.Context, spec Spec, backupPath string) (result *MigrationCheckResult, err error) {
+	if spec.Manifest != nil || len(spec.Mounts) > 0 {
+		return nil, fmt.Errorf("a migration check container must not mount volumes")
+	}
+	if strings.HasSuffix(backupPath, ".snapshot") {
+		return nil, fmt.Errorf("snapshot backups roll back the production volume and cannot be checked; use a dump backup")
+	}
+	dockerArgs, name, err := BuildRunArgs(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := c.runner.InspectRunning(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing container %s: %w", name, err)
+	}
+	if running {
+		return nil, fmt.Errorf("container %s is already running; remove it before checking migrations", name)
+	}
+
+	c.logf("Pulling image: %s", spec.Image)
+	if err := c.runner.Pull(ctx, spec.Image); err != nil {
+		return nil, fmt.Errorf("failed to pull image: %w", err)
+	}
+
+	result = &MigrationCheckResult{ContainerName: name, Image: spec.Image, BackupPath: backupPath}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), c.CleanupTimeout)
+		defer cancel()
+		c.logf("Removing throwaway container %s and its volumes", name)
+		if rmErr := c.runner.RemoveWithVolumes(cleanupCtx, name); rmErr != nil {
+			rmErr = fmt.Errorf("failed to remove throwaway container %s (remove it with 'docker rm -f -v %s'): %w", name, name, rmErr)
+			if err == nil {
+				err = rmErr
+			} else {
+				err = fmt.Errorf("%w; %v", err, rmErr)
+			}
+			return
+		}
+		result.CleanedUp = true
+	}()
+
+	c.logf("Creating throwaway container %s from %s", name, spec.Image)
+	if err := c.runner.Run(ctx, dockerArgs); err != nil {
+		return result, fmt.Errorf("failed to create container: %w", err)
+	}
+	if err := c.waitReady(ctx, name); err != nil {
+		return result, fmt.Errorf("%s did not become healthy on an empty database; backup NOT restored: %w", spec.Image, err)
+	}
+
+	c.logf("Restoring backup into %s: %s", name, backupPath)
+	if _, err := c.restorer.RestoreBackup(ctx, backupPath, backup.RestoreOptions{
+		Confirmed:     true,
+		ContainerName: name,
+		Scratch:       true,
+	}); err != nil {
+		return result, fmt.Errorf("database restore into %s failed: %w", name, err)
+	}
+	result.DBRestored = true
+
+	c.logf("Restarting %s so %s migrates the restored data", name, spec.Image)
+	if err := c.runner.Restart(ctx, name); err != nil {
+		return result, fmt.Errorf("failed to restart %s: %w", name, err)
+	}
+	if err := c.waitReady(ctx, name); err != nil {
+		return result, fmt.Errorf("migrations failed: %s did not become healthy on the restored data: %w", spec.Image, err)
+	}
+	result.Migrated = true
+	return result, nil
+}
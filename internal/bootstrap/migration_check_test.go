@@ -0,0 +1,185 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+type fakeScratchRunner struct {
+	fakeRunner
+	restarted bool
+	removeErr error
+}
+
+func (f *fakeScratchRunner) Restart(ctx context.Context, name string) error {
+	f.calls = append(f.calls, "restart:"+name)
+	f.restarted = true
+	return nil
+}
+
+func (f *fakeScratchRunner) RemoveWithVolumes(ctx context.Context, name string) error {
+	f.calls = append(f.calls, "rm:"+name)
+	return f.removeErr
+}
+
+func newTestMigrationChecker(runner ScratchRunner, restorer Restorer, health HealthCheckFunc) *MigrationChecker {
+	c := NewMigrationChecker(runner, restorer, health, log.New(io.Discard, "", 0))
+	c.PollInterval = time.Millisecond
+	c.ReadyTimeout = 20 * time.Millisecond
+	return c
+}
+
+func scratchTestSpec(t *testing.T) Spec {
+	t.Helper()
+	spec, err := ScratchSpec("payramapp/payram:1.8.0", "payram-migration-check", []manifest.Port{{Container: 8080, Host: 8080}}, []string{"AES_KEY=secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestScratchSpec_LoopbackPortsAndNoMounts(t *testing.T) {
+	spec, err := ScratchSpec("payramapp/payram:1.8.0", "payram-migration-check", []manifest.Port{{Container: 8080, Host: 8080}, {Container: 8443}}, nil)
+	if err != nil {
+		t.Fatalf("ScratchSpec failed: %v", err)
+	}
+	if len(spec.Ports) != 2 || spec.Ports[0].HostPort == spec.Ports[1].HostPort {
+		t.Fatalf("expected two distinct host ports, got %+v", spec.Ports)
+	}
+	for _, port := range spec.Ports {
+		if port.HostIP != "127.0.0.1" || port.HostPort == "" || port.HostPort == "8080" || port.Protocol != "tcp" {
+			t.Errorf("expected a free loopback host port, got %+v", port)
+		}
+	}
+
+	args, _, err := BuildRunArgs(spec)
+	if err != nil {
+		t.Fatalf("BuildRunArgs failed: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	if strings.Contains(joined, " -v ") || !strings.Contains(joined, "--restart no") || !strings.Contains(joined, "-p 127.0.0.1:"+spec.Ports[0].HostPort+":8080/tcp") {
+		t.Errorf("expected loopback ports, no volumes and no restart, got %q", joined)
+	}
+	if !strings.HasPrefix(joined, "run -d --label "+ThrowawayLabel+"=true ") || !strings.HasSuffix(joined, " payramapp/payram:1.8.0") {
+		t.Errorf("expected the throwaway label ahead of the image, got %q", joined)
+	}
+	if spec.Labels[ThrowawayCreatorLabel] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("expected the creator label to name this process, got %q", spec.Labels[ThrowawayCreatorLabel])
+	}
+}
+
+func TestScratchSpec_ForcesLocalDatabase(t *testing.T) {
+	spec, err := ScratchSpec("payramapp/payram:1.8.0", "payram-migration-check", nil,
+		[]string{"AES_KEY=secret", "POSTGRES_HOST=db.prod.internal", "POSTGRES_PORT=6432", "POSTGRES_DATABASE=payram"})
+	if err != nil {
+		t.Fatalf("ScratchSpec failed: %v", err)
+	}
+	want := []string{"AES_KEY=secret", "POSTGRES_DATABASE=payram", "POSTGRES_HOST=127.0.0.1", "POSTGRES_PORT=5432"}
+	if strings.Join(spec.Env, " ") != strings.Join(want, " ") {
+		t.Errorf("expected the production host replaced by the container's own database, got %v", spec.Env)
+	}
+}
+
+func TestMigrationChecker_Run_RestoresRestartsAndCleansUp(t *testing.T) {
+	runner := &fakeScratchRunner{}
+	restorer := &fakeRestorer{}
+
+	result, err := newTestMigrationChecker(runner, restorer, nil).Run(context.Background(), scratchTestSpec(t), "/backups/payram-backup.dump")
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	got := strings.Join(runner.calls, ",")
+	want := "inspect:payram-migration-check,pull:payramapp/payram:1.8.0,run,inspect:payram-migration-check,restart:payram-migration-check,inspect:payram-migration-check,rm:payram-migration-check"
+	if got != want {
+		t.Errorf("expected calls\n  %s\ngot\n  %s", want, got)
+	}
+	if restorer.opts.ContainerName != "payram-migration-check" || !restorer.opts.Scratch || !restorer.opts.Confirmed {
+		t.Errorf("expected a confirmed scratch restore into the throwaway container, got %+v", restorer.opts)
+	}
+	if !result.DBRestored || !result.Migrated || !result.CleanedUp {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestMigrationChecker_Run_MigrationFailureCleansUp(t *testing.T) {
+	runner := &fakeScratchRunner{}
+	health := func(ctx context.Context) error {
+		if runner.restarted {
+			return errors.New("HTTP 503: migration 0042 failed")
+		}
+		return nil
+	}
+
+	result, err := newTestMigrationChecker(runner, &fakeRestorer{}, health).Run(context.Background(), scratchTestSpec(t), "/backups/b.dump")
+	if err == nil || !strings.Contains(err.Error(), "migrations failed") || !strings.Contains(err.Error(), "migration 0042 failed") {
+		t.Fatalf("expected a migration failure, got %v", err)
+	}
+	if result == nil || !result.DBRestored || result.Migrated || !result.CleanedUp {
+		t.Errorf("expected a restored, unmigrated, cleaned-up result, got %+v", result)
+	}
+}
+
+func TestMigrationChecker_Run_RestoreFailureCleansUp(t *testing.T) {
+	runner := &fakeScratchRunner{}
+
+	result, err := newTestMigrationChecker(runner, &fakeRestorer{err: errors.New("pg_restore failed")}, nil).Run(context.Background(), scratchTestSpec(t), "/backups/b.dump")
+	if err == nil || !strings.Contains(err.Error(), "pg_restore failed") {
+		t.Fatalf("expected the restore error, got %v", err)
+	}
+	if strings.Contains(strings.Join(runner.calls, ","), "restart:") {
+		t.Errorf("expected no restart after a failed restore, got %v", runner.calls)
+	}
+	if result == nil || !result.CleanedUp || runner.calls[len(runner.calls)-1] != "rm:payram-migration-check" {
+		t.Errorf("expected the container to be removed, got %+v calls %v", result, runner.calls)
+	}
+}
+
+func TestMigrationChecker_Run_CleanupFailureReported(t *testing.T) {
+	runner := &fakeScratchRunner{removeErr: errors.New("device busy")}
+
+	result, err := newTestMigrationChecker(runner, &fakeRestorer{}, nil).Run(context.Background(), scratchTestSpec(t), "/backups/b.dump")
+	if err == nil || !strings.Contains(err.Error(), "docker rm -f -v payram-migration-check") {
+		t.Fatalf("expected the failed cleanup to be reported, got %v", err)
+	}
+	if result == nil || !result.Migrated || result.CleanedUp {
+		t.Errorf("expected a migrated result that was not cleaned up, got %+v", result)
+	}
+}
+
+func TestMigrationChecker_Run_PullFailureCreatesNothing(t *testing.T) {
+	runner := &fakeScratchRunner{fakeRunner: fakeRunner{pullErr: errors.New("manifest unknown")}}
+
+	if _, err := newTestMigrationChecker(runner, &fakeRestorer{}, nil).Run(context.Background(), scratchTestSpec(t), "/backups/b.dump"); err == nil {
+		t.Fatal("expected error when pull fails")
+	}
+	if runner.created || strings.Contains(strings.Join(runner.calls, ","), "rm:") {
+		t.Errorf("expected no container and nothing to clean up, got %v", runner.calls)
+	}
+}
+
+func TestMigrationChecker_Run_RefusesMountsAndSnapshots(t *testing.T) {
+	runner := &fakeScratchRunner{}
+	c := newTestMigrationChecker(runner, &fakeRestorer{}, nil)
+
+	withManifest := scratchTestSpec(t)
+	withManifest.Manifest = testManifest()
+	if _, err := c.Run(context.Background(), withManifest, "/backups/b.dump"); err == nil {
+		t.Error("expected a spec with a manifest to be refused")
+	}
+	if _, err := c.Run(context.Background(), scratchTestSpec(t), "/backups/b.snapshot"); err == nil {
+		t.Error("expected a snapshot backup to be refused")
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("expected no docker calls, got %v", runner.calls)
+	}
+}
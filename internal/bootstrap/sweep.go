@@ -0,0 +1,65 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
+	"github.com/payram/payram-updater/internal/statelock"
+)
+
+// ThrowawayLabel marks the containers the updater creates only to remove
+// them again, such as migration check containers. Nothing else carries it,
+// so SweepThrowaway can never remove a real Payram container.
+const ThrowawayLabel = "io.payram.updater.throwaway"
+
+// ThrowawayCreatorLabel records the PID of the process that created a
+// ThrowawayLabel container, so SweepThrowaway can tell a leaked container
+// from one still in use.
+const ThrowawayCreatorLabel = "io.payram.updater.throwaway.pid"
+
+// SweepRunner is the subset of dockerexec.Runner SweepThrowaway needs.
+type SweepRunner interface {
+	ListLabeled(ctx context.Context, label string) ([]dockerexec.ContainerSummary, error)
+	RemoveWithVolumes(ctx context.Context, container string) error
+}
+
+// SweepThrowaway removes, with their volumes, the ThrowawayLabel containers
+// whose creator crashed before cleaning up. A container whose
+// ThrowawayCreatorLabel process is still running is in use and left alone,
+// however old; one whose creator has exited is removed at once. A container
+// without the label, from an older updater, is removed once created more
+// than maxAge before now. It returns the removed containers; a failed
+// removal does not stop the sweep and is reported in the error.
+func SweepThrowaway(ctx context.Context, runner SweepRunner, maxAge time.Duration, now time.Time) ([]string, error) {
+	containers, err := runner.ListLabeled(ctx, ThrowawayLabel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list throwaway containers: %w", err)
+	}
+
+	var removed []string
+	var errs []error
+	for _, c := range containers {
+		if !throwawayLeaked(c, maxAge, now) {
+			continue
+		}
+		if err := runner.RemoveWithVolumes(ctx, c.Name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove throwaway container %s: %w", c.Name, err))
+			continue
+		}
+		removed = append(removed, c.Name)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// throwawayLeaked reports whether the throwaway container c was left behind
+// by its creator (see SweepThrowaway).
+func throwawayLeaked(c dockerexec.ContainerSummary, maxAge time.Duration, now time.Time) bool {
+	if pid, err := strconv.Atoi(c.Labels[ThrowawayCreatorLabel]); err == nil && pid > 0 {
+		return !statelock.ProcessAlive(pid)
+	}
+	return now.Sub(c.CreatedAt) > maxAge
+}
@@ -0,0 +1,86 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
+)
+
+type fakeSweepRunner struct {
+	label      string
+	containers []dockerexec.ContainerSummary
+	removeErr  map[string]error
+	removed    []string
+}
+
+func (f *fakeSweepRunner) ListLabeled(ctx context.Context, label string) ([]dockerexec.ContainerSummary, error) {
+	f.label = label
+	return f.containers, nil
+}
+
+func (f *fakeSweepRunner) RemoveWithVolumes(ctx context.Context, name string) error {
+	if err := f.removeErr[name]; err != nil {
+		return err
+	}
+	f.removed = append(f.removed, name)
+	return nil
+}
+
+func TestSweepThrowaway_RemovesOnlyStaleContainers(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	runner := &fakeSweepRunner{
+		containers: []dockerexec.ContainerSummary{
+			{Name: "payram-migration-check", CreatedAt: now.Add(-3 * time.Hour)},
+			{Name: "payram-migration-check-2", CreatedAt: now.Add(-2 * time.Hour)},
+			{Name: "payram-migration-check-running", CreatedAt: now.Add(-5 * time.Minute)},
+		},
+		removeErr: map[string]error{"payram-migration-check-2": errors.New("device busy")},
+	}
+
+	removed, err := SweepThrowaway(context.Background(), runner, time.Hour, now)
+	if runner.label != ThrowawayLabel {
+		t.Errorf("expected containers to be listed by %s, got %q", ThrowawayLabel, runner.label)
+	}
+	if len(removed) != 1 || removed[0] != "payram-migration-check" {
+		t.Errorf("expected only the stale container to be removed, got %v", removed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "payram-migration-check-2") {
+		t.Errorf("expected the failed removal to be reported, got %v", err)
+	}
+	for _, name := range runner.removed {
+		if name == "payram-migration-check-running" {
+			t.Error("expected a recent throwaway container to be left alone")
+		}
+	}
+}
+
+func TestSweepThrowaway_ChecksCreatorProcess(t *testing.T) {
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	runner := &fakeSweepRunner{
+		containers: []dockerexec.ContainerSummary{
+			{Name: "payram-migration-check-live", CreatedAt: now.Add(-3 * time.Hour),
+				Labels: map[string]string{ThrowawayCreatorLabel: strconv.Itoa(os.Getpid())}},
+			{Name: "payram-migration-check-orphan", CreatedAt: now.Add(-time.Minute),
+				Labels: map[string]string{ThrowawayCreatorLabel: strconv.Itoa(exited.Process.Pid)}},
+		},
+	}
+
+	removed, err := SweepThrowaway(context.Background(), runner, time.Hour, now)
+	if err != nil {
+		t.Fatalf("SweepThrowaway failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "payram-migration-check-orphan" {
+		t.Errorf("expected only the container whose creator exited to be removed, got %v", removed)
+	}
+}
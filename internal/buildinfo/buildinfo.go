@@ -0,0 +1,36 @@
+// Package buildinfo holds the updater's own build information. Version,
+// Commit and Date are set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/payram/payram-updater/internal/buildinfo.Version=1.4.0 \
+//	  -X github.com/payram/payram-updater/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/payram/payram-updater/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// This is the updater's version, not Payram Core's.
+package buildinfo
+
+import "runtime"
+
+// Set via -ldflags -X; a plain go build leaves the defaults.
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// Info is the updater's build information.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: Date,
+		GoVersion: runtime.Version(),
+	}
+}
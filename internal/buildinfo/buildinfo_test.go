@@ -0,0 +1,25 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGet_ReportsInjectedValues(t *testing.T) {
+	defer func(version, commit, date string) { Version, Commit, Date = version, commit, date }(Version, Commit, Date)
+	Version, Commit, Date = "1.4.0", "abc1234", "2026-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "1.4.0" || info.Commit != "abc1234" || info.BuildDate != "2026-01-02T03:04:05Z" {
+		t.Errorf("expected the injected build info, got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+}
+
+func TestGet_Defaults(t *testing.T) {
+	if info := Get(); info.Version != "dev" || info.Commit != "unknown" || info.BuildDate != "unknown" {
+		t.Errorf("expected the defaults of an un-injected build, got %+v", info)
+	}
+}
@@ -29,7 +29,18 @@ type UpgradeSummary struct {
 	RequestedTarget string
 	ResolvedTarget  string
 	ImageRepo       string
-	ContainerName   string
+	// ImageRepoOverride marks ImageRepo as set with --image-repo rather
+	// than taken from the manifest.
+	ImageRepoOverride bool
+	ContainerName     string
+}
+
+// image returns the image repo to show, marked when it was overridden.
+func (s *UpgradeSummary) image() string {
+	if s.ImageRepoOverride {
+		return s.ImageRepo + " (--image-repo override)"
+	}
+	return s.ImageRepo
 }
 
 // Confirmer handles interactive confirmation prompts.
@@ -40,6 +51,8 @@ type Confirmer struct {
 	// IsTTY is a function that returns true if stdin is a TTY.
 	// This allows for testing by injecting a mock function.
 	IsTTY func() bool
+	// Plain prints the summary without the box and emoji.
+	Plain bool
 }
 
 // NewConfirmer creates a new Confirmer with default stdin/stdout/stderr.
@@ -49,6 +62,7 @@ func NewConfirmer() *Confirmer {
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
 		IsTTY:  defaultIsTTY,
+		Plain:  Std.Plain,
 	}
 }
 
@@ -93,8 +107,37 @@ func (c *Confirmer) Confirm(summary *UpgradeSummary, yesFlag bool) ConfirmResult
 	return ConfirmNo
 }
 
+// DefaultConfirmPhrase is what a restore asks to be typed when no
+// RESTORE_CONFIRM_PHRASE is configured.
+const DefaultConfirmPhrase = "yes"
+
+// ConfirmPhrase asks for phrase to be typed and reports whether it was. The
+// default phrase is accepted in any case, as before; a configured phrase
+// must be typed exactly, apart from surrounding spaces.
+func (c *Confirmer) ConfirmPhrase(phrase string) bool {
+	if phrase == "" {
+		phrase = DefaultConfirmPhrase
+	}
+	fmt.Fprintf(c.Stdout, "\nType '%s' to confirm: ", phrase)
+
+	input, err := bufio.NewReader(c.Stdin).ReadString('\n')
+	if err != nil && input == "" {
+		fmt.Fprintln(c.Stdout)
+		return false
+	}
+	input = strings.TrimSpace(input)
+	if phrase == DefaultConfirmPhrase {
+		return strings.ToLower(input) == phrase
+	}
+	return input == phrase
+}
+
 // printSummary prints the upgrade summary to stdout.
 func (c *Confirmer) printSummary(summary *UpgradeSummary) {
+	if c.Plain {
+		c.printPlainSummary(summary)
+		return
+	}
 	fmt.Fprintln(c.Stdout)
 	fmt.Fprintln(c.Stdout, "╔══════════════════════════════════════════════════════════════╗")
 	fmt.Fprintln(c.Stdout, "║                     UPGRADE SUMMARY                          ║")
@@ -105,7 +148,7 @@ func (c *Confirmer) printSummary(summary *UpgradeSummary) {
 		fmt.Fprintf(c.Stdout, "║  Resolved Target:  %-40s  ║\n", summary.ResolvedTarget)
 	}
 	if summary.ImageRepo != "" {
-		fmt.Fprintf(c.Stdout, "║  Image:            %-40s  ║\n", summary.ImageRepo)
+		fmt.Fprintf(c.Stdout, "║  Image:            %-40s  ║\n", summary.image())
 	}
 	if summary.ContainerName != "" {
 		fmt.Fprintf(c.Stdout, "║  Container:        %-40s  ║\n", summary.ContainerName)
@@ -121,6 +164,28 @@ func (c *Confirmer) printSummary(summary *UpgradeSummary) {
 	fmt.Fprintln(c.Stdout)
 }
 
+// printPlainSummary prints the upgrade summary without decoration.
+func (c *Confirmer) printPlainSummary(summary *UpgradeSummary) {
+	fmt.Fprintln(c.Stdout)
+	fmt.Fprintln(c.Stdout, "Upgrade summary:")
+	fmt.Fprintf(c.Stdout, "  Mode:             %s\n", summary.Mode)
+	fmt.Fprintf(c.Stdout, "  Requested Target: %s\n", summary.RequestedTarget)
+	if summary.ResolvedTarget != "" && summary.ResolvedTarget != summary.RequestedTarget {
+		fmt.Fprintf(c.Stdout, "  Resolved Target:  %s\n", summary.ResolvedTarget)
+	}
+	if summary.ImageRepo != "" {
+		fmt.Fprintf(c.Stdout, "  Image:            %s\n", summary.image())
+	}
+	if summary.ContainerName != "" {
+		fmt.Fprintf(c.Stdout, "  Container:        %s\n", summary.ContainerName)
+	}
+	fmt.Fprintln(c.Stdout, "This will stop and replace the container. Brief downtime expected.")
+	if summary.Mode == "DASHBOARD" {
+		fmt.Fprintln(c.Stdout, "Dashboard upgrades may be blocked by policy breakpoints.")
+	}
+	fmt.Fprintln(c.Stdout)
+}
+
 // ConfirmOrExit is a convenience function that handles the confirmation result
 // and exits appropriately. It returns true if the user confirmed.
 // If the user declines, it prints "Aborted by user." and exits with code 0.
@@ -135,6 +200,9 @@ func (c *Confirmer) ConfirmOrExit(summary *UpgradeSummary, yesFlag bool) bool {
 		fmt.Fprintln(c.Stdout, "Aborted by user.")
 		os.Exit(0)
 	case ConfirmNonInteractive:
+		if Std.JSONErrors {
+			Std.Fail(CommandError{Message: "refusing to run without confirmation in non-interactive mode", Code: CodeConfirmationNeeded, Hint: "Re-run with --yes."})
+		}
 		fmt.Fprintln(c.Stderr, "ERROR: refusing to run without confirmation in non-interactive mode. Re-run with --yes.")
 		os.Exit(2)
 	}
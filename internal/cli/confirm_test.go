@@ -375,3 +375,83 @@ func TestConfirmResultValues(t *testing.T) {
 		t.Errorf("expected ConfirmNonInteractive to be 2, got %d", ConfirmNonInteractive)
 	}
 }
+
+func TestConfirm_PlainSummaryHasNoDecoration(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	c := &Confirmer{
+		Stdin:  strings.NewReader("n\n"),
+		Stdout: stdout,
+		Stderr: &bytes.Buffer{},
+		IsTTY:  func() bool { return true },
+		Plain:  true,
+	}
+
+	c.Confirm(&UpgradeSummary{Mode: "DASHBOARD", RequestedTarget: "v1.7.0", ContainerName: "payram"}, false)
+
+	output := stdout.String()
+	for _, decoration := range []string{"╔", "║", "⚠️", "ℹ️"} {
+		if strings.Contains(output, decoration) {
+			t.Errorf("expected no %q in plain summary, got:\n%s", decoration, output)
+		}
+	}
+	for _, want := range []string{"Requested Target: v1.7.0", "Container:        payram", "This will stop and replace the container."} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected plain summary to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestConfirm_SummaryMarksImageRepoOverride(t *testing.T) {
+	for _, plain := range []bool{false, true} {
+		stdout := &bytes.Buffer{}
+		c := &Confirmer{
+			Stdin:  strings.NewReader("n\n"),
+			Stdout: stdout,
+			Stderr: &bytes.Buffer{},
+			IsTTY:  func() bool { return true },
+			Plain:  plain,
+		}
+
+		c.Confirm(&UpgradeSummary{Mode: "MANUAL", RequestedTarget: "v1.7.0", ImageRepo: "ghcr.io/acme/payram", ImageRepoOverride: true}, false)
+
+		if !strings.Contains(stdout.String(), "ghcr.io/acme/payram (--image-repo override)") {
+			t.Errorf("expected the image repo to be marked as an override (plain=%v), got:\n%s", plain, stdout.String())
+		}
+	}
+}
+
+func TestConfirmPhrase(t *testing.T) {
+	tests := []struct {
+		name   string
+		phrase string
+		input  string
+		want   bool
+	}{
+		{"default yes", "", "yes\n", true},
+		{"default any case", DefaultConfirmPhrase, "YES\n", true},
+		{"default rejects y", DefaultConfirmPhrase, "y\n", false},
+		{"custom phrase typed", "restore payram_prod", "  restore payram_prod \n", true},
+		{"custom phrase without newline", "payram_prod", "payram_prod", true},
+		{"custom phrase rejects yes", "payram_prod", "yes\n", false},
+		{"custom phrase is case sensitive", "payram_prod", "PAYRAM_PROD\n", false},
+		{"custom phrase rejects a prefix", "restore payram_prod", "restore\n", false},
+		{"empty input", "payram_prod", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			c := &Confirmer{Stdin: strings.NewReader(tt.input), Stdout: stdout, Stderr: &bytes.Buffer{}}
+
+			if got := c.ConfirmPhrase(tt.phrase); got != tt.want {
+				t.Errorf("expected %v for input %q, got %v", tt.want, tt.input, got)
+			}
+			want := DefaultConfirmPhrase
+			if tt.phrase != "" {
+				want = tt.phrase
+			}
+			if !strings.Contains(stdout.String(), "Type '"+want+"' to confirm: ") {
+				t.Errorf("expected the prompt to name %q, got %q", want, stdout.String())
+			}
+		})
+	}
+}
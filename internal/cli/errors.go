@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// JSONErrorsEnvVar enables --json-errors when set to "true".
+const JSONErrorsEnvVar = "UPDATER_JSON_ERRORS"
+
+// Codes for errors raised by the CLI itself rather than by an upgrade job,
+// whose failure codes are used as they are.
+const (
+	CodeUsage               = "USAGE_ERROR"
+	CodeConfig              = "CONFIG_ERROR"
+	CodeDaemonUnreachable   = "DAEMON_UNREACHABLE"
+	CodeDaemonResponse      = "DAEMON_RESPONSE_INVALID"
+	CodeJobActive           = "JOB_ACTIVE"
+	CodeConfirmationNeeded  = "CONFIRMATION_REQUIRED"
+	CodeUpgradeCancelled    = "UPGRADE_CANCELLED"
+	CodeWaitTimeout         = "WAIT_TIMEOUT"
+	CodeOperationFailed     = "OPERATION_FAILED"
+	CodeContainerUnresolved = "CONTAINER_NAME_UNRESOLVED"
+)
+
+// ExitCode maps an error code to the process exit code: the codes shared
+// with run --synchronous and --wait keep their exit codes (see
+// ExitNeedsConfirm), upgrade failure codes exit with FailureExitCode, and
+// every other error exits 1.
+func ExitCode(code string) int {
+	switch code {
+	case CodeConfirmationNeeded:
+		return ExitNeedsConfirm
+	case CodeUpgradeCancelled:
+		return ExitUpgradeCancelled
+	case CodeWaitTimeout:
+		return ExitWaitTimeout
+	}
+	return FailureExitCode(code)
+}
+
+// CommandError is an error the CLI reports before exiting. With
+// --json-errors it is written to stderr as a single JSON object; otherwise
+// Text, or Message followed by Hint, is written as is.
+type CommandError struct {
+	Message  string `json:"error"`
+	Code     string `json:"code"`
+	Hint     string `json:"hint,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	// Text replaces Message and Hint in human output, for errors printed
+	// over several lines.
+	Text string `json:"-"`
+}
+
+// WriteError reports err on Stderr. Unlike other messages, errors are
+// never hidden by --quiet. An empty Code defaults to OPERATION_FAILED and a
+// zero ExitCode to ExitCode(Code).
+func (o *Output) WriteError(err CommandError) {
+	if err.Code == "" {
+		err.Code = CodeOperationFailed
+	}
+	if err.ExitCode == 0 {
+		err.ExitCode = ExitCode(err.Code)
+	}
+	err.Message = strings.TrimSpace(err.Message)
+	err.Hint = strings.TrimSpace(err.Hint)
+
+	if o.JSONErrors {
+		data, _ := json.Marshal(err)
+		fmt.Fprintln(o.Stderr, string(data))
+		return
+	}
+	text := err.Text
+	if text == "" {
+		text = err.Message + "\n"
+		if err.Hint != "" {
+			text += err.Hint + "\n"
+		}
+	}
+	fmt.Fprint(o.stderr(), text)
+}
+
+// Fail reports err and exits with its exit code.
+func (o *Output) Fail(err CommandError) {
+	if err.ExitCode == 0 {
+		err.ExitCode = ExitCode(err.Code)
+	}
+	o.WriteError(err)
+	os.Exit(err.ExitCode)
+}
+
+// Failf reports a formatted error with code and hint (either may be empty)
+// and exits with ExitCode(code).
+func (o *Output) Failf(code, hint, format string, args ...interface{}) {
+	o.Fail(CommandError{Message: fmt.Sprintf(format, args...), Code: code, Hint: hint})
+}
+
+// ExtractJSONErrorsFlag removes the global --json-errors flag from args,
+// wherever it appears, and reports whether JSON errors are enabled by the
+// flag or by UPDATER_JSON_ERRORS=true.
+func ExtractJSONErrorsFlag(args []string) ([]string, bool) {
+	out := make([]string, 0, len(args))
+	found := os.Getenv(JSONErrorsEnvVar) == "true"
+	for _, arg := range args {
+		if arg == "--json-errors" || arg == "-json-errors" {
+			found = true
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out, found
+}
+
+// FailureError reports a failed plan or job: in human output, heading
+// followed by the indented failure code and message, then hint.
+func FailureError(heading, code, message, hint string) CommandError {
+	text := fmt.Sprintf("%s\n  Code: %s\n  Message: %s\n", heading, code, message)
+	if hint != "" {
+		text += hint + "\n"
+	}
+	return CommandError{Message: message, Code: code, Hint: hint, Text: text}
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteError_JSON(t *testing.T) {
+	out, stdout, stderr := newTestOutput(VerbosityQuiet)
+	out.JSONErrors = true
+
+	out.WriteError(CommandError{
+		Message: "Failed to connect to daemon: connection refused",
+		Code:    CodeDaemonUnreachable,
+		Hint:    "Is the payram-updater daemon running?",
+		Text:    "ignored in JSON mode\n",
+	})
+
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", stdout.String())
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(stderr.Bytes(), &got); err != nil {
+		t.Fatalf("expected one JSON object on stderr, got %q: %v", stderr.String(), err)
+	}
+	want := map[string]interface{}{
+		"error":    "Failed to connect to daemon: connection refused",
+		"code":     "DAEMON_UNREACHABLE",
+		"hint":     "Is the payram-updater daemon running?",
+		"exitCode": float64(1),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestWriteError_JSONDefaults(t *testing.T) {
+	out, _, stderr := newTestOutput(VerbosityNormal)
+	out.JSONErrors = true
+
+	out.WriteError(CommandError{Message: "something broke\n"})
+	out.WriteError(CommandError{Message: "job cancelled", Code: CodeUpgradeCancelled})
+
+	want := `{"error":"something broke","code":"OPERATION_FAILED","exitCode":1}` + "\n" +
+		`{"error":"job cancelled","code":"UPGRADE_CANCELLED","exitCode":3}` + "\n"
+	if stderr.String() != want {
+		t.Errorf("expected %q, got %q", want, stderr.String())
+	}
+}
+
+func TestWriteError_HumanOutputUnchanged(t *testing.T) {
+	out, stdout, stderr := newTestOutput(VerbosityQuiet)
+
+	out.WriteError(CommandError{Message: "Failed to connect to daemon: refused", Hint: "Is the payram-updater daemon running?"})
+	out.WriteError(CommandError{Message: "Error: --file is required"})
+	out.WriteError(FailureError("Upgrade job job-1 failed:", "HEALTHCHECK_FAILED", "health check timed out",
+		"Use 'payram-updater status' for the recovery playbook."))
+
+	want := "Failed to connect to daemon: refused\n" +
+		"Is the payram-updater daemon running?\n" +
+		"Error: --file is required\n" +
+		"Upgrade job job-1 failed:\n" +
+		"  Code: HEALTHCHECK_FAILED\n" +
+		"  Message: health check timed out\n" +
+		"Use 'payram-updater status' for the recovery playbook.\n"
+	if stderr.String() != want {
+		t.Errorf("expected %q, got %q", want, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", stdout.String())
+	}
+}
+
+func TestFailureError_JSON(t *testing.T) {
+	out, _, stderr := newTestOutput(VerbosityNormal)
+	out.JSONErrors = true
+
+	out.WriteError(FailureError("Upgrade validation failed:", "MANIFEST_FETCH_FAILED", "manifest unavailable", ""))
+
+	want := `{"error":"manifest unavailable","code":"MANIFEST_FETCH_FAILED","exitCode":5}` + "\n"
+	if stderr.String() != want {
+		t.Errorf("expected %q, got %q", want, stderr.String())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := map[string]int{
+		CodeConfirmationNeeded: ExitNeedsConfirm,
+		CodeUpgradeCancelled:   ExitUpgradeCancelled,
+		CodeWaitTimeout:        ExitWaitTimeout,
+		CodeDaemonUnreachable:  ExitUpgradeFailed,
+		"DOCKER_PULL_FAILED":   ExitUpgradeRetryable,
+		"HEALTHCHECK_FAILED":   ExitUpgradeDataRisk,
+		"DOCKER_TOO_OLD":       ExitUpgradeFailed,
+	}
+	for code, want := range cases {
+		if got := ExitCode(code); got != want {
+			t.Errorf("ExitCode(%s): expected %d, got %d", code, want, got)
+		}
+	}
+}
+
+func TestExtractJSONErrorsFlag(t *testing.T) {
+	t.Setenv(JSONErrorsEnvVar, "")
+
+	args, enabled := ExtractJSONErrorsFlag([]string{"run", "--json-errors", "--to", "1.7.8"})
+	if !enabled {
+		t.Error("expected --json-errors to enable JSON errors")
+	}
+	if want := []string{"run", "--to", "1.7.8"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("expected %v, got %v", want, args)
+	}
+
+	if _, enabled := ExtractJSONErrorsFlag([]string{"status"}); enabled {
+		t.Error("expected JSON errors to be off by default")
+	}
+
+	t.Setenv(JSONErrorsEnvVar, "true")
+	if _, enabled := ExtractJSONErrorsFlag([]string{"status"}); !enabled {
+		t.Errorf("expected %s=true to enable JSON errors", JSONErrorsEnvVar)
+	}
+}
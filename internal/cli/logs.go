@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+// LogFollower prints the daemon's job log as it is written, from the
+// /upgrade/logs?follow=true stream. Each response says where in the log it
+// starts (see jobs.LogCursorHeader), so after a reconnect it resumes right
+// after the last line printed, even if the log was rotated meanwhile.
+type LogFollower struct {
+	BaseURL string        // e.g. http://127.0.0.1:2359
+	Client  *http.Client  // defaults to http.DefaultClient
+	JobID   string        // if set, lines before "Starting upgrade job <JobID>" are skipped
+	Retry   time.Duration // between reconnects, defaults to 1s
+	Output  *Output       // log lines go to Stdout, defaults to Std
+
+	cursor  jobs.LogCursor // just after the last whole line read
+	started bool           // whether the line starting JobID was read
+}
+
+// Follow prints the log until ctx is done. It returns an error only if the
+// first connection fails; later failures are reported and retried.
+func (f *LogFollower) Follow(ctx context.Context) error {
+	retry := f.Retry
+	if retry <= 0 {
+		retry = time.Second
+	}
+	first := true
+	for {
+		more, err := f.read(ctx, true)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if first {
+				return err
+			}
+			f.output().Warnf("failed to follow logs: %v\n", err)
+		}
+		first = false
+		if more {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+	}
+}
+
+// Flush prints the lines of the log not printed yet. Call it after Follow
+// has returned, to pick up the lines written just before it stopped.
+func (f *LogFollower) Flush() error {
+	for {
+		more, err := f.read(context.Background(), false)
+		if err != nil || !more {
+			return err
+		}
+	}
+}
+
+// read prints the whole lines of one /upgrade/logs response, requested from
+// the cursor, and moves the cursor past them. It reports whether the response
+// ended at the end of a rotated log file, with newer lines to read at once.
+func (f *LogFollower) read(ctx context.Context, follow bool) (bool, error) {
+	query := url.Values{}
+	if follow {
+		query.Set("follow", "true")
+	}
+	if f.cursor != (jobs.LogCursor{}) {
+		query.Set("after", f.cursor.String())
+	}
+	path := "/upgrade/logs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.BaseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("HTTP %d from %s", resp.StatusCode, path)
+	}
+	header := resp.Header.Get(jobs.LogCursorHeader)
+	if header == "" {
+		// The daemon could not open the log
+		return false, nil
+	}
+	at, err := jobs.ParseLogCursor(header)
+	if err != nil {
+		return false, err
+	}
+	f.cursor = at
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		// Only consume whole lines, the rest is read again on reconnect
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return resp.Header.Get(jobs.LogMoreHeader) == "true", nil
+		}
+		if err != nil {
+			return false, err
+		}
+		f.cursor = f.cursor.Advance(len(line))
+		if !f.started {
+			if f.JobID != "" && !strings.HasPrefix(line, "Starting upgrade job "+f.JobID) {
+				continue
+			}
+			f.started = true
+		}
+		f.output().Printf("%s", line)
+	}
+}
+
+func (f *LogFollower) output() *Output {
+	if f.Output == nil {
+		return Std
+	}
+	return f.Output
+}
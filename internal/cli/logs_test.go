@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+func TestLogFollower_StreamsNewLines(t *testing.T) {
+	lines := make(chan string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("follow") != "true" {
+			t.Errorf("expected a follow request, got %s", r.URL)
+		}
+		w.Header().Set(jobs.LogCursorHeader, "7:0")
+		w.Write([]byte("Starting upgrade job job-0\nold job line\nStarting upgrade job job-1: mode=MANUAL\n"))
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case line := <-lines:
+				w.Write([]byte(line + "\n"))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer srv.Close()
+	stdout := &syncBuffer{}
+	follower := &LogFollower{BaseURL: srv.URL, JobID: "job-1", Output: &Output{Stdout: stdout, Stderr: stdout}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- follower.Follow(ctx) }()
+	lines <- "Pulling image"
+	lines <- "Starting container"
+	for deadline := time.Now().Add(time.Second); !strings.Contains(stdout.String(), "Starting container") && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	want := "Starting upgrade job job-1: mode=MANUAL\nPulling image\nStarting container\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+// logFileServer serves /upgrade/logs from log files by inode, as the daemon
+// does: from ?after= in the file it names, or from the start of current.
+type logFileServer struct {
+	mu      sync.Mutex
+	files   map[uint64]string
+	current uint64
+	afters  []string
+}
+
+func (l *logFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	after := r.URL.Query().Get("after")
+	l.afters = append(l.afters, after)
+	cursor, _ := jobs.ParseLogCursor(after)
+	if _, ok := l.files[cursor.File]; !ok {
+		cursor = jobs.LogCursor{File: l.current}
+	}
+	content := l.files[cursor.File]
+	if cursor.File != l.current {
+		if cursor.Offset == int64(len(content)) {
+			cursor = jobs.LogCursor{File: l.current}
+			content = l.files[l.current]
+		} else {
+			w.Header().Set(jobs.LogMoreHeader, "true")
+		}
+	}
+	w.Header().Set(jobs.LogCursorHeader, cursor.String())
+	w.Write([]byte(content[cursor.Offset:]))
+}
+
+func TestLogFollower_ResumesFromCursorOnReconnect(t *testing.T) {
+	logs := &logFileServer{files: map[uint64]string{7: "first\nsecond (partial"}, current: 7}
+	srv := httptest.NewServer(logs)
+	defer srv.Close()
+	out, stdout, _ := newTestOutput(VerbosityNormal)
+	follower := &LogFollower{BaseURL: srv.URL, Output: out}
+
+	if err := follower.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	logs.files[7] = "first\nsecond\nthird\n"
+	if err := follower.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if got := stdout.String(); got != "first\nsecond\nthird\n" {
+		t.Errorf("expected every line printed once, got %q", got)
+	}
+	if logs.afters[1] != "7:6" {
+		t.Errorf("expected the reconnect to resume after the last whole line, got after=%q", logs.afters[1])
+	}
+}
+
+func TestLogFollower_ResumesAcrossRotation(t *testing.T) {
+	logs := &logFileServer{files: map[uint64]string{7: "first\n"}, current: 7}
+	srv := httptest.NewServer(logs)
+	defer srv.Close()
+	out, stdout, _ := newTestOutput(VerbosityNormal)
+	follower := &LogFollower{BaseURL: srv.URL, Output: out}
+
+	if err := follower.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	// The log grows, then is rotated and the new file started
+	logs.files[7] = "first\nsecond\n"
+	logs.files[8] = "third\n"
+	logs.current = 8
+	if err := follower.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if got := stdout.String(); got != "first\nsecond\nthird\n" {
+		t.Errorf("expected the rotated file finished before the new one, got %q", got)
+	}
+}
+
+func TestLogFollower_FirstConnectionFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	out, _, _ := newTestOutput(VerbosityNormal)
+	follower := &LogFollower{BaseURL: srv.URL, Output: out}
+
+	err := follower.Follow(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "HTTP 500") {
+		t.Errorf("expected the first connection's HTTP 500, got %v", err)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to write while a test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
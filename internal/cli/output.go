@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Verbosity controls how much of the CLI's secondary output is shown.
+type Verbosity int
+
+const (
+	// VerbosityQuiet shows only primary output and errors.
+	VerbosityQuiet Verbosity = iota
+	// VerbosityNormal also shows progress messages, decoration and warnings.
+	VerbosityNormal
+	// VerbosityVerbose also shows diagnostics.
+	VerbosityVerbose
+)
+
+// Output separates a command's primary output (JSON, tables, the thing a
+// script parses) on Stdout from secondary messages on Stderr, which are
+// filtered by Verbosity. Errors are reported with WriteError and Fail,
+// and are always printed.
+//
+// When Plain is set, emoji and "=====" separator lines are stripped from
+// human-readable output, for logs and CI where decoration is clutter; JSON
+// written with PrintJSON is left untouched. When
+// JSONErrors is set, errors are written as JSON objects (see CommandError).
+type Output struct {
+	Stdout     io.Writer
+	Stderr     io.Writer
+	Verbosity  Verbosity
+	Plain      bool
+	JSONErrors bool
+}
+
+// Std is the output used by the payram-updater command.
+var Std = &Output{Stdout: os.Stdout, Stderr: os.Stderr, Verbosity: VerbosityNormal}
+
+// Printf writes primary output. It is never suppressed.
+func (o *Output) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(o.StdoutWriter(), format, args...)
+}
+
+// Println writes a line of primary output. It is never suppressed.
+func (o *Output) Println(args ...interface{}) {
+	fmt.Fprintln(o.StdoutWriter(), args...)
+}
+
+// PrintJSON writes data, a JSON document, as a line of primary output. Unlike
+// Println it is never stripped of decoration, so a script parses exactly what
+// was marshalled even when an emoji appears inside a string value.
+func (o *Output) PrintJSON(data []byte) {
+	fmt.Fprintln(o.Stdout, string(data))
+}
+
+// Separator writes a "=====" separator line of primary output.
+func (o *Output) Separator() {
+	o.Println(strings.Repeat("=", 60))
+}
+
+// Infof writes a progress or decorative message, hidden by --quiet.
+func (o *Output) Infof(format string, args ...interface{}) {
+	if o.Verbosity >= VerbosityNormal {
+		fmt.Fprintf(o.stderr(), format, args...)
+	}
+}
+
+// Warnf writes a warning, hidden by --quiet.
+func (o *Output) Warnf(format string, args ...interface{}) {
+	if o.Verbosity >= VerbosityNormal {
+		fmt.Fprintf(o.stderr(), "WARNING: "+format, args...)
+	}
+}
+
+// Debugf writes a diagnostic message, shown only with --verbose.
+func (o *Output) Debugf(format string, args ...interface{}) {
+	if o.Verbosity >= VerbosityVerbose {
+		fmt.Fprintf(o.stderr(), "[debug] "+format, args...)
+	}
+}
+
+// Writer returns a writer for secondary output at the given verbosity, for
+// components that log through a *log.Logger.
+func (o *Output) Writer(level Verbosity) io.Writer {
+	if o.Verbosity >= level {
+		return o.stderr()
+	}
+	return io.Discard
+}
+
+// StdoutWriter returns a writer for primary output, for components that
+// format onto an io.Writer.
+func (o *Output) StdoutWriter() io.Writer {
+	if o.Plain {
+		return plainWriter{o.Stdout}
+	}
+	return o.Stdout
+}
+
+func (o *Output) stderr() io.Writer {
+	if o.Plain {
+		return plainWriter{o.Stderr}
+	}
+	return o.Stderr
+}
+
+// emojiStripper removes the emoji the CLI decorates messages with, along
+// with the spacing that follows them.
+var emojiStripper = strings.NewReplacer(
+	"⚠️  ", "", "ℹ️  ", "", "✅ ", "", "❌ ", "", "✓ ", "", "🔍 ", "",
+	"⚠️", "", "ℹ️", "", "✅", "", "❌", "", "✓", "", "🔍", "",
+)
+
+// plainWriter strips decoration from each write. Separator lines are only
+// recognised when written whole, as fmt.Fprintln does.
+type plainWriter struct {
+	w io.Writer
+}
+
+func (p plainWriter) Write(b []byte) (int, error) {
+	lines := strings.SplitAfter(emojiStripper.Replace(string(b)), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !isSeparatorLine(line) {
+			kept = append(kept, line)
+		}
+	}
+	if _, err := io.WriteString(p.w, strings.Join(kept, "")); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// isSeparatorLine reports whether line is made only of "=" characters.
+func isSeparatorLine(line string) bool {
+	line = strings.TrimRight(line, "\n")
+	return line != "" && strings.Trim(line, "=") == ""
+}
+
+// IsPlain reports whether decoration should be disabled: when --no-color was
+// given, NO_COLOR is set to any non-empty value, or stdout is not a terminal.
+func IsPlain(noColorFlag bool, stdout *os.File) bool {
+	if noColorFlag || os.Getenv("NO_COLOR") != "" {
+		return true
+	}
+	return !term.IsTerminal(int(stdout.Fd()))
+}
+
+// ExtractNoColorFlag removes the global --no-color flag from args, wherever
+// it appears, and reports whether it was present.
+func ExtractNoColorFlag(args []string) ([]string, bool) {
+	out := make([]string, 0, len(args))
+	found := false
+	for _, arg := range args {
+		if arg == "--no-color" || arg == "-no-color" {
+			found = true
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out, found
+}
+
+// ExtractVerbosityFlags removes the global --quiet/-q and --verbose/-v flags
+// from args, wherever they appear, and returns the remaining args with the
+// requested verbosity.
+func ExtractVerbosityFlags(args []string) ([]string, Verbosity, error) {
+	out := make([]string, 0, len(args))
+	quiet, verbose := false, false
+	for _, arg := range args {
+		switch strings.TrimLeft(arg, "-") {
+		case "quiet", "q":
+			if strings.HasPrefix(arg, "-") {
+				quiet = true
+				continue
+			}
+		case "verbose", "v":
+			if strings.HasPrefix(arg, "-") {
+				verbose = true
+				continue
+			}
+		}
+		out = append(out, arg)
+	}
+	switch {
+	case quiet && verbose:
+		return nil, VerbosityNormal, fmt.Errorf("--quiet and --verbose cannot be combined")
+	case quiet:
+		return out, VerbosityQuiet, nil
+	case verbose:
+		return out, VerbosityVerbose, nil
+	}
+	return out, VerbosityNormal, nil
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeAll emits one message of every kind through out.
+func writeAll(out *Output) {
+	out.Infof("Creating database backup...\n")
+	out.Warnf("falling back to %s\n", "http://127.0.0.1:8080")
+	out.Debugf("Daemon port: %d\n", 2567)
+	log.New(out.Writer(VerbosityNormal), "", 0).Printf("Discovered container: payram")
+	out.Println(`{"success": true}`)
+}
+
+func newTestOutput(verbosity Verbosity) (*Output, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &Output{Stdout: stdout, Stderr: stderr, Verbosity: verbosity}, stdout, stderr
+}
+
+func TestOutput_QuietKeepsOnlyPrimaryOutput(t *testing.T) {
+	out, stdout, stderr := newTestOutput(VerbosityQuiet)
+
+	writeAll(out)
+
+	if stderr.Len() != 0 {
+		t.Errorf("expected no secondary output with --quiet, got %q", stderr.String())
+	}
+	if stdout.String() != "{\"success\": true}\n" {
+		t.Errorf("expected primary output to be preserved, got %q", stdout.String())
+	}
+}
+
+func TestOutput_NormalHidesDiagnostics(t *testing.T) {
+	out, stdout, stderr := newTestOutput(VerbosityNormal)
+
+	writeAll(out)
+
+	want := "Creating database backup...\n" +
+		"WARNING: falling back to http://127.0.0.1:8080\n" +
+		"Discovered container: payram\n"
+	if stderr.String() != want {
+		t.Errorf("expected progress and warnings without diagnostics, got %q", stderr.String())
+	}
+	if stdout.String() != "{\"success\": true}\n" {
+		t.Errorf("expected primary output on stdout only, got %q", stdout.String())
+	}
+}
+
+func TestOutput_VerboseShowsDiagnostics(t *testing.T) {
+	out, _, stderr := newTestOutput(VerbosityVerbose)
+
+	writeAll(out)
+
+	if !bytes.Contains(stderr.Bytes(), []byte("[debug] Daemon port: 2567\n")) {
+		t.Errorf("expected diagnostics with --verbose, got %q", stderr.String())
+	}
+}
+
+func TestExtractVerbosityFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantArgs  []string
+		wantLevel Verbosity
+		wantErr   bool
+	}{
+		{"none", []string{"payram-updater", "status"}, []string{"payram-updater", "status"}, VerbosityNormal, false},
+		{"quiet before command", []string{"payram-updater", "--quiet", "backup", "list"}, []string{"payram-updater", "backup", "list"}, VerbosityQuiet, false},
+		{"short quiet after command", []string{"payram-updater", "backup", "create", "-q"}, []string{"payram-updater", "backup", "create"}, VerbosityQuiet, false},
+		{"verbose", []string{"payram-updater", "-v", "inspect"}, []string{"payram-updater", "inspect"}, VerbosityVerbose, false},
+		{"positional word kept", []string{"payram-updater", "playbook", "show", "quiet"}, []string{"payram-updater", "playbook", "show", "quiet"}, VerbosityNormal, false},
+		{"both", []string{"payram-updater", "--quiet", "--verbose", "status"}, nil, VerbosityNormal, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, level, err := ExtractVerbosityFlags(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) || level != tt.wantLevel {
+				t.Errorf("expected %v at %d, got %v at %d", tt.wantArgs, tt.wantLevel, args, level)
+			}
+		})
+	}
+}
+
+// writeDecorated emits decorated primary and secondary output through out.
+func writeDecorated(out *Output) {
+	out.Separator()
+	out.Println("✅ SYNC SUCCESSFUL")
+	out.Separator()
+	out.Printf("Current running version: %s\n", "1.1.0")
+	out.Infof("\n⚠️  Full recovery mode: Rolling back container...\n")
+	out.Infof("✓ Skipping redundant confirmation\n")
+}
+
+func TestOutput_PlainStripsDecoration(t *testing.T) {
+	out, stdout, stderr := newTestOutput(VerbosityNormal)
+	out.Plain = true
+
+	writeDecorated(out)
+
+	if stdout.String() != "SYNC SUCCESSFUL\nCurrent running version: 1.1.0\n" {
+		t.Errorf("expected undecorated primary output, got %q", stdout.String())
+	}
+	if stderr.String() != "\nFull recovery mode: Rolling back container...\nSkipping redundant confirmation\n" {
+		t.Errorf("expected undecorated secondary output, got %q", stderr.String())
+	}
+}
+
+func TestOutput_PlainStripsDryRunEmoji(t *testing.T) {
+	out, stdout, _ := newTestOutput(VerbosityNormal)
+	out.Plain = true
+
+	out.Println("🔍 SYNC DRY RUN (nothing was changed)")
+
+	if stdout.String() != "SYNC DRY RUN (nothing was changed)\n" {
+		t.Errorf("expected the dry-run banner undecorated, got %q", stdout.String())
+	}
+}
+
+func TestOutput_PrintJSONKeepsDecoration(t *testing.T) {
+	out, stdout, _ := newTestOutput(VerbosityNormal)
+	out.Plain = true
+
+	doc := []byte(`{"message": "✅ backup created\n====="}`)
+	out.PrintJSON(doc)
+
+	if stdout.String() != string(doc)+"\n" {
+		t.Errorf("expected JSON written unchanged, got %q", stdout.String())
+	}
+}
+
+func TestOutput_DecoratedByDefault(t *testing.T) {
+	out, stdout, stderr := newTestOutput(VerbosityNormal)
+
+	writeDecorated(out)
+
+	if !strings.Contains(stdout.String(), strings.Repeat("=", 60)+"\n✅ SYNC SUCCESSFUL\n") {
+		t.Errorf("expected separators and emoji on stdout, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "⚠️  Full recovery mode") {
+		t.Errorf("expected emoji on stderr, got %q", stderr.String())
+	}
+}
+
+func TestIsPlain(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	t.Setenv("NO_COLOR", "")
+	if !IsPlain(false, file) {
+		t.Error("expected plain output when stdout is a file")
+	}
+	if !IsPlain(true, file) {
+		t.Error("expected plain output with --no-color")
+	}
+
+	// A character device that is not a terminal is not a TTY either
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	if !IsPlain(false, devNull) {
+		t.Errorf("expected plain output when stdout is %s", os.DevNull)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if !IsPlain(false, os.Stdout) {
+		t.Error("expected plain output with NO_COLOR set")
+	}
+}
+
+func TestExtractNoColorFlag(t *testing.T) {
+	args, noColor := ExtractNoColorFlag([]string{"payram-updater", "inspect", "--no-color"})
+	if !noColor || !reflect.DeepEqual(args, []string{"payram-updater", "inspect"}) {
+		t.Errorf("expected --no-color removed and reported, got %v %v", args, noColor)
+	}
+	args, noColor = ExtractNoColorFlag([]string{"payram-updater", "status"})
+	if noColor || !reflect.DeepEqual(args, []string{"payram-updater", "status"}) {
+		t.Errorf("expected args unchanged without --no-color, got %v %v", args, noColor)
+	}
+}
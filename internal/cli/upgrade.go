@@ -4,6 +4,8 @@ package cli
 import (
 	"errors"
 	"strings"
+
+	"github.com/payram/payram-updater/internal/policy"
 )
 
 // UpgradeMode represents the upgrade mode.
@@ -34,7 +36,7 @@ var (
 // It enforces:
 // - mode must be "dashboard" or "manual" (case-insensitive)
 // - target must not be empty
-// - "latest" is only allowed in manual mode
+// - "latest" and the other strategies (e.g. "latest-patch") are manual-only
 func ParseUpgradeRequest(mode, target string) (*UpgradeRequest, error) {
 	// Validate mode is present
 	if mode == "" {
@@ -61,8 +63,8 @@ func ParseUpgradeRequest(mode, target string) (*UpgradeRequest, error) {
 		return nil, ErrInvalidMode
 	}
 
-	// Dashboard mode cannot use 'latest'
-	if parsedMode == ModeDashboard && strings.ToLower(normalizedTarget) == "latest" {
+	// Dashboard mode cannot use 'latest' or another strategy
+	if parsedMode == ModeDashboard && policy.IsStrategy(normalizedTarget) {
 		return nil, ErrLatestNotAllowed
 	}
 
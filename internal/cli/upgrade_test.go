@@ -99,6 +99,20 @@ func TestParseUpgradeRequest(t *testing.T) {
 			target:  "LATEST",
 			wantErr: ErrLatestNotAllowed,
 		},
+		{
+			name:    "dashboard mode with latest-patch (rejected)",
+			mode:    "dashboard",
+			target:  "latest-patch",
+			wantErr: ErrLatestNotAllowed,
+		},
+		{
+			name:       "manual mode with latest-dashboard (allowed)",
+			mode:       "manual",
+			target:     "latest-dashboard",
+			wantMode:   ModeManual,
+			wantTarget: "latest-dashboard",
+			wantErr:    nil,
+		},
 		{
 			name:       "manual mode with LATEST uppercase (allowed)",
 			mode:       "manual",
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/recovery"
+)
+
+// Exit codes for the outcome of an upgrade job, shared by run --synchronous
+// and run --wait so orchestrators can act on the result either way.
+const (
+	ExitUpgradeSucceeded = 0
+	ExitUpgradeFailed    = 1
+	ExitNeedsConfirm     = 2 // same as a declined non-interactive confirmation
+	ExitUpgradeCancelled = 3
+	ExitWaitTimeout      = 4 // the job was still running when --wait-timeout expired
+	ExitUpgradeRetryable = 5 // failed before anything was changed; safe to retry
+	ExitUpgradeDataRisk  = 6 // failed after the container was changed; recover or restore
+)
+
+// ErrWaitTimeout is returned by JobWaiter.Wait when the job has not finished
+// within the timeout. The job keeps running in the daemon.
+var ErrWaitTimeout = errors.New("timed out waiting for the upgrade job to finish")
+
+// JobWaiter polls the daemon's /upgrade/status until a job finishes, printing
+// the job's log lines from the /upgrade/logs stream as they are written.
+type JobWaiter struct {
+	BaseURL  string        // e.g. http://127.0.0.1:2359
+	Client   *http.Client  // defaults to http.DefaultClient
+	Interval time.Duration // between polls, defaults to 2s
+	Timeout  time.Duration // 0 waits forever
+	Output   *Output       // log lines go to Stdout, defaults to Std
+}
+
+// Wait blocks until the job with jobID reaches a terminal state and returns
+// it. Transient poll failures are reported and retried; the wait only gives up
+// on timeout, or if the daemon reports a different job as the latest.
+func (w *JobWaiter) Wait(jobID string) (*jobs.Job, error) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	var deadline time.Time
+	if w.Timeout > 0 {
+		deadline = time.Now().Add(w.Timeout)
+	}
+
+	logs := &LogFollower{BaseURL: w.BaseURL, Client: w.Client, JobID: jobID, Retry: interval, Output: w.output()}
+	stopLogs := w.followLogs(logs)
+	defer stopLogs()
+
+	for {
+		job, err := w.fetchStatus()
+		if err != nil {
+			w.output().Warnf("failed to fetch job status: %v\n", err)
+		} else if job.JobID != jobID {
+			return nil, fmt.Errorf("job %s is no longer the latest job (latest: %s, state=%s)", jobID, job.JobID, job.State)
+		} else if IsJobFinished(job) {
+			// Pick up the lines written after the stream was last read
+			stopLogs()
+			logs.Flush()
+			return job, nil
+		}
+
+		sleep := interval
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, ErrWaitTimeout
+			}
+			if remaining < sleep {
+				sleep = remaining
+			}
+		}
+		time.Sleep(sleep)
+	}
+}
+
+// followLogs runs logs.Follow in the background and returns a function that
+// stops it and waits for it to return. Logs are only progress output, so a
+// failed first connection is retried too. The returned function may be
+// called more than once.
+func (w *JobWaiter) followLogs(logs *LogFollower) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for logs.Follow(ctx) != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(logs.Retry):
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// IsJobFinished reports whether job has reached a terminal state: FAILED,
+// CANCELLED, or READY after it has run.
+func IsJobFinished(job *jobs.Job) bool {
+	switch job.State {
+	case jobs.JobStateFailed, jobs.JobStateCancelled:
+		return true
+	case jobs.JobStateReady:
+		return job.Message != jobs.MessageJobCreated
+	default:
+		return false
+	}
+}
+
+// JobExitCode maps a finished job to the run command's exit code: a failed
+// job exits with FailureExitCode of its failure code.
+func JobExitCode(job *jobs.Job) int {
+	switch job.State {
+	case jobs.JobStateReady:
+		return ExitUpgradeSucceeded
+	case jobs.JobStateCancelled:
+		return ExitUpgradeCancelled
+	default:
+		return FailureExitCode(job.FailureCode)
+	}
+}
+
+// FailureExitCode maps an upgrade failure code to an exit code by its
+// recovery playbook: ExitUpgradeRetryable for a retryable failure,
+// ExitUpgradeDataRisk when the container or data may have been changed, and
+// ExitUpgradeFailed for the rest, including codes without a playbook.
+func FailureExitCode(code string) int {
+	if !recovery.IsKnownCode(code) {
+		return ExitUpgradeFailed
+	}
+	switch {
+	case recovery.IsRetryable(code):
+		return ExitUpgradeRetryable
+	case recovery.HasDataRisk(code):
+		return ExitUpgradeDataRisk
+	default:
+		return ExitUpgradeFailed
+	}
+}
+
+func (w *JobWaiter) fetchStatus() (*jobs.Job, error) {
+	body, err := w.get("/upgrade/status")
+	if err != nil {
+		return nil, err
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(body, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse status response: %w", err)
+	}
+	return &job, nil
+}
+
+func (w *JobWaiter) get(path string) ([]byte, error) {
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(w.BaseURL + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, path)
+	}
+	return body, nil
+}
+
+func (w *JobWaiter) output() *Output {
+	if w.Output == nil {
+		return Std
+	}
+	return w.Output
+}
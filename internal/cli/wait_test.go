@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+// fakeDaemon serves /upgrade/status and /upgrade/logs from a scripted job
+// whose state advances by one step on every status poll. Its log is a single
+// file that is never rotated.
+type fakeDaemon struct {
+	mu    sync.Mutex
+	steps []jobs.Job // returned in order, the last one repeated
+	logs  []string   // logs[i] is appended when steps[i] is returned
+	polls int
+	log   strings.Builder
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/upgrade/status":
+		i := d.polls
+		if i >= len(d.steps) {
+			i = len(d.steps) - 1
+		} else if i < len(d.logs) {
+			d.log.WriteString(d.logs[i])
+		}
+		d.polls++
+		json.NewEncoder(w).Encode(d.steps[i])
+	case "/upgrade/logs":
+		cursor, _ := jobs.ParseLogCursor(r.URL.Query().Get("after"))
+		if cursor.File != 1 {
+			cursor = jobs.LogCursor{File: 1}
+		}
+		w.Header().Set(jobs.LogCursorHeader, cursor.String())
+		w.Write([]byte(d.log.String()[cursor.Offset:]))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestWaiter returns a waiter polling d every millisecond, and the buffer
+// its streamed log lines are written to.
+func newTestWaiter(t *testing.T, d *fakeDaemon) (*JobWaiter, *bytes.Buffer) {
+	t.Helper()
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	out, stdout, _ := newTestOutput(VerbosityNormal)
+	return &JobWaiter{BaseURL: srv.URL, Interval: time.Millisecond, Output: out}, stdout
+}
+
+func TestJobWaiter_BlocksUntilSuccess(t *testing.T) {
+	d := &fakeDaemon{
+		steps: []jobs.Job{
+			{JobID: "job-1", State: jobs.JobStateReady, Message: jobs.MessageJobCreated},
+			{JobID: "job-1", State: jobs.JobStateBackingUp},
+			{JobID: "job-1", State: jobs.JobStateExecuting},
+			{JobID: "job-1", State: jobs.JobStateReady, Message: "Upgrade completed successfully"},
+		},
+		logs: []string{
+			"Starting upgrade job job-1: mode=MANUAL target=1.2.3\n",
+			"Creating database backup\n",
+			"Pulling image\n",
+			"SUCCESS: Upgrade to 1.2.3 completed successfully\n",
+		},
+	}
+	d.log.WriteString("Starting upgrade job job-0: mode=MANUAL target=1.2.2\nold job line\n")
+	waiter, stdout := newTestWaiter(t, d)
+
+	job, err := waiter.Wait("job-1")
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	if d.polls != 4 {
+		t.Errorf("expected Wait to poll until the 4th status, polled %d times", d.polls)
+	}
+	if got := JobExitCode(job); got != ExitUpgradeSucceeded {
+		t.Errorf("expected exit code %d, got %d", ExitUpgradeSucceeded, got)
+	}
+	streamed := stdout.String()
+	if strings.Contains(streamed, "old job line") {
+		t.Errorf("expected only the waited-for job's log lines, got %q", streamed)
+	}
+	for _, line := range d.logs {
+		if strings.Count(streamed, line) != 1 {
+			t.Errorf("expected %q streamed exactly once, got %q", line, streamed)
+		}
+	}
+}
+
+func TestJobWaiter_ExitCodeFollowsOutcome(t *testing.T) {
+	tests := []struct {
+		name  string
+		final jobs.Job
+		want  int
+	}{
+		{"failed", jobs.Job{JobID: "job-1", State: jobs.JobStateFailed, FailureCode: "DOCKER_TOO_OLD"}, ExitUpgradeFailed},
+		{"retryable", jobs.Job{JobID: "job-1", State: jobs.JobStateFailed, FailureCode: "DOCKER_PULL_FAILED"}, ExitUpgradeRetryable},
+		{"data risk", jobs.Job{JobID: "job-1", State: jobs.JobStateFailed, FailureCode: "HEALTHCHECK_FAILED"}, ExitUpgradeDataRisk},
+		{"unknown code", jobs.Job{JobID: "job-1", State: jobs.JobStateFailed, FailureCode: "SOMETHING_NEW"}, ExitUpgradeFailed},
+		{"cancelled", jobs.Job{JobID: "job-1", State: jobs.JobStateCancelled}, ExitUpgradeCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDaemon{steps: []jobs.Job{
+				{JobID: "job-1", State: jobs.JobStateExecuting},
+				tt.final,
+			}}
+			waiter, _ := newTestWaiter(t, d)
+
+			job, err := waiter.Wait("job-1")
+			if err != nil {
+				t.Fatalf("Wait failed: %v", err)
+			}
+			if job.FailureCode != tt.final.FailureCode {
+				t.Errorf("expected failure code %q, got %q", tt.final.FailureCode, job.FailureCode)
+			}
+			if got := JobExitCode(job); got != tt.want {
+				t.Errorf("expected exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJobWaiter_TimesOutWhileRunning(t *testing.T) {
+	d := &fakeDaemon{steps: []jobs.Job{{JobID: "job-1", State: jobs.JobStateExecuting}}}
+	waiter, _ := newTestWaiter(t, d)
+	waiter.Interval = 5 * time.Millisecond
+	waiter.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := waiter.Wait("job-1")
+
+	if !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected ErrWaitTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected Wait to block for the timeout, returned after %s", elapsed)
+	}
+}
+
+func TestJobWaiter_FailsWhenJobReplaced(t *testing.T) {
+	d := &fakeDaemon{steps: []jobs.Job{{JobID: "job-2", State: jobs.JobStateExecuting}}}
+	waiter, _ := newTestWaiter(t, d)
+
+	if _, err := waiter.Wait("job-1"); err == nil || !strings.Contains(err.Error(), "job-2") {
+		t.Errorf("expected an error naming the latest job, got %v", err)
+	}
+}
+
+func TestIsJobFinished_CreatedJobIsNotFinished(t *testing.T) {
+	if IsJobFinished(&jobs.Job{State: jobs.JobStateReady, Message: jobs.MessageJobCreated}) {
+		t.Error("expected a just-created READY job not to be finished")
+	}
+	if !IsJobFinished(&jobs.Job{State: jobs.JobStateReady, Message: "Upgrade completed successfully"}) {
+		t.Error("expected a completed READY job to be finished")
+	}
+}
@@ -1,22 +1,50 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/policy"
 )
 
 // BackupConfig holds configuration for database backups.
 // Backups are always enabled.
 type BackupConfig struct {
-	Dir        string
-	Retention  int
-	PGHost     string
-	PGPort     int
-	PGDB       string
-	PGUser     string
-	PGPassword string
+	Dir             string
+	Retention       int
+	MaxAgeHours     int // inspect warns when the newest backup is older than this (0 disables the age warning)
+	PGHost          string
+	PGPort          int
+	PGDB            string
+	PGUser          string
+	PGPassword      string
+	PGSuperUser     string // Optional: role restores connect as instead of the app user, for pg_restore --clean
+	PGSuperPassword string // Optional: PGSuperUser's password (external databases only)
+	PreHook         string // Optional: command or http(s) URL run before each pre-upgrade backup
+	PostHook        string // Optional: command or http(s) URL run after each pre-upgrade backup, even on failure
+
+	Strategy                string // "dump" (default), "snapshot" or "both"
+	SnapshotCommand         string // Command taking a volume snapshot; {name} is replaced with the snapshot name
+	SnapshotRollbackCommand string // Command restoring a snapshot backup; {id} is replaced with the snapshot ID
+
+	DumpFormat string // pg_dump format of every backup: "custom" (default), "plain" or "directory"
+
+	Database       string   // Optional: database dumped instead of the container's configured one
+	IncludeSchemas []string // Optional: only these schemas are dumped
+	ExcludeSchemas []string // Optional: these schemas are not dumped
+
+	PerDatabaseDirs   bool           // Write each dump to a <Dir>/<database>/ subdirectory
+	RequireMount      bool           // Fail backups with BACKUP_MOUNT_MISSING when Dir is on the root filesystem
+	VerifyAfterCreate bool           // Check the pre-upgrade dump is restorable before the container is changed
+	DatabaseRetention map[string]int // Per-database Retention overrides (PerDatabaseDirs only)
 }
 
 const (
@@ -25,6 +53,9 @@ const (
 	DefaultAutoUpdateEnabled = false
 	// DefaultAutoUpdateIntervalHours is the default check interval in hours.
 	DefaultAutoUpdateIntervalHours = 24
+	// DefaultAutoUpdateMaxAttempts is how many failed auto-update jobs a
+	// target version gets by default before auto-update skips it.
+	DefaultAutoUpdateMaxAttempts = 3
 )
 
 // Config holds all configuration for the payram-updater service.
@@ -33,78 +64,207 @@ const (
 // via Docker inspection and overlaid with manifest settings. Only job state,
 // logs, and backups are persisted.
 type Config struct {
-	Port                 int
-	PolicyURL            string
-	RuntimeManifestURL   string
-	FetchTimeoutSeconds  int
-	StateDir             string // For job state persistence only
-	CoreBaseURL          string
-	ExecutionMode        string
-	DockerBin            string
-	TargetContainerName  string // Optional: overrides manifest container_name
-	ImageRepoOverride    string // Optional: for testing with different image repos (e.g., payram-dummy)
-	DebugVersionMode     bool   // When true, allows arbitrary version names and uses release list ordering
-	AutoUpdateEnabled    bool
-	AutoUpdateInterval   int // Hours
-	BackupTimeoutSeconds int // Timeout for pre-upgrade backup operations (default 600s)
-	SupervisorExclude    []string
-	SupervisorInclude    []string
-	Backup               BackupConfig
+	Port                      int
+	PolicyURL                 string
+	RuntimeManifestURL        string
+	FetchTimeoutSeconds       int
+	StateDir                  string // For job state persistence only
+	CoreBaseURL               string
+	VerifyURL                 string // Optional: Payram base URL (e.g. an HA virtual IP) verified after an upgrade as well as the local one
+	ExecutionMode             string
+	DockerBin                 string
+	TargetContainerName       string // Optional: overrides manifest container_name
+	ImageRepoOverride         string // Optional: for testing with different image repos (e.g., payram-dummy)
+	ImageTagTemplate          string // Optional: image tag for a version, e.g. "{version}-{env}" (empty tags with the bare version)
+	ImageEnv                  string // Substituted for {env} in ImageTagTemplate
+	DebugVersionMode          bool   // When true, allows arbitrary version names and uses release list ordering
+	AutoUpdateEnabled         bool
+	AutoUpdateInterval        int  // Hours
+	AutoUpdateMaxAttempts     int  // Failed auto-update jobs per target version before it is skipped (set from updater-config.json)
+	BackupScheduleEnabled     bool // Set from updater-config.json by `backup schedule`
+	BackupScheduleInterval    int  // Minutes between scheduled backups
+	BackupTimeoutSeconds      int  // Timeout for pre-upgrade backup operations (default 600s)
+	SupervisorExclude         []string
+	SupervisorInclude         []string
+	VerifyPausePrograms       []string // Optional: supervisor programs kept stopped in the new container until verification passes
+	VerifyPauseHoldFile       string   // supervisord include copied into the new container so VerifyPausePrograms do not autostart
+	RestoreConfirmPhrase      string   // Text to type to confirm a backup restore (default "yes")
+	AllowedCIDRs              []string // Extra CIDR ranges allowed to reach the API (in addition to localhost and the Payram container)
+	HealthPort                int      // Optional: also serve /health and /livez alone on this port (0 disables)
+	HealthBindAddress         string   // Address the HealthPort listener binds to (default 0.0.0.0)
+	HealthAllowedCIDRs        []string // Extra CIDR ranges allowed to reach the HealthPort listener only
+	AllowedImageRepos         []string // Optional: image repos the manifest may point at; empty allows any
+	IdleTimeoutSeconds        int      // Optional: daemon exits after this long with no job or API activity (0 disables)
+	AllowedExtraRunFlags      []string // Optional: manifest extra_run_args flags permitted beyond the built-in allowlist
+	UpgradeTimeoutSeconds     int      // Overall upgrade deadline, excluding health retries (0 disables)
+	MigrationMaxWaitSeconds   int      // Longest the health wait is extended for a running migration (0 disables migration monitoring)
+	MigrationStallSeconds     int      // How long one session must stay blocked or idle in its transaction for MIGRATION_STALLED
+	MinUpgradeIntervalMinutes int      // Optional: runs are refused this soon after the last successful upgrade (0 disables)
+	ThrowawayMaxAgeMinutes    int      // Daemon startup removes leaked throwaway containers (e.g. migration checks) without a creator PID older than this (0 disables)
+	TelemetryEnabled          bool     // Opt-in: report anonymized upgrade outcomes to TelemetryURL
+	TelemetryURL              string   // Endpoint receiving telemetry events (required when enabled)
+	Profile                   string   // Active profile, empty when none is selected
+	ReportDir                 string   // Optional: directory receiving a report file per upgrade (empty disables)
+	ReportFormat              string   // Report file format: "json" (default) or "markdown"
+	ResumeInterruptedUpgrades bool     // Opt-in: at startup, resume an upgrade interrupted before the container was stopped
+	HotSwapUpgrades           bool     // Experimental opt-in: replace the container by rename for upgrades the manifest marks hot_swap
+	SerializeOperations       bool     // Opt-in: run upgrades, auto-updates and scheduled backups one at a time, in FIFO order
+	PermissionWarnings        []string // Config file permission problems, for the caller to report (empty when the files are safe)
+	JobLogMaxSizeMB           int      // Job log size at which it is rotated (0 disables rotation)
+	JobLogMaxFiles            int      // Rotated job log files kept
+	LatestStrategy            string   // What a "latest" target and auto-update resolve to: "latest" (default), "latest-patch" or "latest-dashboard"
+	TLSCAFile                 string   // Optional: PEM CA bundle trusted for outbound HTTPS in addition to the system roots
+	Backup                    BackupConfig
+}
+
+// DefaultFilePath is the env file read when UPDATER_CONFIG_FILE is not set.
+const DefaultFilePath = "/etc/payram/updater.env"
+
+// FilePath returns the env file Load reads: UPDATER_CONFIG_FILE if set
+// (the CLI's --config flag sets it), otherwise DefaultFilePath.
+func FilePath() string {
+	if path := os.Getenv("UPDATER_CONFIG_FILE"); path != "" {
+		return path
+	}
+	return DefaultFilePath
 }
 
 // Load reads configuration with the following precedence order:
 //  1. OS environment variables (highest priority)
-//  2. .env file in current working directory (if present)
-//  3. /etc/payram/updater.env (if present)
-//  4. Default values (lowest priority)
+//  2. Settings of the active profile (UPDATER_PROFILE), if one is selected
+//  3. .env file in current working directory (if present)
+//  4. FilePath(): UPDATER_CONFIG_FILE, or /etc/payram/updater.env (if present)
+//  5. Default values (lowest priority)
 //
-// Required fields are validated.
+// An explicitly configured UPDATER_CONFIG_FILE must exist, and so must an
+// explicitly selected profile. Required fields are validated. A config file
+// that cannot be read is an error; an unsafe one (see
+// envFilePermissionProblem) is returned in PermissionWarnings for the
+// caller to report, or refused when STRICT_CONFIG_PERMISSIONS is true.
 func Load() (*Config, error) {
+	explicit := environKeys()
+
 	// Load config files in reverse precedence order (lowest to highest priority)
 	// so that higher priority sources can override lower priority ones.
 
-	// Try to load from /etc/payram/updater.env if it exists (lowest priority file)
-	etcEnvFilePath := "/etc/payram/updater.env"
-	if _, err := os.Stat(etcEnvFilePath); err == nil {
-		if err := loadEnvFile(etcEnvFilePath); err != nil {
+	// Try to load the updater env file if it exists (lowest priority file)
+	var permissionProblems []string
+	envFilePath := FilePath()
+	if info, err := os.Stat(envFilePath); err == nil {
+		if err := loadEnvFile(envFilePath); err != nil {
 			return nil, fmt.Errorf("failed to load env file: %w", err)
 		}
+		if problem := envFilePermissionProblem(envFilePath, info); problem != "" {
+			permissionProblems = append(permissionProblems, problem)
+		}
+	} else if errors.Is(err, fs.ErrPermission) {
+		return nil, unreadableEnvFileError(envFilePath, err)
+	} else if envFilePath != DefaultFilePath {
+		return nil, fmt.Errorf("config file %s: %w", envFilePath, err)
 	}
 
 	// Try to load from .env in current working directory if it exists (higher priority)
 	cwdEnvFilePath := ".env"
-	if _, err := os.Stat(cwdEnvFilePath); err == nil {
+	if info, err := os.Stat(cwdEnvFilePath); err == nil {
 		if err := loadEnvFile(cwdEnvFilePath); err != nil {
 			return nil, fmt.Errorf("failed to load .env file: %w", err)
 		}
+		if problem := envFilePermissionProblem(cwdEnvFilePath, info); problem != "" {
+			permissionProblems = append(permissionProblems, problem)
+		}
+	}
+
+	// Overlay the active profile, which may also be selected by a config file
+	profile := os.Getenv(ProfileEnvVar)
+	if profile != "" {
+		if err := applyProfile(profile, explicit); err != nil {
+			return nil, err
+		}
 	}
 
+	// Unsafe config files are refused only once the files themselves could
+	// have set STRICT_CONFIG_PERMISSIONS
+	if len(permissionProblems) > 0 && getEnvString("STRICT_CONFIG_PERMISSIONS", "") == "true" {
+		return nil, fmt.Errorf("%s (refused because STRICT_CONFIG_PERMISSIONS is true)", permissionProblems[0])
+	}
 	// Build config from environment variables (OS env vars have highest priority)
 	cfg := &Config{
-		Port:                 getEnvInt("UPDATER_PORT", 2567),
-		PolicyURL:            os.Getenv("POLICY_URL"),
-		RuntimeManifestURL:   os.Getenv("RUNTIME_MANIFEST_URL"),
-		FetchTimeoutSeconds:  getEnvInt("FETCH_TIMEOUT_SECONDS", 10),
-		StateDir:             getEnvString("STATE_DIR", "/var/lib/payram-updater"),
-		CoreBaseURL:          os.Getenv("CORE_BASE_URL"), // Optional: will be discovered if not provided
-		ExecutionMode:        getEnvString("EXECUTION_MODE", "dry-run"),
-		DockerBin:            getEnvString("DOCKER_BIN", "docker"),
-		TargetContainerName:  os.Getenv("TARGET_CONTAINER_NAME"), // Optional: no default
-		ImageRepoOverride:    os.Getenv("IMAGE_REPO_OVERRIDE"),   // Optional: for testing (e.g., "payram-dummy")
-		DebugVersionMode:     getEnvString("DEBUG_VERSION_MODE", "") == "true",
-		AutoUpdateEnabled:    DefaultAutoUpdateEnabled,
-		AutoUpdateInterval:   DefaultAutoUpdateIntervalHours,
-		BackupTimeoutSeconds: getEnvInt("BACKUP_TIMEOUT_SECONDS", 600),
-		SupervisorExclude:    parseCSV(getEnvString("SUPERVISOR_EXCLUDE", "postgres,postgresql")),
-		SupervisorInclude:    parseCSV(os.Getenv("SUPERVISOR_INCLUDE")),
+		Port:                      getEnvInt("UPDATER_PORT", 2567),
+		PolicyURL:                 os.Getenv("POLICY_URL"),
+		RuntimeManifestURL:        os.Getenv("RUNTIME_MANIFEST_URL"),
+		FetchTimeoutSeconds:       getEnvInt("FETCH_TIMEOUT_SECONDS", 10),
+		StateDir:                  getEnvString("STATE_DIR", "/var/lib/payram-updater"),
+		CoreBaseURL:               os.Getenv("CORE_BASE_URL"), // Optional: will be discovered if not provided
+		VerifyURL:                 os.Getenv("VERIFY_URL"),
+		ExecutionMode:             getEnvString("EXECUTION_MODE", "dry-run"),
+		DockerBin:                 getEnvString("DOCKER_BIN", "docker"),
+		TargetContainerName:       os.Getenv("TARGET_CONTAINER_NAME"), // Optional: no default
+		ImageRepoOverride:         os.Getenv("IMAGE_REPO_OVERRIDE"),   // Optional: for testing (e.g., "payram-dummy")
+		ImageTagTemplate:          os.Getenv("IMAGE_TAG_TEMPLATE"),
+		ImageEnv:                  os.Getenv("IMAGE_ENV"),
+		DebugVersionMode:          getEnvString("DEBUG_VERSION_MODE", "") == "true",
+		AutoUpdateEnabled:         DefaultAutoUpdateEnabled,
+		AutoUpdateInterval:        DefaultAutoUpdateIntervalHours,
+		AutoUpdateMaxAttempts:     DefaultAutoUpdateMaxAttempts,
+		BackupTimeoutSeconds:      getEnvInt("BACKUP_TIMEOUT_SECONDS", 600),
+		SupervisorExclude:         parseCSV(getEnvString("SUPERVISOR_EXCLUDE", "postgres,postgresql")),
+		SupervisorInclude:         parseCSV(os.Getenv("SUPERVISOR_INCLUDE")),
+		VerifyPausePrograms:       parseCSV(os.Getenv("VERIFY_PAUSE_PROGRAMS")),
+		VerifyPauseHoldFile:       getEnvString("VERIFY_PAUSE_HOLD_FILE", "/etc/supervisor/conf.d/zz-payram-updater-hold.conf"),
+		RestoreConfirmPhrase:      strings.TrimSpace(getEnvString("RESTORE_CONFIRM_PHRASE", "yes")),
+		AllowedCIDRs:              parseCSV(os.Getenv("ALLOWED_CIDRS")),
+		HealthPort:                getEnvInt("HEALTH_PORT", 0),
+		HealthBindAddress:         getEnvString("HEALTH_BIND_ADDRESS", "0.0.0.0"),
+		HealthAllowedCIDRs:        parseCSV(os.Getenv("HEALTH_ALLOWED_CIDRS")),
+		AllowedImageRepos:         parseCSV(os.Getenv("ALLOWED_IMAGE_REPOS")),
+		IdleTimeoutSeconds:        getEnvInt("IDLE_TIMEOUT_SECONDS", 0),
+		AllowedExtraRunFlags:      parseCSV(os.Getenv("ALLOWED_EXTRA_RUN_FLAGS")),
+		UpgradeTimeoutSeconds:     getEnvInt("UPGRADE_TIMEOUT_SECONDS", 3600),
+		MigrationMaxWaitSeconds:   getEnvInt("MIGRATION_MAX_WAIT_SECONDS", 900),
+		MigrationStallSeconds:     getEnvInt("MIGRATION_STALL_SECONDS", 60),
+		MinUpgradeIntervalMinutes: getEnvInt("MIN_UPGRADE_INTERVAL_MINUTES", 0),
+		ThrowawayMaxAgeMinutes:    getEnvInt("THROWAWAY_CONTAINER_MAX_AGE_MINUTES", 60),
+		TelemetryEnabled:          getEnvString("TELEMETRY_ENABLED", "") == "true",
+		TelemetryURL:              os.Getenv("TELEMETRY_URL"),
+		Profile:                   profile,
+		ReportDir:                 os.Getenv("REPORT_DIR"),
+		ReportFormat:              getEnvString("REPORT_FORMAT", "json"),
+		JobLogMaxSizeMB:           getEnvInt("JOB_LOG_MAX_SIZE_MB", 10),
+		JobLogMaxFiles:            getEnvInt("JOB_LOG_MAX_FILES", 3),
+		LatestStrategy:            strings.ToLower(getEnvString("LATEST_STRATEGY", policy.StrategyLatest)),
+		TLSCAFile:                 os.Getenv("TLS_CA_FILE"),
+		ResumeInterruptedUpgrades: getEnvString("RESUME_INTERRUPTED_UPGRADES", "") == "true",
+		HotSwapUpgrades:           getEnvString("HOT_SWAP_UPGRADES", "") == "true",
+		SerializeOperations:       getEnvString("SERIALIZE_OPERATIONS", "") == "true",
+		PermissionWarnings:        permissionProblems,
 		Backup: BackupConfig{
-			Dir:        getEnvString("BACKUP_DIR", "data/backups"),
-			Retention:  getEnvInt("BACKUP_RETENTION", 10),
-			PGHost:     getEnvString("PG_HOST", "127.0.0.1"),
-			PGPort:     getEnvInt("PG_PORT", 5432),
-			PGDB:       getEnvString("PG_DB", "payram"),
-			PGUser:     getEnvString("PG_USER", "payram"),
-			PGPassword: getEnvString("PG_PASSWORD", ""),
+			Dir:             getEnvString("BACKUP_DIR", "data/backups"),
+			Retention:       getEnvInt("BACKUP_RETENTION", 10),
+			MaxAgeHours:     getEnvInt("BACKUP_MAX_AGE_HOURS", 168),
+			PGHost:          getEnvString("PG_HOST", "127.0.0.1"),
+			PGPort:          getEnvInt("PG_PORT", 5432),
+			PGDB:            getEnvString("PG_DB", "payram"),
+			PGUser:          getEnvString("PG_USER", "payram"),
+			PGPassword:      getEnvString("PG_PASSWORD", ""),
+			PGSuperUser:     os.Getenv("PG_SUPERUSER"),
+			PGSuperPassword: os.Getenv("PG_SUPERUSER_PASSWORD"),
+			PreHook:         os.Getenv("PRE_BACKUP_HOOK"),
+			PostHook:        os.Getenv("POST_BACKUP_HOOK"),
+
+			Strategy:                getEnvString("BACKUP_STRATEGY", "dump"),
+			SnapshotCommand:         os.Getenv("BACKUP_SNAPSHOT_COMMAND"),
+			SnapshotRollbackCommand: os.Getenv("BACKUP_SNAPSHOT_ROLLBACK_COMMAND"),
+
+			DumpFormat: getEnvString("BACKUP_DUMP_FORMAT", "custom"),
+
+			Database:       os.Getenv("BACKUP_DATABASE"),
+			IncludeSchemas: parseCSV(os.Getenv("BACKUP_INCLUDE_SCHEMAS")),
+			ExcludeSchemas: parseCSV(os.Getenv("BACKUP_EXCLUDE_SCHEMAS")),
+
+			PerDatabaseDirs: getEnvString("BACKUP_PER_DATABASE_DIRS", "") == "true",
+			RequireMount:    getEnvString("BACKUP_REQUIRE_MOUNT", "") == "true",
+
+			VerifyAfterCreate: getEnvString("VERIFY_BACKUP_AFTER_CREATE", "") == "true",
 		},
 	}
 
@@ -121,6 +281,114 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("EXECUTION_MODE must be 'dry-run' or 'execute', got '%s'", cfg.ExecutionMode)
 	}
 
+	for _, cidr := range cfg.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("ALLOWED_CIDRS contains invalid CIDR '%s'", cidr)
+		}
+	}
+
+	if err := (TagTemplate{Template: cfg.ImageTagTemplate, Env: cfg.ImageEnv}).Validate(); err != nil {
+		return nil, fmt.Errorf("IMAGE_TAG_TEMPLATE %s, got '%s'", err, cfg.ImageTagTemplate)
+	}
+
+	if cfg.IdleTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("IDLE_TIMEOUT_SECONDS must be 0 (disabled) or positive, got %d", cfg.IdleTimeoutSeconds)
+	}
+
+	if cfg.UpgradeTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("UPGRADE_TIMEOUT_SECONDS must be 0 (disabled) or positive, got %d", cfg.UpgradeTimeoutSeconds)
+	}
+
+	for _, cidr := range cfg.HealthAllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("HEALTH_ALLOWED_CIDRS contains invalid CIDR '%s'", cidr)
+		}
+	}
+
+	if cfg.HealthPort < 0 || cfg.HealthPort > 65535 {
+		return nil, fmt.Errorf("HEALTH_PORT must be 0 (disabled) or a port number, got %d", cfg.HealthPort)
+	}
+	if cfg.HealthPort != 0 && cfg.HealthPort == cfg.Port {
+		return nil, fmt.Errorf("HEALTH_PORT must differ from UPDATER_PORT (%d)", cfg.Port)
+	}
+
+	if cfg.MigrationMaxWaitSeconds < 0 {
+		return nil, fmt.Errorf("MIGRATION_MAX_WAIT_SECONDS must be 0 (disabled) or positive, got %d", cfg.MigrationMaxWaitSeconds)
+	}
+	if cfg.MigrationStallSeconds < 1 {
+		return nil, fmt.Errorf("MIGRATION_STALL_SECONDS must be at least 1, got %d", cfg.MigrationStallSeconds)
+	}
+
+	if cfg.MinUpgradeIntervalMinutes < 0 {
+		return nil, fmt.Errorf("MIN_UPGRADE_INTERVAL_MINUTES must be 0 (disabled) or positive, got %d", cfg.MinUpgradeIntervalMinutes)
+	}
+	if cfg.ThrowawayMaxAgeMinutes < 0 {
+		return nil, fmt.Errorf("THROWAWAY_CONTAINER_MAX_AGE_MINUTES must be 0 (disabled) or positive, got %d", cfg.ThrowawayMaxAgeMinutes)
+	}
+
+	if cfg.VerifyURL != "" {
+		if u, err := url.Parse(cfg.VerifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("VERIFY_URL must be an http(s) URL, got '%s'", cfg.VerifyURL)
+		}
+	}
+
+	if cfg.TelemetryEnabled {
+		if u, err := url.Parse(cfg.TelemetryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("TELEMETRY_URL must be an http(s) URL when TELEMETRY_ENABLED is true, got '%s'", cfg.TelemetryURL)
+		}
+	}
+
+	if cfg.ReportFormat != "json" && cfg.ReportFormat != "markdown" {
+		return nil, fmt.Errorf("REPORT_FORMAT must be 'json' or 'markdown', got '%s'", cfg.ReportFormat)
+	}
+
+	if cfg.JobLogMaxSizeMB < 0 {
+		return nil, fmt.Errorf("JOB_LOG_MAX_SIZE_MB must be 0 (disabled) or positive, got %d", cfg.JobLogMaxSizeMB)
+	}
+
+	if cfg.JobLogMaxFiles < 1 {
+		return nil, fmt.Errorf("JOB_LOG_MAX_FILES must be at least 1, got %d", cfg.JobLogMaxFiles)
+	}
+
+	if !policy.IsStrategy(cfg.LatestStrategy) {
+		return nil, fmt.Errorf("LATEST_STRATEGY must be one of %s, got '%s'", strings.Join(policy.Strategies, ", "), cfg.LatestStrategy)
+	}
+
+	if cfg.Backup.MaxAgeHours < 0 {
+		return nil, fmt.Errorf("BACKUP_MAX_AGE_HOURS must be 0 (disabled) or positive, got %d", cfg.Backup.MaxAgeHours)
+	}
+
+	switch cfg.Backup.Strategy {
+	case "dump":
+	case "snapshot", "both":
+		if cfg.Backup.SnapshotCommand == "" {
+			return nil, fmt.Errorf("BACKUP_SNAPSHOT_COMMAND is required when BACKUP_STRATEGY is '%s'", cfg.Backup.Strategy)
+		}
+	default:
+		return nil, fmt.Errorf("BACKUP_STRATEGY must be 'dump', 'snapshot' or 'both', got '%s'", cfg.Backup.Strategy)
+	}
+
+	switch cfg.Backup.DumpFormat {
+	case "custom", "plain", "directory":
+	default:
+		return nil, fmt.Errorf("BACKUP_DUMP_FORMAT must be 'custom', 'plain' or 'directory', got '%s'", cfg.Backup.DumpFormat)
+	}
+
+	for _, schema := range cfg.Backup.ExcludeSchemas {
+		if slices.Contains(cfg.Backup.IncludeSchemas, schema) {
+			return nil, fmt.Errorf("BACKUP_EXCLUDE_SCHEMAS must not repeat a schema of BACKUP_INCLUDE_SCHEMAS, got '%s'", schema)
+		}
+	}
+
+	retention, err := parseDatabaseRetention(os.Getenv("BACKUP_DATABASE_RETENTION"))
+	if err != nil {
+		return nil, fmt.Errorf("BACKUP_DATABASE_RETENTION %v", err)
+	}
+	if len(retention) > 0 && !cfg.Backup.PerDatabaseDirs {
+		return nil, fmt.Errorf("BACKUP_DATABASE_RETENTION requires BACKUP_PER_DATABASE_DIRS=true")
+	}
+	cfg.Backup.DatabaseRetention = retention
+
 	if cfg.AutoUpdateEnabled && cfg.AutoUpdateInterval < 1 {
 		return nil, fmt.Errorf("AUTO_UPDATE_INTERVAL_HOURS must be at least 1 when auto update is enabled, got %d", cfg.AutoUpdateInterval)
 	}
@@ -163,3 +431,31 @@ func parseCSV(value string) []string {
 	}
 	return result
 }
+
+// parseDatabaseRetention parses a comma-separated list of database=count
+// pairs, e.g. "payram=10,analytics=3". The result is keyed by each database's
+// backup subdirectory name, the name backups are grouped under for pruning.
+func parseDatabaseRetention(value string) (map[string]int, error) {
+	pairs := parseCSV(value)
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]int, len(pairs))
+	for _, pair := range pairs {
+		database, countStr, ok := strings.Cut(pair, "=")
+		database = strings.TrimSpace(database)
+		if !ok || database == "" {
+			return nil, fmt.Errorf("must be database=count pairs, got '%s'", pair)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(countStr))
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("count for '%s' must be at least 1, got '%s'", database, strings.TrimSpace(countStr))
+		}
+		dir := backup.DatabaseDirName(database)
+		if _, dup := result[dir]; dup {
+			return nil, fmt.Errorf("names the backup directory '%s' more than once", dir)
+		}
+		result[dir] = count
+	}
+	return result, nil
+}
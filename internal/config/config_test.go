@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -113,6 +117,9 @@ func TestLoad_BackupDefaults(t *testing.T) {
 	if cfg.Backup.Retention != 10 {
 		t.Errorf("expected default retention 10, got %d", cfg.Backup.Retention)
 	}
+	if cfg.Backup.MaxAgeHours != 168 {
+		t.Errorf("expected default backup max age 168 hours, got %d", cfg.Backup.MaxAgeHours)
+	}
 	if cfg.Backup.PGHost != "127.0.0.1" {
 		t.Errorf("expected default PG_HOST '127.0.0.1', got %s", cfg.Backup.PGHost)
 	}
@@ -126,3 +133,724 @@ func TestLoad_BackupDefaults(t *testing.T) {
 		t.Errorf("expected default PG_USER 'payram', got %s", cfg.Backup.PGUser)
 	}
 }
+
+func TestLoad_AllowedCIDRs(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+	os.Setenv("ALLOWED_CIDRS", "172.18.0.0/16, fd00::/64")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.AllowedCIDRs) != 2 || cfg.AllowedCIDRs[0] != "172.18.0.0/16" || cfg.AllowedCIDRs[1] != "fd00::/64" {
+		t.Errorf("expected allowed CIDRs [172.18.0.0/16 fd00::/64], got %v", cfg.AllowedCIDRs)
+	}
+}
+
+func TestLoad_AllowedCIDRsInvalid(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+	os.Setenv("ALLOWED_CIDRS", "172.18.0.0/16,172.18.0.5")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected error for invalid CIDR, got nil")
+	}
+	expected := "ALLOWED_CIDRS contains invalid CIDR '172.18.0.5'"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_IdleTimeoutInvalid(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+	os.Setenv("IDLE_TIMEOUT_SECONDS", "-5")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected error for negative IDLE_TIMEOUT_SECONDS, got nil")
+	}
+	expected := "IDLE_TIMEOUT_SECONDS must be 0 (disabled) or positive, got -5"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_HealthPort(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.HealthPort != 0 || cfg.HealthBindAddress != "0.0.0.0" {
+		t.Errorf("expected the health port off and bound to 0.0.0.0 by default, got %d on %s", cfg.HealthPort, cfg.HealthBindAddress)
+	}
+
+	os.Setenv("HEALTH_PORT", "2567")
+	if _, err := Load(); err == nil || err.Error() != "HEALTH_PORT must differ from UPDATER_PORT (2567)" {
+		t.Errorf("expected a HEALTH_PORT clash error, got %v", err)
+	}
+
+	os.Setenv("HEALTH_PORT", "70000")
+	if _, err := Load(); err == nil || err.Error() != "HEALTH_PORT must be 0 (disabled) or a port number, got 70000" {
+		t.Errorf("expected a HEALTH_PORT range error, got %v", err)
+	}
+
+	os.Setenv("HEALTH_PORT", "2568")
+	os.Setenv("HEALTH_ALLOWED_CIDRS", "10.0.0.0/8,10.1.2.3")
+	if _, err := Load(); err == nil || err.Error() != "HEALTH_ALLOWED_CIDRS contains invalid CIDR '10.1.2.3'" {
+		t.Errorf("expected a HEALTH_ALLOWED_CIDRS error, got %v", err)
+	}
+}
+
+func TestLoad_ImageTagTemplate(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+	os.Setenv("IMAGE_TAG_TEMPLATE", "{version}-{env}")
+	os.Setenv("IMAGE_ENV", "prod")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ImageTagTemplate != "{version}-{env}" || cfg.ImageEnv != "prod" {
+		t.Errorf("expected the tag template and env, got %q/%q", cfg.ImageTagTemplate, cfg.ImageEnv)
+	}
+
+	os.Unsetenv("IMAGE_ENV")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for {env} without IMAGE_ENV, got nil")
+	}
+	expected := "IMAGE_TAG_TEMPLATE uses {env} but no environment is set, got '{version}-{env}'"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_UpgradeTimeout(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.UpgradeTimeoutSeconds != 3600 {
+		t.Errorf("expected default UpgradeTimeoutSeconds 3600, got %d", cfg.UpgradeTimeoutSeconds)
+	}
+
+	os.Setenv("UPGRADE_TIMEOUT_SECONDS", "-1")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for negative UPGRADE_TIMEOUT_SECONDS, got nil")
+	}
+	expected := "UPGRADE_TIMEOUT_SECONDS must be 0 (disabled) or positive, got -1"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_MinUpgradeInterval(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MinUpgradeIntervalMinutes != 0 {
+		t.Errorf("expected the minimum interval disabled by default, got %d", cfg.MinUpgradeIntervalMinutes)
+	}
+
+	os.Setenv("MIN_UPGRADE_INTERVAL_MINUTES", "-10")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for negative MIN_UPGRADE_INTERVAL_MINUTES, got nil")
+	}
+	expected := "MIN_UPGRADE_INTERVAL_MINUTES must be 0 (disabled) or positive, got -10"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_MigrationStallSeconds(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MigrationStallSeconds != 60 {
+		t.Errorf("expected 60 seconds by default, got %d", cfg.MigrationStallSeconds)
+	}
+
+	os.Setenv("MIGRATION_STALL_SECONDS", "0")
+	if _, err := Load(); err == nil || err.Error() != "MIGRATION_STALL_SECONDS must be at least 1, got 0" {
+		t.Errorf("expected a MIGRATION_STALL_SECONDS error, got %v", err)
+	}
+}
+
+func TestLoad_JobLogRotation(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.JobLogMaxSizeMB != 10 || cfg.JobLogMaxFiles != 3 {
+		t.Errorf("expected 10 MB and 3 files by default, got %d MB and %d files", cfg.JobLogMaxSizeMB, cfg.JobLogMaxFiles)
+	}
+
+	os.Setenv("JOB_LOG_MAX_SIZE_MB", "-1")
+	if _, err := Load(); err == nil || err.Error() != "JOB_LOG_MAX_SIZE_MB must be 0 (disabled) or positive, got -1" {
+		t.Errorf("expected a JOB_LOG_MAX_SIZE_MB error, got %v", err)
+	}
+
+	os.Setenv("JOB_LOG_MAX_SIZE_MB", "0")
+	os.Setenv("JOB_LOG_MAX_FILES", "0")
+	if _, err := Load(); err == nil || err.Error() != "JOB_LOG_MAX_FILES must be at least 1, got 0" {
+		t.Errorf("expected a JOB_LOG_MAX_FILES error, got %v", err)
+	}
+}
+
+func TestLoad_LatestStrategy(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LatestStrategy != "latest" {
+		t.Errorf("expected the latest strategy by default, got %q", cfg.LatestStrategy)
+	}
+
+	os.Setenv("LATEST_STRATEGY", "Latest-Patch")
+	if cfg, err = Load(); err != nil || cfg.LatestStrategy != "latest-patch" {
+		t.Errorf("expected latest-patch, got %v, %v", cfg, err)
+	}
+
+	os.Setenv("LATEST_STRATEGY", "newest")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LATEST_STRATEGY must be one of latest, latest-patch, latest-dashboard") {
+		t.Errorf("expected a LATEST_STRATEGY error, got %v", err)
+	}
+}
+
+func TestLoad_PerDatabaseBackups(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.PerDatabaseDirs || cfg.Backup.DatabaseRetention != nil {
+		t.Errorf("expected the flat backup layout by default, got %+v", cfg.Backup)
+	}
+
+	os.Setenv("BACKUP_DATABASE_RETENTION", "analytics=3")
+	if _, err := Load(); err == nil || err.Error() != "BACKUP_DATABASE_RETENTION requires BACKUP_PER_DATABASE_DIRS=true" {
+		t.Errorf("expected a BACKUP_PER_DATABASE_DIRS error, got %v", err)
+	}
+
+	os.Setenv("BACKUP_PER_DATABASE_DIRS", "true")
+	os.Setenv("BACKUP_DATABASE_RETENTION", "payram=10, analytics=3")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Backup.PerDatabaseDirs || cfg.Backup.DatabaseRetention["payram"] != 10 || cfg.Backup.DatabaseRetention["analytics"] != 3 {
+		t.Errorf("unexpected per-database backup config: %+v", cfg.Backup)
+	}
+
+	os.Setenv("BACKUP_DATABASE_RETENTION", "team/analytics=3")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.DatabaseRetention["team-analytics"] != 3 {
+		t.Errorf("expected the retention to be keyed by the backup directory name, got %v", cfg.Backup.DatabaseRetention)
+	}
+
+	for _, value := range []string{"analytics", "=3", "analytics=0", "analytics=x", "a/b=1,a-b=2"} {
+		os.Setenv("BACKUP_DATABASE_RETENTION", value)
+		if _, err := Load(); err == nil {
+			t.Errorf("expected BACKUP_DATABASE_RETENTION=%q to be rejected", value)
+		}
+	}
+}
+
+func TestLoad_Telemetry(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TelemetryEnabled {
+		t.Error("expected telemetry to be disabled by default")
+	}
+
+	os.Setenv("TELEMETRY_ENABLED", "true")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for TELEMETRY_ENABLED without TELEMETRY_URL, got nil")
+	}
+	expected := "TELEMETRY_URL must be an http(s) URL when TELEMETRY_ENABLED is true, got ''"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+
+	os.Setenv("TELEMETRY_URL", "https://telemetry.example.com/v1/upgrades")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.TelemetryEnabled || cfg.TelemetryURL != "https://telemetry.example.com/v1/upgrades" {
+		t.Errorf("expected telemetry enabled with URL, got enabled=%v url=%q", cfg.TelemetryEnabled, cfg.TelemetryURL)
+	}
+}
+
+func TestLoad_VerifyURL(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	os.Setenv("VERIFY_URL", "payram.example.com")
+	if _, err := Load(); err == nil || err.Error() != "VERIFY_URL must be an http(s) URL, got 'payram.example.com'" {
+		t.Fatalf("expected an error for a VERIFY_URL without scheme, got %v", err)
+	}
+
+	os.Setenv("VERIFY_URL", "https://payram.example.com")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.VerifyURL != "https://payram.example.com" {
+		t.Errorf("expected VerifyURL to be set, got %q", cfg.VerifyURL)
+	}
+}
+
+func TestLoad_Report(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ReportDir != "" || cfg.ReportFormat != "json" {
+		t.Errorf("expected reports disabled with json format by default, got dir=%q format=%q", cfg.ReportDir, cfg.ReportFormat)
+	}
+
+	os.Setenv("REPORT_DIR", "/var/lib/payram-updater/reports")
+	os.Setenv("REPORT_FORMAT", "markdown")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ReportDir != "/var/lib/payram-updater/reports" || cfg.ReportFormat != "markdown" {
+		t.Errorf("expected configured report dir and format, got dir=%q format=%q", cfg.ReportDir, cfg.ReportFormat)
+	}
+
+	os.Setenv("REPORT_FORMAT", "html")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for unsupported REPORT_FORMAT, got nil")
+	}
+	expected := "REPORT_FORMAT must be 'json' or 'markdown', got 'html'"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_BackupSelection(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.Database != "" || len(cfg.Backup.IncludeSchemas) != 0 || len(cfg.Backup.ExcludeSchemas) != 0 {
+		t.Errorf("expected no backup selection by default, got %+v", cfg.Backup)
+	}
+
+	os.Setenv("BACKUP_DATABASE", "payram")
+	os.Setenv("BACKUP_INCLUDE_SCHEMAS", "public, app")
+	os.Setenv("BACKUP_EXCLUDE_SCHEMAS", "audit")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.Database != "payram" || strings.Join(cfg.Backup.IncludeSchemas, ",") != "public,app" || strings.Join(cfg.Backup.ExcludeSchemas, ",") != "audit" {
+		t.Errorf("unexpected backup selection %+v", cfg.Backup)
+	}
+
+	os.Setenv("BACKUP_EXCLUDE_SCHEMAS", "audit,app")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for a schema both included and excluded, got nil")
+	}
+	expected := "BACKUP_EXCLUDE_SCHEMAS must not repeat a schema of BACKUP_INCLUDE_SCHEMAS, got 'app'"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_BackupStrategy(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.Strategy != "dump" {
+		t.Errorf("expected default BACKUP_STRATEGY dump, got %q", cfg.Backup.Strategy)
+	}
+
+	os.Setenv("BACKUP_STRATEGY", "snapshot")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for snapshot strategy without BACKUP_SNAPSHOT_COMMAND, got nil")
+	}
+	expected := "BACKUP_SNAPSHOT_COMMAND is required when BACKUP_STRATEGY is 'snapshot'"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+
+	os.Setenv("BACKUP_SNAPSHOT_COMMAND", "lvcreate --snapshot --size 5G --name {name} vg0/payram-db")
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	os.Setenv("BACKUP_STRATEGY", "zfs")
+	_, err = Load()
+	if err == nil {
+		t.Fatal("expected error for unknown BACKUP_STRATEGY, got nil")
+	}
+	expected = "BACKUP_STRATEGY must be 'dump', 'snapshot' or 'both', got 'zfs'"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestLoad_BackupDumpFormat(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.DumpFormat != "custom" {
+		t.Errorf("expected default BACKUP_DUMP_FORMAT custom, got %q", cfg.Backup.DumpFormat)
+	}
+
+	os.Setenv("BACKUP_DUMP_FORMAT", "directory")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.DumpFormat != "directory" {
+		t.Errorf("expected BACKUP_DUMP_FORMAT directory, got %q", cfg.Backup.DumpFormat)
+	}
+
+	os.Setenv("BACKUP_DUMP_FORMAT", "tar")
+	_, err = Load()
+	expected := "BACKUP_DUMP_FORMAT must be 'custom', 'plain' or 'directory', got 'tar'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func TestLoad_AlternateConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updater.env")
+	content := `POLICY_URL=https://example.com/policy
+RUNTIME_MANIFEST_URL=https://example.com/manifest
+UPDATER_PORT=3001
+EXECUTION_MODE=execute`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", path)
+	os.Setenv("UPDATER_PORT", "4001")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PolicyURL != "https://example.com/policy" {
+		t.Errorf("expected PolicyURL from alternate file, got %q", cfg.PolicyURL)
+	}
+	if cfg.ExecutionMode != "execute" {
+		t.Errorf("expected ExecutionMode from alternate file, got %q", cfg.ExecutionMode)
+	}
+	if cfg.Port != 4001 {
+		t.Errorf("expected env UPDATER_PORT to win over file, got %d", cfg.Port)
+	}
+}
+
+func TestLoad_AlternateConfigFileMissing(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", filepath.Join(t.TempDir(), "missing.env"))
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for missing UPDATER_CONFIG_FILE, got nil")
+	}
+}
+
+// writeConfigFile writes a minimal config file with extra lines and mode.
+func writeConfigFile(t *testing.T, extra string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "updater.env")
+	content := "POLICY_URL=https://example.com/policy\nRUNTIME_MANIFEST_URL=https://example.com/manifest\n" + extra
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	// WriteFile's mode is subject to the umask
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatalf("failed to chmod config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_ConfigFilePermissionWarnings(t *testing.T) {
+	tests := []struct {
+		name    string
+		extra   string
+		mode    os.FileMode
+		warning string // substring of the expected warning, "" for none
+	}{
+		{"private file with secrets", "PG_PASSWORD=hunter2\n", 0600, ""},
+		{"world-readable file without secrets", "", 0644, ""},
+		{"world-readable file with secrets", "PG_PASSWORD=hunter2\n", 0644, "holds secrets but is readable by other users (mode 0644)"},
+		{"group-readable profile secret", "PROFILE_PROD_PG_PASSWORD=hunter2\n", 0640, "holds secrets"},
+		{"group-writable file", "", 0664, "is writable by other users (mode 0664)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			path := writeConfigFile(t, tt.extra, tt.mode)
+			os.Setenv("UPDATER_CONFIG_FILE", path)
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.warning == "" {
+				if len(cfg.PermissionWarnings) != 0 {
+					t.Errorf("expected no warnings, got %v", cfg.PermissionWarnings)
+				}
+				return
+			}
+			if len(cfg.PermissionWarnings) != 1 || !strings.Contains(cfg.PermissionWarnings[0], tt.warning) || !strings.Contains(cfg.PermissionWarnings[0], path) {
+				t.Errorf("expected a warning containing %q for %s, got %v", tt.warning, path, cfg.PermissionWarnings)
+			}
+		})
+	}
+}
+
+func TestLoad_StrictConfigPermissionsRefuses(t *testing.T) {
+	// The strict setting may come from the checked file itself
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", writeConfigFile(t, "PG_PASSWORD=hunter2\nSTRICT_CONFIG_PERMISSIONS=true\n", 0644))
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "holds secrets but is readable by other users") || !strings.Contains(err.Error(), "STRICT_CONFIG_PERMISSIONS") {
+		t.Fatalf("expected a strict permission error, got %v", err)
+	}
+
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", writeConfigFile(t, "PG_PASSWORD=hunter2\nSTRICT_CONFIG_PERMISSIONS=true\n", 0600))
+	if _, err := Load(); err != nil {
+		t.Errorf("expected a private file to load in strict mode, got %v", err)
+	}
+}
+
+func TestLoad_UnreadableConfigFileErrors(t *testing.T) {
+	// Root reads any file, so simulate the permission error
+	defer func(open func(string) (*os.File, error)) { openEnvFile = open }(openEnvFile)
+	openEnvFile = func(path string) (*os.File, error) {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission}
+	}
+
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", writeConfigFile(t, "", 0600))
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "cannot be read by uid") || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected an unreadable config file error, got %v", err)
+	}
+}
+
+func TestLoad_ConfigDirUnreadableErrors(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can stat files in any directory")
+	}
+	dir := filepath.Join(t.TempDir(), "payram")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "updater.env")
+	if err := os.WriteFile(path, []byte("POLICY_URL=https://example.com/policy\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0700)
+
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", path)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "cannot be read by uid") {
+		t.Errorf("expected an unreadable config file error, got %v", err)
+	}
+}
+
+func TestLoad_BackupMaxAgeInvalid(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("POLICY_URL", "https://example.com/policy")
+	os.Setenv("RUNTIME_MANIFEST_URL", "https://example.com/manifest")
+	os.Setenv("BACKUP_MAX_AGE_HOURS", "-1")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for negative BACKUP_MAX_AGE_HOURS, got nil")
+	}
+}
+
+// writeProfilesFile writes a config file defining staging and production
+// profiles on top of shared settings.
+func writeProfilesFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "updater.env")
+	content := `POLICY_URL=https://example.com/policy
+RUNTIME_MANIFEST_URL=https://example.com/manifest
+BACKUP_DIR=/var/backups/payram
+EXECUTION_MODE=execute
+
+PROFILE_STAGING_POLICY_URL=https://staging.example.com/policy
+PROFILE_STAGING_RUNTIME_MANIFEST_URL=https://staging.example.com/manifest
+PROFILE_STAGING_BACKUP_DIR=/var/backups/payram-staging
+PROFILE_STAGING_TARGET_CONTAINER_NAME=payram-staging
+
+PROFILE_PROD_EU_TARGET_CONTAINER_NAME=payram-eu`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Profile(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", writeProfilesFile(t))
+	os.Setenv("UPDATER_PROFILE", "staging")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Profile != "staging" {
+		t.Errorf("expected active profile staging, got %q", cfg.Profile)
+	}
+	if cfg.PolicyURL != "https://staging.example.com/policy" || cfg.RuntimeManifestURL != "https://staging.example.com/manifest" {
+		t.Errorf("expected staging URLs, got %q and %q", cfg.PolicyURL, cfg.RuntimeManifestURL)
+	}
+	if cfg.Backup.Dir != "/var/backups/payram-staging" || cfg.TargetContainerName != "payram-staging" {
+		t.Errorf("expected staging backup dir and container, got %q and %q", cfg.Backup.Dir, cfg.TargetContainerName)
+	}
+	if cfg.ExecutionMode != "execute" {
+		t.Errorf("expected shared settings to apply to the profile, got ExecutionMode %q", cfg.ExecutionMode)
+	}
+}
+
+func TestLoad_ProfileNameWithDash(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", writeProfilesFile(t))
+	os.Setenv("UPDATER_PROFILE", "prod-eu")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TargetContainerName != "payram-eu" {
+		t.Errorf("expected prod-eu container, got %q", cfg.TargetContainerName)
+	}
+	if cfg.PolicyURL != "https://example.com/policy" || cfg.Backup.Dir != "/var/backups/payram" {
+		t.Errorf("expected shared values for settings the profile does not set, got %q and %q", cfg.PolicyURL, cfg.Backup.Dir)
+	}
+}
+
+func TestLoad_ProfileEnvStillWins(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", writeProfilesFile(t))
+	os.Setenv("UPDATER_PROFILE", "staging")
+	os.Setenv("BACKUP_DIR", "/mnt/backups")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Backup.Dir != "/mnt/backups" {
+		t.Errorf("expected env BACKUP_DIR to win over the profile, got %q", cfg.Backup.Dir)
+	}
+	if cfg.TargetContainerName != "payram-staging" {
+		t.Errorf("expected the rest of the profile to apply, got %q", cfg.TargetContainerName)
+	}
+}
+
+func TestLoad_NoProfileUsesSharedValues(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("UPDATER_CONFIG_FILE", writeProfilesFile(t))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Profile != "" || cfg.PolicyURL != "https://example.com/policy" || cfg.TargetContainerName != "" {
+		t.Errorf("expected shared values without a profile, got profile=%q policy=%q container=%q", cfg.Profile, cfg.PolicyURL, cfg.TargetContainerName)
+	}
+}
+
+func TestLoad_ProfileInvalid(t *testing.T) {
+	for _, name := range []string{"production", "bad name"} {
+		os.Clearenv()
+		os.Setenv("UPDATER_CONFIG_FILE", writeProfilesFile(t))
+		os.Setenv("UPDATER_PROFILE", name)
+
+		if _, err := Load(); err == nil {
+			t.Errorf("expected error for profile %q, got nil", name)
+		}
+	}
+}
@@ -2,18 +2,28 @@ package config
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
+	"syscall"
 )
 
+// openEnvFile opens an env file; tests replace it to simulate a file the
+// updater's user may not read.
+var openEnvFile = os.Open
+
 // loadEnvFile reads an environment file and sets environment variables.
 // It supports KEY=value and KEY="value" formats.
 // Lines starting with # are treated as comments and ignored.
 // Blank lines are ignored.
 func loadEnvFile(path string) error {
-	file, err := os.Open(path)
+	file, err := openEnvFile(path)
 	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return unreadableEnvFileError(path, err)
+		}
 		return fmt.Errorf("failed to open env file: %w", err)
 	}
 	defer file.Close()
@@ -55,3 +65,55 @@ func loadEnvFile(path string) error {
 
 	return nil
 }
+
+// unreadableEnvFileError is the error for an env file the updater's user may
+// not read or even stat. Load fails with it instead of silently running on
+// environment variables and defaults alone.
+func unreadableEnvFileError(path string, err error) error {
+	return fmt.Errorf("config file %s cannot be read by uid %d (%w); run the updater as root or fix the owner and permissions of the file and its directory", path, os.Geteuid(), err)
+}
+
+// envFilePermissionProblem describes why the env file at path is unsafe, or
+// returns "" if it is not: it is writable by group or others (a writer could
+// set PRE_BACKUP_HOOK and run commands as the updater), it is owned by
+// neither root nor the updater's user, or it holds secrets (PG_PASSWORD and
+// the like) and is readable by group or others.
+func envFilePermissionProblem(path string, info os.FileInfo) string {
+	mode := info.Mode().Perm()
+	if mode&0o022 != 0 {
+		return fmt.Sprintf("config file %s is writable by other users (mode %04o); run chmod 600 %s", path, mode, path)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Geteuid() {
+		return fmt.Sprintf("config file %s is owned by uid %d, not root or the updater's user (uid %d); run chown root %s", path, stat.Uid, os.Geteuid(), path)
+	}
+	if mode&0o044 != 0 && envFileHasSecrets(path) {
+		return fmt.Sprintf("config file %s holds secrets but is readable by other users (mode %04o); run chmod 600 %s", path, mode, path)
+	}
+	return ""
+}
+
+// envFileHasSecrets reports whether the env file at path sets a password,
+// secret or token. An unreadable file reports false; loading it fails anyway.
+func envFileHasSecrets(path string) bool {
+	file, err := openEnvFile(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _ := strings.Cut(line, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		for _, marker := range []string{"PASSWORD", "SECRET", "TOKEN"} {
+			if strings.Contains(key, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ProfileEnvVar names the active profile (the CLI's --profile flag sets it).
+const ProfileEnvVar = "UPDATER_PROFILE"
+
+// profileNamePattern restricts profile names to what can appear in an
+// environment variable name once upper-cased and '-' is mapped to '_'.
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// profilePrefix returns the prefix of the settings belonging to a profile:
+// profile "staging" sets POLICY_URL through PROFILE_STAGING_POLICY_URL.
+func profilePrefix(name string) string {
+	return "PROFILE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// environKeys returns the names of the variables currently set in the
+// process environment.
+func environKeys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, entry := range os.Environ() {
+		if key, _, ok := strings.Cut(entry, "="); ok {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// applyProfile copies every PROFILE_<NAME>_<KEY> setting of the named
+// profile to KEY, so the profile overrides the values shared by all
+// profiles. Keys in explicit, the variables set in the environment before
+// any config file was read, are left alone: the environment still wins.
+func applyProfile(name string, explicit map[string]bool) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("%s must contain only letters, digits, '-' and '_', got '%s'", ProfileEnvVar, name)
+	}
+
+	prefix := profilePrefix(name)
+	found := false
+	for _, entry := range os.Environ() {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(key, prefix) || len(key) == len(prefix) {
+			continue
+		}
+		found = true
+		target := strings.TrimPrefix(key, prefix)
+		if !explicit[target] {
+			os.Setenv(target, value)
+		}
+	}
+	if !found {
+		return fmt.Errorf("profile '%s' is not defined (no %s* settings found)", name, prefix)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// dockerTagPattern is the syntax docker accepts for an image tag.
+var dockerTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// tagPlaceholderPattern matches a {name} placeholder in a tag template.
+var tagPlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// TagTemplate maps a release version to the image tag it is published under,
+// for registries that tag images per environment (e.g. "{version}-{env}"
+// with Env "prod" publishes 1.7.0 as "1.7.0-prod"). The zero value tags
+// images with the bare version.
+type TagTemplate struct {
+	Template string // May use {version} (required, once) and {env}
+	Env      string // Substituted for {env}
+}
+
+// Validate reports whether the template can produce valid docker tags.
+func (t TagTemplate) Validate() error {
+	if t.Template == "" {
+		return nil
+	}
+	if n := strings.Count(t.Template, "{version}"); n != 1 {
+		return fmt.Errorf("must contain {version} exactly once")
+	}
+	for _, placeholder := range tagPlaceholderPattern.FindAllString(t.Template, -1) {
+		if placeholder != "{version}" && placeholder != "{env}" {
+			return fmt.Errorf("unknown placeholder %s (only {version} and {env} are supported)", placeholder)
+		}
+	}
+	if strings.Contains(t.Template, "{env}") && t.Env == "" {
+		return fmt.Errorf("uses {env} but no environment is set")
+	}
+	if sample := t.Expand("1.0.0"); !dockerTagPattern.MatchString(sample) {
+		return fmt.Errorf("produces an invalid image tag %q", sample)
+	}
+	return nil
+}
+
+// Expand returns the image tag for version.
+func (t TagTemplate) Expand(version string) string {
+	if t.Template == "" {
+		return version
+	}
+	tag := strings.ReplaceAll(t.Template, "{env}", t.Env)
+	return strings.Replace(tag, "{version}", version, 1)
+}
+
+// Version returns the version an image tag was expanded from, or the tag
+// unchanged when it does not match the template.
+// e.g. "{version}-{env}" with Env "prod": "1.7.0-prod" → "1.7.0"
+func (t TagTemplate) Version(tag string) string {
+	if t.Template == "" {
+		return tag
+	}
+	prefix, suffix, found := strings.Cut(strings.ReplaceAll(t.Template, "{env}", t.Env), "{version}")
+	if !found || len(tag) <= len(prefix)+len(suffix) || !strings.HasPrefix(tag, prefix) || !strings.HasSuffix(tag, suffix) {
+		return tag
+	}
+	return tag[len(prefix) : len(tag)-len(suffix)]
+}
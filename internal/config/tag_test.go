@@ -0,0 +1,78 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagTemplate_Expand(t *testing.T) {
+	tests := []struct {
+		name     string
+		template TagTemplate
+		version  string
+		want     string
+	}{
+		{"no template", TagTemplate{}, "1.7.0", "1.7.0"},
+		{"env suffix", TagTemplate{Template: "{version}-{env}", Env: "prod"}, "1.7.0", "1.7.0-prod"},
+		{"env prefix", TagTemplate{Template: "{env}-{version}", Env: "staging"}, "1.7.0", "staging-1.7.0"},
+		{"literal only", TagTemplate{Template: "v{version}-lts"}, "1.7.0", "v1.7.0-lts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.template.Expand(tt.version); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTagTemplate_Version(t *testing.T) {
+	tpl := TagTemplate{Template: "{version}-{env}", Env: "prod"}
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"1.7.0-prod", "1.7.0"},
+		{"1.7.0", "1.7.0"},                 // not templated
+		{"1.7.0-staging", "1.7.0-staging"}, // other environment
+		{"-prod", "-prod"},                 // no version left
+	}
+	for _, tt := range tests {
+		if got := tpl.Version(tt.tag); got != tt.want {
+			t.Errorf("Version(%q): expected %q, got %q", tt.tag, tt.want, got)
+		}
+	}
+	if got := (TagTemplate{}).Version("1.7.0-prod"); got != "1.7.0-prod" {
+		t.Errorf("expected the zero template to keep the tag, got %q", got)
+	}
+}
+
+func TestTagTemplate_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template TagTemplate
+		wantErr  string
+	}{
+		{"empty", TagTemplate{}, ""},
+		{"valid", TagTemplate{Template: "{version}-{env}", Env: "prod"}, ""},
+		{"missing version", TagTemplate{Template: "{env}", Env: "prod"}, "{version} exactly once"},
+		{"repeated version", TagTemplate{Template: "{version}-{version}"}, "{version} exactly once"},
+		{"unknown placeholder", TagTemplate{Template: "{version}-{region}"}, "unknown placeholder {region}"},
+		{"env unset", TagTemplate{Template: "{version}-{env}"}, "no environment"},
+		{"invalid tag", TagTemplate{Template: "{version}/{env}", Env: "prod"}, "invalid image tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.template.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
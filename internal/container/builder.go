@@ -2,6 +2,7 @@ package container
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/payram/payram-updater/internal/logger"
 	"github.com/payram/payram-updater/internal/manifest"
@@ -44,6 +45,10 @@ application code, not the runtime environment.
 // DockerRunBuilder constructs docker run arguments from runtime state and manifest.
 type DockerRunBuilder struct {
 	logger Logger
+
+	// AllowedExtraFlags permits manifest extra_run_args flags beyond the
+	// built-in allowlist (e.g. "--privileged").
+	AllowedExtraFlags []string
 }
 
 // NewDockerRunBuilder creates a new builder.
@@ -187,8 +192,8 @@ func (b *DockerRunBuilder) BuildUpgradeArgs(
 	for _, env := range reconciled.Env {
 		args = append(args, "-e", env)
 	}
-	b.logger.Printf("Environment variables: %d total (%d from runtime, %d added from manifest)",
-		len(reconciled.Env), len(runtimeState.Env), reconciled.AddedEnvs)
+	b.logger.Printf("Environment variables: %d total (%d from runtime, %d added from manifest, %d set and %d removed by manifest env changes)",
+		len(reconciled.Env), len(runtimeState.Env), reconciled.AddedEnvs, reconciled.SetEnvs, reconciled.RemovedEnvs)
 
 	// Networks (PRESERVED from runtime state)
 	// Note: Docker run only supports connecting to ONE network at creation time.
@@ -205,6 +210,15 @@ func (b *DockerRunBuilder) BuildUpgradeArgs(
 		}
 	}
 
+	// Extra run flags from the manifest (VALIDATED against the allowlist)
+	if extra := manifest.Defaults.ExtraRunArgs; len(extra) > 0 {
+		if err := ValidateExtraRunArgs(extra, b.AllowedExtraFlags); err != nil {
+			return nil, err
+		}
+		args = append(args, extra...)
+		b.logger.Printf("Extra run args: %s (from manifest)", strings.Join(extra, " "))
+	}
+
 	// Image with new tag (ONLY CHANGE)
 	newImage := fmt.Sprintf("%s:%s", manifest.Image.Repo, newImageTag)
 	args = append(args, newImage)
@@ -157,6 +157,45 @@ func TestBuildUpgradeArgs_AddsManifestRequirements(t *testing.T) {
 	}
 }
 
+// TestBuildUpgradeArgs_AppliesManifestEnvChanges tests that manifest env
+// additions and removals are applied on top of the preserved runtime env.
+func TestBuildUpgradeArgs_AppliesManifestEnvChanges(t *testing.T) {
+	state := &RuntimeState{
+		Name:          "payram",
+		Image:         "payramapp/payram:1.8.0",
+		Env:           []string{"AES_KEY=secret", "SMTP_HOST=mail", "LEGACY_FLAG=1"},
+		RestartPolicy: RestartPolicy{Name: "no"},
+	}
+	m := &manifest.Manifest{
+		Image: manifest.Image{Repo: "payramapp/payram"},
+		Defaults: manifest.Defaults{
+			ContainerName: "payram",
+			Env: manifest.EnvChanges{
+				Additions: map[string]string{"SMTP_HOST": "smtp.internal", "MAIL_PORT": "587"},
+				Removals:  []string{"LEGACY_FLAG"},
+			},
+		},
+	}
+
+	args, err := NewDockerRunBuilder(&mockLogger{}).BuildUpgradeArgs(state, m, "1.9.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !containsArg(args, "-e", "AES_KEY=secret") {
+		t.Error("Unchanged env var not preserved")
+	}
+	if !containsArg(args, "-e", "SMTP_HOST=smtp.internal") || containsArg(args, "-e", "SMTP_HOST=mail") {
+		t.Error("Manifest addition did not override the runtime value")
+	}
+	if !containsArg(args, "-e", "MAIL_PORT=587") {
+		t.Error("Manifest addition not added")
+	}
+	if containsArg(args, "-e", "LEGACY_FLAG=1") {
+		t.Error("Manifest removal not dropped")
+	}
+}
+
 // TestBuildUpgradeArgs_OnlyImageChanges tests that only image tag changes.
 func TestBuildUpgradeArgs_OnlyImageChanges(t *testing.T) {
 	state := &RuntimeState{
@@ -776,3 +815,91 @@ func TestBuildUpgradeArgs_NoInvalidMountSpecs(t *testing.T) {
 		})
 	}
 }
+
+// TestBuildUpgradeArgs_ExtraRunArgs tests that allowed manifest extra args are appended before the image.
+func TestBuildUpgradeArgs_ExtraRunArgs(t *testing.T) {
+	state := &RuntimeState{
+		Name:          "payram",
+		Image:         "payramapp/payram:1.8.0",
+		RestartPolicy: RestartPolicy{Name: "always"},
+	}
+	m := &manifest.Manifest{
+		Image: manifest.Image{Repo: "payramapp/payram"},
+		Defaults: manifest.Defaults{
+			ExtraRunArgs: []string{"--shm-size", "1g", "--tmpfs=/run", "--init"},
+		},
+	}
+
+	args, err := NewDockerRunBuilder(&mockLogger{}).BuildUpgradeArgs(state, m, "1.9.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !containsArg(args, "--shm-size", "1g") {
+		t.Error("Expected --shm-size 1g in args")
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "--tmpfs=/run --init payramapp/payram:1.9.0") {
+		t.Errorf("Expected extra args before the image, got %v", args)
+	}
+}
+
+// TestBuildUpgradeArgs_ExtraRunArgsRejected tests that dangerous or managed flags are rejected.
+func TestBuildUpgradeArgs_ExtraRunArgsRejected(t *testing.T) {
+	state := &RuntimeState{Name: "payram", Image: "payramapp/payram:1.8.0"}
+
+	tests := []struct {
+		name    string
+		extra   []string
+		wantErr string
+	}{
+		{"privileged", []string{"--privileged"}, "--privileged is not allowed"},
+		{"cap-add", []string{"--cap-add=SYS_ADMIN"}, "--cap-add is not allowed"},
+		{"short volume flag", []string{"-v", "/:/host"}, "only long --flag forms"},
+		{"managed flag", []string{"--network", "host"}, "conflicts with a setting the updater manages"},
+		{"stray value", []string{"1g"}, "is not a flag"},
+		{"image after boolean flag", []string{"--init", "evil/image:latest"}, `"evil/image:latest" is not a flag`},
+		{"value flag without value", []string{"--shm-size"}, "--shm-size needs a value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &manifest.Manifest{
+				Image:    manifest.Image{Repo: "payramapp/payram"},
+				Defaults: manifest.Defaults{ExtraRunArgs: tt.extra},
+			}
+			_, err := NewDockerRunBuilder(&mockLogger{}).BuildUpgradeArgs(state, m, "1.9.0")
+			if err == nil {
+				t.Fatalf("Expected error for %v", tt.extra)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+// TestBuildUpgradeArgs_ExtraRunArgsPermitted tests that operators can permit flags beyond the allowlist.
+func TestBuildUpgradeArgs_ExtraRunArgsPermitted(t *testing.T) {
+	state := &RuntimeState{Name: "payram", Image: "payramapp/payram:1.8.0"}
+	m := &manifest.Manifest{
+		Image:    manifest.Image{Repo: "payramapp/payram"},
+		Defaults: manifest.Defaults{ExtraRunArgs: []string{"--privileged"}},
+	}
+
+	builder := NewDockerRunBuilder(&mockLogger{})
+	builder.AllowedExtraFlags = []string{"privileged"}
+	args, err := builder.BuildUpgradeArgs(state, m, "1.9.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(strings.Join(args, " "), "--privileged") {
+		t.Errorf("Expected --privileged in args, got %v", args)
+	}
+
+	// A permitted flag has no known arity, so its value must be attached
+	m.Defaults.ExtraRunArgs = []string{"--privileged", "evil/image:latest"}
+	if _, err := builder.BuildUpgradeArgs(state, m, "1.9.0"); err == nil || !strings.Contains(err.Error(), "is not a flag") {
+		t.Errorf("Expected a separate value after a permitted flag to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package container
+
+import "strings"
+
+// RedactEnvArgs returns a copy of docker args with the value of every -e
+// environment variable replaced by ***, so the args can be printed or stored.
+func RedactEnvArgs(args []string) []string {
+	out := make([]string, len(args))
+	copy(out, args)
+	for i := 1; i < len(out); i++ {
+		if out[i-1] == "-e" {
+			if idx := strings.Index(out[i], "="); idx >= 0 {
+				out[i] = out[i][:idx+1] + "***"
+			}
+		}
+	}
+	return out
+}
+
+// FormatRunCommand renders docker args as a copy-pasteable shell command,
+// with env values redacted and each argument quoted where the shell needs it.
+func FormatRunCommand(args []string) string {
+	words := []string{"docker"}
+	for _, arg := range RedactEnvArgs(args) {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuote returns arg unchanged if the shell reads it as one literal word,
+// and single-quoted otherwise.
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
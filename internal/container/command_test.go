@@ -0,0 +1,45 @@
+package container
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactEnvArgs(t *testing.T) {
+	args := []string{"run", "-d", "--name", "payram", "-e", "AES_KEY=s3cr3t", "-e", "POSTGRES_HOST=db", "--label", "a=b", "payramapp/payram:1.1.0"}
+
+	got := RedactEnvArgs(args)
+
+	want := []string{"run", "-d", "--name", "payram", "-e", "AES_KEY=***", "-e", "POSTGRES_HOST=***", "--label", "a=b", "payramapp/payram:1.1.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if args[5] != "AES_KEY=s3cr3t" {
+		t.Error("expected the input args to be left unchanged")
+	}
+}
+
+func TestFormatRunCommand_IsRedactedAndParsesBack(t *testing.T) {
+	args := []string{"run", "-d", "--name", "payram", "-e", "AES_KEY=it's secret", "-v", "/srv/payram data:/data", "--label", "note=it's a b", "payramapp/payram:1.1.0"}
+
+	command := FormatRunCommand(args)
+
+	if strings.Contains(command, "secret") {
+		t.Fatalf("expected env values redacted, got %s", command)
+	}
+	if !strings.HasPrefix(command, "docker run -d --name payram -e ") {
+		t.Errorf("expected a docker run command, got %s", command)
+	}
+
+	// The shell must split the command back into exactly the redacted args
+	out, err := exec.Command("sh", "-c", `set -- `+strings.TrimPrefix(command, "docker ")+`; printf '%s\n' "$@"`).Output()
+	if err != nil {
+		t.Fatalf("command is not valid shell: %v", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if want := RedactEnvArgs(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the shell to read %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,85 @@
+package container
+
+import (
+	"fmt"
+
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+const (
+	ManifestNameDiffers          = "MANIFEST_NAME_DIFFERS"
+	ManifestRestartPolicyDiffers = "MANIFEST_RESTART_POLICY_DIFFERS"
+	ManifestPortDiffers          = "MANIFEST_PORT_DIFFERS"
+)
+
+// DetectManifestDivergence compares the manifest defaults with the running
+// container and returns one warning per container name, restart policy or
+// port that differs, saying what the upgrade will do about it (see
+// BuildUpgradeArgs and Reconciler). It only reads state, so plan can report
+// the differences before anything changes.
+func DetectManifestDivergence(state *RuntimeState, m *manifest.Manifest) []LayoutWarning {
+	if state == nil || m == nil {
+		return nil
+	}
+	defaults := m.Defaults
+
+	var warnings []LayoutWarning
+
+	if defaults.ContainerName != "" && state.Name != "" && defaults.ContainerName != state.Name {
+		warnings = append(warnings, LayoutWarning{
+			Code:    ManifestNameDiffers,
+			Message: fmt.Sprintf("manifest container_name is %q but the running container is %q; the upgraded container keeps the name %q", defaults.ContainerName, state.Name, state.Name),
+		})
+	}
+
+	running := formatRestartPolicy(state.RestartPolicy)
+	if defaults.RestartPolicy != "" && defaults.RestartPolicy != running {
+		warnings = append(warnings, LayoutWarning{
+			Code:    ManifestRestartPolicyDiffers,
+			Message: fmt.Sprintf("manifest restart_policy is %q but the running container uses %q; the upgraded container keeps %q", defaults.RestartPolicy, running, running),
+		})
+	}
+
+	published := make(map[string]PortMapping)
+	usedHostPorts := make(map[string]string)
+	for _, port := range state.Ports {
+		published[port.ContainerPort+"/"+port.Protocol] = port
+		if port.HostPort != "" {
+			usedHostPorts[port.HostPort] = port.ContainerPort + "/" + port.Protocol
+		}
+	}
+	for _, port := range defaults.Ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		key := fmt.Sprintf("%d/%s", port.Container, protocol)
+		hostPort := fmt.Sprintf("%d", port.Host)
+		if port.Host <= 0 {
+			hostPort = fmt.Sprintf("%d", port.Container)
+		}
+
+		if existing, ok := published[key]; ok {
+			if existing.HostPort != hostPort {
+				warnings = append(warnings, LayoutWarning{
+					Code:    ManifestPortDiffers,
+					Message: fmt.Sprintf("manifest publishes %s on host port %s but the running container publishes it on %s; the upgrade keeps %s", key, hostPort, existing.HostPort, existing.HostPort),
+				})
+			}
+			continue
+		}
+		if other, ok := usedHostPorts[hostPort]; ok {
+			warnings = append(warnings, LayoutWarning{
+				Code:    ManifestPortDiffers,
+				Message: fmt.Sprintf("manifest publishes %s on host port %s, which the running container uses for %s; the upgrade will fail with PORT_CONFLICT", key, hostPort, other),
+			})
+			continue
+		}
+		warnings = append(warnings, LayoutWarning{
+			Code:    ManifestPortDiffers,
+			Message: fmt.Sprintf("manifest publishes %s on host port %s, which the running container does not publish; the upgrade adds it", key, hostPort),
+		})
+	}
+
+	return warnings
+}
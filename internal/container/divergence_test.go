@@ -0,0 +1,73 @@
+package container
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+func divergenceTestState() *RuntimeState {
+	return &RuntimeState{
+		Name:          "payram",
+		RestartPolicy: RestartPolicy{Name: "always"},
+		Ports: []PortMapping{
+			{HostIP: "0.0.0.0", HostPort: "8080", ContainerPort: "8080", Protocol: "tcp"},
+			{HostIP: "0.0.0.0", HostPort: "9443", ContainerPort: "443", Protocol: "tcp"},
+		},
+	}
+}
+
+func TestDetectManifestDivergence_Matching(t *testing.T) {
+	m := &manifest.Manifest{Defaults: manifest.Defaults{
+		ContainerName: "payram",
+		RestartPolicy: "always",
+		Ports:         []manifest.Port{{Container: 8080, Host: 8080}, {Container: 443, Host: 9443, Protocol: "tcp"}},
+	}}
+
+	if warnings := DetectManifestDivergence(divergenceTestState(), m); len(warnings) != 0 {
+		t.Errorf("expected no warnings when the manifest matches, got %+v", warnings)
+	}
+}
+
+func TestDetectManifestDivergence_DivergentDefaults(t *testing.T) {
+	m := &manifest.Manifest{Defaults: manifest.Defaults{
+		ContainerName: "payram-core",
+		RestartPolicy: "unless-stopped",
+		Ports: []manifest.Port{
+			{Container: 443, Host: 443},   // published elsewhere
+			{Container: 5432, Host: 8080}, // host port taken
+			{Container: 9090},             // not published
+		},
+	}}
+
+	warnings := DetectManifestDivergence(divergenceTestState(), m)
+	if len(warnings) != 5 {
+		t.Fatalf("expected 5 warnings, got %+v", warnings)
+	}
+	want := []struct{ code, text string }{
+		{ManifestNameDiffers, `keeps the name "payram"`},
+		{ManifestRestartPolicyDiffers, `keeps "always"`},
+		{ManifestPortDiffers, "443/tcp on host port 443 but the running container publishes it on 9443"},
+		{ManifestPortDiffers, "fail with PORT_CONFLICT"},
+		{ManifestPortDiffers, "9090/tcp on host port 9090, which the running container does not publish"},
+	}
+	for i, w := range want {
+		if warnings[i].Code != w.code || !strings.Contains(warnings[i].Message, w.text) {
+			t.Errorf("warning %d: expected %s containing %q, got %s: %s", i, w.code, w.text, warnings[i].Code, warnings[i].Message)
+		}
+	}
+}
+
+func TestDetectManifestDivergence_EmptyDefaults(t *testing.T) {
+	state := divergenceTestState()
+	state.RestartPolicy = RestartPolicy{}
+
+	if warnings := DetectManifestDivergence(state, &manifest.Manifest{}); len(warnings) != 0 {
+		t.Errorf("expected unset manifest defaults to be ignored, got %+v", warnings)
+	}
+	m := &manifest.Manifest{Defaults: manifest.Defaults{RestartPolicy: "no"}}
+	if warnings := DetectManifestDivergence(state, m); len(warnings) != 0 {
+		t.Errorf("expected no restart policy to match \"no\", got %+v", warnings)
+	}
+}
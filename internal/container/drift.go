@@ -0,0 +1,113 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuntimeDrift compares the runtime state of a container started with args,
+// as built by BuildUpgradeArgs, against what those args asked for. It returns
+// one description per env var, mount or port that did not take effect, or
+// nil if the container matches. Env values are never included, since they
+// may hold secrets.
+func RuntimeDrift(args []string, state *RuntimeState) []string {
+	env := make(map[string]string, len(state.Env))
+	for _, kv := range state.Env {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+
+	var drift []string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-e":
+			i++
+			key, want, ok := strings.Cut(args[i], "=")
+			if !ok {
+				// -e KEY passes the host's value through; nothing to compare
+				continue
+			}
+			if got, found := env[key]; !found {
+				drift = append(drift, fmt.Sprintf("env %s is missing", key))
+			} else if got != want {
+				drift = append(drift, fmt.Sprintf("env %s has a different value", key))
+			}
+		case "-v":
+			i++
+			if problem := mountDrift(args[i], state.Mounts); problem != "" {
+				drift = append(drift, problem)
+			}
+		case "-p":
+			i++
+			if problem := portDrift(args[i], state.Ports); problem != "" {
+				drift = append(drift, problem)
+			}
+		}
+	}
+	return drift
+}
+
+// mountDrift checks a -v source:destination[:mode] spec against the mounts
+// of the container.
+func mountDrift(spec string, mounts []Mount) string {
+	parts := strings.Split(spec, ":")
+	source, destination, mode := "", parts[0], ""
+	if len(parts) > 1 {
+		source, destination = parts[0], parts[1]
+	}
+	if len(parts) > 2 {
+		mode = parts[2]
+	}
+
+	for _, m := range mounts {
+		if m.Destination != destination {
+			continue
+		}
+		switch {
+		case source == "":
+		case strings.HasPrefix(source, "/"):
+			if m.Source != source {
+				return fmt.Sprintf("mount %s is bound from %s, expected %s", destination, m.Source, source)
+			}
+		case m.Name != source && m.Source != source:
+			return fmt.Sprintf("mount %s uses volume %s, expected %s", destination, m.Name, source)
+		}
+		if m.RW && containsOption(mode, "ro") {
+			return fmt.Sprintf("mount %s is writable, expected read-only", destination)
+		}
+		return ""
+	}
+	return fmt.Sprintf("mount %s is missing", destination)
+}
+
+// portDrift checks a -p [hostIP:]hostPort:containerPort/protocol spec
+// against the published ports of the container.
+func portDrift(spec string, ports []PortMapping) string {
+	mapping, protocol, found := strings.Cut(spec, "/")
+	if !found {
+		protocol = "tcp"
+	}
+	parts := strings.Split(mapping, ":")
+	containerPort := parts[len(parts)-1]
+	hostPort := ""
+	if len(parts) > 1 {
+		hostPort = parts[len(parts)-2]
+	}
+
+	for _, p := range ports {
+		if p.ContainerPort == containerPort && p.Protocol == protocol && (hostPort == "" || p.HostPort == hostPort) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("port %s is not published", spec)
+}
+
+func containsOption(mode, option string) bool {
+	for _, o := range strings.Split(mode, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package container
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func driftTestState() *RuntimeState {
+	return &RuntimeState{
+		Name: "payram",
+		Env:  []string{"PATH=/usr/bin", "POSTGRES_HOST=db", "AES_KEY=secret"},
+		Mounts: []Mount{
+			{Type: "bind", Source: "/srv/payram", Destination: "/root/payram", RW: true},
+			{Type: "volume", Name: "payram-db", Source: "/var/lib/docker/volumes/payram-db/_data", Destination: "/var/lib/postgresql", RW: true},
+			{Type: "bind", Source: "/etc/payram/certs", Destination: "/certs", RW: false},
+		},
+		Ports: []PortMapping{
+			{HostIP: "0.0.0.0", HostPort: "8080", ContainerPort: "8080", Protocol: "tcp"},
+			{HostIP: "127.0.0.1", HostPort: "5432", ContainerPort: "5432", Protocol: "tcp"},
+		},
+	}
+}
+
+func driftTestArgs() []string {
+	return []string{"run", "-d", "--name", "payram", "--restart", "always",
+		"-p", "8080:8080/tcp", "-p", "127.0.0.1:5432:5432/tcp",
+		"-v", "/srv/payram:/root/payram", "-v", "payram-db:/var/lib/postgresql", "-v", "/etc/payram/certs:/certs:ro",
+		"-e", "POSTGRES_HOST=db", "-e", "AES_KEY=secret",
+		"payramapp/payram:1.1.0"}
+}
+
+func TestRuntimeDrift_NoDrift(t *testing.T) {
+	if drift := RuntimeDrift(driftTestArgs(), driftTestState()); drift != nil {
+		t.Errorf("expected no drift, got %v", drift)
+	}
+}
+
+func TestRuntimeDrift_MissingMount(t *testing.T) {
+	state := driftTestState()
+	state.Mounts = state.Mounts[1:]
+
+	drift := RuntimeDrift(driftTestArgs(), state)
+
+	if want := []string{"mount /root/payram is missing"}; !reflect.DeepEqual(drift, want) {
+		t.Errorf("expected %v, got %v", want, drift)
+	}
+}
+
+func TestRuntimeDrift_Detects(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*RuntimeState)
+		want   string
+	}{
+		{"missing env", func(s *RuntimeState) { s.Env = s.Env[:2] }, "env AES_KEY is missing"},
+		{"changed env", func(s *RuntimeState) { s.Env[1] = "POSTGRES_HOST=localhost" }, "env POSTGRES_HOST has a different value"},
+		{"other bind source", func(s *RuntimeState) { s.Mounts[0].Source = "/tmp/payram" }, "mount /root/payram is bound from /tmp/payram, expected /srv/payram"},
+		{"other volume", func(s *RuntimeState) { s.Mounts[1].Name = "payram-db-2" }, "mount /var/lib/postgresql uses volume payram-db-2, expected payram-db"},
+		{"writable read-only mount", func(s *RuntimeState) { s.Mounts[2].RW = true }, "mount /certs is writable, expected read-only"},
+		{"unpublished port", func(s *RuntimeState) { s.Ports = s.Ports[1:] }, "port 8080:8080/tcp is not published"},
+		{"other host port", func(s *RuntimeState) { s.Ports[1].HostPort = "15432" }, "port 127.0.0.1:5432:5432/tcp is not published"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := driftTestState()
+			tt.change(state)
+
+			drift := RuntimeDrift(driftTestArgs(), state)
+
+			if len(drift) != 1 || drift[0] != tt.want {
+				t.Errorf("expected [%s], got %v", tt.want, drift)
+			}
+		})
+	}
+}
+
+func TestRuntimeDrift_NeverReportsEnvValues(t *testing.T) {
+	state := driftTestState()
+	state.Env = []string{"AES_KEY=other-secret"}
+
+	for _, problem := range RuntimeDrift(driftTestArgs(), state) {
+		if strings.Contains(problem, "secret") {
+			t.Errorf("expected env values to be left out, got %q", problem)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultExtraRunFlags are the docker run flags a manifest may pass through
+// without operator opt-in, each mapped to whether it takes a value. They tune
+// resources and logging but cannot widen the container's access to the host.
+var defaultExtraRunFlags = map[string]bool{
+	"--shm-size":     true,
+	"--tmpfs":        true,
+	"--ulimit":       true,
+	"--memory":       true,
+	"--memory-swap":  true,
+	"--cpus":         true,
+	"--pids-limit":   true,
+	"--log-driver":   true,
+	"--log-opt":      true,
+	"--stop-timeout": true,
+	"--stop-signal":  true,
+	"--init":         false,
+	"--label":        true,
+}
+
+// builderManagedFlags are emitted by BuildUpgradeArgs itself (or change how
+// the updater runs the container) and may never be passed through.
+var builderManagedFlags = map[string]bool{
+	"--name":    true,
+	"--restart": true,
+	"--detach":  true,
+	"--rm":      true,
+	"--publish": true,
+	"--volume":  true,
+	"--env":     true,
+	"--network": true,
+}
+
+// ValidateExtraRunArgs checks manifest extra_run_args against the default
+// allowlist plus any flags the operator explicitly permitted. Every flag must
+// use the long "--flag" or "--flag=value" form. Only a built-in flag known to
+// take a value may have it as the next element instead: a boolean flag such
+// as --init never consumes the next element, which would otherwise slip in a
+// bare argument (e.g. another image) ahead of the one BuildUpgradeArgs appends.
+// Operator-permitted flags have no known arity and must use "--flag=value".
+func ValidateExtraRunArgs(args []string, permitted []string) error {
+	allowed := make(map[string]bool, len(defaultExtraRunFlags)+len(permitted))
+	for flag := range defaultExtraRunFlags {
+		allowed[flag] = true
+	}
+	for _, flag := range permitted {
+		flag = strings.TrimSpace(flag)
+		if flag == "" {
+			continue
+		}
+		if !strings.HasPrefix(flag, "--") {
+			flag = "--" + flag
+		}
+		allowed[flag] = true
+	}
+
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if !strings.HasPrefix(arg, "--") {
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("extra run arg %q: only long --flag forms are supported", arg)
+			}
+			return fmt.Errorf("extra run arg %q is not a flag (values must follow their flag)", arg)
+		}
+
+		flag := arg
+		hasValue := false
+		if eq := strings.Index(arg, "="); eq >= 0 {
+			flag = arg[:eq]
+			hasValue = true
+		}
+		if builderManagedFlags[flag] {
+			return fmt.Errorf("extra run arg %s conflicts with a setting the updater manages", flag)
+		}
+		if !allowed[flag] {
+			return fmt.Errorf("extra run arg %s is not allowed (permit it with ALLOWED_EXTRA_RUN_FLAGS)", flag)
+		}
+
+		// Consume a separate value, e.g. ["--shm-size", "1g"]
+		if !hasValue && defaultExtraRunFlags[flag] {
+			if idx+1 >= len(args) || strings.HasPrefix(args[idx+1], "-") {
+				return fmt.Errorf("extra run arg %s needs a value", flag)
+			}
+			idx++
+		}
+	}
+	return nil
+}
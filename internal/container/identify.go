@@ -3,12 +3,13 @@ package container
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/payram/payram-updater/internal/httpclient"
 )
 
 const (
@@ -36,29 +37,25 @@ type PortIdentifier struct {
 
 // NewPortIdentifier creates a new port identifier.
 func NewPortIdentifier(logger Logger) *PortIdentifier {
-	noFollow := func(req *http.Request, via []*http.Request) error {
-		return http.ErrUseLastResponse
-	}
+	// Don't follow redirects - we want to check the root endpoint directly
+	httpClient := httpclient.New(PortIdentificationTimeout)
+	httpClient.CheckRedirect = noFollowRedirects
+	// httpsClient skips TLS verification because the container's certificate
+	// may be self-signed or not include 127.0.0.1 as a SAN.  This is
+	// acceptable here since we only ever probe loopback addresses.
+	httpsClient := httpclient.NewLoopback(PortIdentificationTimeout)
+	httpsClient.CheckRedirect = noFollowRedirects
 	return &PortIdentifier{
-		httpClient: &http.Client{
-			Timeout: PortIdentificationTimeout,
-			// Don't follow redirects - we want to check the root endpoint directly
-			CheckRedirect: noFollow,
-		},
-		// httpsClient skips TLS verification because the container's certificate
-		// may be self-signed or not include 127.0.0.1 as a SAN.  This is
-		// acceptable here since we only ever probe loopback addresses.
-		httpsClient: &http.Client{
-			Timeout:       PortIdentificationTimeout,
-			CheckRedirect: noFollow,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
-			},
-		},
-		logger: logger,
+		httpClient:  httpClient,
+		httpsClient: httpsClient,
+		logger:      logger,
 	}
 }
 
+func noFollowRedirects(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
 // IdentifyPayramCorePort identifies which exposed port is running Payram Core.
 //
 // Process:
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"strings"
 	"time"
 )
 
@@ -52,6 +53,7 @@ type PortMapping struct {
 type Mount struct {
 	Type        string // "bind" or "volume"
 	Source      string // Host path or volume name
+	Name        string // Volume name as reported by docker (volume mounts only)
 	Destination string // Container path
 	Mode        string // Mount options (e.g., "rw", "ro")
 	RW          bool   // Read-write flag
@@ -107,6 +109,7 @@ type dockerInspectOutput struct {
 	} `json:"HostConfig"`
 	Mounts []struct {
 		Type        string `json:"Type"`
+		Name        string `json:"Name"`
 		Source      string `json:"Source"`
 		Destination string `json:"Destination"`
 		Mode        string `json:"Mode"`
@@ -252,6 +255,7 @@ func extractPorts(portBindings map[string][]struct {
 // extractMounts converts Docker mounts to Mount structs.
 func extractMounts(dockerMounts []struct {
 	Type        string `json:"Type"`
+	Name        string `json:"Name"`
 	Source      string `json:"Source"`
 	Destination string `json:"Destination"`
 	Mode        string `json:"Mode"`
@@ -263,6 +267,7 @@ func extractMounts(dockerMounts []struct {
 		mounts[i] = Mount{
 			Type:        m.Type,
 			Source:      m.Source,
+			Name:        m.Name,
 			Destination: m.Destination,
 			Mode:        m.Mode,
 			RW:          m.RW,
@@ -272,6 +277,52 @@ func extractMounts(dockerMounts []struct {
 	return mounts
 }
 
+// NamedVolumes returns the names of the Docker volumes mounted into the
+// container, in mount order. Bind mounts are skipped.
+func NamedVolumes(state *RuntimeState) []string {
+	var names []string
+	for _, m := range state.Mounts {
+		if m.Type != "volume" {
+			continue
+		}
+		name := m.Name
+		if name == "" && !strings.HasPrefix(m.Source, "/") {
+			name = m.Source
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DefaultPGDataDir is where Postgres keeps its data when PGDATA is unset.
+const DefaultPGDataDir = "/var/lib/postgresql/data"
+
+// DBDataMount returns the mount holding the container's Postgres data
+// directory (PGDATA, or DefaultPGDataDir), or nil when the data lives in the
+// container's own filesystem. The deepest mount covering the directory wins.
+func DBDataMount(state *RuntimeState) *Mount {
+	dataDir := DefaultPGDataDir
+	for _, entry := range state.Env {
+		if key, value, _ := strings.Cut(entry, "="); key == "PGDATA" && value != "" {
+			dataDir = value
+		}
+	}
+
+	var found *Mount
+	for i, m := range state.Mounts {
+		dest := strings.TrimSuffix(m.Destination, "/")
+		if dataDir != dest && !strings.HasPrefix(dataDir, dest+"/") {
+			continue
+		}
+		if found == nil || len(dest) > len(strings.TrimSuffix(found.Destination, "/")) {
+			found = &state.Mounts[i]
+		}
+	}
+	return found
+}
+
 // extractNetworks converts Docker networks to NetworkConfig structs.
 func extractNetworks(dockerNetworks map[string]struct {
 	IPAddress  string `json:"IPAddress"`
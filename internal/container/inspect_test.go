@@ -442,6 +442,7 @@ func TestExtractPorts(t *testing.T) {
 func TestExtractMounts(t *testing.T) {
 	dockerMounts := []struct {
 		Type        string `json:"Type"`
+		Name        string `json:"Name"`
 		Source      string `json:"Source"`
 		Destination string `json:"Destination"`
 		Mode        string `json:"Mode"`
@@ -478,6 +479,56 @@ func TestExtractMounts(t *testing.T) {
 	}
 }
 
+// TestNamedVolumes tests that only volume mounts are reported, by name.
+func TestNamedVolumes(t *testing.T) {
+	state := &RuntimeState{Mounts: []Mount{
+		{Type: "volume", Name: "pgdata", Source: "/var/lib/docker/volumes/pgdata/_data", Destination: "/var/lib/postgresql"},
+		{Type: "bind", Source: "/host/config", Destination: "/config"},
+		{Type: "volume", Source: "data-vol", Destination: "/data"},
+		{Type: "volume", Source: "/var/lib/docker/volumes/unknown/_data", Destination: "/cache"},
+	}}
+
+	names := NamedVolumes(state)
+
+	if len(names) != 2 || names[0] != "pgdata" || names[1] != "data-vol" {
+		t.Errorf("Expected [pgdata data-vol], got %v", names)
+	}
+}
+
+func TestDBDataMount(t *testing.T) {
+	mounts := []Mount{
+		{Type: "bind", Source: "/srv/payram", Destination: "/var/lib"},
+		{Type: "volume", Name: "pgdata", Source: "/var/lib/docker/volumes/pgdata/_data", Destination: "/var/lib/postgresql/data"},
+		{Type: "bind", Source: "/srv/pg", Destination: "/pg/"},
+	}
+
+	tests := []struct {
+		name   string
+		env    []string
+		mounts []Mount
+		want   string
+	}{
+		{"default data dir, deepest mount", nil, mounts, "/var/lib/docker/volumes/pgdata/_data"},
+		{"PGDATA under a mount", []string{"PGDATA=/pg/main"}, mounts, "/srv/pg"},
+		{"parent mount only", nil, mounts[:1], "/srv/payram"},
+		{"not mounted", []string{"PGDATA=/opt/pg"}, mounts, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DBDataMount(&RuntimeState{Env: tt.env, Mounts: tt.mounts})
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("expected no mount, got %+v", got)
+				}
+				return
+			}
+			if got == nil || got.Source != tt.want {
+				t.Errorf("expected mount from %s, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
 // TestExtractNetworks tests network extraction.
 func TestExtractNetworks(t *testing.T) {
 	dockerNetworks := map[string]struct {
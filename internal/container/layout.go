@@ -0,0 +1,77 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LayoutWarning describes a part of a container's runtime layout that the
+// upgrade may not reproduce faithfully when it recreates the container.
+type LayoutWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	LayoutComposeManaged   = "COMPOSE_MANAGED"
+	LayoutSwarmManaged     = "SWARM_MANAGED"
+	LayoutHostNetwork      = "HOST_NETWORK"
+	LayoutNamedNetwork     = "NAMED_NETWORK"
+	LayoutMultipleNetworks = "MULTIPLE_NETWORKS"
+)
+
+// DetectUnmanagedLayout flags containers that were not created with the plain
+// `docker run` pattern BuildUpgradeArgs reproduces. It is a heuristic: a
+// warning means the operator should review the dry-run output, not that the
+// upgrade will fail.
+func DetectUnmanagedLayout(state *RuntimeState) []LayoutWarning {
+	if state == nil {
+		return nil
+	}
+
+	var warnings []LayoutWarning
+
+	if project := state.Labels["com.docker.compose.project"]; project != "" {
+		warnings = append(warnings, LayoutWarning{
+			Code:    LayoutComposeManaged,
+			Message: fmt.Sprintf("container belongs to docker-compose project %q; the upgrade recreates it with docker run, so compose will no longer manage it", project),
+		})
+	}
+
+	if state.Labels["com.docker.swarm.service.id"] != "" || state.Labels["com.docker.stack.namespace"] != "" {
+		warnings = append(warnings, LayoutWarning{
+			Code:    LayoutSwarmManaged,
+			Message: "container is a swarm service task; swarm may reschedule it independently of the upgrade",
+		})
+	}
+
+	var named []string
+	for _, network := range state.Networks {
+		switch network.NetworkName {
+		case "bridge", "none":
+		case "host":
+			warnings = append(warnings, LayoutWarning{
+				Code:    LayoutHostNetwork,
+				Message: "container uses host networking; the upgraded container is started on the default bridge network",
+			})
+		default:
+			named = append(named, network.NetworkName)
+		}
+	}
+	sort.Strings(named)
+
+	if len(state.Networks) > 1 {
+		warnings = append(warnings, LayoutWarning{
+			Code:    LayoutMultipleNetworks,
+			Message: fmt.Sprintf("container is attached to %d networks; only the first is preserved", len(state.Networks)),
+		})
+	}
+	if len(named) > 0 {
+		warnings = append(warnings, LayoutWarning{
+			Code:    LayoutNamedNetwork,
+			Message: fmt.Sprintf("container uses user-defined network(s) %v; network aliases and static IPs are not preserved", named),
+		})
+	}
+
+	return warnings
+}
@@ -0,0 +1,80 @@
+package container
+
+import (
+	"testing"
+)
+
+func layoutCodes(warnings []LayoutWarning) map[string]bool {
+	codes := make(map[string]bool, len(warnings))
+	for _, w := range warnings {
+		codes[w.Code] = true
+	}
+	return codes
+}
+
+func TestDetectUnmanagedLayout_PlainContainer(t *testing.T) {
+	state := &RuntimeState{
+		Name:     "payram",
+		Labels:   map[string]string{"org.opencontainers.image.version": "1.8.0"},
+		Networks: []NetworkConfig{{NetworkName: "bridge"}},
+	}
+
+	if warnings := DetectUnmanagedLayout(state); len(warnings) != 0 {
+		t.Errorf("expected no warnings for plain docker run container, got %+v", warnings)
+	}
+}
+
+func TestDetectUnmanagedLayout_ComposeLabels(t *testing.T) {
+	state := &RuntimeState{
+		Name: "payram",
+		Labels: map[string]string{
+			"com.docker.compose.project": "payram-stack",
+			"com.docker.compose.service": "payram",
+		},
+		Networks: []NetworkConfig{{NetworkName: "payram-stack_default"}},
+	}
+
+	codes := layoutCodes(DetectUnmanagedLayout(state))
+	if !codes[LayoutComposeManaged] {
+		t.Error("expected COMPOSE_MANAGED warning for compose labels")
+	}
+	if !codes[LayoutNamedNetwork] {
+		t.Error("expected NAMED_NETWORK warning for compose default network")
+	}
+	if codes[LayoutMultipleNetworks] {
+		t.Error("did not expect MULTIPLE_NETWORKS for a single network")
+	}
+}
+
+func TestDetectUnmanagedLayout_Networks(t *testing.T) {
+	state := &RuntimeState{
+		Name: "payram",
+		Networks: []NetworkConfig{
+			{NetworkName: "host"},
+			{NetworkName: "payram-net"},
+		},
+	}
+
+	codes := layoutCodes(DetectUnmanagedLayout(state))
+	for _, code := range []string{LayoutHostNetwork, LayoutMultipleNetworks, LayoutNamedNetwork} {
+		if !codes[code] {
+			t.Errorf("expected %s warning, got %v", code, codes)
+		}
+	}
+}
+
+func TestDetectUnmanagedLayout_Swarm(t *testing.T) {
+	state := &RuntimeState{
+		Labels: map[string]string{"com.docker.stack.namespace": "payram"},
+	}
+
+	if codes := layoutCodes(DetectUnmanagedLayout(state)); !codes[LayoutSwarmManaged] {
+		t.Errorf("expected SWARM_MANAGED warning, got %v", codes)
+	}
+}
+
+func TestDetectUnmanagedLayout_NilState(t *testing.T) {
+	if warnings := DetectUnmanagedLayout(nil); warnings != nil {
+		t.Errorf("expected nil warnings for nil state, got %+v", warnings)
+	}
+}
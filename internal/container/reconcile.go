@@ -3,6 +3,8 @@ package container
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/payram/payram-updater/internal/manifest"
 )
@@ -12,6 +14,7 @@ MANIFEST AS ADDITIVE OVERLAY
 
 The manifest serves as an ADDITIVE overlay only. It specifies minimum required
 configuration but NEVER removes, remaps, or overrides existing container state.
+The one exception is explicit env migration (see ApplyEnvChanges).
 
 MANIFEST MAY DEFINE:
   - Required ports (added if missing)
@@ -36,6 +39,11 @@ RECONCILIATION PRINCIPLE:
   - Add manifest requirements that are missing
   - Never remove or change existing values
 
+EXPLICIT ENV MIGRATION:
+  The manifest's env.additions and env.removals are applied after the additive
+  overlay, so a version can rename or retire a setting. They name each key
+  explicitly; protected secrets are never touched.
+
 SPECIAL CASES:
   - Secrets (AES_KEY, DB credentials): Never generated or modified
   - Port conflicts: Fail if manifest requires a port whose host port is unavailable
@@ -62,13 +70,33 @@ type ReconciledConfiguration struct {
 	// Mounts is the union of inspected mounts + manifest-required mounts
 	Mounts []Mount
 
-	// Env is the union of inspected env + manifest-required env (no overwrites)
+	// Env is the union of inspected env + manifest-required env (no overwrites),
+	// with the manifest's explicit env changes applied
 	Env []string
 
 	// Metadata about what was added
 	AddedPorts  int
 	AddedMounts int
 	AddedEnvs   int
+
+	// Metadata about the manifest's explicit env changes
+	SetEnvs     int
+	RemovedEnvs int
+}
+
+// protectedEnvKeys are secrets and database connection settings that
+// manifest env changes may never set or remove: changing the connection
+// would point Payram, and the pre-upgrade backup's restore, at another
+// database.
+var protectedEnvKeys = map[string]bool{
+	"AES_KEY":           true,
+	"POSTGRES_PASSWORD": true,
+	"POSTGRES_HOST":     true,
+	"POSTGRES_PORT":     true,
+	"POSTGRES_USER":     true,
+	"POSTGRES_USERNAME": true,
+	"POSTGRES_DB":       true,
+	"POSTGRES_DATABASE": true,
 }
 
 // ReconcilePorts implements D2 - Port reconciliation logic.
@@ -292,6 +320,69 @@ func (r *Reconciler) ReconcileEnv(inspected []string, manifestEnv map[string]str
 	return result, nil
 }
 
+// ApplyEnvChanges applies the manifest's explicit env changes to env: each
+// removal is dropped, then each addition is set, replacing any existing value.
+// Protected secrets and database settings are skipped. Every change is logged. It returns the new
+// env and the number of keys set and removed.
+func (r *Reconciler) ApplyEnvChanges(env []string, changes manifest.EnvChanges) ([]string, int, int) {
+	if len(changes.Additions) == 0 && len(changes.Removals) == 0 {
+		return env, 0, 0
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range changes.Removals {
+		if protectedEnvKeys[key] {
+			r.logger.Printf("Manifest env removal of %s skipped (protected)", key)
+			continue
+		}
+		remove[key] = true
+	}
+	set := make(map[string]string)
+	for key, value := range changes.Additions {
+		if protectedEnvKeys[key] {
+			r.logger.Printf("Manifest env addition of %s skipped (protected)", key)
+			continue
+		}
+		set[key] = value
+		delete(remove, key)
+	}
+	applied := len(set)
+
+	result := make([]string, 0, len(env)+len(set))
+	removed := 0
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		if remove[key] {
+			r.logger.Printf("Removed env var %s (manifest removal)", key)
+			removed++
+			continue
+		}
+		if value, ok := set[key]; ok {
+			if entry != key+"="+value {
+				r.logger.Printf("Replaced env var %s (manifest addition)", key)
+			}
+			result = append(result, key+"="+value)
+			delete(set, key)
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	// Remaining additions are new keys; sort them for a stable docker run
+	newKeys := make([]string, 0, len(set))
+	for key := range set {
+		newKeys = append(newKeys, key)
+	}
+	sort.Strings(newKeys)
+	for _, key := range newKeys {
+		r.logger.Printf("Added env var %s (manifest addition)", key)
+		result = append(result, key+"="+set[key])
+	}
+
+	r.logger.Printf("Manifest env changes applied: %d set, %d removed", applied, removed)
+	return result, applied, removed
+}
+
 // Reconcile performs full configuration reconciliation.
 //
 // This is a convenience method that calls all reconciliation functions and returns
@@ -330,13 +421,20 @@ func (r *Reconciler) Reconcile(state *RuntimeState, manifest *manifest.Manifest)
 		return nil, fmt.Errorf("env reconciliation failed: %w", err)
 	}
 
+	addedEnvs := len(env) - len(state.Env)
+
+	// Apply the manifest's explicit env migration on top
+	env, setEnvs, removedEnvs := r.ApplyEnvChanges(env, manifest.Defaults.Env)
+
 	config := &ReconciledConfiguration{
 		Ports:       ports,
 		Mounts:      mounts,
 		Env:         env,
 		AddedPorts:  len(ports) - len(state.Ports),
 		AddedMounts: len(mounts) - len(state.Mounts),
-		AddedEnvs:   len(env) - len(state.Env),
+		AddedEnvs:   addedEnvs,
+		SetEnvs:     setEnvs,
+		RemovedEnvs: removedEnvs,
 	}
 
 	r.logger.Printf("Reconciliation complete: added %d ports, %d mounts, %d env vars",
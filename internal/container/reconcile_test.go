@@ -1,6 +1,7 @@
 package container
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/payram/payram-updater/internal/manifest"
@@ -501,6 +502,67 @@ func TestReconcileEnv_EmptyValueIsValid(t *testing.T) {
 	}
 }
 
+// TestApplyEnvChanges_AdditionsOverrideAndRemovalsDrop tests the manifest's
+// explicit env migration.
+func TestApplyEnvChanges_AdditionsOverrideAndRemovalsDrop(t *testing.T) {
+	env := []string{
+		"KEEP=kept",
+		"OLD_SETTING=legacy",
+		"LOG_LEVEL=info",
+	}
+	changes := manifest.EnvChanges{
+		Additions: map[string]string{
+			"LOG_LEVEL":   "debug", // overrides the runtime value
+			"NEW_SETTING": "legacy",
+		},
+		Removals: []string{"OLD_SETTING", "NOT_PRESENT"},
+	}
+
+	reconciler := NewReconciler(&mockLogger{})
+	result, set, removed := reconciler.ApplyEnvChanges(env, changes)
+
+	want := []string{"KEEP=kept", "LOG_LEVEL=debug", "NEW_SETTING=legacy"}
+	if len(result) != len(want) {
+		t.Fatalf("Expected env %v, got %v", want, result)
+	}
+	for i := range want {
+		if result[i] != want[i] {
+			t.Errorf("Expected env %v, got %v", want, result)
+			break
+		}
+	}
+	if set != 2 || removed != 1 {
+		t.Errorf("Expected 2 set and 1 removed, got %d set and %d removed", set, removed)
+	}
+}
+
+// TestApplyEnvChanges_SkipsProtectedSecrets tests that secrets and database
+// connection settings are never set or removed by manifest env changes.
+func TestApplyEnvChanges_SkipsProtectedSecrets(t *testing.T) {
+	env := []string{"AES_KEY=secret", "POSTGRES_PASSWORD=pw", "POSTGRES_HOST=localhost", "POSTGRES_PORT=5432", "POSTGRES_USERNAME=payram", "POSTGRES_DATABASE=payram"}
+	changes := manifest.EnvChanges{
+		Additions: map[string]string{
+			"AES_KEY":           "replaced",
+			"POSTGRES_HOST":     "db.example.com",
+			"POSTGRES_PORT":     "6543",
+			"POSTGRES_USER":     "other",
+			"POSTGRES_DB":       "other",
+			"POSTGRES_DATABASE": "other",
+		},
+		Removals: []string{"POSTGRES_PASSWORD", "POSTGRES_USERNAME"},
+	}
+
+	reconciler := NewReconciler(&mockLogger{})
+	result, set, removed := reconciler.ApplyEnvChanges(env, changes)
+
+	if strings.Join(result, " ") != strings.Join(env, " ") {
+		t.Errorf("Expected secrets and database settings to be left alone, got %v", result)
+	}
+	if set != 0 || removed != 0 {
+		t.Errorf("Expected no changes, got %d set and %d removed", set, removed)
+	}
+}
+
 // TestReconcile_FullIntegration tests the full reconciliation flow.
 func TestReconcile_FullIntegration(t *testing.T) {
 	state := &RuntimeState{
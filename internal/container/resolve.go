@@ -3,12 +3,14 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/payram/payram-updater/internal/dockerexec"
 	"github.com/payram/payram-updater/internal/logger"
 	"github.com/payram/payram-updater/internal/manifest"
 )
@@ -92,8 +94,7 @@ func (r *Resolver) ValidateExists(ctx context.Context, containerName string) err
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		// Check if it's specifically a "not found" error
-		outputStr := strings.ToLower(string(output))
-		if strings.Contains(outputStr, "no such") || strings.Contains(outputStr, "not found") {
+		if errors.Is(dockerexec.NewCommandError("inspect", output, err), dockerexec.ErrContainerNotFound) {
 			return &ResolutionError{
 				FailureCode: "CONTAINER_NOT_FOUND",
 				Message:     fmt.Sprintf("Container '%s' not found", containerName),
@@ -126,6 +127,44 @@ func (r *Resolver) ResolveAndValidate(ctx context.Context, manifestData *manifes
 	return resolved, nil
 }
 
+// PayramDiscoverer finds the running Payram container (satisfied by *Discoverer).
+type PayramDiscoverer interface {
+	DiscoverPayramContainer(ctx context.Context) (*DiscoveredContainer, error)
+}
+
+// DiscoveryMismatch returns a warning when a configured container name (from
+// env or manifest) disagrees with the Payram container discovery finds
+// running, or "" when they agree or there is nothing to compare.
+func DiscoveryMismatch(resolved *ResolvedContainer, discovered *DiscoveredContainer) string {
+	if resolved == nil || resolved.Source == "" || discovered == nil || discovered.Name == "" {
+		return ""
+	}
+	if resolved.Name == discovered.Name {
+		return ""
+	}
+	return fmt.Sprintf("Configured container %q (from %s) does not match the running Payram container %q (image %s); "+
+		"operations will target %q. Fix TARGET_CONTAINER_NAME or the manifest container_name if that is not intended",
+		resolved.Name, resolved.Source, discovered.Name, discovered.ImageFull, resolved.Name)
+}
+
+// WarnOnDiscoveryMismatch runs discovery and logs a prominent warning if the
+// resolved name disagrees with it. It returns the warning, or "" when there is
+// no mismatch or discovery finds no container.
+func (r *Resolver) WarnOnDiscoveryMismatch(ctx context.Context, resolved *ResolvedContainer, discoverer PayramDiscoverer) string {
+	if resolved == nil || resolved.Source == "" {
+		return ""
+	}
+	discovered, err := discoverer.DiscoverPayramContainer(ctx)
+	if err != nil {
+		return ""
+	}
+	warning := DiscoveryMismatch(resolved, discovered)
+	if warning != "" {
+		r.logger.Printf("WARNING: %s", warning)
+	}
+	return warning
+}
+
 // ResolutionError represents a container resolution failure with a specific failure code.
 type ResolutionError struct {
 	FailureCode string
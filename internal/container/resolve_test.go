@@ -1,7 +1,11 @@
 package container
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log"
+	"strings"
 	"testing"
 
 	"github.com/payram/payram-updater/internal/manifest"
@@ -166,3 +170,58 @@ func TestResolver_ValidateExists_ContainerNotFound(t *testing.T) {
 		t.Errorf("expected failure code 'CONTAINER_NOT_FOUND', got '%s'", resErr.GetFailureCode())
 	}
 }
+
+type fakeDiscoverer struct {
+	found *DiscoveredContainer
+	err   error
+}
+
+func (f *fakeDiscoverer) DiscoverPayramContainer(ctx context.Context) (*DiscoveredContainer, error) {
+	return f.found, f.err
+}
+
+func TestDiscoveryMismatch(t *testing.T) {
+	discovered := &DiscoveredContainer{Name: "payram", ImageFull: "payramapp/payram:1.7.0"}
+	tests := []struct {
+		name       string
+		resolved   *ResolvedContainer
+		discovered *DiscoveredContainer
+		wantWarn   bool
+	}{
+		{"manifest name differs", &ResolvedContainer{Name: "payram-core", Source: SourceManifest}, discovered, true},
+		{"env name differs", &ResolvedContainer{Name: "old-payram", Source: SourceEnv}, discovered, true},
+		{"names match", &ResolvedContainer{Name: "payram", Source: SourceEnv}, discovered, false},
+		{"name came from discovery", &ResolvedContainer{Name: "other"}, discovered, false},
+		{"nothing discovered", &ResolvedContainer{Name: "payram-core", Source: SourceManifest}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning := DiscoveryMismatch(tt.resolved, tt.discovered)
+			if (warning != "") != tt.wantWarn {
+				t.Errorf("DiscoveryMismatch() = %q, want warning: %v", warning, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestResolver_WarnOnDiscoveryMismatch(t *testing.T) {
+	var logs bytes.Buffer
+	resolver := NewResolver("", "docker", log.New(&logs, "", 0))
+	resolved := &ResolvedContainer{Name: "payram-core", Source: SourceManifest}
+
+	warning := resolver.WarnOnDiscoveryMismatch(context.Background(), resolved, &fakeDiscoverer{
+		found: &DiscoveredContainer{Name: "payram", ImageFull: "payramapp/payram:1.7.0"},
+	})
+	if !strings.Contains(warning, `"payram-core"`) || !strings.Contains(warning, `"payram"`) {
+		t.Errorf("expected warning naming both containers, got %q", warning)
+	}
+	if !strings.Contains(logs.String(), "WARNING:") {
+		t.Errorf("expected a WARNING log line, got %q", logs.String())
+	}
+
+	logs.Reset()
+	warning = resolver.WarnOnDiscoveryMismatch(context.Background(), resolved, &fakeDiscoverer{err: errors.New("no containers")})
+	if warning != "" || logs.Len() != 0 {
+		t.Errorf("expected no warning when discovery fails, got %q (logs %q)", warning, logs.String())
+	}
+}
@@ -2,7 +2,6 @@ package coreclient
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +10,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/payram/payram-updater/internal/httpclient"
 )
 
 const (
@@ -51,25 +52,18 @@ type VersionResponse struct {
 // include the loopback IP.  For all other HTTPS endpoints the default TLS
 // verification is applied.
 func NewClient(baseURL string) *Client {
-	transport := http.DefaultTransport
+	httpClient := httpclient.New(DefaultTimeout)
 	if parsed, err := url.Parse(baseURL); err == nil && parsed.Scheme == "https" {
 		host := parsed.Hostname()
 		if host == "localhost" || host == "::1" {
-			transport = &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
-			}
+			httpClient = httpclient.NewLoopback(DefaultTimeout)
 		} else if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
-			transport = &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
-			}
+			httpClient = httpclient.NewLoopback(DefaultTimeout)
 		}
 	}
 	return &Client{
-		BaseURL: strings.TrimSuffix(baseURL, "/"),
-		HTTPClient: &http.Client{
-			Timeout:   DefaultTimeout,
-			Transport: transport,
-		},
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: httpClient,
 	}
 }
 
@@ -232,6 +232,27 @@ func TestClient_DefaultTimeout(t *testing.T) {
 	}
 }
 
+// TestHealth_SlowServerTimesOut tests that the client timeout is enforced.
+func TestHealth_SlowServerTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(server.URL)
+	client.HTTPClient.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := client.Health(context.Background()); err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the request to time out after ~100ms, took %s", elapsed)
+	}
+}
+
 // TestHealth_MissingFields tests handling of responses with missing required fields.
 func TestHealth_MissingFields(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
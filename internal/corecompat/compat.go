@@ -12,6 +12,7 @@ import (
 
 	"github.com/hashicorp/go-version"
 	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/httpclient"
 )
 
 const (
@@ -26,6 +27,20 @@ func NormalizeVersion(value string) string {
 	return value
 }
 
+// SameVersion reports whether a and b name the same version once both are
+// normalized and any build metadata ("+..."), which does not affect version
+// precedence, is dropped: "v1.7.0" and "1.7.0+build.5" are the same version.
+func SameVersion(a, b string) bool {
+	return stripBuildMetadata(NormalizeVersion(a)) == stripBuildMetadata(NormalizeVersion(b))
+}
+
+func stripBuildMetadata(value string) string {
+	if i := strings.Index(value, "+"); i >= 0 {
+		return value[:i]
+	}
+	return value
+}
+
 // IsBeforeInit returns true when currentVersion is lower than initVersion.
 func IsBeforeInit(currentVersion, initVersion string) (bool, error) {
 	initVersion = NormalizeVersion(initVersion)
@@ -51,7 +66,7 @@ func IsBeforeInit(currentVersion, initVersion string) (bool, error) {
 
 // LegacyHealth checks the root endpoint for the legacy welcome marker.
 func LegacyHealth(ctx context.Context, baseURL string) error {
-	client := &http.Client{Timeout: 3 * time.Second}
+	client := httpclient.New(3 * time.Second)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create legacy health request: %w", err)
@@ -0,0 +1,25 @@
+package corecompat
+
+import "testing"
+
+func TestSameVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.7.0", "1.7.0", true},
+		{"v1.7.0", "1.7.0", true},
+		{"1.7.0", " v1.7.0\n", true},
+		{"1.7.0+build.5", "1.7.0", true},
+		{"v1.7.0+abc", "1.7.0+def", true},
+		{"1.7.0-rc.1", "1.7.0", false},
+		{"1.7.0-rc.1+build.5", "v1.7.0-rc.1", true},
+		{"1.7.1", "1.7.0", false},
+		{"", "1.7.0", false},
+	}
+	for _, tt := range tests {
+		if got := SameVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameVersion(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
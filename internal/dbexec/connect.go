@@ -0,0 +1,40 @@
+package dbexec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// containerConnectScript connects to the database exactly as the Payram
+// container does: over TCP with the host, port, database, user, password and
+// SSL mode from the container's own environment. The credentials never leave
+// the container.
+const containerConnectScript = `PGPASSWORD="$POSTGRES_PASSWORD" PGSSLMODE="${POSTGRES_SSLMODE:-prefer}" ` +
+	`psql -h "${POSTGRES_HOST:-127.0.0.1}" -p "${POSTGRES_PORT:-5432}" ` +
+	`-U "${POSTGRES_USER:-$POSTGRES_USERNAME}" -d "${POSTGRES_DB:-$POSTGRES_DATABASE}" ` +
+	`-w -At -c 'SELECT 1'`
+
+// CheckContainerConnection verifies that containerName can connect to its
+// database with its own credentials, by running psql inside it. After a
+// restore this catches credentials that no longer match the restored
+// database (e.g. a password changed after the backup), which the restore
+// itself, run as the database superuser, does not notice. It fails with
+// POST_RESTORE_CONNECT_FAILED.
+func CheckContainerConnection(ctx context.Context, executor CommandExecutor, containerName string) error {
+	output, err := executor.Execute(ctx, "docker", []string{"exec", containerName, "sh", "-c", containerConnectScript}, nil)
+	if err != nil {
+		return &DBError{
+			Code:    ErrCodeConnectFailed,
+			Message: fmt.Sprintf("container %s cannot connect to its database with its own credentials: %s", containerName, strings.TrimSpace(string(output))),
+			Err:     err,
+		}
+	}
+	if strings.TrimSpace(string(output)) != "1" {
+		return &DBError{
+			Code:    ErrCodeConnectFailed,
+			Message: fmt.Sprintf("container %s got an unexpected answer to SELECT 1: %q", containerName, strings.TrimSpace(string(output))),
+		}
+	}
+	return nil
+}
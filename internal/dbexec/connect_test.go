@@ -0,0 +1,58 @@
+package dbexec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckContainerConnection_Succeeds(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return []byte("1\n"), nil
+		},
+	}
+
+	if err := CheckContainerConnection(context.Background(), executor, "payram"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(executor.calls) != 1 {
+		t.Fatalf("expected one docker call, got %d", len(executor.calls))
+	}
+	args := strings.Join(executor.calls[0].Args, " ")
+	if !strings.HasPrefix(args, "exec payram sh -c ") || !strings.Contains(args, `PGPASSWORD="$POSTGRES_PASSWORD"`) || !strings.Contains(args, `-h "${POSTGRES_HOST:-127.0.0.1}"`) {
+		t.Errorf("expected psql over TCP with the container's own credentials, got %q", args)
+	}
+}
+
+func TestCheckContainerConnection_AuthFailure(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return []byte(`psql: error: FATAL:  password authentication failed for user "payram"`), errors.New("exit status 2")
+		},
+	}
+
+	err := CheckContainerConnection(context.Background(), executor, "payram")
+	var dbErr *DBError
+	if !errors.As(err, &dbErr) || dbErr.Code != ErrCodeConnectFailed {
+		t.Fatalf("expected POST_RESTORE_CONNECT_FAILED, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "password authentication failed") {
+		t.Errorf("expected psql's error in the message, got %q", err.Error())
+	}
+}
+
+func TestCheckContainerConnection_UnexpectedOutput(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return []byte(""), nil
+		},
+	}
+
+	err := CheckContainerConnection(context.Background(), executor, "payram")
+	var dbErr *DBError
+	if !errors.As(err, &dbErr) || dbErr.Code != ErrCodeConnectFailed {
+		t.Fatalf("expected POST_RESTORE_CONNECT_FAILED, got %v", err)
+	}
+}
@@ -218,3 +218,109 @@ func TestHostPGExecutor_Dump(t *testing.T) {
 		t.Error("expected PGPASSWORD in environment")
 	}
 }
+
+// TestHostPGExecutor_Formats tests the pg_dump flag and restore tool per format.
+func TestHostPGExecutor_Formats(t *testing.T) {
+	tests := []struct {
+		format      string
+		path        string
+		dumpFlag    string
+		restoreTool string
+	}{
+		{"dump", "backup.dump", "-Fc", "pg_restore"},
+		{"sql", "backup.sql", "-Fp", "psql"},
+		{FormatDirectory, "backup.dir", "-Fd", "pg_restore"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.path)
+			executor := &mockExecutor{
+				executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+					if tt.format == FormatDirectory {
+						return nil, os.Mkdir(path, 0755)
+					}
+					return nil, os.WriteFile(path, []byte("backup data"), 0644)
+				},
+			}
+			pgExec := NewHostPGExecutor(executor, &mockLogger{})
+			dbCtx := DBContext{Mode: DBModeExternal, Creds: DBCreds{Host: "db.example.com", Port: "5432", Database: "payramdb", Username: "payram"}}
+
+			if err := pgExec.Dump(context.Background(), dbCtx, path, tt.format); err != nil {
+				t.Fatalf("Dump failed: %v", err)
+			}
+			executor.executeFunc = nil
+			if err := pgExec.Restore(context.Background(), dbCtx, path, tt.format); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+
+			dump, restore := executor.calls[0], executor.calls[1]
+			if !containsString(dump.Args, tt.dumpFlag) || !containsString(dump.Args, path) {
+				t.Errorf("expected pg_dump %s -f %s, got %v", tt.dumpFlag, path, dump.Args)
+			}
+			if restore.Name != tt.restoreTool || !containsString(restore.Args, path) {
+				t.Errorf("expected %s on %s, got %s %v", tt.restoreTool, path, restore.Name, restore.Args)
+			}
+		})
+	}
+}
+
+// TestDockerPGExecutor_DirectoryFormat tests that directory-format backups are
+// staged in the container and copied with docker cp, since they cannot be piped.
+func TestDockerPGExecutor_DirectoryFormat(t *testing.T) {
+	backupDir := filepath.Join(t.TempDir(), "payram-backup-20260301-120000-manual-to-manual.dir")
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			return nil, os.MkdirAll(backupDir, 0755)
+		},
+	}
+	pgExec := NewDockerPGExecutor(executor, &mockLogger{})
+	dbCtx := DBContext{Mode: DBModeInContainer, ContainerName: "payram-core", Creds: DBCreds{Database: "payramdb", Username: "payram"}}
+
+	if err := pgExec.Dump(context.Background(), dbCtx, backupDir, FormatDirectory); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	if err := pgExec.Restore(context.Background(), dbCtx, backupDir, FormatDirectory); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	staged := "/tmp/" + filepath.Base(backupDir)
+	dump := executor.calls[0].Args[1]
+	for _, want := range []string{"pg_dump -Fd -f " + staged, "docker cp payram-core:" + staged + " " + backupDir, "rm -rf " + staged} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("expected %q in dump command, got: %s", want, dump)
+		}
+	}
+	if strings.Contains(dump, ">") {
+		t.Errorf("expected no output redirection for a directory dump, got: %s", dump)
+	}
+	restore := executor.calls[1].Args[1]
+	for _, want := range []string{"docker cp " + backupDir + " payram-core:" + staged, "pg_restore --clean", "-d payramdb " + staged, "rm -rf " + staged} {
+		if !strings.Contains(restore, want) {
+			t.Errorf("expected %q in restore command, got: %s", want, restore)
+		}
+	}
+}
+
+func TestDockerPGExecutor_RestoreQuotesRole(t *testing.T) {
+	backupFile := filepath.Join(t.TempDir(), "backup.dump")
+	os.WriteFile(backupFile, []byte("backup data"), 0644)
+	executor := &mockExecutor{}
+	pgExec := NewDockerPGExecutor(executor, &mockLogger{})
+	dbCtx := DBContext{Mode: DBModeInContainer, ContainerName: "payram-core", Creds: DBCreds{Database: "payramdb", Username: "db admin; rm -rf /"}}
+
+	if err := pgExec.Restore(context.Background(), dbCtx, backupFile, "dump"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restore := executor.calls[0].Args[1]; !strings.Contains(restore, "-U 'db admin; rm -rf /' -d payramdb") {
+		t.Errorf("expected the role to be shell-quoted, got: %s", restore)
+	}
+}
+
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
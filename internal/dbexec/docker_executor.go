@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // DockerPGExecutor executes PostgreSQL operations inside a Docker container.
@@ -52,21 +53,31 @@ func (e *DockerPGExecutor) Dump(ctx context.Context, db DBContext, outFile strin
 		}
 	}
 
-	// Build pg_dump command with appropriate format flag
-	formatFlag := "-Fc" // custom format by default
-	if format == "sql" {
-		formatFlag = "-Fp" // plain SQL format
-	}
-
 	// Build the docker exec command
 	// We redirect output to the host file system
 	shellCmd := fmt.Sprintf("docker exec %s pg_dump %s -U %s -d %s > %s",
 		db.ContainerName,
-		formatFlag,
-		db.Creds.Username,
-		db.Creds.Database,
+		PGDumpFormatFlag(format),
+		shellQuote(db.Creds.Username),
+		shellQuote(db.Creds.Database),
 		absOutFile,
 	)
+	if format == FormatDirectory {
+		// A directory dump cannot be streamed: write it inside the
+		// container, copy it out, and always remove the container copy.
+		tmpDir := containerTempPath(absOutFile)
+		shellCmd = fmt.Sprintf("docker exec %s pg_dump -Fd -f %s -U %s -d %s && docker cp %s:%s %s; rc=$?; docker exec %s rm -rf %s; exit $rc",
+			db.ContainerName,
+			tmpDir,
+			shellQuote(db.Creds.Username),
+			shellQuote(db.Creds.Database),
+			db.ContainerName,
+			tmpDir,
+			absOutFile,
+			db.ContainerName,
+			tmpDir,
+		)
+	}
 
 	e.Logger.Printf("[DockerPGExecutor] Running: docker exec %s pg_dump ...", db.ContainerName)
 
@@ -126,21 +137,35 @@ func (e *DockerPGExecutor) Restore(ctx context.Context, db DBContext, inFile str
 	}
 
 	var shellCmd string
-	if format == "sql" {
+	if format == FormatDirectory {
+		e.Logger.Printf("Executing pg_restore inside container: %s", db.ContainerName)
+		tmpDir := containerTempPath(absInFile)
+		shellCmd = fmt.Sprintf("docker cp %s %s:%s && docker exec %s pg_restore --clean --if-exists --no-owner --no-privileges -U %s -d %s %s; rc=$?; docker exec %s rm -rf %s; exit $rc",
+			absInFile,
+			db.ContainerName,
+			tmpDir,
+			db.ContainerName,
+			shellQuote(db.Creds.Username),
+			shellQuote(db.Creds.Database),
+			tmpDir,
+			db.ContainerName,
+			tmpDir,
+		)
+	} else if format == "sql" {
 		e.Logger.Printf("Executing psql inside container: %s", db.ContainerName)
 		shellCmd = fmt.Sprintf("cat %s | docker exec -i %s psql -U %s -d %s",
 			absInFile,
 			db.ContainerName,
-			db.Creds.Username,
-			db.Creds.Database,
+			shellQuote(db.Creds.Username),
+			shellQuote(db.Creds.Database),
 		)
 	} else {
 		e.Logger.Printf("Executing pg_restore inside container: %s", db.ContainerName)
 		shellCmd = fmt.Sprintf("cat %s | docker exec -i %s pg_restore --clean --if-exists --no-owner --no-privileges -U %s -d %s",
 			absInFile,
 			db.ContainerName,
-			db.Creds.Username,
-			db.Creds.Database,
+			shellQuote(db.Creds.Username),
+			shellQuote(db.Creds.Database),
 		)
 	}
 
@@ -158,3 +183,84 @@ func (e *DockerPGExecutor) Restore(ctx context.Context, db DBContext, inFile str
 	e.Logger.Printf("Database restored successfully from: %s", absInFile)
 	return nil
 }
+
+// ListArchive lists the contents of a custom or directory format backup with
+// pg_restore inside the container.
+func (e *DockerPGExecutor) ListArchive(ctx context.Context, db DBContext, inFile string, format string) (string, error) {
+	if db.ContainerName == "" {
+		return "", &DBError{
+			Code:    "CONTAINER_NOT_FOUND",
+			Message: "container name is required for in-container database operations",
+		}
+	}
+	absInFile, err := filepath.Abs(inFile)
+	if err != nil {
+		return "", &DBError{Code: ErrCodeRestoreFailed, Message: "failed to get absolute path for backup file", Err: err}
+	}
+
+	shellCmd := fmt.Sprintf("cat %s | docker exec -i %s pg_restore --list", absInFile, db.ContainerName)
+	if format == FormatDirectory {
+		tmpDir := containerTempPath(absInFile)
+		shellCmd = fmt.Sprintf("docker cp %s %s:%s && docker exec %s pg_restore --list %s; rc=$?; docker exec %s rm -rf %s; exit $rc",
+			absInFile,
+			db.ContainerName,
+			tmpDir,
+			db.ContainerName,
+			tmpDir,
+			db.ContainerName,
+			tmpDir,
+		)
+	}
+
+	output, err := e.Executor.Execute(ctx, "sh", []string{"-c", shellCmd}, nil)
+	if err != nil {
+		return "", &DBError{
+			Code:    ErrCodeRestoreFailed,
+			Message: fmt.Sprintf("pg_restore --list (container) failed: %v: %s", err, string(output)),
+			Err:     err,
+		}
+	}
+	return string(output), nil
+}
+
+// Query runs sql with psql inside the container.
+func (e *DockerPGExecutor) Query(ctx context.Context, db DBContext, sql string) (string, error) {
+	if db.Mode != DBModeInContainer {
+		return "", &DBError{
+			Code:    "INVALID_DB_CONFIG",
+			Message: "DockerPGExecutor can only be used with in-container databases",
+		}
+	}
+	if db.ContainerName == "" {
+		return "", &DBError{
+			Code:    "CONTAINER_NOT_FOUND",
+			Message: "container name is required for in-container database operations",
+		}
+	}
+
+	args := []string{"exec", db.ContainerName, "psql", "-U", db.Creds.Username, "-d", db.Creds.Database, "-At", "-F", "|", "-c", sql}
+	output, err := e.Executor.Execute(ctx, "docker", args, nil)
+	if err != nil {
+		return "", &DBError{
+			Code:    ErrCodeRestoreFailed,
+			Message: fmt.Sprintf("psql query (container) failed: %v: %s", err, string(output)),
+			Err:     err,
+		}
+	}
+	return string(output), nil
+}
+
+// containerTempPath is where a directory-format backup is staged inside the
+// container while it is dumped or restored.
+func containerTempPath(hostPath string) string {
+	return "/tmp/" + filepath.Base(hostPath)
+}
+
+// shellQuote quotes arg for use as a single sh word, leaving plain words
+// as they are.
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -62,11 +62,7 @@ func (e *HostPGExecutor) Dump(ctx context.Context, db DBContext, outFile string,
 	}
 
 	// Add format flag
-	if format == "sql" {
-		args = append(args, "-Fp") // plain SQL format
-	} else {
-		args = append(args, "-Fc") // custom format
-	}
+	args = append(args, PGDumpFormatFlag(format))
 
 	// Build environment with PGPASSWORD
 	env := os.Environ()
@@ -169,3 +165,53 @@ func (e *HostPGExecutor) Restore(ctx context.Context, db DBContext, inFile strin
 	e.Logger.Printf("Database restored successfully from: %s", absInFile)
 	return nil
 }
+
+// ListArchive lists the contents of a custom or directory format backup with
+// the host pg_restore. No database connection is needed.
+func (e *HostPGExecutor) ListArchive(ctx context.Context, db DBContext, inFile string, format string) (string, error) {
+	absInFile, err := filepath.Abs(inFile)
+	if err != nil {
+		return "", &DBError{Code: ErrCodeRestoreFailed, Message: "failed to get absolute path for backup file", Err: err}
+	}
+	output, err := e.Executor.Execute(ctx, e.PGRestoreBin, []string{"--list", absInFile}, nil)
+	if err != nil {
+		return "", &DBError{
+			Code:    ErrCodeRestoreFailed,
+			Message: fmt.Sprintf("pg_restore --list (host) failed: %v: %s", err, string(output)),
+			Err:     err,
+		}
+	}
+	return string(output), nil
+}
+
+// Query runs sql with the host psql against the external database.
+func (e *HostPGExecutor) Query(ctx context.Context, db DBContext, sql string) (string, error) {
+	if db.Mode == DBModeInContainer {
+		return "", &DBError{
+			Code:    "INVALID_DB_CONFIG",
+			Message: "HostPGExecutor can only be used with external databases",
+		}
+	}
+
+	env := os.Environ()
+	if db.Creds.Password != "" {
+		env = append(env, fmt.Sprintf("PGPASSWORD=%s", db.Creds.Password))
+	}
+	args := []string{
+		"-h", db.Creds.Host,
+		"-p", db.Creds.Port,
+		"-U", db.Creds.Username,
+		"-d", db.Creds.Database,
+		"-At", "-F", "|",
+		"-c", sql,
+	}
+	output, err := e.Executor.Execute(ctx, e.PSQLBin, args, env)
+	if err != nil {
+		return "", &DBError{
+			Code:    ErrCodeRestoreFailed,
+			Message: fmt.Sprintf("psql query (host) failed: %v: %s", err, string(output)),
+			Err:     err,
+		}
+	}
+	return string(output), nil
+}
@@ -59,15 +59,40 @@ type DBContext struct {
 	ContainerName string // set only for in_container mode
 }
 
+// FormatDirectory is the Dump/Restore format of a pg_dump directory-format
+// backup, which is a directory rather than a file.
+const FormatDirectory = "directory"
+
 // PGExecutor defines the interface for executing PostgreSQL operations.
 type PGExecutor interface {
 	// Dump creates a database backup.
-	// format should be "sql" for plain SQL or "dump" for custom format.
+	// format should be "sql" for plain SQL, FormatDirectory for directory
+	// format or "dump" for custom format.
 	Dump(ctx context.Context, db DBContext, outFile string, format string) error
 
-	// Restore restores a database from a backup.
-	// format should be "sql" for plain SQL or "dump" for custom format.
+	// Restore restores a database from a backup: "sql" backups with psql,
+	// custom and directory format backups with pg_restore.
 	Restore(ctx context.Context, db DBContext, inFile string, format string) error
+
+	// ListArchive returns the table of contents (pg_restore --list) of a
+	// custom or directory format backup.
+	ListArchive(ctx context.Context, db DBContext, inFile string, format string) (string, error)
+
+	// Query runs an SQL statement and returns its rows, one per line with
+	// columns separated by "|".
+	Query(ctx context.Context, db DBContext, sql string) (string, error)
+}
+
+// PGDumpFormatFlag returns the pg_dump -F flag for a Dump format.
+func PGDumpFormatFlag(format string) string {
+	switch format {
+	case "sql":
+		return "-Fp" // plain SQL format
+	case FormatDirectory:
+		return "-Fd"
+	default:
+		return "-Fc" // custom format
+	}
 }
 
 // DBError represents a database operation error with a code.
@@ -83,6 +108,7 @@ const (
 	ErrCodeInvalidConfig     = "INVALID_DB_CONFIG"
 	ErrCodeBackupFailed      = "BACKUP_FAILED"
 	ErrCodeRestoreFailed     = "RESTORE_FAILED"
+	ErrCodeConnectFailed     = "POST_RESTORE_CONNECT_FAILED"
 )
 
 func (e *DBError) Error() string {
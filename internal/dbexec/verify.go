@@ -0,0 +1,170 @@
+package dbexec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ObjectCounts maps an object kind, as pg_restore --list names it (TABLE,
+// VIEW, ...), to the number of such objects.
+type ObjectCounts map[string]int
+
+// verifiedKinds are the object kinds compared after a restore. Kinds that
+// also start other entry types are matched against those first, so a
+// "TABLE DATA" entry is not counted as a TABLE.
+var verifiedKinds = []string{"MATERIALIZED VIEW", "VIEW", "TABLE", "SEQUENCE", "INDEX"}
+
+// notObjectEntries are pg_restore --list entry types that share a prefix
+// with a verified kind but do not create one.
+var notObjectEntries = []string{
+	"MATERIALIZED VIEW DATA",
+	"TABLE DATA",
+	"TABLE ATTACH",
+	"SEQUENCE SET",
+	"SEQUENCE OWNED BY",
+	"INDEX ATTACH",
+}
+
+// ObjectCountQuery counts the objects of each verified kind in the database,
+// leaving out system schemas, extension members and the indexes behind
+// primary key, unique and exclusion constraints, which pg_dump lists as
+// constraints rather than indexes.
+const ObjectCountQuery = `SELECT CASE c.relkind
+    WHEN 'r' THEN 'TABLE' WHEN 'p' THEN 'TABLE'
+    WHEN 'v' THEN 'VIEW' WHEN 'm' THEN 'MATERIALIZED VIEW'
+    WHEN 'S' THEN 'SEQUENCE' ELSE 'INDEX' END, count(*)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'v', 'm', 'S', 'i', 'I')
+  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+  AND n.nspname NOT LIKE 'pg\_toast%' AND n.nspname NOT LIKE 'pg\_temp%'
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'e')
+  AND NOT EXISTS (SELECT 1 FROM pg_constraint k WHERE k.conindid = c.oid AND k.contype IN ('p', 'u', 'x'))
+GROUP BY 1`
+
+// RestoreEntry is one entry of pg_restore --list output.
+type RestoreEntry struct {
+	Object string // type, schema and name, e.g. "TABLE public users"
+	Owner  string // empty for an object without owner, e.g. an EXTENSION
+}
+
+// ParseRestoreEntries returns the entries of pg_restore --list output, in
+// order. Entries look like "215; 1259 16386 TABLE public users payram": a
+// dump ID, the catalog table OID and object OID, then the object and its
+// owner. An object without owner ends in a blank one. Comments and any other
+// lines are ignored.
+func ParseRestoreEntries(list string) []RestoreEntry {
+	var entries []RestoreEntry
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(strings.TrimSpace(line), ";") {
+			continue
+		}
+		_, entry, ok := strings.Cut(line, ";")
+		if !ok {
+			continue
+		}
+		// Skip the catalog table OID and object OID
+		fields := strings.Fields(entry)
+		if len(fields) < 3 {
+			continue
+		}
+		fields = fields[2:]
+		var owner string
+		if !strings.HasSuffix(entry, " ") && len(fields) > 2 {
+			owner = fields[len(fields)-1]
+			fields = fields[:len(fields)-1]
+		}
+		entries = append(entries, RestoreEntry{Object: strings.Join(fields, " "), Owner: owner})
+	}
+	return entries
+}
+
+// ParseRestoreList counts the objects of each verified kind in pg_restore
+// --list output, as parsed by ParseRestoreEntries.
+func ParseRestoreList(list string) ObjectCounts {
+	counts := ObjectCounts{}
+	for _, entry := range ParseRestoreEntries(list) {
+		if kind := entryKind(entry.Object); kind != "" {
+			counts[kind]++
+		}
+	}
+	return counts
+}
+
+// entryKind returns the verified kind a pg_restore --list entry description
+// creates, or "" if it creates none.
+func entryKind(desc string) string {
+	for _, other := range notObjectEntries {
+		if strings.HasPrefix(desc, other+" ") {
+			return ""
+		}
+	}
+	for _, kind := range verifiedKinds {
+		if strings.HasPrefix(desc, kind+" ") {
+			return kind
+		}
+	}
+	return ""
+}
+
+// ParseObjectCounts parses the "KIND|count" rows of ObjectCountQuery.
+func ParseObjectCounts(output string) (ObjectCounts, error) {
+	counts := ObjectCounts{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		kind, value, ok := strings.Cut(line, "|")
+		if !ok {
+			return nil, fmt.Errorf("unexpected object count row %q", line)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected object count row %q: %w", line, err)
+		}
+		counts[strings.TrimSpace(kind)] += n
+	}
+	return counts, nil
+}
+
+// CompareObjectCounts returns one discrepancy per verified kind whose count
+// in the database differs from the backup's, in kind order.
+func CompareObjectCounts(backup, database ObjectCounts) []string {
+	kinds := append([]string(nil), verifiedKinds...)
+	sort.Strings(kinds)
+
+	var discrepancies []string
+	for _, kind := range kinds {
+		want, got := backup[kind], database[kind]
+		switch {
+		case got < want:
+			discrepancies = append(discrepancies, fmt.Sprintf("%s: backup has %d, database has %d (%d missing)", kind, want, got, want-got))
+		case got > want:
+			discrepancies = append(discrepancies, fmt.Sprintf("%s: backup has %d, database has %d (%d not in the backup)", kind, want, got, got-want))
+		}
+	}
+	return discrepancies
+}
+
+// VerifyRestoredObjects compares the objects listed in a custom or directory
+// format backup with those present in the database it was restored into,
+// and returns the discrepancies. It is a sanity check on object counts, not
+// a validation of the data.
+func VerifyRestoredObjects(ctx context.Context, pgExec PGExecutor, db DBContext, inFile string, format string) ([]string, error) {
+	list, err := pgExec.ListArchive(ctx, db, inFile, format)
+	if err != nil {
+		return nil, err
+	}
+	output, err := pgExec.Query(ctx, db, ObjectCountQuery)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := ParseObjectCounts(output)
+	if err != nil {
+		return nil, err
+	}
+	return CompareObjectCounts(ParseRestoreList(list), actual), nil
+}
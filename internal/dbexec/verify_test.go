@@ -0,0 +1,118 @@
+package dbexec
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// restoreListFixture is pg_restore --list output for a small custom-format backup.
+const restoreListFixture = `;
+; Archive created at 2026-03-01 12:00:00 UTC
+;     dbname: payram
+;     TOC Entries: 24
+;     Compression: gzip
+;     Dump Version: 1.15-0
+;     Format: CUSTOM
+;
+; Selected TOC Entries:
+;
+4; 2615 2200 SCHEMA - public pg_database_owner
+2; 3079 16385 EXTENSION - pgcrypto
+215; 1259 16400 TABLE public users payram
+216; 1259 16410 TABLE public payments payram
+217; 1259 16420 TABLE public "order items" payram
+218; 1259 16399 SEQUENCE public users_id_seq payram
+3350; 0 0 SEQUENCE OWNED BY public users_id_seq payram
+219; 1259 16430 VIEW public active_users payram
+220; 1259 16440 MATERIALIZED VIEW public daily_totals payram
+3340; 0 16400 TABLE DATA public users payram
+3341; 0 16410 TABLE DATA public payments payram
+3360; 0 0 SEQUENCE SET public users_id_seq payram
+3200; 2606 16450 CONSTRAINT public users users_pkey payram
+3201; 1259 16460 INDEX public payments_user_idx payram
+3202; 1259 16461 INDEX public payments_created_idx payram
+3203; 2606 16470 FK CONSTRAINT public payments payments_user_fk payram
+3380; 0 16440 MATERIALIZED VIEW DATA public daily_totals payram
+`
+
+func TestParseRestoreList(t *testing.T) {
+	got := ParseRestoreList(restoreListFixture)
+	want := ObjectCounts{"TABLE": 3, "SEQUENCE": 1, "VIEW": 1, "MATERIALIZED VIEW": 1, "INDEX": 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseRestoreEntries(t *testing.T) {
+	got := ParseRestoreEntries("; comment\n4; 3079 16385 EXTENSION - pgcrypto \r\n215; 1259 16400 TABLE public users payram\nnot an entry\n")
+	want := []RestoreEntry{
+		{Object: "EXTENSION - pgcrypto"},
+		{Object: "TABLE public users", Owner: "payram"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseObjectCounts(t *testing.T) {
+	got, err := ParseObjectCounts("TABLE|3\nINDEX|2\nMATERIALIZED VIEW|1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ObjectCounts{"TABLE": 3, "INDEX": 2, "MATERIALIZED VIEW": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := ParseObjectCounts("psql: error: connection refused"); err == nil {
+		t.Error("expected an error for output that is not count rows")
+	}
+}
+
+func TestCompareObjectCounts(t *testing.T) {
+	backup := ParseRestoreList(restoreListFixture)
+
+	if got := CompareObjectCounts(backup, ObjectCounts{"TABLE": 3, "SEQUENCE": 1, "VIEW": 1, "MATERIALIZED VIEW": 1, "INDEX": 2}); len(got) != 0 {
+		t.Errorf("expected no discrepancies for matching counts, got %v", got)
+	}
+
+	got := CompareObjectCounts(backup, ObjectCounts{"TABLE": 2, "SEQUENCE": 1, "VIEW": 1, "MATERIALIZED VIEW": 1, "INDEX": 3})
+	want := []string{
+		"INDEX: backup has 2, database has 3 (1 not in the backup)",
+		"TABLE: backup has 3, database has 2 (1 missing)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestVerifyRestoredObjects_DockerExecutor(t *testing.T) {
+	executor := &mockExecutor{
+		executeFunc: func(ctx context.Context, name string, args []string, env []string) ([]byte, error) {
+			if name == "sh" {
+				return []byte(restoreListFixture), nil
+			}
+			return []byte("TABLE|3\nSEQUENCE|1\nVIEW|1\nMATERIALIZED VIEW|0\nINDEX|2\n"), nil
+		},
+	}
+	pgExec := NewDockerPGExecutor(executor, &mockLogger{})
+	dbCtx := DBContext{Mode: DBModeInContainer, ContainerName: "payram-core", Creds: DBCreds{Database: "payramdb", Username: "payram"}}
+
+	got, err := VerifyRestoredObjects(context.Background(), pgExec, dbCtx, "/backups/test.dump", "dump")
+	if err != nil {
+		t.Fatalf("VerifyRestoredObjects failed: %v", err)
+	}
+	if len(got) != 1 || !strings.HasPrefix(got[0], "MATERIALIZED VIEW: backup has 1, database has 0") {
+		t.Errorf("expected the missing materialized view, got %v", got)
+	}
+
+	if list := executor.calls[0].Args[1]; !strings.Contains(list, "docker exec -i payram-core pg_restore --list") {
+		t.Errorf("expected pg_restore --list in the container, got: %s", list)
+	}
+	query := executor.calls[1]
+	if query.Name != "docker" || !containsString(query.Args, "payram-core") || !containsString(query.Args, ObjectCountQuery) {
+		t.Errorf("expected the count query via docker exec psql, got: %s %v", query.Name, query.Args)
+	}
+}
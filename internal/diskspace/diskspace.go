@@ -112,3 +112,85 @@ func FormatCheckResults(results []CheckResult) []string {
 
 	return formatted
 }
+
+// DeviceFunc returns the ID of the filesystem holding path.
+type DeviceFunc func(path string) (uint64, error)
+
+// PathDevice is the DeviceFunc backed by stat(2).
+func PathDevice(path string) (uint64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Dev), nil
+}
+
+// SameFilesystem reports whether a and b live on the same filesystem, by
+// comparing their device IDs.
+func SameFilesystem(device DeviceFunc, a, b string) (bool, error) {
+	devA, err := device(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", a, err)
+	}
+	devB, err := device(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", b, err)
+	}
+	return devA == devB, nil
+}
+
+// RetentionSampleSize is how many of the newest backups ProjectRetention
+// averages, so the projection follows recent database growth.
+const RetentionSampleSize = 5
+
+// RetentionProjection estimates the backup directory's footprint once
+// retention is full, from the average size of recent backups.
+type RetentionProjection struct {
+	Retention   int
+	AverageGB   float64 // mean size of the sampled backups
+	CurrentGB   float64 // size of the backups kept today
+	ProjectedGB float64 // Retention * AverageGB
+	GrowthGB    float64 // ProjectedGB - CurrentGB, never negative
+	AvailableGB float64
+}
+
+// Exceeds reports whether the projected growth does not fit in the free space.
+func (p RetentionProjection) Exceeds() bool {
+	return p.GrowthGB > p.AvailableGB
+}
+
+// ProjectRetention projects the steady-state footprint of retention backups.
+// sizes are the kept backups' sizes in bytes, newest first. ok is false when
+// there is nothing to project from.
+func ProjectRetention(sizes []int64, retention int, availableGB float64) (RetentionProjection, bool) {
+	if retention < 1 || len(sizes) == 0 {
+		return RetentionProjection{}, false
+	}
+
+	const gb = 1024 * 1024 * 1024
+	var current, sampled int64
+	for i, size := range sizes {
+		if i < retention {
+			current += size
+		}
+		if i < RetentionSampleSize {
+			sampled += size
+		}
+	}
+	samples := len(sizes)
+	if samples > RetentionSampleSize {
+		samples = RetentionSampleSize
+	}
+
+	p := RetentionProjection{
+		Retention:   retention,
+		AverageGB:   float64(sampled) / float64(samples) / gb,
+		CurrentGB:   float64(current) / gb,
+		AvailableGB: availableGB,
+	}
+	p.ProjectedGB = p.AverageGB * float64(retention)
+	if p.ProjectedGB > p.CurrentGB {
+		p.GrowthGB = p.ProjectedGB - p.CurrentGB
+	}
+	return p, true
+}
@@ -1,6 +1,9 @@
 package diskspace
 
 import (
+	"errors"
+	"math"
+	"os"
 	"strings"
 	"testing"
 )
@@ -152,3 +155,80 @@ func TestFormatCheckResults_PathNotExists(t *testing.T) {
 		t.Errorf("Expected 'path does not exist' message, got: %s", formatted[0])
 	}
 }
+
+func TestSameFilesystem(t *testing.T) {
+	devices := map[string]uint64{
+		"/var/lib/payram-updater/backups": 2049,
+		"/var/lib/docker/volumes/pgdata":  2049,
+		"/mnt/backups":                    2065,
+	}
+	device := func(path string) (uint64, error) {
+		dev, ok := devices[path]
+		if !ok {
+			return 0, os.ErrNotExist
+		}
+		return dev, nil
+	}
+
+	shared, err := SameFilesystem(device, "/var/lib/payram-updater/backups", "/var/lib/docker/volumes/pgdata")
+	if err != nil || !shared {
+		t.Errorf("expected a shared filesystem, got %v (err %v)", shared, err)
+	}
+	shared, err = SameFilesystem(device, "/mnt/backups", "/var/lib/docker/volumes/pgdata")
+	if err != nil || shared {
+		t.Errorf("expected different filesystems, got %v (err %v)", shared, err)
+	}
+	if _, err := SameFilesystem(device, "/missing", "/mnt/backups"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the stat error, got %v", err)
+	}
+}
+
+func TestPathDevice_SameDirectory(t *testing.T) {
+	dir := t.TempDir()
+	shared, err := SameFilesystem(PathDevice, dir, dir)
+	if err != nil || !shared {
+		t.Errorf("expected a directory to share its own filesystem, got %v (err %v)", shared, err)
+	}
+}
+
+func TestProjectRetention(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	tests := []struct {
+		name        string
+		sizes       []int64
+		retention   int
+		availableGB float64
+		wantAverage float64
+		wantGrowth  float64
+		wantExceeds bool
+	}{
+		{"fills up past free space", []int64{2 * gb, 2 * gb}, 10, 10, 2, 16, true},
+		{"fits in free space", []int64{2 * gb, 2 * gb}, 10, 20, 2, 16, false},
+		{"averages only recent backups", []int64{4 * gb, 4 * gb, 4 * gb, 4 * gb, 4 * gb, 1 * gb}, 6, 1, 4, 3, true},
+		{"already at retention", []int64{3 * gb, 1 * gb, 2 * gb}, 3, 0.5, 2, 0, false},
+		{"more kept than retention", []int64{1 * gb, 1 * gb, 5 * gb}, 2, 0.5, 7.0 / 3, 8.0 / 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := ProjectRetention(tt.sizes, tt.retention, tt.availableGB)
+			if !ok {
+				t.Fatal("expected a projection")
+			}
+			if math.Abs(p.AverageGB-tt.wantAverage) > 1e-9 || math.Abs(p.GrowthGB-tt.wantGrowth) > 1e-9 {
+				t.Errorf("expected average %.2f GB and growth %.2f GB, got %.2f and %.2f", tt.wantAverage, tt.wantGrowth, p.AverageGB, p.GrowthGB)
+			}
+			if p.Exceeds() != tt.wantExceeds {
+				t.Errorf("expected Exceeds()=%v for %+v", tt.wantExceeds, p)
+			}
+		})
+	}
+}
+
+func TestProjectRetention_NothingToProject(t *testing.T) {
+	if _, ok := ProjectRetention(nil, 10, 5); ok {
+		t.Error("expected no projection without backups")
+	}
+	if _, ok := ProjectRetention([]int64{1024}, 0, 5); ok {
+		t.Error("expected no projection without retention")
+	}
+}
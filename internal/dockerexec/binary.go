@@ -0,0 +1,46 @@
+package dockerexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Errors returned by CheckBinary.
+var (
+	ErrBinaryNotFound = errors.New("docker binary not found or not executable")
+	ErrNotDocker      = errors.New("binary does not behave like docker")
+)
+
+// BinaryFixHint tells the operator how to fix a CheckBinary failure.
+const BinaryFixHint = "Set DOCKER_BIN to the docker CLI (the output of 'command -v docker'), then restart the updater."
+
+// clientVersionPattern matches the client version 'docker version' prints.
+var clientVersionPattern = regexp.MustCompile(`^v?[0-9]+\.[0-9]+`)
+
+// CheckBinary verifies that dockerBin exists, is executable and answers
+// 'docker version' with a client version. The daemon need not be running:
+// docker prints its client version before failing to reach the daemon, and
+// CheckDockerDaemon reports a down daemon separately. Errors name the
+// configured path and match ErrBinaryNotFound or ErrNotDocker.
+func CheckBinary(ctx context.Context, dockerBin string) error {
+	path, err := exec.LookPath(dockerBin)
+	if err != nil {
+		return fmt.Errorf("%w: DOCKER_BIN=%q: %v", ErrBinaryNotFound, dockerBin, err)
+	}
+
+	// Only stdout carries the version; a down daemon is reported on stderr.
+	output, err := exec.CommandContext(ctx, path, "version", "--format", "{{.Client.Version}}").Output()
+	version := strings.TrimSpace(string(output))
+	if !clientVersionPattern.MatchString(version) {
+		detail := fmt.Sprintf("'%s version' printed %q", dockerBin, version)
+		if err != nil {
+			detail = fmt.Sprintf("'%s version' failed: %v", dockerBin, err)
+		}
+		return fmt.Errorf("%w: DOCKER_BIN=%q: %s", ErrNotDocker, dockerBin, detail)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package dockerexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScript writes an executable shell script and returns its path.
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckBinary_MissingBinary(t *testing.T) {
+	bogus := filepath.Join(t.TempDir(), "no-such-docker")
+
+	err := CheckBinary(context.Background(), bogus)
+
+	if !errors.Is(err, ErrBinaryNotFound) {
+		t.Fatalf("expected ErrBinaryNotFound, got %v", err)
+	}
+	if !strings.Contains(err.Error(), bogus) {
+		t.Errorf("expected the configured path in the error, got %v", err)
+	}
+}
+
+func TestCheckBinary_NotExecutable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho 27.1.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckBinary(context.Background(), path); !errors.Is(err, ErrBinaryNotFound) {
+		t.Fatalf("expected ErrBinaryNotFound for a non-executable file, got %v", err)
+	}
+}
+
+func TestCheckBinary_NotDocker(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"prints nothing", "#!/bin/sh\nexit 0\n"},
+		{"echoes its arguments", "#!/bin/sh\necho \"$@\"\n"},
+		{"fails", "#!/bin/sh\necho 'unknown command: version' >&2\nexit 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeScript(t, tt.script)
+
+			err := CheckBinary(context.Background(), path)
+
+			if !errors.Is(err, ErrNotDocker) {
+				t.Fatalf("expected ErrNotDocker, got %v", err)
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Errorf("expected the configured path in the error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckBinary_DockerWithDaemonDown(t *testing.T) {
+	path := writeScript(t, "#!/bin/sh\necho 27.1.1\necho 'Cannot connect to the Docker daemon at unix:///var/run/docker.sock' >&2\nexit 1\n")
+
+	if err := CheckBinary(context.Background(), path); err != nil {
+		t.Fatalf("expected a docker client to pass without a daemon, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package dockerexec
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Typed errors for the docker failures callers branch on. Runner methods
+// return a *CommandError that matches one of these with errors.Is, so
+// callers need not match docker's messages themselves.
+var (
+	ErrDaemonDown              = errors.New("docker daemon is not reachable")
+	ErrPermissionDenied        = errors.New("permission denied")
+	ErrContainerNotFound       = errors.New("container not found")
+	ErrContainerNotRunning     = errors.New("container is not running")
+	ErrContainerAlreadyRunning = errors.New("container is already running")
+	ErrNameConflict            = errors.New("container name already in use")
+	ErrVolumeNotFound          = errors.New("volume not found")
+	ErrRateLimited             = errors.New("registry rate limit reached")
+	ErrImageNotFound           = errors.New("image not found")
+	ErrRegistryAuth            = errors.New("registry authentication failed")
+	ErrInvalidReference        = errors.New("invalid image reference")
+	ErrExecutableNotFound      = errors.New("executable not found in container")
+)
+
+// errorPatterns maps fragments of docker's output, lowercased, to the typed
+// error they indicate. The first match wins, so more specific fragments come
+// before the general ones they contain (a private image's "pull access
+// denied" before "denied"). A pattern with an op only applies to that docker
+// subcommand: a shell's "not found" means a missing executable in docker exec
+// output, but a missing image in docker pull output.
+var errorPatterns = []struct {
+	op       string // docker subcommand the pattern is limited to, or empty for any
+	fragment string
+	kind     error
+}{
+	{"", "cannot connect to the docker daemon", ErrDaemonDown},
+	{"", "is the docker daemon running", ErrDaemonDown},
+	{"", "error during connect", ErrDaemonDown},
+	{"", "permission denied", ErrPermissionDenied},
+	{"", "no such container", ErrContainerNotFound},
+	{"", "no such object", ErrContainerNotFound},
+	{"", "no such volume", ErrVolumeNotFound},
+	{"", "is already in use by container", ErrNameConflict},
+	{"", "is not running", ErrContainerNotRunning},
+	{"", "already stopped", ErrContainerNotRunning},
+	{"", "is already running", ErrContainerAlreadyRunning},
+	{"", "toomanyrequests", ErrRateLimited},
+	{"", "too many requests", ErrRateLimited},
+	{"", "manifest unknown", ErrImageNotFound},
+	{"", "manifest for", ErrImageNotFound},
+	{"", "no such image", ErrImageNotFound},
+	{"", "pull access denied", ErrImageNotFound},
+	{"", "repository does not exist", ErrImageNotFound},
+	{"", "unauthorized", ErrRegistryAuth},
+	{"", "authentication required", ErrRegistryAuth},
+	{"", "denied", ErrRegistryAuth},
+	{"", "invalid reference format", ErrInvalidReference},
+	{"", "executable file not found", ErrExecutableNotFound},
+	{"pull", ": not found", ErrImageNotFound}, // e.g. failed to resolve reference "docker.io/payramapp/payram:9.9.9": ...: not found
+	{"exec", "command not found", ErrExecutableNotFound},
+	{"exec", "no such file or directory", ErrExecutableNotFound},
+	{"exec", ": not found", ErrExecutableNotFound},
+}
+
+// classifyOutput returns the typed error the output of the docker op
+// subcommand indicates, or nil if it matches none.
+func classifyOutput(op, output string) error {
+	lower := strings.ToLower(output)
+	for _, p := range errorPatterns {
+		if p.op != "" && p.op != op {
+			continue
+		}
+		if strings.Contains(lower, p.fragment) {
+			return p.kind
+		}
+	}
+	return nil
+}
+
+// CommandError is a failed docker command. It matches its typed error, if
+// docker's output indicated one, and the underlying exec error with
+// errors.Is and errors.As.
+type CommandError struct {
+	Op     string // docker subcommand, e.g. "pull" or "volume inspect"
+	Output string // combined output, trimmed
+	Kind   error  // typed error, or nil if the output matched none
+	Err    error  // error from running the command
+}
+
+// NewCommandError returns the error of the docker op subcommand that failed
+// with err and printed output, classified like the Runner's own errors, for
+// callers that run docker themselves.
+func NewCommandError(op string, output []byte, err error) *CommandError {
+	trimmed := strings.TrimSpace(string(output))
+	return &CommandError{Op: op, Output: trimmed, Kind: classifyOutput(op, trimmed), Err: err}
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("docker %s failed: %v: %s", e.Op, e.Err, e.Output)
+}
+
+func (e *CommandError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// ExitCode returns the exit code of the docker command, or -1 if it did not
+// exit normally.
+func (e *CommandError) ExitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.Err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,111 @@
+package dockerexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestClassifyOutput tests that docker error output maps to the right typed error.
+func TestClassifyOutput(t *testing.T) {
+	testCases := []struct {
+		op     string
+		output string
+		want   error
+	}{
+		{"", "Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?", ErrDaemonDown},
+		{"", "error during connect: Get \"http://%2F%2F.%2Fpipe%2Fdocker_engine/v1.24/info\": open //./pipe/docker_engine: The system cannot find the file specified.", ErrDaemonDown},
+		{"", "permission denied while trying to connect to the Docker daemon socket at unix:///var/run/docker.sock", ErrPermissionDenied},
+		{"", "Error response from daemon: No such container: payram", ErrContainerNotFound},
+		{"", "Error: No such object: payram", ErrContainerNotFound},
+		{"", "Error response from daemon: get pgdata: no such volume", ErrVolumeNotFound},
+		{"", "docker: Error response from daemon: Conflict. The container name \"/payram\" is already in use by container \"abc123\". You have to remove (or rename) that container to be able to reuse that name.", ErrNameConflict},
+		{"", "Error response from daemon: Container abc123 is not running", ErrContainerNotRunning},
+		{"", "Error response from daemon: container payram is already running", ErrContainerAlreadyRunning},
+		{"", "Error response from daemon: toomanyrequests: You have reached your pull rate limit.", ErrRateLimited},
+		{"", "Error response from daemon: 429 Too Many Requests", ErrRateLimited},
+		{"", "Error response from daemon: manifest for payramapp/payram:9.9.9 not found: manifest unknown: manifest unknown", ErrImageNotFound},
+		{"", "Error response from daemon: pull access denied for payramapp/private, repository does not exist or may require 'docker login': denied: requested access to the resource is denied", ErrImageNotFound},
+		{"", "Error: No such image: payramapp/payram:1.0.0", ErrImageNotFound},
+		{"", "Error response from daemon: Head \"https://registry-1.docker.io/v2/payramapp/payram/manifests/1.2.0\": unauthorized: incorrect username or password", ErrRegistryAuth},
+		{"", "docker: invalid reference format.", ErrInvalidReference},
+		{"exec", "OCI runtime exec failed: exec failed: unable to start container process: exec: \"supervisorctl\": executable file not found in $PATH: unknown", ErrExecutableNotFound},
+		{"exec", "/bin/sh: 1: supervisorctl: not found", ErrExecutableNotFound},
+		{"pull", "Error response from daemon: failed to resolve reference \"docker.io/payramapp/payram:9.9.9\": docker.io/payramapp/payram:9.9.9: not found", ErrImageNotFound},
+		{"run", "docker: Error response from daemon: error while creating mount source path '/data': mkdir /data: no such file or directory.", nil},
+		{"pull", "Error response from daemon: Get \"https://registry-1.docker.io/v2/\": dial tcp: lookup registry-1.docker.io on 127.0.0.53:53: no such host", nil},
+		{"", "error pulling image configuration: read tcp 10.0.0.2:443: connection reset by peer", nil},
+		{"", "", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.op+" "+tc.output, func(t *testing.T) {
+			if got := classifyOutput(tc.op, tc.output); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// stubRunner returns a runner whose docker binary runs script.
+func stubRunner(t *testing.T, script string) *Runner {
+	t.Helper()
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Runner{DockerBin: dockerBin}
+}
+
+// TestRunner_ReturnsTypedErrors tests that runner failures match their typed error.
+func TestRunner_ReturnsTypedErrors(t *testing.T) {
+	runner := stubRunner(t, `echo 'docker: Error response from daemon: Conflict. The container name "/payram" is already in use by container "abc123".' >&2; exit 125`)
+
+	err := runner.Run(context.Background(), []string{"run", "-d", "--name", "payram", "payramapp/payram:1.2.0"})
+	if !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("expected ErrNameConflict, got %v", err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected a *CommandError, got %T", err)
+	}
+	if cmdErr.Op != "run" || cmdErr.ExitCode() != 125 {
+		t.Errorf("expected op run with exit code 125, got %s/%d", cmdErr.Op, cmdErr.ExitCode())
+	}
+	if !strings.HasPrefix(err.Error(), "docker run failed: exit status 125: ") {
+		t.Errorf("expected the error message to keep its format, got %q", err.Error())
+	}
+}
+
+// TestRunner_IdempotentOnTypedErrors tests that idempotent operations ignore the expected typed errors.
+func TestRunner_IdempotentOnTypedErrors(t *testing.T) {
+	ctx := context.Background()
+
+	if err := stubRunner(t, "echo 'Error response from daemon: No such container: payram' >&2; exit 1").Stop(ctx, "payram"); err != nil {
+		t.Errorf("expected stop of a missing container to succeed, got %v", err)
+	}
+	if err := stubRunner(t, "echo 'Error response from daemon: No such container: payram' >&2; exit 1").Remove(ctx, "payram"); err != nil {
+		t.Errorf("expected removal of a missing container to succeed, got %v", err)
+	}
+	if err := stubRunner(t, "echo 'Error response from daemon: container payram is already running' >&2; exit 1").Start(ctx, "payram"); err != nil {
+		t.Errorf("expected start of a running container to succeed, got %v", err)
+	}
+
+	err := stubRunner(t, "echo 'Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?' >&2; exit 1").Stop(ctx, "payram")
+	if !errors.Is(err, ErrDaemonDown) {
+		t.Errorf("expected ErrDaemonDown from stop, got %v", err)
+	}
+}
+
+// TestExec_ExecutableNotFound tests that a command missing from the container is reported as such.
+func TestExec_ExecutableNotFound(t *testing.T) {
+	runner := stubRunner(t, `echo 'OCI runtime exec failed: exec failed: exec: "supervisorctl": executable file not found in $PATH: unknown' >&2; exit 126`)
+
+	_, err := runner.Exec(context.Background(), "payram", "supervisorctl", "status")
+	if !errors.Is(err, ErrExecutableNotFound) {
+		t.Errorf("expected ErrExecutableNotFound, got %v", err)
+	}
+}
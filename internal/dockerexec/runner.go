@@ -2,9 +2,12 @@ package dockerexec
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Logger defines the interface for logging.
@@ -23,10 +26,8 @@ func (r *Runner) Pull(ctx context.Context, image string) error {
 	args := []string{"pull", image}
 	r.logCommand(args)
 
-	cmd := exec.CommandContext(ctx, r.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker pull failed: %w: %s", err, string(output))
+	if _, err := r.exec(ctx, "pull", args); err != nil {
+		return err
 	}
 
 	r.logf("Successfully pulled image: %s", image)
@@ -39,18 +40,13 @@ func (r *Runner) Stop(ctx context.Context, container string) error {
 	args := []string{"stop", container}
 	r.logCommand(args)
 
-	cmd := exec.CommandContext(ctx, r.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if _, err := r.exec(ctx, "stop", args); err != nil {
 		// Check if error is because container doesn't exist or isn't running
-		outputStr := string(output)
-		if strings.Contains(outputStr, "No such container") ||
-			strings.Contains(outputStr, "is not running") ||
-			strings.Contains(outputStr, "already stopped") {
+		if errors.Is(err, ErrContainerNotFound) || errors.Is(err, ErrContainerNotRunning) {
 			r.logf("Container %s not running (idempotent operation)", container)
 			return nil
 		}
-		return fmt.Errorf("docker stop failed: %w: %s", err, outputStr)
+		return err
 	}
 
 	r.logf("Successfully stopped container: %s", container)
@@ -63,15 +59,12 @@ func (r *Runner) Start(ctx context.Context, container string) error {
 	args := []string{"start", container}
 	r.logCommand(args)
 
-	cmd := exec.CommandContext(ctx, r.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		outputStr := string(output)
-		if strings.Contains(outputStr, "is already running") {
+	if _, err := r.exec(ctx, "start", args); err != nil {
+		if errors.Is(err, ErrContainerAlreadyRunning) {
 			r.logf("Container %s already running (idempotent operation)", container)
 			return nil
 		}
-		return fmt.Errorf("docker start failed: %w: %s", err, outputStr)
+		return err
 	}
 
 	r.logf("Successfully started container: %s", container)
@@ -83,10 +76,8 @@ func (r *Runner) Restart(ctx context.Context, container string) error {
 	args := []string{"restart", container}
 	r.logCommand(args)
 
-	cmd := exec.CommandContext(ctx, r.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker restart failed: %w: %s", err, strings.TrimSpace(string(output)))
+	if _, err := r.exec(ctx, "restart", args); err != nil {
+		return err
 	}
 
 	r.logf("Successfully restarted container: %s", container)
@@ -99,30 +90,118 @@ func (r *Runner) Remove(ctx context.Context, container string) error {
 	args := []string{"rm", "-f", container}
 	r.logCommand(args)
 
-	cmd := exec.CommandContext(ctx, r.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if _, err := r.exec(ctx, "rm", args); err != nil {
 		// Check if error is because container doesn't exist
-		outputStr := string(output)
-		if strings.Contains(outputStr, "No such container") {
+		if errors.Is(err, ErrContainerNotFound) {
 			r.logf("Container %s does not exist (idempotent operation)", container)
 			return nil
 		}
-		return fmt.Errorf("docker rm failed: %w: %s", err, outputStr)
+		return err
 	}
 
 	r.logf("Successfully removed container: %s", container)
 	return nil
 }
 
+// RemoveWithVolumes removes a Docker container together with its anonymous
+// volumes, for throwaway containers whose data must not outlive them.
+// Idempotent: returns no error if the container does not exist.
+func (r *Runner) RemoveWithVolumes(ctx context.Context, container string) error {
+	args := []string{"rm", "-f", "-v", container}
+	r.logCommand(args)
+
+	if _, err := r.exec(ctx, "rm", args); err != nil {
+		if errors.Is(err, ErrContainerNotFound) {
+			r.logf("Container %s does not exist (idempotent operation)", container)
+			return nil
+		}
+		return err
+	}
+
+	r.logf("Successfully removed container and volumes: %s", container)
+	return nil
+}
+
+// Rename renames a container.
+func (r *Runner) Rename(ctx context.Context, container, newName string) error {
+	args := []string{"rename", container, newName}
+	r.logCommand(args)
+
+	if _, err := r.exec(ctx, "rename", args); err != nil {
+		return err
+	}
+
+	r.logf("Renamed container %s to %s", container, newName)
+	return nil
+}
+
+// Exists reports whether a container exists, running or not.
+func (r *Runner) Exists(ctx context.Context, container string) (bool, error) {
+	args := []string{"inspect", "-f", "{{.Name}}", container}
+	r.logCommand(args)
+
+	if _, err := r.exec(ctx, "inspect", args); err != nil {
+		if errors.Is(err, ErrContainerNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ContainerSummary is a container as listed by docker ps.
+type ContainerSummary struct {
+	Name      string
+	CreatedAt time.Time
+	Labels    map[string]string
+}
+
+// psCreatedAtLayout is the format of docker ps's {{.CreatedAt}}.
+const psCreatedAtLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// ListLabeled returns every container, running or not, that carries label.
+func (r *Runner) ListLabeled(ctx context.Context, label string) ([]ContainerSummary, error) {
+	args := []string{"ps", "-a", "--filter", "label=" + label, "--format", "{{.Names}}\t{{.CreatedAt}}\t{{.Labels}}"}
+	r.logCommand(args)
+
+	output, err := r.exec(ctx, "ps", args)
+	if err != nil {
+		return nil, err
+	}
+	var containers []ContainerSummary
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		name, rest, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		created, labels, _ := strings.Cut(rest, "\t")
+		createdAt, err := time.Parse(psCreatedAtLayout, created)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected creation time %q for container %s: %w", created, name, err)
+		}
+		containers = append(containers, ContainerSummary{Name: name, CreatedAt: createdAt, Labels: parsePSLabels(labels)})
+	}
+	return containers, nil
+}
+
+// parsePSLabels parses docker ps's {{.Labels}}, "key=value" pairs separated
+// by commas.
+func parsePSLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
 // Run executes a docker command with the provided arguments.
 func (r *Runner) Run(ctx context.Context, args []string) error {
 	r.logCommand(args)
 
-	cmd := exec.CommandContext(ctx, r.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker run failed: %w: %s", err, string(output))
+	if _, err := r.exec(ctx, "run", args); err != nil {
+		return err
 	}
 
 	r.logf("Successfully executed docker command")
@@ -135,17 +214,13 @@ func (r *Runner) InspectRunning(ctx context.Context, container string) (bool, er
 	args := []string{"inspect", "-f", "{{.State.Running}}", container}
 	r.logCommand(args)
 
-	cmd := exec.CommandContext(ctx, r.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
+	output, err := r.exec(ctx, "inspect", args)
 	if err != nil {
-		// Container doesn't exist
-		outputStr := string(output)
-		if strings.Contains(outputStr, "No such object") ||
-			strings.Contains(outputStr, "No such container") {
+		if errors.Is(err, ErrContainerNotFound) {
 			r.logf("Container %s does not exist", container)
 			return false, nil
 		}
-		return false, fmt.Errorf("docker inspect failed: %w: %s", err, outputStr)
+		return false, err
 	}
 
 	outputStr := strings.TrimSpace(string(output))
@@ -155,6 +230,70 @@ func (r *Runner) InspectRunning(ctx context.Context, container string) (bool, er
 	return isRunning, nil
 }
 
+// VolumeExists reports whether a named Docker volume exists.
+func (r *Runner) VolumeExists(ctx context.Context, volume string) (bool, error) {
+	args := []string{"volume", "inspect", "-f", "{{.Name}}", volume}
+	r.logCommand(args)
+
+	if _, err := r.exec(ctx, "volume inspect", args); err != nil {
+		if errors.Is(err, ErrVolumeNotFound) {
+			r.logf("Volume %s does not exist", volume)
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RestartCount returns how many times Docker has restarted a container under
+// its restart policy. A count that climbs while the container is "running"
+// means it is crash-looping.
+func (r *Runner) RestartCount(ctx context.Context, container string) (int, error) {
+	args := []string{"inspect", "-f", "{{.RestartCount}}", container}
+	r.logCommand(args)
+
+	output, err := r.exec(ctx, "inspect", args)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected restart count %q", strings.TrimSpace(string(output)))
+	}
+	return count, nil
+}
+
+// ServerVersion returns the version of the Docker daemon, as reported by
+// docker info.
+func (r *Runner) ServerVersion(ctx context.Context) (string, error) {
+	args := []string{"info", "--format", "{{.ServerVersion}}"}
+	r.logCommand(args)
+
+	output, err := r.exec(ctx, "info", args)
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(output))
+	if version == "" {
+		return "", fmt.Errorf("docker info reported no server version")
+	}
+	return version, nil
+}
+
+// Logs returns the last tail lines a container wrote to stdout and stderr,
+// with timestamps.
+func (r *Runner) Logs(ctx context.Context, container string, tail int) (string, error) {
+	args := []string{"logs", "--timestamps", "--tail", strconv.Itoa(tail), container}
+	r.logCommand(args)
+
+	output, err := r.exec(ctx, "logs", args)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
 // PrunePayramImages removes old Payram images for the given repo.
 // It keeps the current tag and any tags used by running containers.
 // Best-effort: returns error only if listing images or containers fails.
@@ -166,10 +305,9 @@ func (r *Runner) PrunePayramImages(ctx context.Context, imageRepo string, keepTa
 	// Collect images used by running containers
 	psArgs := []string{"ps", "--format", "{{.Image}}"}
 	r.logCommand(psArgs)
-	psCmd := exec.CommandContext(ctx, r.DockerBin, psArgs...)
-	psOutput, err := psCmd.CombinedOutput()
+	psOutput, err := r.exec(ctx, "ps", psArgs)
 	if err != nil {
-		return fmt.Errorf("docker ps failed: %w: %s", err, string(psOutput))
+		return err
 	}
 	runningImages := map[string]struct{}{}
 	for _, line := range strings.Split(strings.TrimSpace(string(psOutput)), "\n") {
@@ -182,10 +320,9 @@ func (r *Runner) PrunePayramImages(ctx context.Context, imageRepo string, keepTa
 	// List all images for the repo
 	listArgs := []string{"images", "--format", "{{.Repository}}:{{.Tag}}", "--filter", fmt.Sprintf("reference=%s:*", imageRepo)}
 	r.logCommand(listArgs)
-	listCmd := exec.CommandContext(ctx, r.DockerBin, listArgs...)
-	listOutput, err := listCmd.CombinedOutput()
+	listOutput, err := r.exec(ctx, "images", listArgs)
 	if err != nil {
-		return fmt.Errorf("docker images failed: %w: %s", err, string(listOutput))
+		return err
 	}
 
 	currentRef := fmt.Sprintf("%s:%s", imageRepo, keepTag)
@@ -208,10 +345,8 @@ func (r *Runner) PrunePayramImages(ctx context.Context, imageRepo string, keepTa
 
 		rmiArgs := []string{"rmi", ref}
 		r.logCommand(rmiArgs)
-		rmiCmd := exec.CommandContext(ctx, r.DockerBin, rmiArgs...)
-		rmiOutput, rmiErr := rmiCmd.CombinedOutput()
-		if rmiErr != nil {
-			r.logf("Warning: failed to remove image %s: %v: %s", ref, rmiErr, strings.TrimSpace(string(rmiOutput)))
+		if _, rmiErr := r.exec(ctx, "rmi", rmiArgs); rmiErr != nil {
+			r.logf("Warning: failed to remove image %s: %v", ref, rmiErr)
 			continue
 		}
 		r.logf("Removed old image: %s", ref)
@@ -220,6 +355,27 @@ func (r *Runner) PrunePayramImages(ctx context.Context, imageRepo string, keepTa
 	return nil
 }
 
+// Exec runs command in a running container with docker exec and returns
+// its combined output. A command missing from the container fails with
+// ErrExecutableNotFound; use CommandError.ExitCode for other exit codes.
+func (r *Runner) Exec(ctx context.Context, container string, command ...string) (string, error) {
+	args := append([]string{"exec", container}, command...)
+	r.logCommand(args)
+
+	output, err := r.exec(ctx, "exec", args)
+	return string(output), err
+}
+
+// exec runs docker with args and returns its combined output. A failure is
+// returned as a *CommandError for op.
+func (r *Runner) exec(ctx context.Context, op string, args []string) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, r.DockerBin, args...).CombinedOutput()
+	if err != nil {
+		return output, NewCommandError(op, output, err)
+	}
+	return output, nil
+}
+
 // logf logs a formatted message if a logger is available.
 func (r *Runner) logf(format string, args ...interface{}) {
 	if r.Logger != nil {
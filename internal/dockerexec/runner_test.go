@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -237,9 +239,8 @@ func TestIdempotentOperations(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Test idempotent detection logic
-			isIdempotent := strings.Contains(tc.errorOutput, "No such container") ||
-				strings.Contains(tc.errorOutput, "is not running") ||
-				strings.Contains(tc.errorOutput, "already stopped")
+			kind := classifyOutput(tc.operation, tc.errorOutput)
+			isIdempotent := errors.Is(kind, ErrContainerNotFound) || errors.Is(kind, ErrContainerNotRunning)
 
 			if isIdempotent != tc.shouldBeIdempotent {
 				t.Errorf("expected idempotent=%v, got %v for output: %s",
@@ -378,3 +379,185 @@ func TestContext_Integration(t *testing.T) {
 	var _ func(context.Context, []string) error = runner.Run
 	var _ func(context.Context, string) (bool, error) = runner.InspectRunning
 }
+
+// TestRestartCount tests parsing of the container restart count.
+func TestRestartCount(t *testing.T) {
+	testCases := []struct {
+		name     string
+		script   string
+		expected int
+		wantErr  bool
+	}{
+		{"count", "echo 4", 4, false},
+		{"not a number", "echo '<no value>'", 0, true},
+		{"missing container", "echo 'Error: No such object: payram' >&2; exit 1", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dockerBin := filepath.Join(t.TempDir(), "docker")
+			if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n"+tc.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			runner := &Runner{DockerBin: dockerBin}
+
+			count, err := runner.RestartCount(context.Background(), "payram")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if count != tc.expected {
+				t.Errorf("expected restart count %d, got %d", tc.expected, count)
+			}
+		})
+	}
+}
+
+// TestRename tests that Rename passes both names to docker rename.
+func TestRename(t *testing.T) {
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	script := "#!/bin/sh\n[ \"$*\" = 'rename payram payram-previous' ] || { echo \"unexpected: $*\" >&2; exit 1; }\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runner := &Runner{DockerBin: dockerBin}
+
+	if err := runner.Rename(context.Background(), "payram", "payram-previous"); err != nil {
+		t.Fatalf("expected rename to succeed, got %v", err)
+	}
+}
+
+// TestExists tests container existence detection.
+func TestExists(t *testing.T) {
+	testCases := []struct {
+		name     string
+		script   string
+		expected bool
+		wantErr  bool
+	}{
+		{"exists", "echo /payram", true, false},
+		{"missing", "echo 'Error: No such object: payram' >&2; exit 1", false, false},
+		{"daemon down", "echo 'Cannot connect to the Docker daemon' >&2; exit 1", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dockerBin := filepath.Join(t.TempDir(), "docker")
+			if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n"+tc.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			runner := &Runner{DockerBin: dockerBin}
+
+			exists, err := runner.Exists(context.Background(), "payram")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if exists != tc.expected {
+				t.Errorf("expected exists=%v, got %v", tc.expected, exists)
+			}
+		})
+	}
+}
+
+// TestServerVersion tests reading the Docker daemon version.
+func TestServerVersion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		script   string
+		expected string
+		wantErr  bool
+	}{
+		{"version", "[ \"$1 $2 $3\" = 'info --format {{.ServerVersion}}' ] && echo 24.0.7", "24.0.7", false},
+		{"empty", "echo", "", true},
+		{"daemon down", "echo 'Cannot connect to the Docker daemon' >&2; exit 1", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dockerBin := filepath.Join(t.TempDir(), "docker")
+			if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n"+tc.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			runner := &Runner{DockerBin: dockerBin}
+
+			version, err := runner.ServerVersion(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if version != tc.expected {
+				t.Errorf("expected version %q, got %q", tc.expected, version)
+			}
+		})
+	}
+}
+
+func TestLogs(t *testing.T) {
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	script := "#!/bin/sh\necho \"args: $*\"\necho 'panic: migration failed' >&2\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runner := &Runner{DockerBin: dockerBin}
+
+	logs, err := runner.Logs(context.Background(), "payram", 500)
+	if err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	if !strings.Contains(logs, "args: logs --timestamps --tail 500 payram") {
+		t.Errorf("unexpected arguments in %q", logs)
+	}
+	if !strings.Contains(logs, "panic: migration failed") {
+		t.Errorf("expected the container's stderr to be included, got %q", logs)
+	}
+}
+
+func TestListLabeled(t *testing.T) {
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	script := "#!/bin/sh\nprintf 'payram-migration-check\\t2026-10-16 12:00:00 +0000 UTC\\tio.payram.updater.throwaway=true,io.payram.updater.throwaway.pid=42\\n'\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runner := &Runner{DockerBin: dockerBin}
+
+	containers, err := runner.ListLabeled(context.Background(), "io.payram.updater.throwaway")
+	if err != nil {
+		t.Fatalf("ListLabeled failed: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Name != "payram-migration-check" || containers[0].CreatedAt.Hour() != 12 {
+		t.Fatalf("unexpected containers %+v", containers)
+	}
+	if pid := containers[0].Labels["io.payram.updater.throwaway.pid"]; pid != "42" {
+		t.Errorf("expected the creator label parsed, got %q", pid)
+	}
+}
+
+// TestVolumeExists tests volume existence detection.
+func TestVolumeExists(t *testing.T) {
+	testCases := []struct {
+		name     string
+		script   string
+		expected bool
+		wantErr  bool
+	}{
+		{"exists", "echo pgdata", true, false},
+		{"missing", "echo 'Error response from daemon: get pgdata: no such volume' >&2; exit 1", false, false},
+		{"daemon down", "echo 'Cannot connect to the Docker daemon' >&2; exit 1", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dockerBin := filepath.Join(t.TempDir(), "docker")
+			if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n"+tc.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			runner := &Runner{DockerBin: dockerBin}
+
+			exists, err := runner.VolumeExists(context.Background(), "pgdata")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if exists != tc.expected {
+				t.Errorf("expected exists=%v, got %v", tc.expected, exists)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -76,16 +77,79 @@ func (s *Store) List(limit int, typeFilter, statusFilter string) ([]Event, error
 		limit = 100
 	}
 
+	var events []Event
+	err := s.scan(typeFilter, statusFilter, func(evt Event) error {
+		events = append(events, evt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		return []Event{}, nil
+	}
+
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	// Reverse to newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	return events, nil
+}
+
+// Export writes events filtered by type and status to w as JSON Lines, oldest
+// first. Events are written as they are read, so the history is never held in
+// memory. Returns the number of events written.
+func (s *Store) Export(w io.Writer, typeFilter, statusFilter string) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+
+	count := 0
+	encoder := json.NewEncoder(w)
+	err := s.scan(typeFilter, statusFilter, func(evt Event) error {
+		if err := encoder.Encode(evt); err != nil {
+			return fmt.Errorf("failed to write history event: %w", err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// JobBackupPath returns the path of the newest successful backup recorded
+// for jobID, or "" if the history has none.
+func (s *Store) JobBackupPath(jobID string) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+
+	var path string
+	err := s.scan("backup", "succeeded", func(evt Event) error {
+		if evt.Data["jobId"] == jobID && evt.Data["backupPath"] != "" {
+			path = evt.Data["backupPath"]
+		}
+		return nil
+	})
+	return path, err
+}
+
+// scan reads the history file in order and calls fn for each event matching
+// the filters. Malformed lines are skipped.
+func (s *Store) scan(typeFilter, statusFilter string, fn func(Event) error) error {
 	file, err := os.Open(s.path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []Event{}, nil
+			return nil
 		}
-		return nil, fmt.Errorf("failed to read history file: %w", err)
+		return fmt.Errorf("failed to read history file: %w", err)
 	}
 	defer file.Close()
 
-	var events []Event
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -106,21 +170,14 @@ func (s *Store) List(limit int, typeFilter, statusFilter string) ([]Event, error
 			continue
 		}
 
-		events = append(events, evt)
+		if err := fn(evt); err != nil {
+			return err
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan history file: %w", err)
-	}
-
-	if len(events) > limit {
-		events = events[len(events)-limit:]
-	}
-
-	// Reverse to newest first
-	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
-		events[i], events[j] = events[j], events[i]
+		return fmt.Errorf("failed to scan history file: %w", err)
 	}
 
-	return events, nil
+	return nil
 }
@@ -0,0 +1,142 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendEvents(t *testing.T, store *Store, events ...Event) {
+	t.Helper()
+	for _, evt := range events {
+		if err := store.Append(evt); err != nil {
+			t.Fatalf("failed to append event: %v", err)
+		}
+	}
+}
+
+func TestStore_ListNewestFirst(t *testing.T) {
+	store := NewStore(t.TempDir())
+	appendEvents(t, store,
+		Event{ID: "1", Type: "upgrade", Status: "started"},
+		Event{ID: "2", Type: "backup", Status: "succeeded"},
+		Event{ID: "3", Type: "upgrade", Status: "succeeded"},
+	)
+
+	events, err := store.List(2, "", "")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(events) != 2 || events[0].ID != "3" || events[1].ID != "2" {
+		t.Errorf("expected newest two events [3 2], got %+v", events)
+	}
+}
+
+func TestStore_ListMissingFile(t *testing.T) {
+	events, err := NewStore(t.TempDir()).List(10, "", "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if events == nil || len(events) != 0 {
+		t.Errorf("expected empty non-nil slice, got %#v", events)
+	}
+}
+
+func TestStore_ExportJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	appendEvents(t, store,
+		Event{ID: "1", Type: "upgrade", Status: "started", Data: map[string]string{"target": "1.9.0"}},
+		Event{ID: "2", Type: "backup", Status: "succeeded", Message: "multi\nline"},
+		Event{ID: "3", Type: "upgrade", Status: "failed"},
+	)
+
+	// A malformed line must be skipped rather than break the export.
+	f, err := os.OpenFile(filepath.Join(dir, "history.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open history file: %v", err)
+	}
+	f.WriteString("{not json\n")
+	f.Close()
+
+	var buf bytes.Buffer
+	count, err := store.Export(&buf, "", "")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 events exported, got %d", count)
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var evt Event
+		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+			t.Fatalf("line is not a standalone JSON event: %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, evt.ID)
+	}
+	if len(ids) != 3 || ids[0] != "1" || ids[2] != "3" {
+		t.Errorf("expected events oldest first [1 2 3], got %v", ids)
+	}
+}
+
+func TestStore_ExportFilters(t *testing.T) {
+	store := NewStore(t.TempDir())
+	appendEvents(t, store,
+		Event{ID: "1", Type: "upgrade", Status: "started"},
+		Event{ID: "2", Type: "backup", Status: "failed"},
+		Event{ID: "3", Type: "upgrade", Status: "failed"},
+	)
+
+	var buf bytes.Buffer
+	count, err := store.Export(&buf, "UPGRADE", "failed")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 event, got %d: %s", count, buf.String())
+	}
+	var evt Event
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &evt); err != nil || evt.ID != "3" {
+		t.Errorf("expected event 3, got %q (%v)", buf.String(), err)
+	}
+}
+
+func TestStore_ExportMissingFile(t *testing.T) {
+	var buf bytes.Buffer
+	count, err := NewStore(t.TempDir()).Export(&buf, "", "")
+	if err != nil || count != 0 || buf.Len() != 0 {
+		t.Errorf("expected empty export, got count=%d err=%v output=%q", count, err, buf.String())
+	}
+}
+
+func TestStore_JobBackupPath(t *testing.T) {
+	store := NewStore(t.TempDir())
+	appendEvents(t, store,
+		Event{Type: "backup", Status: "succeeded", Data: map[string]string{"jobId": "job-1", "backupPath": "/backups/first.sql"}},
+		Event{Type: "backup", Status: "succeeded", Data: map[string]string{"jobId": "job-2", "backupPath": "/backups/other.sql"}},
+		Event{Type: "backup", Status: "failed", Data: map[string]string{"jobId": "job-1", "backupPath": "/backups/failed.sql"}},
+		Event{Type: "backup", Status: "succeeded", Data: map[string]string{"jobId": "job-1", "backupPath": "/backups/retry.sql"}},
+		Event{Type: "upgrade", Status: "succeeded", Data: map[string]string{"jobId": "job-1", "backupPath": "/backups/upgrade.sql"}},
+	)
+
+	path, err := store.JobBackupPath("job-1")
+	if err != nil {
+		t.Fatalf("JobBackupPath failed: %v", err)
+	}
+	if path != "/backups/retry.sql" {
+		t.Errorf("expected the newest successful backup of job-1, got %q", path)
+	}
+
+	if path, err := store.JobBackupPath("job-3"); err != nil || path != "" {
+		t.Errorf("expected no backup for an unknown job, got %q (err %v)", path, err)
+	}
+	if path, err := NewStore(t.TempDir()).JobBackupPath("job-1"); err != nil || path != "" {
+		t.Errorf("expected no backup without a history file, got %q (err %v)", path, err)
+	}
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/payram/payram-updater/internal/autoupdate"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
+	"github.com/payram/payram-updater/internal/recovery"
+)
+
+// autoUpdateRetryable reports whether auto-update may start another job after
+// job failed: only failures whose playbook is retryable are, and only until
+// the target runs out of attempts (see autoUpdateTargetAllowed). Anything
+// else needs an operator first.
+func autoUpdateRetryable(job *jobs.Job) bool {
+	return recovery.GetPlaybook(job.FailureCode).Severity == recovery.SeverityRetryable
+}
+
+// loadAutoUpdateAttempts reads the persistent attempt counter. An unreadable
+// counter is logged and treated as empty.
+func (s *Server) loadAutoUpdateAttempts() *autoupdate.Attempts {
+	attempts, err := autoupdate.LoadAttempts(autoupdate.AttemptsPath(s.config.StateDir))
+	if err != nil {
+		logger.Error("Server", "loadAutoUpdateAttempts", err)
+		return &autoupdate.Attempts{}
+	}
+	return attempts
+}
+
+// autoUpdateTargetAllowed reports whether auto-update may start a job for
+// target. A target that already failed AutoUpdateMaxAttempts auto-update jobs
+// is skipped until a different target is resolved, which starts over.
+func (s *Server) autoUpdateTargetAllowed(target string) bool {
+	attempts := s.loadAutoUpdateAttempts().For(target)
+	if attempts.Exhausted(s.config.AutoUpdateMaxAttempts) {
+		logger.Warnf("Server", "runAutoUpdateOnce", "Auto update: %s failed %d times (last %s), skipping it until a newer version is available", target, attempts.Failures, attempts.LastFailureCode)
+		return false
+	}
+	return true
+}
+
+// recordAutoUpdateOutcome counts a failed auto-update job for target, and
+// clears the counter after a successful one. When the failure uses up the
+// target's last attempt it is logged, in the job log and the history.
+func (s *Server) recordAutoUpdateOutcome(job *jobs.Job, target string) {
+	path := autoupdate.AttemptsPath(s.config.StateDir)
+	switch job.State {
+	case jobs.JobStateReady:
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Error("Server", "recordAutoUpdateOutcome", err)
+		}
+		return
+	case jobs.JobStateFailed:
+	default:
+		return // a cancelled job is not a failed attempt
+	}
+
+	attempts := s.loadAutoUpdateAttempts().For(target)
+	attempts.Failures++
+	attempts.LastFailureCode = job.FailureCode
+	gaveUp := attempts.Exhausted(s.config.AutoUpdateMaxAttempts)
+	if gaveUp {
+		attempts.GaveUpAt = time.Now().UTC()
+	}
+	if err := autoupdate.SaveAttempts(path, attempts); err != nil {
+		logger.Error("Server", "recordAutoUpdateOutcome", err)
+	}
+
+	if !gaveUp {
+		s.jobStore.AppendLog(fmt.Sprintf("Auto update: attempt %d/%d at %s failed", attempts.Failures, s.config.AutoUpdateMaxAttempts, target))
+		return
+	}
+	message := fmt.Sprintf("Auto update gave up on %s after %d failed attempts (last failure %s); it will not be retried until a newer version is available", target, attempts.Failures, job.FailureCode)
+	logger.Warnf("Server", "recordAutoUpdateOutcome", "%s", message)
+	s.jobStore.AppendLog("WARNING: " + message)
+	s.recordHistory(history.Event{
+		Type:    "auto_update",
+		Status:  "gave_up",
+		Message: message,
+		Data: map[string]string{
+			"jobId":       job.JobID,
+			"target":      target,
+			"attempts":    strconv.Itoa(attempts.Failures),
+			"failureCode": job.FailureCode,
+		},
+	})
+}
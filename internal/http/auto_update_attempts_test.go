@@ -0,0 +1,91 @@
+package http
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+func newAutoUpdateTestServer(t *testing.T, maxAttempts int) *Server {
+	t.Helper()
+	stateDir := filepath.Join(t.TempDir(), "state")
+	return &Server{
+		config:       &config.Config{StateDir: stateDir, AutoUpdateMaxAttempts: maxAttempts},
+		jobStore:     jobs.NewStore(stateDir),
+		historyStore: history.NewStore(stateDir),
+	}
+}
+
+func failedAutoUpdateJob(target, code string) *jobs.Job {
+	job := jobs.NewJob("job-"+target, jobs.JobModeDashboard, target)
+	job.State = jobs.JobStateFailed
+	job.FailureCode = code
+	return job
+}
+
+func TestAutoUpdateAttempts_CappedPerTarget(t *testing.T) {
+	s := newAutoUpdateTestServer(t, 3)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if !s.autoUpdateTargetAllowed("1.8.0") {
+			t.Fatalf("expected attempt %d at 1.8.0 to be allowed", attempt)
+		}
+		s.recordAutoUpdateOutcome(failedAutoUpdateJob("1.8.0", "DOCKER_PULL_FAILED"), "1.8.0")
+	}
+	if s.autoUpdateTargetAllowed("1.8.0") {
+		t.Fatal("expected 1.8.0 to be skipped after 3 failed attempts")
+	}
+
+	events, err := s.historyStore.List(10, "auto_update", "gave_up")
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected one gave_up event, got %+v (%v)", events, err)
+	}
+	if events[0].Data["target"] != "1.8.0" || events[0].Data["attempts"] != "3" || events[0].Data["failureCode"] != "DOCKER_PULL_FAILED" {
+		t.Errorf("unexpected gave_up event: %+v", events[0])
+	}
+
+	// A cancelled job does not count, and the counter survives a restart
+	cancelled := failedAutoUpdateJob("1.8.0", "")
+	cancelled.State = jobs.JobStateCancelled
+	s.recordAutoUpdateOutcome(cancelled, "1.8.0")
+	restarted := &Server{config: s.config, jobStore: s.jobStore}
+	if restarted.autoUpdateTargetAllowed("1.8.0") {
+		t.Error("expected the exhausted target to stay skipped after a restart")
+	}
+}
+
+func TestAutoUpdateAttempts_ResetForNewTarget(t *testing.T) {
+	s := newAutoUpdateTestServer(t, 2)
+	s.recordAutoUpdateOutcome(failedAutoUpdateJob("1.8.0", "BACKUP_FAILED"), "1.8.0")
+	s.recordAutoUpdateOutcome(failedAutoUpdateJob("1.8.0", "BACKUP_FAILED"), "1.8.0")
+	if s.autoUpdateTargetAllowed("1.8.0") {
+		t.Fatal("expected 1.8.0 to be skipped after 2 failed attempts")
+	}
+
+	if !s.autoUpdateTargetAllowed("1.8.1") {
+		t.Fatal("expected a newer target to be attempted")
+	}
+	s.recordAutoUpdateOutcome(failedAutoUpdateJob("1.8.1", "BACKUP_FAILED"), "1.8.1")
+	if !s.autoUpdateTargetAllowed("1.8.1") {
+		t.Error("expected 1.8.1 to have a second attempt of its own")
+	}
+
+	succeeded := jobs.NewJob("job-ok", jobs.JobModeDashboard, "1.8.1")
+	succeeded.State = jobs.JobStateReady
+	s.recordAutoUpdateOutcome(succeeded, "1.8.1")
+	if attempts := s.loadAutoUpdateAttempts(); attempts.Failures != 0 {
+		t.Errorf("expected a successful upgrade to clear the counter, got %+v", attempts)
+	}
+}
+
+func TestAutoUpdateRetryable(t *testing.T) {
+	if !autoUpdateRetryable(failedAutoUpdateJob("1.8.0", "DOCKER_PULL_FAILED")) {
+		t.Error("expected a pull failure to be retried")
+	}
+	if autoUpdateRetryable(failedAutoUpdateJob("1.8.0", "HEALTHCHECK_FAILED")) {
+		t.Error("expected a failed health check to wait for an operator")
+	}
+}
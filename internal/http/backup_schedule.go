@@ -0,0 +1,186 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/logger"
+	"github.com/payram/payram-updater/internal/orchestrator"
+)
+
+// backupScheduleCheckInterval is how often the schedule loop checks whether
+// a scheduled backup is due.
+const backupScheduleCheckInterval = time.Minute
+
+// scheduledBackupTrigger marks scheduled backups in history, telling them
+// apart from pre-upgrade and manual backups.
+const scheduledBackupTrigger = "scheduled"
+
+// startBackupScheduleLoop takes a backup every BackupScheduleInterval minutes
+// until ctx is cancelled, independently of upgrades.
+func (s *Server) startBackupScheduleLoop(ctx context.Context) {
+	interval := time.Duration(s.config.BackupScheduleInterval) * time.Minute
+	if interval <= 0 {
+		logger.Warnf("Server", "startBackupScheduleLoop", "Scheduled backups disabled due to invalid interval: %d minutes", s.config.BackupScheduleInterval)
+		return
+	}
+
+	logger.Infof("Server", "startBackupScheduleLoop", "Scheduled backups enabled. Backing up every %s", interval)
+
+	ticker := time.NewTicker(backupScheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runScheduledBackupIfDue(ctx)
+		select {
+		case <-ctx.Done():
+			logger.Infof("Server", "startBackupScheduleLoop", "Backup schedule loop stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runScheduledBackupIfDue takes a backup if BackupScheduleInterval has passed
+// since the last scheduled one, and reports whether it took one. A backup
+// that is due while an upgrade runs waits for the next check.
+func (s *Server) runScheduledBackupIfDue(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	interval := time.Duration(s.config.BackupScheduleInterval) * time.Minute
+	if last := s.lastScheduledBackupTime(); !last.IsZero() && s.clock().Sub(last) < interval {
+		return false
+	}
+
+	existingJob, err := s.jobStore.LoadLatest()
+	if err != nil {
+		logger.Error("Server", "runScheduledBackupIfDue", err)
+		return false
+	}
+	if existingJob != nil && isJobActive(existingJob) {
+		logger.Infof("Server", "runScheduledBackupIfDue", "Scheduled backup: active job %s in state %s, postponing", existingJob.JobID, existingJob.State)
+		return false
+	}
+	release, ok := s.operations.TryAcquire(orchestrator.Operation{Kind: orchestrator.KindScheduledBackup})
+	if !ok {
+		logger.Infof("Server", "runScheduledBackupIfDue", "Scheduled backup: another operation is running or queued, postponing")
+		return false
+	}
+	defer release()
+	unlock, err := s.lockOperation("scheduled backup", false)
+	if err != nil {
+		logger.Infof("Server", "runScheduledBackupIfDue", "Scheduled backup: %v, postponing", err)
+		return false
+	}
+	defer unlock()
+
+	s.runScheduledBackup(ctx)
+	return true
+}
+
+// lastScheduledBackupTime returns when the last scheduled backup was taken,
+// looking it up in history the first time so a restart does not reset the
+// schedule. It returns the zero time if there never was one.
+func (s *Server) lastScheduledBackupTime() time.Time {
+	if !s.lastScheduledBackup.IsZero() || s.historyStore == nil {
+		return s.lastScheduledBackup
+	}
+
+	events, err := s.historyStore.List(0, "backup", "")
+	if err != nil {
+		logger.Error("Server", "lastScheduledBackupTime", err)
+		return s.lastScheduledBackup
+	}
+	for _, event := range events {
+		if event.Data["trigger"] != scheduledBackupTrigger {
+			continue
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, event.Timestamp); err == nil {
+			s.lastScheduledBackup = ts
+		}
+		break
+	}
+	return s.lastScheduledBackup
+}
+
+// runScheduledBackup takes one backup of the core database, records it in
+// history and prunes old backups per BACKUP_RETENTION. A failed attempt also
+// counts as a run, so a broken database is retried on the next interval
+// rather than every minute.
+func (s *Server) runScheduledBackup(ctx context.Context) {
+	s.lastScheduledBackup = s.clock()
+
+	containerName, err := s.discoverContainerName(ctx)
+	if err != nil {
+		logger.Error("Server", "runScheduledBackup", err)
+		s.recordScheduledBackupFailure("CONTAINER_NAME_UNRESOLVED", err.Error())
+		return
+	}
+
+	logger.Infof("Server", "runScheduledBackup", "Taking scheduled backup of %s", containerName)
+	result := s.containerBackupExec.ExecuteBackup(ctx, containerName, backup.BackupMeta{
+		FromVersion:   scheduledBackupTrigger,
+		TargetVersion: scheduledBackupTrigger,
+		JobID:         fmt.Sprintf("scheduled-%d", s.clock().Unix()),
+	})
+	if !result.Success {
+		logger.Warnf("Server", "runScheduledBackup", "Scheduled backup failed: %s - %s", result.FailureCode, result.ErrorMessage)
+		s.recordScheduledBackupFailure(result.FailureCode, result.ErrorMessage)
+		return
+	}
+
+	logger.Infof("Server", "runScheduledBackup", "Scheduled backup created: %s (%.2f MB)", result.Filename, float64(result.Size)/(1024*1024))
+	data := map[string]string{
+		"trigger":    scheduledBackupTrigger,
+		"backupPath": result.Path,
+		"sizeBytes":  fmt.Sprintf("%d", result.Size),
+	}
+	if result.SnapshotID != "" {
+		data["snapshotId"] = result.SnapshotID
+	}
+	s.recordHistory(history.Event{
+		Type:    "backup",
+		Status:  "succeeded",
+		Message: "Scheduled backup completed",
+		Data:    data,
+	})
+
+	// Only scheduled backups count against each other: frequent scheduled
+	// backups must not push out the pre-upgrade backup a restore needs
+	pruned, err := s.backupManager.PruneBackupsWhere(s.backupManager.Config.Retention, isScheduledBackup)
+	if err != nil {
+		logger.Warnf("Server", "runScheduledBackup", "Failed to prune old backups: %v", err)
+	} else if len(pruned) > 0 {
+		logger.Infof("Server", "runScheduledBackup", "Pruned %d old backup(s)", len(pruned))
+	}
+}
+
+func (s *Server) recordScheduledBackupFailure(code, message string) {
+	s.recordHistory(history.Event{
+		Type:    "backup",
+		Status:  "failed",
+		Message: message,
+		Data: map[string]string{
+			"trigger":     scheduledBackupTrigger,
+			"failureCode": code,
+		},
+	})
+}
+
+// isScheduledBackup reports whether b was taken by the backup schedule.
+func isScheduledBackup(b backup.BackupListItem) bool {
+	return b.FromVersion == scheduledBackupTrigger
+}
+
+// pruneUpgradeBackups prunes the backups other than scheduled ones to
+// BACKUP_RETENTION, so each kind keeps its own retention.
+func (s *Server) pruneUpgradeBackups() ([]backup.BackupListItem, error) {
+	return s.backupManager.PruneBackupsWhere(s.backupManager.Config.Retention, func(b backup.BackupListItem) bool {
+		return !isScheduledBackup(b)
+	})
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+// newScheduleTestServer returns a server whose backups succeed as volume
+// snapshots, on a clock the test moves forward with advance.
+func newScheduleTestServer(t *testing.T, intervalMinutes, retention int) (s *Server, advance func(time.Duration)) {
+	t.Helper()
+	dir := t.TempDir()
+	dockerBin := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"  inspect) [ \"$2\" = --format ] && echo '[\"POSTGRES_HOST=localhost\",\"POSTGRES_DATABASE=payram\",\"POSTGRES_USERNAME=payram\"]' || echo '[]' ;;\n" +
+		"  exec) echo 1 ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		DockerBin:              dockerBin,
+		TargetContainerName:    "payram",
+		StateDir:               filepath.Join(dir, "state"),
+		FetchTimeoutSeconds:    1,
+		BackupScheduleEnabled:  true,
+		BackupScheduleInterval: intervalMinutes,
+		Backup: config.BackupConfig{
+			Dir:             filepath.Join(dir, "backups"),
+			Retention:       retention,
+			Strategy:        backup.StrategySnapshot,
+			SnapshotCommand: "echo {name}",
+		},
+	}
+	s = New(cfg, jobs.NewStore(cfg.StateDir))
+	s.containerBackupExec.BackupTimeout = 10 * time.Second
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+// seedBackups writes n old dump backups from 1.0.0 to 1.1.0 to the backup
+// directory, as upgrades would.
+func seedBackups(t *testing.T, dir string, n int) {
+	t.Helper()
+	seedBackupsFrom(t, dir, n, "1.0.0-to-1.1.0")
+}
+
+// seedBackupsFrom writes n old dump backups with the given versions part of
+// the filename, e.g. "scheduled-to-scheduled".
+func seedBackupsFrom(t *testing.T, dir string, n int, versions string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("payram-backup-202501%02d-000000-%s.dump", i+1, versions)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("dump"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScheduledBackup_FiresOnInterval(t *testing.T) {
+	s, advance := newScheduleTestServer(t, 60, 10)
+	ctx := context.Background()
+
+	if !s.runScheduledBackupIfDue(ctx) {
+		t.Fatal("expected the first scheduled backup to run immediately")
+	}
+	advance(59 * time.Minute)
+	if s.runScheduledBackupIfDue(ctx) {
+		t.Error("expected no backup before the interval has passed")
+	}
+	advance(time.Minute)
+	if !s.runScheduledBackupIfDue(ctx) {
+		t.Error("expected a backup once the interval has passed")
+	}
+
+	events, err := s.historyStore.List(0, "backup", "succeeded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 scheduled backups in history, got %d", len(events))
+	}
+	for _, event := range events {
+		if event.Data["trigger"] != "scheduled" || event.Data["backupPath"] == "" {
+			t.Errorf("expected a scheduled backup event with its path, got %+v", event.Data)
+		}
+	}
+}
+
+func TestScheduledBackup_PrunesPerRetention(t *testing.T) {
+	s, _ := newScheduleTestServer(t, 60, 2)
+	seedBackupsFrom(t, s.config.Backup.Dir, 3, "scheduled-to-scheduled")
+
+	if !s.runScheduledBackupIfDue(context.Background()) {
+		t.Fatal("expected a scheduled backup")
+	}
+
+	backups, err := s.backupManager.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected retention to keep 2 backups, got %d", len(backups))
+	}
+	if backups[0].Format != "snapshot" || backups[0].FromVersion != "scheduled" {
+		t.Errorf("expected the new scheduled backup to be kept, got %+v", backups[0])
+	}
+	if backups[1].Filename != "payram-backup-20250103-000000-scheduled-to-scheduled.dump" {
+		t.Errorf("expected the newest old backup to be kept, got %s", backups[1].Filename)
+	}
+}
+
+func TestScheduledBackup_KeepsPreUpgradeBackups(t *testing.T) {
+	s, _ := newScheduleTestServer(t, 60, 1)
+	seedBackups(t, s.config.Backup.Dir, 2)
+	seedBackupsFrom(t, s.config.Backup.Dir, 2, "scheduled-to-scheduled")
+
+	if !s.runScheduledBackupIfDue(context.Background()) {
+		t.Fatal("expected a scheduled backup")
+	}
+
+	backups, err := s.backupManager.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var preUpgrade, scheduled int
+	for _, b := range backups {
+		if isScheduledBackup(b) {
+			scheduled++
+		} else {
+			preUpgrade++
+		}
+	}
+	if preUpgrade != 2 || scheduled != 1 {
+		t.Errorf("expected both pre-upgrade backups and 1 scheduled backup kept, got %d and %d", preUpgrade, scheduled)
+	}
+}
+
+func TestScheduledBackup_PostponedWhileAnUpgradeRuns(t *testing.T) {
+	s, _ := newScheduleTestServer(t, 60, 10)
+	unlock, err := s.lockOperation("upgrade job-1", true)
+	if err != nil {
+		t.Fatalf("lockOperation: %v", err)
+	}
+
+	if s.runScheduledBackupIfDue(context.Background()) {
+		t.Error("expected the scheduled backup to wait for the running upgrade")
+	}
+	unlock()
+	if !s.runScheduledBackupIfDue(context.Background()) {
+		t.Error("expected the scheduled backup to run once the upgrade finished")
+	}
+}
+
+func TestScheduledBackup_WaitsForActiveUpgrade(t *testing.T) {
+	s, _ := newScheduleTestServer(t, 60, 10)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.1.0")
+	job.State = jobs.JobStateExecuting
+	s.jobStore.Save(job)
+
+	if s.runScheduledBackupIfDue(context.Background()) {
+		t.Error("expected the scheduled backup to wait for the running upgrade")
+	}
+
+	job.State = jobs.JobStateReady
+	s.jobStore.Save(job)
+	if !s.runScheduledBackupIfDue(context.Background()) {
+		t.Error("expected the scheduled backup to run once the upgrade finished")
+	}
+}
+
+func TestScheduledBackup_ResumesScheduleFromHistory(t *testing.T) {
+	s, _ := newScheduleTestServer(t, 60, 10)
+	if !s.runScheduledBackupIfDue(context.Background()) {
+		t.Fatal("expected a scheduled backup")
+	}
+
+	// A restarted daemon has no in-memory schedule; history must hold it
+	s.lastScheduledBackup = time.Time{}
+	s.now = func() time.Time { return time.Now() }
+	if s.runScheduledBackupIfDue(context.Background()) {
+		t.Error("expected the schedule to survive a restart")
+	}
+}
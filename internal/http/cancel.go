@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+var (
+	errUpgradeCancelledByOperator = errors.New("cancelled by operator")
+	errDaemonShuttingDown         = errors.New("daemon shutting down")
+	errUpgradeDeadline            = errors.New("upgrade deadline exceeded")
+	errUpgradeInterrupted         = errors.New("interrupted") // a synchronous run's context ended
+)
+
+// upgradeContext returns the contexts for one upgrade run that verifies the
+// new container verifyPasses times. ctx is cancelled by CancelUpgrade
+// (operator request or daemon shutdown) and by the UPGRADE_TIMEOUT_SECONDS
+// deadline; committed is bound only by the deadline and is used once the
+// upgrade passes the point of no return. The deadline is extended by the
+// verification window of each pass so a slow but healthy start is not cut off
+// while its health checks are still retrying.
+func (s *Server) upgradeContext(verifyPasses int) (ctx, committed context.Context, cancel context.CancelFunc) {
+	committed, cancelDeadline := context.Background(), context.CancelFunc(func() {})
+	if timeout := time.Duration(s.config.UpgradeTimeoutSeconds) * time.Second; timeout > 0 {
+		committed, cancelDeadline = context.WithTimeoutCause(context.Background(), timeout+time.Duration(verifyPasses)*s.verifyWindow, errUpgradeDeadline)
+	}
+	ctx, cancelCause := context.WithCancelCause(committed)
+
+	s.upgradeMu.Lock()
+	s.cancelUpgrade = cancelCause
+	s.upgradeMu.Unlock()
+
+	return ctx, committed, func() {
+		s.clearUpgradeCancel()
+		cancelCause(context.Canceled)
+		cancelDeadline()
+	}
+}
+
+// clearUpgradeCancel stops CancelUpgrade from reaching the current upgrade.
+// It is called once the upgrade passes the point of no return.
+func (s *Server) clearUpgradeCancel() {
+	s.upgradeMu.Lock()
+	s.cancelUpgrade = nil
+	s.upgradeMu.Unlock()
+}
+
+// CancelUpgrade cancels the in-flight upgrade if it has not yet reached a
+// destructive step. It reports whether there was an upgrade to cancel.
+func (s *Server) CancelUpgrade(cause error) bool {
+	s.upgradeMu.Lock()
+	defer s.upgradeMu.Unlock()
+	if s.cancelUpgrade == nil {
+		return false
+	}
+	s.cancelUpgrade(cause)
+	s.cancelUpgrade = nil
+	return true
+}
+
+// abortIfCancelled stops the job if ctx is done: it is marked cancelled, or
+// failed with UPGRADE_TIMEOUT when the deadline expired. It must only be used
+// before the container is stopped, when stopping leaves it untouched.
+func (s *Server) abortIfCancelled(ctx context.Context, job *jobs.Job) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	if errors.Is(context.Cause(ctx), errUpgradeDeadline) {
+		s.failUpgradeTimeout(job, "Upgrade timed out before the point of no return; container not modified")
+		return true
+	}
+	job.State = jobs.JobStateCancelled
+	job.FailureCode = ""
+	job.Message = fmt.Sprintf("Upgrade cancelled (%v); container not modified", context.Cause(ctx))
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("CANCELLED: %s", job.Message))
+	return true
+}
+
+// phaseFailed reports whether a destructive phase failed, reporting a failure
+// caused by the upgrade deadline as UPGRADE_TIMEOUT.
+func (s *Server) phaseFailed(ctx context.Context, job *jobs.Job, ok bool) bool {
+	if ok {
+		return false
+	}
+	s.failIfTimedOut(ctx, job)
+	return true
+}
+
+// failIfTimedOut re-labels the failure of a destructive phase as
+// UPGRADE_TIMEOUT when it was caused by the deadline rather than by the docker
+// or health error it surfaced as.
+func (s *Server) failIfTimedOut(ctx context.Context, job *jobs.Job) bool {
+	if !errors.Is(context.Cause(ctx), errUpgradeDeadline) {
+		return false
+	}
+	s.failUpgradeTimeout(job, fmt.Sprintf("Upgrade timed out after the point of no return (%s)", job.Message))
+	return true
+}
+
+func (s *Server) failUpgradeTimeout(job *jobs.Job, message string) {
+	job.State = jobs.JobStateFailed
+	job.FailureCode = "UPGRADE_TIMEOUT"
+	job.Message = message
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+}
+
+// phaseStopped reports whether the upgrade must stop after a pre-destructive
+// phase, either because the phase failed or because ctx was cancelled. A
+// failure caused by the cancellation is recorded as a cancellation.
+func (s *Server) phaseStopped(ctx context.Context, job *jobs.Job, ok bool) bool {
+	if s.abortIfCancelled(ctx, job) {
+		return true
+	}
+	return !ok
+}
+
+// enterPointOfNoReturn is called just before the container is stopped. It
+// detaches the upgrade from cancellation and returns committed, the context
+// to use for the destructive phases. A cancellation or deadline that arrived
+// before this point still wins: quiesced supervisor programs are restarted and
+// the job is stopped.
+func (s *Server) enterPointOfNoReturn(ctx, committed context.Context, job *jobs.Job, containerName string, quiescedPrograms []string) (context.Context, bool) {
+	s.clearUpgradeCancel()
+	if ctx.Err() != nil {
+		s.resumeQuiescedPrograms(context.WithoutCancel(ctx), job, containerName, quiescedPrograms)
+		s.abortIfCancelled(ctx, job)
+		return ctx, false
+	}
+	s.jobStore.AppendLog("Point of no return reached: the upgrade can no longer be cancelled")
+	return committed, true
+}
+
+// HandleUpgradeCancel returns a handler for the POST /upgrade/cancel endpoint.
+// Cancellation only takes effect before the container is stopped; after that
+// the upgrade runs to completion so the container is never left half-replaced.
+func (s *Server) HandleUpgradeCancel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !s.CancelUpgrade(errUpgradeCancelledByOperator) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "No cancellable upgrade in progress (none running, or already past the point of no return)",
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Cancellation requested; the upgrade stops before its next step",
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/telemetry"
+)
+
+const cancelTestInspect = `[{"Id":"abc","Name":"/payram","Image":"sha256:abc",` +
+	`"Config":{"Image":"payramapp/payram:1.0.0","Env":["POSTGRES_HOST=localhost"],"Labels":{}},` +
+	`"HostConfig":{"RestartPolicy":{"Name":"always","MaximumRetryCount":0},"PortBindings":{}},` +
+	`"Mounts":[],"NetworkSettings":{"Networks":{}}}]`
+
+// newCancelTestServer returns a server in execute mode whose docker binary is
+// a stub that records every invocation and blocks on blockOn (e.g. "pull").
+func newCancelTestServer(t *testing.T, upgradeTimeoutSeconds int, blockOn string) (*Server, *jobs.Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	callLog := filepath.Join(dir, "docker-calls.log")
+	dockerBin := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callLog + "\n" +
+		"case \"$1\" in\n" +
+		"  inspect) [ \"$2\" = --format ] && echo '[\"POSTGRES_HOST=localhost\",\"POSTGRES_DATABASE=payram\",\"POSTGRES_USERNAME=payram\"]' || echo '" + cancelTestInspect + "' ;;\n" +
+		"  info|version) echo 24.0.0 ;;\n" +
+		"  " + blockOn + ") exec sleep 5 ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		DockerBin:             dockerBin,
+		ExecutionMode:         "execute",
+		TargetContainerName:   "payram",
+		StateDir:              filepath.Join(dir, "state"),
+		FetchTimeoutSeconds:   1,
+		UpgradeTimeoutSeconds: upgradeTimeoutSeconds,
+		Backup:                config.BackupConfig{Dir: filepath.Join(dir, "backups")},
+	}
+	if err := os.MkdirAll(cfg.Backup.Dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	jobStore := jobs.NewStore(cfg.StateDir)
+	s := New(cfg, jobStore)
+	s.verifyWindow = 0
+	return s, jobStore, callLog
+}
+
+func startCancelTestJob(t *testing.T, s *Server, jobStore *jobs.Store) (*jobs.Job, chan struct{}) {
+	t.Helper()
+	job := jobs.NewJob("job-cancel", jobs.JobModeManual, "1.1.0")
+	job.ResolvedTarget = "1.1.0"
+	jobStore.Save(job)
+
+	done := make(chan struct{})
+	go func() {
+		s.executeUpgrade(job, &manifest.Manifest{Image: manifest.Image{Repo: "payramapp/payram"}}, nil, "")
+		close(done)
+	}()
+	return job, done
+}
+
+func waitForPull(t *testing.T, callLog string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := os.ReadFile(callLog); strings.Contains(string(data), "pull ") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("upgrade never reached the image pull")
+}
+
+func assertNoDestructiveDockerCalls(t *testing.T, callLog string) {
+	t.Helper()
+	data, _ := os.ReadFile(callLog)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && (fields[0] == "stop" || fields[0] == "rm" || fields[0] == "run") {
+			t.Errorf("expected no destructive docker calls after cancellation, got %q", line)
+		}
+	}
+}
+
+func TestExecuteUpgrade_OperatorCancelStopsBeforeDestructiveSteps(t *testing.T) {
+	s, jobStore, callLog := newCancelTestServer(t, 0, "pull")
+	job, done := startCancelTestJob(t, s, jobStore)
+
+	waitForPull(t, callLog)
+	if !s.CancelUpgrade(errUpgradeCancelledByOperator) {
+		t.Fatal("expected an upgrade to cancel")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upgrade did not stop after cancellation")
+	}
+
+	if job.State != jobs.JobStateCancelled {
+		t.Fatalf("expected state CANCELLED, got %s (%s: %s)", job.State, job.FailureCode, job.Message)
+	}
+	if !strings.Contains(job.Message, "cancelled by operator") {
+		t.Errorf("expected cancellation cause in message, got %q", job.Message)
+	}
+	assertNoDestructiveDockerCalls(t, callLog)
+
+	saved, err := jobStore.LoadLatest()
+	if err != nil || saved.State != jobs.JobStateCancelled {
+		t.Errorf("expected persisted state CANCELLED, got %+v (err=%v)", saved, err)
+	}
+	if s.CancelUpgrade(errUpgradeCancelledByOperator) {
+		t.Error("expected nothing left to cancel after the upgrade stopped")
+	}
+}
+
+func TestExecuteUpgrade_DeadlineFailsBeforeDestructiveSteps(t *testing.T) {
+	s, jobStore, callLog := newCancelTestServer(t, 1, "pull")
+	job, done := startCancelTestJob(t, s, jobStore)
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("upgrade did not stop at the deadline")
+	}
+
+	if job.State != jobs.JobStateFailed || job.FailureCode != "UPGRADE_TIMEOUT" {
+		t.Fatalf("expected FAILED/UPGRADE_TIMEOUT, got %s/%s (%s)", job.State, job.FailureCode, job.Message)
+	}
+	if !strings.Contains(job.Message, "container not modified") {
+		t.Errorf("expected message to say the container was untouched, got %q", job.Message)
+	}
+	assertNoDestructiveDockerCalls(t, callLog)
+}
+
+func TestExecuteUpgrade_ReportsTelemetryOutcome(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer collector.Close()
+
+	s, jobStore, _ := newCancelTestServer(t, 1, "pull")
+	s.telemetry = telemetry.New(true, collector.URL)
+	_, done := startCancelTestJob(t, s, jobStore)
+	<-done
+
+	var body []byte
+	select {
+	case body = <-bodies:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a telemetry event for the finished upgrade")
+	}
+	var event telemetry.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("payload is not a telemetry event: %v (%s)", err, body)
+	}
+	if event.Outcome != "failed" || event.FailureCode != "UPGRADE_TIMEOUT" {
+		t.Errorf("expected failed/UPGRADE_TIMEOUT, got %s/%s", event.Outcome, event.FailureCode)
+	}
+	if event.FromVersion != "1.0.0" || event.ToVersion != "1.1.0" {
+		t.Errorf("expected 1.0.0 -> 1.1.0, got %s -> %s", event.FromVersion, event.ToVersion)
+	}
+	if strings.Contains(string(body), "job-cancel") {
+		t.Errorf("telemetry payload leaks the job ID: %s", body)
+	}
+}
+
+func TestPhaseFailed_DeadlineAfterPointOfNoReturn(t *testing.T) {
+	s, _, _ := newCancelTestServer(t, 0, "stop")
+	job := jobs.NewJob("job-timeout", jobs.JobModeManual, "1.1.0")
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 200*time.Millisecond, errUpgradeDeadline)
+	defer cancel()
+
+	start := time.Now()
+	if !s.phaseFailed(ctx, job, s.stopContainerForUpgrade(ctx, job, "payram")) {
+		t.Fatal("expected the blocked docker stop to fail at the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the blocked docker stop to be killed at the deadline, took %s", elapsed)
+	}
+	if job.State != jobs.JobStateFailed || job.FailureCode != "UPGRADE_TIMEOUT" {
+		t.Fatalf("expected FAILED/UPGRADE_TIMEOUT, got %s/%s (%s)", job.State, job.FailureCode, job.Message)
+	}
+	if !strings.Contains(job.Message, "after the point of no return") {
+		t.Errorf("expected message to mention the point of no return, got %q", job.Message)
+	}
+}
+
+func TestUpgradeContext_DeadlineCoversHealthRetries(t *testing.T) {
+	s := New(&config.Config{Port: 8080, UpgradeTimeoutSeconds: 60}, jobs.NewStore(t.TempDir()))
+
+	ctx, committed, cancel := s.upgradeContext(2)
+	defer cancel()
+
+	want := time.Now().Add(60*time.Second + 2*healthVerifyWindow)
+	for name, c := range map[string]context.Context{"ctx": ctx, "committed": committed} {
+		deadline, ok := c.Deadline()
+		if !ok {
+			t.Fatalf("%s: expected a deadline", name)
+		}
+		if diff := want.Sub(deadline); diff < 0 || diff > time.Second {
+			t.Errorf("%s: expected deadline near %s, got %s", name, want, deadline)
+		}
+	}
+
+	if !s.CancelUpgrade(errUpgradeCancelledByOperator) {
+		t.Fatal("expected the upgrade to be cancellable")
+	}
+	if ctx.Err() == nil {
+		t.Error("expected ctx to be cancelled")
+	}
+	if committed.Err() != nil {
+		t.Error("expected committed to survive an operator cancel")
+	}
+}
+
+func TestHandleUpgradeCancel_NoUpgrade(t *testing.T) {
+	server := New(&config.Config{Port: 8080}, jobs.NewStore(t.TempDir()))
+
+	w := httptest.NewRecorder()
+	server.HandleUpgradeCancel()(w, httptest.NewRequest(http.MethodPost, "/upgrade/cancel", nil))
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body["error"] == "" {
+		t.Error("expected an error message")
+	}
+}
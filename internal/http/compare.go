@@ -0,0 +1,250 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	goversion "github.com/hashicorp/go-version"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/policy"
+)
+
+// compareDurationSamples is how many recent successful upgrades the duration
+// estimate of POST /upgrade/compare is based on.
+const compareDurationSamples = 10
+
+// CompareRequest represents the request body for POST /upgrade/compare.
+type CompareRequest struct {
+	Mode           string   `json:"mode"`
+	Source         string   `json:"source"`
+	Targets        []string `json:"targets"`        // exactly two versions or strategies to compare
+	CurrentVersion string   `json:"currentVersion"` // running version of the core container; resolved from the container when empty
+	ImageRepo      string   `json:"imageRepo"`      // optional: image repo to use instead of the manifest's
+}
+
+// CompareGate is a breakpoint or stop point an upgrade to a target crosses.
+type CompareGate struct {
+	Version string `json:"version"`
+	Kind    string `json:"kind"` // "breakpoint" or "stop_point"
+	Reason  string `json:"reason,omitempty"`
+}
+
+// CompareOutcome is the plan outcome of one target in a comparison.
+type CompareOutcome struct {
+	RequestedTarget string `json:"requestedTarget"`
+	State           string `json:"state"`
+	ResolvedTarget  string `json:"resolvedTarget,omitempty"`
+	SteppingStone   string `json:"steppingStone,omitempty"`
+	FailureCode     string `json:"failureCode,omitempty"`
+	Message         string `json:"message"`
+	AlreadyOnTarget bool   `json:"alreadyOnTarget,omitempty"`
+	// DashboardEligible is set when a dashboard upgrade reaches the target in
+	// one job, without being capped at a stepping stone or stopped for SSH.
+	DashboardEligible bool          `json:"dashboardEligible"`
+	GatesCrossed      []CompareGate `json:"gatesCrossed,omitempty"`
+	// EstimatedDurationSeconds is the median duration of recent successful
+	// upgrades, doubled when the job also upgrades through a stepping stone.
+	// Omitted when there is no upgrade history to base it on.
+	EstimatedDurationSeconds int64    `json:"estimatedDurationSeconds,omitempty"`
+	Warnings                 []string `json:"warnings,omitempty"`
+}
+
+// CompareResponse represents the response for POST /upgrade/compare.
+type CompareResponse struct {
+	Mode           string           `json:"mode"`
+	CurrentVersion string           `json:"currentVersion,omitempty"`
+	Targets        []CompareOutcome `json:"targets"`
+}
+
+// HandleUpgradeCompare returns a handler for the POST /upgrade/compare
+// endpoint. It plans each of two targets read-only, as POST /upgrade/plan
+// does, with the same image repo and Docker version checks, and returns
+// their outcomes side by side.
+func (s *Server) HandleUpgradeCompare() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req CompareRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		mode, err := resolveMode(req.Mode, req.Source)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(req.Targets) != 2 || strings.TrimSpace(req.Targets[0]) == "" || strings.TrimSpace(req.Targets[1]) == "" {
+			http.Error(w, "targets must list exactly two versions", http.StatusBadRequest)
+			return
+		}
+		if req.ImageRepo != "" {
+			if err := checkImageRepoCaller(r, req.Source); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err := validateImageRepo(req.ImageRepo); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		currentVersion := req.CurrentVersion
+		if currentVersion == "" {
+			currentVersion = s.resolveCurrentVersion(r.Context())
+		}
+
+		estimate := s.recentUpgradeDuration()
+		response := CompareResponse{Mode: string(mode), CurrentVersion: currentVersion}
+		for _, target := range req.Targets {
+			response.Targets = append(response.Targets, s.compareTarget(ctx, mode, target, currentVersion, req.ImageRepo, estimate))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// compareTarget plans target in mode and, to judge dashboard eligibility and
+// the gates crossed, in both manual mode (which ignores gates) and dashboard
+// mode. Each plan is checked against imageRepo and the Docker version as
+// POST /upgrade/plan checks it. estimate is the duration of one upgrade, or 0
+// if unknown.
+func (s *Server) compareTarget(ctx context.Context, mode jobs.JobMode, target, currentVersion, imageRepo string, estimate time.Duration) CompareOutcome {
+	plans := map[jobs.JobMode]*UpgradePlan{}
+	planIn := func(m jobs.JobMode) *UpgradePlan {
+		if plans[m] == nil {
+			plan := s.PlanUpgrade(ctx, m, target, currentVersion)
+			s.applyImageRepoOverride(plan, imageRepo)
+			s.checkDockerVersion(ctx, plan)
+			plans[m] = plan
+		}
+		return plans[m]
+	}
+
+	plan := planIn(mode)
+	if plan.State != jobs.JobStateFailed {
+		markAlreadyOnTarget(plan, currentVersion)
+	}
+	outcome := CompareOutcome{
+		RequestedTarget: target,
+		State:           string(plan.State),
+		ResolvedTarget:  plan.ResolvedTarget,
+		SteppingStone:   plan.SteppingStone,
+		FailureCode:     plan.FailureCode,
+		Message:         plan.Message,
+		AlreadyOnTarget: plan.AlreadyOnTarget,
+		Warnings:        plan.Warnings,
+	}
+
+	ungated, dashboard := planIn(jobs.JobModeManual), planIn(jobs.JobModeDashboard)
+	outcome.DashboardEligible = dashboard.State != jobs.JobStateFailed && dashboard.SteppingStone == "" &&
+		ungated.ResolvedTarget != "" && dashboard.ResolvedTarget == ungated.ResolvedTarget
+	if ungated.policyData != nil {
+		outcome.GatesCrossed = crossedGates(ungated.policyData, currentVersion, ungated.ResolvedTarget)
+	}
+
+	if estimate > 0 && plan.State != jobs.JobStateFailed && !plan.AlreadyOnTarget {
+		hops := int64(1)
+		if plan.SteppingStone != "" {
+			hops = 2
+		}
+		outcome.EstimatedDurationSeconds = hops * int64(estimate.Seconds())
+	}
+	return outcome
+}
+
+// crossedGates returns the breakpoints and stop points of p that an upgrade
+// from currentVersion to target crosses (current < gate <= target), lowest
+// first. It returns nil when either version is unknown or unparseable.
+func crossedGates(p *policy.Policy, currentVersion, target string) []CompareGate {
+	parse := func(v string) (*goversion.Version, error) {
+		return goversion.NewVersion(strings.TrimPrefix(strings.TrimSpace(v), "v"))
+	}
+	cur, err := parse(currentVersion)
+	if err != nil || currentVersion == "" {
+		return nil
+	}
+	tgt, err := parse(target)
+	if err != nil {
+		return nil
+	}
+
+	type crossed struct {
+		ver  *goversion.Version
+		gate CompareGate
+	}
+	var gates []crossed
+	add := func(version, kind, reason string) {
+		v, err := parse(version)
+		if err != nil || !cur.LessThan(v) || tgt.LessThan(v) {
+			return
+		}
+		gates = append(gates, crossed{ver: v, gate: CompareGate{Version: version, Kind: kind, Reason: reason}})
+	}
+	for _, bp := range p.Breakpoints {
+		add(bp.Version, "breakpoint", bp.Reason)
+	}
+	for _, sp := range p.StopPoints {
+		add(sp.Version, "stop_point", sp.Reason)
+	}
+
+	sort.SliceStable(gates, func(i, j int) bool { return gates[i].ver.LessThan(gates[j].ver) })
+	var out []CompareGate
+	for _, g := range gates {
+		out = append(out, g.gate)
+	}
+	return out
+}
+
+// recentUpgradeDuration returns the median duration of the most recent
+// successful upgrades in the history, or 0 when there are none. Dry runs are
+// recorded as "validated" and do not count.
+func (s *Server) recentUpgradeDuration() time.Duration {
+	events, err := s.historyStore.List(1000, "upgrade", "")
+	if err != nil {
+		return 0
+	}
+
+	// events are newest first, so a job's end is seen before its start
+	finished := map[string]time.Time{}
+	var durations []time.Duration
+	for _, event := range events {
+		jobID := event.Data["jobId"]
+		at, err := time.Parse(time.RFC3339Nano, event.Timestamp)
+		if jobID == "" || err != nil {
+			continue
+		}
+		switch event.Status {
+		case "succeeded":
+			finished[jobID] = at
+		case "started":
+			if end, ok := finished[jobID]; ok && end.After(at) {
+				durations = append(durations, end.Sub(at))
+				delete(finished, jobID)
+			}
+		}
+		if len(durations) == compareDurationSamples {
+			break
+		}
+	}
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2]
+}
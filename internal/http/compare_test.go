@@ -0,0 +1,156 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/policy"
+)
+
+func postCompare(t *testing.T, srv *Server, body string) (int, CompareResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/upgrade/compare", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.HandleUpgradeCompare()(w, req)
+
+	var resp CompareResponse
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestHandleUpgradeCompare_EligibleVersusBreakpoint(t *testing.T) {
+	releases := []string{"1.7.5", "1.7.9", "1.8.0", "1.9.9"}
+	breakpoints := []map[string]string{{"version": "1.8.0", "reason": "Schema rewrite."}}
+	srv := newTestServer(t, buildPolicyFile(t, "1.9.9", releases, breakpoints), buildManifestFile(t))
+	srv.historyStore = history.NewStore(t.TempDir())
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, minutes := range []int{4, 6, 30} {
+		jobID := string(rune('a' + i))
+		started := start.Add(time.Duration(i) * time.Hour)
+		srv.recordHistory(history.Event{Type: "upgrade", Status: "started", Timestamp: started.Format(time.RFC3339Nano), Data: map[string]string{"jobId": jobID}})
+		srv.recordHistory(history.Event{Type: "upgrade", Status: "succeeded", Timestamp: started.Add(time.Duration(minutes) * time.Minute).Format(time.RFC3339Nano), Data: map[string]string{"jobId": jobID}})
+	}
+
+	code, resp := postCompare(t, srv, `{"targets":["1.7.9","1.9.9"],"currentVersion":"1.7.5"}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.Mode != "DASHBOARD" || resp.CurrentVersion != "1.7.5" || len(resp.Targets) != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	eligible, gated := resp.Targets[0], resp.Targets[1]
+	if !eligible.DashboardEligible || eligible.ResolvedTarget != "1.7.9" || len(eligible.GatesCrossed) != 0 {
+		t.Errorf("expected 1.7.9 to be dashboard-eligible with no gates, got %+v", eligible)
+	}
+	if eligible.EstimatedDurationSeconds != 360 {
+		t.Errorf("expected the median upgrade duration (360s), got %d", eligible.EstimatedDurationSeconds)
+	}
+
+	if gated.DashboardEligible {
+		t.Errorf("expected 1.9.9 not to be dashboard-eligible across the breakpoint, got %+v", gated)
+	}
+	if gated.ResolvedTarget != "1.8.0" || gated.SteppingStone != "1.7.9" {
+		t.Errorf("expected the dashboard plan to chain through 1.7.9 to 1.8.0, got %s via %s", gated.ResolvedTarget, gated.SteppingStone)
+	}
+	if len(gated.GatesCrossed) != 1 || gated.GatesCrossed[0] != (CompareGate{Version: "1.8.0", Kind: "breakpoint", Reason: "Schema rewrite."}) {
+		t.Errorf("expected the 1.8.0 breakpoint to be crossed, got %+v", gated.GatesCrossed)
+	}
+	if gated.EstimatedDurationSeconds != 720 {
+		t.Errorf("expected two hops to double the estimate (720s), got %d", gated.EstimatedDurationSeconds)
+	}
+}
+
+func TestHandleUpgradeCompare_StopPointBlocks(t *testing.T) {
+	releases := []string{"1.9.9", "2.0.0", "2.1.0"}
+	stopPoints := []map[string]string{{"version": "2.0.0", "reason": "SSH required."}}
+	srv := newTestServer(t, buildPolicyFileWithStopPoints(t, "2.1.0", releases, nil, stopPoints), buildManifestFile(t))
+
+	_, resp := postCompare(t, srv, `{"targets":["2.1.0","1.9.9"],"currentVersion":"1.9.9"}`)
+	blocked, current := resp.Targets[0], resp.Targets[1]
+	if blocked.FailureCode != "MANUAL_UPGRADE_REQUIRED" || blocked.DashboardEligible {
+		t.Errorf("expected 2.1.0 to need a manual upgrade, got %+v", blocked)
+	}
+	if len(blocked.GatesCrossed) != 1 || blocked.GatesCrossed[0].Kind != "stop_point" {
+		t.Errorf("expected the stop point to be crossed, got %+v", blocked.GatesCrossed)
+	}
+	if !current.AlreadyOnTarget || current.EstimatedDurationSeconds != 0 {
+		t.Errorf("expected the running version to be a no-op, got %+v", current)
+	}
+}
+
+func TestHandleUpgradeCompare_ChecksLikePlan(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	manifestJSON := strings.Replace(minimalManifest, `"image"`, `"min_docker_version": "25.0.0", "image"`, 1)
+	if err := os.WriteFile(manifestPath, []byte(manifestJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n[ \"$1\" = info ] && echo 20.10.24\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv := New(&config.Config{
+		PolicyURL:           buildPolicyFile(t, "1.2.0", []string{"1.1.0", "1.2.0"}, nil),
+		RuntimeManifestURL:  manifestPath,
+		FetchTimeoutSeconds: 5,
+		DockerBin:           dockerBin,
+	}, jobs.NewStore(t.TempDir()))
+
+	_, resp := postCompare(t, srv, `{"targets":["1.2.0","1.1.0"],"currentVersion":"1.0.0"}`)
+	for _, outcome := range resp.Targets {
+		if outcome.FailureCode != "DOCKER_TOO_OLD" || outcome.DashboardEligible {
+			t.Errorf("expected %s to fail with DOCKER_TOO_OLD as plan would, got %+v", outcome.RequestedTarget, outcome)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/upgrade/compare", strings.NewReader(`{"targets":["1.2.0","1.1.0"],"imageRepo":"ghcr.io/fork/payram"}`))
+	w := httptest.NewRecorder()
+	srv.HandleUpgradeCompare()(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected an image repo override from the API to be refused with 403, got %d", w.Code)
+	}
+}
+
+func TestHandleUpgradeCompare_RejectsBadRequests(t *testing.T) {
+	srv := newTestServer(t, buildPolicyFile(t, "1.9.9", []string{"1.9.9"}, nil), buildManifestFile(t))
+	for _, body := range []string{`{"targets":["1.9.9"]}`, `{"targets":["1.9.9","1.8.0","1.7.0"]}`, `{"targets":["1.9.9",""]}`, `not json`} {
+		if code, _ := postCompare(t, srv, body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/upgrade/compare", nil)
+	w := httptest.NewRecorder()
+	srv.HandleUpgradeCompare()(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestCrossedGates(t *testing.T) {
+	p := &policy.Policy{
+		Breakpoints: []policy.Breakpoint{{Version: "2.0.0"}, {Version: "1.8.0"}},
+		StopPoints:  []policy.StopPoint{{Version: "v1.9.0"}, {Version: "3.0.0"}},
+	}
+	got := crossedGates(p, "1.8.0", "2.0.0")
+	if len(got) != 2 || got[0].Version != "v1.9.0" || got[1].Version != "2.0.0" {
+		t.Errorf("expected v1.9.0 then 2.0.0, got %+v", got)
+	}
+	if got := crossedGates(p, "", "2.0.0"); got != nil {
+		t.Errorf("expected no gates without a current version, got %+v", got)
+	}
+}
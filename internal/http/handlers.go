@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,18 +16,22 @@ import (
 	"github.com/payram/payram-updater/internal/jobs"
 	"github.com/payram/payram-updater/internal/logger"
 	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/orchestrator"
 	"github.com/payram/payram-updater/internal/recovery"
 )
 
 // HealthResponse represents the health check response.
 type HealthResponse struct {
-	Status string `json:"status"`
+	Status string        `json:"status"`           // "ok" or "degraded"
+	Checks []HealthCheck `json:"checks,omitempty"` // readiness checks; omitted by /livez
 }
 
 // UpgradeStatusResponse extends Job with recovery playbook for FAILED states.
 type UpgradeStatusResponse struct {
 	*jobs.Job
 	RecoveryPlaybook *recovery.Playbook `json:"recoveryPlaybook,omitempty"`
+	// Operations is the operation queue, when SERIALIZE_OPERATIONS is set.
+	Operations *orchestrator.Status `json:"operations,omitempty"`
 }
 
 // HistoryResponse represents the response for history queries.
@@ -41,18 +46,26 @@ type PlanRequest struct {
 	RequestedTarget string `json:"requestedTarget"`
 	Source          string `json:"source"`
 	CurrentVersion  string `json:"currentVersion"` // running version of the core container; enables breakpoint crossing detection
+	PrintRunCommand bool   `json:"printRunCommand"` // also build the docker run command the upgrade would use
+	ImageRepo       string `json:"imageRepo"`       // optional: image repo to use instead of the manifest's
 }
 
 // PlanResponse represents the response for POST /upgrade/plan.
 type PlanResponse struct {
-	State           string `json:"state"`
-	Mode            string `json:"mode"`
-	RequestedTarget string `json:"requestedTarget"`
-	ResolvedTarget  string `json:"resolvedTarget,omitempty"`
-	FailureCode     string `json:"failureCode,omitempty"`
-	Message         string `json:"message"`
-	ImageRepo       string `json:"imageRepo,omitempty"`
-	ContainerName   string `json:"containerName,omitempty"`
+	State             string     `json:"state"`
+	Mode              string     `json:"mode"`
+	RequestedTarget   string     `json:"requestedTarget"`
+	ResolvedTarget    string     `json:"resolvedTarget,omitempty"`
+	FailureCode       string     `json:"failureCode,omitempty"`
+	Message           string     `json:"message"`
+	ImageRepo         string     `json:"imageRepo,omitempty"`
+	ImageRepoOverride bool       `json:"imageRepoOverride,omitempty"` // ImageRepo comes from the request, not the manifest
+	ContainerName     string     `json:"containerName,omitempty"`
+	RunCommand        string     `json:"runCommand,omitempty"`      // with printRunCommand: the docker run command, env values redacted
+	RunCommandError   string     `json:"runCommandError,omitempty"` // with printRunCommand: why the command could not be built
+	AlreadyOnTarget   bool       `json:"alreadyOnTarget,omitempty"` // the running version is the target; run is a no-op unless forced
+	Warnings          []string   `json:"warnings,omitempty"`        // non-fatal planning issues, e.g. a manual target missing from the policy releases
+	Steps             []PlanStep `json:"steps,omitempty"`           // the steps run would execute, in order; a job's step is one of their names
 }
 
 // RunRequest represents the request body for POST /upgrade/run.
@@ -61,6 +74,10 @@ type RunRequest struct {
 	RequestedTarget string `json:"requestedTarget"`
 	Source          string `json:"source"` // Origin of request, defaults to "UNKNOWN"
 	CurrentVersion  string `json:"currentVersion"` // running version of the core container; enables breakpoint crossing detection
+	Force           bool   `json:"force"`          // skip the MIN_UPGRADE_INTERVAL_MINUTES check
+	ImageRepo       string `json:"imageRepo"`      // optional: image repo to use instead of the manifest's
+	RestartOnly     bool   `json:"restartOnly"`    // restart the running container without changing its version; requestedTarget is ignored
+	SkipVerify      bool   `json:"skipVerify"`     // manual mode only: skip post-upgrade verification; the job succeeds unverified
 }
 
 func parseJobMode(value string) (jobs.JobMode, error) {
@@ -96,21 +113,56 @@ type RunResponse struct {
 	ResolvedTarget  string `json:"resolvedTarget,omitempty"`
 	FailureCode     string `json:"failureCode,omitempty"`
 	Message         string `json:"message"`
+	AlreadyOnTarget bool   `json:"alreadyOnTarget,omitempty"` // the running version is the target; no job was created
+}
+
+// FailureCodeInfo classifies a failure code for the /enums endpoint.
+type FailureCodeInfo struct {
+	Code     string            `json:"code"`
+	Title    string            `json:"title"`
+	Severity recovery.Severity `json:"severity"`
+	DataRisk recovery.DataRisk `json:"dataRisk"`
+}
+
+// EnumsResponse lists the values the daemon can report, so clients do not
+// have to hardcode them.
+type EnumsResponse struct {
+	JobStates    []jobs.JobState     `json:"jobStates"`
+	FailureCodes []FailureCodeInfo   `json:"failureCodes"`
+	Severities   []recovery.Severity `json:"severities"`
+	DataRisks    []recovery.DataRisk `json:"dataRisks"`
 }
 
-// HandleHealth returns a handler for the /health endpoint.
-func HandleHealth() http.HandlerFunc {
+// HandleEnums returns a handler for the /enums endpoint. Failure codes are
+// sorted by code.
+func HandleEnums() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		codes := recovery.AllCodes()
+		sort.Strings(codes)
+		failureCodes := make([]FailureCodeInfo, 0, len(codes))
+		for _, code := range codes {
+			playbook := recovery.GetPlaybook(code)
+			failureCodes = append(failureCodes, FailureCodeInfo{
+				Code:     code,
+				Title:    playbook.Title,
+				Severity: playbook.Severity,
+				DataRisk: playbook.DataRisk,
+			})
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-
-		response := HealthResponse{Status: "ok"}
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(EnumsResponse{
+			JobStates:    jobs.AllStates(),
+			FailureCodes: failureCodes,
+			Severities:   recovery.AllSeverities(),
+			DataRisks:    recovery.AllDataRisks(),
+		})
 	}
 }
 
@@ -139,6 +191,10 @@ func (s *Server) HandleUpgradeStatus() http.HandlerFunc {
 
 		// Build response with recovery playbook if job failed
 		response := UpgradeStatusResponse{Job: job}
+		if s.operations != nil {
+			operations := s.operations.Status()
+			response.Operations = &operations
+		}
 		if job.State == jobs.JobStateFailed && job.FailureCode != "" {
 			ctx := s.buildPlaybookContext(job.BackupPath)
 			playbook := recovery.RenderPlaybook(job.FailureCode, ctx)
@@ -152,6 +208,9 @@ func (s *Server) HandleUpgradeStatus() http.HandlerFunc {
 }
 
 // HandleUpgradeLogs returns a handler for the /upgrade/logs endpoint.
+// With ?after=<cursor> the log is written from that cursor (see jobs.LogCursor)
+// instead of from the start, and with ?follow=true the response stays open
+// and streams new lines (see followLogs).
 func (s *Server) HandleUpgradeLogs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -159,23 +218,24 @@ func (s *Server) HandleUpgradeLogs() http.HandlerFunc {
 			return
 		}
 
-		// Read logs
-		logs, err := s.jobStore.ReadLogs()
+		after, err := jobs.ParseLogCursor(r.URL.Query().Get("after"))
 		if err != nil {
-			logger.Error("Server", "HandleUpgradeLogs", err)
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusOK)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(logs))
+		if r.URL.Query().Get("follow") == "true" {
+			s.followLogs(w, r, after)
+			return
+		}
+		s.writeLogs(w, after)
 	}
 }
 
 // HandleHistory returns a handler for history queries.
 // Supports query params: ?type=upgrade|backup|restore&status=started|succeeded|failed&limit=100
+// With ?format=jsonl the full filtered history is streamed as JSON Lines (oldest first);
+// limit is not supported in that mode.
 func (s *Server) HandleHistory() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -186,6 +246,25 @@ func (s *Server) HandleHistory() http.HandlerFunc {
 		q := r.URL.Query()
 		typeFilter := strings.TrimSpace(q.Get("type"))
 		statusFilter := strings.TrimSpace(q.Get("status"))
+
+		switch format := strings.TrimSpace(q.Get("format")); format {
+		case "", "json":
+		case "jsonl":
+			if q.Get("limit") != "" {
+				http.Error(w, "limit is not supported with format=jsonl", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			if _, err := s.historyStore.Export(w, typeFilter, statusFilter); err != nil {
+				logger.Error("Server", "HandleHistory", err)
+			}
+			return
+		default:
+			http.Error(w, "invalid format", http.StatusBadRequest)
+			return
+		}
+
 		limit := 100
 		if rawLimit := strings.TrimSpace(q.Get("limit")); rawLimit != "" {
 			parsed, err := strconv.Atoi(rawLimit)
@@ -214,6 +293,7 @@ func isJobActive(job *jobs.Job) bool {
 	// Active states are those that indicate ongoing work
 	return job.State == jobs.JobStatePolicyFetching ||
 		job.State == jobs.JobStateManifestFetching ||
+		job.State == jobs.JobStateBackingUp ||
 		job.State == jobs.JobStateExecuting ||
 		job.State == jobs.JobStateVerifying
 }
@@ -298,85 +378,72 @@ func (s *Server) HandleUpgradeInspect() http.HandlerFunc {
 			return
 		}
 
-		// Resolve container name for inspection
-		// For inspect, we need to fetch the manifest first to get the container name
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
+		result := s.inspectSystem(ctx)
 
-		// Fetch manifest to get container name
-		manifestClient := manifest.NewClient(time.Duration(s.config.FetchTimeoutSeconds) * time.Second)
-		manifestData, _ := manifestClient.Fetch(ctx, s.config.RuntimeManifestURL)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
 
-		// Resolve container name
-		resolver := container.NewResolver(s.config.TargetContainerName, s.config.DockerBin, logger.StdLogger())
-		resolved, err := resolver.Resolve(manifestData)
-		if err != nil {
-			if resErr, ok := err.(*container.ResolutionError); ok && resErr.GetFailureCode() == "CONTAINER_NAME_UNRESOLVED" {
-				imagePattern := "payramapp/payram:"
-				if s.config.ImageRepoOverride != "" {
-					imagePattern = s.config.ImageRepoOverride + ":"
-				}
-				discoverer := container.NewDiscoverer(s.config.DockerBin, imagePattern, logger.StdLogger())
-				discovered, discoverErr := discoverer.DiscoverPayramContainer(ctx)
-				if discoverErr != nil {
-					// For inspect, return error in JSON instead of failing
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusOK)
-					json.NewEncoder(w).Encode(inspect.InspectResult{
-						OverallState: inspect.StateBroken,
-						Issues: []inspect.Issue{
-							{
-								Component:   "container",
-								Description: err.Error(),
-								Severity:    "CRITICAL",
-							},
-						},
-						Checks: map[string]inspect.CheckResult{
-							"container_name": {Status: "FAILED", Message: err.Error()},
-						},
-					})
-					return
-				}
-				resolved = &container.ResolvedContainer{Name: discovered.Name}
-			} else {
-				// For inspect, return error in JSON instead of failing
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusOK)
-				json.NewEncoder(w).Encode(inspect.InspectResult{
-					OverallState: inspect.StateBroken,
-					Issues: []inspect.Issue{
-						{
-							Component:   "container",
-							Description: err.Error(),
-							Severity:    "CRITICAL",
-						},
-					},
-					Checks: map[string]inspect.CheckResult{
-						"container_name": {Status: "FAILED", Message: err.Error()},
-					},
-				})
-				return
+// inspectSystem resolves the target container and runs every inspection
+// check against it. A container that cannot be resolved is reported as a
+// BROKEN result rather than an error.
+func (s *Server) inspectSystem(ctx context.Context) *inspect.InspectResult {
+	// For inspect, we need to fetch the manifest first to get the container name
+	manifestClient := manifest.NewClient(time.Duration(s.config.FetchTimeoutSeconds) * time.Second)
+	manifestData, _ := manifestClient.Fetch(ctx, s.config.RuntimeManifestURL)
+
+	// Resolve container name
+	resolver := container.NewResolver(s.config.TargetContainerName, s.config.DockerBin, logger.StdLogger())
+	resolved, err := resolver.Resolve(manifestData)
+	if err != nil {
+		if resErr, ok := err.(*container.ResolutionError); ok && resErr.GetFailureCode() == "CONTAINER_NAME_UNRESOLVED" {
+			discovered, discoverErr := s.payramDiscoverer().DiscoverPayramContainer(ctx)
+			if discoverErr != nil {
+				return unresolvedInspectResult(err)
 			}
+			resolved = &container.ResolvedContainer{Name: discovered.Name}
+		} else {
+			return unresolvedInspectResult(err)
 		}
+	}
 
-		containerName := resolved.Name
-		logger.Infof("Server", "HandleUpgradeInspect", "Target container resolved as: %s", containerName)
-
-		inspector := inspect.NewInspector(
-			s.jobStore,
-			s.dockerRunner.DockerBin,
-			containerName,
-			s.coreClient.BaseURL, // Use resolved BaseURL from coreClient (handles auto-discovery)
-			s.config.PolicyURL,
-			s.config.RuntimeManifestURL,
-			s.config.DebugVersionMode,
-		)
-
-		result := inspector.Run(ctx)
+	containerName := resolved.Name
+	logger.Infof("Server", "inspectSystem", "Target container resolved as: %s", containerName)
+
+	inspector := inspect.NewInspector(
+		s.jobStore,
+		s.dockerRunner.DockerBin,
+		containerName,
+		s.coreClient.BaseURL, // Use resolved BaseURL from coreClient (handles auto-discovery)
+		s.config.PolicyURL,
+		s.config.RuntimeManifestURL,
+		s.config.DebugVersionMode,
+	)
+	inspector.SetBackupCheck(s.backupManager, time.Duration(s.config.Backup.MaxAgeHours)*time.Hour)
+	inspector.SetBackupLocationCheck(s.config.Backup.Dir)
+	inspector.SetNameCheck(resolved, s.payramDiscoverer())
+
+	return inspector.Run(ctx)
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(result)
+// unresolvedInspectResult reports a target container that could not be resolved.
+func unresolvedInspectResult(err error) *inspect.InspectResult {
+	return &inspect.InspectResult{
+		OverallState: inspect.StateBroken,
+		Issues: []inspect.Issue{
+			{
+				Component:   "container",
+				Description: err.Error(),
+				Severity:    "CRITICAL",
+			},
+		},
+		Checks: map[string]inspect.CheckResult{
+			"container_name": {Status: "FAILED", Message: err.Error()},
+		},
 	}
 }
 
@@ -408,6 +475,16 @@ func (s *Server) HandleUpgradePlan() http.HandlerFunc {
 			http.Error(w, "requestedTarget is required", http.StatusBadRequest)
 			return
 		}
+		if req.ImageRepo != "" {
+			if err := checkImageRepoCaller(r, req.Source); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err := validateImageRepo(req.ImageRepo); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
 		// Perform read-only planning
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
@@ -418,26 +495,27 @@ func (s *Server) HandleUpgradePlan() http.HandlerFunc {
 		// gate enforcement (breakpoints and stop points).
 		currentVersion := req.CurrentVersion
 		if currentVersion == "" {
-			resolveCtx, resolveCancel := context.WithTimeout(r.Context(), time.Duration(s.config.FetchTimeoutSeconds)*time.Second)
-			defer resolveCancel()
-			if containerName, cnErr := s.discoverContainerName(resolveCtx); cnErr == nil {
-				initVersion := s.fetchPolicyInitVersion(resolveCtx)
-				if ver, _, verErr := s.resolveCoreVersion(resolveCtx, containerName, initVersion); verErr == nil {
-					currentVersion = ver
-				}
-			}
+			currentVersion = s.resolveCurrentVersion(r.Context())
 		}
 
 		plan := s.PlanUpgrade(ctx, mode, req.RequestedTarget, currentVersion)
+		s.applyImageRepoOverride(plan, req.ImageRepo)
+		s.checkDockerVersion(ctx, plan)
+		if plan.State != jobs.JobStateFailed {
+			markAlreadyOnTarget(plan, currentVersion)
+		}
 
 		// Build response
 		response := PlanResponse{
-			State:           string(plan.State),
-			Mode:            string(plan.Mode),
-			RequestedTarget: plan.RequestedTarget,
-			ResolvedTarget:  plan.ResolvedTarget,
-			FailureCode:     plan.FailureCode,
-			Message:         plan.Message,
+			State:             string(plan.State),
+			Mode:              string(plan.Mode),
+			RequestedTarget:   plan.RequestedTarget,
+			ResolvedTarget:    plan.ResolvedTarget,
+			FailureCode:       plan.FailureCode,
+			Message:           plan.Message,
+			ImageRepoOverride: plan.ImageRepoOverride != "",
+			AlreadyOnTarget:   plan.AlreadyOnTarget,
+			Warnings:          plan.Warnings,
 		}
 
 		// Add manifest info if available
@@ -470,6 +548,23 @@ func (s *Server) HandleUpgradePlan() http.HandlerFunc {
 			}
 		}
 
+		if response.FailureCode == "" && !plan.AlreadyOnTarget {
+			response.Steps = upgradeSteps(plan.SteppingStone)
+		}
+
+		// Tell the operator where the manifest defaults disagree with the running container
+		if response.FailureCode == "" && response.ContainerName != "" {
+			response.Warnings = append(response.Warnings, s.manifestDivergenceWarnings(ctx, response.ContainerName, plan)...)
+		}
+
+		if req.PrintRunCommand && response.FailureCode == "" && response.ContainerName != "" {
+			if runCommand, err := s.plannedRunCommand(ctx, response.ContainerName, plan); err != nil {
+				response.RunCommandError = err.Error()
+			} else {
+				response.RunCommand = runCommand
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(response)
@@ -497,12 +592,28 @@ func (s *Server) HandleUpgradeRun() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if req.SkipVerify {
+			if err := validateSkipVerify(mode, req.RestartOnly); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
 		// Validate requestedTarget
-		if req.RequestedTarget == "" {
+		if req.RequestedTarget == "" && !req.RestartOnly {
 			http.Error(w, "requestedTarget is required", http.StatusBadRequest)
 			return
 		}
+		if req.ImageRepo != "" {
+			if err := checkImageRepoCaller(r, req.Source); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err := validateImageRepo(req.ImageRepo); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
 		// Validate source
 		source := req.Source
@@ -529,6 +640,50 @@ func (s *Server) HandleUpgradeRun() http.HandlerFunc {
 			})
 			return
 		}
+		if op, queued := s.operations.Pending(orchestrator.KindUpgrade, orchestrator.KindAutoUpdate, orchestrator.KindRestart); queued {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "An upgrade is already queued",
+				"jobId":   op.ID,
+				"message": "Wait for the queued upgrade to complete or check its status",
+			})
+			return
+		}
+
+		// A restart is not an upgrade: no plan, no interval check
+		if req.RestartOnly {
+			job, err := s.startRestartJob(mode, source)
+			if err != nil {
+				logger.Error("Server", "HandleUpgradeRun", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(RunResponse{
+				JobID:   job.JobID,
+				State:   string(job.State),
+				Mode:    string(job.Mode),
+				Message: "Restart job started",
+			})
+			return
+		}
+
+		if !req.Force {
+			if wait := s.checkUpgradeInterval(); wait != "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				json.NewEncoder(w).Encode(RunResponse{
+					State:           string(jobs.JobStateFailed),
+					Mode:            string(mode),
+					RequestedTarget: req.RequestedTarget,
+					FailureCode:     "UPGRADE_TOO_SOON",
+					Message:         wait,
+				})
+				return
+			}
+		}
 
 		// First, do a read-only plan to validate
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
@@ -537,17 +692,12 @@ func (s *Server) HandleUpgradeRun() http.HandlerFunc {
 		// If caller did not supply currentVersion, resolve it from the running container.
 		currentVersion := req.CurrentVersion
 		if currentVersion == "" {
-			resolveCtx, resolveCancel := context.WithTimeout(r.Context(), time.Duration(s.config.FetchTimeoutSeconds)*time.Second)
-			defer resolveCancel()
-			if containerName, cnErr := s.discoverContainerName(resolveCtx); cnErr == nil {
-				initVersion := s.fetchPolicyInitVersion(resolveCtx)
-				if ver, _, verErr := s.resolveCoreVersion(resolveCtx, containerName, initVersion); verErr == nil {
-					currentVersion = ver
-				}
-			}
+			currentVersion = s.resolveCurrentVersion(r.Context())
 		}
 
 		plan := s.PlanUpgrade(ctx, mode, req.RequestedTarget, currentVersion)
+		s.applyImageRepoOverride(plan, req.ImageRepo)
+		s.checkDockerVersion(ctx, plan)
 		if plan.State == jobs.JobStateFailed {
 			// Planning failed - return error without creating a job
 			w.Header().Set("Content-Type", "application/json")
@@ -562,12 +712,29 @@ func (s *Server) HandleUpgradeRun() http.HandlerFunc {
 			return
 		}
 
+		// Re-running the same image only risks downtime, unless forced
+		if !req.Force && markAlreadyOnTarget(plan, currentVersion) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(RunResponse{
+				State:           string(plan.State),
+				Mode:            string(plan.Mode),
+				RequestedTarget: plan.RequestedTarget,
+				ResolvedTarget:  plan.ResolvedTarget,
+				Message:         plan.Message,
+				AlreadyOnTarget: true,
+			})
+			return
+		}
+
 		// Planning succeeded - create and execute job
 		jobID := fmt.Sprintf("job-%d", time.Now().UnixNano())
 		job := jobs.NewJob(jobID, mode, req.RequestedTarget)
 		job.ResolvedTarget = plan.ResolvedTarget
+		job.ImageRepoOverride = plan.ImageRepoOverride
+		job.SkipVerify = req.SkipVerify
 		job.State = jobs.JobStateReady
-		job.Message = "Upgrade job created"
+		job.Message = jobs.MessageJobCreated
 		job.UpdatedAt = time.Now().UTC()
 
 		// Save job
@@ -580,9 +747,12 @@ func (s *Server) HandleUpgradeRun() http.HandlerFunc {
 		// Log start with source
 		s.jobStore.AppendLog(fmt.Sprintf("Starting upgrade job %s: mode=%s target=%s (resolved: %s) source=%s",
 			jobID, mode, req.RequestedTarget, plan.ResolvedTarget, source))
+		for _, warning := range plan.Warnings {
+			s.addJobWarning(job, warning)
+		}
 
 		// Launch background execution goroutine
-		go s.executeUpgrade(job, plan.Manifest, plan.ArchSupport, plan.SteppingStone)
+		go s.runQueuedUpgrade(job, plan)
 		// Return response
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -1,7 +1,10 @@
 package http
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,10 +12,12 @@ import (
 	"time"
 
 	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/history"
 	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/recovery"
 )
 
-func TestHandleHealth(t *testing.T) {
+func TestHandleLivez(t *testing.T) {
 	tests := []struct {
 		name           string
 		method         string
@@ -41,10 +46,10 @@ func TestHandleHealth(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(tt.method, "/health", nil)
+			req := httptest.NewRequest(tt.method, "/livez", nil)
 			w := httptest.NewRecorder()
 
-			handler := HandleHealth()
+			handler := HandleLivez()
 			handler(w, req)
 
 			resp := w.Result()
@@ -279,6 +284,153 @@ func TestHandleUpgradeLogs_WithLogs(t *testing.T) {
 	}
 }
 
+func TestHandleUpgradeLogs_Follow(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	if err := jobStore.AppendLog("Starting update"); err != nil {
+		t.Fatalf("failed to append log: %v", err)
+	}
+	server := New(&config.Config{Port: 8080}, jobStore)
+	ts := httptest.NewServer(server.HandleUpgradeLogs())
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"?follow=true", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	readLine := func() string {
+		t.Helper()
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read streamed line: %v", err)
+		}
+		return strings.TrimSuffix(line, "\n")
+	}
+
+	if got := readLine(); got != "Starting update" {
+		t.Fatalf("expected the existing log first, got %q", got)
+	}
+	if err := jobStore.AppendLog("Pulling image"); err != nil {
+		t.Fatalf("failed to append log: %v", err)
+	}
+	if got := readLine(); got != "Pulling image" {
+		t.Errorf("expected the new line to be streamed, got %q", got)
+	}
+}
+
+func TestHandleUpgradeLogs_FollowEndsOnShutdown(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	if err := jobStore.AppendLog("Starting update"); err != nil {
+		t.Fatalf("failed to append log: %v", err)
+	}
+	server := New(&config.Config{Port: 8080}, jobStore)
+	ts := httptest.NewServer(server.HandleUpgradeLogs())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "?follow=true")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatalf("failed to read the existing log: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(reader)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the stream to end cleanly, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected shutdown to end the follow stream")
+	}
+}
+
+func TestHandleUpgradeLogs_After(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	jobStore.AppendLog("Starting update")
+	server := New(&config.Config{Port: 8080}, jobStore)
+	handler := server.HandleUpgradeLogs()
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/upgrade/logs", nil))
+	cursor, err := jobs.ParseLogCursor(w.Header().Get(jobs.LogCursorHeader))
+	if err != nil || cursor.File == 0 || cursor.Offset != 0 {
+		t.Fatalf("expected a cursor to the start of the log, got %q (%v)", w.Header().Get(jobs.LogCursorHeader), err)
+	}
+	jobStore.AppendLog("Pulling image")
+
+	after := cursor.Advance(w.Body.Len())
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/upgrade/logs?after="+after.String(), nil))
+	if w.Body.String() != "Pulling image\n" {
+		t.Errorf("expected only the lines after the cursor, got %q", w.Body.String())
+	}
+	if w.Header().Get(jobs.LogCursorHeader) != after.String() {
+		t.Errorf("expected the body to start at %s, got %s", after, w.Header().Get(jobs.LogCursorHeader))
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/upgrade/logs?after=bogus", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a bad cursor, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestHandleUpgradeLogs_FollowEndsAtRotation(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	jobStore.LogMaxBytes = 32
+	jobStore.AppendLog("Starting update")
+	server := New(&config.Config{Port: 8080}, jobStore)
+	ts := httptest.NewServer(server.HandleUpgradeLogs())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "?follow=true")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatalf("failed to read the existing log: %v", err)
+	}
+
+	// "Pulling image" still fits in the file; "Starting container" rotates it
+	jobStore.AppendLog("Pulling image")
+	jobStore.AppendLog("Starting container")
+
+	done := make(chan string, 1)
+	go func() {
+		rest, _ := io.ReadAll(reader)
+		done <- string(rest)
+	}()
+	select {
+	case rest := <-done:
+		if rest != "Pulling image\n" {
+			t.Errorf("expected the stream to finish the rotated file and end, got %q", rest)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected rotation to end the follow stream")
+	}
+}
+
 func TestHandleUpgradeLogs_MethodNotAllowed(t *testing.T) {
 	tmpDir := t.TempDir()
 	cfg := &config.Config{Port: 8080}
@@ -813,3 +965,109 @@ func TestHandleUpgradeRun_MissingTarget(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
 	}
 }
+
+func TestHandleHistory_JSONLines(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{Port: 8080, StateDir: tmpDir}
+	server := New(cfg, jobs.NewStore(tmpDir))
+	server.recordHistory(history.Event{Type: "upgrade", Status: "started", Message: "Upgrade started"})
+	server.recordHistory(history.Event{Type: "upgrade", Status: "succeeded", Message: "Upgrade completed"})
+
+	req := httptest.NewRequest(http.MethodGet, "/history?format=jsonl", nil)
+	w := httptest.NewRecorder()
+	server.HandleHistory()(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected Content-Type application/x-ndjson, got %q", ct)
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), w.Body.String())
+	}
+	for _, line := range lines {
+		var evt history.Event
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			t.Errorf("line is not a standalone JSON event: %q: %v", line, err)
+		}
+	}
+}
+
+func TestHandleHistory_InvalidFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	server := New(&config.Config{Port: 8080, StateDir: tmpDir}, jobs.NewStore(tmpDir))
+
+	for _, query := range []string{"?format=xml", "?format=jsonl&limit=5"} {
+		req := httptest.NewRequest(http.MethodGet, "/history"+query, nil)
+		w := httptest.NewRecorder()
+		server.HandleHistory()(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestHandleEnums_ListsEveryStateAndCode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/enums", nil)
+	w := httptest.NewRecorder()
+
+	HandleEnums()(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var result EnumsResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	states := make(map[jobs.JobState]bool)
+	for _, state := range result.JobStates {
+		states[state] = true
+	}
+	for _, state := range []jobs.JobState{
+		jobs.JobStateIdle, jobs.JobStatePolicyFetching, jobs.JobStateManifestFetching,
+		jobs.JobStateReady, jobs.JobStateBackingUp, jobs.JobStateExecuting,
+		jobs.JobStateVerifying, jobs.JobStateFailed, jobs.JobStateCancelled,
+	} {
+		if !states[state] {
+			t.Errorf("expected job state %s to be listed", state)
+		}
+	}
+
+	codes := make(map[string]FailureCodeInfo)
+	for _, info := range result.FailureCodes {
+		codes[info.Code] = info
+	}
+	for _, code := range recovery.AllCodes() {
+		info, ok := codes[code]
+		if !ok {
+			t.Errorf("expected failure code %s to be listed", code)
+			continue
+		}
+		if playbook := recovery.GetPlaybook(code); info.Severity != playbook.Severity || info.DataRisk != playbook.DataRisk {
+			t.Errorf("expected %s to be classified %s/%s, got %s/%s", code, playbook.Severity, playbook.DataRisk, info.Severity, info.DataRisk)
+		}
+	}
+	if len(result.FailureCodes) != len(recovery.AllCodes()) {
+		t.Errorf("expected %d failure codes, got %d", len(recovery.AllCodes()), len(result.FailureCodes))
+	}
+	if len(result.Severities) == 0 || len(result.DataRisks) == 0 {
+		t.Errorf("expected severity and data risk levels, got %v and %v", result.Severities, result.DataRisks)
+	}
+}
+
+func TestHandleEnums_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/enums", nil)
+	w := httptest.NewRecorder()
+
+	HandleEnums()(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
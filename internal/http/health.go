@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/policy"
+)
+
+// readinessTimeout bounds all readiness checks of one /health request, so a
+// slow policy host cannot hang a probe.
+const readinessTimeout = 5 * time.Second
+
+// HealthCheck is the result of one readiness check.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HandleLivez returns a handler for the /livez endpoint: it answers ok as
+// long as the daemon is serving requests.
+func HandleLivez() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		response := HealthResponse{Status: "ok"}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// HandleHealth returns a handler for the /health readiness endpoint. It
+// reports "degraded" with status 503 when the core container was not
+// discovered at startup, the policy or manifest cannot be fetched, or the
+// backup directory is not writable.
+func (s *Server) HandleHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		response := HealthResponse{Status: "ok", Checks: s.readinessChecks(ctx)}
+		status := http.StatusOK
+		for _, check := range response.Checks {
+			if !check.OK {
+				response.Status = "degraded"
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// readinessChecks runs every readiness check in a fixed order.
+func (s *Server) readinessChecks(ctx context.Context) []HealthCheck {
+	fetchTimeout := time.Duration(s.config.FetchTimeoutSeconds) * time.Second
+	return []HealthCheck{
+		newHealthCheck("core_discovery", s.discoveryErr),
+		newHealthCheck("policy", func() error {
+			_, err := policy.NewClient(fetchTimeout).Fetch(ctx, s.config.PolicyURL)
+			return err
+		}()),
+		newHealthCheck("manifest", func() error {
+			_, err := manifest.NewClient(fetchTimeout).Fetch(ctx, s.config.RuntimeManifestURL)
+			return err
+		}()),
+		newHealthCheck("backup_dir", func() error {
+			if err := backup.CheckMount(s.config.Backup.Dir, s.config.Backup.RequireMount); err != nil {
+				return err
+			}
+			return backup.EnsureDir(s.config.Backup.Dir)
+		}()),
+	}
+}
+
+func newHealthCheck(name string, err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{Name: name, OK: false, Detail: err.Error()}
+	}
+	return HealthCheck{Name: name, OK: true}
+}
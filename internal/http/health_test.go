@@ -0,0 +1,132 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newHealthTestServer returns a server whose readiness checks all pass.
+func newHealthTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, _, _ := newCancelTestServer(t, 0, "pull")
+	s.discoveryErr = nil
+	s.config.PolicyURL = buildPolicyFile(t, "1.1.0", []string{"1.0.0", "1.1.0"}, nil)
+	s.config.RuntimeManifestURL = buildManifestFile(t)
+	return s
+}
+
+func getHealth(t *testing.T, s *Server) (int, HealthResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.HandleHealth()(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var got HealthResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return w.Code, got
+}
+
+// failedCheck returns the named check if it failed.
+func failedCheck(resp HealthResponse, name string) (HealthCheck, bool) {
+	for _, check := range resp.Checks {
+		if check.Name == name && !check.OK {
+			return check, true
+		}
+	}
+	return HealthCheck{}, false
+}
+
+func TestHandleHealth_ReadyReturnsOK(t *testing.T) {
+	s := newHealthTestServer(t)
+
+	code, resp := getHealth(t, s)
+
+	if code != http.StatusOK || resp.Status != "ok" {
+		t.Fatalf("expected 200 ok, got %d %q (%+v)", code, resp.Status, resp.Checks)
+	}
+	if len(resp.Checks) != 4 {
+		t.Errorf("expected 4 checks, got %+v", resp.Checks)
+	}
+}
+
+func TestHandleHealth_DegradedConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		breakIt func(t *testing.T, s *Server)
+		check   string
+		detail  string
+	}{
+		{
+			name:    "core discovery failed",
+			breakIt: func(t *testing.T, s *Server) { s.discoveryErr = errors.New("no container matching payramapp/payram") },
+			check:   "core_discovery",
+			detail:  "no container matching",
+		},
+		{
+			name:    "policy unreachable",
+			breakIt: func(t *testing.T, s *Server) { s.config.PolicyURL = filepath.Join(t.TempDir(), "missing-policy.json") },
+			check:   "policy",
+			detail:  "missing-policy.json",
+		},
+		{
+			name: "manifest unreachable",
+			breakIt: func(t *testing.T, s *Server) {
+				s.config.RuntimeManifestURL = filepath.Join(t.TempDir(), "missing-manifest.json")
+			},
+			check:  "manifest",
+			detail: "missing-manifest.json",
+		},
+		{
+			name: "backup dir not a directory",
+			breakIt: func(t *testing.T, s *Server) {
+				path := filepath.Join(t.TempDir(), "backups")
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+				s.config.Backup.Dir = path
+			},
+			check:  "backup_dir",
+			detail: "not a directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newHealthTestServer(t)
+			tt.breakIt(t, s)
+
+			code, resp := getHealth(t, s)
+
+			if code != http.StatusServiceUnavailable || resp.Status != "degraded" {
+				t.Fatalf("expected 503 degraded, got %d %q", code, resp.Status)
+			}
+			check, failed := failedCheck(resp, tt.check)
+			if !failed {
+				t.Fatalf("expected check %s to fail, got %+v", tt.check, resp.Checks)
+			}
+			if !strings.Contains(check.Detail, tt.detail) {
+				t.Errorf("expected detail to mention %q, got %q", tt.detail, check.Detail)
+			}
+			for _, other := range resp.Checks {
+				if other.Name != tt.check && !other.OK {
+					t.Errorf("expected only %s to fail, %s failed too: %s", tt.check, other.Name, other.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHealth_RejectsNonGet(t *testing.T) {
+	s := newHealthTestServer(t)
+	w := httptest.NewRecorder()
+	s.HandleHealth()(w, httptest.NewRequest(http.MethodPost, "/health", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+// Hot swap is an experimental replacement of the container for upgrades that
+// change no database schema. The new container is created while the old one
+// keeps running, so only the stop and the start are downtime, and the old
+// container is kept under another name until the upgrade is verified.
+const (
+	hotSwapCandidateSuffix = "-next"
+	hotSwapPreviousSuffix  = "-previous"
+)
+
+// hotSwapAllowed reports whether the upgrade of job may use the hot swap:
+// only when HOT_SWAP_UPGRADES is on and the manifest override for exactly the
+// resolved target sets hot_swap.
+func (s *Server) hotSwapAllowed(job *jobs.Job, m *manifest.Manifest) bool {
+	if !s.config.HotSwapUpgrades {
+		return false
+	}
+	target := strings.TrimPrefix(strings.TrimSpace(job.ResolvedTarget), "v")
+	if m != nil {
+		for _, override := range m.Overrides {
+			if override.HotSwap && strings.TrimPrefix(strings.TrimSpace(override.Version), "v") == target {
+				s.jobStore.AppendLog(fmt.Sprintf("Hot swap: the manifest marks %s as hot-swappable", job.ResolvedTarget))
+				return true
+			}
+		}
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Hot swap: the manifest does not mark %s as hot-swappable; using the full stop/remove/run cycle", job.ResolvedTarget))
+	return false
+}
+
+// hotSwapCreateArgs turns the docker run args of BuildUpgradeArgs into docker
+// create args for a container called name. It returns nil for args it does
+// not recognise.
+func hotSwapCreateArgs(dockerArgs []string, containerName, name string) []string {
+	if len(dockerArgs) < 2 || dockerArgs[0] != "run" || dockerArgs[1] != "-d" {
+		return nil
+	}
+	args := append([]string{"create"}, dockerArgs[2:]...)
+	for i := 1; i < len(args)-1; i++ {
+		if args[i] == "--name" && args[i+1] == containerName {
+			args[i+1] = name
+			return args
+		}
+	}
+	return nil
+}
+
+// hotSwapRestartPolicy returns the --restart value of docker run args. The
+// builder preserves the running container's policy, so it is the old
+// container's too.
+func hotSwapRestartPolicy(dockerArgs []string) string {
+	for i := 0; i < len(dockerArgs)-1; i++ {
+		if dockerArgs[i] == "--restart" {
+			return dockerArgs[i+1]
+		}
+	}
+	return ""
+}
+
+// hotSwapContainer replaces containerName by creating the new container as
+// <name>-next, stopping the old one, renaming it to <name>-previous and
+// starting the new one under <name>. The old container's restart policy is
+// set to "no" so Docker does not start it alongside the new one. swapped is
+// false when the hot swap was abandoned before the old container was
+// touched; the caller then uses the full cycle. ok is false if the job
+// failed. A failure after the stop puts the old container back and restarts
+// it.
+func (s *Server) hotSwapContainer(ctx context.Context, job *jobs.Job, containerName string, dockerArgs []string) (swapped, ok bool) {
+	candidate := containerName + hotSwapCandidateSuffix
+	previous := containerName + hotSwapPreviousSuffix
+	policy := hotSwapRestartPolicy(dockerArgs)
+	fallBack := func(reason string) (bool, bool) {
+		s.jobStore.AppendLog(fmt.Sprintf("Hot swap: %s; falling back to the full stop/remove/run cycle", reason))
+		return false, true
+	}
+
+	createArgs := hotSwapCreateArgs(dockerArgs, containerName, candidate)
+	if createArgs == nil || policy == "" {
+		return fallBack("unrecognised docker run arguments")
+	}
+	for _, name := range []string{candidate, previous} {
+		exists, err := s.dockerRunner.Exists(ctx, name)
+		if err != nil {
+			return fallBack(fmt.Sprintf("could not check for container %s: %v", name, err))
+		}
+		if exists {
+			return fallBack(fmt.Sprintf("container %s already exists", name))
+		}
+	}
+
+	job.State = jobs.JobStateExecuting
+	job.Message = "Creating new container"
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("Hot swap: creating the new container as %s while %s keeps running", candidate, containerName))
+	if err := s.dockerRunner.Run(ctx, createArgs); err != nil {
+		if errors.Is(err, dockerexec.ErrNameConflict) {
+			// Another process took the name since the check; the container is not ours to remove
+			return fallBack(fmt.Sprintf("container %s was created by someone else", candidate))
+		}
+		if removeErr := s.dockerRunner.Remove(ctx, candidate); removeErr != nil {
+			s.addJobWarning(job, fmt.Sprintf("failed to remove container %s: %v", candidate, removeErr))
+		}
+		return fallBack(fmt.Sprintf("could not create the new container: %v", err))
+	}
+	s.holdProgramsForVerification(ctx, job, candidate)
+
+	if !s.stopContainerForUpgrade(ctx, job, containerName) {
+		if err := s.dockerRunner.Remove(ctx, candidate); err != nil {
+			s.addJobWarning(job, fmt.Sprintf("failed to remove container %s: %v", candidate, err))
+		}
+		return true, false
+	}
+	s.saveCheckpoint(job, jobs.CheckpointStopped)
+
+	if err := s.dockerRunner.Rename(ctx, containerName, previous); err != nil {
+		s.failHotSwap(ctx, job, containerName, containerName, candidate, policy, fmt.Sprintf("Failed to rename the old container: %v", err))
+		return true, false
+	}
+	if err := s.dockerRunner.Run(ctx, []string{"update", "--restart", "no", previous}); err != nil {
+		s.failHotSwap(ctx, job, containerName, previous, candidate, policy, fmt.Sprintf("Failed to disable the restart policy of the old container: %v", err))
+		return true, false
+	}
+	if err := s.dockerRunner.Rename(ctx, candidate, containerName); err != nil {
+		s.failHotSwap(ctx, job, containerName, previous, candidate, policy, fmt.Sprintf("Failed to rename the new container: %v", err))
+		return true, false
+	}
+
+	job.Message = "Starting new container"
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("Hot swap: starting the new container; the old one is kept as %s", previous))
+	if err := s.dockerRunner.Start(ctx, containerName); err != nil {
+		s.failHotSwap(ctx, job, containerName, previous, containerName, policy, fmt.Sprintf("Failed to start container: %v", err))
+		return true, false
+	}
+	s.jobStore.AppendLog("Container started successfully")
+
+	if !s.checkReplacedContainer(ctx, job, containerName, dockerArgs) {
+		s.logHotSwapRollback(containerName, policy)
+		return true, false
+	}
+	return true, true
+}
+
+// failHotSwap restores the old container after a hot swap failed midway and
+// marks the job failed with message. previous is the old container's current
+// name, discard the new container, removed first, and policy the old
+// container's restart policy.
+func (s *Server) failHotSwap(ctx context.Context, job *jobs.Job, containerName, previous, discard, policy, message string) {
+	ctx = context.WithoutCancel(ctx)
+	err := s.dockerRunner.Remove(ctx, discard)
+	if err == nil && previous != containerName {
+		err = s.dockerRunner.Rename(ctx, previous, containerName)
+		if err == nil {
+			err = s.dockerRunner.Run(ctx, []string{"update", "--restart", policy, containerName})
+		}
+	}
+	if err == nil {
+		err = s.dockerRunner.Start(ctx, containerName)
+	}
+
+	job.State = jobs.JobStateFailed
+	job.FailureCode = "DOCKER_ERROR"
+	if err == nil {
+		job.Message = message + "; the previous container was restarted"
+	} else {
+		job.Message = fmt.Sprintf("%s; restoring the previous container also failed: %v", message, err)
+	}
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+	if err != nil && previous != containerName {
+		s.logHotSwapRollback(containerName, policy)
+	}
+}
+
+// logHotSwapRollback tells the operator how to go back to the container kept
+// by the hot swap.
+func (s *Server) logHotSwapRollback(containerName, policy string) {
+	previous := containerName + hotSwapPreviousSuffix
+	s.jobStore.AppendLog(fmt.Sprintf("The previous container is kept as %s. To switch back to it: docker rm -f %s && docker rename %s %s && docker update --restart %s %s && docker start %s",
+		previous, containerName, previous, containerName, policy, containerName, containerName))
+}
+
+// restoreHotSwapPrevious renames the old container of a hot swap that was
+// interrupted between its renames back to containerName, when no container
+// has that name and <name>-previous exists, and restores its restart policy.
+// It reports whether it renamed the container.
+func (s *Server) restoreHotSwapPrevious(ctx context.Context, containerName, policy string) (bool, error) {
+	previous := containerName + hotSwapPreviousSuffix
+	if exists, err := s.dockerRunner.Exists(ctx, containerName); err != nil || exists {
+		return false, err
+	}
+	if exists, err := s.dockerRunner.Exists(ctx, previous); err != nil || !exists {
+		return false, err
+	}
+	if err := s.dockerRunner.Rename(ctx, previous, containerName); err != nil {
+		return false, err
+	}
+	if policy != "" {
+		if err := s.dockerRunner.Run(ctx, []string{"update", "--restart", policy, containerName}); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// removeHotSwapPrevious removes the old container once the hot-swapped
+// upgrade has been verified. A failure is only a warning.
+func (s *Server) removeHotSwapPrevious(ctx context.Context, job *jobs.Job, containerName string) {
+	previous := containerName + hotSwapPreviousSuffix
+	if err := s.dockerRunner.Remove(ctx, previous); err != nil {
+		s.addJobWarning(job, fmt.Sprintf("failed to remove the previous container %s: %v", previous, err))
+		return
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Removed the previous container %s", previous))
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+// hotSwapTestScript records every docker call, fails the first call of the
+// fail subcommand, reports the hot-swap container names as absent and answers inspect with a
+// running container matching driftTestArgs.
+func hotSwapTestScript(fail string) string {
+	inspectJSON := strings.Replace(cancelTestInspect, `"Mounts":[]`,
+		`"Mounts":[{"Type":"bind","Source":"/srv/payram","Destination":"/root/payram","Mode":"","RW":true}]`, 1)
+	return "#!/bin/sh\n" +
+		"echo \"$*\" >> \"$(dirname \"$0\")/calls\"\n" +
+		"if [ \"$1\" = " + fail + " ] && [ ! -e \"$(dirname \"$0\")/failed\" ]; then\n" +
+		"  touch \"$(dirname \"$0\")/failed\"; echo 'Error response from daemon: boom' >&2; exit 1\n" +
+		"fi\n" +
+		"if [ \"$1\" = inspect ] && [ \"$3\" = '{{.Name}}' ]; then echo \"Error: No such object: $4\" >&2; exit 1; fi\n" +
+		"if [ \"$1\" = inspect ] && [ \"$2\" = -f ]; then echo true; exit 0; fi\n" +
+		"if [ \"$1\" = inspect ]; then echo '" + inspectJSON + "'; fi\n"
+}
+
+// newHotSwapTestServer returns a finalize test server running
+// hotSwapTestScript(fail), with the calls made during setup discarded.
+func newHotSwapTestServer(t *testing.T, fail string) (*Server, *jobs.Store) {
+	t.Helper()
+	server, jobStore := newFinalizeTestServer(t, hotSwapTestScript(fail))
+	os.Remove(filepath.Join(filepath.Dir(server.config.DockerBin), "calls"))
+	return server, jobStore
+}
+
+func dockerCalls(t *testing.T, server *Server) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(server.config.DockerBin), "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestHotSwapAllowed(t *testing.T) {
+	m := &manifest.Manifest{Overrides: []manifest.Override{
+		{Version: "1.2.0", HotSwap: true},
+		{Version: "1.3.0"},
+	}}
+	tests := []struct {
+		name     string
+		enabled  bool
+		target   string
+		manifest *manifest.Manifest
+		want     bool
+	}{
+		{"marked and enabled", true, "1.2.0", m, true},
+		{"v prefix", true, "v1.2.0", m, true},
+		{"not opted in", false, "1.2.0", m, false},
+		{"override without hot_swap", true, "1.3.0", m, false},
+		{"no override", true, "1.4.0", m, false},
+		{"no manifest", true, "1.2.0", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newHotSwapTestServer(t, "none")
+			server.config.HotSwapUpgrades = tt.enabled
+			job := jobs.NewJob("job-1", jobs.JobModeManual, tt.target)
+			job.ResolvedTarget = tt.target
+
+			if got := server.hotSwapAllowed(job, tt.manifest); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHotSwapCreateArgs(t *testing.T) {
+	got := hotSwapCreateArgs(driftTestArgs(), "payram", "payram-next")
+	want := []string{"create", "--name", "payram-next", "--restart", "always",
+		"-v", "/srv/payram:/root/payram", "-e", "POSTGRES_HOST=localhost", "payramapp/payram:1.2.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if args := driftTestArgs(); args[3] != "payram" {
+		t.Errorf("expected the run args to be left unchanged, got %v", args)
+	}
+
+	for _, args := range [][]string{
+		{"create", "--name", "payram", "payramapp/payram:1.2.0"},
+		{"run", "-d", "--name", "other", "payramapp/payram:1.2.0"},
+		{"run", "-d", "payramapp/payram:1.2.0"},
+	} {
+		if got := hotSwapCreateArgs(args, "payram", "payram-next"); got != nil {
+			t.Errorf("expected %v to be rejected, got %v", args, got)
+		}
+	}
+}
+
+func TestHotSwapContainer_SwapsByRename(t *testing.T) {
+	server, jobStore := newHotSwapTestServer(t, "none")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	swapped, ok := server.hotSwapContainer(context.Background(), job, "payram", driftTestArgs())
+	if !swapped || !ok {
+		t.Fatalf("expected the hot swap to succeed, got swapped=%v ok=%v (%s: %s)", swapped, ok, job.FailureCode, job.Message)
+	}
+
+	want := []string{
+		"inspect -f {{.Name}} payram-next",
+		"inspect -f {{.Name}} payram-previous",
+		"create --name payram-next --restart always -v /srv/payram:/root/payram -e POSTGRES_HOST=localhost payramapp/payram:1.2.0",
+		"stop payram",
+		"rename payram payram-previous",
+		"update --restart no payram-previous",
+		"rename payram-next payram",
+		"start payram",
+	}
+	calls := dockerCalls(t, server)
+	if len(calls) < len(want) || !reflect.DeepEqual(calls[:len(want)], want) {
+		t.Fatalf("expected the calls to start with\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(calls, "\n"))
+	}
+	for _, call := range calls {
+		if strings.HasPrefix(call, "rm") {
+			t.Errorf("expected the previous container to be kept until verification, got %q", call)
+		}
+	}
+	if job.Checkpoint != jobs.CheckpointStopped {
+		t.Errorf("expected checkpoint %s, got %s", jobs.CheckpointStopped, job.Checkpoint)
+	}
+
+	server.removeHotSwapPrevious(context.Background(), job, "payram")
+	calls = dockerCalls(t, server)
+	if last := calls[len(calls)-1]; last != "rm -f payram-previous" {
+		t.Errorf("expected the previous container to be removed after verification, got %q", last)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Removed the previous container payram-previous") {
+		t.Errorf("expected the removal to be logged, got:\n%s", logs)
+	}
+}
+
+func TestHotSwapContainer_FallsBackWhenCreateFails(t *testing.T) {
+	server, jobStore := newHotSwapTestServer(t, "create")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	swapped, ok := server.hotSwapContainer(context.Background(), job, "payram", driftTestArgs())
+	if swapped || !ok {
+		t.Fatalf("expected a fallback to the full cycle, got swapped=%v ok=%v", swapped, ok)
+	}
+	for _, call := range dockerCalls(t, server) {
+		if strings.HasPrefix(call, "stop") || strings.HasPrefix(call, "rename") {
+			t.Errorf("expected the running container to be untouched, got %q", call)
+		}
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "falling back to the full stop/remove/run cycle") {
+		t.Errorf("expected the fallback to be logged, got:\n%s", logs)
+	}
+}
+
+func TestHotSwapContainer_CreateNameConflictKeepsOtherContainer(t *testing.T) {
+	script := strings.Replace(hotSwapTestScript("create"), "boom",
+		`Conflict. The container name \"/payram-next\" is already in use by container \"abc123\"`, 1)
+	server, _ := newFinalizeTestServer(t, script)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	swapped, ok := server.hotSwapContainer(context.Background(), job, "payram", driftTestArgs())
+	if swapped || !ok {
+		t.Fatalf("expected a fallback to the full cycle, got swapped=%v ok=%v", swapped, ok)
+	}
+	for _, call := range dockerCalls(t, server) {
+		if strings.HasPrefix(call, "rm") {
+			t.Errorf("expected the container holding the name to be left alone, got %q", call)
+		}
+	}
+}
+
+func TestHotSwapContainer_FallsBackWhenArgsUnrecognised(t *testing.T) {
+	server, _ := newHotSwapTestServer(t, "none")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	swapped, ok := server.hotSwapContainer(context.Background(), job, "other", driftTestArgs())
+	if swapped || !ok {
+		t.Fatalf("expected a fallback to the full cycle, got swapped=%v ok=%v", swapped, ok)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(server.config.DockerBin), "calls")); !os.IsNotExist(err) {
+		t.Errorf("expected no docker calls, got %v", dockerCalls(t, server))
+	}
+}
+
+func TestHotSwapContainer_StartFailureRestoresPrevious(t *testing.T) {
+	server, _ := newHotSwapTestServer(t, "start")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	swapped, ok := server.hotSwapContainer(context.Background(), job, "payram", driftTestArgs())
+	if !swapped || ok {
+		t.Fatalf("expected the hot swap to fail after the swap, got swapped=%v ok=%v", swapped, ok)
+	}
+	if job.State != jobs.JobStateFailed || job.FailureCode != "DOCKER_ERROR" {
+		t.Fatalf("expected FAILED/DOCKER_ERROR, got %s/%s", job.State, job.FailureCode)
+	}
+	if !strings.HasSuffix(job.Message, "the previous container was restarted") {
+		t.Errorf("expected the message to report the restore, got %q", job.Message)
+	}
+
+	calls := dockerCalls(t, server)
+	want := []string{
+		"start payram",
+		"rm -f payram",
+		"rename payram-previous payram",
+		"update --restart always payram",
+		"start payram",
+	}
+	if got := calls[len(calls)-len(want):]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the restore calls\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(calls, "\n"))
+	}
+}
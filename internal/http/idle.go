@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
+)
+
+// trackActivity records the time of every API request so the idle watcher
+// does not shut the daemon down while a client is still using it.
+func (s *Server) trackActivity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.markActivity()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) markActivity() {
+	s.lastActivity.Store(s.clock().UnixNano())
+}
+
+// clock returns the current time, using the injected clock in tests.
+func (s *Server) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// isIdle reports whether the daemon has gone IdleTimeoutSeconds without an
+// API request or job activity and no job is in progress.
+func (s *Server) isIdle() bool {
+	timeout := time.Duration(s.config.IdleTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		return false
+	}
+
+	last := time.Unix(0, s.lastActivity.Load())
+	if job, err := s.jobStore.LoadLatest(); err == nil && job != nil {
+		switch job.State {
+		case jobs.JobStateIdle, jobs.JobStateReady, jobs.JobStateFailed, jobs.JobStateCancelled:
+			// A job that just finished counts as activity
+			if job.UpdatedAt.After(last) {
+				last = job.UpdatedAt
+			}
+		default:
+			return false
+		}
+	}
+
+	return s.clock().Sub(last) >= timeout
+}
+
+// watchIdle closes idle once the daemon has been idle for the configured
+// timeout. It checks every interval until ctx is cancelled.
+func (s *Server) watchIdle(ctx context.Context, interval time.Duration, idle chan<- struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.isIdle() {
+				logger.Warnf("Server", "watchIdle", "No activity for %d seconds, shutting down", s.config.IdleTimeoutSeconds)
+				close(idle)
+				return
+			}
+		}
+	}
+}
+
+// idleCheckInterval picks how often to check for idleness: often enough to
+// exit close to the deadline, but no more than once a second.
+func idleCheckInterval(timeoutSeconds int) time.Duration {
+	interval := time.Duration(timeoutSeconds) * time.Second / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
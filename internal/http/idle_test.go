@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+// fakeClock is an injectable clock for idle-timeout tests.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newIdleTestServer(t *testing.T, timeoutSeconds int) (*Server, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := &Server{
+		config:   &config.Config{IdleTimeoutSeconds: timeoutSeconds},
+		jobStore: jobs.NewStore(t.TempDir()),
+		now:      clock.Now,
+	}
+	s.markActivity()
+	return s, clock
+}
+
+func TestIsIdle_ExpiresAfterTimeout(t *testing.T) {
+	s, clock := newIdleTestServer(t, 60)
+
+	clock.Advance(59 * time.Second)
+	if s.isIdle() {
+		t.Error("expected not idle before the timeout")
+	}
+
+	clock.Advance(time.Second)
+	if !s.isIdle() {
+		t.Error("expected idle once the timeout has elapsed")
+	}
+}
+
+func TestIsIdle_APIActivityResetsTimer(t *testing.T) {
+	s, clock := newIdleTestServer(t, 60)
+	handler := s.trackActivity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	clock.Advance(50 * time.Second)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/upgrade/status", nil))
+
+	clock.Advance(50 * time.Second)
+	if s.isIdle() {
+		t.Error("expected API request to reset the idle timer")
+	}
+
+	clock.Advance(10 * time.Second)
+	if !s.isIdle() {
+		t.Error("expected idle 60s after the last request")
+	}
+}
+
+func TestIsIdle_NotWhileJobActive(t *testing.T) {
+	s, clock := newIdleTestServer(t, 60)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+	job.State = jobs.JobStateExecuting
+	job.UpdatedAt = clock.Now()
+	if err := s.jobStore.Save(job); err != nil {
+		t.Fatalf("save job: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if s.isIdle() {
+		t.Error("expected daemon not to be idle while a job is active")
+	}
+
+	// Once the job finishes, the idle timer starts from its completion
+	job.State = jobs.JobStateReady
+	job.UpdatedAt = clock.Now()
+	if err := s.jobStore.Save(job); err != nil {
+		t.Fatalf("save job: %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	if s.isIdle() {
+		t.Error("expected a just-finished job to count as activity")
+	}
+	clock.Advance(30 * time.Second)
+	if !s.isIdle() {
+		t.Error("expected idle 60s after the job finished")
+	}
+}
+
+func TestIsIdle_DisabledByDefault(t *testing.T) {
+	s, clock := newIdleTestServer(t, 0)
+
+	clock.Advance(24 * time.Hour)
+	if s.isIdle() {
+		t.Error("expected idle timeout to be disabled when IdleTimeoutSeconds is 0")
+	}
+}
+
+func TestWatchIdle_SignalsShutdown(t *testing.T) {
+	s, clock := newIdleTestServer(t, 60)
+	clock.Advance(2 * time.Minute)
+
+	idle := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.watchIdle(ctx, time.Millisecond, idle)
+
+	select {
+	case <-idle:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected idle watcher to signal shutdown")
+	}
+}
+
+func TestWatchIdle_NoShutdownWhileJobActive(t *testing.T) {
+	s, clock := newIdleTestServer(t, 60)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+	job.State = jobs.JobStateBackingUp
+	if err := s.jobStore.Save(job); err != nil {
+		t.Fatalf("save job: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	idle := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.watchIdle(ctx, time.Millisecond, idle)
+
+	select {
+	case <-idle:
+		t.Error("expected no shutdown while a job is active")
+	default:
+	}
+}
+
+func TestIdleCheckInterval(t *testing.T) {
+	if got := idleCheckInterval(5); got != time.Second {
+		t.Errorf("expected 1s minimum interval, got %v", got)
+	}
+	if got := idleCheckInterval(600); got != time.Minute {
+		t.Errorf("expected a tenth of the timeout, got %v", got)
+	}
+}
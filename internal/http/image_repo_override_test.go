@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+func TestValidateImageRepo(t *testing.T) {
+	for _, repo := range []string{"payramapp/payram", "ghcr.io/acme/payram", "registry.local:5000/team/payram-fork", "payram"} {
+		if err := validateImageRepo(repo); err != nil {
+			t.Errorf("expected %q to be valid, got %v", repo, err)
+		}
+	}
+	for _, repo := range []string{"acme/payram:1.1.0", "acme/payram@sha256:abc", "Acme/Payram", "acme payram", "acme/payram/", "-e FOO=bar"} {
+		if err := validateImageRepo(repo); err == nil {
+			t.Errorf("expected %q to be rejected", repo)
+		}
+	}
+}
+
+func TestRunUpgradeSync_ImageRepoOverrideReachesDockerArgs(t *testing.T) {
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+	s.config.AllowedImageRepos = []string{"payramapp/payram", "ghcr.io/acme/payram"}
+
+	_, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "ghcr.io/acme/payram", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if job.State != jobs.JobStateReady {
+		t.Fatalf("expected a READY job, got %s: %s", job.State, job.Message)
+	}
+	if !strings.HasSuffix(job.RunCommand, " ghcr.io/acme/payram:1.1.0") {
+		t.Errorf("expected the docker args to use the override repo, got %s", job.RunCommand)
+	}
+	if job.ImageRepoOverride != "ghcr.io/acme/payram" {
+		t.Errorf("expected the override on the job, got %q", job.ImageRepoOverride)
+	}
+
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Image repo overridden for this upgrade") {
+		t.Errorf("expected the override to be logged, got:\n%s", logs)
+	}
+	events, err := s.historyStore.List(0, "upgrade", "")
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected started and final upgrade events, got %d (err=%v)", len(events), err)
+	}
+	for _, event := range events {
+		if event.Data["imageRepoOverride"] != "ghcr.io/acme/payram" {
+			t.Errorf("expected the override in the %s event, got %+v", event.Status, event.Data)
+		}
+	}
+}
+
+func TestRunUpgradeSync_ImageRepoOverrideMustBeAllowed(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+	s.config.AllowedImageRepos = []string{"payramapp/payram"}
+
+	plan, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "ghcr.io/acme/payram", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if plan.FailureCode != "IMAGE_REPO_NOT_ALLOWED" || job != nil {
+		t.Errorf("expected IMAGE_REPO_NOT_ALLOWED and no job, got %s/%+v", plan.FailureCode, job)
+	}
+}
+
+func TestRunUpgradeSync_ImageRepoOverrideNeedsAllowlist(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+
+	plan, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "ghcr.io/acme/payram", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if plan.FailureCode != "IMAGE_REPO_NOT_ALLOWED" || job != nil {
+		t.Errorf("expected IMAGE_REPO_NOT_ALLOWED without ALLOWED_IMAGE_REPOS, got %s/%+v", plan.FailureCode, job)
+	}
+}
+
+// loopbackRequest returns a request to path from the CLI's loopback address.
+func loopbackRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:50000"
+	return req
+}
+
+func TestHandleUpgradePlan_ImageRepoOverride(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+	s.config.AllowedImageRepos = []string{"payramapp/payram", "ghcr.io/acme/payram"}
+
+	w := httptest.NewRecorder()
+	s.HandleUpgradePlan()(w, loopbackRequest(http.MethodPost, "/upgrade/plan", `{"requestedTarget":"1.1.0","currentVersion":"1.0.0","source":"CLI","printRunCommand":true,"imageRepo":"ghcr.io/acme/payram"}`))
+
+	var resp PlanResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ImageRepo != "ghcr.io/acme/payram" || !resp.ImageRepoOverride {
+		t.Errorf("expected the override repo in the plan, got %q (override=%v)", resp.ImageRepo, resp.ImageRepoOverride)
+	}
+	if !strings.HasSuffix(resp.RunCommand, " ghcr.io/acme/payram:1.1.0") {
+		t.Errorf("expected the run command to use the override repo, got %s", resp.RunCommand)
+	}
+}
+
+func TestHandleUpgradeRun_RejectsImageRepoWithTag(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+
+	w := httptest.NewRecorder()
+	s.HandleUpgradeRun()(w, loopbackRequest(http.MethodPost, "/upgrade/run", `{"requestedTarget":"1.1.0","source":"CLI","imageRepo":"ghcr.io/acme/payram:1.1.0"}`))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an image repo with a tag, got %d", w.Code)
+	}
+}
+
+func TestImageRepoOverride_OnlyFromLoopbackCLI(t *testing.T) {
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+	s.config.AllowedImageRepos = []string{"payramapp/payram", "ghcr.io/acme/payram"}
+
+	tests := []struct {
+		name   string
+		remote string
+		source string
+	}{
+		{"container on the docker bridge", "172.17.0.2:41000", "CLI"},
+		{"loopback dashboard", "127.0.0.1:41000", "DASHBOARD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/upgrade/plan", "/upgrade/run"} {
+				req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"requestedTarget":"1.1.0","currentVersion":"1.0.0","source":"`+tt.source+`","imageRepo":"ghcr.io/acme/payram"}`))
+				req.RemoteAddr = tt.remote
+				w := httptest.NewRecorder()
+				if path == "/upgrade/plan" {
+					s.HandleUpgradePlan()(w, req)
+				} else {
+					s.HandleUpgradeRun()(w, req)
+				}
+				if w.Code != http.StatusForbidden {
+					t.Errorf("%s: expected 403, got %d", path, w.Code)
+				}
+			}
+		})
+	}
+	if job, _ := jobStore.LoadLatest(); job != nil {
+		t.Errorf("expected no job, got %+v", job)
+	}
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
+	"github.com/payram/payram-updater/internal/rollback"
+)
+
+// interruptedRecoveryTimeout bounds bringing the container back after an
+// interrupted upgrade.
+const interruptedRecoveryTimeout = 2 * time.Minute
+
+// recoverInterruptedUpgrade fails a job that an updater process left active
+// when it died mid-upgrade with UPGRADE_INTERRUPTED, first bringing back the
+// container if the upgrade had stopped or removed it. The caller must hold
+// the state lock, so no live process can still own the job.
+//
+// With RESUME_INTERRUPTED_UPGRADES set, a job interrupted at a resumable
+// checkpoint is left active instead, and the job and the plan to resume it
+// with are returned for the caller to pass to executeUpgrade.
+func (s *Server) recoverInterruptedUpgrade(ctx context.Context) (*jobs.Job, *UpgradePlan) {
+	job, err := s.jobStore.LoadLatest()
+	if err != nil {
+		logger.Error("Server", "recoverInterruptedUpgrade", err)
+		return nil, nil
+	}
+	if job == nil || !isJobActive(job) {
+		return nil, nil
+	}
+
+	s.jobStore.AppendLog(fmt.Sprintf("Job %s was left in state %s by an updater that stopped mid-upgrade", job.JobID, job.State))
+	if plan := s.planResume(ctx, job); plan != nil {
+		return job, plan
+	}
+	ctx, cancel := context.WithTimeout(ctx, interruptedRecoveryTimeout)
+	defer cancel()
+	outcome := s.restoreInterruptedContainer(ctx, job)
+
+	job.State = jobs.JobStateFailed
+	job.FailureCode = "UPGRADE_INTERRUPTED"
+	job.Message = "The updater stopped during the upgrade; " + outcome
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+	s.recordHistory(history.Event{
+		Type:    "upgrade",
+		Status:  "failed",
+		Message: job.Message,
+		Data: map[string]string{
+			"jobId":          job.JobID,
+			"mode":           string(job.Mode),
+			"resolvedTarget": job.ResolvedTarget,
+			"failureCode":    job.FailureCode,
+		},
+	})
+	return nil, nil
+}
+
+// planResume re-plans an interrupted job that may be resumed: resuming is
+// enabled, the job's checkpoint is before the container was stopped, and the
+// plan still resolves to the job's target. It returns nil, logging why, when
+// the job must be failed instead.
+func (s *Server) planResume(ctx context.Context, job *jobs.Job) *UpgradePlan {
+	if !s.config.ResumeInterruptedUpgrades || !job.Checkpoint.Resumable() {
+		return nil
+	}
+
+	planCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	plan := s.PlanUpgrade(planCtx, job.Mode, job.RequestedTarget, s.resolveCurrentVersion(planCtx))
+	s.applyImageRepoOverride(plan, job.ImageRepoOverride)
+	s.checkDockerVersion(planCtx, plan)
+	if plan.State == jobs.JobStateFailed {
+		s.jobStore.AppendLog(fmt.Sprintf("Cannot resume job %s: planning failed (%s: %s)", job.JobID, plan.FailureCode, plan.Message))
+		return nil
+	}
+	if plan.ResolvedTarget != job.ResolvedTarget {
+		s.jobStore.AppendLog(fmt.Sprintf("Cannot resume job %s: its target now resolves to %s instead of %s", job.JobID, plan.ResolvedTarget, job.ResolvedTarget))
+		return nil
+	}
+
+	s.jobStore.AppendLog(fmt.Sprintf("Resuming job %s from checkpoint %s: pulling the image again, then continuing", job.JobID, job.Checkpoint))
+	return plan
+}
+
+// restoreInterruptedContainer makes sure the container of an interrupted
+// upgrade exists and is running: a container a hot swap left renamed to
+// <name>-previous is renamed back, a stopped container is started, and a
+// removed one is recreated from the job's pre-upgrade snapshot. It returns
+// what it did, for the job message.
+func (s *Server) restoreInterruptedContainer(ctx context.Context, job *jobs.Job) string {
+	if s.lastGoodStore == nil {
+		return "the container was left as is"
+	}
+	snapshot, err := s.lastGoodStore.LoadSnapshot()
+	if err != nil {
+		return fmt.Sprintf("the pre-upgrade snapshot could not be read (%v), so the container was left as is", err)
+	}
+	if snapshot == nil || snapshot.JobID != job.JobID {
+		// The job died before the snapshot was saved, so before anything stopped the container
+		return "it had not stopped the container yet, so the container was left as is"
+	}
+
+	name := snapshot.ContainerName
+	var policy string
+	if snapshot.RuntimeState != nil {
+		policy = snapshot.RuntimeState.RestartPolicy.Name
+	}
+	renamed, err := s.restoreHotSwapPrevious(ctx, name, policy)
+	if err != nil {
+		return fmt.Sprintf("the hot swap's previous container %s could not be renamed back to %s: %v", name+hotSwapPreviousSuffix, name, err)
+	}
+	if renamed {
+		s.jobStore.AppendLog(fmt.Sprintf("Renamed the previous container %s back to %s", name+hotSwapPreviousSuffix, name))
+	}
+	err = s.dockerRunner.Start(ctx, name)
+	if err == nil {
+		if renamed {
+			return fmt.Sprintf("the hot swap was interrupted between its renames; the previous container was renamed back to %s and is running", name)
+		}
+		return fmt.Sprintf("container %s is running; check its version before retrying", name)
+	}
+	if !errors.Is(err, dockerexec.ErrContainerNotFound) {
+		return fmt.Sprintf("container %s could not be started: %v", name, err)
+	}
+
+	state := snapshot.RuntimeState
+	s.jobStore.AppendLog(fmt.Sprintf("Container %s is missing; recreating it from the pre-upgrade snapshot (%s)", name, state.Image))
+	dockerArgs, err := rollback.RecreateArgs(state, state.ImageTag, logger.StdLogger())
+	if err != nil {
+		return fmt.Sprintf("container %s is missing and its docker run args could not be rebuilt: %v", name, err)
+	}
+	if err := s.dockerRunner.Run(ctx, dockerArgs); err != nil {
+		return fmt.Sprintf("container %s is missing and could not be recreated: %v", name, err)
+	}
+	return fmt.Sprintf("container %s was missing and has been recreated from the pre-upgrade snapshot running %s", name, state.Image)
+}
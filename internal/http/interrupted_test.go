@@ -0,0 +1,270 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/coreclient"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/rollback"
+)
+
+func TestExecuteUpgrade_SavesSnapshotBeforeStop(t *testing.T) {
+	s, jobStore, callLog := newCancelTestServer(t, 0, "pull")
+	job, done := startCancelTestJob(t, s, jobStore)
+
+	// The image pull comes after the snapshot and before the stop
+	waitForPull(t, callLog)
+	snapshot, err := s.lastGoodStore.LoadSnapshot()
+	if err != nil || snapshot == nil {
+		t.Fatalf("expected a snapshot before the container is stopped, got %+v (err=%v)", snapshot, err)
+	}
+	if snapshot.JobID != job.JobID || snapshot.ContainerName != "payram" || snapshot.RuntimeState.ImageTag != "1.0.0" {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+
+	if !s.CancelUpgrade(errUpgradeCancelledByOperator) {
+		t.Fatal("expected an upgrade to cancel")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upgrade did not stop after cancellation")
+	}
+	assertNoDestructiveDockerCalls(t, callLog)
+}
+
+// interruptedTestServer returns a server with an EXECUTING job left behind
+// by a dead updater, and a snapshot for it if withSnapshot. Its docker stub
+// logs every call and answers start with startOutput, failing if it is set.
+func interruptedTestServer(t *testing.T, startOutput string, withSnapshot bool) (*Server, *jobs.Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	callLog := filepath.Join(dir, "docker-calls.log")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callLog + "\n"
+	if startOutput != "" {
+		script += "[ \"$1\" = start ] && { echo '" + startOutput + "' >&2; exit 1; }\n"
+	}
+	script += "exit 0\n"
+	server, jobStore := newFinalizeTestServer(t, script)
+	os.Remove(callLog) // drop the container discovery done by New
+
+	job := jobs.NewJob("job-interrupted", jobs.JobModeManual, "1.2.0")
+	job.State = jobs.JobStateExecuting
+	jobStore.Save(job)
+	if withSnapshot {
+		err := server.lastGoodStore.SaveSnapshot(&rollback.Snapshot{
+			JobID:         job.JobID,
+			ContainerName: "payram",
+			RuntimeState: &container.RuntimeState{
+				Name:          "payram",
+				Image:         "payramapp/payram:1.1.0",
+				ImageTag:      "1.1.0",
+				Mounts:        []container.Mount{{Type: "bind", Source: "/srv/payram", Destination: "/root/payram", RW: true}},
+				RestartPolicy: container.RestartPolicy{Name: "always"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return server, jobStore, callLog
+}
+
+func readCalls(t *testing.T, callLog string) string {
+	t.Helper()
+	data, err := os.ReadFile(callLog)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRecoverInterruptedUpgrade_RecreatesMissingContainer(t *testing.T) {
+	s, jobStore, callLog := interruptedTestServer(t, "Error response from daemon: No such container: payram", true)
+
+	s.recoverInterruptedUpgrade(context.Background())
+
+	calls := readCalls(t, callLog)
+	if !strings.Contains(calls, "run -d --name payram --restart always -v /srv/payram:/root/payram payramapp/payram:1.1.0") {
+		t.Errorf("expected the container to be recreated from the snapshot, got calls:\n%s", calls)
+	}
+	job, _ := jobStore.LoadLatest()
+	if job.State != jobs.JobStateFailed || job.FailureCode != "UPGRADE_INTERRUPTED" {
+		t.Fatalf("expected FAILED/UPGRADE_INTERRUPTED, got %s/%s", job.State, job.FailureCode)
+	}
+	if !strings.Contains(job.Message, "recreated from the pre-upgrade snapshot") {
+		t.Errorf("expected the message to say the container was recreated, got %q", job.Message)
+	}
+}
+
+func TestRecoverInterruptedUpgrade_StartsStoppedContainer(t *testing.T) {
+	s, jobStore, callLog := interruptedTestServer(t, "", true)
+
+	s.recoverInterruptedUpgrade(context.Background())
+
+	calls := readCalls(t, callLog)
+	if !strings.Contains(calls, "start payram") || strings.Contains(calls, "run ") {
+		t.Errorf("expected only a start of the existing container, got calls:\n%s", calls)
+	}
+	job, _ := jobStore.LoadLatest()
+	if job.FailureCode != "UPGRADE_INTERRUPTED" {
+		t.Errorf("expected UPGRADE_INTERRUPTED, got %s", job.FailureCode)
+	}
+}
+
+func TestRecoverInterruptedUpgrade_RenamesHotSwapPreviousBack(t *testing.T) {
+	s, jobStore, callLog := interruptedTestServer(t, "", true)
+	// Only payram-previous exists: the hot swap died between its renames
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callLog + "\n" +
+		"[ \"$1\" = inspect ] && [ \"$4\" = payram ] && [ ! -e " + callLog + ".renamed ] && { echo 'Error: No such object: payram' >&2; exit 1; }\n" +
+		"[ \"$1\" = rename ] && touch " + callLog + ".renamed\n" +
+		"exit 0\n"
+	if err := os.WriteFile(s.config.DockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s.recoverInterruptedUpgrade(context.Background())
+
+	calls := readCalls(t, callLog)
+	if !strings.Contains(calls, "rename payram-previous payram\nupdate --restart always payram\nstart payram\n") {
+		t.Errorf("expected the previous container to be renamed back and started, got calls:\n%s", calls)
+	}
+	if strings.Contains(calls, "run ") {
+		t.Errorf("expected no container to be recreated, got calls:\n%s", calls)
+	}
+	job, _ := jobStore.LoadLatest()
+	if job.FailureCode != "UPGRADE_INTERRUPTED" || !strings.Contains(job.Message, "renamed back to payram") {
+		t.Errorf("expected UPGRADE_INTERRUPTED saying the container was renamed back, got %s: %q", job.FailureCode, job.Message)
+	}
+}
+
+func TestRecoverInterruptedUpgrade_NoSnapshotLeavesContainer(t *testing.T) {
+	s, jobStore, callLog := interruptedTestServer(t, "", false)
+
+	s.recoverInterruptedUpgrade(context.Background())
+
+	if calls := readCalls(t, callLog); calls != "" {
+		t.Errorf("expected no docker calls without a snapshot, got:\n%s", calls)
+	}
+	job, _ := jobStore.LoadLatest()
+	if job.State != jobs.JobStateFailed || !strings.Contains(job.Message, "left as is") {
+		t.Errorf("expected a failed job saying the container was left as is, got %s: %q", job.State, job.Message)
+	}
+}
+
+func TestRecoverInterruptedUpgrade_IgnoresFinishedJob(t *testing.T) {
+	s, jobStore, callLog := interruptedTestServer(t, "", true)
+	job, _ := jobStore.LoadLatest()
+	job.State = jobs.JobStateReady
+	jobStore.Save(job)
+
+	s.recoverInterruptedUpgrade(context.Background())
+
+	if calls := readCalls(t, callLog); calls != "" {
+		t.Errorf("expected no docker calls for a finished job, got:\n%s", calls)
+	}
+	if saved, _ := jobStore.LoadLatest(); saved.State != jobs.JobStateReady {
+		t.Errorf("expected the finished job untouched, got %s", saved.State)
+	}
+}
+
+// snapshotBackups makes s take its backups as volume snapshots with a
+// command that always succeeds, so an upgrade gets past the backup without a
+// database to dump.
+func snapshotBackups(s *Server) {
+	s.containerBackupExec.Snapshot = backup.SnapshotConfig{Strategy: backup.StrategySnapshot, Command: "echo {name}"}
+	s.containerBackupExec.BackupTimeout = 10 * time.Second
+}
+
+func TestRecoverInterruptedUpgrade_ResumesFromBackupCheckpoint(t *testing.T) {
+	s, jobStore, callLog := newCancelTestServer(t, 0, "none")
+	s.config.PolicyURL = buildPolicyFile(t, "1.1.0", []string{"1.0.0", "1.1.0"}, nil)
+	s.config.RuntimeManifestURL = buildManifestFile(t)
+	s.config.ResumeInterruptedUpgrades = true
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/health":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/api/v1/version":
+			w.Write([]byte(`{"version":"1.1.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(core.Close)
+	s.coreClient = coreclient.NewClient(core.URL)
+
+	snapshotBackups(s)
+
+	backupFile := filepath.Join(s.config.Backup.Dir, "payram-backup-20260301-120000-1.0.0-to-1.1.0.dump")
+	if err := os.WriteFile(backupFile, []byte("PGDMP data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	interrupted := jobs.NewJob("job-interrupted", jobs.JobModeManual, "1.1.0")
+	interrupted.ResolvedTarget = "1.1.0"
+	interrupted.State = jobs.JobStateBackingUp
+	interrupted.Checkpoint = jobs.CheckpointBackedUp
+	interrupted.BackupPath = backupFile
+	jobStore.Save(interrupted)
+	os.Remove(callLog)
+
+	job, plan := s.recoverInterruptedUpgrade(context.Background())
+	if plan == nil || job == nil || job.JobID != interrupted.JobID {
+		t.Fatalf("expected the interrupted job to be resumed, got job %+v", job)
+	}
+	s.executeUpgrade(job, plan.Manifest, plan.ArchSupport, plan.SteppingStone)
+
+	// The stub never reports the new container running; what matters is
+	// that the upgrade went on from the checkpoint to the replacement
+	calls := readCalls(t, callLog)
+	if !strings.Contains(calls, "pull ") || !strings.Contains(calls, "stop payram") {
+		t.Errorf("expected a re-pull and the container replaced, got calls:\n%s", calls)
+	}
+	if job.BackupPath == "" || job.BackupPath == backupFile || job.Checkpoint != jobs.CheckpointStopped {
+		t.Errorf("expected a new backup and a STOPPED checkpoint, got %s at %s", job.BackupPath, job.Checkpoint)
+	}
+	if logs, _ := jobStore.ReadLogs(); !strings.Contains(logs, "Resuming job job-interrupted from checkpoint BACKED_UP") ||
+		!strings.Contains(logs, "Not reusing the pre-upgrade backup of the interrupted run") {
+		t.Errorf("expected resume and a new backup in logs, got:\n%s", logs)
+	}
+}
+
+func TestRecoverInterruptedUpgrade_DoesNotResume(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		checkpoint jobs.Checkpoint
+	}{
+		{"resuming disabled", false, jobs.CheckpointBackedUp},
+		{"container already stopped", true, jobs.CheckpointStopped},
+		{"no checkpoint", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, jobStore, _ := interruptedTestServer(t, "", true)
+			s.config.ResumeInterruptedUpgrades = tt.enabled
+			job, _ := jobStore.LoadLatest()
+			job.Checkpoint = tt.checkpoint
+			jobStore.Save(job)
+
+			if _, plan := s.recoverInterruptedUpgrade(context.Background()); plan != nil {
+				t.Fatal("expected the job not to be resumed")
+			}
+			if job, _ := jobStore.LoadLatest(); job.FailureCode != "UPGRADE_INTERRUPTED" {
+				t.Errorf("expected UPGRADE_INTERRUPTED, got %s/%s", job.State, job.FailureCode)
+			}
+		})
+	}
+}
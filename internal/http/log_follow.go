@@ -0,0 +1,101 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
+)
+
+// logFollowPoll is how often a follow stream checks the log file for lines
+// appended by other processes, which are not published to subscriptions.
+const logFollowPoll = time.Second
+
+// followLogs writes the job log from after, then streams what is appended to
+// it until the client disconnects, the daemon shuts down, or the log is
+// rotated. Lines are read from the file itself, so the stream is exactly the
+// file's bytes from the cursor in the LogCursorHeader and a client can resume
+// after the last line it read. A client that reads slower than the log is
+// written never holds up the upgrade; it just falls behind in the file.
+func (s *Server) followLogs(w http.ResponseWriter, r *http.Request, after jobs.LogCursor) {
+	// Subscribe before opening the file so no append falls in between. The
+	// subscription only signals that the file has grown.
+	sub := s.jobStore.SubscribeLogs(1)
+	defer sub.Close()
+
+	f, at, more, err := s.jobStore.OpenLogs(after)
+	if err != nil {
+		logger.Error("Server", "followLogs", err)
+		http.Error(w, "failed to open job log", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set(jobs.LogCursorHeader, at.String())
+	if more {
+		w.Header().Set(jobs.LogMoreHeader, "true")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	copyLog := func() bool {
+		if _, err := io.Copy(w, f); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+	if !copyLog() || more {
+		return
+	}
+
+	ticker := time.NewTicker(logFollowPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.followersDone:
+			return
+		case _, ok := <-sub.Lines():
+			if !ok {
+				return
+			}
+		case <-ticker.C:
+		}
+		if !copyLog() {
+			return
+		}
+		if !s.jobStore.IsCurrentLog(f) {
+			// Rotated: finish the old file and end the stream, so the
+			// client resumes in the new one with a cursor it can follow
+			copyLog()
+			return
+		}
+	}
+}
+
+// writeLogs writes the job log from after and returns.
+func (s *Server) writeLogs(w http.ResponseWriter, after jobs.LogCursor) {
+	f, at, more, err := s.jobStore.OpenLogs(after)
+	if err != nil {
+		logger.Error("Server", "HandleUpgradeLogs", err)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set(jobs.LogCursorHeader, at.String())
+	if more {
+		w.Header().Set(jobs.LogMoreHeader, "true")
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
@@ -0,0 +1,233 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+// Migration monitoring during health verification. Only transactions open
+// for at least migrationMinTxAge are considered, so ordinary requests of a
+// container that is already serving are ignored.
+const migrationMinTxAge = 10 * time.Second
+
+// migrationActivityScript lists the long-running transactions on the
+// container's database, connecting as the container does (see
+// dbexec.CheckContainerConnection). Each line is pid|state|wait event
+// type|transaction age in seconds|seconds since the state last changed.
+var migrationActivityScript = `PGPASSWORD="$POSTGRES_PASSWORD" PGSSLMODE="${POSTGRES_SSLMODE:-prefer}" ` +
+	`psql -h "${POSTGRES_HOST:-127.0.0.1}" -p "${POSTGRES_PORT:-5432}" ` +
+	`-U "${POSTGRES_USER:-$POSTGRES_USERNAME}" -d "${POSTGRES_DB:-$POSTGRES_DATABASE}" ` +
+	`-w -At -F '|' -c "SELECT pid, state, concat(wait_event_type), extract(epoch FROM now() - xact_start)::int, ` +
+	`extract(epoch FROM now() - state_change)::int ` +
+	`FROM pg_stat_activity WHERE datname = current_database() AND pid <> pg_backend_pid() ` +
+	fmt.Sprintf(`AND xact_start < now() - make_interval(secs => %d) ORDER BY xact_start"`, int(migrationMinTxAge.Seconds()))
+
+// dbSession is one long-running transaction from pg_stat_activity.
+type dbSession struct {
+	PID           string
+	State         string // "active", "idle in transaction", ...
+	WaitEventType string // "Lock" while waiting for a lock held by another session
+	TxSeconds     int
+	StateSeconds  int // since state last changed, e.g. since it went idle
+}
+
+// parseDBSessions parses the output of migrationActivityScript, skipping
+// lines it does not recognise.
+func parseDBSessions(output string) []dbSession {
+	var sessions []dbSession
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 5 {
+			continue
+		}
+		age, err := strconv.Atoi(fields[3])
+		if err != nil {
+			continue
+		}
+		stateAge, err := strconv.Atoi(fields[4])
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, dbSession{PID: fields[0], State: fields[1], WaitEventType: fields[2], TxSeconds: age, StateSeconds: stateAge})
+	}
+	return sessions
+}
+
+// migrationActivity classifies a pg_stat_activity snapshot.
+type migrationActivity string
+
+const (
+	migrationNone        migrationActivity = "none"
+	migrationProgressing migrationActivity = "progressing"
+	migrationBlocked     migrationActivity = "blocked"
+	migrationIdle        migrationActivity = "idle in transaction"
+)
+
+// classifyMigrationActivity reports what the long-running transactions in
+// sessions are doing and the session that decided it. Any transaction still
+// running counts as progress, even if others are stuck: the migration may be
+// the one holding their locks.
+func classifyMigrationActivity(sessions []dbSession) (migrationActivity, dbSession) {
+	var blocked, idle *dbSession
+	for i := range sessions {
+		session := &sessions[i]
+		switch {
+		case session.State == "active" && session.WaitEventType == "Lock":
+			if blocked == nil {
+				blocked = session
+			}
+		case session.State == "active":
+			return migrationProgressing, *session
+		case strings.HasPrefix(session.State, "idle in transaction"):
+			if idle == nil {
+				idle = session
+			}
+		}
+	}
+	if blocked != nil {
+		return migrationBlocked, *blocked
+	}
+	if idle != nil {
+		return migrationIdle, *idle
+	}
+	return migrationNone, dbSession{}
+}
+
+// migrationVerdict is what verifyUpgrade does after a failed health check.
+type migrationVerdict int
+
+const (
+	migrationKeepWaiting migrationVerdict = iota // no migration seen
+	migrationExtend                              // a migration is running, or may be stalled: extend the health wait
+	migrationTimedOut                            // still running after MIGRATION_MAX_WAIT_SECONDS
+	migrationStalled                             // one session blocked or idle for MIGRATION_STALL_SECONDS
+)
+
+// migrationMonitor follows the migration of a new container across the
+// failed health checks of one verification.
+type migrationMonitor struct {
+	maxWait      time.Duration
+	stallAfter   time.Duration
+	runningSince time.Time // first poll that saw a migration
+	last         migrationActivity
+	stalledPID   string    // session blocked or idle on the last poll
+	stalledSince time.Time // since when stalledPID has been stuck in last
+}
+
+// observe records a snapshot classified as activity at now, decided by
+// session, and returns the verdict. A stall fails the job only once the same
+// session has been blocked, or idle, for stallAfter: an idle session counts
+// from its state_change, a blocked one from the first poll that saw it
+// blocked, since its state_change is when its query started. Until then the
+// health wait is extended, as for a running migration.
+func (m *migrationMonitor) observe(activity migrationActivity, session dbSession, now time.Time) migrationVerdict {
+	previous := m.last
+	m.last = activity
+	if activity == migrationNone {
+		m.stalledPID = ""
+		return migrationKeepWaiting
+	}
+	if m.runningSince.IsZero() {
+		m.runningSince = now
+	}
+
+	if activity == migrationProgressing {
+		m.stalledPID = ""
+	} else {
+		if session.PID != m.stalledPID || activity != previous {
+			m.stalledPID = session.PID
+			m.stalledSince = now
+		}
+		if activity == migrationIdle {
+			if idleSince := now.Add(-time.Duration(session.StateSeconds) * time.Second); idleSince.Before(m.stalledSince) {
+				m.stalledSince = idleSince
+			}
+		}
+		if now.Sub(m.stalledSince) >= m.stallAfter {
+			return migrationStalled
+		}
+	}
+	if now.Sub(m.runningSince) >= m.maxWait {
+		return migrationTimedOut
+	}
+	return migrationExtend
+}
+
+// stalledFor is how long the stalled session has been stuck as of now.
+func (m *migrationMonitor) stalledFor(now time.Time) time.Duration {
+	return now.Sub(m.stalledSince).Round(time.Second)
+}
+
+// newMigrationMonitor returns a monitor for containerName, or nil when
+// MIGRATION_MAX_WAIT_SECONDS is 0 or the container's database is not local.
+func (s *Server) newMigrationMonitor(ctx context.Context, containerName string) *migrationMonitor {
+	if s.config.MigrationMaxWaitSeconds <= 0 {
+		return nil
+	}
+	env, err := backup.NewDockerInspector(s.config.DockerBin, nil).GetContainerEnv(ctx, containerName)
+	if err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Could not read the container's database settings: %v (migration monitoring skipped)", err))
+		return nil
+	}
+	if !backup.IsLocalDB(env["POSTGRES_HOST"]) {
+		return nil
+	}
+	return &migrationMonitor{
+		maxWait:    time.Duration(s.config.MigrationMaxWaitSeconds) * time.Second,
+		stallAfter: time.Duration(s.config.MigrationStallSeconds) * time.Second,
+	}
+}
+
+// watchMigration polls pg_stat_activity after a failed health check and
+// reports whether the health wait should be extended. ok is false if the
+// job failed with MIGRATION_STALLED or MIGRATION_TIMEOUT. A failed poll is
+// logged and treated as no migration.
+func (s *Server) watchMigration(ctx context.Context, job *jobs.Job, m *migrationMonitor, containerName string) (extend, ok bool) {
+	pollCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	output, err := s.dockerRunner.Exec(pollCtx, containerName, "sh", "-c", migrationActivityScript)
+	cancel()
+	if err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Could not read pg_stat_activity: %v", strings.TrimSpace(err.Error())))
+		m.observe(migrationNone, dbSession{}, time.Now())
+		return false, true
+	}
+
+	previous := m.last
+	now := time.Now()
+	activity, session := classifyMigrationActivity(parseDBSessions(output))
+	verdict := m.observe(activity, session, now)
+	if activity != previous && activity != migrationNone {
+		s.jobStore.AppendLog(fmt.Sprintf("Migration monitor: session %s is %s (transaction open for %ds)", session.PID, activity, session.TxSeconds))
+	}
+
+	switch verdict {
+	case migrationExtend:
+		return true, true
+	case migrationTimedOut:
+		s.failMigration(job, "MIGRATION_TIMEOUT", fmt.Sprintf("Database migration still running after %s (session %s, transaction open for %ds)", m.maxWait, session.PID, session.TxSeconds))
+		return false, false
+	case migrationStalled:
+		reason := "has been waiting on a lock"
+		if activity == migrationIdle {
+			reason = "has been idle in its transaction"
+		}
+		s.failMigration(job, "MIGRATION_STALLED", fmt.Sprintf("Database migration stalled: session %s %s for %s (transaction open for %ds)", session.PID, reason, m.stalledFor(now), session.TxSeconds))
+		return false, false
+	}
+	return false, true
+}
+
+func (s *Server) failMigration(job *jobs.Job, code, message string) {
+	job.State = jobs.JobStateFailed
+	job.FailureCode = code
+	job.Message = message
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (manual recovery required)", job.FailureCode, job.Message))
+}
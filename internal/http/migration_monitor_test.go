@@ -0,0 +1,206 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/coreclient"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+func TestParseDBSessions(t *testing.T) {
+	output := "42|active||95|90\n" +
+		"43|idle in transaction|Client|30|12\n" +
+		"psql: warning: something\n" +
+		"44|active|Lock|notanumber|5\n" +
+		"45|active|Lock|95\n"
+
+	want := []dbSession{
+		{PID: "42", State: "active", TxSeconds: 95, StateSeconds: 90},
+		{PID: "43", State: "idle in transaction", WaitEventType: "Client", TxSeconds: 30, StateSeconds: 12},
+	}
+	if got := parseDBSessions(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestClassifyMigrationActivity(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions []dbSession
+		want     migrationActivity
+		wantPID  string
+	}{
+		{"no long transactions", nil, migrationNone, ""},
+		{"progressing", []dbSession{{PID: "42", State: "active", WaitEventType: "IO", TxSeconds: 95}}, migrationProgressing, "42"},
+		{"blocked", []dbSession{{PID: "42", State: "active", WaitEventType: "Lock", TxSeconds: 95}}, migrationBlocked, "42"},
+		{"idle in transaction", []dbSession{{PID: "42", State: "idle in transaction", WaitEventType: "Client", TxSeconds: 95}}, migrationIdle, "42"},
+		{"aborted transaction", []dbSession{{PID: "42", State: "idle in transaction (aborted)", TxSeconds: 95}}, migrationIdle, "42"},
+		{"running beats blocked", []dbSession{
+			{PID: "42", State: "active", WaitEventType: "Lock", TxSeconds: 95},
+			{PID: "43", State: "active", TxSeconds: 90},
+		}, migrationProgressing, "43"},
+		{"blocked beats idle", []dbSession{
+			{PID: "42", State: "idle in transaction", TxSeconds: 95},
+			{PID: "43", State: "active", WaitEventType: "Lock", TxSeconds: 90},
+		}, migrationBlocked, "43"},
+		{"idle session", []dbSession{{PID: "42", State: "idle", TxSeconds: 95}}, migrationNone, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, session := classifyMigrationActivity(tt.sessions)
+			if got != tt.want || session.PID != tt.wantPID {
+				t.Errorf("expected %s (session %q), got %s (session %q)", tt.want, tt.wantPID, got, session.PID)
+			}
+		})
+	}
+}
+
+func TestMigrationMonitor_Observe(t *testing.T) {
+	running := dbSession{PID: "42", State: "active"}
+	blocked := dbSession{PID: "42", State: "active", WaitEventType: "Lock", StateSeconds: 600}
+	otherBlocked := dbSession{PID: "43", State: "active", WaitEventType: "Lock", StateSeconds: 600}
+	idleNow := dbSession{PID: "42", State: "idle in transaction", StateSeconds: 5}
+	idleLong := dbSession{PID: "42", State: "idle in transaction", StateSeconds: 120}
+	type poll struct {
+		activity migrationActivity
+		session  dbSession
+	}
+	start := time.Now()
+	tests := []struct {
+		name  string
+		polls []poll
+		want  []migrationVerdict
+	}{
+		{"progressing extends", []poll{{migrationProgressing, running}, {migrationProgressing, running}},
+			[]migrationVerdict{migrationExtend, migrationExtend}},
+		{"progressing past the max wait", []poll{{migrationProgressing, running}, {migrationProgressing, running}, {migrationProgressing, running}, {migrationProgressing, running}},
+			[]migrationVerdict{migrationExtend, migrationExtend, migrationExtend, migrationTimedOut}},
+		{"blocked for the stall duration", []poll{{migrationBlocked, blocked}, {migrationBlocked, blocked}},
+			[]migrationVerdict{migrationExtend, migrationStalled}},
+		{"blocked sessions change", []poll{{migrationBlocked, blocked}, {migrationBlocked, otherBlocked}},
+			[]migrationVerdict{migrationExtend, migrationExtend}},
+		{"idle from its state change", []poll{{migrationIdle, idleLong}},
+			[]migrationVerdict{migrationStalled}},
+		{"idle for the stall duration", []poll{{migrationIdle, idleNow}, {migrationIdle, idleNow}},
+			[]migrationVerdict{migrationExtend, migrationStalled}},
+		{"progress resets a stall", []poll{{migrationBlocked, blocked}, {migrationProgressing, running}, {migrationBlocked, blocked}},
+			[]migrationVerdict{migrationExtend, migrationExtend, migrationExtend}},
+		{"no migration", []poll{{migrationNone, dbSession{}}, {migrationNone, dbSession{}}},
+			[]migrationVerdict{migrationKeepWaiting, migrationKeepWaiting}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &migrationMonitor{maxWait: 3 * time.Minute, stallAfter: time.Minute}
+			for i, p := range tt.polls {
+				if got := m.observe(p.activity, p.session, start.Add(time.Duration(i)*time.Minute)); got != tt.want[i] {
+					t.Errorf("poll %d (%s): expected verdict %d, got %d", i+1, p.activity, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+// migrationTestScript answers docker for a container with an in-container
+// database (POSTGRES_HOST=host), a stable restart count, and activity as the
+// pg_stat_activity poll output.
+func migrationTestScript(host, activity string) string {
+	return "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"  inspect) case \"$3\" in\n" +
+		"    *Config.Env*) echo '[\"POSTGRES_HOST=" + host + "\",\"POSTGRES_DATABASE=payram\"]' ;;\n" +
+		"    *) echo 0 ;;\n" +
+		"  esac ;;\n" +
+		"  exec) printf '" + activity + "' ;;\n" +
+		"esac\n"
+}
+
+// newMigrationTestServer returns a server with migration monitoring on whose
+// Payram Core never becomes healthy.
+func newMigrationTestServer(t *testing.T, activity string, maxWaitSeconds, stallSeconds int) (*Server, *jobs.Store) {
+	t.Helper()
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "migrating", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(core.Close)
+
+	server, jobStore := newFinalizeTestServer(t, migrationTestScript("localhost", activity))
+	server.config.MigrationMaxWaitSeconds = maxWaitSeconds
+	server.config.MigrationStallSeconds = stallSeconds
+	server.coreClient = coreclient.NewClient(core.URL)
+	return server, jobStore
+}
+
+func TestVerifyUpgrade_StalledMigrationFailsFast(t *testing.T) {
+	// A session idle since long before the poll stalls at once; a blocked
+	// one only after MIGRATION_STALL_SECONDS of polls
+	tests := []struct {
+		name         string
+		activity     string
+		stallSeconds int
+		reason       string
+	}{
+		{"blocked", `42|active|Lock|95|95\n`, 1, "waiting on a lock"},
+		{"idle in transaction", `42|idle in transaction|Client|95|90\n`, 60, "idle in its transaction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, jobStore := newMigrationTestServer(t, tt.activity, 900, tt.stallSeconds)
+			job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+			if server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+				t.Fatal("expected verification to fail")
+			}
+			if job.FailureCode != "MIGRATION_STALLED" {
+				t.Fatalf("expected MIGRATION_STALLED, got %s (%s)", job.FailureCode, job.Message)
+			}
+			if !strings.Contains(job.Message, "session 42 has been "+tt.reason) {
+				t.Errorf("expected the stalled session in the message, got %q", job.Message)
+			}
+			logs, _ := jobStore.ReadLogs()
+			if strings.Contains(logs, "Health check attempt 3") {
+				t.Errorf("expected the stall to end verification after the second poll, got:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestVerifyUpgrade_RunningMigrationTimesOut(t *testing.T) {
+	server, jobStore := newMigrationTestServer(t, `42|active||95|95\n`, 1, 60)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatal("expected verification to fail")
+	}
+	if job.FailureCode != "MIGRATION_TIMEOUT" {
+		t.Fatalf("expected MIGRATION_TIMEOUT, got %s (%s)", job.FailureCode, job.Message)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Migration monitor: session 42 is progressing") {
+		t.Errorf("expected the running migration to be logged, got:\n%s", logs)
+	}
+}
+
+func TestNewMigrationMonitor(t *testing.T) {
+	server, _ := newFinalizeTestServer(t, migrationTestScript("localhost", ""))
+	server.config.MigrationMaxWaitSeconds = 900
+	if server.newMigrationMonitor(context.Background(), "payram") == nil {
+		t.Error("expected a monitor for an in-container database")
+	}
+
+	server.config.MigrationMaxWaitSeconds = 0
+	if server.newMigrationMonitor(context.Background(), "payram") != nil {
+		t.Error("expected MIGRATION_MAX_WAIT_SECONDS=0 to disable monitoring")
+	}
+
+	server, _ = newFinalizeTestServer(t, migrationTestScript("db.example.com", ""))
+	server.config.MigrationMaxWaitSeconds = 900
+	if server.newMigrationMonitor(context.Background(), "payram") != nil {
+		t.Error("expected no monitor for an external database")
+	}
+}
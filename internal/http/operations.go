@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/orchestrator"
+)
+
+// errOperationRunning is returned by lockOperation when another operation of
+// this daemon runs and the caller does not wait for it.
+var errOperationRunning = errors.New("another operation is running")
+
+// runQueuedUpgrade executes the upgrade planned for job once it holds the
+// operation queue (see runQueued).
+func (s *Server) runQueuedUpgrade(job *jobs.Job, plan *UpgradePlan) {
+	s.runQueued(job, orchestrator.KindUpgrade, func() {
+		s.executeUpgrade(job, plan.Manifest, plan.ArchSupport, plan.SteppingStone)
+	})
+}
+
+// runQueued runs job's operation once it holds the operation queue, after
+// every operation queued before it. Without SERIALIZE_OPERATIONS it runs
+// at once.
+func (s *Server) runQueued(job *jobs.Job, kind orchestrator.Kind, run func()) {
+	if holder := s.operations.Status().Holder; holder != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Queued behind %s %s; waiting for it to finish", holder.Kind, holder.ID))
+	}
+	// Waiting ends only when the lock is handed over
+	release, _ := s.operations.Acquire(context.Background(), orchestrator.Operation{Kind: kind, ID: job.JobID})
+	defer release()
+	run()
+}
+
+// lockOperation keeps an upgrade, restart or scheduled backup, described by
+// what, from overlapping another one of this daemon, whether or not
+// SERIALIZE_OPERATIONS is set, and from a restore or rollback run by another
+// process, through the restore lock they all take. With wait set it waits
+// for the operation of this daemon to finish; otherwise it fails with
+// errOperationRunning. It fails with RESTORE_IN_PROGRESS while another
+// process holds the restore lock. The returned function releases both.
+func (s *Server) lockOperation(what string, wait bool) (func(), error) {
+	if !s.operationMu.TryLock() {
+		if !wait {
+			return nil, errOperationRunning
+		}
+		s.jobStore.AppendLog(fmt.Sprintf("Waiting for another operation to finish before the %s", what))
+		s.operationMu.Lock()
+	}
+	lock, err := s.backupManager.LockOperation(what)
+	if err != nil {
+		s.operationMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		lock.Release()
+		s.operationMu.Unlock()
+	}, nil
+}
+
+// operationLockFailure returns the failure code and message of a job that
+// could not take the operation lock.
+func operationLockFailure(err error) (string, string) {
+	if message, ok := strings.CutPrefix(err.Error(), "RESTORE_IN_PROGRESS: "); ok {
+		return "RESTORE_IN_PROGRESS", message
+	}
+	return "BACKUP_FAILED", err.Error()
+}
@@ -0,0 +1,211 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/orchestrator"
+	"github.com/payram/payram-updater/internal/recovery"
+)
+
+func TestScheduledBackup_WaitsForQueuedOperation(t *testing.T) {
+	s, _ := newScheduleTestServer(t, 60, 10)
+	s.operations = orchestrator.New()
+	release, _ := s.operations.TryAcquire(orchestrator.Operation{Kind: orchestrator.KindUpgrade, ID: "job-1"})
+
+	if s.runScheduledBackupIfDue(context.Background()) {
+		t.Error("expected the scheduled backup to wait while an upgrade holds the queue")
+	}
+	release()
+	if !s.runScheduledBackupIfDue(context.Background()) {
+		t.Error("expected the scheduled backup to run once the queue is free")
+	}
+	if holder := s.operations.Status().Holder; holder != nil {
+		t.Errorf("expected the scheduled backup to release the queue, got %+v", holder)
+	}
+}
+
+func TestRunQueued_WaitsForTheHolder(t *testing.T) {
+	server, jobStore := newFinalizeTestServer(t, "#!/bin/sh\n")
+	server.operations = orchestrator.New()
+	release, _ := server.operations.TryAcquire(orchestrator.Operation{Kind: orchestrator.KindScheduledBackup})
+
+	job := jobs.NewJob("job-queued", jobs.JobModeManual, "1.2.0")
+	jobStore.Save(job)
+	ran := make(chan struct{})
+	go server.runQueued(job, orchestrator.KindUpgrade, func() { close(ran) })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.operations.Status().Waiting) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the upgrade to wait in the queue, got %+v", server.operations.Status())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-ran:
+		t.Fatal("expected the upgrade not to run while the scheduled backup holds the queue")
+	default:
+	}
+
+	release()
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the upgrade to run once the scheduled backup released the queue")
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Queued behind scheduled_backup") {
+		t.Errorf("expected the wait to be logged, got:\n%s", logs)
+	}
+}
+
+func TestHandleUpgradeRun_ConflictWhileUpgradeQueued(t *testing.T) {
+	cfg := &config.Config{Port: 8080, PolicyURL: "http://localhost:1/policy", RuntimeManifestURL: "http://localhost:1/manifest", FetchTimeoutSeconds: 1}
+	server := New(cfg, jobs.NewStore(t.TempDir()))
+	server.operations = orchestrator.New()
+	release, _ := server.operations.TryAcquire(orchestrator.Operation{Kind: orchestrator.KindAutoUpdate, ID: "job-auto"})
+	defer release()
+
+	req := httptest.NewRequest(http.MethodPost, "/upgrade/run", strings.NewReader(`{"requestedTarget":"v1.7.0","source":"CLI"}`))
+	w := httptest.NewRecorder()
+	server.HandleUpgradeRun()(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	var errResp map[string]string
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if errResp["error"] != "An upgrade is already queued" || errResp["jobId"] != "job-auto" {
+		t.Errorf("unexpected response %v", errResp)
+	}
+}
+
+func TestHandleUpgradeStatus_ShowsOperationQueue(t *testing.T) {
+	server := New(&config.Config{Port: 8080}, jobs.NewStore(t.TempDir()))
+	get := func() UpgradeStatusResponse {
+		w := httptest.NewRecorder()
+		server.HandleUpgradeStatus()(w, httptest.NewRequest(http.MethodGet, "/upgrade/status", nil))
+		var resp UpgradeStatusResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return resp
+	}
+	if resp := get(); resp.Operations != nil {
+		t.Errorf("expected no operation queue without SERIALIZE_OPERATIONS, got %+v", resp.Operations)
+	}
+
+	server.operations = orchestrator.New()
+	release, _ := server.operations.TryAcquire(orchestrator.Operation{Kind: orchestrator.KindScheduledBackup})
+	defer release()
+	go server.operations.Acquire(context.Background(), orchestrator.Operation{Kind: orchestrator.KindUpgrade, ID: "job-manual"})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.operations.Status().Waiting) != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp := get()
+	if resp.Operations == nil || resp.Operations.Holder == nil || resp.Operations.Holder.Kind != orchestrator.KindScheduledBackup {
+		t.Fatalf("expected the scheduled backup to hold the queue, got %+v", resp.Operations)
+	}
+	if len(resp.Operations.Waiting) != 1 || resp.Operations.Waiting[0].ID != "job-manual" {
+		t.Errorf("expected the manual upgrade to wait, got %+v", resp.Operations.Waiting)
+	}
+}
+
+// holdRestoreLock makes the restore lock in backupDir look held by another
+// live process, the test's parent, as a restore run by the CLI would.
+func holdRestoreLock(t *testing.T, backupDir string) {
+	t.Helper()
+	contents := fmt.Sprintf("%d\nrestore of payram-backup.dump (started 2026-01-01T00:00:00Z)\n", os.Getppid())
+	if err := os.WriteFile(filepath.Join(backupDir, ".restore.lock"), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecuteUpgrade_RefusedWhileAnotherProcessRestores(t *testing.T) {
+	s, jobStore, callLog := newCancelTestServer(t, 0, "none")
+	holdRestoreLock(t, s.config.Backup.Dir)
+	job := jobs.NewJob("job-restoring", jobs.JobModeManual, "1.1.0")
+	job.ResolvedTarget = "1.1.0"
+	jobStore.Save(job)
+
+	s.executeUpgrade(job, &manifest.Manifest{Image: manifest.Image{Repo: "payramapp/payram"}}, nil, "")
+
+	if job.State != jobs.JobStateFailed || job.FailureCode != "RESTORE_IN_PROGRESS" {
+		t.Fatalf("expected RESTORE_IN_PROGRESS, got %s/%s: %s", job.State, job.FailureCode, job.Message)
+	}
+	if !strings.Contains(job.Message, "restore of payram-backup.dump") {
+		t.Errorf("expected the message to name the running restore, got %s", job.Message)
+	}
+	if calls := readCalls(t, callLog); strings.Contains(calls, "stop payram") || strings.Contains(calls, "pull ") {
+		t.Errorf("expected the container untouched, got calls:\n%s", calls)
+	}
+}
+
+func TestOperationLockFailure(t *testing.T) {
+	tests := []struct {
+		err         error
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			err:         errors.New("RESTORE_IN_PROGRESS: another process (PID 42) holds the restore lock for the restore of payram-backup.dump"),
+			wantCode:    "RESTORE_IN_PROGRESS",
+			wantMessage: "another process (PID 42) holds the restore lock for the restore of payram-backup.dump",
+		},
+		{
+			err:         errors.New("failed to take restore lock: permission denied"),
+			wantCode:    "BACKUP_FAILED",
+			wantMessage: "failed to take restore lock: permission denied",
+		},
+	}
+
+	for _, tt := range tests {
+		code, message := operationLockFailure(tt.err)
+		if code != tt.wantCode || message != tt.wantMessage {
+			t.Errorf("operationLockFailure(%q) = %s, %q; want %s, %q", tt.err, code, message, tt.wantCode, tt.wantMessage)
+		}
+		if playbook := recovery.GetPlaybook(code); playbook.Code != code {
+			t.Errorf("expected a recovery playbook for %s", code)
+		}
+	}
+	if !recovery.IsRetryable("RESTORE_IN_PROGRESS") {
+		t.Error("expected RESTORE_IN_PROGRESS to be retryable")
+	}
+}
+
+func TestLockOperation_HoldsTheRestoreLock(t *testing.T) {
+	s, _, _ := newCancelTestServer(t, 0, "none")
+	unlock, err := s.lockOperation("upgrade job-1", true)
+	if err != nil {
+		t.Fatalf("lockOperation: %v", err)
+	}
+
+	if _, err := s.backupManager.LockRestore("payram-backup.dump"); err == nil || !strings.Contains(err.Error(), "upgrade job-1") {
+		t.Errorf("expected a restore to be refused while the upgrade runs, got %v", err)
+	}
+	if _, err := s.lockOperation("scheduled backup", false); err != errOperationRunning {
+		t.Errorf("expected errOperationRunning for a second operation, got %v", err)
+	}
+
+	unlock()
+	lock, err := s.backupManager.LockRestore("payram-backup.dump")
+	if err != nil {
+		t.Fatalf("expected the restore lock to be free once the upgrade is done, got %v", err)
+	}
+	lock.Release()
+}
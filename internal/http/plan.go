@@ -2,12 +2,17 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
 	goversion "github.com/hashicorp/go-version"
 	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
 	"github.com/payram/payram-updater/internal/manifest"
 	"github.com/payram/payram-updater/internal/policy"
 )
@@ -26,6 +31,16 @@ type UpgradePlan struct {
 	Message         string             `json:"message"`
 	Manifest        *manifest.Manifest `json:"manifest,omitempty"`
 	ArchSupport     map[string]string  `json:"-"` // arch variant min versions, not serialized
+	// ImageRepoOverride is the per-invocation image repo used instead of the manifest's, if any.
+	ImageRepoOverride string `json:"imageRepoOverride,omitempty"`
+	// AlreadyOnTarget is set by run when the running version is the resolved
+	// target; the upgrade is skipped and Message says so.
+	AlreadyOnTarget bool `json:"alreadyOnTarget,omitempty"`
+	// Warnings are non-fatal planning issues; run records them on the job.
+	Warnings []string `json:"warnings,omitempty"`
+	// Resumed is set by run when it finished an interrupted job instead of
+	// starting the requested upgrade; the returned job is the resumed one.
+	Resumed bool `json:"resumed,omitempty"`
 
 	// Internal fields (not serialized)
 	policyData *policy.Policy
@@ -99,24 +114,64 @@ func (s *Server) PlanUpgrade(ctx context.Context, mode jobs.JobMode, requestedTa
 
 	plan.Manifest = manifestData
 
+	s.reconcileEnvOverrides(plan)
+
 	// Apply IMAGE_REPO_OVERRIDE if configured (for testing with dummy repos)
 	if s.config.ImageRepoOverride != "" {
 		plan.Manifest.Image.Repo = s.config.ImageRepoOverride
 	}
 
+	// Refuse to pull from a repo the operator has not allowlisted
+	if !isImageRepoAllowed(plan.Manifest.Image.Repo, s.config.AllowedImageRepos) {
+		plan.State = jobs.JobStateFailed
+		plan.FailureCode = "IMAGE_REPO_NOT_ALLOWED"
+		plan.Message = fmt.Sprintf("Image repo %q is not in ALLOWED_IMAGE_REPOS (%s)", plan.Manifest.Image.Repo, strings.Join(s.config.AllowedImageRepos, ", "))
+		return plan
+	}
+
 	// Step 3: Resolve target
-	// If "latest" is requested, resolve it from the policy
+	// A strategy name ("latest", "latest-patch", "latest-dashboard") is
+	// resolved from the policy; plain "latest" uses LATEST_STRATEGY
 	resolvedTarget := requestedTarget
-	if strings.EqualFold(requestedTarget, "latest") {
-		if policyData != nil && strings.TrimSpace(policyData.Latest) != "" {
-			resolvedTarget = strings.TrimSpace(policyData.Latest)
-		} else {
-			// No policy or empty latest field
+	if policy.IsStrategy(requestedTarget) {
+		strategy := requestedTarget
+		if strings.EqualFold(strings.TrimSpace(requestedTarget), policy.StrategyLatest) && s.config.LatestStrategy != "" {
+			strategy = s.config.LatestStrategy
+		}
+		if policyData == nil {
 			plan.State = jobs.JobStateFailed
 			plan.FailureCode = "POLICY_REQUIRED"
-			plan.Message = "Cannot resolve 'latest': policy not available or latest field is empty"
+			plan.Message = fmt.Sprintf("Cannot resolve '%s': policy not available or latest field is empty", requestedTarget)
 			return plan
 		}
+		resolved, err := policyData.ResolveLatest(strategy, currentVersion)
+		if errors.Is(err, policy.ErrLatestEmpty) {
+			plan.State = jobs.JobStateFailed
+			plan.FailureCode = "POLICY_REQUIRED"
+			plan.Message = fmt.Sprintf("Cannot resolve '%s': policy not available or latest field is empty", requestedTarget)
+			return plan
+		}
+		if err != nil {
+			plan.State = jobs.JobStateFailed
+			plan.FailureCode = "LATEST_UNRESOLVED"
+			plan.Message = fmt.Sprintf("Cannot resolve '%s': %v", requestedTarget, err)
+			return plan
+		}
+		resolvedTarget = resolved
+	}
+
+	// A target missing from the policy's releases is most likely a typo that
+	// would otherwise fail late, at the image pull. Manual mode may target
+	// unlisted builds, so it only warns.
+	if policyData != nil && len(policyData.Releases) > 0 && !isReleaseListed(policyData.Releases, resolvedTarget) {
+		message := fmt.Sprintf("Target version %s is not in the policy releases (available: %s)", resolvedTarget, formatReleaseList(policyData.Releases))
+		if mode == jobs.JobModeDashboard {
+			plan.State = jobs.JobStateFailed
+			plan.FailureCode = "UNKNOWN_TARGET_VERSION"
+			plan.Message = message
+			return plan
+		}
+		plan.Warnings = append(plan.Warnings, message)
 	}
 
 	// Gate enforcement (DASHBOARD mode only, requires currentVersion).
@@ -241,3 +296,150 @@ func (s *Server) PlanUpgrade(ctx context.Context, mode jobs.JobMode, requestedTa
 
 	return plan
 }
+
+// isReleaseListed reports whether version is one of releases, ignoring a
+// leading "v" on either side.
+func isReleaseListed(releases []string, version string) bool {
+	normalized := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	for _, release := range releases {
+		if strings.TrimPrefix(strings.TrimSpace(release), "v") == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// maxListedReleases caps how many releases an UNKNOWN_TARGET_VERSION message names.
+const maxListedReleases = 10
+
+// formatReleaseList joins releases for a message, naming at most
+// maxListedReleases of them.
+func formatReleaseList(releases []string) string {
+	if len(releases) <= maxListedReleases {
+		return strings.Join(releases, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(releases[:maxListedReleases], ", "), len(releases)-maxListedReleases)
+}
+
+// imageRepoPattern matches an image repository without tag or digest,
+// optionally prefixed by a registry host and port.
+var imageRepoPattern = regexp.MustCompile(`^([a-z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+
+// validateImageRepo checks that an --image-repo override names a repository
+// and not an image reference with a tag or digest.
+func validateImageRepo(repo string) error {
+	if !imageRepoPattern.MatchString(repo) {
+		return fmt.Errorf("imageRepo must be an image repository without tag or digest, e.g. ghcr.io/acme/payram, got %q", repo)
+	}
+	return nil
+}
+
+// reconcileEnvOverrides adds a plan warning, and logs it, for each setting of
+// the environment that contradicts the manifest: IMAGE_REPO_OVERRIDE against
+// the manifest's image repo and TARGET_CONTAINER_NAME against its
+// container_name. The environment wins in both cases; the warning says so, so
+// an upgrade that pulls another image or replaces another container than the
+// manifest describes is not a surprise.
+func (s *Server) reconcileEnvOverrides(plan *UpgradePlan) {
+	var conflicts []string
+	if repo := s.config.ImageRepoOverride; repo != "" && repo != plan.Manifest.Image.Repo {
+		conflicts = append(conflicts, fmt.Sprintf("IMAGE_REPO_OVERRIDE %s overrides the manifest's image repo %s: images are pulled from %s because environment settings take precedence over the manifest", repo, plan.Manifest.Image.Repo, repo))
+	}
+	if name, manifestName := s.config.TargetContainerName, plan.Manifest.Defaults.ContainerName; name != "" && manifestName != "" && name != manifestName {
+		conflicts = append(conflicts, fmt.Sprintf("TARGET_CONTAINER_NAME %s overrides the manifest's container_name %s: container %s is upgraded because environment settings take precedence over the manifest", name, manifestName, name))
+	}
+	for _, conflict := range conflicts {
+		logger.Warnf("Server", "PlanUpgrade", "%s", conflict)
+		plan.Warnings = append(plan.Warnings, conflict)
+	}
+}
+
+// checkImageRepoCaller refuses an imageRepo override unless the request
+// comes from the CLI on this host. The API is also reachable from the Payram
+// container, which must not be able to make the updater run an image of its
+// choosing with the production secrets and data mounts.
+func checkImageRepoCaller(r *http.Request, source string) error {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if source != "CLI" || ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("imageRepo is only accepted from the CLI on this host (source CLI from a loopback address)")
+	}
+	return nil
+}
+
+// applyImageRepoOverride points a successful plan at imageRepo instead of
+// the manifest's repo, for testing a fork or private build. The override
+// has to be in ALLOWED_IMAGE_REPOS; with no allowlist no override is allowed.
+func (s *Server) applyImageRepoOverride(plan *UpgradePlan, imageRepo string) {
+	if imageRepo == "" || plan.State == jobs.JobStateFailed || plan.Manifest == nil {
+		return
+	}
+	logger.Warnf("Server", "applyImageRepoOverride", "Image repo overridden for this invocation: %s (manifest: %s)", imageRepo, plan.Manifest.Image.Repo)
+	plan.ImageRepoOverride = imageRepo
+	plan.Manifest.Image.Repo = imageRepo
+	switch {
+	case len(s.config.AllowedImageRepos) == 0:
+		plan.State = jobs.JobStateFailed
+		plan.FailureCode = "IMAGE_REPO_NOT_ALLOWED"
+		plan.Message = fmt.Sprintf("Image repo override %q refused: set ALLOWED_IMAGE_REPOS to the repos overrides may use", imageRepo)
+	case !isImageRepoAllowed(imageRepo, s.config.AllowedImageRepos):
+		plan.State = jobs.JobStateFailed
+		plan.FailureCode = "IMAGE_REPO_NOT_ALLOWED"
+		plan.Message = fmt.Sprintf("Image repo %q is not in ALLOWED_IMAGE_REPOS (%s)", imageRepo, strings.Join(s.config.AllowedImageRepos, ", "))
+	}
+}
+
+// checkDockerVersion fails a successful plan with DOCKER_TOO_OLD when the
+// Docker daemon is older than the manifest's min_docker_version. A version
+// that cannot be read or parsed only adds a warning.
+func (s *Server) checkDockerVersion(ctx context.Context, plan *UpgradePlan) {
+	if plan.State == jobs.JobStateFailed || plan.Manifest == nil || plan.Manifest.MinDockerVersion == "" {
+		return
+	}
+	minVersion, err := goversion.NewVersion(strings.TrimPrefix(strings.TrimSpace(plan.Manifest.MinDockerVersion), "v"))
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Manifest min_docker_version %q is not a version; Docker version not checked", plan.Manifest.MinDockerVersion))
+		return
+	}
+	serverVersion, err := s.dockerRunner.ServerVersion(ctx)
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Could not read the Docker version to check min_docker_version %s: %v", plan.Manifest.MinDockerVersion, err))
+		return
+	}
+	current, err := goversion.NewVersion(serverVersion)
+	if err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("Docker version %q is not a version; min_docker_version %s not checked", serverVersion, plan.Manifest.MinDockerVersion))
+		return
+	}
+	if current.LessThan(minVersion) {
+		plan.State = jobs.JobStateFailed
+		plan.FailureCode = "DOCKER_TOO_OLD"
+		plan.Message = fmt.Sprintf("Docker %s is older than %s, the minimum the manifest requires", serverVersion, plan.Manifest.MinDockerVersion)
+	}
+}
+
+// isImageRepoAllowed reports whether repo matches an entry in allowed.
+// An empty allowlist permits any repo. Docker Hub prefixes are ignored so
+// "payramapp/payram" and "docker.io/payramapp/payram" are treated as equal.
+func isImageRepoAllowed(repo string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	repo = normalizeImageRepo(repo)
+	for _, candidate := range allowed {
+		if normalizeImageRepo(candidate) == repo {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeImageRepo(repo string) string {
+	repo = strings.ToLower(strings.TrimSpace(repo))
+	repo = strings.TrimPrefix(repo, "index.docker.io/")
+	repo = strings.TrimPrefix(repo, "docker.io/")
+	return strings.TrimSuffix(repo, "/")
+}
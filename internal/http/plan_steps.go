@@ -0,0 +1,57 @@
+package http
+
+import (
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/report"
+)
+
+// PlanStep is one step of an upgrade. The plan lists the steps and the
+// executor runs them under the same names: each is a phase of the upgrade
+// report and, while it runs, the job's Step.
+type PlanStep struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Destructive bool   `json:"destructive"` // stops or replaces the running container
+}
+
+// The upgrade steps, in the order executeUpgrade runs them.
+var (
+	stepResolveContainer = PlanStep{Name: "resolve_container", Description: "Resolve the target container"}
+	stepPrepare          = PlanStep{Name: "prepare", Description: "Read the running container's configuration and build the new container's arguments"}
+	stepPreflight        = PlanStep{Name: "preflight", Description: "Check Docker, disk space and volumes, and save a pre-upgrade snapshot"}
+	stepPullStepping     = PlanStep{Name: "pull_stepping_stone", Description: "Pull the stepping stone image"}
+	stepBackup           = PlanStep{Name: "backup", Description: "Quiesce supervisor programs and back up the database"}
+	stepReplaceStepping  = PlanStep{Name: "replace_stepping_stone", Description: "Stop the container and run the stepping stone in its place", Destructive: true}
+	stepVerifyStepping   = PlanStep{Name: "verify_stepping_stone", Description: "Verify the stepping stone: container running, health endpoint, version"}
+	stepPull             = PlanStep{Name: "pull", Description: "Pull the target image"}
+	stepReplace          = PlanStep{Name: "replace", Description: "Replace the container with the target version (hot swap when the manifest allows it)", Destructive: true}
+	stepVerify           = PlanStep{Name: "verify", Description: "Verify the new container: running, health endpoint, version matching the target"}
+	stepFinalize         = PlanStep{Name: "finalize", Description: "Mark the upgrade complete and prune old images"}
+)
+
+// upgradeSteps returns the steps of an upgrade through steppingStone, if set,
+// to its target. A dry run stops after stepPrepare and logs the rest.
+func upgradeSteps(steppingStone string) []PlanStep {
+	if steppingStone != "" {
+		// The backup is taken once, before the first hop, and covers both
+		return []PlanStep{
+			stepResolveContainer, stepPrepare, stepPreflight, stepPullStepping, stepBackup,
+			stepReplaceStepping, stepVerifyStepping, stepPull, stepReplace, stepVerify, stepFinalize,
+		}
+	}
+	return []PlanStep{
+		stepResolveContainer, stepPrepare, stepPreflight, stepPull, stepBackup,
+		stepReplace, stepVerify, stepFinalize,
+	}
+}
+
+// startStep starts step in the upgrade report's phases and records it as
+// the job's current step.
+func (s *Server) startStep(job *jobs.Job, phases *report.PhaseTimer, step PlanStep) {
+	phases.Start(step.Name)
+	job.Step = step.Name
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/report"
+)
+
+func stepNames(steps []PlanStep) []string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Name
+	}
+	return names
+}
+
+func TestHandleUpgradePlan_ReturnsSteps(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"requestedTarget":"1.1.0","currentVersion":"1.0.0","source":"CLI","mode":"manual"}`)
+	s.HandleUpgradePlan()(w, httptest.NewRequest(http.MethodPost, "/upgrade/plan", body))
+
+	var resp PlanResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.FailureCode != "" {
+		t.Fatalf("expected a successful plan, got %s (%s)", resp.FailureCode, resp.Message)
+	}
+	want := "resolve_container prepare preflight pull backup replace verify finalize"
+	if got := strings.Join(stepNames(resp.Steps), " "); got != want {
+		t.Errorf("expected steps %q, got %q", want, got)
+	}
+	for _, step := range resp.Steps {
+		if step.Description == "" {
+			t.Errorf("expected a description for step %s", step.Name)
+		}
+		if step.Destructive != (step.Name == "replace") {
+			t.Errorf("unexpected destructive=%v for step %s", step.Destructive, step.Name)
+		}
+	}
+}
+
+func TestUpgradeSteps_SteppingStoneHopsFirst(t *testing.T) {
+	want := "resolve_container prepare preflight pull_stepping_stone backup replace_stepping_stone verify_stepping_stone pull replace verify finalize"
+	if got := strings.Join(stepNames(upgradeSteps("1.1.0")), " "); got != want {
+		t.Errorf("expected steps %q, got %q", want, got)
+	}
+}
+
+func TestExecuteUpgrade_RunsThePlannedSteps(t *testing.T) {
+	s, jobStore, _ := newCancelTestServer(t, 0, "none")
+	s.config.ReportDir = filepath.Join(t.TempDir(), "reports")
+	s.config.ReportFormat = report.FormatJSON
+	job, done := startCancelTestJob(t, s, jobStore)
+	<-done
+
+	// The fake docker has no database to back up, so the upgrade stops
+	// part-way; every phase it ran must be the planned step at that position
+	planned := stepNames(upgradeSteps(""))
+	phases := readUpgradeReport(t, s.config.ReportDir, job.JobID).Phases
+	if len(phases) < 4 {
+		t.Fatalf("expected the upgrade to get past the pull, got phases %+v (%s: %s)", phases, job.FailureCode, job.Message)
+	}
+	for i, phase := range phases {
+		if i >= len(planned) || phase.Name != planned[i] {
+			t.Fatalf("phase %d is %s, planned steps are %v", i, phase.Name, planned)
+		}
+	}
+	if job.Step != phases[len(phases)-1].Name {
+		t.Errorf("expected the job's step to be the last phase run, got %q", job.Step)
+	}
+}
+
+func TestExecuteDryRun_LogsThePlannedSteps(t *testing.T) {
+	s, jobStore, _ := newCancelTestServer(t, 0, "none")
+	s.config.ExecutionMode = "dry-run"
+	_, done := startCancelTestJob(t, s, jobStore)
+	<-done
+
+	logs, _ := jobStore.ReadLogs()
+	for _, step := range upgradeSteps("") {
+		if !strings.Contains(logs, step.Name+": "+step.Description) {
+			t.Errorf("expected the dry run to log step %s, got:\n%s", step.Name, logs)
+		}
+	}
+	if !strings.Contains(logs, "replace: ") || !strings.Contains(logs, "(destructive)") {
+		t.Errorf("expected the destructive step to be flagged, got:\n%s", logs)
+	}
+}
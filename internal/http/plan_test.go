@@ -451,6 +451,103 @@ func TestPlanUpgrade_LatestResolution(t *testing.T) {
 	}
 }
 
+// TestPlanUpgrade_LatestStrategies verifies strategy targets and
+// LATEST_STRATEGY resolve through the policy resolver.
+func TestPlanUpgrade_LatestStrategies(t *testing.T) {
+	releases := []string{"1.0.0", "1.0.1", "1.0.2", "1.1.0", "1.2.0"}
+	stopPoints := []map[string]string{{"version": "1.2.0", "reason": "Manual migration.", "docs": "https://docs.example.com/1.2.0"}}
+	manifestPath := buildManifestFile(t)
+	policyPath := buildPolicyFileWithStopPoints(t, "1.2.0", releases, nil, stopPoints)
+
+	tests := []struct {
+		name            string
+		latestStrategy  string
+		requestedTarget string
+		wantResolved    string
+	}{
+		{"latest-patch target", "", "latest-patch", "1.0.2"},
+		{"latest-dashboard target", "", "latest-dashboard", "1.1.0"},
+		{"latest with LATEST_STRATEGY=latest-patch", "latest-patch", "latest", "1.0.2"},
+		{"explicit strategy overrides LATEST_STRATEGY", "latest-patch", "latest-dashboard", "1.1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, policyPath, manifestPath)
+			srv.config.LatestStrategy = tt.latestStrategy
+
+			plan := srv.PlanUpgrade(context.Background(), jobs.JobModeManual, tt.requestedTarget, "1.0.0")
+			if plan.State != jobs.JobStateReady {
+				t.Fatalf("expected Ready, got %q %s (%s)", plan.State, plan.FailureCode, plan.Message)
+			}
+			if plan.ResolvedTarget != tt.wantResolved {
+				t.Errorf("expected resolvedTarget %s, got %q", tt.wantResolved, plan.ResolvedTarget)
+			}
+		})
+	}
+
+	// latest-patch needs the running version
+	srv := newTestServer(t, policyPath, manifestPath)
+	plan := srv.PlanUpgrade(context.Background(), jobs.JobModeManual, "latest-patch", "")
+	if plan.State != jobs.JobStateFailed || plan.FailureCode != "LATEST_UNRESOLVED" {
+		t.Errorf("expected LATEST_UNRESOLVED without a running version, got %q %q (%s)", plan.State, plan.FailureCode, plan.Message)
+	}
+}
+
+// TestPlanUpgrade_UnknownTargetVersion verifies a dashboard target missing
+// from the policy releases fails the plan and names the available versions.
+func TestPlanUpgrade_UnknownTargetVersion(t *testing.T) {
+	releases := []string{"1.7.0", "1.7.5", "1.7.8"}
+	manifestPath := buildManifestFile(t)
+	policyPath := buildPolicyFile(t, "1.7.8", releases, nil)
+	srv := newTestServer(t, policyPath, manifestPath)
+
+	plan := srv.PlanUpgrade(context.Background(), jobs.JobModeDashboard, "1.7.9", "1.7.0")
+
+	if plan.State != jobs.JobStateFailed || plan.FailureCode != "UNKNOWN_TARGET_VERSION" {
+		t.Fatalf("expected UNKNOWN_TARGET_VERSION, got %q %q (%s)", plan.State, plan.FailureCode, plan.Message)
+	}
+	if !strings.Contains(plan.Message, "1.7.9") || !strings.Contains(plan.Message, "1.7.0, 1.7.5, 1.7.8") {
+		t.Errorf("expected the message to name the target and the releases, got %q", plan.Message)
+	}
+
+	// A leading "v" on the target still matches the release
+	plan = srv.PlanUpgrade(context.Background(), jobs.JobModeDashboard, "v1.7.8", "1.7.0")
+	if plan.State != jobs.JobStateReady {
+		t.Errorf("expected v1.7.8 to match release 1.7.8, got %q (%s)", plan.State, plan.Message)
+	}
+}
+
+// TestPlanUpgrade_UnknownTargetVersionManualWarns verifies manual mode only
+// warns about a target missing from the policy releases.
+func TestPlanUpgrade_UnknownTargetVersionManualWarns(t *testing.T) {
+	releases := []string{"1.7.0", "1.7.8"}
+	manifestPath := buildManifestFile(t)
+	policyPath := buildPolicyFile(t, "1.7.8", releases, nil)
+	srv := newTestServer(t, policyPath, manifestPath)
+
+	plan := srv.PlanUpgrade(context.Background(), jobs.JobModeManual, "1.7.9", "1.7.0")
+
+	if plan.State != jobs.JobStateReady {
+		t.Fatalf("expected Ready, got %q (%s)", plan.State, plan.Message)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "not in the policy releases") {
+		t.Errorf("expected a warning about the unlisted target, got %v", plan.Warnings)
+	}
+
+	plan = srv.PlanUpgrade(context.Background(), jobs.JobModeManual, "1.7.8", "1.7.0")
+	if len(plan.Warnings) != 0 {
+		t.Errorf("expected no warnings for a listed target, got %v", plan.Warnings)
+	}
+}
+
+func TestFormatReleaseList_Truncates(t *testing.T) {
+	releases := []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0", "1.5.0", "1.6.0", "1.7.0", "1.8.0", "1.9.0", "1.10.0", "1.11.0"}
+	got := formatReleaseList(releases)
+	if !strings.HasPrefix(got, "1.0.0, 1.1.0") || !strings.HasSuffix(got, "1.9.0 and 2 more") {
+		t.Errorf("unexpected release list %q", got)
+	}
+}
+
 // TestHandleUpgradePlan_CurrentVersionWiredThrough verifies the HTTP handler reads
 // currentVersion from the request body and forwards it to PlanUpgrade so that
 // breakpoint capping works end-to-end over the API.
@@ -564,3 +661,199 @@ func TestHandleUpgradeRun_CurrentVersionWiredThrough(t *testing.T) {
 		t.Errorf("expected job resolvedTarget 1.8.0, got %q", job.ResolvedTarget)
 	}
 }
+
+// TestPlanUpgrade_ImageRepoNotAllowed verifies a manifest repo outside
+// ALLOWED_IMAGE_REPOS fails the plan before any version resolution.
+func TestPlanUpgrade_ImageRepoNotAllowed(t *testing.T) {
+	releases := []string{"1.0.0", "1.1.0"}
+	manifestPath := buildManifestFile(t)
+	policyPath := buildPolicyFile(t, "1.1.0", releases, nil)
+	srv := newTestServer(t, policyPath, manifestPath)
+	srv.config.AllowedImageRepos = []string{"payramapp/payram-staging"}
+
+	plan := srv.PlanUpgrade(context.Background(), jobs.JobModeDashboard, "1.1.0", "1.0.0")
+
+	if plan.State != jobs.JobStateFailed {
+		t.Fatalf("expected Failed, got %q (%s)", plan.State, plan.Message)
+	}
+	if plan.FailureCode != "IMAGE_REPO_NOT_ALLOWED" {
+		t.Errorf("expected IMAGE_REPO_NOT_ALLOWED, got %q", plan.FailureCode)
+	}
+	if !strings.Contains(plan.Message, "payramapp/payram") {
+		t.Errorf("expected message to name the rejected repo, got %q", plan.Message)
+	}
+}
+
+// TestPlanUpgrade_ImageRepoAllowed verifies an allowlisted repo proceeds,
+// including when the allowlist entry carries a docker.io prefix.
+func TestPlanUpgrade_ImageRepoAllowed(t *testing.T) {
+	releases := []string{"1.0.0", "1.1.0"}
+	manifestPath := buildManifestFile(t)
+	policyPath := buildPolicyFile(t, "1.1.0", releases, nil)
+	srv := newTestServer(t, policyPath, manifestPath)
+	srv.config.AllowedImageRepos = []string{"payramapp/payram-staging", "docker.io/payramapp/payram"}
+
+	plan := srv.PlanUpgrade(context.Background(), jobs.JobModeDashboard, "1.1.0", "1.0.0")
+
+	if plan.State != jobs.JobStateReady {
+		t.Errorf("expected Ready, got %q (%s)", plan.State, plan.Message)
+	}
+	if plan.ResolvedTarget != "1.1.0" {
+		t.Errorf("expected resolvedTarget 1.1.0, got %q", plan.ResolvedTarget)
+	}
+}
+
+// TestPlanUpgrade_ImageRepoOverrideChecked verifies IMAGE_REPO_OVERRIDE is
+// subject to the allowlist as well, since it is the repo actually pulled.
+func TestPlanUpgrade_ImageRepoOverrideChecked(t *testing.T) {
+	releases := []string{"1.0.0", "1.1.0"}
+	manifestPath := buildManifestFile(t)
+	policyPath := buildPolicyFile(t, "1.1.0", releases, nil)
+	srv := newTestServer(t, policyPath, manifestPath)
+	srv.config.ImageRepoOverride = "payramapp/payram-dummy"
+	srv.config.AllowedImageRepos = []string{"payramapp/payram"}
+
+	plan := srv.PlanUpgrade(context.Background(), jobs.JobModeDashboard, "1.1.0", "1.0.0")
+
+	if plan.FailureCode != "IMAGE_REPO_NOT_ALLOWED" {
+		t.Errorf("expected IMAGE_REPO_NOT_ALLOWED for overridden repo, got %q (%s)", plan.FailureCode, plan.Message)
+	}
+}
+
+// TestPlanUpgrade_EnvOverridesConflictingWithManifestWarn verifies a plan
+// warns, naming both values, when IMAGE_REPO_OVERRIDE or
+// TARGET_CONTAINER_NAME contradicts the manifest, and that the environment
+// wins.
+func TestPlanUpgrade_EnvOverridesConflictingWithManifestWarn(t *testing.T) {
+	releases := []string{"1.0.0", "1.1.0"}
+	manifestPath := buildManifestFile(t)
+	policyPath := buildPolicyFile(t, "1.1.0", releases, nil)
+
+	tests := []struct {
+		name          string
+		repoOverride  string
+		containerName string
+		wantWarnings  []string
+	}{
+		{"no overrides", "", "", nil},
+		{"overrides matching the manifest", "payramapp/payram", "payram-core", nil},
+		{"image repo differs", "payramapp/payram-dummy", "", []string{"IMAGE_REPO_OVERRIDE payramapp/payram-dummy overrides the manifest's image repo payramapp/payram"}},
+		{"container name differs", "", "payram-prod", []string{"TARGET_CONTAINER_NAME payram-prod overrides the manifest's container_name payram-core"}},
+		{"both differ", "payramapp/payram-dummy", "payram-prod", []string{"IMAGE_REPO_OVERRIDE", "TARGET_CONTAINER_NAME"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, policyPath, manifestPath)
+			srv.config.ImageRepoOverride = tt.repoOverride
+			srv.config.TargetContainerName = tt.containerName
+
+			plan := srv.PlanUpgrade(context.Background(), jobs.JobModeDashboard, "1.1.0", "1.0.0")
+			if plan.State != jobs.JobStateReady {
+				t.Fatalf("expected Ready, got %q (%s)", plan.State, plan.Message)
+			}
+			if len(plan.Warnings) != len(tt.wantWarnings) {
+				t.Fatalf("expected %d warnings, got %v", len(tt.wantWarnings), plan.Warnings)
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(plan.Warnings[i], want) {
+					t.Errorf("expected warning %d to contain %q, got %q", i, want, plan.Warnings[i])
+				}
+			}
+			if tt.repoOverride != "" && plan.Manifest.Image.Repo != tt.repoOverride {
+				t.Errorf("expected the override to win, got repo %q", plan.Manifest.Image.Repo)
+			}
+		})
+	}
+}
+
+func TestCheckDockerVersion(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	manifestJSON := strings.Replace(minimalManifest, `"image"`, `"min_docker_version": "25.0.0", "image"`, 1)
+	if err := os.WriteFile(manifestPath, []byte(manifestJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		script          string
+		wantFailureCode string
+		wantWarning     string
+	}{
+		{"too old", "echo 24.0.7", "DOCKER_TOO_OLD", ""},
+		{"new enough", "echo 25.0.3", "", ""},
+		{"distribution suffix", "echo 26.1.5+dfsg1", "", ""},
+		{"unreadable", "echo 'Cannot connect to the Docker daemon' >&2; exit 1", "", "Could not read the Docker version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dockerBin := filepath.Join(t.TempDir(), "docker")
+			if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n"+tt.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			server := New(&config.Config{
+				PolicyURL:           buildPolicyFile(t, "1.2.0", []string{"1.2.0"}, nil),
+				RuntimeManifestURL:  manifestPath,
+				FetchTimeoutSeconds: 5,
+				DockerBin:           dockerBin,
+			}, jobs.NewStore(t.TempDir()))
+
+			plan := server.PlanUpgrade(context.Background(), jobs.JobModeManual, "1.2.0", "")
+			server.checkDockerVersion(context.Background(), plan)
+
+			if plan.FailureCode != tt.wantFailureCode {
+				t.Fatalf("expected failure code %q, got %q (%s)", tt.wantFailureCode, plan.FailureCode, plan.Message)
+			}
+			if tt.wantFailureCode != "" && !strings.Contains(plan.Message, "Docker 24.0.7 is older than 25.0.0") {
+				t.Errorf("expected both versions in the message, got %q", plan.Message)
+			}
+			if tt.wantWarning != "" && (len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], tt.wantWarning)) {
+				t.Errorf("expected a warning containing %q, got %v", tt.wantWarning, plan.Warnings)
+			}
+			if tt.wantWarning == "" && len(plan.Warnings) != 0 {
+				t.Errorf("expected no warnings, got %v", plan.Warnings)
+			}
+		})
+	}
+}
+
+func TestHandleUpgradePlan_DockerTooOld(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	manifestJSON := strings.Replace(minimalManifest, `"image"`, `"min_docker_version": "25.0.0", "image"`, 1)
+	if err := os.WriteFile(manifestPath, []byte(manifestJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\n[ \"$1\" = info ] && echo 20.10.24\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	server := New(&config.Config{
+		PolicyURL:           buildPolicyFile(t, "1.2.0", []string{"1.2.0"}, nil),
+		RuntimeManifestURL:  manifestPath,
+		FetchTimeoutSeconds: 5,
+		DockerBin:           dockerBin,
+	}, jobs.NewStore(t.TempDir()))
+
+	for _, path := range []string{"/upgrade/plan", "/upgrade/run"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"mode":"MANUAL","requestedTarget":"1.2.0","currentVersion":"1.1.0"}`))
+		rec := httptest.NewRecorder()
+		if path == "/upgrade/plan" {
+			server.HandleUpgradePlan()(rec, req)
+		} else {
+			server.HandleUpgradeRun()(rec, req)
+		}
+
+		var resp struct {
+			State       string `json:"state"`
+			FailureCode string `json:"failureCode"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", path, err)
+		}
+		if resp.State != "FAILED" || resp.FailureCode != "DOCKER_TOO_OLD" {
+			t.Errorf("%s: expected FAILED with DOCKER_TOO_OLD, got %s %s", path, resp.State, resp.FailureCode)
+		}
+	}
+	if job, _ := server.jobStore.LoadLatest(); job != nil {
+		t.Errorf("expected no job to be created, got %s", job.JobID)
+	}
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/report"
+)
+
+// writeUpgradeReport writes the report of a finished upgrade to REPORT_DIR.
+// It does nothing when REPORT_DIR is unset, and a write failure is only
+// logged: the job's outcome is already final.
+func (s *Server) writeUpgradeReport(job *jobs.Job, outcome, fromVersion, steppingStone string, startedAt time.Time, phases []report.Phase) {
+	if s.config.ReportDir == "" {
+		return
+	}
+
+	finishedAt := time.Now().UTC()
+	r := &report.Report{
+		JobID:           job.JobID,
+		Mode:            string(job.Mode),
+		RequestedTarget: job.RequestedTarget,
+		ResolvedTarget:  job.ResolvedTarget,
+		SteppingStone:   steppingStone,
+		FromVersion:     fromVersion,
+		ExecutionMode:   s.config.ExecutionMode,
+		Outcome:         outcome,
+		State:           string(job.State),
+		FailureCode:     job.FailureCode,
+		Message:         job.Message,
+		StartedAt:       startedAt.UTC(),
+		FinishedAt:      finishedAt,
+		DurationSeconds: finishedAt.Sub(startedAt).Seconds(),
+		Phases:          phases,
+		BackupPath:      job.BackupPath,
+		Warnings:        job.Warnings,
+	}
+	if job.BackupPath != "" {
+		checksum, err := backup.FileChecksum(job.BackupPath)
+		if err != nil {
+			s.jobStore.AppendLog(fmt.Sprintf("Warning: failed to checksum backup for the upgrade report: %v", err))
+		}
+		r.BackupChecksum = checksum
+	}
+
+	path, err := report.Write(s.config.ReportDir, s.config.ReportFormat, r)
+	if err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Warning: failed to write upgrade report: %v", err))
+		return
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Upgrade report written: %s", path))
+}
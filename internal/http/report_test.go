@@ -0,0 +1,85 @@
+package http
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/report"
+)
+
+func readUpgradeReport(t *testing.T, dir, jobID string) report.Report {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "upgrade-"+jobID+".json"))
+	if err != nil {
+		t.Fatalf("expected an upgrade report: %v", err)
+	}
+	var r report.Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	return r
+}
+
+func TestExecuteUpgrade_WritesReportOnSuccess(t *testing.T) {
+	s, jobStore, _ := newCancelTestServer(t, 0, "pull")
+	s.config.ExecutionMode = "dry-run"
+	s.config.ReportDir = filepath.Join(t.TempDir(), "reports")
+	s.config.ReportFormat = report.FormatJSON
+	job, done := startCancelTestJob(t, s, jobStore)
+	<-done
+
+	r := readUpgradeReport(t, s.config.ReportDir, job.JobID)
+	if r.Outcome != "validated" || r.State != string(jobs.JobStateReady) || r.FailureCode != "" {
+		t.Errorf("expected a validated READY report, got %+v", r)
+	}
+	if r.ResolvedTarget != "1.1.0" || r.FromVersion != "1.0.0" || r.ExecutionMode != "dry-run" {
+		t.Errorf("unexpected job summary in report: %+v", r)
+	}
+	if len(r.Phases) == 0 || r.Phases[len(r.Phases)-1].Name != "dry_run" {
+		t.Errorf("expected phases ending with dry_run, got %+v", r.Phases)
+	}
+	if r.FinishedAt.Before(r.StartedAt) {
+		t.Errorf("expected finish after start, got %v → %v", r.StartedAt, r.FinishedAt)
+	}
+}
+
+func TestExecuteUpgrade_WritesReportOnFailure(t *testing.T) {
+	s, jobStore := newFinalizeTestServer(t, "#!/bin/sh\necho 'Error: No such object: payram' >&2\nexit 1\n")
+	s.config.TargetContainerName = "payram"
+	s.config.ExecutionMode = "execute"
+	s.config.ReportDir = t.TempDir()
+	s.config.ReportFormat = report.FormatJSON
+
+	job := jobs.NewJob("job-fail", jobs.JobModeManual, "1.1.0")
+	job.ResolvedTarget = "1.1.0"
+	jobStore.Save(job)
+	s.executeUpgrade(job, &manifest.Manifest{Image: manifest.Image{Repo: "payramapp/payram"}}, nil, "")
+
+	if job.State != jobs.JobStateFailed {
+		t.Fatalf("expected the upgrade to fail, got %s", job.State)
+	}
+	r := readUpgradeReport(t, s.config.ReportDir, job.JobID)
+	if r.Outcome != "failed" || r.FailureCode != job.FailureCode || r.Message != job.Message {
+		t.Errorf("expected a failed report matching the job, got %+v (job %s: %s)", r, job.FailureCode, job.Message)
+	}
+	if len(r.Phases) == 0 {
+		t.Error("expected the phases reached before the failure")
+	}
+}
+
+func TestExecuteUpgrade_NoReportWhenUnconfigured(t *testing.T) {
+	s, jobStore, _ := newCancelTestServer(t, 0, "pull")
+	s.config.ExecutionMode = "dry-run"
+	_, done := startCancelTestJob(t, s, jobStore)
+	<-done
+
+	logs, _ := jobStore.ReadLogs()
+	if strings.Contains(logs, "Upgrade report written") {
+		t.Errorf("expected no report without REPORT_DIR, got logs:\n%s", logs)
+	}
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
+	"github.com/payram/payram-updater/internal/orchestrator"
+)
+
+// markAlreadyOnTarget reports whether the running version is already the
+// plan's resolved target, in which case an upgrade would only stop, remove
+// and re-run the same image. It marks the plan so callers can return a no-op
+// result instead. A per-invocation image repo counts as a different image.
+func markAlreadyOnTarget(plan *UpgradePlan, currentVersion string) bool {
+	if currentVersion == "" || plan.ImageRepoOverride != "" {
+		return false
+	}
+	normalize := func(v string) string {
+		return strings.TrimPrefix(baseVersionTag(strings.TrimSpace(v)), "v")
+	}
+	if normalize(currentVersion) != normalize(plan.ResolvedTarget) {
+		return false
+	}
+	plan.AlreadyOnTarget = true
+	plan.Message = fmt.Sprintf("Already on version %s; nothing to upgrade. Use --force to reinstall it, or --restart-only to restart the container.", currentVersion)
+	return true
+}
+
+// startRestartJob creates a restart-only job and runs it in the background:
+// the running container is restarted in place, on its current image, and its
+// health verified. Nothing is pulled, backed up or recreated.
+func (s *Server) startRestartJob(mode jobs.JobMode, source string) (*jobs.Job, error) {
+	jobID := fmt.Sprintf("job-%d", time.Now().UnixNano())
+	job := jobs.NewJob(jobID, mode, "")
+	job.State = jobs.JobStateReady
+	job.Message = jobs.MessageJobCreated
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.jobStore.Save(job); err != nil {
+		return nil, err
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Starting upgrade job %s: mode=%s restart-only (no version change) source=%s", jobID, mode, source))
+
+	go s.runQueued(job, orchestrator.KindRestart, func() { s.executeRestart(job) })
+	return job, nil
+}
+
+// executeRestart restarts the target container without changing its version.
+// There is no point of no return to guard: 'docker restart' is the only
+// destructive step, so the job cannot be cancelled.
+func (s *Server) executeRestart(job *jobs.Job) {
+	s.upgrades.Add(1)
+	defer s.upgrades.Done()
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout := time.Duration(s.config.UpgradeTimeoutSeconds) * time.Second; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout+s.verifyWindow)
+	}
+	defer cancel()
+
+	containerName := "" // set once resolved, for the failure log capture below
+	s.recordHistory(history.Event{
+		Type:    "restart",
+		Status:  "started",
+		Message: "Restart started",
+		Data:    map[string]string{"jobId": job.JobID, "mode": string(job.Mode)},
+	})
+	defer func() {
+		status := "failed"
+		data := map[string]string{"jobId": job.JobID, "mode": string(job.Mode), "version": job.ResolvedTarget}
+		if job.State == jobs.JobStateReady {
+			status = "succeeded"
+		} else if job.FailureCode != "" {
+			data["failureCode"] = job.FailureCode
+		}
+		if job.State == jobs.JobStateFailed {
+			s.saveFailureContainerLogs(job, containerName)
+		}
+		if job.ContainerLogPath != "" {
+			data["containerLog"] = job.ContainerLogPath
+		}
+		s.recordHistory(history.Event{Type: "restart", Status: status, Message: job.Message, Data: data})
+	}()
+
+	unlock, err := s.lockOperation("restart "+job.JobID, true)
+	if err != nil {
+		code, message := operationLockFailure(err)
+		s.failRestart(job, code, message)
+		return
+	}
+	defer unlock()
+
+	containerName, err = s.discoverContainerName(ctx)
+	if err != nil {
+		s.failRestart(job, "CONTAINER_NAME_UNRESOLVED", fmt.Sprintf("Failed to find the Payram container: %v", err))
+		return
+	}
+	state, err := container.NewInspector(s.config.DockerBin, logger.StdLogger()).ExtractRuntimeState(ctx, containerName)
+	if err != nil {
+		s.failRestart(job, "RUNTIME_INSPECTION_FAILED", fmt.Sprintf("Failed to inspect container %s: %v", containerName, err))
+		return
+	}
+	job.RequestedTarget = state.ImageTag
+	job.ResolvedTarget = state.ImageTag
+
+	job.State = jobs.JobStateExecuting
+	job.Message = "Restarting container"
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("Restarting container %s on %s (no version change)", containerName, state.Image))
+
+	if err := s.dockerRunner.Restart(ctx, containerName); err != nil {
+		s.failRestart(job, "DOCKER_ERROR", fmt.Sprintf("Failed to restart container: %v", err))
+		return
+	}
+	s.jobStore.AppendLog("Container restarted")
+
+	job.State = jobs.JobStateVerifying
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	if !s.verifyUpgrade(ctx, job, containerName, state.ImageTag, s.fetchPolicyInitVersion(ctx)) {
+		return
+	}
+
+	job.State = jobs.JobStateReady
+	job.Message = fmt.Sprintf("Container restarted on %s (no version change)", state.ImageTag)
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("SUCCESS: %s", job.Message))
+}
+
+// failRestart marks a restart-only job failed.
+func (s *Server) failRestart(job *jobs.Job, code, message string) {
+	job.State = jobs.JobStateFailed
+	job.FailureCode = code
+	job.Message = message
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/coreclient"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+func TestMarkAlreadyOnTarget(t *testing.T) {
+	tests := []struct {
+		name           string
+		currentVersion string
+		resolved       string
+		imageRepo      string
+		want           bool
+	}{
+		{"same version", "1.2.0", "1.2.0", "", true},
+		{"v prefix", "v1.2.0", "1.2.0", "", true},
+		{"arch suffix", "1.2.0-arm64", "1.2.0", "", true},
+		{"different version", "1.1.0", "1.2.0", "", false},
+		{"unknown current version", "", "1.2.0", "", false},
+		{"image repo override", "1.2.0", "1.2.0", "ghcr.io/acme/payram", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &UpgradePlan{State: jobs.JobStateReady, ResolvedTarget: tt.resolved, ImageRepoOverride: tt.imageRepo}
+
+			if got := markAlreadyOnTarget(plan, tt.currentVersion); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			if plan.AlreadyOnTarget != tt.want {
+				t.Errorf("expected plan.AlreadyOnTarget=%v", tt.want)
+			}
+			if tt.want && !strings.Contains(plan.Message, "Already on version") {
+				t.Errorf("expected an already-on-version message, got %q", plan.Message)
+			}
+		})
+	}
+}
+
+// postRun sends body to HandleUpgradeRun and decodes the response.
+func postRun(t *testing.T, s *Server, body string) RunResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.HandleUpgradeRun()(w, httptest.NewRequest(http.MethodPost, "/upgrade/run", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp RunResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp
+}
+
+// waitForJobDone polls the job store until the latest job has finished, then
+// waits for its goroutine so it does not outlive the test.
+func waitForJobDone(t *testing.T, s *Server) *jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, _ := s.jobStore.LoadLatest()
+		if job != nil && !isJobActive(job) && job.Message != jobs.MessageJobCreated {
+			s.upgrades.Wait()
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("job never finished")
+	return nil
+}
+
+func TestHandleUpgradeRun_AlreadyOnTargetIsNoOp(t *testing.T) {
+	s := newHealthTestServer(t)
+
+	resp := postRun(t, s, `{"mode":"manual","requestedTarget":"1.1.0","currentVersion":"1.1.0","source":"CLI"}`)
+
+	if !resp.AlreadyOnTarget || resp.JobID != "" {
+		t.Fatalf("expected a no-op result without a job, got %+v", resp)
+	}
+	if !strings.Contains(resp.Message, "Already on version 1.1.0") {
+		t.Errorf("expected an already-on-version message, got %q", resp.Message)
+	}
+	if job, _ := s.jobStore.LoadLatest(); job != nil {
+		t.Errorf("expected no job to be created, got %s", job.JobID)
+	}
+}
+
+func TestHandleUpgradeRun_ForceUpgradesToRunningVersion(t *testing.T) {
+	s, _, callLog := newCancelTestServer(t, 0, "pull")
+	s.config.PolicyURL = buildPolicyFile(t, "1.1.0", []string{"1.0.0", "1.1.0"}, nil)
+	s.config.RuntimeManifestURL = buildManifestFile(t)
+
+	resp := postRun(t, s, `{"mode":"manual","requestedTarget":"1.1.0","currentVersion":"1.1.0","source":"CLI","force":true}`)
+
+	if resp.AlreadyOnTarget || resp.JobID == "" {
+		t.Fatalf("expected --force to start a job, got %+v", resp)
+	}
+	waitForPull(t, callLog)
+	s.CancelUpgrade(errUpgradeCancelledByOperator)
+	waitForJobDone(t, s)
+}
+
+func TestHandleUpgradeRun_RestartOnly(t *testing.T) {
+	s, _, callLog := newCancelTestServer(t, 0, "none")
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/health":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/api/v1/version":
+			w.Write([]byte(`{"version":"1.0.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(core.Close)
+	s.coreClient = coreclient.NewClient(core.URL)
+	os.Remove(callLog)
+
+	resp := postRun(t, s, `{"mode":"manual","restartOnly":true,"source":"CLI"}`)
+	if resp.JobID == "" {
+		t.Fatalf("expected a restart job, got %+v", resp)
+	}
+	job := waitForJobDone(t, s)
+
+	if job.State != jobs.JobStateReady {
+		t.Fatalf("expected READY, got %s/%s (%s)", job.State, job.FailureCode, job.Message)
+	}
+	if job.ResolvedTarget != "1.0.0" || !strings.Contains(job.Message, "no version change") {
+		t.Errorf("expected a restart on the running version, got target %q: %s", job.ResolvedTarget, job.Message)
+	}
+	calls, _ := os.ReadFile(callLog)
+	if !strings.Contains(string(calls), "restart payram") {
+		t.Errorf("expected docker restart, got calls:\n%s", calls)
+	}
+	for _, destructive := range []string{"pull ", "rm ", "run ", "stop "} {
+		if strings.Contains(string(calls), "\n"+destructive) || strings.HasPrefix(string(calls), destructive) {
+			t.Errorf("expected no docker %s during a restart, got calls:\n%s", strings.TrimSpace(destructive), calls)
+		}
+	}
+}
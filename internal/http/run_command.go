@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/logger"
+)
+
+// plannedRunCommand builds the docker run command an upgrade to the plan's
+// resolved target would use for containerName, with env values redacted. It
+// inspects the container but changes nothing.
+func (s *Server) plannedRunCommand(ctx context.Context, containerName string, plan *UpgradePlan) (string, error) {
+	inspector := container.NewInspector(s.config.DockerBin, logger.StdLogger())
+	runtimeState, err := inspector.ExtractRuntimeState(ctx, containerName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", containerName, err)
+	}
+
+	imageTag, _ := s.targetImageTag(runtimeState.ImageTag, plan.ResolvedTarget, plan.ArchSupport)
+	builder := container.NewDockerRunBuilder(logger.StdLogger())
+	builder.AllowedExtraFlags = s.config.AllowedExtraRunFlags
+	dockerArgs, err := builder.BuildUpgradeArgs(runtimeState, plan.Manifest, imageTag)
+	if err != nil {
+		return "", fmt.Errorf("failed to build docker run args: %w", err)
+	}
+	return container.FormatRunCommand(dockerArgs), nil
+}
+
+// manifestDivergenceWarnings inspects containerName and returns a warning
+// for each manifest default that differs from it (see
+// container.DetectManifestDivergence), formatted as "CODE: message". It
+// changes nothing and returns nil if the container cannot be inspected.
+func (s *Server) manifestDivergenceWarnings(ctx context.Context, containerName string, plan *UpgradePlan) []string {
+	runtimeState, err := container.NewInspector(s.config.DockerBin, logger.StdLogger()).ExtractRuntimeState(ctx, containerName)
+	if err != nil {
+		logger.Warnf("Server", "manifestDivergenceWarnings", "Skipping manifest comparison: failed to inspect container %s: %v", containerName, err)
+		return nil
+	}
+	return formatManifestDivergence(container.DetectManifestDivergence(runtimeState, plan.Manifest))
+}
+
+func formatManifestDivergence(divergence []container.LayoutWarning) []string {
+	var warnings []string
+	for _, w := range divergence {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", w.Code, w.Message))
+	}
+	return warnings
+}
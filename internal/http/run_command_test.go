@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+func TestHandleUpgradePlan_PrintRunCommand(t *testing.T) {
+	s, _, callLog := newSyncTestServer(t, "execute")
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"requestedTarget":"1.1.0","currentVersion":"1.0.0","source":"CLI","printRunCommand":true}`)
+	s.HandleUpgradePlan()(w, httptest.NewRequest(http.MethodPost, "/upgrade/plan", body))
+
+	var resp PlanResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.FailureCode != "" || resp.RunCommandError != "" {
+		t.Fatalf("expected a run command, got %s %s (%s)", resp.FailureCode, resp.RunCommandError, resp.Message)
+	}
+	for _, want := range []string{"docker run ", "--name ", "-e 'POSTGRES_HOST=***'", ":1.1.0"} {
+		if !strings.Contains(resp.RunCommand, want) {
+			t.Errorf("expected run command to contain %q, got %s", want, resp.RunCommand)
+		}
+	}
+	if strings.Contains(resp.RunCommand, "localhost") {
+		t.Errorf("expected env values redacted, got %s", resp.RunCommand)
+	}
+	assertNoDestructiveDockerCalls(t, callLog)
+}
+
+func TestHandleUpgradePlan_NoRunCommandUnlessRequested(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "execute")
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"requestedTarget":"1.1.0","currentVersion":"1.0.0","source":"CLI"}`)
+	s.HandleUpgradePlan()(w, httptest.NewRequest(http.MethodPost, "/upgrade/plan", body))
+
+	var resp PlanResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.RunCommand != "" {
+		t.Errorf("expected no run command, got %s", resp.RunCommand)
+	}
+}
+
+func TestUpgrade_RecordsRedactedRunCommand(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+
+	_, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if !strings.HasPrefix(job.RunCommand, "docker run ") || !strings.Contains(job.RunCommand, "POSTGRES_HOST=***") {
+		t.Errorf("expected a redacted run command on the job, got %q", job.RunCommand)
+	}
+
+	events, err := s.historyStore.List(1, "upgrade", "validated")
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected the final history event, got %v (err=%v)", events, err)
+	}
+	if events[0].Data["runCommand"] != job.RunCommand {
+		t.Errorf("expected the run command in history, got %q", events[0].Data["runCommand"])
+	}
+}
+
+func TestHandleUpgradePlan_WarnsOnManifestDivergence(t *testing.T) {
+	s, _, callLog := newSyncTestServer(t, "execute")
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	divergent := `{"image":{"repo":"payramapp/payram"},"defaults":{"container_name":"payram-core","restart_policy":"unless-stopped","ports":[{"container":8080,"host":8080}]}}`
+	if err := os.WriteFile(manifestPath, []byte(divergent), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.config.RuntimeManifestURL = manifestPath
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"requestedTarget":"1.1.0","currentVersion":"1.0.0","source":"CLI"}`)
+	s.HandleUpgradePlan()(w, httptest.NewRequest(http.MethodPost, "/upgrade/plan", body))
+
+	var resp PlanResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.FailureCode != "" {
+		t.Fatalf("expected the plan to succeed, got %s (%s)", resp.FailureCode, resp.Message)
+	}
+	for _, code := range []string{"MANIFEST_NAME_DIFFERS", "MANIFEST_RESTART_POLICY_DIFFERS", "MANIFEST_PORT_DIFFERS"} {
+		found := false
+		for _, warning := range resp.Warnings {
+			found = found || strings.HasPrefix(warning, code+": ")
+		}
+		if !found {
+			t.Errorf("expected a %s warning, got %v", code, resp.Warnings)
+		}
+	}
+	assertNoDestructiveDockerCalls(t, callLog)
+}
+
+func TestUpgrade_RecordsManifestDivergenceOnJob(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+
+	_, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	// The test manifest names the container payram-core; the running one is payram
+	found := false
+	for _, warning := range job.Warnings {
+		found = found || strings.HasPrefix(warning, "MANIFEST_NAME_DIFFERS: ")
+	}
+	if !found {
+		t.Errorf("expected the divergence to be recorded on the job, got %v", job.Warnings)
+	}
+}
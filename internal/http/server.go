@@ -7,22 +7,34 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/bootstrap"
 	"github.com/payram/payram-updater/internal/config"
 	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/coreclient"
 	"github.com/payram/payram-updater/internal/corecompat"
+	"github.com/payram/payram-updater/internal/diskspace"
 	"github.com/payram/payram-updater/internal/dockerexec"
 	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/inspect"
 	"github.com/payram/payram-updater/internal/jobs"
 	"github.com/payram/payram-updater/internal/logger"
 	"github.com/payram/payram-updater/internal/manifest"
 	"github.com/payram/payram-updater/internal/network"
+	"github.com/payram/payram-updater/internal/orchestrator"
 	"github.com/payram/payram-updater/internal/policy"
+	"github.com/payram/payram-updater/internal/report"
+	"github.com/payram/payram-updater/internal/rollback"
+	"github.com/payram/payram-updater/internal/sdnotify"
+	"github.com/payram/payram-updater/internal/statelock"
+	"github.com/payram/payram-updater/internal/telemetry"
 )
 
 // discoverCoreBaseURL discovers the Payram Core base URL by:
@@ -67,6 +79,7 @@ func discoverCoreBaseURLByName(ctx context.Context, dockerBin string, containerN
 // Server represents the HTTP server.
 type Server struct {
 	httpServer          *http.Server
+	healthServer        *http.Server // HEALTH_PORT listener, nil when disabled
 	port                int
 	config              *config.Config
 	jobStore            *jobs.Store
@@ -75,6 +88,59 @@ type Server struct {
 	backupManager       *backup.Manager
 	containerBackupExec *backup.ContainerBackupExecutor
 	historyStore        *history.Store
+	lastGoodStore       *rollback.Store
+	// discoveryErr is why the core container could not be discovered at
+	// startup; nil when discovery succeeded.
+	discoveryErr error
+
+	// lastActivity is the UnixNano time of the last API request; now is
+	// the clock used for idle-timeout checks (nil means time.Now).
+	lastActivity atomic.Int64
+	now          func() time.Time
+
+	// cancelUpgrade cancels the in-flight upgrade while it is still safe to
+	// do so; upgrades tracks running upgrades so shutdown can wait for them.
+	upgradeMu     sync.Mutex
+	cancelUpgrade context.CancelCauseFunc
+	upgrades      sync.WaitGroup
+	// followersDone is closed on shutdown to end the open
+	// /upgrade/logs?follow=true streams, which would otherwise hold
+	// httpServer.Shutdown until its timeout.
+	followersDone     chan struct{}
+	stopFollowersOnce sync.Once
+	// verifyHeld are the VERIFY_PAUSE_PROGRAMS the new container was started
+	// with held (see holdProgramsForVerification). Only the upgrade goroutine
+	// uses it.
+	verifyHeld []string
+	// verifyWindow extends the upgrade deadline once per health verification,
+	// including the longest extension for a running migration.
+	verifyWindow time.Duration
+	// pullBackoff is the wait before the first image pull retry.
+	pullBackoff time.Duration
+	// healthInterval is the wait between health verification attempts.
+	healthInterval time.Duration
+	// lastScheduledBackup is when the last scheduled backup was taken; zero
+	// until it is first looked up in history.
+	lastScheduledBackup time.Time
+	// telemetry reports upgrade outcomes; nil when telemetry is disabled.
+	telemetry *telemetry.Reporter
+	// operations serializes upgrades, auto-updates and scheduled backups;
+	// nil unless SERIALIZE_OPERATIONS is set.
+	operations *orchestrator.Queue
+	// operationMu is held, with the restore lock, while an upgrade, restart
+	// or scheduled backup runs (see lockOperation).
+	operationMu sync.Mutex
+	// lastAutoUpdateCheck is the UnixNano time of the last auto-update
+	// check; 0 until the first one.
+	lastAutoUpdateCheck atomic.Int64
+	// summaryInspect caches the inspection behind GET /summary, taken at
+	// summaryInspectAt, so frequent polling does not re-run every check.
+	summaryMu        sync.Mutex
+	summaryInspect   *inspect.InspectResult
+	summaryInspectAt time.Time
+	// deviceOf reports the filesystem a path lives on, to tell whether
+	// backups share a disk with the database data.
+	deviceOf diskspace.DeviceFunc
 }
 
 // New creates a new HTTP server instance.
@@ -95,12 +161,14 @@ func New(cfg *config.Config, jobStore *jobs.Store) *Server {
 
 	var coreBaseURL string
 	var err error
+	var discoveryErr error
 	if cfg.TargetContainerName != "" {
 		// Use explicit container name if set
 		coreBaseURL, err = discoverCoreBaseURLByName(context.Background(), cfg.DockerBin, cfg.TargetContainerName)
 		if err != nil {
 			logger.Error("Server", "New", err)
 			logger.Warnf("Server", "New", "Falling back to http://127.0.0.1:8080 (this may not work if Payram Core is on a different port)")
+			discoveryErr = err
 			coreBaseURL = "http://127.0.0.1:8080"
 		} else {
 			logger.Infof("Server", "New", "Discovered Payram Core at: %s (from TARGET_CONTAINER_NAME=%s)", coreBaseURL, cfg.TargetContainerName)
@@ -111,6 +179,7 @@ func New(cfg *config.Config, jobStore *jobs.Store) *Server {
 		if err != nil {
 			logger.Error("Server", "New", err)
 			logger.Warnf("Server", "New", "Falling back to http://127.0.0.1:8080 (this may not work if Payram Core is on a different port)")
+			discoveryErr = err
 			coreBaseURL = "http://127.0.0.1:8080"
 		} else {
 			logger.Infof("Server", "New", "Discovered Payram Core at: %s", coreBaseURL)
@@ -155,8 +224,19 @@ func New(cfg *config.Config, jobStore *jobs.Store) *Server {
 		PGDB:                cfg.Backup.PGDB,
 		PGUser:              cfg.Backup.PGUser,
 		PGPassword:          cfg.Backup.PGPassword,
+		PGSuperUser:         cfg.Backup.PGSuperUser,
+		PGSuperPassword:     cfg.Backup.PGSuperPassword,
 		ImagePattern:        imagePattern,
 		TargetContainerName: cfg.TargetContainerName,
+		PerDatabaseDirs:     cfg.Backup.PerDatabaseDirs,
+		DatabaseRetention:   cfg.Backup.DatabaseRetention,
+		RequireMount:        cfg.Backup.RequireMount,
+		DumpFormat:          cfg.Backup.DumpFormat,
+		Snapshot: backup.SnapshotConfig{
+			Strategy:        cfg.Backup.Strategy,
+			Command:         cfg.Backup.SnapshotCommand,
+			RollbackCommand: cfg.Backup.SnapshotRollbackCommand,
+		},
 	}
 	backupMgr := backup.NewManager(backupCfg, &backup.RealExecutor{}, logger.StdLogger())
 
@@ -168,6 +248,18 @@ func New(cfg *config.Config, jobStore *jobs.Store) *Server {
 		logger.StdLogger(),
 	)
 	containerBackupExec.BackupTimeout = time.Duration(cfg.BackupTimeoutSeconds) * time.Second
+	containerBackupExec.PreBackupHook = cfg.Backup.PreHook
+	containerBackupExec.PostBackupHook = cfg.Backup.PostHook
+	containerBackupExec.Snapshot = backupCfg.Snapshot
+	containerBackupExec.PerDatabaseDirs = cfg.Backup.PerDatabaseDirs
+	containerBackupExec.RequireMount = cfg.Backup.RequireMount
+	containerBackupExec.VerifyAfterCreate = cfg.Backup.VerifyAfterCreate
+	containerBackupExec.DumpFormat = cfg.Backup.DumpFormat
+	containerBackupExec.Selection = backup.DumpSelection{
+		Database:       cfg.Backup.Database,
+		IncludeSchemas: cfg.Backup.IncludeSchemas,
+		ExcludeSchemas: cfg.Backup.ExcludeSchemas,
+	}
 
 	s := &Server{
 		port:                cfg.Port,
@@ -178,19 +270,39 @@ func New(cfg *config.Config, jobStore *jobs.Store) *Server {
 		backupManager:       backupMgr,
 		containerBackupExec: containerBackupExec,
 		historyStore:        history.NewStore(cfg.StateDir),
+		lastGoodStore:       rollback.NewStore(cfg.StateDir),
+		discoveryErr:        discoveryErr,
+		verifyWindow:        healthVerifyWindow + time.Duration(cfg.MigrationMaxWaitSeconds)*time.Second,
+		pullBackoff:         pullInitialBackoff,
+		healthInterval:      healthCheckInterval,
+		telemetry:           telemetry.New(cfg.TelemetryEnabled, cfg.TelemetryURL),
+		deviceOf:            diskspace.PathDevice,
+	}
+	if cfg.SerializeOperations {
+		s.operations = orchestrator.New()
+	}
+	if cfg.VerifyURL != "" {
+		// VERIFY_URL is checked after the local endpoint, with its own retries
+		s.verifyWindow += healthVerifyWindow
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", HandleHealth())
+	mux.HandleFunc("/health", s.HandleHealth())
+	mux.HandleFunc("/livez", HandleLivez())
 	mux.HandleFunc("/upgrade/status", s.HandleUpgradeStatus())
 	mux.HandleFunc("/upgrade/logs", s.HandleUpgradeLogs())
 	mux.HandleFunc("/upgrade/last", s.HandleUpgradeLast())
 	mux.HandleFunc("/upgrade/playbook", s.HandleUpgradePlaybook())
 	mux.HandleFunc("/upgrade/inspect", s.HandleUpgradeInspect())
 	mux.HandleFunc("/upgrade/plan", s.HandleUpgradePlan())
+	mux.HandleFunc("/upgrade/compare", s.HandleUpgradeCompare())
 	mux.HandleFunc("/upgrade/run", s.HandleUpgradeRun())
+	mux.HandleFunc("/upgrade/cancel", s.HandleUpgradeCancel())
 	mux.HandleFunc("/history", s.HandleHistory())
+	mux.HandleFunc("/enums", HandleEnums())
+	mux.HandleFunc("/version", HandleVersion())
 	mux.HandleFunc("/upgrade/history", s.HandleHistory())
+	mux.HandleFunc("/summary", s.HandleSummary())
 
 	// Apply IP restriction middleware to allow only localhost and Payram container
 	allowedIPs := []string{
@@ -200,7 +312,8 @@ func New(cfg *config.Config, jobStore *jobs.Store) *Server {
 	if payramContainerIP != "" {
 		allowedIPs = append(allowedIPs, payramContainerIP)
 	}
-	handler := network.AllowedIPsMiddleware(allowedIPs, logger.StdLogger())(mux)
+	allowedIPs = append(allowedIPs, cfg.AllowedCIDRs...)
+	handler := network.AllowedIPsMiddleware(allowedIPs, logger.StdWarnLogger())(s.trackActivity(mux))
 	logger.Infof("Server", "New", "API access restricted to: %v", allowedIPs)
 
 	// Bind only to localhost and docker bridge (local machine only)
@@ -209,13 +322,56 @@ func New(cfg *config.Config, jobStore *jobs.Store) *Server {
 		Addr:    addr,
 		Handler: handler,
 	}
+	s.followersDone = make(chan struct{})
+	s.httpServer.RegisterOnShutdown(s.stopFollowers)
+
+	if cfg.HealthPort > 0 {
+		healthAllowedIPs := append(append([]string{}, allowedIPs...), cfg.HealthAllowedCIDRs...)
+		s.healthServer = &http.Server{
+			Addr:    net.JoinHostPort(cfg.HealthBindAddress, strconv.Itoa(cfg.HealthPort)),
+			Handler: network.AllowedIPsMiddleware(healthAllowedIPs, logger.StdWarnLogger())(s.healthMux()),
+		}
+		logger.Infof("Server", "New", "Health port %d access restricted to: %v", cfg.HealthPort, healthAllowedIPs)
+	}
 
 	return s
 }
 
+// healthMux serves the read-only probes alone, for the HEALTH_PORT listener.
+// The upgrade API is not reachable through it, so it can be opened to a
+// broader allowlist than the main port. Probes on it do not count as API
+// activity for IDLE_TIMEOUT_SECONDS.
+func (s *Server) healthMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.HandleHealth())
+	mux.HandleFunc("/livez", HandleLivez())
+	return mux
+}
+
 // Start starts the HTTP server and blocks until shutdown.
 // It handles graceful shutdown on SIGINT and SIGTERM.
 func (s *Server) Start() error {
+	// Fail fast on an unusable backup directory instead of on the first upgrade
+	if err := backup.EnsureDir(s.config.Backup.Dir); err != nil {
+		return err
+	}
+	logger.Infof("Server", "Start", "Backup directory: %s", s.config.Backup.Dir)
+
+	// Refuse to share the state directory with another daemon
+	lock, err := statelock.Acquire(s.config.StateDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			logger.Error("Server", "Start", err)
+		}
+	}()
+	if job, plan := s.recoverInterruptedUpgrade(context.Background()); plan != nil {
+		go s.runQueuedUpgrade(job, plan)
+	}
+	go s.sweepThrowawayContainers(context.Background())
+
 	autoUpdateCtx, autoUpdateCancel := context.WithCancel(context.Background())
 	defer autoUpdateCancel()
 
@@ -225,6 +381,7 @@ func (s *Server) Start() error {
 
 	// Create a channel to capture server errors
 	serverErrors := make(chan error, 1)
+	listening := make(chan struct{})
 
 	// Start the server in a goroutine
 	go func() {
@@ -262,6 +419,21 @@ func (s *Server) Start() error {
 			}
 		}
 
+		if s.healthServer != nil {
+			healthListener, err := net.Listen("tcp", s.healthServer.Addr)
+			if err != nil {
+				serverErrors <- fmt.Errorf("failed to create health listener: %w", err)
+				return
+			}
+			logger.Infof("Server", "Start", "Health port: http://%s", s.healthServer.Addr)
+			go func() {
+				if err := s.healthServer.Serve(healthListener); err != nil && err != http.ErrServerClosed {
+					serverErrors <- fmt.Errorf("HTTP server error (health port): %w", err)
+				}
+			}()
+		}
+
+		close(listening)
 		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			serverErrors <- fmt.Errorf("HTTP server error: %w", err)
 		}
@@ -270,28 +442,99 @@ func (s *Server) Start() error {
 	if s.config.AutoUpdateEnabled {
 		go s.startAutoUpdateLoop(autoUpdateCtx)
 	}
+	if s.config.BackupScheduleEnabled {
+		go s.startBackupScheduleLoop(autoUpdateCtx)
+	}
+
+	// Optional idle shutdown (for ephemeral/CI daemons)
+	idle := make(chan struct{})
+	if s.config.IdleTimeoutSeconds > 0 {
+		s.markActivity()
+		logger.Infof("Server", "Start", "Idle timeout enabled: exiting after %d seconds without activity", s.config.IdleTimeoutSeconds)
+		go s.watchIdle(autoUpdateCtx, idleCheckInterval(s.config.IdleTimeoutSeconds), idle)
+	}
+
+	// Tell systemd (Type=notify) we are ready once the listeners are bound
+	select {
+	case err := <-serverErrors:
+		autoUpdateCancel()
+		return err
+	case <-listening:
+		s.notifyReady()
+	}
 
-	// Wait for either a signal or server error
+	// Wait for a signal, idle timeout, or server error
 	select {
 	case err := <-serverErrors:
 		autoUpdateCancel()
 		return err
 	case sig := <-stop:
 		logger.Warnf("Server", "Start", "Received signal %v, initiating graceful shutdown", sig)
+	case <-idle:
+		logger.Warnf("Server", "Start", "Idle timeout reached, initiating graceful shutdown")
+	}
+
+	if _, err := sdnotify.Notify(sdnotify.Stopping); err != nil {
+		logger.Error("Server", "Start", err)
 	}
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := s.httpServer.Shutdown(ctx); err != nil {
-		return fmt.Errorf("server shutdown error: %w", err)
+	if s.healthServer != nil {
+		if err := s.healthServer.Shutdown(ctx); err != nil {
+			logger.Error("Server", "Start", err)
+		}
+	}
+	shutdownErr := s.httpServer.Shutdown(ctx)
+	if shutdownErr != nil {
+		logger.Error("Server", "Start", shutdownErr)
+	}
+
+	// Stop an in-flight upgrade if it has not reached a destructive step,
+	// and let one that has run to completion rather than orphan the container.
+	// This runs even if Shutdown timed out: the upgrade matters more than
+	// the connections left open.
+	if s.CancelUpgrade(errDaemonShuttingDown) {
+		logger.Warnf("Server", "Start", "Cancelled in-flight upgrade")
 	}
+	s.upgrades.Wait()
 
+	if shutdownErr != nil {
+		return fmt.Errorf("server shutdown error: %w", shutdownErr)
+	}
 	logger.Infof("Server", "Start", "Server stopped gracefully")
 	return nil
 }
 
+// stopFollowers ends every open log follow stream. It is registered with
+// httpServer.RegisterOnShutdown and safe to call more than once.
+func (s *Server) stopFollowers() {
+	s.stopFollowersOnce.Do(func() {
+		if s.followersDone != nil {
+			close(s.followersDone)
+		}
+	})
+}
+
+// notifyReady sends READY=1 to systemd, with the outcome of the startup
+// container discovery (done in New) as the unit status. A failed discovery
+// does not hold readiness back: the API is up and reports it on /health.
+// No-op unless run under a Type=notify unit.
+func (s *Server) notifyReady() {
+	status := fmt.Sprintf("Listening on port %d", s.port)
+	if s.discoveryErr != nil {
+		status += "; Payram container not discovered"
+	}
+	sent, err := sdnotify.Notify(sdnotify.Ready, sdnotify.Status(status))
+	if err != nil {
+		logger.Error("Server", "notifyReady", err)
+	} else if sent {
+		logger.Infof("Server", "notifyReady", "Notified systemd: ready (%s)", status)
+	}
+}
+
 func (s *Server) startAutoUpdateLoop(ctx context.Context) {
 	interval := time.Duration(s.config.AutoUpdateInterval) * time.Hour
 	if interval <= 0 {
@@ -318,6 +561,26 @@ func (s *Server) startAutoUpdateLoop(ctx context.Context) {
 	}
 }
 
+// sweepThrowawayContainers removes the throwaway containers, e.g. of a
+// migration check, that an updater which crashed mid-check left behind and
+// that are older than THROWAWAY_CONTAINER_MAX_AGE_MINUTES. Failing to sweep
+// only logs a warning.
+func (s *Server) sweepThrowawayContainers(ctx context.Context) {
+	if s.config.ThrowawayMaxAgeMinutes == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	maxAge := time.Duration(s.config.ThrowawayMaxAgeMinutes) * time.Minute
+	removed, err := bootstrap.SweepThrowaway(ctx, s.dockerRunner, maxAge, time.Now())
+	for _, name := range removed {
+		logger.Infof("Server", "sweepThrowawayContainers", "Removed leaked throwaway container %s and its volumes", name)
+	}
+	if err != nil {
+		logger.Warnf("Server", "sweepThrowawayContainers", "Could not remove leaked throwaway containers: %v", err)
+	}
+}
+
 func (s *Server) recordHistory(event history.Event) {
 	if s.historyStore == nil {
 		return
@@ -334,6 +597,7 @@ func (s *Server) runAutoUpdateOnce(ctx context.Context) {
 	if ctx.Err() != nil {
 		return
 	}
+	s.lastAutoUpdateCheck.Store(s.clock().UnixNano())
 
 	// Skip if an active job exists
 	existingJob, err := s.jobStore.LoadLatest()
@@ -346,11 +610,15 @@ func (s *Server) runAutoUpdateOnce(ctx context.Context) {
 			logger.Infof("Server", "runAutoUpdateOnce", "Auto update: active job %s in state %s, skipping", existingJob.JobID, existingJob.State)
 			return
 		}
-		if existingJob.State == jobs.JobStateFailed {
-			logger.Warnf("Server", "runAutoUpdateOnce", "Auto update: last job failed (%s), skipping", existingJob.FailureCode)
+		if existingJob.State == jobs.JobStateFailed && !autoUpdateRetryable(existingJob) {
+			logger.Warnf("Server", "runAutoUpdateOnce", "Auto update: last job failed (%s) and needs manual recovery, skipping", existingJob.FailureCode)
 			return
 		}
 	}
+	if wait := s.checkUpgradeInterval(); wait != "" {
+		logger.Infof("Server", "runAutoUpdateOnce", "Auto update: skipping: %s", wait)
+		return
+	}
 
 	// Fetch policy to get latest version
 	policyClient := policy.NewClient(time.Duration(s.config.FetchTimeoutSeconds) * time.Second)
@@ -361,11 +629,6 @@ func (s *Server) runAutoUpdateOnce(ctx context.Context) {
 		logger.Error("Server", "runAutoUpdateOnce", err)
 		return
 	}
-	latest := strings.TrimSpace(policyData.Latest)
-	if latest == "" {
-		logger.Warnf("Server", "runAutoUpdateOnce", "Auto update: policy latest is empty, skipping")
-		return
-	}
 	initVersion := strings.TrimSpace(policyData.UpdaterAPIInitVersion)
 
 	containerName, err := s.discoverContainerName(ctx)
@@ -383,15 +646,24 @@ func (s *Server) runAutoUpdateOnce(ctx context.Context) {
 		return
 	}
 
+	latest, err := policyData.ResolveLatest(s.config.LatestStrategy, currentVersion)
+	if err != nil {
+		logger.Warnf("Server", "runAutoUpdateOnce", "Auto update: cannot resolve %s (%v), skipping", s.config.LatestStrategy, err)
+		return
+	}
 	if currentVersion == latest {
 		logger.Infof("Server", "runAutoUpdateOnce", "Auto update: already on latest version %s", latest)
 		return
 	}
+	if !s.autoUpdateTargetAllowed(latest) {
+		return
+	}
 
 	// Plan upgrade using DASHBOARD mode
 	planCtx, cancel3 := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel3()
 	plan := s.PlanUpgrade(planCtx, jobs.JobModeDashboard, latest, currentVersion)
+	s.checkDockerVersion(planCtx, plan)
 	if plan.State == jobs.JobStateFailed {
 		logger.Warnf("Server", "runAutoUpdateOnce", "Auto update: planning failed (%s): %s", plan.FailureCode, plan.Message)
 		return
@@ -405,6 +677,14 @@ func (s *Server) runAutoUpdateOnce(ctx context.Context) {
 	}
 
 	jobID := fmt.Sprintf("job-%d", time.Now().UnixNano())
+	// An auto-update never waits in the operation queue: it would hold up
+	// whatever is queued after it, and the next check retries anyway
+	release, ok := s.operations.TryAcquire(orchestrator.Operation{Kind: orchestrator.KindAutoUpdate, ID: jobID})
+	if !ok {
+		logger.Infof("Server", "runAutoUpdateOnce", "Auto update: another operation is running or queued, skipping")
+		return
+	}
+
 	job := jobs.NewJob(jobID, jobs.JobModeDashboard, plan.RequestedTarget)
 	job.ResolvedTarget = plan.ResolvedTarget
 	job.State = jobs.JobStateReady
@@ -412,12 +692,17 @@ func (s *Server) runAutoUpdateOnce(ctx context.Context) {
 	job.UpdatedAt = time.Now().UTC()
 
 	if err := s.jobStore.Save(job); err != nil {
+		release()
 		logger.Error("Server", "runAutoUpdateOnce", err)
 		return
 	}
 
 	s.jobStore.AppendLog(fmt.Sprintf("Starting auto update job %s: mode=%s target=%s source=AUTO", jobID, "DASHBOARD", plan.RequestedTarget))
-	go s.executeUpgrade(job, plan.Manifest, plan.ArchSupport, plan.SteppingStone)
+	go func() {
+		defer release()
+		s.executeUpgrade(job, plan.Manifest, plan.ArchSupport, plan.SteppingStone)
+		s.recordAutoUpdateOutcome(job, latest)
+	}()
 }
 
 // executeUpgrade runs the upgrade execution in the background.
@@ -443,11 +728,28 @@ func (s *Server) runAutoUpdateOnce(ctx context.Context) {
 // See internal/recovery/playbook.go for complete recovery instructions.
 // Every failure includes next steps for manual recovery.
 func (s *Server) executeUpgrade(job *jobs.Job, manifestData *manifest.Manifest, archSupport map[string]string, steppingStone string) {
-	ctx := context.Background()
+	s.upgrades.Add(1)
+	defer s.upgrades.Done()
+
+	// ctx is cancellable until the container is stopped; from then on the
+	// upgrade runs on the committed context, which only the overall deadline
+	// can end, so it is never abandoned half-way by a cancel request.
+	verifyPasses := 1
+	if steppingStone != "" {
+		verifyPasses = 2
+	}
+	ctx, committed, cancel := s.upgradeContext(verifyPasses)
+	defer cancel()
+
 	isDryRun := s.config.ExecutionMode == "dry-run"
+	startedAt := time.Now()
+	fromVersion := ""
+	var phases report.PhaseTimer
 	imageTag := job.ResolvedTarget
 	imageRepo := manifestData.Image.Repo
 	policyInitVersion := s.fetchPolicyInitVersion(ctx)
+	resumeFrom := job.Checkpoint
+	containerName := "" // set once resolved, for the failure log capture below
 
 	// Record upgrade start
 	upgradeData := map[string]string{
@@ -460,6 +762,17 @@ func (s *Server) executeUpgrade(job *jobs.Job, manifestData *manifest.Manifest,
 	if isDryRun {
 		upgradeData["dryRun"] = "true"
 	}
+	if resumeFrom != "" {
+		upgradeData["resumedFrom"] = string(resumeFrom)
+	}
+	if job.ImageRepoOverride != "" {
+		upgradeData["imageRepoOverride"] = job.ImageRepoOverride
+		s.jobStore.AppendLog(fmt.Sprintf("WARNING: Image repo overridden for this upgrade: pulling %s instead of the manifest's repo", job.ImageRepoOverride))
+	}
+	if job.SkipVerify {
+		upgradeData["skipVerify"] = "true"
+		s.jobStore.AppendLog("WARNING: post-upgrade verification will be SKIPPED (--skip-verify); the upgrade will not be checked")
+	}
 	s.recordHistory(history.Event{
 		Type:    "upgrade",
 		Status:  "started",
@@ -478,17 +791,37 @@ func (s *Server) executeUpgrade(job *jobs.Job, manifestData *manifest.Manifest,
 			"resolvedTarget":  job.ResolvedTarget,
 			"executionMode":   s.config.ExecutionMode,
 		}
+		if job.RunCommand != "" {
+			data["runCommand"] = job.RunCommand
+		}
+		if job.ImageRepoOverride != "" {
+			data["imageRepoOverride"] = job.ImageRepoOverride
+		}
+		if job.SkipVerify {
+			data["skipVerify"] = "true"
+		}
 		if job.State == jobs.JobStateFailed {
 			status = "failed"
 			if job.FailureCode != "" {
 				data["failureCode"] = job.FailureCode
 			}
+			if !isDryRun {
+				s.saveFailureContainerLogs(job, containerName)
+			}
+			if job.ContainerLogPath != "" {
+				data["containerLog"] = job.ContainerLogPath
+			}
 		} else if job.State == jobs.JobStateReady {
 			if isDryRun {
 				status = "validated"
 			} else {
 				status = "succeeded"
+				if job.SkipVerify {
+					message = "UNVERIFIED: " + message
+				}
 			}
+		} else if job.State == jobs.JobStateCancelled {
+			status = "cancelled"
 		}
 		if status == "" {
 			return
@@ -499,33 +832,65 @@ func (s *Server) executeUpgrade(job *jobs.Job, manifestData *manifest.Manifest,
 			Message: message,
 			Data:    data,
 		})
+		s.writeUpgradeReport(job, status, fromVersion, steppingStone, startedAt, phases.Stop())
+		if !isDryRun {
+			s.telemetry.Report(telemetry.Event{
+				FromVersion:     fromVersion,
+				ToVersion:       job.ResolvedTarget,
+				Mode:            string(job.Mode),
+				Outcome:         status,
+				FailureCode:     job.FailureCode,
+				SteppingStone:   steppingStone != "",
+				DurationSeconds: int64(time.Since(startedAt).Seconds()),
+			})
+		}
 	}()
 
 	// Phase 1: Resolve target container name
+	s.startStep(job, &phases, stepResolveContainer)
 	containerName, ok := s.resolveTargetContainer(ctx, job, manifestData)
-	if !ok {
+	if s.phaseStopped(ctx, job, ok) {
 		return
 	}
 
 	// Phase 2: Prepare upgrade arguments (extract runtime state & build docker args).
 	// Also applies arch suffix from current container tag (e.g. 1.9.3 → 1.9.3-arm64).
-	dockerArgs, imageTag, ok := s.prepareUpgradeArgs(ctx, job, containerName, manifestData, imageTag, archSupport)
-	if !ok {
+	s.startStep(job, &phases, stepPrepare)
+	dockerArgs, imageTag, previousState, ok := s.prepareUpgradeArgs(ctx, job, containerName, manifestData, imageTag, archSupport)
+	if previousState != nil {
+		fromVersion = previousState.ImageTag
+	}
+	if s.phaseStopped(ctx, job, ok) {
 		return
 	}
 
 	// Phase 3: Execute dry-run if configured
 	if isDryRun {
-		s.executeDryRun(job, imageRepo, imageTag, containerName, dockerArgs)
+		phases.Start("dry_run")
+		s.executeDryRun(job, imageRepo, imageTag, containerName, dockerArgs, steppingStone)
 		return
 	}
 
 	// EXECUTE mode: perform actual upgrade
+	unlock, ok := s.lockUpgrade(job)
+	if !ok {
+		return
+	}
+	defer unlock()
 
 	// Phase 4: Pre-flight checks
-	if !s.preflightChecks(ctx, job, containerName) {
+	s.startStep(job, &phases, stepPreflight)
+	if s.phaseStopped(ctx, job, s.preflightChecks(ctx, job, containerName)) {
+		return
+	}
+	if s.phaseStopped(ctx, job, s.checkVolumesExist(ctx, job, previousState)) {
+		return
+	}
+	s.warnBackupOnDBFilesystem(job, previousState)
+	if s.phaseStopped(ctx, job, s.savePreUpgradeSnapshot(job, containerName, previousState)) {
 		return
 	}
+	s.saveCheckpoint(job, jobs.CheckpointPreflight)
 
 	if steppingStone != "" {
 		// TWO-HOP UPGRADE: breakpoint chaining.
@@ -534,62 +899,73 @@ func (s *Server) executeUpgrade(job *jobs.Job, manifestData *manifest.Manifest,
 		// Both hops use the same pre-hop backup for rollback safety.
 
 		// Phase 5a: Pull stepping stone image
-		steppingArgs, steppingTag, ok := s.prepareUpgradeArgs(ctx, job, containerName, manifestData, steppingStone, archSupport)
-		if !ok {
+		s.startStep(job, &phases, stepPullStepping)
+		steppingArgs, steppingTag, _, ok := s.prepareUpgradeArgs(ctx, job, containerName, manifestData, steppingStone, archSupport)
+		if s.phaseStopped(ctx, job, ok) {
 			return
 		}
 		s.jobStore.AppendLog(fmt.Sprintf("Breakpoint upgrade: passing through stepping stone %s first, then continuing to %s", steppingTag, imageTag))
-		if !s.pullUpgradeImage(ctx, job, imageRepo, steppingTag) {
+		if s.phaseStopped(ctx, job, s.pullUpgradeImage(ctx, job, imageRepo, steppingTag)) {
 			return
 		}
+		s.saveCheckpoint(job, jobs.CheckpointPulled)
 
 		// Phase 6a: Quiesce + Backup (once, covers both hops)
-		stoppedPrograms, usedSupervisor, ok := s.quiesceSupervisorPrograms(ctx, job, containerName)
+		s.startStep(job, &phases, stepBackup)
+		quiesced, ok := s.backupForUpgrade(ctx, job, resumeFrom, containerName, steppingTag, policyInitVersion)
 		if !ok {
 			return
 		}
-		if usedSupervisor {
-			if _, ok := s.createPreUpgradeBackupAfterQuiesce(ctx, job, containerName, steppingTag, policyInitVersion, 3, stoppedPrograms); !ok {
-				return
-			}
-		} else {
-			if _, ok := s.createPreUpgradeBackupBeforeStop(ctx, job, containerName, steppingTag, policyInitVersion); !ok {
-				return
-			}
+		if ctx, ok = s.enterPointOfNoReturn(ctx, committed, job, containerName, quiesced); !ok {
+			return
 		}
 
 		// Phase 7a: Stop → replace → verify stepping stone
-		if !s.stopContainerForUpgrade(ctx, job, containerName) {
+		s.startStep(job, &phases, stepReplaceStepping)
+		if s.phaseFailed(ctx, job, s.stopContainerForUpgrade(ctx, job, containerName)) {
 			return
 		}
-		if !s.replaceContainer(ctx, job, containerName, steppingArgs) {
+		s.saveCheckpoint(job, jobs.CheckpointStopped)
+		if s.phaseFailed(ctx, job, s.replaceContainer(ctx, job, containerName, steppingArgs)) {
 			return
 		}
 		job.Message = fmt.Sprintf("Passing through %s, upgrading to %s...", steppingTag, imageTag)
 		job.UpdatedAt = time.Now().UTC()
 		s.jobStore.Save(job)
-		if !s.verifyUpgrade(ctx, job, containerName, steppingTag, policyInitVersion) {
+		s.startStep(job, &phases, stepVerifyStepping)
+		if s.phaseFailed(ctx, job, s.verifyWithWritesPaused(ctx, job, containerName, steppingTag, policyInitVersion)) {
 			return
 		}
 		s.jobStore.AppendLog(fmt.Sprintf("Stepping stone %s healthy, continuing to %s", steppingTag, imageTag))
 
 		// Phase 5b: Pull final image (stepping stone is now running — re-read runtime state)
-		dockerArgs, imageTag, ok = s.prepareUpgradeArgs(ctx, job, containerName, manifestData, imageTag, archSupport)
-		if !ok {
+		// From the plain target version: imageTag already has the tag template and arch suffix applied
+		s.startStep(job, &phases, stepPull)
+		dockerArgs, imageTag, _, ok = s.prepareUpgradeArgs(ctx, job, containerName, manifestData, job.ResolvedTarget, archSupport)
+		if s.phaseFailed(ctx, job, ok) {
 			return
 		}
-		if !s.pullUpgradeImage(ctx, job, imageRepo, imageTag) {
+		if s.phaseFailed(ctx, job, s.pullUpgradeImage(ctx, job, imageRepo, imageTag)) {
 			return
 		}
 
 		// Phase 7b: Stop stepping stone → replace → verify final target
-		if !s.stopContainerForUpgrade(ctx, job, containerName) {
+		s.startStep(job, &phases, stepReplace)
+		if s.phaseFailed(ctx, job, s.stopContainerForUpgrade(ctx, job, containerName)) {
 			return
 		}
-		if !s.replaceContainer(ctx, job, containerName, dockerArgs) {
+		if s.phaseFailed(ctx, job, s.replaceContainer(ctx, job, containerName, dockerArgs)) {
 			return
 		}
-		if !s.verifyUpgrade(ctx, job, containerName, imageTag, policyInitVersion) {
+		s.startStep(job, &phases, stepVerify)
+		if !s.verifyWithWritesPaused(ctx, job, containerName, imageTag, policyInitVersion) {
+			if s.failIfTimedOut(ctx, job) {
+				return
+			}
+			if job.FailureCode == "PAUSED_PROGRAMS_NOT_RESUMED" {
+				// Hop 2 passed verification; only the programs failed to restart
+				return
+			}
 			// Hop 2 failed. System is on stepping stone (now stopped). Report clearly.
 			job.FailureCode = "HEALTHCHECK_FAILED"
 			job.Message = fmt.Sprintf(
@@ -603,51 +979,66 @@ func (s *Server) executeUpgrade(job *jobs.Job, manifestData *manifest.Manifest,
 			return
 		}
 
-		s.finalizeUpgrade(ctx, job, imageRepo, imageTag)
+		s.startStep(job, &phases, stepFinalize)
+		s.finalizeUpgrade(ctx, job, imageRepo, imageTag, previousState)
 		return
 	}
 
 	// SINGLE-HOP UPGRADE (no stepping stone)
 
 	// Phase 5: Pull image before stopping container
-	if !s.pullUpgradeImage(ctx, job, imageRepo, imageTag) {
+	s.startStep(job, &phases, stepPull)
+	if s.phaseStopped(ctx, job, s.pullUpgradeImage(ctx, job, imageRepo, imageTag)) {
 		return
 	}
+	s.saveCheckpoint(job, jobs.CheckpointPulled)
 
-	// Phase 6: Quiesce supervisor programs (if available)
-	stoppedPrograms, usedSupervisor, ok := s.quiesceSupervisorPrograms(ctx, job, containerName)
+	// Phase 6-7: Quiesce supervisor programs (if available) and create backup
+	s.startStep(job, &phases, stepBackup)
+	quiesced, ok := s.backupForUpgrade(ctx, job, resumeFrom, containerName, imageTag, policyInitVersion)
 	if !ok {
 		return
 	}
+	if ctx, ok = s.enterPointOfNoReturn(ctx, committed, job, containerName, quiesced); !ok {
+		return
+	}
 
-	// Phase 7: Create backup (supervisor quiesce or fallback)
-	if usedSupervisor {
-		if _, ok := s.createPreUpgradeBackupAfterQuiesce(ctx, job, containerName, imageTag, policyInitVersion, 3, stoppedPrograms); !ok {
+	// Phase 8-9: Replace the container with the new version, by hot swap if
+	// the manifest allows it, otherwise by stopping, removing and running
+	s.startStep(job, &phases, stepReplace)
+	swapped, ok := false, true
+	if s.hotSwapAllowed(job, manifestData) {
+		swapped, ok = s.hotSwapContainer(ctx, job, containerName, dockerArgs)
+		if s.phaseFailed(ctx, job, ok) {
 			return
 		}
-	} else {
-		if _, ok := s.createPreUpgradeBackupBeforeStop(ctx, job, containerName, imageTag, policyInitVersion); !ok {
+	}
+	if !swapped {
+		if s.phaseFailed(ctx, job, s.stopContainerForUpgrade(ctx, job, containerName)) {
 			return
 		}
-	}
+		s.saveCheckpoint(job, jobs.CheckpointStopped)
 
-	// Phase 8: Stop container before replacement
-	if !s.stopContainerForUpgrade(ctx, job, containerName) {
-		return
-	}
-
-	// Phase 9: Replace container with new version
-	if !s.replaceContainer(ctx, job, containerName, dockerArgs) {
-		return
+		if s.phaseFailed(ctx, job, s.replaceContainer(ctx, job, containerName, dockerArgs)) {
+			return
+		}
 	}
 
 	// Phase 10: Verify upgrade (health and version checks)
-	if !s.verifyUpgrade(ctx, job, containerName, imageTag, policyInitVersion) {
+	s.startStep(job, &phases, stepVerify)
+	if s.phaseFailed(ctx, job, s.verifyWithWritesPaused(ctx, job, containerName, imageTag, policyInitVersion)) {
+		if swapped {
+			s.logHotSwapRollback(containerName, hotSwapRestartPolicy(dockerArgs))
+		}
 		return
 	}
+	if swapped {
+		s.removeHotSwapPrevious(ctx, job, containerName)
+	}
 
 	// Phase 11: Finalize upgrade (mark complete and prune old images)
-	s.finalizeUpgrade(ctx, job, imageRepo, imageTag)
+	s.startStep(job, &phases, stepFinalize)
+	s.finalizeUpgrade(ctx, job, imageRepo, imageTag, previousState)
 }
 
 func (s *Server) fetchPolicyInitVersion(ctx context.Context) string {
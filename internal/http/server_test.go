@@ -1,10 +1,25 @@
 package http
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/rollback"
+	"github.com/payram/payram-updater/internal/sdnotify"
+	"github.com/payram/payram-updater/internal/statelock"
 )
 
 func TestNew(t *testing.T) {
@@ -41,3 +56,277 @@ func TestNew(t *testing.T) {
 		t.Fatal("expected jobStore to be set, got nil")
 	}
 }
+
+func TestRecordLastKnownGood(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := &Server{
+		config:        &config.Config{StateDir: tmpDir},
+		jobStore:      jobs.NewStore(tmpDir),
+		lastGoodStore: rollback.NewStore(tmpDir),
+	}
+
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.9.0")
+	job.BackupPath = "/backups/payram-backup-20250101-120000-1.8.0-to-1.9.0.dump"
+	previous := &container.RuntimeState{
+		Name:     "payram",
+		Image:    "payramapp/payram:1.8.0",
+		ImageTag: "1.8.0",
+	}
+
+	srv.recordLastKnownGood(job, "1.9.0", previous)
+
+	marker, err := rollback.NewStore(tmpDir).Load()
+	if err != nil {
+		t.Fatalf("failed to load marker: %v", err)
+	}
+	if marker == nil {
+		t.Fatal("expected marker to be recorded")
+	}
+	if marker.PreviousVersion != "1.8.0" {
+		t.Errorf("expected previous version 1.8.0, got %s", marker.PreviousVersion)
+	}
+	if marker.UpgradedTo != "1.9.0" {
+		t.Errorf("expected upgraded-to 1.9.0, got %s", marker.UpgradedTo)
+	}
+	if marker.BackupPath != job.BackupPath {
+		t.Errorf("expected backup path %s, got %s", job.BackupPath, marker.BackupPath)
+	}
+	if marker.RuntimeState == nil || marker.RuntimeState.Name != "payram" {
+		t.Errorf("expected runtime state for container payram, got %+v", marker.RuntimeState)
+	}
+}
+
+func TestRecordLastKnownGood_NoPreviousState(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := &Server{
+		config:        &config.Config{StateDir: tmpDir},
+		jobStore:      jobs.NewStore(tmpDir),
+		lastGoodStore: rollback.NewStore(tmpDir),
+	}
+
+	srv.recordLastKnownGood(jobs.NewJob("job-1", jobs.JobModeManual, "1.9.0"), "1.9.0", nil)
+
+	marker, err := rollback.NewStore(tmpDir).Load()
+	if err != nil {
+		t.Fatalf("failed to load marker: %v", err)
+	}
+	if marker != nil {
+		t.Errorf("expected no marker without previous state, got %+v", marker)
+	}
+}
+
+func TestStart_FailsFastOnInvalidBackupDir(t *testing.T) {
+	backupPath := filepath.Join(t.TempDir(), "backups")
+	if err := os.WriteFile(backupPath, []byte("not a directory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Port: 0, Backup: config.BackupConfig{Dir: backupPath}}
+	server := New(cfg, jobs.NewStore(t.TempDir()))
+
+	err := server.Start()
+	if err == nil {
+		t.Fatal("expected Start to fail when the backup directory is a file")
+	}
+	if !strings.Contains(err.Error(), backupPath) {
+		t.Errorf("expected the error to name the backup path, got %v", err)
+	}
+}
+
+func TestStart_FailsWhenAnotherDaemonHoldsStateDir(t *testing.T) {
+	holder := exec.Command("sleep", "30")
+	if err := holder.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		holder.Process.Kill()
+		holder.Wait()
+	}()
+
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	lockPath := filepath.Join(stateDir, statelock.FileName)
+	if err := os.WriteFile(lockPath, []byte(strconv.Itoa(holder.Process.Pid)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Port: 0, StateDir: stateDir, Backup: config.BackupConfig{Dir: filepath.Join(dir, "backups")}}
+	server := New(cfg, jobs.NewStore(stateDir))
+
+	err := server.Start()
+
+	var held *statelock.HeldError
+	if !errors.As(err, &held) || held.PID != holder.Process.Pid {
+		t.Fatalf("expected Start to refuse a state directory locked by PID %d, got %v", holder.Process.Pid, err)
+	}
+	if data, _ := os.ReadFile(lockPath); string(data) != strconv.Itoa(holder.Process.Pid) {
+		t.Errorf("expected the other daemon's lock to be left alone, got %q", data)
+	}
+}
+
+func TestStart_NotifiesSystemdReadyAndStopping(t *testing.T) {
+	sockDir, err := os.MkdirTemp("", "sdn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sockDir)
+	sockPath := filepath.Join(sockDir, "notify")
+	notify, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notify.Close()
+	t.Setenv(sdnotify.SocketEnv, sockPath)
+
+	dir := t.TempDir()
+	cfg := &config.Config{Port: 0, StateDir: filepath.Join(dir, "state"), IdleTimeoutSeconds: 1, Backup: config.BackupConfig{Dir: filepath.Join(dir, "backups")}}
+	server := New(cfg, jobs.NewStore(cfg.StateDir))
+	done := make(chan error, 1)
+	go func() { done <- server.Start() }()
+
+	read := func() string {
+		t.Helper()
+		notify.SetReadDeadline(time.Now().Add(10 * time.Second))
+		buf := make([]byte, 1024)
+		n, err := notify.Read(buf)
+		if err != nil {
+			t.Fatalf("expected a systemd notification: %v", err)
+		}
+		return string(buf[:n])
+	}
+	if got := read(); !strings.HasPrefix(got, "READY=1\nSTATUS=Listening on port 0") {
+		t.Errorf("expected READY=1 with a status, got %q", got)
+	}
+	if got := read(); got != "STOPPING=1\n" {
+		t.Errorf("expected STOPPING=1 on shutdown, got %q", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean idle shutdown, got %v", err)
+	}
+}
+
+func TestStart_ServesProbesOnHealthPort(t *testing.T) {
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := probe.Addr().(*net.TCPAddr).Port
+	probe.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Port:               0,
+		HealthPort:         port,
+		HealthBindAddress:  "127.0.0.1",
+		StateDir:           filepath.Join(dir, "state"),
+		IdleTimeoutSeconds: 3,
+		Backup:             config.BackupConfig{Dir: filepath.Join(dir, "backups")},
+	}
+	server := New(cfg, jobs.NewStore(cfg.StateDir))
+	done := make(chan error, 1)
+	go func() { done <- server.Start() }()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if resp, err = http.Get(base + "/livez"); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("expected /livez on the health port: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected /livez to return 200, got %d", resp.StatusCode)
+	}
+
+	if resp, err := http.Get(base + "/health"); err != nil || resp.StatusCode == http.StatusNotFound {
+		t.Errorf("expected /health on the health port, got %v (err %v)", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+
+	for _, path := range []string{"/upgrade/run", "/upgrade/cancel", "/upgrade/status", "/history"} {
+		resp, err := http.Post(base+path, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected %s not to be served on the health port, got %d", path, resp.StatusCode)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean idle shutdown, got %v", err)
+	}
+}
+
+func TestNew_HealthPortAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Port:               2567,
+		HealthPort:         2568,
+		HealthBindAddress:  "0.0.0.0",
+		HealthAllowedCIDRs: []string{"10.20.0.0/16"},
+		StateDir:           filepath.Join(dir, "state"),
+	}
+	server := New(cfg, jobs.NewStore(cfg.StateDir))
+	if server.healthServer == nil || server.healthServer.Addr != "0.0.0.0:2568" {
+		t.Fatalf("expected a health listener on 0.0.0.0:2568, got %+v", server.healthServer)
+	}
+
+	get := func(handler http.Handler, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.20.3.4:40000"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := get(server.healthServer.Handler, "/livez"); code != http.StatusOK {
+		t.Errorf("expected HEALTH_ALLOWED_CIDRS to reach the health port, got %d", code)
+	}
+	if code := get(server.httpServer.Handler, "/livez"); code != http.StatusForbidden {
+		t.Errorf("expected HEALTH_ALLOWED_CIDRS not to reach the main port, got %d", code)
+	}
+
+	if New(&config.Config{Port: 2567, StateDir: cfg.StateDir}, jobs.NewStore(cfg.StateDir)).healthServer != nil {
+		t.Error("expected no health listener without HEALTH_PORT")
+	}
+}
+
+func TestSweepThrowawayContainers_RemovesLeakedOnesOnly(t *testing.T) {
+	callLog := filepath.Join(t.TempDir(), "docker-calls.log")
+	old := time.Now().Add(-3 * time.Hour).UTC().Format("2006-01-02 15:04:05 -0700 MST")
+	recent := time.Now().Add(-time.Minute).UTC().Format("2006-01-02 15:04:05 -0700 MST")
+	// Like docker, list only the labeled containers for the label filter
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callLog + "\n" +
+		"case \"$*\" in\n" +
+		"  *label=io.payram.updater.throwaway*) printf 'payram-migration-check\\t" + old + "\\npayram-migration-check-new\\t" + recent + "\\n' ;;\n" +
+		"  ps*) printf 'payram\\t" + old + "\\n' ;;\n" +
+		"esac\n"
+	s, _ := newFinalizeTestServer(t, script)
+	s.config.ThrowawayMaxAgeMinutes = 60
+
+	s.sweepThrowawayContainers(t.Context())
+
+	data, _ := os.ReadFile(callLog)
+	calls := string(data)
+	if !strings.Contains(calls, "rm -f -v payram-migration-check\n") {
+		t.Errorf("expected the leaked throwaway container to be removed, got:\n%s", calls)
+	}
+	if strings.Contains(calls, "rm -f -v payram-migration-check-new") || strings.Contains(calls, "rm -f -v payram\n") || strings.Contains(calls, "rm payram") {
+		t.Errorf("expected the recent throwaway and the production container untouched, got:\n%s", calls)
+	}
+
+	os.Remove(callLog)
+	s.config.ThrowawayMaxAgeMinutes = 0
+	s.sweepThrowawayContainers(t.Context())
+	if _, err := os.Stat(callLog); !os.IsNotExist(err) {
+		t.Error("expected no docker calls with the sweep disabled")
+	}
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/payram/payram-updater/internal/inspect"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
+)
+
+// summaryInspectTTL is how long GET /summary reuses its last inspection.
+// Job, backup and auto-update fields are cheap and always read fresh.
+const summaryInspectTTL = time.Minute
+
+// SummaryResponse is a compact status of this updater instance for fleet
+// dashboards that poll many hosts.
+type SummaryResponse struct {
+	Hostname        string                   `json:"hostname"`
+	CurrentVersion  string                   `json:"currentVersion,omitempty"`
+	LatestVersion   string                   `json:"latestVersion,omitempty"`
+	UpdateAvailable bool                     `json:"updateAvailable"`
+	UpdateEligible  bool                     `json:"updateEligible"`            // a dashboard upgrade to LatestVersion could start now
+	EligibilityNote string                   `json:"eligibilityNote,omitempty"` // why UpdateEligible is false
+	Deprecation     *inspect.DeprecationInfo `json:"deprecation,omitempty"`     // set when the policy deprecates CurrentVersion
+	OverallState    inspect.OverallState     `json:"overallState"`              // from inspect: OK, DEGRADED or BROKEN
+	InspectedAt     time.Time                `json:"inspectedAt"`               // when the inspect-derived fields were computed
+	LastJob         *SummaryJob              `json:"lastJob,omitempty"`
+	LastBackupAt    string                   `json:"lastBackupAt,omitempty"` // RFC3339
+	AutoUpdate      SummaryAutoUpdate        `json:"autoUpdate"`
+}
+
+// SummaryJob is the outcome of the latest upgrade job.
+type SummaryJob struct {
+	JobID          string        `json:"jobId"`
+	State          jobs.JobState `json:"state"`
+	FailureCode    string        `json:"failureCode,omitempty"`
+	ResolvedTarget string        `json:"resolvedTarget,omitempty"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+}
+
+// SummaryAutoUpdate is the auto-update configuration and its last check.
+type SummaryAutoUpdate struct {
+	Enabled       bool   `json:"enabled"`
+	IntervalHours int    `json:"intervalHours,omitempty"`
+	LastCheckAt   string `json:"lastCheckAt,omitempty"` // RFC3339; empty until the first check
+}
+
+// HandleSummary returns a handler for GET /summary.
+func (s *Server) HandleSummary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s.buildSummary(r.Context()))
+	}
+}
+
+// buildSummary assembles the summary from the cached inspection and the
+// current job, backup and auto-update state.
+func (s *Server) buildSummary(ctx context.Context) *SummaryResponse {
+	result, inspectedAt := s.cachedInspection(ctx)
+
+	summary := &SummaryResponse{
+		OverallState: result.OverallState,
+		InspectedAt:  inspectedAt.UTC(),
+		AutoUpdate: SummaryAutoUpdate{
+			Enabled: s.config.AutoUpdateEnabled,
+		},
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		summary.Hostname = hostname
+	}
+	if info := result.UpdateInfo; info != nil {
+		summary.CurrentVersion = info.CurrentVersion
+		summary.LatestVersion = info.LatestVersion
+		summary.UpdateAvailable = info.UpdateAvailable
+		summary.Deprecation = info.Deprecated
+		summary.UpdateEligible = info.CanUpdateViaDashboard
+		if !info.CanUpdateViaDashboard {
+			summary.EligibilityNote = info.Message
+		}
+	} else {
+		summary.EligibilityNote = "Update availability unknown (see /upgrade/inspect)"
+	}
+
+	job, err := s.jobStore.LoadLatest()
+	if err != nil {
+		logger.Error("Server", "buildSummary", err)
+	} else if job != nil {
+		summary.LastJob = &SummaryJob{
+			JobID:          job.JobID,
+			State:          job.State,
+			FailureCode:    job.FailureCode,
+			ResolvedTarget: job.ResolvedTarget,
+			UpdatedAt:      job.UpdatedAt,
+		}
+		if summary.UpdateEligible && isJobActive(job) {
+			summary.UpdateEligible = false
+			summary.EligibilityNote = "An upgrade job is in progress"
+		}
+	}
+	if summary.UpdateEligible {
+		if wait := s.checkUpgradeInterval(); wait != "" {
+			summary.UpdateEligible = false
+			summary.EligibilityNote = wait
+		}
+	}
+
+	if s.backupManager != nil {
+		if latest, err := s.backupManager.GetLatestBackup(); err == nil && latest != nil {
+			summary.LastBackupAt = latest.CreatedAt
+		}
+	}
+
+	if s.config.AutoUpdateEnabled {
+		summary.AutoUpdate.IntervalHours = s.config.AutoUpdateInterval
+	}
+	if last := s.lastAutoUpdateCheck.Load(); last != 0 {
+		summary.AutoUpdate.LastCheckAt = time.Unix(0, last).UTC().Format(time.RFC3339)
+	}
+	return summary
+}
+
+// cachedInspection returns the inspection behind the summary, re-running it
+// when it is older than summaryInspectTTL, and when it was taken.
+func (s *Server) cachedInspection(ctx context.Context) (*inspect.InspectResult, time.Time) {
+	s.summaryMu.Lock()
+	defer s.summaryMu.Unlock()
+
+	now := s.clock()
+	if s.summaryInspect != nil && now.Sub(s.summaryInspectAt) < summaryInspectTTL {
+		return s.summaryInspect, s.summaryInspectAt
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	s.summaryInspect = s.inspectSystem(ctx)
+	s.summaryInspectAt = now
+	return s.summaryInspect, s.summaryInspectAt
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/inspect"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+func getSummary(t *testing.T, s *Server) SummaryResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.HandleSummary()(w, httptest.NewRequest(http.MethodGet, "/summary", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var got SummaryResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return got
+}
+
+// seedSummaryInspection makes the summary use result instead of inspecting.
+func seedSummaryInspection(s *Server, result *inspect.InspectResult) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.summaryInspect = result
+	s.summaryInspectAt = now
+}
+
+func TestHandleSummary_PopulatesFields(t *testing.T) {
+	s := newHealthTestServer(t)
+	seedSummaryInspection(s, &inspect.InspectResult{
+		OverallState: inspect.StateDegraded,
+		UpdateInfo: &inspect.UpdateInfo{
+			CurrentVersion:        "1.0.0",
+			LatestVersion:         "1.1.0",
+			UpdateAvailable:       true,
+			CanUpdateViaDashboard: true,
+		},
+	})
+	job := jobs.NewJob("job-1", jobs.JobModeDashboard, "1.0.0")
+	job.State = jobs.JobStateFailed
+	job.FailureCode = "HEALTHCHECK_FAILED"
+	s.jobStore.Save(job)
+	backupFile := filepath.Join(s.config.Backup.Dir, "payram-backup-20260228-100000-0.9.0-to-1.0.0.dump")
+	if err := os.WriteFile(backupFile, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.config.AutoUpdateEnabled = true
+	s.config.AutoUpdateInterval = 6
+	s.lastAutoUpdateCheck.Store(time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC).UnixNano())
+
+	got := getSummary(t, s)
+
+	if hostname, _ := os.Hostname(); got.Hostname != hostname {
+		t.Errorf("expected hostname %q, got %q", hostname, got.Hostname)
+	}
+	if got.CurrentVersion != "1.0.0" || got.LatestVersion != "1.1.0" || !got.UpdateAvailable {
+		t.Errorf("expected versions from inspect, got current=%q latest=%q available=%v", got.CurrentVersion, got.LatestVersion, got.UpdateAvailable)
+	}
+	if !got.UpdateEligible || got.EligibilityNote != "" {
+		t.Errorf("expected eligible, got %v (%s)", got.UpdateEligible, got.EligibilityNote)
+	}
+	if got.OverallState != inspect.StateDegraded {
+		t.Errorf("expected DEGRADED, got %s", got.OverallState)
+	}
+	if got.LastJob == nil || got.LastJob.JobID != "job-1" || got.LastJob.FailureCode != "HEALTHCHECK_FAILED" {
+		t.Errorf("expected the last job's outcome, got %+v", got.LastJob)
+	}
+	if !strings.HasPrefix(got.LastBackupAt, "2026-02-28T10:00:00") {
+		t.Errorf("expected the latest backup time, got %q", got.LastBackupAt)
+	}
+	if !got.AutoUpdate.Enabled || got.AutoUpdate.IntervalHours != 6 || got.AutoUpdate.LastCheckAt != "2026-03-01T06:00:00Z" {
+		t.Errorf("expected auto-update status, got %+v", got.AutoUpdate)
+	}
+}
+
+func TestHandleSummary_ActiveJobIsNotEligible(t *testing.T) {
+	s := newHealthTestServer(t)
+	seedSummaryInspection(s, &inspect.InspectResult{
+		OverallState: inspect.StateOK,
+		UpdateInfo:   &inspect.UpdateInfo{CurrentVersion: "1.0.0", LatestVersion: "1.1.0", UpdateAvailable: true, CanUpdateViaDashboard: true},
+	})
+	job := jobs.NewJob("job-1", jobs.JobModeDashboard, "1.1.0")
+	job.State = jobs.JobStateExecuting
+	s.jobStore.Save(job)
+
+	got := getSummary(t, s)
+
+	if got.UpdateEligible || !strings.Contains(got.EligibilityNote, "in progress") {
+		t.Errorf("expected not eligible during an upgrade, got %v (%s)", got.UpdateEligible, got.EligibilityNote)
+	}
+}
+
+func TestHandleSummary_ReusesInspectionWithinTTL(t *testing.T) {
+	s, _, callLog := newCancelTestServer(t, 0, "none")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	os.Remove(callLog)
+	dockerCalls := func() int {
+		data, _ := os.ReadFile(callLog)
+		return strings.Count(string(data), "\n")
+	}
+
+	first := getSummary(t, s)
+	afterFirst := dockerCalls()
+	if afterFirst == 0 {
+		t.Fatal("expected the first summary to inspect the container")
+	}
+
+	now = now.Add(summaryInspectTTL / 2)
+	if second := getSummary(t, s); !second.InspectedAt.Equal(first.InspectedAt) || dockerCalls() != afterFirst {
+		t.Errorf("expected the cached inspection within the TTL, got %d docker calls (was %d)", dockerCalls(), afterFirst)
+	}
+
+	now = now.Add(summaryInspectTTL)
+	if third := getSummary(t, s); !third.InspectedAt.After(first.InspectedAt) || dockerCalls() == afterFirst {
+		t.Error("expected a fresh inspection once the TTL expired")
+	}
+}
+
+func TestHandleSummary_DeprecatedVersion(t *testing.T) {
+	s := newHealthTestServer(t)
+	seedSummaryInspection(s, &inspect.InspectResult{
+		OverallState: inspect.StateOK,
+		UpdateInfo: &inspect.UpdateInfo{
+			CurrentVersion:        "1.0.0",
+			LatestVersion:         "1.1.0",
+			UpdateAvailable:       true,
+			CanUpdateViaDashboard: true,
+			Deprecated:            &inspect.DeprecationInfo{Reason: "end of life"},
+		},
+	})
+
+	got := getSummary(t, s)
+
+	if got.Deprecation == nil || got.Deprecation.Reason != "end of life" {
+		t.Errorf("expected the deprecation in the summary, got %+v", got.Deprecation)
+	}
+	if !got.UpdateEligible || got.OverallState != inspect.StateOK {
+		t.Errorf("expected the deprecation to be informational, got eligible=%v state=%s", got.UpdateEligible, got.OverallState)
+	}
+}
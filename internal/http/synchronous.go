@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/logger"
+	"github.com/payram/payram-updater/internal/statelock"
+)
+
+// RunUpgradeSync plans and executes an upgrade in the calling goroutine,
+// without the HTTP layer, for one-shot runners such as Kubernetes Jobs. It
+// blocks until the job reaches a terminal state and returns it.
+//
+// It holds the state directory lock throughout, so it refuses to run while a
+// daemon uses the same state directory, and first fails any upgrade a dead
+// updater left in progress (see recoverInterruptedUpgrade). If that upgrade
+// is resumed instead, RunUpgradeSync returns once it is done, with its job
+// and a plan with Resumed set, without starting the requested upgrade.
+// If planning fails no job is created:
+// the returned job is nil and the plan carries the failure. confirm, if not
+// nil, is called with the successful plan; returning false abandons the
+// upgrade without creating a job. imageRepo, if set, replaces the manifest's
+// image repo for this upgrade. Unless force is set, an upgrade within
+// MIN_UPGRADE_INTERVAL_MINUTES of the last successful one fails like a plan
+// with UPGRADE_TOO_SOON, and an upgrade to the running version returns a plan
+// with AlreadyOnTarget set and no job. skipVerify skips post-upgrade
+// verification (see validateSkipVerify); it is refused outside manual mode.
+// Ending ctx cancels the upgrade the same way an operator cancel does.
+func (s *Server) RunUpgradeSync(ctx context.Context, mode jobs.JobMode, requestedTarget, imageRepo string, force, skipVerify bool, confirm func(*UpgradePlan) bool) (*UpgradePlan, *jobs.Job, error) {
+	if skipVerify {
+		if err := validateSkipVerify(mode, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	if imageRepo != "" {
+		if err := validateImageRepo(imageRepo); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := backup.EnsureDir(s.config.Backup.Dir); err != nil {
+		return nil, nil, err
+	}
+	lock, err := statelock.Acquire(s.config.StateDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			logger.Error("Server", "RunUpgradeSync", err)
+		}
+	}()
+	if job, plan := s.recoverInterruptedUpgrade(ctx); plan != nil {
+		// The requested upgrade was not planned against what the resumed
+		// job leaves behind, so it needs a run of its own
+		s.runQueuedUpgrade(job, plan)
+		plan.Resumed = true
+		return plan, job, nil
+	}
+
+	existingJob, err := s.jobStore.LoadLatest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load latest job: %w", err)
+	}
+	if existingJob != nil && isJobActive(existingJob) {
+		return nil, nil, fmt.Errorf("an active job already exists (%s, state %s)", existingJob.JobID, existingJob.State)
+	}
+
+	if !force {
+		if wait := s.checkUpgradeInterval(); wait != "" {
+			return &UpgradePlan{
+				State:           jobs.JobStateFailed,
+				Mode:            mode,
+				RequestedTarget: requestedTarget,
+				FailureCode:     "UPGRADE_TOO_SOON",
+				Message:         wait,
+			}, nil, nil
+		}
+	}
+
+	planCtx, cancelPlan := context.WithTimeout(ctx, 30*time.Second)
+	defer cancelPlan()
+	currentVersion := s.resolveCurrentVersion(planCtx)
+	plan := s.PlanUpgrade(planCtx, mode, requestedTarget, currentVersion)
+	s.applyImageRepoOverride(plan, imageRepo)
+	s.checkDockerVersion(planCtx, plan)
+	if plan.State == jobs.JobStateFailed {
+		return plan, nil, nil
+	}
+	if !force && markAlreadyOnTarget(plan, currentVersion) {
+		return plan, nil, nil
+	}
+	if confirm != nil && !confirm(plan) {
+		return plan, nil, nil
+	}
+
+	jobID := fmt.Sprintf("job-%d", time.Now().UnixNano())
+	job := jobs.NewJob(jobID, mode, requestedTarget)
+	job.ResolvedTarget = plan.ResolvedTarget
+	job.ImageRepoOverride = plan.ImageRepoOverride
+	job.SkipVerify = skipVerify
+	job.State = jobs.JobStateReady
+	job.Message = jobs.MessageJobCreated
+	job.UpdatedAt = time.Now().UTC()
+	if err := s.jobStore.Save(job); err != nil {
+		return plan, nil, fmt.Errorf("failed to save job: %w", err)
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Starting upgrade job %s: mode=%s target=%s (resolved: %s) source=CLI (synchronous)",
+		jobID, mode, requestedTarget, plan.ResolvedTarget))
+	for _, warning := range plan.Warnings {
+		s.addJobWarning(job, warning)
+	}
+
+	// Forward the end of ctx to the upgrade's own cancellation, which keeps
+	// the point-of-no-return guarantees of an operator cancel. CancelUpgrade
+	// finds nothing to cancel until the upgrade has started, or once it is
+	// past the point of no return, so keep trying until it finishes.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		for !s.CancelUpgrade(errUpgradeInterrupted) {
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+
+	s.runQueuedUpgrade(job, plan)
+	return plan, job, nil
+}
+
+// resolveCurrentVersion returns the version of the running Payram container,
+// or "" if it cannot be determined.
+func (s *Server) resolveCurrentVersion(ctx context.Context) string {
+	resolveCtx, cancel := context.WithTimeout(ctx, time.Duration(s.config.FetchTimeoutSeconds)*time.Second)
+	defer cancel()
+	containerName, err := s.discoverContainerName(resolveCtx)
+	if err != nil {
+		return ""
+	}
+	initVersion := s.fetchPolicyInitVersion(resolveCtx)
+	version, _, err := s.resolveCoreVersion(resolveCtx, containerName, initVersion)
+	if err != nil {
+		return ""
+	}
+	return version
+}
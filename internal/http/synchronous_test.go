@@ -0,0 +1,201 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/statelock"
+)
+
+// newSyncTestServer returns a cancel test server that also plans against
+// local policy and manifest files offering 1.1.0.
+func newSyncTestServer(t *testing.T, executionMode string) (*Server, *jobs.Store, string) {
+	t.Helper()
+	s, jobStore, callLog := newCancelTestServer(t, 0, "pull")
+	s.config.ExecutionMode = executionMode
+	s.config.PolicyURL = buildPolicyFile(t, "1.1.0", []string{"1.0.0", "1.1.0"}, nil)
+	s.config.RuntimeManifestURL = buildManifestFile(t)
+	return s, jobStore, callLog
+}
+
+func TestRunUpgradeSync_RunsToCompletion(t *testing.T) {
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+
+	plan, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "latest", "", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if plan.ResolvedTarget != "1.1.0" {
+		t.Errorf("expected resolved target 1.1.0, got %q", plan.ResolvedTarget)
+	}
+	if job == nil || job.State != jobs.JobStateReady {
+		t.Fatalf("expected a READY job, got %+v", job)
+	}
+
+	saved, err := jobStore.LoadLatest()
+	if err != nil || saved.JobID != job.JobID || saved.State != jobs.JobStateReady {
+		t.Errorf("expected persisted READY job %s, got %+v (err=%v)", job.JobID, saved, err)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "source=CLI (synchronous)") {
+		t.Errorf("expected synchronous start in logs, got:\n%s", logs)
+	}
+}
+
+func TestRunUpgradeSync_ReturnsAfterResumedJob(t *testing.T) {
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+	s.config.ResumeInterruptedUpgrades = true
+	interrupted := jobs.NewJob("job-interrupted", jobs.JobModeManual, "1.1.0")
+	interrupted.ResolvedTarget = "1.1.0"
+	interrupted.State = jobs.JobStateBackingUp
+	interrupted.Checkpoint = jobs.CheckpointBackedUp
+	jobStore.Save(interrupted)
+
+	plan, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "latest", "", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if !plan.Resumed || job == nil || job.JobID != interrupted.JobID {
+		t.Fatalf("expected the resumed job to be returned, got resumed=%v job=%+v", plan.Resumed, job)
+	}
+	if saved, _ := jobStore.LoadLatest(); saved.JobID != interrupted.JobID {
+		t.Errorf("expected no new job after the resumed one, got %s", saved.JobID)
+	}
+	if logs, _ := jobStore.ReadLogs(); strings.Contains(logs, "source=CLI (synchronous)") {
+		t.Errorf("expected the requested upgrade not to start, got logs:\n%s", logs)
+	}
+}
+
+func TestRunUpgradeSync_PlanFailureCreatesNoJob(t *testing.T) {
+	s, jobStore, callLog := newSyncTestServer(t, "dry-run")
+	s.config.AllowedImageRepos = []string{"payramapp/payram-staging"}
+
+	plan, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "latest", "", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if plan.FailureCode != "IMAGE_REPO_NOT_ALLOWED" || job != nil {
+		t.Fatalf("expected an IMAGE_REPO_NOT_ALLOWED plan and no job, got %s/%+v", plan.FailureCode, job)
+	}
+	if saved, _ := jobStore.LoadLatest(); saved != nil {
+		t.Errorf("expected no persisted job, got %+v", saved)
+	}
+	assertNoDestructiveDockerCalls(t, callLog)
+}
+
+func TestRunUpgradeSync_DeclinedConfirmationCreatesNoJob(t *testing.T) {
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+
+	var confirmed *UpgradePlan
+	plan, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "latest", "", false, false, func(p *UpgradePlan) bool {
+		confirmed = p
+		return false
+	})
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if confirmed != plan || job != nil {
+		t.Fatalf("expected the plan to be offered for confirmation and no job, got %+v", job)
+	}
+	if saved, _ := jobStore.LoadLatest(); saved != nil {
+		t.Errorf("expected no persisted job, got %+v", saved)
+	}
+}
+
+func TestRunUpgradeSync_ContextCancelStopsBeforeDestructiveSteps(t *testing.T) {
+	s, _, callLog := newSyncTestServer(t, "execute")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		job *jobs.Job
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		_, job, err := s.RunUpgradeSync(ctx, jobs.JobModeManual, "1.1.0", "", false, false, nil)
+		done <- result{job, err}
+	}()
+
+	waitForPull(t, callLog)
+	cancel()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("synchronous upgrade did not stop after its context ended")
+	}
+	if res.err != nil {
+		t.Fatalf("RunUpgradeSync: %v", res.err)
+	}
+	if res.job.State != jobs.JobStateCancelled || !strings.Contains(res.job.Message, "interrupted") {
+		t.Fatalf("expected an interrupted CANCELLED job, got %s (%s)", res.job.State, res.job.Message)
+	}
+	assertNoDestructiveDockerCalls(t, callLog)
+}
+
+func TestRunUpgradeSync_RefusesWhileStateDirLocked(t *testing.T) {
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+	lock, err := statelock.Acquire(s.config.StateDir)
+	if err != nil {
+		t.Fatalf("acquire lock: %v", err)
+	}
+	defer lock.Release()
+
+	if _, _, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "latest", "", false, false, nil); err == nil {
+		t.Fatal("expected RunUpgradeSync to refuse while the state directory is locked")
+	}
+	if saved, _ := jobStore.LoadLatest(); saved != nil {
+		t.Errorf("expected no persisted job, got %+v", saved)
+	}
+}
+
+func TestRunUpgradeSync_SkipVerifyOnlyInManualMode(t *testing.T) {
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+
+	if _, _, err := s.RunUpgradeSync(context.Background(), jobs.JobModeDashboard, "latest", "", false, true, nil); err == nil {
+		t.Fatal("expected RunUpgradeSync to refuse skipVerify in dashboard mode")
+	}
+	if saved, _ := jobStore.LoadLatest(); saved != nil {
+		t.Errorf("expected no persisted job, got %+v", saved)
+	}
+
+	_, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "latest", "", false, true, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if !job.SkipVerify {
+		t.Error("expected the job to carry skipVerify")
+	}
+	events, err := s.historyStore.List(0, "upgrade", "")
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected started and final upgrade events, got %d (err=%v)", len(events), err)
+	}
+	for _, event := range events {
+		if event.Data["skipVerify"] != "true" {
+			t.Errorf("expected skipVerify in the %s event, got %+v", event.Status, event.Data)
+		}
+	}
+}
+
+func TestHandleUpgradeRun_RejectsSkipVerifyOutsideManualMode(t *testing.T) {
+	s, _, _ := newSyncTestServer(t, "dry-run")
+
+	for _, body := range []string{
+		`{"requestedTarget":"1.1.0","skipVerify":true}`,
+		`{"requestedTarget":"1.1.0","source":"CLI","mode":"dashboard","skipVerify":true}`,
+		`{"source":"CLI","mode":"manual","restartOnly":true,"skipVerify":true}`,
+	} {
+		w := httptest.NewRecorder()
+		s.HandleUpgradeRun()(w, httptest.NewRequest(http.MethodPost, "/upgrade/run", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/payram/payram-updater/internal/logger"
+)
+
+// checkUpgradeInterval returns why a new upgrade must wait, or "" when it may
+// run: MIN_UPGRADE_INTERVAL_MINUTES must have passed since the last
+// successful upgrade finished, so each upgrade has time to settle and
+// regressions time to surface before the next one.
+func (s *Server) checkUpgradeInterval() string {
+	minInterval := time.Duration(s.config.MinUpgradeIntervalMinutes) * time.Minute
+	if minInterval <= 0 || s.historyStore == nil {
+		return ""
+	}
+
+	events, err := s.historyStore.List(1, "upgrade", "succeeded")
+	if err != nil {
+		// A broken history file must not block upgrades
+		logger.Error("Server", "checkUpgradeInterval", err)
+		return ""
+	}
+	if len(events) == 0 {
+		return ""
+	}
+	finishedAt, err := time.Parse(time.RFC3339Nano, events[0].Timestamp)
+	if err != nil {
+		logger.Error("Server", "checkUpgradeInterval", err)
+		return ""
+	}
+
+	since := s.clock().Sub(finishedAt)
+	if since >= minInterval {
+		return ""
+	}
+	wait := (minInterval - since).Round(time.Minute)
+	if wait < time.Minute {
+		wait = time.Minute
+	}
+	return fmt.Sprintf("The last upgrade completed %s ago; MIN_UPGRADE_INTERVAL_MINUTES=%d requires waiting another %s. Use --force to upgrade anyway.",
+		since.Round(time.Minute), s.config.MinUpgradeIntervalMinutes, wait)
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/history"
+	"github.com/payram/payram-updater/internal/jobs"
+)
+
+// newIntervalTestServer returns a sync test server requiring 60 minutes
+// between upgrades whose last successful upgrade finished ago before now.
+func newIntervalTestServer(t *testing.T, ago time.Duration) (*Server, *jobs.Store) {
+	t.Helper()
+	s, jobStore, _ := newSyncTestServer(t, "dry-run")
+	s.config.MinUpgradeIntervalMinutes = 60
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	if err := s.historyStore.Append(history.Event{
+		Type:      "upgrade",
+		Status:    "succeeded",
+		Timestamp: now.Add(-ago).Format(time.RFC3339Nano),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// A later failure does not restart the interval
+	if err := s.historyStore.Append(history.Event{Type: "upgrade", Status: "failed"}); err != nil {
+		t.Fatal(err)
+	}
+	return s, jobStore
+}
+
+func TestCheckUpgradeInterval(t *testing.T) {
+	s, _ := newIntervalTestServer(t, 20*time.Minute)
+	msg := s.checkUpgradeInterval()
+	if !strings.Contains(msg, "completed 20m0s ago") || !strings.Contains(msg, "waiting another 40m0s") {
+		t.Errorf("expected the elapsed and remaining time, got %q", msg)
+	}
+
+	s, _ = newIntervalTestServer(t, 2*time.Hour)
+	if msg := s.checkUpgradeInterval(); msg != "" {
+		t.Errorf("expected no wait two hours after the last upgrade, got %q", msg)
+	}
+
+	s, _ = newIntervalTestServer(t, 20*time.Minute)
+	s.config.MinUpgradeIntervalMinutes = 0
+	if msg := s.checkUpgradeInterval(); msg != "" {
+		t.Errorf("expected no wait when disabled, got %q", msg)
+	}
+}
+
+func TestHandleUpgradeRun_TooSoonRejected(t *testing.T) {
+	s, jobStore := newIntervalTestServer(t, 20*time.Minute)
+
+	w := httptest.NewRecorder()
+	s.HandleUpgradeRun()(w, httptest.NewRequest(http.MethodPost, "/upgrade/run", strings.NewReader(`{"requestedTarget":"1.1.0","source":"CLI"}`)))
+
+	var resp RunResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.State != string(jobs.JobStateFailed) || resp.FailureCode != "UPGRADE_TOO_SOON" {
+		t.Fatalf("expected UPGRADE_TOO_SOON, got %+v", resp)
+	}
+	if !strings.Contains(resp.Message, "40m0s") {
+		t.Errorf("expected the remaining wait in the message, got %q", resp.Message)
+	}
+	if job, _ := jobStore.LoadLatest(); job != nil {
+		t.Errorf("expected no job, got %+v", job)
+	}
+}
+
+func TestRunUpgradeSync_TooSoonRejectedUnlessForced(t *testing.T) {
+	s, jobStore := newIntervalTestServer(t, 20*time.Minute)
+
+	plan, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if plan.FailureCode != "UPGRADE_TOO_SOON" || job != nil {
+		t.Fatalf("expected UPGRADE_TOO_SOON and no job, got %s/%+v", plan.FailureCode, job)
+	}
+
+	_, job, err = s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "", true, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if job == nil || job.State != jobs.JobStateReady {
+		t.Fatalf("expected a forced upgrade to run, got %+v", job)
+	}
+	if saved, _ := jobStore.LoadLatest(); saved == nil || saved.JobID != job.JobID {
+		t.Errorf("expected the forced job to be persisted, got %+v", saved)
+	}
+}
+
+func TestRunUpgradeSync_ProceedsAfterInterval(t *testing.T) {
+	s, _ := newIntervalTestServer(t, 2*time.Hour)
+
+	_, job, err := s.RunUpgradeSync(context.Background(), jobs.JobModeManual, "1.1.0", "", false, false, nil)
+	if err != nil {
+		t.Fatalf("RunUpgradeSync: %v", err)
+	}
+	if job == nil || job.State != jobs.JobStateReady {
+		t.Fatalf("expected the upgrade to run once the interval passed, got %+v", job)
+	}
+}
+
+func TestRunAutoUpdateOnce_WaitsForInterval(t *testing.T) {
+	var policyFetches atomic.Int32
+	policySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policyFetches.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer policySrv.Close()
+
+	s, _ := newIntervalTestServer(t, 20*time.Minute)
+	s.config.PolicyURL = policySrv.URL
+	s.runAutoUpdateOnce(context.Background())
+	if n := policyFetches.Load(); n != 0 {
+		t.Errorf("expected the auto update to skip within the interval, fetched the policy %d times", n)
+	}
+
+	s, _ = newIntervalTestServer(t, 2*time.Hour)
+	s.config.PolicyURL = policySrv.URL
+	s.runAutoUpdateOnce(context.Background())
+	if n := policyFetches.Load(); n != 1 {
+		t.Errorf("expected the auto update to check the policy once the interval passed, fetched it %d times", n)
+	}
+}
@@ -4,21 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
-	"sort"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/config"
 	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/coreclient"
 	"github.com/payram/payram-updater/internal/corecompat"
 	"github.com/payram/payram-updater/internal/diskspace"
+	"github.com/payram/payram-updater/internal/dockerexec"
 	"github.com/payram/payram-updater/internal/history"
 	"github.com/payram/payram-updater/internal/jobs"
 	"github.com/payram/payram-updater/internal/logger"
 	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/rollback"
+	"github.com/payram/payram-updater/internal/supervisor"
 )
 
 // upgradePhase represents discrete upgrade execution phases.
@@ -31,12 +38,7 @@ func (s *Server) resolveTargetContainer(ctx context.Context, job *jobs.Job, mani
 	resolved, err := resolver.Resolve(manifestData)
 	if err != nil {
 		if resErr, ok := err.(*container.ResolutionError); ok && resErr.GetFailureCode() == "CONTAINER_NAME_UNRESOLVED" {
-			imagePattern := "payramapp/payram:"
-			if s.config.ImageRepoOverride != "" {
-				imagePattern = s.config.ImageRepoOverride + ":"
-			}
-			discoverer := container.NewDiscoverer(s.config.DockerBin, imagePattern, logger.StdLogger())
-			discovered, discoverErr := discoverer.DiscoverPayramContainer(ctx)
+			discovered, discoverErr := s.payramDiscoverer().DiscoverPayramContainer(ctx)
 			if discoverErr != nil {
 				job.State = jobs.JobStateFailed
 				job.FailureCode = resErr.GetFailureCode()
@@ -67,11 +69,22 @@ func (s *Server) resolveTargetContainer(ctx context.Context, job *jobs.Job, mani
 	}
 	containerName := resolved.Name
 	s.jobStore.AppendLog(fmt.Sprintf("Target container resolved as: %s", containerName))
+	if warning := resolver.WarnOnDiscoveryMismatch(ctx, resolved, s.payramDiscoverer()); warning != "" {
+		s.addJobWarning(job, warning)
+	}
 	return containerName, true
 }
 
-// prepareUpgradeArgs extracts runtime state and builds docker run arguments.
-// Returns docker args or fails the job with appropriate error code.
+// payramDiscoverer returns a discoverer for running Payram containers, honoring
+// IMAGE_REPO_OVERRIDE.
+func (s *Server) payramDiscoverer() *container.Discoverer {
+	imagePattern := "payramapp/payram:"
+	if s.config.ImageRepoOverride != "" {
+		imagePattern = s.config.ImageRepoOverride + ":"
+	}
+	return container.NewDiscoverer(s.config.DockerBin, imagePattern, logger.StdLogger())
+}
+
 // knownArchSuffixes are the only tag suffixes treated as architecture variants.
 var knownArchSuffixes = []string{"-arm64"}
 
@@ -99,7 +112,23 @@ func baseVersionTag(tag string) string {
 	return tag
 }
 
-func (s *Server) prepareUpgradeArgs(ctx context.Context, job *jobs.Job, containerName string, manifestData *manifest.Manifest, imageTag string, archSupport map[string]string) ([]string, string, bool) {
+// tagTemplate returns the configured IMAGE_TAG_TEMPLATE.
+func (s *Server) tagTemplate() config.TagTemplate {
+	return config.TagTemplate{Template: s.config.ImageTagTemplate, Env: s.config.ImageEnv}
+}
+
+// untemplatedVersion strips the IMAGE_TAG_TEMPLATE decoration from an image
+// tag, keeping any architecture suffix.
+// e.g. "{version}-{env}" with IMAGE_ENV=prod: "1.7.0-prod-arm64" → "1.7.0-arm64"
+func (s *Server) untemplatedVersion(tag string) string {
+	suffix := archSuffixFromTag(tag)
+	return s.tagTemplate().Version(strings.TrimSuffix(tag, suffix)) + suffix
+}
+
+// prepareUpgradeArgs extracts runtime state and builds docker run arguments.
+// Returns docker args and the extracted runtime state, or fails the job with
+// appropriate error code.
+func (s *Server) prepareUpgradeArgs(ctx context.Context, job *jobs.Job, containerName string, manifestData *manifest.Manifest, imageTag string, archSupport map[string]string) ([]string, string, *container.RuntimeState, bool) {
 	s.jobStore.AppendLog("Extracting runtime state from container...")
 	inspector := container.NewInspector(s.config.DockerBin, logger.StdLogger())
 	runtimeState, err := inspector.ExtractRuntimeState(ctx, containerName)
@@ -110,35 +139,35 @@ func (s *Server) prepareUpgradeArgs(ctx context.Context, job *jobs.Job, containe
 		job.UpdatedAt = time.Now().UTC()
 		s.jobStore.Save(job)
 		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (container not modified)", job.FailureCode, job.Message))
-		return nil, "", false
+		return nil, "", nil, false
 	}
 	s.jobStore.AppendLog(fmt.Sprintf("Runtime state extracted: %d ports, %d mounts, %d env vars",
 		len(runtimeState.Ports), len(runtimeState.Mounts), len(runtimeState.Env)))
 
-	// Detect architecture suffix from the currently running container and apply
-	// it to the target tag — but only if the target version meets the minimum
-	// version for that arch variant as declared in the policy arch_support field.
-	// e.g. current=1.9.1-arm64 + target=1.9.3 → 1.9.3-arm64 (if arm64 min is 1.9.1)
-	if suffix := archSuffixFromTag(runtimeState.ImageTag); suffix != "" {
-		archKey := strings.TrimPrefix(suffix, "-") // "-arm64" → "arm64"
-		minVersion, hasMin := archSupport[archKey]
-		applySuffix := true
-		if hasMin && minVersion != "" {
-			targetV, err1 := version.NewVersion(baseVersionTag(imageTag))
-			minV, err2 := version.NewVersion(minVersion)
-			if err1 != nil || err2 != nil || targetV.LessThan(minV) {
-				applySuffix = false
-				s.jobStore.AppendLog(fmt.Sprintf("Arch suffix %s not applied: target %s is below minimum %s for this variant", suffix, imageTag, minVersion))
-			}
-		}
-		if applySuffix {
-			imageTag = imageTag + suffix
-			s.jobStore.AppendLog(fmt.Sprintf("Arch suffix detected from running container: target image tag adjusted to %s", imageTag))
+	// Preflight: warn (without blocking) about layouts the rebuilt container may not reproduce
+	if warnings := container.DetectUnmanagedLayout(runtimeState); len(warnings) > 0 {
+		for _, w := range warnings {
+			s.jobStore.AppendLog(fmt.Sprintf("WARNING: %s - %s", w.Code, w.Message))
 		}
+		s.jobStore.AppendLog("WARNING: Review the planned docker run args with 'payram-updater dry-run' if this container is not managed by the updater")
+	}
+	for _, warning := range formatManifestDivergence(container.DetectManifestDivergence(runtimeState, manifestData)) {
+		s.addJobWarning(job, warning)
+	}
+
+	// Carry the running container's architecture suffix over to the target tag
+	targetVersion := imageTag
+	imageTag, archNote := s.targetImageTag(runtimeState.ImageTag, targetVersion, archSupport)
+	if archNote != "" {
+		s.jobStore.AppendLog(archNote)
+	}
+	if s.config.ImageTagTemplate != "" {
+		s.jobStore.AppendLog(fmt.Sprintf("Image tag template %s applied: %s → %s", s.config.ImageTagTemplate, targetVersion, imageTag))
 	}
 
 	// Build docker run arguments from runtime state + manifest overlays
 	builder := container.NewDockerRunBuilder(logger.StdLogger())
+	builder.AllowedExtraFlags = s.config.AllowedExtraRunFlags
 	dockerArgs, err := builder.BuildUpgradeArgs(runtimeState, manifestData, imageTag)
 	if err != nil {
 		job.State = jobs.JobStateFailed
@@ -147,24 +176,61 @@ func (s *Server) prepareUpgradeArgs(ctx context.Context, job *jobs.Job, containe
 		job.UpdatedAt = time.Now().UTC()
 		s.jobStore.Save(job)
 		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (container not modified)", job.FailureCode, job.Message))
-		return nil, "", false
+		return nil, "", nil, false
 	}
 	s.jobStore.AppendLog("Docker run arguments built successfully (runtime parity preserved)")
-	return dockerArgs, imageTag, true
+	job.RunCommand = container.FormatRunCommand(dockerArgs)
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("Run command: %s", job.RunCommand))
+	return dockerArgs, imageTag, runtimeState, true
+}
+
+// applyArchSuffix carries the architecture suffix of the running container's
+// tag over to the target tag, but only if the target version meets the
+// minimum version for that arch variant in the policy arch_support field.
+// It returns the target tag to use and a log note ("" when there is no
+// suffix).
+// e.g. current=1.9.1-arm64 + target=1.9.3 → 1.9.3-arm64 (if arm64 min is 1.9.1)
+func applyArchSuffix(runningTag, imageTag string, archSupport map[string]string) (string, string) {
+	suffix := archSuffixFromTag(runningTag)
+	if suffix == "" {
+		return imageTag, ""
+	}
+	archKey := strings.TrimPrefix(suffix, "-") // "-arm64" → "arm64"
+	if minVersion := archSupport[archKey]; minVersion != "" {
+		targetV, err1 := version.NewVersion(baseVersionTag(imageTag))
+		minV, err2 := version.NewVersion(minVersion)
+		if err1 != nil || err2 != nil || targetV.LessThan(minV) {
+			return imageTag, fmt.Sprintf("Arch suffix %s not applied: target %s is below minimum %s for this variant", suffix, imageTag, minVersion)
+		}
+	}
+	imageTag += suffix
+	return imageTag, fmt.Sprintf("Arch suffix detected from running container: target image tag adjusted to %s", imageTag)
+}
+
+// targetImageTag returns the image tag to run version under: the
+// IMAGE_TAG_TEMPLATE expansion of version, followed by the running
+// container's arch suffix (see applyArchSuffix), and the arch log note.
+// e.g. "{version}-{env}" with IMAGE_ENV=prod: running 1.7.0-prod-arm64 + 1.8.0 → 1.8.0-prod-arm64
+func (s *Server) targetImageTag(runningTag, version string, archSupport map[string]string) (string, string) {
+	archTag, note := applyArchSuffix(runningTag, version, archSupport)
+	return s.tagTemplate().Expand(version) + strings.TrimPrefix(archTag, version), note
 }
 
-// executeDryRun logs planned upgrade steps and completes the job in dry-run mode.
-func (s *Server) executeDryRun(job *jobs.Job, imageRepo, imageTag, containerName string, dockerArgs []string) {
+// executeDryRun logs the planned upgrade steps and completes the job in dry-run mode.
+func (s *Server) executeDryRun(job *jobs.Job, imageRepo, imageTag, containerName string, dockerArgs []string, steppingStone string) {
 	s.jobStore.AppendLog("DRY-RUN mode: would execute the following steps:")
-	s.jobStore.AppendLog(fmt.Sprintf("  0. Pull image: %s:%s", imageRepo, imageTag))
-	s.jobStore.AppendLog("  1. Quiesce supervisor programs (stop non-DB processes)")
-	s.jobStore.AppendLog("  2. Create database backup")
-	s.jobStore.AppendLog(fmt.Sprintf("  3. Stop container: %s", containerName))
-	s.jobStore.AppendLog(fmt.Sprintf("  4. Remove container: %s", containerName))
-	s.jobStore.AppendLog(fmt.Sprintf("  5. Run new container: docker %s", strings.Join(dockerArgs, " ")))
-	s.jobStore.AppendLog("  6. Verify: container running")
-	s.jobStore.AppendLog("  7. Verify: /api/v1/health endpoint")
-	s.jobStore.AppendLog("  8. Verify: /api/v1/version matches target")
+	for i, step := range upgradeSteps(steppingStone) {
+		line := fmt.Sprintf("  %d. %s: %s", i+1, step.Name, step.Description)
+		if step.Destructive {
+			line += " (destructive)"
+		}
+		s.jobStore.AppendLog(line)
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("  Image: %s:%s", imageRepo, imageTag))
+	s.jobStore.AppendLog(fmt.Sprintf("  Container: %s", containerName))
+	s.jobStore.AppendLog(fmt.Sprintf("  New container: %s", container.FormatRunCommand(dockerArgs)))
 
 	job.State = jobs.JobStateReady
 	job.Message = "Dry-run validation complete"
@@ -173,18 +239,73 @@ func (s *Server) executeDryRun(job *jobs.Job, imageRepo, imageTag, containerName
 	s.jobStore.AppendLog("Dry-run complete - no changes made")
 }
 
-// preflightChecks verifies Docker daemon is running.
+// dockerPermissionNextSteps is the guidance for DOCKER_PERMISSION_DENIED.
+const dockerPermissionNextSteps = "Next steps: Docker is running but the updater cannot use its socket. Run the updater as root, or add its user to the docker group ('sudo usermod -aG docker <user>') and restart the service."
+
+// backupMountNextSteps is the guidance for BACKUP_MOUNT_MISSING.
+const backupMountNextSteps = "Next steps: Mount the backup storage (check 'findmnt --target <backup_dir>' and the symlink target of BACKUP_DIR), then retry."
+
+// lockUpgrade takes the operation lock for job's upgrade (see
+// lockOperation), failing the job if a restore runs in another process.
+func (s *Server) lockUpgrade(job *jobs.Job) (func(), bool) {
+	unlock, err := s.lockOperation("upgrade "+job.JobID, true)
+	if err != nil {
+		job.State = jobs.JobStateFailed
+		job.FailureCode, job.Message = operationLockFailure(err)
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+		return nil, false
+	}
+	return unlock, true
+}
+
+// preflightChecks verifies the backup directory's storage is mounted and the
+// Docker CLI works and the daemon is running.
 // Returns false if checks fail (job is already marked failed).
 func (s *Server) preflightChecks(ctx context.Context, job *jobs.Job, containerName string) bool {
+	s.jobStore.AppendLog("Pre-flight: Checking backup directory mount...")
+	if err := backup.CheckMount(s.config.Backup.Dir, s.config.Backup.RequireMount); err != nil {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "BACKUP_FAILED"
+		job.Message = err.Error()
+		if errors.Is(err, backup.ErrMountMissing) {
+			job.FailureCode = "BACKUP_MOUNT_MISSING"
+			job.Message = strings.TrimPrefix(job.Message, "BACKUP_MOUNT_MISSING: ")
+		}
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+		s.jobStore.AppendLog(backupMountNextSteps)
+		return false
+	}
+
+	s.jobStore.AppendLog("Pre-flight: Checking Docker binary...")
+	if err := dockerexec.CheckBinary(ctx, s.config.DockerBin); err != nil {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "DOCKER_BINARY_INVALID"
+		job.Message = err.Error()
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+		s.jobStore.AppendLog("Next steps: " + dockerexec.BinaryFixHint)
+		return false
+	}
+
 	s.jobStore.AppendLog("Pre-flight: Checking Docker daemon...")
 	if err := backup.CheckDockerDaemon(ctx, s.config.DockerBin); err != nil {
 		job.State = jobs.JobStateFailed
-		job.FailureCode = "DOCKER_DAEMON_DOWN"
+		job.FailureCode = backup.DaemonFailureCode(err)
 		job.Message = "Docker daemon is not running"
+		nextSteps := "Next steps: Start Docker daemon with 'sudo systemctl start docker' and retry."
+		if job.FailureCode == "DOCKER_PERMISSION_DENIED" {
+			job.Message = "Permission denied on the Docker socket"
+			nextSteps = dockerPermissionNextSteps
+		}
 		job.UpdatedAt = time.Now().UTC()
 		s.jobStore.Save(job)
 		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
-		s.jobStore.AppendLog("Next steps: Start Docker daemon with 'sudo systemctl start docker' and retry.")
+		s.jobStore.AppendLog(nextSteps)
 		return false
 	}
 	s.jobStore.AppendLog("Docker daemon is running")
@@ -265,77 +386,101 @@ func (s *Server) preflightChecks(ctx context.Context, job *jobs.Job, containerNa
 		return false
 	}
 	s.jobStore.AppendLog("Disk space checks passed")
+	s.warnRetentionFootprint(job, results[0].AvailableGB)
 
 	return true
 }
 
-var errSupervisorUnavailable = errors.New("supervisorctl not available")
-
-func (s *Server) supervisorctlStatus(ctx context.Context, containerName string) (string, error) {
-	cmd := exec.CommandContext(ctx, s.config.DockerBin, "exec", containerName, "supervisorctl", "status")
-	output, err := cmd.CombinedOutput()
-	if err == nil {
-		return string(output), nil
+// warnRetentionFootprint adds a job warning when keeping the configured
+// number of backups, at their recent average size, would need more space than
+// the backup directory has free. It never blocks the upgrade: today's backup
+// fits, but later ones will fail unless retention or the disk changes.
+func (s *Server) warnRetentionFootprint(job *jobs.Job, availableGB float64) {
+	if s.backupManager == nil {
+		return
 	}
-
-	outputStr := string(output)
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if exitErr.ProcessState != nil && exitErr.ProcessState.ExitCode() == 3 {
-			return outputStr, nil
-		}
+	listed, err := s.backupManager.ListBackups()
+	if err != nil {
+		return
 	}
-	if strings.Contains(outputStr, "supervisorctl: not found") ||
-		strings.Contains(outputStr, "command not found") ||
-		strings.Contains(outputStr, "executable file not found") ||
-		strings.Contains(outputStr, "No such file or directory") {
-		return "", errSupervisorUnavailable
+	// Pinned backups are outside retention and snapshot metadata files do
+	// not reflect the snapshot's size.
+	var sizes []int64
+	for _, b := range listed {
+		if !b.Pinned && b.Format != "snapshot" {
+			sizes = append(sizes, b.SizeBytes)
+		}
 	}
 
-	return "", fmt.Errorf("supervisorctl status failed: %w: %s", err, outputStr)
-}
-
-func parseSupervisorStatus(output string) map[string]string {
-	status := make(map[string]string)
-	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			continue
-		}
-		status[fields[0]] = fields[1]
+	projection, ok := diskspace.ProjectRetention(sizes, s.backupManager.Config.Retention, availableGB)
+	if !ok || !projection.Exceeds() {
+		return
 	}
-	return status
+	s.addJobWarning(job, fmt.Sprintf("keeping %d backups of ~%.2f GB needs %.2f GB more in %s, but only %.2f GB is free; later backups will fail. Lower BACKUP_RETENTION or add disk space.",
+		projection.Retention, projection.AverageGB, projection.GrowthGB, s.backupManager.Config.Dir, projection.AvailableGB))
 }
 
-func (s *Server) supervisorctlStop(ctx context.Context, containerName string, programs []string) error {
-	if len(programs) == 0 {
-		return nil
+// warnBackupOnDBFilesystem adds a job warning when the backup directory is on
+// the same filesystem as the database data mount. It never blocks the upgrade:
+// the backup still protects against a bad migration, just not a lost disk.
+func (s *Server) warnBackupOnDBFilesystem(job *jobs.Job, runtimeState *container.RuntimeState) {
+	mount := container.DBDataMount(runtimeState)
+	if mount == nil || !strings.HasPrefix(mount.Source, "/") {
+		return
 	}
-	args := append([]string{"exec", containerName, "supervisorctl", "stop"}, programs...)
-	cmd := exec.CommandContext(ctx, s.config.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("supervisorctl stop failed: %w: %s", err, strings.TrimSpace(string(output)))
+	shared, err := diskspace.SameFilesystem(s.deviceOf, s.config.Backup.Dir, mount.Source)
+	if err != nil || !shared {
+		return
 	}
-	return nil
+	s.addJobWarning(job, fmt.Sprintf("backup directory %s is on the same filesystem as the database data (%s); a failure of that disk would lose both, and backups use the database's free space. Set BACKUP_DIR to a different disk or volume.", s.config.Backup.Dir, mount.Source))
 }
 
-func (s *Server) supervisorctlStart(ctx context.Context, containerName string, programs []string) error {
-	if len(programs) == 0 {
-		return nil
+// checkVolumesExist verifies that every named volume mounted into the running
+// container still exists. A volume removed out-of-band would be recreated
+// empty by the rebuilt container, which looks like total data loss.
+// Returns false if a volume is missing (job is already marked failed).
+func (s *Server) checkVolumesExist(ctx context.Context, job *jobs.Job, runtimeState *container.RuntimeState) bool {
+	volumes := container.NamedVolumes(runtimeState)
+	if len(volumes) == 0 {
+		return true
 	}
-	args := append([]string{"exec", containerName, "supervisorctl", "start"}, programs...)
-	cmd := exec.CommandContext(ctx, s.config.DockerBin, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("supervisorctl start failed: %w: %s", err, strings.TrimSpace(string(output)))
+
+	s.jobStore.AppendLog(fmt.Sprintf("Pre-flight: Checking %d named volume(s) exist...", len(volumes)))
+	var missing []string
+	for _, volume := range volumes {
+		exists, err := s.dockerRunner.VolumeExists(ctx, volume)
+		if err != nil {
+			job.State = jobs.JobStateFailed
+			job.FailureCode = "DOCKER_ERROR"
+			job.Message = fmt.Sprintf("Failed to check volume %s: %v", volume, err)
+			job.UpdatedAt = time.Now().UTC()
+			s.jobStore.Save(job)
+			s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (container not modified)", job.FailureCode, job.Message))
+			return false
+		}
+		if !exists {
+			missing = append(missing, volume)
+		}
 	}
-	return nil
+
+	if len(missing) > 0 {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "MISSING_VOLUME"
+		job.Message = fmt.Sprintf("Named volume(s) used by the container no longer exist: %s", strings.Join(missing, ", "))
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (container not modified)", job.FailureCode, job.Message))
+		s.jobStore.AppendLog("Next steps: Do not stop or remove the container. Back up its data first (payram-updater backup create), then restore the volume before retrying.")
+		return false
+	}
+	s.jobStore.AppendLog("Named volume checks passed")
+	return true
 }
 
 func (s *Server) quiesceSupervisorPrograms(ctx context.Context, job *jobs.Job, containerName string) ([]string, bool, bool) {
-	statusOutput, err := s.supervisorctlStatus(ctx, containerName)
+	statusOutput, err := supervisor.Status(ctx, s.dockerRunner, containerName)
 	if err != nil {
-		if errors.Is(err, errSupervisorUnavailable) {
+		if errors.Is(err, supervisor.ErrUnavailable) {
 			s.jobStore.AppendLog("Supervisor not available; falling back to backup-before-stop flow")
 			return nil, false, true
 		}
@@ -348,44 +493,14 @@ func (s *Server) quiesceSupervisorPrograms(ctx context.Context, job *jobs.Job, c
 		return nil, false, false
 	}
 
-	status := parseSupervisorStatus(statusOutput)
-	excludeSet := make(map[string]struct{}, len(s.config.SupervisorExclude))
-	for _, name := range s.config.SupervisorExclude {
-		excludeSet[name] = struct{}{}
-	}
-	includeSet := make(map[string]struct{}, len(s.config.SupervisorInclude))
-	for _, name := range s.config.SupervisorInclude {
-		includeSet[name] = struct{}{}
-	}
-
-	var programsToStop []string
-	var programsStopped []string
-	for name, state := range status {
-		if len(includeSet) > 0 {
-			if _, ok := includeSet[name]; !ok {
-				continue
-			}
-		} else {
-			if _, ok := excludeSet[name]; ok {
-				continue
-			}
-		}
-
-		programsToStop = append(programsToStop, name)
-		if state == "RUNNING" || state == "STARTING" {
-			programsStopped = append(programsStopped, name)
-		}
-	}
-
+	programsToStop, programsStopped := supervisor.SelectPrograms(supervisor.ParseStatus(statusOutput), s.config.SupervisorInclude, s.config.SupervisorExclude)
 	if len(programsToStop) == 0 {
 		s.jobStore.AppendLog("No supervisor programs to stop (after filters)")
 		return nil, true, true
 	}
 
-	sort.Strings(programsToStop)
-	sort.Strings(programsStopped)
 	s.jobStore.AppendLog(fmt.Sprintf("Stopping supervisor programs: %s", strings.Join(programsToStop, ", ")))
-	if err := s.supervisorctlStop(ctx, containerName, programsToStop); err != nil {
+	if err := supervisor.Stop(ctx, s.dockerRunner, containerName, programsToStop); err != nil {
 		job.State = jobs.JobStateFailed
 		job.FailureCode = "SUPERVISORCTL_FAILED"
 		job.Message = err.Error()
@@ -404,6 +519,278 @@ func (s *Server) quiesceSupervisorPrograms(ctx context.Context, job *jobs.Job, c
 	return programsStopped, true, true
 }
 
+// quiesceAndBackup quiesces supervisor programs (if available) and takes the
+// pre-upgrade backup. On success it returns the programs left stopped for the
+// container stop; on failure or cancellation the job is already marked.
+func (s *Server) quiesceAndBackup(ctx context.Context, job *jobs.Job, containerName, imageTag, policyInitVersion string) ([]string, bool) {
+	stoppedPrograms, usedSupervisor, ok := s.quiesceSupervisorPrograms(ctx, job, containerName)
+	if s.phaseStopped(ctx, job, ok) {
+		return nil, false
+	}
+
+	if usedSupervisor {
+		_, ok = s.createPreUpgradeBackupAfterQuiesce(ctx, job, containerName, imageTag, policyInitVersion, 3, stoppedPrograms)
+		if !ok {
+			// The failed backup already restarted the quiesced programs
+			s.abortIfCancelled(ctx, job)
+			return nil, false
+		}
+		return stoppedPrograms, true
+	}
+
+	_, ok = s.createPreUpgradeBackupBeforeStop(ctx, job, containerName, imageTag, policyInitVersion)
+	if s.phaseStopped(ctx, job, ok) {
+		return nil, false
+	}
+	return nil, true
+}
+
+// backupForUpgrade quiesces and backs up like quiesceAndBackup. A job resumed
+// from CheckpointBackedUp takes a new backup too: nothing shows Payram's
+// programs stayed stopped after the interrupted run, and a restore from its
+// backup would lose whatever they have written since.
+func (s *Server) backupForUpgrade(ctx context.Context, job *jobs.Job, resumeFrom jobs.Checkpoint, containerName, imageTag, policyInitVersion string) ([]string, bool) {
+	if resumeFrom == jobs.CheckpointBackedUp && job.BackupPath != "" {
+		s.jobStore.AppendLog(fmt.Sprintf("Not reusing the pre-upgrade backup of the interrupted run (%s): Payram may have written to the database since; creating a new one", job.BackupPath))
+	}
+
+	quiesced, ok := s.quiesceAndBackup(ctx, job, containerName, imageTag, policyInitVersion)
+	if ok {
+		s.saveCheckpoint(job, jobs.CheckpointBackedUp)
+	}
+	return quiesced, ok
+}
+
+// saveCheckpoint records the upgrade phase job has just completed.
+func (s *Server) saveCheckpoint(job *jobs.Job, checkpoint jobs.Checkpoint) {
+	job.Checkpoint = checkpoint
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+}
+
+// addJobWarning records a non-fatal issue on the job, so an upgrade that
+// succeeded with caveats can be told apart from a fully clean one.
+func (s *Server) addJobWarning(job *jobs.Job, message string) {
+	job.Warnings = append(job.Warnings, message)
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("Warning: %s", message))
+}
+
+// failureLogTail is how many lines of container output a failed job keeps.
+const failureLogTail = 500
+
+// saveFailureContainerLogs saves the recent output of the job's container to
+// a file of its own, so it outlives the container and the job log that is
+// overwritten by the next upgrade. Best-effort: failures are only logged.
+func (s *Server) saveFailureContainerLogs(job *jobs.Job, containerName string) {
+	if containerName == "" || s.dockerRunner == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	output, err := s.dockerRunner.Logs(ctx, containerName, failureLogTail)
+	if err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Warning: could not capture the logs of container %s: %v", containerName, err))
+		return
+	}
+	path, err := s.jobStore.SaveContainerLog(job.JobID, []byte(output))
+	if err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Warning: could not save the logs of container %s: %v", containerName, err))
+		if path == "" {
+			return
+		}
+	}
+	job.ContainerLogPath = path
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("Saved the last %d lines of container %s output to %s", failureLogTail, containerName, path))
+}
+
+// resumeQuiescedPrograms restarts supervisor programs stopped for the backup
+// when the upgrade stops before the container is replaced.
+func (s *Server) resumeQuiescedPrograms(ctx context.Context, job *jobs.Job, containerName string, programs []string) {
+	if len(programs) == 0 {
+		return
+	}
+	if err := supervisor.Start(ctx, s.dockerRunner, containerName, programs); err != nil {
+		s.addJobWarning(job, fmt.Sprintf("failed to restart supervisor programs: %v", err))
+		return
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Supervisor programs restarted: %s", strings.Join(programs, ", ")))
+}
+
+// verifyWithWritesPaused runs verifyUpgrade with the VERIFY_PAUSE_PROGRAMS
+// supervisor programs stopped in the new container, which keeps what they
+// write during verification out of the database. The container is normally
+// started with them held (see holdProgramsForVerification); any that run
+// anyway are stopped first. They are restarted once verification passes and
+// left stopped if it fails. A job whose verification passed is still failed
+// with PAUSED_PROGRAMS_NOT_RESUMED if they cannot be restarted, since Payram
+// would otherwise run without them. A manual job run with --skip-verify
+// skips verification altogether (see skipVerification).
+func (s *Server) verifyWithWritesPaused(ctx context.Context, job *jobs.Job, containerName, imageTag, policyInitVersion string) bool {
+	held := s.verifyHeld
+	s.verifyHeld = nil
+	if job.SkipVerify && job.Mode == jobs.JobModeManual {
+		s.skipVerification(job, imageTag)
+		return true
+	}
+	paused := s.pauseWritesForVerification(ctx, job, containerName, held)
+	if !s.verifyUpgrade(ctx, job, containerName, imageTag, policyInitVersion) {
+		if len(paused) > 0 {
+			s.jobStore.AppendLog(fmt.Sprintf("Supervisor programs left stopped so the database stays as backed up: %s", strings.Join(paused, ", ")))
+			s.jobStore.AppendLog(fmt.Sprintf("To resume writes without restoring: docker exec %s supervisorctl start %s", containerName, strings.Join(paused, " ")))
+		}
+		if len(held) > 0 {
+			s.jobStore.AppendLog(fmt.Sprintf("To let them autostart again: docker exec %s rm -f %s", containerName, s.config.VerifyPauseHoldFile))
+		}
+		return false
+	}
+	if len(paused) == 0 {
+		return true
+	}
+	err := supervisor.Start(ctx, s.dockerRunner, containerName, paused)
+	if err == nil && len(held) > 0 {
+		if _, rmErr := s.dockerRunner.Exec(ctx, containerName, "rm", "-f", s.config.VerifyPauseHoldFile); rmErr != nil {
+			err = fmt.Errorf("failed to remove %s, which keeps them from autostarting: %w", s.config.VerifyPauseHoldFile, rmErr)
+		}
+	}
+	if err != nil {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "PAUSED_PROGRAMS_NOT_RESUMED"
+		job.Message = fmt.Sprintf("Verification of %s passed but the paused supervisor programs %s could not be restarted: %v", imageTag, strings.Join(paused, ", "), err)
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s", job.FailureCode, job.Message))
+		return false
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Verification passed; supervisor programs resumed: %s", strings.Join(paused, ", ")))
+	return true
+}
+
+// validateSkipVerify checks that skipping post-upgrade verification was
+// asked for where it is allowed: only by an operator in manual mode, and only
+// for an upgrade (a restart has nothing else to go by).
+func validateSkipVerify(mode jobs.JobMode, restartOnly bool) error {
+	if mode != jobs.JobModeManual {
+		return fmt.Errorf("skipVerify is only allowed in MANUAL mode, not %s", mode)
+	}
+	if restartOnly {
+		return fmt.Errorf("skipVerify cannot be combined with restartOnly")
+	}
+	return nil
+}
+
+// skipVerification records that the health and version checks of imageTag
+// were skipped at the operator's request: a job warning, so the job ends
+// READY with caveats, and a verification_skipped history event. Nothing has
+// confirmed that the new version works.
+func (s *Server) skipVerification(job *jobs.Job, imageTag string) {
+	message := fmt.Sprintf("post-upgrade verification of %s was SKIPPED (--skip-verify): health and version were not checked, so the upgrade is unverified", imageTag)
+	s.jobStore.AppendLog("WARNING: verification skipped at the operator's request")
+	s.addJobWarning(job, message)
+	s.recordHistory(history.Event{
+		Type:    "upgrade",
+		Status:  "verification_skipped",
+		Message: message,
+		Data: map[string]string{
+			"jobId":     job.JobID,
+			"mode":      string(job.Mode),
+			"imageTag":  imageTag,
+			"requested": job.RequestedTarget,
+		},
+	})
+}
+
+// holdProgramsForVerification copies a supervisord include into the created,
+// not yet started, container at VERIFY_PAUSE_HOLD_FILE that turns autostart
+// off for the VERIFY_PAUSE_PROGRAMS, so they do not write to the database
+// before verification passes. Every listed program must exist in the image:
+// supervisord rejects a program section without a command. If the include
+// cannot be copied, the programs start with the container and are stopped
+// right after (see pauseWritesForVerification).
+func (s *Server) holdProgramsForVerification(ctx context.Context, job *jobs.Job, containerName string) {
+	s.verifyHeld = nil
+	programs := s.config.VerifyPausePrograms
+	if len(programs) == 0 || s.config.VerifyPauseHoldFile == "" || (job.SkipVerify && job.Mode == jobs.JobModeManual) {
+		return
+	}
+
+	var conf strings.Builder
+	for _, name := range programs {
+		fmt.Fprintf(&conf, "[program:%s]\nautostart=false\n\n", name)
+	}
+	err := func() error {
+		f, err := os.CreateTemp("", "payram-updater-hold-*.conf")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(conf.String()); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return s.dockerRunner.Run(ctx, []string{"cp", f.Name(), containerName + ":" + s.config.VerifyPauseHoldFile})
+	}()
+	if err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Could not hold supervisor programs in the new container (%v); stopping them after it starts instead", err))
+		return
+	}
+	s.verifyHeld = append([]string(nil), programs...)
+	s.jobStore.AppendLog(fmt.Sprintf("Starting the new container with supervisor programs held until verification passes: %s", strings.Join(programs, ", ")))
+}
+
+// pauseWritesForVerification returns the VERIFY_PAUSE_PROGRAMS stopped in the
+// new container: held, the programs it was started with held, and any that
+// are running, which it stops. Those run from the container start until this
+// stop when the hold could not be set up, and anything they write in that
+// window (typically a few seconds) reaches the database and is not in the
+// pre-upgrade backup. Failing to pause is a warning, not a failure: the
+// container has already been replaced.
+func (s *Server) pauseWritesForVerification(ctx context.Context, job *jobs.Job, containerName string, held []string) []string {
+	if len(s.config.VerifyPausePrograms) == 0 {
+		return nil
+	}
+	statusOutput, err := supervisor.Status(ctx, s.dockerRunner, containerName)
+	if err != nil {
+		s.addJobWarning(job, fmt.Sprintf("could not pause writes during verification: %v", err))
+		return held
+	}
+	status := supervisor.ParseStatus(statusOutput)
+
+	var programs []string
+	for _, name := range s.config.VerifyPausePrograms {
+		state, ok := status[name]
+		if !ok {
+			s.jobStore.AppendLog(fmt.Sprintf("Supervisor program %s not found in the new container; not pausing it", name))
+			continue
+		}
+		if state == "RUNNING" || state == "STARTING" {
+			programs = append(programs, name)
+		}
+	}
+	if len(programs) == 0 {
+		return held
+	}
+
+	if err := supervisor.Stop(ctx, s.dockerRunner, containerName, programs); err != nil {
+		s.addJobWarning(job, fmt.Sprintf("could not pause writes during verification: %v", err))
+		return held
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Paused supervisor programs until verification passes: %s", strings.Join(programs, ", ")))
+	paused := append([]string(nil), held...)
+	for _, name := range programs {
+		if !slices.Contains(paused, name) {
+			paused = append(paused, name)
+		}
+	}
+	return paused
+}
+
 func (s *Server) createPreUpgradeBackupBeforeStop(ctx context.Context, job *jobs.Job, containerName, imageTag, policyInitVersion string) (string, bool) {
 	// Get current version for backup metadata
 	currentVersion := "unknown"
@@ -458,12 +845,28 @@ func (s *Server) createPreUpgradeBackupBeforeStop(ctx context.Context, job *jobs
 		switch backupResult.FailureCode {
 		case "DOCKER_DAEMON_DOWN":
 			s.jobStore.AppendLog("Next steps: Start Docker daemon with 'sudo systemctl start docker' and retry.")
+		case "DOCKER_PERMISSION_DENIED":
+			s.jobStore.AppendLog(dockerPermissionNextSteps)
 		case "CONTAINER_NOT_FOUND":
 			s.jobStore.AppendLog(fmt.Sprintf("Next steps: Ensure container '%s' is running and retry.", containerName))
 		case "INVALID_DB_CONFIG":
 			s.jobStore.AppendLog("Next steps: Verify container has POSTGRES_* environment variables set.")
+		case "DB_UNREACHABLE":
+			s.jobStore.AppendLog("Next steps: Check that the database is running and reachable with the container's POSTGRES_* credentials, then retry.")
+		case "BACKUP_SUSPICIOUSLY_SMALL":
+			s.jobStore.AppendLog("Next steps: Compare a manual pg_dump with the database size and check POSTGRES_DATABASE, then retry.")
+		case "BACKUP_VERIFY_FAILED":
+			s.jobStore.AppendLog("Next steps: Check free space in BACKUP_DIR and that a manual pg_dump completes, then retry.")
 		case "BACKUP_TIMEOUT":
 			s.jobStore.AppendLog("Next steps: Check database connectivity and size. Increase timeout if needed.")
+		case "PRE_BACKUP_HOOK_FAILED":
+			s.jobStore.AppendLog("Next steps: Fix or remove PRE_BACKUP_HOOK and retry.")
+		case "BACKUP_SELECTION_INVALID":
+			s.jobStore.AppendLog("Next steps: Fix BACKUP_DATABASE, BACKUP_INCLUDE_SCHEMAS or BACKUP_EXCLUDE_SCHEMAS to name existing databases and schemas, then retry.")
+		case "SNAPSHOT_FAILED":
+			s.jobStore.AppendLog("Next steps: Run BACKUP_SNAPSHOT_COMMAND by hand to see why it fails, or set BACKUP_STRATEGY=dump, then retry.")
+		case "BACKUP_MOUNT_MISSING":
+			s.jobStore.AppendLog(backupMountNextSteps)
 		default:
 			s.jobStore.AppendLog("Next steps: Check logs and database connectivity, then retry.")
 		}
@@ -479,6 +882,9 @@ func (s *Server) createPreUpgradeBackupBeforeStop(ctx context.Context, job *jobs
 		}
 		s.jobStore.AppendLog(fmt.Sprintf("Database: %s@%s:%s (%s)", backupResult.DBConfig.Database, backupResult.DBConfig.Host, backupResult.DBConfig.Port, dbType))
 	}
+	if backupResult.SnapshotID != "" {
+		s.jobStore.AppendLog(fmt.Sprintf("Volume snapshot: %s", backupResult.SnapshotID))
+	}
 	backupData := map[string]string{
 		"jobId":         job.JobID,
 		"fromVersion":   currentVersion,
@@ -491,6 +897,9 @@ func (s *Server) createPreUpgradeBackupBeforeStop(ctx context.Context, job *jobs
 		backupData["dbPort"] = backupResult.DBConfig.Port
 		backupData["dbName"] = backupResult.DBConfig.Database
 	}
+	if backupResult.SnapshotID != "" {
+		backupData["snapshotId"] = backupResult.SnapshotID
+	}
 	s.recordHistory(history.Event{
 		Type:    "backup",
 		Status:  "succeeded",
@@ -499,8 +908,8 @@ func (s *Server) createPreUpgradeBackupBeforeStop(ctx context.Context, job *jobs
 	})
 
 	// Prune old backups (using legacy manager for retention logic)
-	if _, err := s.backupManager.PruneBackups(s.backupManager.Config.Retention); err != nil {
-		s.jobStore.AppendLog(fmt.Sprintf("Warning: failed to prune old backups: %v", err))
+	if _, err := s.pruneUpgradeBackups(); err != nil {
+		s.addJobWarning(job, fmt.Sprintf("failed to prune old backups: %v", err))
 	}
 
 	return backupResult.Path, true
@@ -555,6 +964,9 @@ func (s *Server) createPreUpgradeBackupAfterQuiesce(ctx context.Context, job *jo
 				}
 				s.jobStore.AppendLog(fmt.Sprintf("Database: %s@%s:%s (%s)", backupResult.DBConfig.Database, backupResult.DBConfig.Host, backupResult.DBConfig.Port, dbType))
 			}
+			if backupResult.SnapshotID != "" {
+				s.jobStore.AppendLog(fmt.Sprintf("Volume snapshot: %s", backupResult.SnapshotID))
+			}
 			backupData := map[string]string{
 				"jobId":         job.JobID,
 				"fromVersion":   currentVersion,
@@ -567,6 +979,9 @@ func (s *Server) createPreUpgradeBackupAfterQuiesce(ctx context.Context, job *jo
 				backupData["dbPort"] = backupResult.DBConfig.Port
 				backupData["dbName"] = backupResult.DBConfig.Database
 			}
+			if backupResult.SnapshotID != "" {
+				backupData["snapshotId"] = backupResult.SnapshotID
+			}
 			s.recordHistory(history.Event{
 				Type:    "backup",
 				Status:  "succeeded",
@@ -575,8 +990,8 @@ func (s *Server) createPreUpgradeBackupAfterQuiesce(ctx context.Context, job *jo
 			})
 
 			// Prune old backups (using legacy manager for retention logic)
-			if _, err := s.backupManager.PruneBackups(s.backupManager.Config.Retention); err != nil {
-				s.jobStore.AppendLog(fmt.Sprintf("Warning: failed to prune old backups: %v", err))
+			if _, err := s.pruneUpgradeBackups(); err != nil {
+				s.addJobWarning(job, fmt.Sprintf("failed to prune old backups: %v", err))
 			}
 
 			return backupResult.Path, true
@@ -611,21 +1026,39 @@ func (s *Server) createPreUpgradeBackupAfterQuiesce(ctx context.Context, job *jo
 	switch lastResult.FailureCode {
 	case "DOCKER_DAEMON_DOWN":
 		s.jobStore.AppendLog("Next steps: Start Docker daemon with 'sudo systemctl start docker' and retry.")
+	case "DOCKER_PERMISSION_DENIED":
+		s.jobStore.AppendLog(dockerPermissionNextSteps)
 	case "CONTAINER_NOT_FOUND":
 		s.jobStore.AppendLog(fmt.Sprintf("Next steps: Ensure container '%s' exists and retry.", containerName))
 	case "INVALID_DB_CONFIG":
 		s.jobStore.AppendLog("Next steps: Verify container has POSTGRES_* environment variables set.")
+	case "DB_UNREACHABLE":
+		s.jobStore.AppendLog("Next steps: Check that the database is running and reachable with the container's POSTGRES_* credentials, then retry.")
+	case "BACKUP_SUSPICIOUSLY_SMALL":
+		s.jobStore.AppendLog("Next steps: Compare a manual pg_dump with the database size and check POSTGRES_DATABASE, then retry.")
+	case "BACKUP_VERIFY_FAILED":
+		s.jobStore.AppendLog("Next steps: Check free space in BACKUP_DIR and that a manual pg_dump completes, then retry.")
 	case "BACKUP_TIMEOUT":
 		s.jobStore.AppendLog("Next steps: Check database connectivity and size. Increase timeout if needed.")
+	case "PRE_BACKUP_HOOK_FAILED":
+		s.jobStore.AppendLog("Next steps: Fix or remove PRE_BACKUP_HOOK and retry.")
+	case "BACKUP_SELECTION_INVALID":
+		s.jobStore.AppendLog("Next steps: Fix BACKUP_DATABASE, BACKUP_INCLUDE_SCHEMAS or BACKUP_EXCLUDE_SCHEMAS to name existing databases and schemas, then retry.")
+	case "SNAPSHOT_FAILED":
+		s.jobStore.AppendLog("Next steps: Run BACKUP_SNAPSHOT_COMMAND by hand to see why it fails, or set BACKUP_STRATEGY=dump, then retry.")
+	case "BACKUP_MOUNT_MISSING":
+		s.jobStore.AppendLog(backupMountNextSteps)
 	default:
 		s.jobStore.AppendLog("Next steps: Check logs and database connectivity, then retry.")
 	}
 
-	if err := s.supervisorctlStart(ctx, containerName, stoppedPrograms); err != nil {
-		s.jobStore.AppendLog(fmt.Sprintf("Warning: failed to restart supervisor programs: %v", err))
+	// Undo the quiesce even if the upgrade was cancelled
+	ctx = context.WithoutCancel(ctx)
+	if err := supervisor.Start(ctx, s.dockerRunner, containerName, stoppedPrograms); err != nil {
+		s.addJobWarning(job, fmt.Sprintf("failed to restart supervisor programs: %v", err))
 		s.jobStore.AppendLog("Attempting to restart container as last resort...")
 		if restartErr := s.dockerRunner.Restart(ctx, containerName); restartErr != nil {
-			s.jobStore.AppendLog(fmt.Sprintf("Warning: failed to restart container: %v", restartErr))
+			s.addJobWarning(job, fmt.Sprintf("failed to restart container: %v", restartErr))
 		}
 		return "", false
 	}
@@ -636,7 +1069,80 @@ func (s *Server) createPreUpgradeBackupAfterQuiesce(ctx context.Context, job *jo
 	return "", false
 }
 
-// pullUpgradeImage pulls the target image before stopping the container.
+// Image pull retries. A pull that drops mid-transfer is retried; docker keeps
+// the layers that completed, so each retry resumes rather than restarts.
+const (
+	pullAttempts       = 3
+	pullInitialBackoff = 5 * time.Second
+)
+
+// nonRetryablePullErrors are docker pull errors that another attempt cannot
+// fix: bad credentials, an image or tag that does not exist, or a registry
+// rate limit that further attempts would only use up.
+var nonRetryablePullErrors = []error{
+	dockerexec.ErrRateLimited,
+	dockerexec.ErrRegistryAuth,
+	dockerexec.ErrImageNotFound,
+	dockerexec.ErrInvalidReference,
+}
+
+// isRetryablePullError reports whether a failed docker pull is worth retrying.
+// Anything not known to be permanent is treated as a transient network error.
+func isRetryablePullError(err error) bool {
+	for _, permanent := range nonRetryablePullErrors {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// rateLimitResetPattern matches the reset time some registries append to a
+// rate-limit error, either as seconds to wait or as a timestamp. The first
+// group is the keyword, the second the rest of the line.
+var rateLimitResetPattern = regexp.MustCompile(`(?i)(retry-after|ratelimit-reset|resets? at|resets? in)[:=]?\s*(.*)`)
+
+// rateLimitValuePattern matches a reset value that is not an HTTP-date.
+var rateLimitValuePattern = regexp.MustCompile(`^[^\s,;]+`)
+
+// isRateLimitedPullError reports whether a failed docker pull hit the
+// registry's pull rate limit (Docker Hub answers "toomanyrequests").
+func isRateLimitedPullError(err error) bool {
+	return errors.Is(err, dockerexec.ErrRateLimited)
+}
+
+// rateLimitReset returns when the registry rate limit resets as found in a
+// pull error, e.g. "in 1h0m0s" or "at 2026-01-02T15:04:05Z", or "" if the
+// error does not say. A Retry-After HTTP-date is rendered as RFC 3339.
+func rateLimitReset(err error) string {
+	m := rateLimitResetPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return ""
+	}
+	keyword, rest := strings.ToLower(m[1]), m[2]
+	if len(rest) >= len(http.TimeFormat) {
+		if at, parseErr := http.ParseTime(rest[:len(http.TimeFormat)]); parseErr == nil {
+			return "at " + at.UTC().Format(time.RFC3339)
+		}
+	}
+	value := rateLimitValuePattern.FindString(rest)
+	if value == "" {
+		return ""
+	}
+	if strings.HasSuffix(keyword, " at") {
+		return "at " + value
+	}
+	if secs, convErr := strconv.Atoi(value); convErr == nil {
+		return "in " + (time.Duration(secs) * time.Second).String()
+	}
+	if strings.HasSuffix(keyword, " in") {
+		return "in " + value
+	}
+	return "at " + value
+}
+
+// pullUpgradeImage pulls the target image before stopping the container,
+// retrying transient failures with exponential backoff.
 // Returns false if the pull fails.
 func (s *Server) pullUpgradeImage(ctx context.Context, job *jobs.Job, imageRepo, imageTag string) bool {
 	job.State = jobs.JobStateExecuting
@@ -647,17 +1153,49 @@ func (s *Server) pullUpgradeImage(ctx context.Context, job *jobs.Job, imageRepo,
 	s.jobStore.Save(job)
 	s.jobStore.AppendLog(fmt.Sprintf("Pulling image: %s", imageWithTag))
 
-	if err := s.dockerRunner.Pull(ctx, imageWithTag); err != nil {
+	var err error
+	backoff := s.pullBackoff
+	for attempt := 1; attempt <= pullAttempts; attempt++ {
+		if err = s.dockerRunner.Pull(ctx, imageWithTag); err == nil {
+			s.jobStore.AppendLog("Image pulled successfully")
+			return true
+		}
+		if !isRetryablePullError(err) || attempt == pullAttempts || ctx.Err() != nil {
+			break
+		}
+		s.jobStore.AppendLog(fmt.Sprintf("Pull attempt %d/%d failed: %v (retrying in %s)", attempt, pullAttempts, err, backoff))
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+
+	if isRateLimitedPullError(err) {
+		reset := "once the limit resets"
+		if when := rateLimitReset(err); when != "" {
+			reset = "after the limit resets " + when
+		}
 		job.State = jobs.JobStateFailed
-		job.FailureCode = "DOCKER_PULL_FAILED"
-		job.Message = fmt.Sprintf("Failed to pull image: %v", err)
+		job.FailureCode = "REGISTRY_RATE_LIMITED"
+		job.Message = fmt.Sprintf("Registry pull rate limit reached for %s; retry %s: %v", imageWithTag, reset, err)
 		job.UpdatedAt = time.Now().UTC()
 		s.jobStore.Save(job)
 		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (container still running)", job.FailureCode, job.Message))
+		s.jobStore.AppendLog(fmt.Sprintf("Next steps: Run 'docker login' on this host so pulls count against an account's higher limit, or retry %s.", reset))
 		return false
 	}
-	s.jobStore.AppendLog("Image pulled successfully")
-	return true
+
+	job.State = jobs.JobStateFailed
+	job.FailureCode = "DOCKER_PULL_FAILED"
+	job.Message = fmt.Sprintf("Failed to pull image: %v", err)
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (container still running)", job.FailureCode, job.Message))
+	return false
 }
 
 // stopContainerForUpgrade stops the container before replacing it.
@@ -708,7 +1246,7 @@ func (s *Server) replaceContainer(ctx context.Context, job *jobs.Job, containerN
 	s.jobStore.Save(job)
 	s.jobStore.AppendLog(fmt.Sprintf("Running new container: %s", containerName))
 
-	if err := s.dockerRunner.Run(ctx, dockerArgs); err != nil {
+	if err := s.runNewContainer(ctx, job, containerName, dockerArgs); err != nil {
 		job.State = jobs.JobStateFailed
 		job.FailureCode = "DOCKER_ERROR"
 		job.Message = fmt.Sprintf("Failed to run container: %v", err)
@@ -719,7 +1257,30 @@ func (s *Server) replaceContainer(ctx context.Context, job *jobs.Job, containerN
 	}
 	s.jobStore.AppendLog("Container started successfully")
 
-	// Step 3: Verify container is running
+	return s.checkReplacedContainer(ctx, job, containerName, dockerArgs)
+}
+
+// runNewContainer runs the new container from dockerArgs. With
+// VERIFY_PAUSE_PROGRAMS set it is created and started in two steps instead,
+// so the programs can be held in between (see holdProgramsForVerification).
+func (s *Server) runNewContainer(ctx context.Context, job *jobs.Job, containerName string, dockerArgs []string) error {
+	s.verifyHeld = nil
+	createArgs := hotSwapCreateArgs(dockerArgs, containerName, containerName)
+	if len(s.config.VerifyPausePrograms) == 0 || createArgs == nil {
+		return s.dockerRunner.Run(ctx, dockerArgs)
+	}
+	if err := s.dockerRunner.Run(ctx, createArgs); err != nil {
+		return err
+	}
+	s.holdProgramsForVerification(ctx, job, containerName)
+	return s.dockerRunner.Start(ctx, containerName)
+}
+
+// checkReplacedContainer verifies that the new container is running and that
+// the env, mounts and ports of its run command took effect. Returns false if
+// not (job is already marked failed).
+func (s *Server) checkReplacedContainer(ctx context.Context, job *jobs.Job, containerName string, dockerArgs []string) bool {
+	// Verify container is running
 	job.State = jobs.JobStateVerifying
 	job.Message = "Verifying container status"
 	job.UpdatedAt = time.Now().UTC()
@@ -747,6 +1308,65 @@ func (s *Server) replaceContainer(ctx context.Context, job *jobs.Job, containerN
 		return false
 	}
 	s.jobStore.AppendLog("Container is running")
+
+	// Verify the env, mounts and ports the builder asked for took effect
+	state, err := container.NewInspector(s.config.DockerBin, logger.StdLogger()).ExtractRuntimeState(ctx, containerName)
+	if err != nil {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "DOCKER_ERROR"
+		job.Message = fmt.Sprintf("Failed to inspect runtime state of new container: %v", err)
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (manual recovery required)", job.FailureCode, job.Message))
+		return false
+	}
+	if drift := container.RuntimeDrift(dockerArgs, state); len(drift) > 0 {
+		for _, problem := range drift {
+			s.jobStore.AppendLog(fmt.Sprintf("Runtime drift: %s", problem))
+		}
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "RUNTIME_DRIFT"
+		job.Message = fmt.Sprintf("New container does not match its run command: %s", strings.Join(drift, "; "))
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (manual recovery required)", job.FailureCode, job.Message))
+		s.jobStore.AppendLog("Next steps: compare runCommand in 'payram-updater status' with 'docker inspect " + containerName + "'")
+		return false
+	}
+	s.jobStore.AppendLog("Container runtime state matches the run command")
+	return true
+}
+
+// Health verification after a container replacement.
+const (
+	healthCheckAttempts = 6
+	healthCheckInterval = 2 * time.Second
+	healthCheckTimeout  = 3 * time.Second
+)
+
+// healthVerifyWindow is the longest verifyUpgrade can take: every health attempt
+// timing out, the sleeps between them, and the final version check.
+const healthVerifyWindow = healthCheckAttempts*healthCheckTimeout + (healthCheckAttempts-1)*healthCheckInterval + healthCheckTimeout
+
+// failIfCrashLooping fails the job with CONTAINER_CRASHLOOP when the
+// container's restart count rose above restartsBefore, whether or not its
+// health checks passed. A count that cannot be read is not a failure.
+func (s *Server) failIfCrashLooping(ctx context.Context, job *jobs.Job, containerName string, restartsBefore int) bool {
+	restartsAfter, err := s.dockerRunner.RestartCount(ctx, containerName)
+	if err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Could not read container restart count: %v (crash-loop detection skipped)", err))
+		return false
+	}
+	if restartsAfter <= restartsBefore {
+		return false
+	}
+
+	job.State = jobs.JobStateFailed
+	job.FailureCode = "CONTAINER_CRASHLOOP"
+	job.Message = fmt.Sprintf("Container restarted %d time(s) during health verification (restart count %d -> %d)", restartsAfter-restartsBefore, restartsBefore, restartsAfter)
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (manual recovery required)", job.FailureCode, job.Message))
 	return true
 }
 
@@ -757,17 +1377,46 @@ func (s *Server) verifyUpgrade(ctx context.Context, job *jobs.Job, containerName
 	job.UpdatedAt = time.Now().UTC()
 	s.jobStore.Save(job)
 
-	useLegacyHealth := s.shouldUseLegacyForTarget(policyInitVersion, baseVersionTag(imageTag))
+	targetVersion := baseVersionTag(s.untemplatedVersion(imageTag))
+	useLegacyHealth := s.shouldUseLegacyForTarget(policyInitVersion, targetVersion)
 	if useLegacyHealth {
-		s.jobStore.AppendLog("Verifying legacy health endpoint (6 retries, 2s apart)...")
+		s.jobStore.AppendLog(fmt.Sprintf("Verifying legacy health endpoint (%d retries, %s apart)...", healthCheckAttempts, s.healthInterval))
 	} else {
-		s.jobStore.AppendLog("Verifying /api/v1/health endpoint (6 retries, 2s apart)...")
+		s.jobStore.AppendLog(fmt.Sprintf("Verifying /api/v1/health endpoint (%d retries, %s apart)...", healthCheckAttempts, s.healthInterval))
+	}
+
+	// A crash-looping container is "running" between restarts and can pass a
+	// lucky health check, so compare its restart count across the health window
+	restartsBefore, restartErr := s.dockerRunner.RestartCount(ctx, containerName)
+	if restartErr != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Could not read container restart count: %v (crash-loop detection skipped)", restartErr))
+	}
+
+	// A migration of the new version can keep the health endpoint down for
+	// longer than the retries allow; while it is still running the retries are
+	// extended, and if it stalls the upgrade fails at once
+	migration := s.newMigrationMonitor(ctx, containerName)
+	if migration != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Watching pg_stat_activity for a stalled migration (health wait extended for up to %s while one runs)", migration.maxWait))
 	}
 
 	// Health check with retries
 	healthOK := false
-	for attempt := 1; attempt <= 6; attempt++ {
-		healthCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	attempts := healthCheckAttempts
+	// followMigration returns false if the job failed with a stalled or
+	// timed-out migration after a failed attempt
+	followMigration := func(attempt int) bool {
+		if migration == nil {
+			return true
+		}
+		extend, ok := s.watchMigration(ctx, job, migration, containerName)
+		if extend && attempt == attempts {
+			attempts++
+		}
+		return ok
+	}
+	for attempt := 1; attempt <= attempts; attempt++ {
+		healthCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
 		var healthResp *coreclient.HealthResponse
 		var err error
 		if useLegacyHealth {
@@ -786,8 +1435,11 @@ func (s *Server) verifyUpgrade(ctx context.Context, job *jobs.Job, containerName
 			// Validate db field only if present
 			if healthResp.DB != "" && healthResp.DB != "ok" {
 				s.jobStore.AppendLog(fmt.Sprintf("Health check attempt %d: status ok but db=%s (retrying...)", attempt, healthResp.DB))
-				if attempt < 6 {
-					time.Sleep(2 * time.Second)
+				if !followMigration(attempt) {
+					return false
+				}
+				if attempt < attempts {
+					time.Sleep(s.healthInterval)
 				}
 				continue
 			}
@@ -801,18 +1453,26 @@ func (s *Server) verifyUpgrade(ctx context.Context, job *jobs.Job, containerName
 			break
 		}
 
-		if attempt < 6 {
+		if !followMigration(attempt) {
+			return false
+		}
+
+		if attempt < attempts {
 			s.jobStore.AppendLog(fmt.Sprintf("Health check attempt %d failed: %v (retrying...)", attempt, err))
-			time.Sleep(2 * time.Second)
+			time.Sleep(s.healthInterval)
 		} else {
 			s.jobStore.AppendLog(fmt.Sprintf("Health check attempt %d failed: %v", attempt, err))
 		}
 	}
 
+	if restartErr == nil && s.failIfCrashLooping(ctx, job, containerName, restartsBefore) {
+		return false
+	}
+
 	if !healthOK {
 		job.State = jobs.JobStateFailed
 		job.FailureCode = "HEALTHCHECK_FAILED"
-		job.Message = "Health check failed after 6 attempts"
+		job.Message = fmt.Sprintf("Health check failed after %d attempts", attempts)
 		job.UpdatedAt = time.Now().UTC()
 		s.jobStore.Save(job)
 		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (manual recovery required)", job.FailureCode, job.Message))
@@ -830,7 +1490,7 @@ func (s *Server) verifyUpgrade(ctx context.Context, job *jobs.Job, containerName
 		s.jobStore.AppendLog("Verifying /api/v1/version matches target...")
 	}
 
-	versionCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	versionCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
 	var versionResp *coreclient.VersionResponse
 	var err error
 	if useLegacyHealth {
@@ -855,7 +1515,7 @@ func (s *Server) verifyUpgrade(ctx context.Context, job *jobs.Job, containerName
 		return false
 	}
 
-	if versionResp.Version != baseVersionTag(imageTag) {
+	if !corecompat.SameVersion(versionResp.Version, targetVersion) {
 		job.State = jobs.JobStateFailed
 		job.FailureCode = "VERSION_MISMATCH"
 		job.Message = fmt.Sprintf("Version mismatch: expected %s, got %s", imageTag, versionResp.Version)
@@ -865,23 +1525,157 @@ func (s *Server) verifyUpgrade(ctx context.Context, job *jobs.Job, containerName
 		return false
 	}
 	s.jobStore.AppendLog(fmt.Sprintf("Version verified: %s", versionResp.Version))
+
+	if s.config.VerifyURL != "" {
+		return s.verifySecondaryURL(ctx, job, imageTag, targetVersion, useLegacyHealth)
+	}
 	return true
 }
 
-// finalizeUpgrade marks job as complete and prunes old images.
-func (s *Server) finalizeUpgrade(ctx context.Context, job *jobs.Job, imageRepo, imageTag string) {
+// verifySecondaryURL repeats the health and version verification against
+// VERIFY_URL, the address clients reach Payram through (e.g. an HA virtual
+// IP), which can be broken while the local port is healthy. Legacy targets
+// only have their health checked there, as their version comes from the
+// local container's labels.
+// Returns false if verification fails (job is already marked failed).
+func (s *Server) verifySecondaryURL(ctx context.Context, job *jobs.Job, imageTag, targetVersion string, useLegacyHealth bool) bool {
+	verifyURL := s.config.VerifyURL
+	client := coreclient.NewClient(verifyURL)
+	job.Message = "Verifying VERIFY_URL"
+	job.UpdatedAt = time.Now().UTC()
+	s.jobStore.Save(job)
+	s.jobStore.AppendLog(fmt.Sprintf("Verifying health through VERIFY_URL %s (%d retries, %s apart)...", verifyURL, healthCheckAttempts, s.healthInterval))
+
+	var err error
+	for attempt := 1; attempt <= healthCheckAttempts; attempt++ {
+		healthCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		if useLegacyHealth {
+			err = corecompat.LegacyHealth(healthCtx, client.BaseURL)
+		} else {
+			var healthResp *coreclient.HealthResponse
+			healthResp, err = client.Health(healthCtx)
+			if err == nil && healthResp.Status != "ok" {
+				err = fmt.Errorf("status=%s", healthResp.Status)
+			} else if err == nil && healthResp.DB != "" && healthResp.DB != "ok" {
+				err = fmt.Errorf("status ok but db=%s", healthResp.DB)
+			}
+		}
+		cancel()
+
+		if err == nil {
+			s.jobStore.AppendLog(fmt.Sprintf("VERIFY_URL health check passed on attempt %d", attempt))
+			break
+		}
+		s.jobStore.AppendLog(fmt.Sprintf("VERIFY_URL health check attempt %d failed: %v", attempt, err))
+		if attempt < healthCheckAttempts {
+			time.Sleep(s.healthInterval)
+		}
+	}
+	if err != nil {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "HEALTHCHECK_FAILED"
+		job.Message = fmt.Sprintf("Health check through VERIFY_URL %s failed after %d attempts (the local endpoint is healthy): %v", verifyURL, healthCheckAttempts, err)
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (manual recovery required)", job.FailureCode, job.Message))
+		return false
+	}
+
+	if useLegacyHealth {
+		s.jobStore.AppendLog("VERIFY_URL version check skipped: the legacy target reports its version through container labels only")
+		return true
+	}
+
+	versionCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	versionResp, err := client.Version(versionCtx)
+	cancel()
+	if err != nil || !corecompat.SameVersion(versionResp.Version, targetVersion) {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "VERSION_MISMATCH"
+		if err != nil {
+			job.Message = fmt.Sprintf("Failed to get version through VERIFY_URL %s: %v", verifyURL, err)
+		} else {
+			job.Message = fmt.Sprintf("Version mismatch through VERIFY_URL %s: expected %s, got %s", verifyURL, imageTag, versionResp.Version)
+		}
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (manual recovery required)", job.FailureCode, job.Message))
+		return false
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Version verified through VERIFY_URL: %s", versionResp.Version))
+	return true
+}
+
+// finalizeUpgrade marks job as complete, records the pre-upgrade container as
+// the last known good marker, and prunes old images.
+func (s *Server) finalizeUpgrade(ctx context.Context, job *jobs.Job, imageRepo, imageTag string, previousState *container.RuntimeState) {
 	job.State = jobs.JobStateReady
 	job.Message = "Upgrade completed successfully"
 	job.UpdatedAt = time.Now().UTC()
 	s.jobStore.Save(job)
 	s.jobStore.AppendLog(fmt.Sprintf("SUCCESS: Upgrade to %s completed successfully", imageTag))
 
+	s.recordLastKnownGood(job, imageTag, previousState)
+
 	// Best-effort: prune old Payram images after successful upgrade
 	pruneCtx, cancelPrune := context.WithTimeout(ctx, 30*time.Second)
 	defer cancelPrune()
 	if err := s.dockerRunner.PrunePayramImages(pruneCtx, imageRepo, imageTag); err != nil {
-		s.jobStore.AppendLog(fmt.Sprintf("Warning: failed to prune Payram images: %v", err))
+		s.addJobWarning(job, fmt.Sprintf("failed to prune Payram images: %v", err))
 	} else {
 		s.jobStore.AppendLog("Pruned old Payram images")
 	}
 }
+
+// savePreUpgradeSnapshot persists the runtime state of the container before
+// anything stops it, so the original can be recreated exactly if the updater
+// dies mid-upgrade. Returns false if it cannot be saved (job is already
+// marked failed; the container is untouched).
+func (s *Server) savePreUpgradeSnapshot(job *jobs.Job, containerName string, previousState *container.RuntimeState) bool {
+	if s.lastGoodStore == nil {
+		return true
+	}
+	snapshot := &rollback.Snapshot{
+		JobID:         job.JobID,
+		ContainerName: containerName,
+		RuntimeState:  previousState,
+	}
+	if err := s.lastGoodStore.SaveSnapshot(snapshot); err != nil {
+		job.State = jobs.JobStateFailed
+		job.FailureCode = "STATE_PERSIST_FAILED"
+		job.Message = fmt.Sprintf("Failed to save the container's pre-upgrade state: %v", err)
+		job.UpdatedAt = time.Now().UTC()
+		s.jobStore.Save(job)
+		s.jobStore.AppendLog(fmt.Sprintf("FAILED: %s - %s (container not modified)", job.FailureCode, job.Message))
+		return false
+	}
+	s.jobStore.AppendLog("Saved pre-upgrade container state")
+	return true
+}
+
+// recordLastKnownGood persists the container that was running before this
+// upgrade so `payram-updater rollback` can return to it, using the
+// pre-upgrade snapshot saved for this job. Best-effort: a failure here never
+// fails an otherwise successful upgrade.
+func (s *Server) recordLastKnownGood(job *jobs.Job, imageTag string, previousState *container.RuntimeState) {
+	if s.lastGoodStore == nil || previousState == nil {
+		return
+	}
+	if snapshot, err := s.lastGoodStore.LoadSnapshot(); err != nil {
+		s.jobStore.AppendLog(fmt.Sprintf("Warning: %v; recording the in-memory state instead", err))
+	} else if snapshot != nil && snapshot.JobID == job.JobID {
+		previousState = snapshot.RuntimeState
+	}
+	marker := &rollback.Marker{
+		JobID:           job.JobID,
+		PreviousVersion: previousState.ImageTag,
+		UpgradedTo:      imageTag,
+		BackupPath:      job.BackupPath,
+		RuntimeState:    previousState,
+	}
+	if err := s.lastGoodStore.Save(marker); err != nil {
+		s.addJobWarning(job, fmt.Sprintf("failed to record last known good version: %v", err))
+		return
+	}
+	s.jobStore.AppendLog(fmt.Sprintf("Recorded last known good version: %s (use 'payram-updater rollback' to return to it)", previousState.ImageTag))
+}
@@ -0,0 +1,1034 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/payram/payram-updater/internal/config"
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/coreclient"
+	"github.com/payram/payram-updater/internal/jobs"
+	"github.com/payram/payram-updater/internal/manifest"
+	"github.com/payram/payram-updater/internal/recovery"
+)
+
+func TestPreflightChecks_DockerPermissionDenied(t *testing.T) {
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	// Like the real CLI, version still prints the client version without the daemon
+	script := "#!/bin/sh\n[ \"$1\" = version ] && echo 24.0.0\necho 'permission denied while trying to connect to the Docker daemon socket at unix:///var/run/docker.sock' >&2\nexit 1\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	jobStore := jobs.NewStore(t.TempDir())
+	server := New(&config.Config{DockerBin: dockerBin}, jobStore)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.preflightChecks(context.Background(), job, "payram") {
+		t.Fatal("expected preflight checks to fail")
+	}
+	if job.FailureCode != "DOCKER_PERMISSION_DENIED" {
+		t.Errorf("expected failure code DOCKER_PERMISSION_DENIED, got %s", job.FailureCode)
+	}
+
+	logs, err := jobStore.ReadLogs()
+	if err != nil {
+		t.Fatalf("failed to read logs: %v", err)
+	}
+	if !strings.Contains(logs, "docker group") {
+		t.Errorf("expected docker group guidance in logs, got:\n%s", logs)
+	}
+	if strings.Contains(logs, "systemctl start docker") {
+		t.Errorf("expected no daemon-down guidance for a permission error, got:\n%s", logs)
+	}
+}
+
+func TestPreflightChecks_BackupMountMissing(t *testing.T) {
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	if err := os.Symlink(filepath.Join(dir, "nas", "backups"), backupDir); err != nil {
+		t.Fatal(err)
+	}
+	server, jobStore := newFinalizeTestServer(t, "#!/bin/sh\necho docker >> "+filepath.Join(dir, "calls")+"\n")
+	server.config.Backup.Dir = backupDir
+	os.Remove(filepath.Join(dir, "calls")) // discovery in New
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.preflightChecks(context.Background(), job, "payram") {
+		t.Fatal("expected preflight checks to fail")
+	}
+	if job.FailureCode != "BACKUP_MOUNT_MISSING" || !strings.Contains(job.Message, backupDir) {
+		t.Errorf("expected BACKUP_MOUNT_MISSING naming %s, got %s (%s)", backupDir, job.FailureCode, job.Message)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "calls")); !os.IsNotExist(err) {
+		t.Error("expected the mount check to fail before docker is called")
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Mount the backup storage") {
+		t.Errorf("expected mount guidance in logs, got:\n%s", logs)
+	}
+}
+
+func TestPreflightChecks_DockerBinaryInvalid(t *testing.T) {
+	notDocker := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(notDocker, []byte("#!/bin/sh\necho 'usage: tool [args]'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		dockerBin string
+	}{
+		{"missing", filepath.Join(t.TempDir(), "no-such-docker")},
+		{"not docker", notDocker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobStore := jobs.NewStore(t.TempDir())
+			server := New(&config.Config{DockerBin: tt.dockerBin}, jobStore)
+			job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+			if server.preflightChecks(context.Background(), job, "payram") {
+				t.Fatal("expected preflight checks to fail")
+			}
+			if job.FailureCode != "DOCKER_BINARY_INVALID" {
+				t.Errorf("expected failure code DOCKER_BINARY_INVALID, got %s", job.FailureCode)
+			}
+			if !strings.Contains(job.Message, tt.dockerBin) {
+				t.Errorf("expected the message to name %s, got %q", tt.dockerBin, job.Message)
+			}
+			logs, _ := jobStore.ReadLogs()
+			if !strings.Contains(logs, "DOCKER_BIN") {
+				t.Errorf("expected DOCKER_BIN guidance in logs, got:\n%s", logs)
+			}
+		})
+	}
+}
+
+func newFinalizeTestServer(t *testing.T, script string) (*Server, *jobs.Store) {
+	t.Helper()
+	dir := t.TempDir()
+	dockerBin := filepath.Join(dir, "docker")
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	jobStore := jobs.NewStore(filepath.Join(dir, "state"))
+	return New(&config.Config{DockerBin: dockerBin, StateDir: filepath.Join(dir, "state")}, jobStore), jobStore
+}
+
+func TestExecuteUpgrade_FailureSavesContainerLogs(t *testing.T) {
+	dir := t.TempDir()
+	dockerBin := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"  inspect) echo '" + cancelTestInspect + "' ;;\n" +
+		"  info|version) echo 24.0.0 ;;\n" +
+		"  pull) echo 'Error response from daemon: manifest unknown' >&2; exit 1 ;;\n" +
+		"  logs) echo \"$@\"; echo 'core: database connection refused' ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		DockerBin:           dockerBin,
+		ExecutionMode:       "execute",
+		TargetContainerName: "payram",
+		StateDir:            filepath.Join(dir, "state"),
+		FetchTimeoutSeconds: 1,
+		Backup:              config.BackupConfig{Dir: filepath.Join(dir, "backups")},
+	}
+	os.MkdirAll(cfg.Backup.Dir, 0755)
+	jobStore := jobs.NewStore(cfg.StateDir)
+	s := New(cfg, jobStore)
+
+	job := jobs.NewJob("job-logs", jobs.JobModeManual, "1.1.0")
+	job.ResolvedTarget = "1.1.0"
+	jobStore.Save(job)
+	s.executeUpgrade(job, &manifest.Manifest{Image: manifest.Image{Repo: "payramapp/payram"}}, nil, "")
+
+	if job.State != jobs.JobStateFailed {
+		t.Fatalf("expected the upgrade to fail, got %s", job.State)
+	}
+	wantPath := filepath.Join(cfg.StateDir, "jobs", "container-logs", "job-logs.log")
+	if job.ContainerLogPath != wantPath {
+		t.Fatalf("expected containerLogPath %s, got %q", wantPath, job.ContainerLogPath)
+	}
+	data, err := os.ReadFile(wantPath)
+	if err != nil {
+		t.Fatalf("expected the container log file: %v", err)
+	}
+	if !strings.Contains(string(data), "logs --timestamps --tail 500 payram") || !strings.Contains(string(data), "database connection refused") {
+		t.Errorf("unexpected container log contents %q", data)
+	}
+
+	saved, _ := jobStore.LoadLatest()
+	if saved == nil || saved.ContainerLogPath != wantPath {
+		t.Errorf("expected the persisted job to reference the container log, got %+v", saved)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "output to "+wantPath) {
+		t.Errorf("expected the job log to reference the container log, got:\n%s", logs)
+	}
+	events, _ := s.historyStore.List(1, "upgrade", "failed")
+	if len(events) != 1 || events[0].Data["containerLog"] != wantPath {
+		t.Errorf("expected the failed history event to reference the container log, got %+v", events)
+	}
+}
+
+func TestFinalizeUpgrade_PruneFailureRecordsWarning(t *testing.T) {
+	server, jobStore := newFinalizeTestServer(t, "#!/bin/sh\necho 'Cannot connect to the Docker daemon' >&2\nexit 1\n")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	server.finalizeUpgrade(context.Background(), job, "payramapp/payram", "1.2.0", nil)
+
+	if job.State != jobs.JobStateReady || job.FailureCode != "" {
+		t.Fatalf("expected a successful job, got %s/%s (%s)", job.State, job.FailureCode, job.Message)
+	}
+	if len(job.Warnings) != 1 || !strings.Contains(job.Warnings[0], "failed to prune Payram images") {
+		t.Fatalf("expected one prune warning, got %v", job.Warnings)
+	}
+
+	saved, err := jobStore.LoadLatest()
+	if err != nil {
+		t.Fatalf("failed to load job: %v", err)
+	}
+	if saved.State != jobs.JobStateReady || len(saved.Warnings) != 1 {
+		t.Errorf("expected persisted READY job with one warning, got %s with %v", saved.State, saved.Warnings)
+	}
+}
+
+func TestFinalizeUpgrade_CleanSuccessHasNoWarnings(t *testing.T) {
+	server, _ := newFinalizeTestServer(t, "#!/bin/sh\nexit 0\n")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	server.finalizeUpgrade(context.Background(), job, "payramapp/payram", "1.2.0", nil)
+
+	if job.State != jobs.JobStateReady {
+		t.Fatalf("expected state READY, got %s", job.State)
+	}
+	if len(job.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", job.Warnings)
+	}
+}
+
+// pullTestScript fails the first failures docker pulls with stderr, then
+// succeeds, counting every pull in a file next to the stub.
+func pullTestScript(failures int, stderr string) string {
+	return "#!/bin/sh\n" +
+		"[ \"$1\" = pull ] || exit 0\n" +
+		"count=\"$(dirname \"$0\")/pulls\"\n" +
+		"echo x >> \"$count\"\n" +
+		"if [ \"$(wc -l < \"$count\")\" -le " + strconv.Itoa(failures) + " ]; then\n" +
+		"  echo '" + stderr + "' >&2\n" +
+		"  exit 1\n" +
+		"fi\n"
+}
+
+func countPulls(t *testing.T, server *Server) int {
+	t.Helper()
+	data, _ := os.ReadFile(filepath.Join(filepath.Dir(server.config.DockerBin), "pulls"))
+	return strings.Count(string(data), "\n")
+}
+
+func TestPullUpgradeImage_RetriesTransientFailure(t *testing.T) {
+	server, jobStore := newFinalizeTestServer(t, pullTestScript(2, "error pulling image configuration: read tcp 10.0.0.2:443: connection reset by peer"))
+	server.pullBackoff = time.Millisecond
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.pullUpgradeImage(context.Background(), job, "payramapp/payram", "1.2.0") {
+		t.Fatalf("expected the pull to succeed after retries, got %s: %s", job.FailureCode, job.Message)
+	}
+	if got := countPulls(t, server); got != 3 {
+		t.Errorf("expected 3 pull attempts, got %d", got)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Pull attempt 2/3 failed") {
+		t.Errorf("expected retries to be logged, got:\n%s", logs)
+	}
+}
+
+func TestPullUpgradeImage_GivesUpAfterMaxAttempts(t *testing.T) {
+	server, _ := newFinalizeTestServer(t, pullTestScript(pullAttempts, "net/http: TLS handshake timeout"))
+	server.pullBackoff = time.Millisecond
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.pullUpgradeImage(context.Background(), job, "payramapp/payram", "1.2.0") {
+		t.Fatal("expected the pull to fail")
+	}
+	if job.FailureCode != "DOCKER_PULL_FAILED" || !strings.Contains(job.Message, "TLS handshake timeout") {
+		t.Errorf("expected DOCKER_PULL_FAILED with the last error, got %s: %s", job.FailureCode, job.Message)
+	}
+	if got := countPulls(t, server); got != pullAttempts {
+		t.Errorf("expected %d pull attempts, got %d", pullAttempts, got)
+	}
+}
+
+func TestPullUpgradeImage_DoesNotRetryPermanentFailure(t *testing.T) {
+	for _, stderr := range []string{
+		"Error response from daemon: manifest for payramapp/payram:9.9.9 not found: manifest unknown",
+		"Error response from daemon: pull access denied for payramapp/private, repository does not exist or may require docker login",
+		"Error response from daemon: Head https://registry-1.docker.io/v2/payramapp/payram/manifests/1.2.0: unauthorized: incorrect username or password",
+	} {
+		server, _ := newFinalizeTestServer(t, pullTestScript(pullAttempts, stderr))
+		server.pullBackoff = time.Hour
+		job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+		if server.pullUpgradeImage(context.Background(), job, "payramapp/payram", "1.2.0") {
+			t.Fatalf("expected the pull to fail for %q", stderr)
+		}
+		if got := countPulls(t, server); got != 1 {
+			t.Errorf("expected a single pull attempt for %q, got %d", stderr, got)
+		}
+	}
+}
+
+func TestPullUpgradeImage_RateLimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		reset  string
+	}{
+		{
+			name:   "docker hub",
+			stderr: "Error response from daemon: toomanyrequests: You have reached your pull rate limit. You may increase the limit by authenticating and upgrading: https://www.docker.com/increase-rate-limit",
+			reset:  "retry once the limit resets",
+		},
+		{
+			name:   "retry-after seconds",
+			stderr: "Error response from daemon: toomanyrequests: rate limit exceeded, Retry-After: 3600",
+			reset:  "retry after the limit resets in 1h0m0s",
+		},
+		{
+			name:   "reset timestamp",
+			stderr: "Error response from daemon: 429 Too Many Requests: pull limit resets at 2026-10-16T12:00:00Z",
+			reset:  "retry after the limit resets at 2026-10-16T12:00:00Z",
+		},
+		{
+			name:   "reset duration",
+			stderr: "Error response from daemon: toomanyrequests: pull limit resets in 1h",
+			reset:  "retry after the limit resets in 1h",
+		},
+		{
+			name:   "retry-after http date",
+			stderr: "Error response from daemon: toomanyrequests: rate limit exceeded, Retry-After: Fri, 16 Oct 2026 12:00:00 GMT",
+			reset:  "retry after the limit resets at 2026-10-16T12:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, jobStore := newFinalizeTestServer(t, pullTestScript(pullAttempts, tt.stderr))
+			server.pullBackoff = time.Hour
+			job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+			if server.pullUpgradeImage(context.Background(), job, "payramapp/payram", "1.2.0") {
+				t.Fatal("expected the pull to fail")
+			}
+			if job.FailureCode != "REGISTRY_RATE_LIMITED" {
+				t.Errorf("expected REGISTRY_RATE_LIMITED, got %s: %s", job.FailureCode, job.Message)
+			}
+			if !strings.Contains(job.Message, tt.reset) {
+				t.Errorf("expected message to contain %q, got %s", tt.reset, job.Message)
+			}
+			if got := countPulls(t, server); got != 1 {
+				t.Errorf("expected a single pull attempt, got %d", got)
+			}
+			logs, _ := jobStore.ReadLogs()
+			if !strings.Contains(logs, "docker login") {
+				t.Errorf("expected next steps to suggest docker login, got:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestPullUpgradeImage_CancelDuringBackoff(t *testing.T) {
+	server, _ := newFinalizeTestServer(t, pullTestScript(pullAttempts, "unexpected EOF"))
+	server.pullBackoff = time.Hour
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if server.pullUpgradeImage(ctx, job, "payramapp/payram", "1.2.0") {
+		t.Fatal("expected the pull to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the backoff to stop on cancellation, took %s", elapsed)
+	}
+	if got := countPulls(t, server); got != 1 {
+		t.Errorf("expected no retry after cancellation, got %d attempts", got)
+	}
+}
+
+// restartCountScript reports a container restart count that grows by step on
+// every docker inspect.
+func restartCountScript(step int) string {
+	return "#!/bin/sh\n" +
+		"[ \"$1\" = inspect ] || exit 0\n" +
+		"count=\"$(dirname \"$0\")/inspects\"\n" +
+		"echo x >> \"$count\"\n" +
+		"echo $(( ($(wc -l < \"$count\") - 1) * " + strconv.Itoa(step) + " + 2 ))\n"
+}
+
+// newVerifyTestServer returns a server whose Payram Core reports healthy on
+// version 1.2.0 and whose container restart count grows by step per inspect.
+func newVerifyTestServer(t *testing.T, step int) (*Server, *jobs.Store) {
+	t.Helper()
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/health":
+			w.Write([]byte(`{"status":"ok","db":"ok"}`))
+		case "/api/v1/version":
+			w.Write([]byte(`{"version":"1.2.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(core.Close)
+
+	server, jobStore := newFinalizeTestServer(t, restartCountScript(step))
+	server.coreClient = coreclient.NewClient(core.URL)
+	return server, jobStore
+}
+
+func TestVerifyUpgrade_FailsWhenRestartCountClimbs(t *testing.T) {
+	server, jobStore := newVerifyTestServer(t, 1)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatal("expected verification to fail for a crash-looping container")
+	}
+	if job.FailureCode != "CONTAINER_CRASHLOOP" {
+		t.Fatalf("expected CONTAINER_CRASHLOOP, got %s (%s)", job.FailureCode, job.Message)
+	}
+	if !strings.Contains(job.Message, "restart count 2 -> 3") {
+		t.Errorf("expected the restart counts in the message, got %q", job.Message)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Health check passed") {
+		t.Errorf("expected the crash loop to be caught despite a passing health check, got:\n%s", logs)
+	}
+}
+
+func TestVerifyUpgrade_StableRestartCountPasses(t *testing.T) {
+	server, _ := newVerifyTestServer(t, 0)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatalf("expected verification to pass, got %s (%s)", job.FailureCode, job.Message)
+	}
+}
+
+func TestVerifyUpgrade_UnreadableRestartCountIsSkipped(t *testing.T) {
+	server, jobStore := newVerifyTestServer(t, 0)
+	if err := os.WriteFile(server.config.DockerBin, []byte("#!/bin/sh\necho '<no value>'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatalf("expected verification to pass without a restart count, got %s (%s)", job.FailureCode, job.Message)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "crash-loop detection skipped") {
+		t.Errorf("expected the skipped detection to be logged, got:\n%s", logs)
+	}
+}
+
+// volumeTestScript reports every volume as present except missing.
+func volumeTestScript(missing string) string {
+	return "#!/bin/sh\n" +
+		"if [ \"$1\" = volume ] && [ \"$5\" = " + missing + " ]; then\n" +
+		"  echo \"Error response from daemon: get $5: no such volume\" >&2; exit 1\n" +
+		"fi\n" +
+		"echo \"$5\"\n"
+}
+
+func TestCheckVolumesExist_MissingVolumeFails(t *testing.T) {
+	server, jobStore := newFinalizeTestServer(t, volumeTestScript("pgdata"))
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+	state := &container.RuntimeState{Mounts: []container.Mount{
+		{Type: "volume", Name: "payram-config", Source: "/var/lib/docker/volumes/payram-config/_data", Destination: "/config"},
+		{Type: "volume", Name: "pgdata", Source: "/var/lib/docker/volumes/pgdata/_data", Destination: "/var/lib/postgresql"},
+		{Type: "bind", Source: "/opt/payram/logs", Destination: "/logs"},
+	}}
+
+	if server.checkVolumesExist(context.Background(), job, state) {
+		t.Fatal("expected the volume check to fail")
+	}
+	if job.State != jobs.JobStateFailed || job.FailureCode != "MISSING_VOLUME" {
+		t.Fatalf("expected FAILED/MISSING_VOLUME, got %s/%s", job.State, job.FailureCode)
+	}
+	if !strings.Contains(job.Message, "pgdata") || strings.Contains(job.Message, "payram-config") {
+		t.Errorf("expected only the missing volume in the message, got %q", job.Message)
+	}
+
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "container not modified") {
+		t.Errorf("expected logs to say the container was not modified, got:\n%s", logs)
+	}
+}
+
+func TestCheckVolumesExist_AllPresentPasses(t *testing.T) {
+	server, _ := newFinalizeTestServer(t, volumeTestScript("other"))
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+	state := &container.RuntimeState{Mounts: []container.Mount{
+		{Type: "volume", Name: "pgdata", Source: "/var/lib/docker/volumes/pgdata/_data", Destination: "/var/lib/postgresql"},
+	}}
+
+	if !server.checkVolumesExist(context.Background(), job, state) {
+		t.Fatalf("expected the volume check to pass, got %s: %s", job.FailureCode, job.Message)
+	}
+	if job.FailureCode != "" {
+		t.Errorf("expected no failure code, got %s", job.FailureCode)
+	}
+}
+
+func TestWarnBackupOnDBFilesystem(t *testing.T) {
+	state := &container.RuntimeState{Mounts: []container.Mount{
+		{Type: "volume", Name: "pgdata", Source: "/var/lib/docker/volumes/pgdata/_data", Destination: container.DefaultPGDataDir},
+	}}
+	tests := []struct {
+		name      string
+		backupDev uint64
+		wantWarn  bool
+	}{
+		{"shared filesystem", 2049, true},
+		{"different filesystem", 2065, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newFinalizeTestServer(t, "#!/bin/sh\nexit 0\n")
+			server.config.Backup.Dir = "/var/lib/payram-updater/backups"
+			server.deviceOf = func(path string) (uint64, error) {
+				if path == server.config.Backup.Dir {
+					return tt.backupDev, nil
+				}
+				return 2049, nil
+			}
+			job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+			server.warnBackupOnDBFilesystem(job, state)
+
+			if tt.wantWarn != (len(job.Warnings) == 1) {
+				t.Fatalf("expected warning=%v, got %v", tt.wantWarn, job.Warnings)
+			}
+			if tt.wantWarn && !strings.Contains(job.Warnings[0], "same filesystem as the database data") {
+				t.Errorf("expected a shared-filesystem warning, got %q", job.Warnings[0])
+			}
+			if job.State == jobs.JobStateFailed {
+				t.Errorf("expected the warning not to fail the job")
+			}
+		})
+	}
+}
+
+func TestWarnRetentionFootprint(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		name        string
+		availableGB float64
+		wantWarn    bool
+	}{
+		{"projection exceeds free space", 0.01, true},
+		{"projection fits", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newFinalizeTestServer(t, "#!/bin/sh\nexit 0\n")
+			server.backupManager.Config.Dir = t.TempDir()
+			server.backupManager.Config.Retention = 10
+			for _, name := range []string{
+				"payram-backup-20260301-060000-1.0.0-to-1.1.0.dump",
+				"payram-backup-20260302-060000-1.1.0-to-1.2.0.dump",
+			} {
+				if err := os.WriteFile(filepath.Join(server.backupManager.Config.Dir, name), make([]byte, 2*mb), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			job := jobs.NewJob("job-1", jobs.JobModeManual, "1.3.0")
+
+			// 10 backups of 2 MB need 16 MB more than the 4 MB kept today.
+			server.warnRetentionFootprint(job, tt.availableGB)
+
+			if tt.wantWarn != (len(job.Warnings) == 1) {
+				t.Fatalf("expected warning=%v, got %v", tt.wantWarn, job.Warnings)
+			}
+			if tt.wantWarn && !strings.Contains(job.Warnings[0], "keeping 10 backups") {
+				t.Errorf("expected the retention in the warning, got %q", job.Warnings[0])
+			}
+		})
+	}
+}
+
+// driftTestScript answers docker inspect with a running container whose
+// runtime state is inspectJSON, and succeeds for every other command.
+func driftTestScript(inspectJSON string) string {
+	return "#!/bin/sh\n" +
+		"if [ \"$1\" = inspect ] && [ \"$2\" = -f ]; then echo true; exit 0; fi\n" +
+		"if [ \"$1\" = inspect ]; then echo '" + inspectJSON + "'; fi\n"
+}
+
+func driftTestArgs() []string {
+	return []string{"run", "-d", "--name", "payram", "--restart", "always",
+		"-v", "/srv/payram:/root/payram", "-e", "POSTGRES_HOST=localhost", "payramapp/payram:1.2.0"}
+}
+
+func TestReplaceContainer_MissingMountFailsWithRuntimeDrift(t *testing.T) {
+	server, jobStore := newFinalizeTestServer(t, driftTestScript(cancelTestInspect))
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.replaceContainer(context.Background(), job, "payram", driftTestArgs()) {
+		t.Fatal("expected the container replacement to fail")
+	}
+	if job.State != jobs.JobStateFailed || job.FailureCode != "RUNTIME_DRIFT" {
+		t.Fatalf("expected FAILED/RUNTIME_DRIFT, got %s/%s", job.State, job.FailureCode)
+	}
+	if !strings.Contains(job.Message, "mount /root/payram is missing") {
+		t.Errorf("expected the missing mount in the message, got %q", job.Message)
+	}
+
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Runtime drift: mount /root/payram is missing") {
+		t.Errorf("expected the drift to be logged, got:\n%s", logs)
+	}
+}
+
+func TestReplaceContainer_MatchingRuntimeStatePasses(t *testing.T) {
+	inspectJSON := strings.Replace(cancelTestInspect, `"Mounts":[]`,
+		`"Mounts":[{"Type":"bind","Source":"/srv/payram","Destination":"/root/payram","Mode":"","RW":true}]`, 1)
+	server, _ := newFinalizeTestServer(t, driftTestScript(inspectJSON))
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.replaceContainer(context.Background(), job, "payram", driftTestArgs()) {
+		t.Fatalf("expected the container replacement to pass, got %s: %s", job.FailureCode, job.Message)
+	}
+}
+
+func TestVerifyUpgrade_TemplatedTagMatchesPlainVersion(t *testing.T) {
+	server, _ := newVerifyTestServer(t, 0)
+	server.config.ImageTagTemplate = "{version}-{env}"
+	server.config.ImageEnv = "prod"
+
+	for _, tag := range []string{"1.2.0-prod", "1.2.0-prod-arm64"} {
+		job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+		if !server.verifyUpgrade(context.Background(), job, "payram", tag, "") {
+			t.Errorf("expected %s to verify against version 1.2.0, got %s (%s)", tag, job.FailureCode, job.Message)
+		}
+	}
+
+	job := jobs.NewJob("job-2", jobs.JobModeManual, "1.2.0")
+	if server.verifyUpgrade(context.Background(), job, "payram", "1.2.0-staging", "") || job.FailureCode != "VERSION_MISMATCH" {
+		t.Errorf("expected another environment's tag to mismatch, got %s (%s)", job.FailureCode, job.Message)
+	}
+}
+
+func TestVerifyUpgrade_NormalizesReportedVersion(t *testing.T) {
+	for _, reported := range []string{"v1.2.0", "1.2.0+build.7"} {
+		core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api/v1/health":
+				w.Write([]byte(`{"status":"ok","db":"ok"}`))
+			case "/api/v1/version":
+				w.Write([]byte(`{"version":"` + reported + `"}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		server, _ := newFinalizeTestServer(t, restartCountScript(0))
+		server.coreClient = coreclient.NewClient(core.URL)
+
+		for _, tag := range []string{"1.2.0", "v1.2.0"} {
+			job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+			if !server.verifyUpgrade(context.Background(), job, "payram", tag, "") {
+				t.Errorf("expected tag %s to verify against reported version %s, got %s (%s)", tag, reported, job.FailureCode, job.Message)
+			}
+		}
+		core.Close()
+	}
+}
+
+func TestTargetImageTag(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		runningTag string
+		want       string
+	}{
+		{"no template", "", "1.1.0", "1.2.0"},
+		{"no template arm64", "", "1.1.0-arm64", "1.2.0-arm64"},
+		{"template", "{version}-{env}", "1.1.0-prod", "1.2.0-prod"},
+		{"template arm64", "{version}-{env}", "1.1.0-prod-arm64", "1.2.0-prod-arm64"},
+		{"template from untemplated tag", "{version}-{env}", "1.1.0", "1.2.0-prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{config: &config.Config{ImageTagTemplate: tt.template, ImageEnv: "prod"}}
+			if got, _ := s.targetImageTag(tt.runningTag, "1.2.0", nil); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// pauseTestScript reports a stable restart count and the supervisor programs
+// worker and api as running, and records supervisorctl stop and start calls.
+const pauseTestScript = "#!/bin/sh\n" +
+	"[ \"$1\" = inspect ] && { echo 2; exit 0; }\n" +
+	"[ \"$1\" = exec ] || exit 0\n" +
+	"shift 2\n" +
+	"if [ \"$2\" = status ]; then\n" +
+	"  printf 'worker RUNNING pid 10\\napi RUNNING pid 11\\n'\n" +
+	"  exit 0\n" +
+	"fi\n" +
+	"echo \"$*\" >> \"$(dirname \"$0\")/supervisorctl\"\n"
+
+func newPauseTestServer(t *testing.T) *Server {
+	t.Helper()
+	server, _ := newVerifyTestServer(t, 0)
+	if err := os.WriteFile(server.config.DockerBin, []byte(pauseTestScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	server.config.VerifyPausePrograms = []string{"worker", "scheduler"}
+	return server
+}
+
+func supervisorctlCalls(t *testing.T, server *Server) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(server.config.DockerBin), "supervisorctl"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestVerifyWithWritesPaused_ResumesAfterVerification(t *testing.T) {
+	server := newPauseTestServer(t)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.verifyWithWritesPaused(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatalf("expected verification to pass, got %s (%s)", job.FailureCode, job.Message)
+	}
+	if calls := supervisorctlCalls(t, server); calls != "supervisorctl stop worker\nsupervisorctl start worker\n" {
+		t.Errorf("expected worker to be stopped and then restarted, got:\n%s", calls)
+	}
+	if len(job.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", job.Warnings)
+	}
+}
+
+func TestVerifyWithWritesPaused_StaysPausedOnFailure(t *testing.T) {
+	server := newPauseTestServer(t)
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.3.0")
+
+	if server.verifyWithWritesPaused(context.Background(), job, "payram", "1.3.0", "") {
+		t.Fatal("expected verification to fail on a version mismatch")
+	}
+	if job.FailureCode != "VERSION_MISMATCH" {
+		t.Errorf("expected VERSION_MISMATCH, got %s (%s)", job.FailureCode, job.Message)
+	}
+	if calls := supervisorctlCalls(t, server); calls != "supervisorctl stop worker\n" {
+		t.Errorf("expected worker to stay stopped, got:\n%s", calls)
+	}
+	logs, _ := server.jobStore.ReadLogs()
+	if !strings.Contains(logs, "docker exec payram supervisorctl start worker") {
+		t.Errorf("expected the command to resume writes in the logs, got:\n%s", logs)
+	}
+}
+
+func TestVerifyWithWritesPaused_ResumesHeldPrograms(t *testing.T) {
+	server := newPauseTestServer(t)
+	// worker was held by the hold file, so it never started
+	script := strings.Replace(pauseTestScript, "worker RUNNING pid 10", "worker STOPPED Not started", 1)
+	if err := os.WriteFile(server.config.DockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	server.config.VerifyPauseHoldFile = "/etc/supervisor/conf.d/hold.conf"
+	server.verifyHeld = []string{"worker"}
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.verifyWithWritesPaused(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatalf("expected verification to pass, got %s (%s)", job.FailureCode, job.Message)
+	}
+	if calls := supervisorctlCalls(t, server); calls != "supervisorctl start worker\nrm -f /etc/supervisor/conf.d/hold.conf\n" {
+		t.Errorf("expected worker started and the hold file removed, got:\n%s", calls)
+	}
+}
+
+func TestVerifyWithWritesPaused_FailsWhenProgramsCannotRestart(t *testing.T) {
+	server := newPauseTestServer(t)
+	script := strings.Replace(pauseTestScript, "fi\n", "fi\n[ \"$2\" = start ] && { echo 'worker: ERROR (spawn error)'; exit 1; }\n", 1)
+	if err := os.WriteFile(server.config.DockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.verifyWithWritesPaused(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatal("expected the job to fail when the paused programs cannot be restarted")
+	}
+	if job.FailureCode != "PAUSED_PROGRAMS_NOT_RESUMED" || job.State != jobs.JobStateFailed {
+		t.Errorf("expected a failed job with PAUSED_PROGRAMS_NOT_RESUMED, got %s %s (%s)", job.State, job.FailureCode, job.Message)
+	}
+	if playbook := recovery.GetPlaybook(job.FailureCode); playbook.Code != job.FailureCode {
+		t.Errorf("expected a playbook for %s, got %s", job.FailureCode, playbook.Code)
+	}
+}
+
+func TestRunNewContainer_HoldsPausedPrograms(t *testing.T) {
+	server, _ := newVerifyTestServer(t, 0)
+	dir := filepath.Dir(server.config.DockerBin)
+	// Record each docker call and keep the copied hold file
+	script := "#!/bin/sh\n" +
+		"echo \"$1 $2\" >> " + filepath.Join(dir, "calls") + "\n" +
+		"[ \"$1\" = cp ] && cp \"$2\" " + filepath.Join(dir, "hold.conf") + "\n" +
+		"exit 0\n"
+	if err := os.WriteFile(server.config.DockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	server.config.VerifyPausePrograms = []string{"worker", "scheduler"}
+	server.config.VerifyPauseHoldFile = "/etc/supervisor/conf.d/hold.conf"
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	args := []string{"run", "-d", "--name", "payram", "--restart", "always", "payramapp/payram:1.2.0"}
+	if err := server.runNewContainer(context.Background(), job, "payram", args); err != nil {
+		t.Fatalf("runNewContainer failed: %v", err)
+	}
+	calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+	if lines := strings.Split(strings.TrimSpace(string(calls)), "\n"); len(lines) != 3 ||
+		lines[0] != "create --name" || !strings.HasPrefix(lines[1], "cp ") || lines[2] != "start payram" {
+		t.Errorf("expected create, cp and start, got:\n%s", calls)
+	}
+	hold, _ := os.ReadFile(filepath.Join(dir, "hold.conf"))
+	if string(hold) != "[program:worker]\nautostart=false\n\n[program:scheduler]\nautostart=false\n\n" {
+		t.Errorf("unexpected hold file:\n%s", hold)
+	}
+	if !slices.Equal(server.verifyHeld, []string{"worker", "scheduler"}) {
+		t.Errorf("expected worker and scheduler held, got %v", server.verifyHeld)
+	}
+}
+
+func TestVerifyWithWritesPaused_DisabledByDefault(t *testing.T) {
+	server := newPauseTestServer(t)
+	server.config.VerifyPausePrograms = nil
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.verifyWithWritesPaused(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatalf("expected verification to pass, got %s (%s)", job.FailureCode, job.Message)
+	}
+	if calls := supervisorctlCalls(t, server); calls != "" {
+		t.Errorf("expected no supervisorctl calls, got:\n%s", calls)
+	}
+}
+
+func TestExecuteUpgrade_UnrestorableBackupAbortsBeforeContainerChange(t *testing.T) {
+	dir := t.TempDir()
+	dockerBin := filepath.Join(dir, "docker")
+	callsFile := filepath.Join(dir, "calls")
+	env := `["POSTGRES_HOST=localhost","POSTGRES_PORT=5432","POSTGRES_DATABASE=payram","POSTGRES_USERNAME=payram","POSTGRES_PASSWORD=secret"]`
+	// pg_dump is cut short: no completion trailer.
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callsFile + "\n" +
+		"case \"$1\" in\n" +
+		"  inspect) if [ \"$2\" = --format ]; then echo '" + env + "'; else echo '" + cancelTestInspect + "'; fi ;;\n" +
+		"  info|version) echo 24.0.0 ;;\n" +
+		"  exec) case \"$*\" in\n" +
+		"    *supervisorctl*) echo 'exec: \"supervisorctl\": executable file not found in $PATH' >&2; exit 126 ;;\n" +
+		"    *pg_dump*) printf -- '--\\n-- PostgreSQL database dump\\n--\\n\\nCOPY public.payments (id) FROM stdin;\\n1\\n' ;;\n" +
+		"    *pg_database_size*) echo 1024 ;;\n" +
+		"    *) echo 1 ;;\n" +
+		"  esac ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		DockerBin:            dockerBin,
+		ExecutionMode:        "execute",
+		TargetContainerName:  "payram",
+		StateDir:             filepath.Join(dir, "state"),
+		FetchTimeoutSeconds:  1,
+		BackupTimeoutSeconds: 30,
+		Backup:               config.BackupConfig{Dir: filepath.Join(dir, "backups"), VerifyAfterCreate: true},
+	}
+	os.MkdirAll(cfg.Backup.Dir, 0755)
+	jobStore := jobs.NewStore(cfg.StateDir)
+	s := New(cfg, jobStore)
+	os.Remove(callsFile)
+
+	job := jobs.NewJob("job-verify", jobs.JobModeManual, "1.1.0")
+	job.ResolvedTarget = "1.1.0"
+	jobStore.Save(job)
+	s.executeUpgrade(job, &manifest.Manifest{Image: manifest.Image{Repo: "payramapp/payram"}}, nil, "")
+
+	if job.State != jobs.JobStateFailed || job.FailureCode != "BACKUP_VERIFY_FAILED" {
+		t.Fatalf("expected the upgrade to fail with BACKUP_VERIFY_FAILED, got %s %s (%s)", job.State, job.FailureCode, job.Message)
+	}
+	calls, _ := os.ReadFile(callsFile)
+	for _, line := range strings.Split(string(calls), "\n") {
+		if strings.HasPrefix(line, "stop ") || strings.HasPrefix(line, "rm ") || strings.HasPrefix(line, "run ") {
+			t.Errorf("expected the container to be left alone, got docker %s", line)
+		}
+	}
+	entries, _ := os.ReadDir(cfg.Backup.Dir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".sql") {
+			t.Errorf("expected the unrestorable backup to be removed, found %s", e.Name())
+		}
+	}
+}
+
+// newVerifyURLCore returns a secondary Payram endpoint answering health with
+// status and version with version.
+func newVerifyURLCore(t *testing.T, status int, version string) string {
+	t.Helper()
+	vip := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/health":
+			w.WriteHeader(status)
+			w.Write([]byte(`{"status":"ok","db":"ok"}`))
+		case "/api/v1/version":
+			w.Write([]byte(`{"version":"` + version + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(vip.Close)
+	return vip.URL
+}
+
+func TestVerifyUpgrade_VerifyURLMustPassToo(t *testing.T) {
+	server, jobStore := newVerifyTestServer(t, 0)
+	server.healthInterval = time.Millisecond
+	server.config.VerifyURL = newVerifyURLCore(t, http.StatusBadGateway, "1.2.0")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatal("expected verification to fail while VERIFY_URL is down")
+	}
+	if job.FailureCode != "HEALTHCHECK_FAILED" || !strings.Contains(job.Message, server.config.VerifyURL) {
+		t.Fatalf("expected HEALTHCHECK_FAILED naming VERIFY_URL, got %s (%s)", job.FailureCode, job.Message)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Health check passed on attempt 1") {
+		t.Errorf("expected the local health check to pass first, got:\n%s", logs)
+	}
+}
+
+func TestVerifyUpgrade_VerifyURLVersionMismatch(t *testing.T) {
+	server, _ := newVerifyTestServer(t, 0)
+	server.config.VerifyURL = newVerifyURLCore(t, http.StatusOK, "1.1.0")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatal("expected verification to fail while VERIFY_URL serves the old version")
+	}
+	if job.FailureCode != "VERSION_MISMATCH" || !strings.Contains(job.Message, "got 1.1.0") {
+		t.Fatalf("expected VERSION_MISMATCH through VERIFY_URL, got %s (%s)", job.FailureCode, job.Message)
+	}
+}
+
+func TestVerifyUpgrade_VerifyURLPasses(t *testing.T) {
+	server, jobStore := newVerifyTestServer(t, 0)
+	server.config.VerifyURL = newVerifyURLCore(t, http.StatusOK, "1.2.0")
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.2.0")
+
+	if !server.verifyUpgrade(context.Background(), job, "payram", "1.2.0", "") {
+		t.Fatalf("expected verification to pass, got %s (%s)", job.FailureCode, job.Message)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if !strings.Contains(logs, "Version verified through VERIFY_URL: 1.2.0") {
+		t.Errorf("expected VERIFY_URL to be verified, got:\n%s", logs)
+	}
+}
+
+func TestVerifyWithWritesPaused_SkipVerifyOnlyInManualMode(t *testing.T) {
+	server, jobStore := newVerifyTestServer(t, 0)
+
+	// The core reports 1.2.0, so verifying 9.9.9 fails unless it is skipped
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "9.9.9")
+	job.SkipVerify = true
+	if !server.verifyWithWritesPaused(context.Background(), job, "payram", "9.9.9", "") {
+		t.Fatalf("expected a manual --skip-verify job to skip verification, got %s (%s)", job.FailureCode, job.Message)
+	}
+	if len(job.Warnings) != 1 || !strings.Contains(job.Warnings[0], "SKIPPED") {
+		t.Errorf("expected a skipped-verification warning, got %v", job.Warnings)
+	}
+	logs, _ := jobStore.ReadLogs()
+	if strings.Contains(logs, "Health check passed") {
+		t.Errorf("expected no health check, got:\n%s", logs)
+	}
+	events, err := server.historyStore.List(0, "upgrade", "verification_skipped")
+	if err != nil || len(events) != 1 || events[0].Data["jobId"] != "job-1" {
+		t.Fatalf("expected a verification_skipped history event, got %+v (err=%v)", events, err)
+	}
+
+	job = jobs.NewJob("job-2", jobs.JobModeDashboard, "9.9.9")
+	job.SkipVerify = true
+	if server.verifyWithWritesPaused(context.Background(), job, "payram", "9.9.9", "") || job.FailureCode != "VERSION_MISMATCH" {
+		t.Errorf("expected a dashboard job to be verified regardless, got %s (%s)", job.FailureCode, job.Message)
+	}
+	if len(job.Warnings) != 0 {
+		t.Errorf("expected no skipped-verification warning for a dashboard job, got %v", job.Warnings)
+	}
+
+	job = jobs.NewJob("job-3", jobs.JobModeManual, "9.9.9")
+	if server.verifyWithWritesPaused(context.Background(), job, "payram", "9.9.9", "") || job.FailureCode != "VERSION_MISMATCH" {
+		t.Errorf("expected a manual job without --skip-verify to be verified, got %s (%s)", job.FailureCode, job.Message)
+	}
+}
+
+func TestValidateSkipVerify(t *testing.T) {
+	if err := validateSkipVerify(jobs.JobModeManual, false); err != nil {
+		t.Errorf("expected manual mode to allow skipVerify, got %v", err)
+	}
+	if err := validateSkipVerify(jobs.JobModeDashboard, false); err == nil {
+		t.Error("expected dashboard mode to refuse skipVerify")
+	}
+	if err := validateSkipVerify(jobs.JobModeManual, true); err == nil {
+		t.Error("expected skipVerify with restartOnly to be refused")
+	}
+}
+
+func TestExecuteUpgrade_SteppingStoneTemplatesTargetOnce(t *testing.T) {
+	s, jobStore, callLog := newCancelTestServer(t, 0, "none")
+	s.config.ImageTagTemplate = "{version}-{env}"
+	s.config.ImageEnv = "prod"
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callLog + "\n" +
+		"if [ \"$1\" = inspect ] && [ \"$2\" = -f ]; then echo true; exit 0; fi\n" +
+		"case \"$1\" in\n" +
+		"  inspect) [ \"$2\" = --format ] && echo '[\"POSTGRES_HOST=localhost\",\"POSTGRES_DATABASE=payram\",\"POSTGRES_USERNAME=payram\"]' || echo '" + cancelTestInspect + "' ;;\n" +
+		"  info|version) echo 24.0.0 ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(s.config.DockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Back up by snapshot and skip verification so both hops run
+	snapshotBackups(s)
+	job := jobs.NewJob("job-two-hop", jobs.JobModeManual, "1.1.0")
+	job.ResolvedTarget = "1.1.0"
+	job.SkipVerify = true
+	jobStore.Save(job)
+
+	s.executeUpgrade(job, &manifest.Manifest{Image: manifest.Image{Repo: "payramapp/payram"}}, nil, "1.0.5")
+
+	calls := readCalls(t, callLog)
+	for _, want := range []string{"pull payramapp/payram:1.0.5-prod", "pull payramapp/payram:1.1.0-prod"} {
+		if !strings.Contains(calls, want+"\n") {
+			t.Errorf("expected %q, got calls:\n%s", want, calls)
+		}
+	}
+	if strings.Contains(calls, "-prod-prod") {
+		t.Errorf("expected the target tag to be templated once, got calls:\n%s", calls)
+	}
+}
@@ -0,0 +1,23 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/payram/payram-updater/internal/buildinfo"
+)
+
+// HandleVersion returns a handler for the /version endpoint: the updater's
+// own build information (not Payram Core's version).
+func HandleVersion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(buildinfo.Get())
+	}
+}
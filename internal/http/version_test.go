@@ -0,0 +1,41 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/buildinfo"
+)
+
+func TestHandleVersion_ReportsInjectedBuildInfo(t *testing.T) {
+	defer func(version, commit, date string) {
+		buildinfo.Version, buildinfo.Commit, buildinfo.Date = version, commit, date
+	}(buildinfo.Version, buildinfo.Commit, buildinfo.Date)
+	buildinfo.Version, buildinfo.Commit, buildinfo.Date = "1.4.0", "abc1234", "2026-01-02T03:04:05Z"
+
+	w := httptest.NewRecorder()
+	HandleVersion()(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var got buildinfo.Info
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := buildinfo.Info{Version: "1.4.0", Commit: "abc1234", BuildDate: "2026-01-02T03:04:05Z", GoVersion: runtime.Version()}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestHandleVersion_MethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandleVersion()(w, httptest.NewRequest(http.MethodPost, "/version", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
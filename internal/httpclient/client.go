@@ -0,0 +1,131 @@
+// Package httpclient provides the HTTP clients used for every outbound
+// request the updater makes (payram-core, policy, manifest, hooks), so that
+// connection pooling, dial/header timeouts and TLS policy live in one place.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DialTimeout bounds establishing a TCP connection.
+	DialTimeout = 5 * time.Second
+	// TLSHandshakeTimeout bounds the TLS handshake.
+	TLSHandshakeTimeout = 5 * time.Second
+	// ResponseHeaderTimeout bounds the wait for response headers once the
+	// request is written. It is an upper bound independent of the per-client
+	// overall timeout, so a server that accepts but never answers is dropped.
+	ResponseHeaderTimeout = 30 * time.Second
+	// IdleConnTimeout is how long an idle pooled connection is kept.
+	IdleConnTimeout = 90 * time.Second
+	// MaxIdleConnsPerHost caps pooled connections per host; the updater talks
+	// to a handful of hosts with little concurrency.
+	MaxIdleConnsPerHost = 4
+)
+
+var (
+	sharedMu       sync.Mutex
+	shared         *http.Transport
+	sharedTLS      *tls.Config
+	sharedCAFile   string
+	loopbackOnce   sync.Once
+	loopbackShared *http.Transport
+)
+
+// newTransport returns a tuned transport using tlsConfig (nil means the
+// system roots with default verification).
+func newTransport(tlsConfig *tls.Config, responseHeaderTimeout time.Duration) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          16,
+		MaxIdleConnsPerHost:   MaxIdleConnsPerHost,
+		IdleConnTimeout:       IdleConnTimeout,
+		TLSHandshakeTimeout:   TLSHandshakeTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+}
+
+// sharedTransport is the pooled transport for verified TLS and plain HTTP.
+func sharedTransport() *http.Transport {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared == nil {
+		shared = newTransport(sharedTLS, ResponseHeaderTimeout)
+	}
+	return shared
+}
+
+// Configure makes clients from New trust the PEM certificates in caFile
+// (TLS_CA_FILE), e.g. a corporate proxy's CA, in addition to the system
+// roots; "" trusts the system roots alone. Call it once the config is
+// loaded, before the first client is created: clients already created keep
+// the previous transport. Calling it again with the same file does nothing.
+func Configure(caFile string) error {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if caFile == sharedCAFile {
+		return nil
+	}
+
+	var tlsConfig *tls.Config
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS_CA_FILE: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("TLS_CA_FILE %s holds no PEM certificates", caFile)
+		}
+		tlsConfig = &tls.Config{RootCAs: roots}
+	}
+
+	if shared != nil {
+		shared.CloseIdleConnections()
+		shared = nil
+	}
+	sharedTLS = tlsConfig
+	sharedCAFile = caFile
+	return nil
+}
+
+// loopbackTransport is the pooled transport for payram-core on a loopback
+// address, which may present a self-signed certificate or one whose SAN does
+// not include the loopback IP.
+func loopbackTransport() *http.Transport {
+	loopbackOnce.Do(func() {
+		loopbackShared = newTransport(&tls.Config{InsecureSkipVerify: true}, ResponseHeaderTimeout) //nolint:gosec
+	})
+	return loopbackShared
+}
+
+// New returns a client on the shared transport with the given overall request
+// timeout (0 relies on the request context alone).
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: sharedTransport()}
+}
+
+// NewLoopback returns a client that skips TLS verification. It must only be
+// used for loopback addresses, where the certificate cannot be meaningfully
+// verified.
+func NewLoopback(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: loopbackTransport()}
+}
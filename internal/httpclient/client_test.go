@@ -0,0 +1,150 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSlowServer returns a server that waits delay before sending headers.
+func newSlowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-release:
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
+
+func TestNew_EnforcesTimeout(t *testing.T) {
+	server := newSlowServer(t, 5*time.Second)
+
+	start := time.Now()
+	resp, err := New(100 * time.Millisecond).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected a timeout error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the request to time out after ~100ms, took %s", elapsed)
+	}
+}
+
+func TestNewTransport_ResponseHeaderTimeout(t *testing.T) {
+	server := newSlowServer(t, 5*time.Second)
+	client := &http.Client{Transport: newTransport(nil, 100*time.Millisecond)}
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected a response header timeout, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the request to time out after ~100ms, took %s", elapsed)
+	}
+}
+
+func TestNew_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := New(time.Second)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("expected 1 pooled connection, got %d", got)
+	}
+}
+
+func TestNewLoopback_AcceptsSelfSignedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	resp, err := NewLoopback(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected loopback client to accept a self-signed certificate: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp, err := New(time.Second).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected the verifying client to reject a self-signed certificate")
+	}
+}
+
+func TestConfigure_TrustsCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	t.Cleanup(func() { Configure("") })
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure(caFile); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	resp, err := New(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the client to trust the CA file: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := Configure(""); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	if resp, err := New(time.Second).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected the certificate rejected once the CA file is dropped")
+	}
+}
+
+func TestConfigure_RejectsFileWithoutCertificates(t *testing.T) {
+	t.Cleanup(func() { Configure("") })
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, []byte("not a certificate"), 0644)
+
+	if err := Configure(caFile); err == nil || !strings.Contains(err.Error(), "no PEM certificates") {
+		t.Errorf("expected a no-certificates error, got %v", err)
+	}
+	if err := Configure(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
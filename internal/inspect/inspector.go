@@ -4,12 +4,19 @@ package inspect
 import (
 	"context"
 	"fmt"
+	"io"
+	"log"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/coreclient"
 	"github.com/payram/payram-updater/internal/corecompat"
+	"github.com/payram/payram-updater/internal/diskspace"
 	"github.com/payram/payram-updater/internal/jobs"
 	"github.com/payram/payram-updater/internal/manifest"
 	"github.com/payram/payram-updater/internal/policy"
@@ -41,13 +48,20 @@ type Recommendation struct {
 
 // UpdateInfo contains information about available updates.
 type UpdateInfo struct {
-	CurrentVersion        string          `json:"currentVersion"`
-	LatestVersion         string          `json:"latestVersion"`
-	UpdateAvailable       bool            `json:"updateAvailable"`
-	CanUpdateViaDashboard bool            `json:"canUpdateViaDashboard"`
-	MaxDashboardVersion   string          `json:"maxDashboardVersion,omitempty"`
-	NextBreakpoint        *BreakpointInfo `json:"nextBreakpoint,omitempty"`
-	Message               string          `json:"message"`
+	CurrentVersion        string           `json:"currentVersion"`
+	LatestVersion         string           `json:"latestVersion"`
+	UpdateAvailable       bool             `json:"updateAvailable"`
+	CanUpdateViaDashboard bool             `json:"canUpdateViaDashboard"`
+	MaxDashboardVersion   string           `json:"maxDashboardVersion,omitempty"`
+	NextBreakpoint        *BreakpointInfo  `json:"nextBreakpoint,omitempty"`
+	Deprecated            *DeprecationInfo `json:"deprecated,omitempty"` // set when the policy deprecates CurrentVersion
+	Message               string           `json:"message"`
+}
+
+// DeprecationInfo is why the policy deprecates the running version.
+type DeprecationInfo struct {
+	Reason string `json:"reason"`
+	Docs   string `json:"docs,omitempty"`
 }
 
 // BreakpointInfo contains information about the next version breakpoint.
@@ -74,6 +88,11 @@ type CheckResult struct {
 	Message string `json:"message"`
 }
 
+// BackupLister reports the newest available backup (satisfied by *backup.Manager).
+type BackupLister interface {
+	GetLatestBackup() (*backup.BackupListItem, error)
+}
+
 // Inspector performs read-only system inspection.
 type Inspector struct {
 	jobStore      *jobs.Store
@@ -86,6 +105,29 @@ type Inspector struct {
 	policyInitSet bool
 	debugMode     bool
 	releaseOrder  []string // For debug mode version ordering
+
+	// Core version resolved once per Run and shared by the health and version checks
+	coreVersion    string
+	coreLegacy     bool
+	coreVersionErr error
+	coreVersionSet bool
+
+	backups      BackupLister
+	backupMaxAge time.Duration
+	now          func() time.Time
+
+	// backupDir is compared against the DB data mount of runtimeState,
+	// which checkRuntimeLayout records for the current Run.
+	backupDir    string
+	deviceOf     diskspace.DeviceFunc
+	runtimeState *container.RuntimeState
+
+	resolved   *container.ResolvedContainer
+	discoverer container.PayramDiscoverer
+
+	// restartSampleInterval separates the two restart count readings that
+	// tell a crash loop from restarts that have stopped.
+	restartSampleInterval time.Duration
 }
 
 // NewInspector creates a new inspector with the given configuration.
@@ -106,9 +148,33 @@ func NewInspector(
 		policyURL:     policyURL,
 		manifestURL:   manifestURL,
 		debugMode:     debugMode,
+		now:           time.Now,
+
+		restartSampleInterval: 2 * time.Second,
 	}
 }
 
+// SetBackupCheck enables the backup recency check. maxAge <= 0 only warns
+// when there are no backups at all.
+func (i *Inspector) SetBackupCheck(backups BackupLister, maxAge time.Duration) {
+	i.backups = backups
+	i.backupMaxAge = maxAge
+}
+
+// SetBackupLocationCheck enables warning when backupDir shares a filesystem
+// with the database data.
+func (i *Inspector) SetBackupLocationCheck(backupDir string) {
+	i.backupDir = backupDir
+	i.deviceOf = diskspace.PathDevice
+}
+
+// SetNameCheck enables comparing the resolved container name against the
+// Payram container discovery finds running.
+func (i *Inspector) SetNameCheck(resolved *container.ResolvedContainer, discoverer container.PayramDiscoverer) {
+	i.resolved = resolved
+	i.discoverer = discoverer
+}
+
 // Run performs all inspection checks and returns the result.
 func (i *Inspector) Run(ctx context.Context) *InspectResult {
 	result := &InspectResult{
@@ -117,6 +183,8 @@ func (i *Inspector) Run(ctx context.Context) *InspectResult {
 		Recommendations: []Recommendation{},
 		Checks:          make(map[string]CheckResult),
 	}
+	i.coreVersionSet = false
+	i.runtimeState = nil
 
 	// Check 1: Last upgrade job state
 	i.checkLastJob(result)
@@ -127,6 +195,9 @@ func (i *Inspector) Run(ctx context.Context) *InspectResult {
 	// Check 3: Container existence and running state
 	i.checkContainer(ctx, result)
 
+	// Check 3b: Restart count of a running container (crash loops)
+	i.checkRestarts(ctx, result)
+
 	// Check 4: Policy readability
 	i.checkPolicy(ctx, result)
 
@@ -142,6 +213,18 @@ func (i *Inspector) Run(ctx context.Context) *InspectResult {
 	// Check 8: Update availability
 	i.checkUpdateAvailability(ctx, result)
 
+	// Check 9: Runtime layout the upgrade may not reproduce (compose, named networks, ...)
+	i.checkRuntimeLayout(ctx, result)
+
+	// Check 10: Age of the newest backup
+	i.checkBackupRecency(result)
+
+	// Check 11: Configured container name vs. the container discovery finds
+	i.checkContainerName(ctx, result)
+
+	// Check 12: Backups on the same filesystem as the database data
+	i.checkBackupLocation(result)
+
 	// Generate recommendations based on state
 	i.generateRecommendations(result)
 
@@ -179,9 +262,13 @@ func (i *Inspector) checkLastJob(result *InspectResult) {
 			Status:  "FAILED",
 			Message: fmt.Sprintf("Last upgrade failed: %s - %s", job.FailureCode, job.Message),
 		}
+		description := fmt.Sprintf("Last upgrade failed with code %s: %s", job.FailureCode, job.Message)
+		if job.ContainerLogPath != "" {
+			description += fmt.Sprintf(" (container logs saved to %s)", job.ContainerLogPath)
+		}
 		result.Issues = append(result.Issues, Issue{
 			Component:   "upgrade",
-			Description: fmt.Sprintf("Last upgrade failed with code %s: %s", job.FailureCode, job.Message),
+			Description: description,
 			Severity:    "CRITICAL",
 		})
 		result.OverallState = StateBroken
@@ -281,6 +368,329 @@ func (i *Inspector) checkContainer(ctx context.Context, result *InspectResult) {
 	}
 }
 
+// highRestartCount is the restart count from which a running container is
+// reported as unstable even if it is not restarting right now.
+const highRestartCount = 3
+
+// checkRestarts flags a running container that Docker keeps restarting. A
+// crash-looping container shows as running between restarts, so the restart
+// count is read twice: a count that rises is a crash loop, a high count that
+// holds steady is a warning.
+func (i *Inspector) checkRestarts(ctx context.Context, result *InspectResult) {
+	containerCheck, ok := result.Checks["container"]
+	if !ok || containerCheck.Status != "OK" {
+		result.Checks["restarts"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: "Skipped (container not running)",
+		}
+		return
+	}
+
+	first, err := i.restartCount(ctx)
+	if err != nil {
+		result.Checks["restarts"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: fmt.Sprintf("Failed to read restart count: %v", err),
+		}
+		return
+	}
+	// A container that has never restarted cannot be crash-looping yet
+	last := first
+	if first > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(i.restartSampleInterval):
+			if count, err := i.restartCount(ctx); err == nil {
+				last = count
+			}
+		}
+	}
+
+	switch {
+	case last > first:
+		result.Checks["restarts"] = CheckResult{
+			Status:  "FAILED",
+			Message: fmt.Sprintf("Container is crash-looping (restart count %d -> %d)", first, last),
+		}
+		result.Issues = append(result.Issues, Issue{
+			Component:   "container",
+			Description: fmt.Sprintf("Container restarted %d time(s) during inspection (%d restarts in total); it is crash-looping", last-first, last),
+			Severity:    "CRITICAL",
+		})
+		if result.OverallState == StateOK {
+			result.OverallState = StateDegraded
+		}
+	case last >= highRestartCount:
+		result.Checks["restarts"] = CheckResult{
+			Status:  "WARNING",
+			Message: fmt.Sprintf("Container has restarted %d times", last),
+		}
+		result.Issues = append(result.Issues, Issue{
+			Component:   "container",
+			Description: fmt.Sprintf("Container has restarted %d times; check its logs for crashes", last),
+			Severity:    "WARNING",
+		})
+		if result.OverallState == StateOK {
+			result.OverallState = StateDegraded
+		}
+	default:
+		result.Checks["restarts"] = CheckResult{
+			Status:  "OK",
+			Message: fmt.Sprintf("Restart count: %d", last),
+		}
+	}
+}
+
+// restartCount reads how many times Docker has restarted the container.
+func (i *Inspector) restartCount(ctx context.Context) (int, error) {
+	output, err := exec.CommandContext(ctx, i.dockerBin, "inspect", "--format", "{{.RestartCount}}", i.containerName).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+func (i *Inspector) checkRuntimeLayout(ctx context.Context, result *InspectResult) {
+	containerCheck, ok := result.Checks["container"]
+	if !ok || containerCheck.Status != "OK" {
+		result.Checks["runtimeLayout"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: "Skipped (container not running)",
+		}
+		return
+	}
+
+	runtimeInspector := container.NewInspector(i.dockerBin, log.New(io.Discard, "", 0))
+	state, err := runtimeInspector.ExtractRuntimeState(ctx, i.containerName)
+	if err != nil {
+		result.Checks["runtimeLayout"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: fmt.Sprintf("Failed to inspect runtime layout: %v", err),
+		}
+		return
+	}
+
+	i.runtimeState = state
+	recordLayoutWarnings(result, container.DetectUnmanagedLayout(state))
+}
+
+// recordLayoutWarnings reports layout warnings as issues. They do not change the
+// overall state: the container is healthy, but the next upgrade needs review.
+func recordLayoutWarnings(result *InspectResult, warnings []container.LayoutWarning) {
+	if len(warnings) == 0 {
+		result.Checks["runtimeLayout"] = CheckResult{
+			Status:  "OK",
+			Message: "Container layout is compatible with upgrades",
+		}
+		return
+	}
+
+	result.Checks["runtimeLayout"] = CheckResult{
+		Status:  "WARNING",
+		Message: fmt.Sprintf("%d layout pattern(s) may not survive an upgrade", len(warnings)),
+	}
+	for _, w := range warnings {
+		result.Issues = append(result.Issues, Issue{
+			Component:   "container",
+			Description: fmt.Sprintf("%s: %s", w.Code, w.Message),
+			Severity:    "WARNING",
+		})
+	}
+}
+
+// checkBackupRecency warns when there is no backup, or the newest one is older
+// than the configured maximum age. Like layout warnings it does not change the
+// overall state: the system may be healthy, but recovery options are thin.
+func (i *Inspector) checkBackupRecency(result *InspectResult) {
+	if i.backups == nil {
+		result.Checks["backupRecency"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: "Skipped (backup check not configured)",
+		}
+		return
+	}
+
+	latest, err := i.backups.GetLatestBackup()
+	if err != nil {
+		result.Checks["backupRecency"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: fmt.Sprintf("Failed to list backups: %v", err),
+		}
+		return
+	}
+	if latest == nil {
+		result.Checks["backupRecency"] = CheckResult{
+			Status:  "WARNING",
+			Message: "No backups found",
+		}
+		result.Issues = append(result.Issues, Issue{
+			Component:   "backup",
+			Description: "No database backups exist; a failed upgrade or data loss could not be recovered",
+			Severity:    "WARNING",
+		})
+		return
+	}
+
+	createdAt, err := backupTime(latest)
+	if err != nil {
+		result.Checks["backupRecency"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: fmt.Sprintf("Latest backup %s has unknown age: %v", latest.Filename, err),
+		}
+		return
+	}
+
+	age := i.now().Sub(createdAt)
+	if i.backupMaxAge > 0 && age > i.backupMaxAge {
+		result.Checks["backupRecency"] = CheckResult{
+			Status:  "WARNING",
+			Message: fmt.Sprintf("Latest backup %s is %s old (threshold %s)", latest.Filename, formatAge(age), formatAge(i.backupMaxAge)),
+		}
+		result.Issues = append(result.Issues, Issue{
+			Component:   "backup",
+			Description: fmt.Sprintf("Newest database backup is %s old", formatAge(age)),
+			Severity:    "WARNING",
+		})
+		return
+	}
+
+	result.Checks["backupRecency"] = CheckResult{
+		Status:  "OK",
+		Message: fmt.Sprintf("Latest backup %s is %s old", latest.Filename, formatAge(age)),
+	}
+}
+
+// checkBackupLocation warns when the backup directory is on the filesystem
+// holding the database data: losing that volume loses the backups with it, and
+// backups fill the database's own disk. Like other backup warnings it does not
+// change the overall state.
+func (i *Inspector) checkBackupLocation(result *InspectResult) {
+	if i.deviceOf == nil || i.backupDir == "" {
+		result.Checks["backupLocation"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: "Skipped (backup location check not configured)",
+		}
+		return
+	}
+	if i.runtimeState == nil {
+		result.Checks["backupLocation"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: "Skipped (container runtime state unavailable)",
+		}
+		return
+	}
+
+	mount := container.DBDataMount(i.runtimeState)
+	if mount == nil || !strings.HasPrefix(mount.Source, "/") {
+		result.Checks["backupLocation"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: "Skipped (database data directory is not on a host mount)",
+		}
+		return
+	}
+
+	shared, err := diskspace.SameFilesystem(i.deviceOf, i.backupDir, mount.Source)
+	if err != nil {
+		result.Checks["backupLocation"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: fmt.Sprintf("Failed to compare filesystems: %v", err),
+		}
+		return
+	}
+	if shared {
+		result.Checks["backupLocation"] = CheckResult{
+			Status:  "WARNING",
+			Message: fmt.Sprintf("Backup directory %s is on the same filesystem as the database data (%s)", i.backupDir, mount.Source),
+		}
+		result.Issues = append(result.Issues, Issue{
+			Component:   "backup",
+			Description: "Backups share a filesystem with the database data; a failure of that volume would lose both, and backups consume the database's disk",
+			Severity:    "WARNING",
+		})
+		return
+	}
+
+	result.Checks["backupLocation"] = CheckResult{
+		Status:  "OK",
+		Message: fmt.Sprintf("Backup directory %s is on a different filesystem from the database data", i.backupDir),
+	}
+}
+
+func (i *Inspector) checkContainerName(ctx context.Context, result *InspectResult) {
+	if i.discoverer == nil || i.resolved == nil {
+		result.Checks["containerName"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: "Skipped (container name check not configured)",
+		}
+		return
+	}
+	if i.resolved.Source == "" {
+		result.Checks["containerName"] = CheckResult{
+			Status:  "OK",
+			Message: fmt.Sprintf("Container '%s' was discovered (no name configured)", i.resolved.Name),
+		}
+		return
+	}
+
+	discovered, err := i.discoverer.DiscoverPayramContainer(ctx)
+	if err != nil {
+		result.Checks["containerName"] = CheckResult{
+			Status:  "UNKNOWN",
+			Message: fmt.Sprintf("Could not discover a running Payram container to compare with: %v", err),
+		}
+		return
+	}
+
+	if warning := container.DiscoveryMismatch(i.resolved, discovered); warning != "" {
+		result.Checks["containerName"] = CheckResult{
+			Status:  "WARNING",
+			Message: warning,
+		}
+		result.Issues = append(result.Issues, Issue{
+			Component:   "container",
+			Description: fmt.Sprintf("Configured container '%s' differs from running Payram container '%s'", i.resolved.Name, discovered.Name),
+			Severity:    "WARNING",
+		})
+		if result.OverallState == StateOK {
+			result.OverallState = StateDegraded
+		}
+		return
+	}
+
+	result.Checks["containerName"] = CheckResult{
+		Status:  "OK",
+		Message: fmt.Sprintf("Configured container '%s' (from %s) matches the running Payram container", i.resolved.Name, i.resolved.Source),
+	}
+}
+
+// backupTime returns when a backup was taken: the timestamp parsed from its
+// filename, or the file's modification time for files without one.
+func backupTime(item *backup.BackupListItem) (time.Time, error) {
+	if item.CreatedAt != "" {
+		if t, err := time.Parse(time.RFC3339, item.CreatedAt); err == nil {
+			return t, nil
+		}
+	}
+	info, err := os.Stat(item.File)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// formatAge renders a duration in days and hours, e.g. "12d 3h".
+func formatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int((d % (24 * time.Hour)) / time.Hour)
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
+
 func (i *Inspector) checkPolicy(ctx context.Context, result *InspectResult) {
 	if i.policyURL == "" {
 		result.Checks["policy"] = CheckResult{
@@ -358,8 +768,7 @@ func (i *Inspector) checkHealth(ctx context.Context, result *InspectResult) {
 		return
 	}
 
-	initVersion := i.getPolicyInitVersion(ctx)
-	_, useLegacy, err := i.resolveCoreVersion(ctx, initVersion)
+	_, useLegacy, err := i.cachedCoreVersion(ctx)
 	if err != nil {
 		result.Checks["health"] = CheckResult{
 			Status:  "WARNING",
@@ -437,11 +846,7 @@ func (i *Inspector) checkVersion(ctx context.Context, result *InspectResult) {
 		return
 	}
 
-	initVersion := i.getPolicyInitVersion(ctx)
-	versionCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
-	defer cancel()
-
-	versionValue, _, err := i.resolveCoreVersion(versionCtx, initVersion)
+	versionValue, _, err := i.cachedCoreVersion(ctx)
 	if err != nil {
 		result.Checks["version"] = CheckResult{
 			Status:  "WARNING",
@@ -549,6 +954,22 @@ func (i *Inspector) getPolicyInitVersion(ctx context.Context) string {
 	return i.policyInitVer
 }
 
+// cachedCoreVersion resolves the running Core version and whether it predates
+// the updater API once per Run, so inspection hits Core's version endpoint once.
+func (i *Inspector) cachedCoreVersion(ctx context.Context) (string, bool, error) {
+	if i.coreVersionSet {
+		return i.coreVersion, i.coreLegacy, i.coreVersionErr
+	}
+
+	initVersion := i.getPolicyInitVersion(ctx)
+	versionCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	i.coreVersion, i.coreLegacy, i.coreVersionErr = i.resolveCoreVersion(versionCtx, initVersion)
+	i.coreVersionSet = true
+	return i.coreVersion, i.coreLegacy, i.coreVersionErr
+}
+
 func (i *Inspector) resolveCoreVersion(ctx context.Context, initVersion string) (string, bool, error) {
 	client := coreclient.NewClient(i.coreBaseURL)
 	versionResp, err := client.Version(ctx)
@@ -616,6 +1037,11 @@ func (i *Inspector) checkUpdateAvailability(ctx context.Context, result *Inspect
 		return
 	}
 
+	// Store release order for debug mode
+	i.releaseOrder = policyData.Releases
+
+	deprecation := i.checkDeprecation(result, policyData, currentVersion)
+
 	latestVersion := strings.TrimSpace(policyData.Latest)
 	if latestVersion == "" {
 		result.Checks["updateCheck"] = CheckResult{
@@ -625,9 +1051,6 @@ func (i *Inspector) checkUpdateAvailability(ctx context.Context, result *Inspect
 		return
 	}
 
-	// Store release order for debug mode
-	i.releaseOrder = policyData.Releases
-
 	// Normalize versions for comparison
 	currentNorm := corecompat.NormalizeVersion(currentVersion)
 	latestNorm := corecompat.NormalizeVersion(latestVersion)
@@ -639,6 +1062,7 @@ func (i *Inspector) checkUpdateAvailability(ctx context.Context, result *Inspect
 		CurrentVersion:  currentVersion,
 		LatestVersion:   latestVersion,
 		UpdateAvailable: cmp < 0,
+		Deprecated:      deprecation,
 	}
 
 	if cmp == 0 {
@@ -762,6 +1186,37 @@ func (i *Inspector) checkUpdateAvailability(ctx context.Context, result *Inspect
 	result.UpdateInfo = updateInfo
 }
 
+// checkDeprecation warns when the policy lists the running version as
+// deprecated. Like backup warnings it is informational: the overall state and
+// dashboard eligibility are unchanged. It returns the deprecation, or nil.
+func (i *Inspector) checkDeprecation(result *InspectResult, policyData *policy.Policy, currentVersion string) *DeprecationInfo {
+	currentNorm := corecompat.NormalizeVersion(currentVersion)
+	for _, d := range policyData.Deprecated {
+		if i.compareVersions(corecompat.NormalizeVersion(d.Version), currentNorm) != 0 {
+			continue
+		}
+		description := fmt.Sprintf("Running version %s is deprecated: %s", currentVersion, d.Reason)
+		if d.Docs != "" {
+			description += fmt.Sprintf(" (see %s)", d.Docs)
+		}
+		result.Checks["deprecation"] = CheckResult{
+			Status:  "WARNING",
+			Message: description,
+		}
+		result.Issues = append(result.Issues, Issue{
+			Component:   "version",
+			Description: description + "; upgrade to a supported release",
+			Severity:    "WARNING",
+		})
+		return &DeprecationInfo{Reason: d.Reason, Docs: d.Docs}
+	}
+	result.Checks["deprecation"] = CheckResult{
+		Status:  "OK",
+		Message: fmt.Sprintf("Running version %s is not deprecated", currentVersion),
+	}
+	return nil
+}
+
 // compareVersions compares two version strings.
 // In debug mode, uses release list ordering. Otherwise uses semver parsing.
 // Returns: -1 if v1 < v2, 0 if v1 == v2, 1 if v1 > v2
@@ -869,7 +1324,7 @@ func (i *Inspector) generateRecommendations(result *InspectResult) {
 				Priority:    priority,
 			})
 			priority++
-		case "POLICY_FETCH_FAILED", "MANIFEST_FETCH_FAILED", "DOCKER_PULL_FAILED", "CONCURRENCY_BLOCKED":
+		case "POLICY_FETCH_FAILED", "MANIFEST_FETCH_FAILED", "DOCKER_PULL_FAILED", "REGISTRY_RATE_LIMITED", "CONCURRENCY_BLOCKED", "RESTORE_IN_PROGRESS":
 			result.Recommendations = append(result.Recommendations, Recommendation{
 				Action:      "retry",
 				Description: "This failure is likely temporary. Retry the upgrade.",
@@ -923,6 +1378,48 @@ func (i *Inspector) generateRecommendations(result *InspectResult) {
 		priority++
 	}
 
+	// If the container layout may not survive an upgrade
+	layoutCheck, ok := result.Checks["runtimeLayout"]
+	if ok && layoutCheck.Status == "WARNING" {
+		result.Recommendations = append(result.Recommendations, Recommendation{
+			Action:      "review_dry_run",
+			Description: "Run 'payram-updater dry-run --to latest' and review the planned docker run args before upgrading",
+			Priority:    priority,
+		})
+		priority++
+	}
+
+	// If there is no recent backup
+	backupCheck, ok := result.Checks["backupRecency"]
+	if ok && backupCheck.Status == "WARNING" {
+		result.Recommendations = append(result.Recommendations, Recommendation{
+			Action:      "create_backup",
+			Description: "Run 'payram-updater backup create' to take a fresh database backup",
+			Priority:    priority,
+		})
+		priority++
+	}
+
+	locationCheck, ok := result.Checks["backupLocation"]
+	if ok && locationCheck.Status == "WARNING" {
+		result.Recommendations = append(result.Recommendations, Recommendation{
+			Action:      "relocate_backups",
+			Description: "Set BACKUP_DIR to a directory on a different disk or volume from the database data",
+			Priority:    priority,
+		})
+		priority++
+	}
+
+	nameCheck, ok := result.Checks["containerName"]
+	if ok && nameCheck.Status == "WARNING" {
+		result.Recommendations = append(result.Recommendations, Recommendation{
+			Action:      "fix_container_name",
+			Description: "Set TARGET_CONTAINER_NAME (or the manifest container_name) to the container that should be upgraded",
+			Priority:    priority,
+		})
+		priority++
+	}
+
 	// If docker daemon is down
 	dockerCheck, ok := result.Checks["dockerDaemon"]
 	if ok && dockerCheck.Status == "FAILED" {
@@ -2,9 +2,18 @@ package inspect
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/container"
 	"github.com/payram/payram-updater/internal/jobs"
 	"github.com/payram/payram-updater/internal/recovery"
 )
@@ -70,6 +79,29 @@ func TestInspector_Run_NoJobOK(t *testing.T) {
 	}
 }
 
+func TestCheckLastJob_FailedJobReferencesContainerLog(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	job := jobs.NewJob("job-1", jobs.JobModeManual, "1.8.0")
+	job.State = jobs.JobStateFailed
+	job.FailureCode = "HEALTHCHECK_FAILED"
+	job.Message = "core did not become healthy"
+	job.ContainerLogPath = "/var/lib/payram-updater/jobs/container-logs/job-1.log"
+	if err := jobStore.Save(job); err != nil {
+		t.Fatal(err)
+	}
+
+	inspector := NewInspector(jobStore, "docker", "payram", "http://localhost:8080", "", "", false)
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+	inspector.checkLastJob(result)
+
+	if len(result.Issues) != 1 || !strings.Contains(result.Issues[0].Description, "container logs saved to "+job.ContainerLogPath) {
+		t.Errorf("expected the failed-job issue to reference the container log, got %+v", result.Issues)
+	}
+	if result.LastJob == nil || result.LastJob.ContainerLogPath != job.ContainerLogPath {
+		t.Errorf("expected lastJob to carry containerLogPath, got %+v", result.LastJob)
+	}
+}
+
 func TestInspector_Run_FailedJobWithPlaybook(t *testing.T) {
 	// Test when there's a failed job - should include recovery playbook
 	tmpDir := t.TempDir()
@@ -306,3 +338,459 @@ func TestRecommendationStructure(t *testing.T) {
 		t.Errorf("expected action 'Restart container', got %s", rec.Action)
 	}
 }
+
+func TestRecordLayoutWarnings_ComposeContainer(t *testing.T) {
+	result := &InspectResult{
+		OverallState:    StateOK,
+		Issues:          []Issue{},
+		Recommendations: []Recommendation{},
+		Checks:          make(map[string]CheckResult),
+	}
+	state := &container.RuntimeState{
+		Name:     "payram",
+		Labels:   map[string]string{"com.docker.compose.project": "payram-stack"},
+		Networks: []container.NetworkConfig{{NetworkName: "payram-stack_default"}},
+	}
+
+	recordLayoutWarnings(result, container.DetectUnmanagedLayout(state))
+
+	if result.Checks["runtimeLayout"].Status != "WARNING" {
+		t.Errorf("expected runtimeLayout WARNING, got %+v", result.Checks["runtimeLayout"])
+	}
+	if len(result.Issues) != 2 {
+		t.Fatalf("expected 2 issues (compose + named network), got %+v", result.Issues)
+	}
+	if result.OverallState != StateOK {
+		t.Errorf("expected layout warnings not to change overall state, got %s", result.OverallState)
+	}
+
+	(&Inspector{}).generateRecommendations(result)
+	found := false
+	for _, rec := range result.Recommendations {
+		if rec.Action == "review_dry_run" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected review_dry_run recommendation, got %+v", result.Recommendations)
+	}
+}
+
+func TestRecordLayoutWarnings_None(t *testing.T) {
+	result := &InspectResult{Checks: make(map[string]CheckResult)}
+
+	recordLayoutWarnings(result, nil)
+
+	if result.Checks["runtimeLayout"].Status != "OK" {
+		t.Errorf("expected runtimeLayout OK, got %+v", result.Checks["runtimeLayout"])
+	}
+	if len(result.Issues) != 0 {
+		t.Errorf("expected no issues, got %+v", result.Issues)
+	}
+}
+
+func TestInspector_Run_ResolvesCoreVersionOnce(t *testing.T) {
+	var versionCalls atomic.Int32
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			versionCalls.Add(1)
+			w.Write([]byte(`{"version":"1.7.0"}`))
+		case "/api/v1/health":
+			w.Write([]byte(`{"status":"ok","db":"ok"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer core.Close()
+
+	// Docker stub that reports a running container
+	dockerBin := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(dockerBin, []byte("#!/bin/sh\necho running\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	inspector := NewInspector(jobs.NewStore(t.TempDir()), dockerBin, "payram-core", core.URL, "", "", false)
+
+	for run := 1; run <= 2; run++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		result := inspector.Run(ctx)
+		cancel()
+
+		if result.Checks["health"].Status != "OK" {
+			t.Errorf("run %d: expected health OK, got %+v", run, result.Checks["health"])
+		}
+		if result.Checks["version"].Message != "Running version: 1.7.0" {
+			t.Errorf("run %d: expected version 1.7.0, got %+v", run, result.Checks["version"])
+		}
+		if got := versionCalls.Load(); got != int32(run) {
+			t.Errorf("run %d: expected %d version endpoint call(s) in total, got %d", run, run, got)
+		}
+	}
+}
+
+type fakeBackupLister struct {
+	latest *backup.BackupListItem
+	err    error
+}
+
+func (f *fakeBackupLister) GetLatestBackup() (*backup.BackupListItem, error) {
+	return f.latest, f.err
+}
+
+func newBackupCheckInspector(t *testing.T, lister BackupLister, now time.Time) *Inspector {
+	t.Helper()
+	inspector := NewInspector(jobs.NewStore(t.TempDir()), "docker", "payram-core", "", "", "", false)
+	inspector.SetBackupCheck(lister, 7*24*time.Hour)
+	inspector.now = func() time.Time { return now }
+	return inspector
+}
+
+func hasRecommendation(result *InspectResult, action string) bool {
+	for _, rec := range result.Recommendations {
+		if rec.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckBackupRecency_NoBackups(t *testing.T) {
+	inspector := newBackupCheckInspector(t, &fakeBackupLister{}, time.Now())
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkBackupRecency(result)
+	inspector.generateRecommendations(result)
+
+	if result.Checks["backupRecency"].Status != "WARNING" {
+		t.Errorf("expected backupRecency WARNING with no backups, got %+v", result.Checks["backupRecency"])
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Component != "backup" {
+		t.Errorf("expected one backup issue, got %+v", result.Issues)
+	}
+	if result.OverallState != StateOK {
+		t.Errorf("expected overall state to stay OK, got %s", result.OverallState)
+	}
+	if !hasRecommendation(result, "create_backup") {
+		t.Errorf("expected create_backup recommendation, got %+v", result.Recommendations)
+	}
+}
+
+func TestCheckBackupRecency_StaleBackup(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	lister := &fakeBackupLister{latest: &backup.BackupListItem{
+		Filename:  "payram-backup-20260101-120000-1.7.0-to-1.8.0.sql",
+		CreatedAt: "2026-01-01T12:00:00Z",
+	}}
+	inspector := newBackupCheckInspector(t, lister, now)
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkBackupRecency(result)
+	inspector.generateRecommendations(result)
+
+	check := result.Checks["backupRecency"]
+	if check.Status != "WARNING" {
+		t.Fatalf("expected backupRecency WARNING for a stale backup, got %+v", check)
+	}
+	if !strings.Contains(check.Message, "59d 0h old") {
+		t.Errorf("expected backup age in message, got %q", check.Message)
+	}
+	if !hasRecommendation(result, "create_backup") {
+		t.Errorf("expected create_backup recommendation, got %+v", result.Recommendations)
+	}
+}
+
+func TestCheckBackupRecency_RecentBackup(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	lister := &fakeBackupLister{latest: &backup.BackupListItem{
+		Filename:  "payram-backup-20260301-060000-1.7.0-to-1.8.0.sql",
+		CreatedAt: "2026-03-01T06:00:00Z",
+	}}
+	inspector := newBackupCheckInspector(t, lister, now)
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkBackupRecency(result)
+	inspector.generateRecommendations(result)
+
+	if result.Checks["backupRecency"].Status != "OK" {
+		t.Errorf("expected backupRecency OK, got %+v", result.Checks["backupRecency"])
+	}
+	if hasRecommendation(result, "create_backup") {
+		t.Errorf("expected no create_backup recommendation, got %+v", result.Recommendations)
+	}
+}
+
+type fakeDiscoverer struct {
+	found *container.DiscoveredContainer
+	err   error
+}
+
+func (f *fakeDiscoverer) DiscoverPayramContainer(ctx context.Context) (*container.DiscoveredContainer, error) {
+	return f.found, f.err
+}
+
+func TestCheckContainerName_Mismatch(t *testing.T) {
+	inspector := NewInspector(jobs.NewStore(t.TempDir()), "docker", "payram-core", "", "", "", false)
+	inspector.SetNameCheck(
+		&container.ResolvedContainer{Name: "payram-core", Source: container.SourceManifest},
+		&fakeDiscoverer{found: &container.DiscoveredContainer{Name: "payram", ImageFull: "payramapp/payram:1.7.0"}},
+	)
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkContainerName(context.Background(), result)
+	inspector.generateRecommendations(result)
+
+	check := result.Checks["containerName"]
+	if check.Status != "WARNING" || !strings.Contains(check.Message, `"payram"`) {
+		t.Errorf("expected containerName WARNING naming the discovered container, got %+v", check)
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Component != "container" {
+		t.Errorf("expected one container issue, got %+v", result.Issues)
+	}
+	if result.OverallState != StateDegraded {
+		t.Errorf("expected overall state DEGRADED, got %s", result.OverallState)
+	}
+	if !hasRecommendation(result, "fix_container_name") {
+		t.Errorf("expected fix_container_name recommendation, got %+v", result.Recommendations)
+	}
+}
+
+func TestCheckContainerName_Match(t *testing.T) {
+	inspector := NewInspector(jobs.NewStore(t.TempDir()), "docker", "payram", "", "", "", false)
+	inspector.SetNameCheck(
+		&container.ResolvedContainer{Name: "payram", Source: container.SourceEnv},
+		&fakeDiscoverer{found: &container.DiscoveredContainer{Name: "payram"}},
+	)
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkContainerName(context.Background(), result)
+
+	if result.Checks["containerName"].Status != "OK" {
+		t.Errorf("expected containerName OK, got %+v", result.Checks["containerName"])
+	}
+	if len(result.Issues) != 0 || result.OverallState != StateOK {
+		t.Errorf("expected no issues and state OK, got %+v (%s)", result.Issues, result.OverallState)
+	}
+}
+
+func TestInspector_Run_ReconciledAfterFullRecovery(t *testing.T) {
+	// A failed upgrade followed by a full-recovery restore should no longer
+	// be reported as a failure
+	jobStore := jobs.NewStore(t.TempDir())
+	failed := jobs.NewJob("test-job-3", jobs.JobModeDashboard, "v2.0.0")
+	failed.ResolvedTarget = "v2.0.0"
+	failed.State = jobs.JobStateFailed
+	failed.FailureCode = "MIGRATION_FAILED"
+	if err := jobStore.Save(failed); err != nil {
+		t.Fatalf("failed to save job: %v", err)
+	}
+	if _, err := jobStore.Reconcile("restore", "v1.9.0", "Reconciled after full-recovery restore"); err != nil {
+		t.Fatalf("failed to reconcile job: %v", err)
+	}
+
+	inspector := NewInspector(
+		jobStore,
+		"docker",
+		"payram-core",
+		"http://localhost:8080",
+		"http://example.com/policy.json",
+		"http://example.com/manifest.json",
+		false, // debugMode
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result := inspector.Run(ctx)
+
+	if result.Checks["lastJob"].Status != "OK" {
+		t.Errorf("expected lastJob check status OK after reconciliation, got %s", result.Checks["lastJob"].Status)
+	}
+	if result.LastJob == nil || result.LastJob.ResolvedTarget != "v1.9.0" {
+		t.Errorf("expected the restored version as last job target, got %+v", result.LastJob)
+	}
+	if result.RecoveryPlaybook != nil {
+		t.Errorf("expected no recovery playbook after reconciliation, got %s", result.RecoveryPlaybook.Code)
+	}
+}
+
+// newRestartCheckInspector returns an inspector for a running container whose
+// restart count starts at start and grows by step on every read.
+func newRestartCheckInspector(t *testing.T, start, step int) *Inspector {
+	t.Helper()
+	dir := t.TempDir()
+	dockerBin := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\n" +
+		"count=\"" + filepath.Join(dir, "reads") + "\"\n" +
+		"echo x >> \"$count\"\n" +
+		"echo $(( ($(wc -l < \"$count\") - 1) * " + strconv.Itoa(step) + " + " + strconv.Itoa(start) + " ))\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	inspector := NewInspector(jobs.NewStore(t.TempDir()), dockerBin, "payram-core", "", "", "", false)
+	inspector.restartSampleInterval = time.Millisecond
+	return inspector
+}
+
+func runRestartCheck(inspector *Inspector) *InspectResult {
+	result := &InspectResult{OverallState: StateOK, Checks: map[string]CheckResult{"container": {Status: "OK"}}}
+	inspector.checkRestarts(context.Background(), result)
+	return result
+}
+
+func TestCheckRestarts_IncreasingCountIsCrashLoop(t *testing.T) {
+	result := runRestartCheck(newRestartCheckInspector(t, 1, 1))
+
+	if result.Checks["restarts"].Status != "FAILED" {
+		t.Fatalf("expected FAILED for a rising restart count, got %+v", result.Checks["restarts"])
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Severity != "CRITICAL" || !strings.Contains(result.Issues[0].Description, "crash-looping") {
+		t.Errorf("expected one critical crash-loop issue, got %+v", result.Issues)
+	}
+	if result.OverallState != StateDegraded {
+		t.Errorf("expected DEGRADED, got %s", result.OverallState)
+	}
+}
+
+func TestCheckRestarts_HighSteadyCountWarns(t *testing.T) {
+	result := runRestartCheck(newRestartCheckInspector(t, 5, 0))
+
+	if result.Checks["restarts"].Status != "WARNING" {
+		t.Fatalf("expected WARNING for a high restart count, got %+v", result.Checks["restarts"])
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Severity != "WARNING" {
+		t.Errorf("expected one warning issue, got %+v", result.Issues)
+	}
+}
+
+func TestCheckRestarts_LowSteadyCountOK(t *testing.T) {
+	result := runRestartCheck(newRestartCheckInspector(t, 1, 0))
+
+	if result.Checks["restarts"].Status != "OK" || len(result.Issues) != 0 || result.OverallState != StateOK {
+		t.Errorf("expected OK without issues, got %+v with %+v", result.Checks["restarts"], result.Issues)
+	}
+}
+
+// newBackupLocationInspector returns an inspector whose backup directory is on
+// backupDev, with the database data on a volume on dataDev.
+func newBackupLocationInspector(t *testing.T, backupDev, dataDev uint64) *Inspector {
+	t.Helper()
+	inspector := NewInspector(jobs.NewStore(t.TempDir()), "docker", "payram-core", "", "", "", false)
+	inspector.SetBackupLocationCheck("/var/lib/payram-updater/backups")
+	inspector.deviceOf = func(path string) (uint64, error) {
+		if path == "/var/lib/payram-updater/backups" {
+			return backupDev, nil
+		}
+		return dataDev, nil
+	}
+	inspector.runtimeState = &container.RuntimeState{Mounts: []container.Mount{
+		{Type: "volume", Name: "pgdata", Source: "/var/lib/docker/volumes/pgdata/_data", Destination: container.DefaultPGDataDir},
+	}}
+	return inspector
+}
+
+func TestCheckBackupLocation_SharedFilesystemWarns(t *testing.T) {
+	inspector := newBackupLocationInspector(t, 2049, 2049)
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkBackupLocation(result)
+	inspector.generateRecommendations(result)
+
+	check := result.Checks["backupLocation"]
+	if check.Status != "WARNING" || !strings.Contains(check.Message, "/var/lib/docker/volumes/pgdata/_data") {
+		t.Fatalf("expected backupLocation WARNING naming the data mount, got %+v", check)
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Component != "backup" {
+		t.Errorf("expected one backup issue, got %+v", result.Issues)
+	}
+	if result.OverallState != StateOK {
+		t.Errorf("expected overall state to stay OK, got %s", result.OverallState)
+	}
+	if !hasRecommendation(result, "relocate_backups") {
+		t.Errorf("expected relocate_backups recommendation, got %+v", result.Recommendations)
+	}
+}
+
+func TestCheckBackupLocation_DifferentFilesystemOK(t *testing.T) {
+	inspector := newBackupLocationInspector(t, 2065, 2049)
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkBackupLocation(result)
+	inspector.generateRecommendations(result)
+
+	if result.Checks["backupLocation"].Status != "OK" {
+		t.Errorf("expected backupLocation OK, got %+v", result.Checks["backupLocation"])
+	}
+	if hasRecommendation(result, "relocate_backups") {
+		t.Errorf("expected no relocate_backups recommendation, got %+v", result.Recommendations)
+	}
+}
+
+func TestCheckBackupLocation_DataNotMountedSkipped(t *testing.T) {
+	inspector := newBackupLocationInspector(t, 2049, 2049)
+	inspector.runtimeState.Mounts = nil
+	result := &InspectResult{OverallState: StateOK, Checks: make(map[string]CheckResult)}
+
+	inspector.checkBackupLocation(result)
+
+	if result.Checks["backupLocation"].Status != "UNKNOWN" {
+		t.Errorf("expected backupLocation UNKNOWN without a data mount, got %+v", result.Checks["backupLocation"])
+	}
+}
+
+// newUpdateCheckInspector returns an inspector whose policy is policyJSON and
+// whose version check reports running.
+func newUpdateCheckInspector(t *testing.T, policyJSON string) (*Inspector, *InspectResult) {
+	t.Helper()
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policyPath, []byte(policyJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inspector := NewInspector(jobs.NewStore(t.TempDir()), "docker", "payram-core", "", policyPath, "", false)
+	result := &InspectResult{OverallState: StateOK, Checks: map[string]CheckResult{
+		"version": {Status: "OK", Message: "Running version: 1.7.0"},
+	}}
+	return inspector, result
+}
+
+func TestCheckUpdateAvailability_DeprecatedVersionWarns(t *testing.T) {
+	inspector, result := newUpdateCheckInspector(t, `{
+		"latest": "1.8.0",
+		"releases": ["1.7.0", "1.8.0"],
+		"deprecated": [{"version": "v1.7.0", "reason": "end of security support", "docs": "https://docs.payram.com/eol"}]
+	}`)
+
+	inspector.checkUpdateAvailability(context.Background(), result)
+
+	check := result.Checks["deprecation"]
+	if check.Status != "WARNING" || !strings.Contains(check.Message, "end of security support") {
+		t.Errorf("expected a deprecation warning, got %+v", check)
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Severity != "WARNING" || !strings.Contains(result.Issues[0].Description, "https://docs.payram.com/eol") {
+		t.Errorf("expected one deprecation issue linking the docs, got %+v", result.Issues)
+	}
+	info := result.UpdateInfo
+	if info == nil || info.Deprecated == nil || info.Deprecated.Reason != "end of security support" {
+		t.Fatalf("expected the deprecation on the update info, got %+v", info)
+	}
+	if !info.CanUpdateViaDashboard || result.OverallState != StateOK {
+		t.Errorf("expected the deprecation to be informational, got eligible=%v state=%s", info.CanUpdateViaDashboard, result.OverallState)
+	}
+}
+
+func TestCheckUpdateAvailability_SupportedVersionNotDeprecated(t *testing.T) {
+	inspector, result := newUpdateCheckInspector(t, `{
+		"latest": "1.8.0",
+		"releases": ["1.6.0", "1.7.0", "1.8.0"],
+		"deprecated": [{"version": "1.6.0", "reason": "end of life"}]
+	}`)
+
+	inspector.checkUpdateAvailability(context.Background(), result)
+
+	if result.Checks["deprecation"].Status != "OK" || len(result.Issues) != 0 {
+		t.Errorf("expected no deprecation warning, got %+v / %+v", result.Checks["deprecation"], result.Issues)
+	}
+	if result.UpdateInfo == nil || result.UpdateInfo.Deprecated != nil {
+		t.Errorf("expected no deprecation on the update info, got %+v", result.UpdateInfo)
+	}
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// LogCursorHeader is the /upgrade/logs response header holding the LogCursor
+// of the first byte of the body. A body is one contiguous run of a single log
+// file, so a client adding the bytes it has consumed gets the cursor to
+// resume from with ?after=.
+const LogCursorHeader = "X-Log-Cursor"
+
+// LogMoreHeader is set to "true" on an /upgrade/logs response that ends at
+// the end of a rotated log file: a newer file follows, and the client should
+// request again from where the body ended.
+const LogMoreHeader = "X-Log-More"
+
+// LogCursor is a position in the job log. Rotation renames logs.txt, so the
+// file is identified by inode rather than by name, and a cursor into a file
+// rotated since it was handed out still resolves.
+type LogCursor struct {
+	File   uint64 // inode of the log file, 0 for none
+	Offset int64  // bytes into the file
+}
+
+// String formats the cursor as "<file>:<offset>", the form ParseLogCursor
+// reads.
+func (c LogCursor) String() string {
+	return fmt.Sprintf("%d:%d", c.File, c.Offset)
+}
+
+// Advance returns the cursor n bytes further into the same file.
+func (c LogCursor) Advance(n int) LogCursor {
+	c.Offset += int64(n)
+	return c
+}
+
+// ParseLogCursor parses a cursor formatted by LogCursor.String. The empty
+// string is the zero LogCursor, the start of the log.
+func ParseLogCursor(s string) (LogCursor, error) {
+	if s == "" {
+		return LogCursor{}, nil
+	}
+	file, offset, ok := strings.Cut(s, ":")
+	if !ok {
+		return LogCursor{}, fmt.Errorf("invalid log cursor %q", s)
+	}
+	var c LogCursor
+	var err error
+	if c.File, err = strconv.ParseUint(file, 10, 64); err != nil {
+		return LogCursor{}, fmt.Errorf("invalid log cursor %q", s)
+	}
+	if c.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil || c.Offset < 0 {
+		return LogCursor{}, fmt.Errorf("invalid log cursor %q", s)
+	}
+	return c, nil
+}
+
+// OpenLogs opens the job log for reading from after, creating an empty log if
+// there is none yet. A cursor into the current logs.txt resumes there. A
+// cursor into the most recently rotated file resumes there instead, and more
+// reports that a newer file follows once it is read to the end. Any other
+// cursor, including the zero LogCursor, starts at the beginning of logs.txt.
+// The caller must close the returned file.
+func (s *Store) OpenLogs(after LogCursor) (f *os.File, at LogCursor, more bool, err error) {
+	if err := s.ensureJobDir(); err != nil {
+		return nil, LogCursor{}, false, err
+	}
+	f, err = os.OpenFile(s.logsPath(), os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, LogCursor{}, false, fmt.Errorf("failed to open log file: %w", err)
+	}
+	file, size, err := logFileInfo(f)
+	if err != nil {
+		f.Close()
+		return nil, LogCursor{}, false, err
+	}
+
+	if after.File != 0 && after.File != file {
+		// The cursor's file may have been rotated since; finish it first
+		if rotated, err := os.Open(s.logsPath() + ".1"); err == nil {
+			rotatedFile, rotatedSize, err := logFileInfo(rotated)
+			if err == nil && rotatedFile == after.File && after.Offset < rotatedSize {
+				if _, err := rotated.Seek(after.Offset, io.SeekStart); err == nil {
+					f.Close()
+					return rotated, after, true, nil
+				}
+			}
+			rotated.Close()
+		}
+	}
+
+	at = LogCursor{File: file}
+	if after.File == file && after.Offset <= size {
+		at.Offset = after.Offset
+	}
+	if _, err := f.Seek(at.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, LogCursor{}, false, fmt.Errorf("failed to seek log file: %w", err)
+	}
+	return f, at, false, nil
+}
+
+// IsCurrentLog reports whether f, opened by OpenLogs, is still logs.txt, that
+// is, the log has not been rotated since.
+func (s *Store) IsCurrentLog(f *os.File) bool {
+	current, err := os.Stat(s.logsPath())
+	if err != nil {
+		return false
+	}
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return os.SameFile(current, opened)
+}
+
+// logFileInfo returns the inode and size of an open log file.
+func logFileInfo(f *os.File) (uint64, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat log file: %w", err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, fmt.Errorf("failed to stat log file: no inode for %s", f.Name())
+	}
+	return stat.Ino, info.Size(), nil
+}
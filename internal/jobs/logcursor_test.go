@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// readLogsFrom opens the log at after and returns what it holds from there.
+func readLogsFrom(t *testing.T, store *Store, after LogCursor) (string, LogCursor, bool) {
+	t.Helper()
+	f, at, more, err := store.OpenLogs(after)
+	if err != nil {
+		t.Fatalf("OpenLogs failed: %v", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	return string(data), at, more
+}
+
+func TestStore_OpenLogs_ResumesAtCursor(t *testing.T) {
+	store := NewStore(t.TempDir())
+	store.AppendLog("first")
+
+	logs, at, more := readLogsFrom(t, store, LogCursor{})
+	if logs != "first\n" || at.Offset != 0 || more {
+		t.Fatalf("expected the whole log from offset 0, got %q at %v (more=%v)", logs, at, more)
+	}
+	store.AppendLog("second")
+
+	logs, _, _ = readLogsFrom(t, store, at.Advance(len("first\n")))
+	if logs != "second\n" {
+		t.Errorf("expected only the line after the cursor, got %q", logs)
+	}
+	logs, _, _ = readLogsFrom(t, store, LogCursor{File: at.File + 1, Offset: 3})
+	if logs != "first\nsecond\n" {
+		t.Errorf("expected an unknown cursor to start at the beginning, got %q", logs)
+	}
+}
+
+func TestStore_OpenLogs_ResumesAcrossRotation(t *testing.T) {
+	store := NewStore(t.TempDir())
+	// "first" and "second" fit in 14 bytes; "third" starts a new file
+	store.LogMaxBytes = 14
+	store.LogMaxFiles = 2
+	store.AppendLog("first")
+
+	_, at, _ := readLogsFrom(t, store, LogCursor{})
+	cursor := at.Advance(len("first\n"))
+	store.AppendLog("second")
+	store.AppendLog("third")
+
+	logs, at, more := readLogsFrom(t, store, cursor)
+	if logs != "second\n" || at != cursor || !more {
+		t.Fatalf("expected the rest of the rotated file with more=true, got %q at %v (more=%v)", logs, at, more)
+	}
+	logs, at, more = readLogsFrom(t, store, cursor.Advance(len("second\n")))
+	if logs != "third\n" || at.File == cursor.File || at.Offset != 0 || more {
+		t.Errorf("expected the new file from its start, got %q at %v (more=%v)", logs, at, more)
+	}
+}
+
+func TestStore_OpenLogs_CreatesEmptyLog(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	logs, at, _ := readLogsFrom(t, store, LogCursor{})
+	if logs != "" || at.File == 0 {
+		t.Errorf("expected an empty log with a cursor into it, got %q at %v", logs, at)
+	}
+	if _, err := os.Stat(store.logsPath()); err != nil {
+		t.Errorf("expected logs.txt to be created: %v", err)
+	}
+}
+
+func TestParseLogCursor(t *testing.T) {
+	cursor, err := ParseLogCursor(LogCursor{File: 42, Offset: 7}.String())
+	if err != nil || cursor != (LogCursor{File: 42, Offset: 7}) {
+		t.Errorf("expected the cursor to round-trip, got %v (%v)", cursor, err)
+	}
+	if cursor, err := ParseLogCursor(""); err != nil || cursor != (LogCursor{}) {
+		t.Errorf("expected the empty string to be the zero cursor, got %v (%v)", cursor, err)
+	}
+	for _, bad := range []string{"42", "x:7", "42:x", "42:-1"} {
+		if _, err := ParseLogCursor(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
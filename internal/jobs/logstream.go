@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultLogSubscriberBuffer is how many log lines a subscriber may fall
+// behind before lines are dropped for it.
+const DefaultLogSubscriberBuffer = 256
+
+// LogSubscription receives the lines AppendLog writes from the moment it is
+// created. Delivery never blocks the writer: when the subscriber falls more
+// than its buffer behind, lines are dropped and replaced by a single
+// "(N lines dropped)" marker once it catches up.
+type LogSubscription struct {
+	lines chan string
+	store *Store
+
+	mu      sync.Mutex
+	dropped int
+	closed  bool
+}
+
+// SubscribeLogs returns a subscription to the job log with room for buffer
+// undelivered lines (DefaultLogSubscriberBuffer if buffer < 1). The caller
+// must Close it.
+func (s *Store) SubscribeLogs(buffer int) *LogSubscription {
+	if buffer < 1 {
+		buffer = DefaultLogSubscriberBuffer
+	}
+	sub := &LogSubscription{lines: make(chan string, buffer), store: s}
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if s.subs == nil {
+		s.subs = make(map[*LogSubscription]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	return sub
+}
+
+// Lines returns the channel delivering log lines, without trailing newlines.
+// It is closed by Close.
+func (sub *LogSubscription) Lines() <-chan string {
+	return sub.lines
+}
+
+// Dropped returns how many lines were dropped and not yet reported by a
+// marker.
+func (sub *LogSubscription) Dropped() int {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.dropped
+}
+
+// Close unsubscribes and closes the Lines channel. It is safe to call more
+// than once.
+func (sub *LogSubscription) Close() {
+	sub.store.subsMu.Lock()
+	delete(sub.store.subs, sub)
+	sub.store.subsMu.Unlock()
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.lines)
+	}
+}
+
+// deliver hands line to the subscriber without blocking. A pending drop
+// marker goes first; if there is no room for it, line is dropped too.
+func (sub *LogSubscription) deliver(line string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+
+	if sub.dropped > 0 {
+		select {
+		case sub.lines <- fmt.Sprintf("(%d lines dropped)", sub.dropped):
+			sub.dropped = 0
+		default:
+			sub.dropped++
+			return
+		}
+	}
+
+	select {
+	case sub.lines <- line:
+	default:
+		sub.dropped++
+	}
+}
+
+// publishLog delivers line to every subscriber.
+func (s *Store) publishLog(line string) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	for sub := range s.subs {
+		sub.deliver(line)
+	}
+}
@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSubscribeLogs_DeliversAppendedLines(t *testing.T) {
+	store := NewStore(t.TempDir())
+	sub := store.SubscribeLogs(10)
+	defer sub.Close()
+
+	for _, line := range []string{"first", "second"} {
+		if err := store.AppendLog(line); err != nil {
+			t.Fatalf("AppendLog failed: %v", err)
+		}
+	}
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case got := <-sub.Lines():
+			if got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+}
+
+func TestSubscribeLogs_SlowSubscriberNeverBlocksAppend(t *testing.T) {
+	store := NewStore(t.TempDir())
+	slow := store.SubscribeLogs(2) // never read until the writer is done
+	defer slow.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 1; i <= 100; i++ {
+			if err := store.AppendLog(fmt.Sprintf("line %d", i)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AppendLog failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AppendLog blocked on a slow subscriber")
+	}
+
+	if got := slow.Dropped(); got != 98 {
+		t.Errorf("expected 98 dropped lines, got %d", got)
+	}
+
+	// The buffered lines arrive first, then the drop marker with the next line
+	for _, want := range []string{"line 1", "line 2"} {
+		if got := <-slow.Lines(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+	if err := store.AppendLog("line 101"); err != nil {
+		t.Fatalf("AppendLog failed: %v", err)
+	}
+	for _, want := range []string{"(98 lines dropped)", "line 101"} {
+		if got := <-slow.Lines(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+	if got := slow.Dropped(); got != 0 {
+		t.Errorf("expected the drop count to be reset after the marker, got %d", got)
+	}
+
+	// The log file itself is complete
+	logs, _ := store.ReadLogs()
+	if want := "line 100\nline 101\n"; logs[len(logs)-len(want):] != want {
+		t.Errorf("expected every line in the log file, got tail %q", logs[len(logs)-len(want):])
+	}
+}
+
+func TestSubscribeLogs_SlowSubscriberDoesNotStarveOthers(t *testing.T) {
+	store := NewStore(t.TempDir())
+	slow := store.SubscribeLogs(1)
+	defer slow.Close()
+	fast := store.SubscribeLogs(100)
+	defer fast.Close()
+
+	for i := 1; i <= 50; i++ {
+		store.AppendLog(fmt.Sprintf("line %d", i))
+	}
+
+	if len(fast.Lines()) != 50 || fast.Dropped() != 0 {
+		t.Errorf("expected the fast subscriber to get all 50 lines, got %d (dropped %d)", len(fast.Lines()), fast.Dropped())
+	}
+	if slow.Dropped() != 49 {
+		t.Errorf("expected the slow subscriber to drop 49 lines, got %d", slow.Dropped())
+	}
+}
+
+func TestLogSubscription_Close(t *testing.T) {
+	store := NewStore(t.TempDir())
+	sub := store.SubscribeLogs(0)
+	sub.Close()
+	sub.Close()
+
+	if _, ok := <-sub.Lines(); ok {
+		t.Error("expected Lines to be closed")
+	}
+	// Appending after Close must not panic on the closed channel
+	if err := store.AppendLog("after close"); err != nil {
+		t.Fatalf("AppendLog failed: %v", err)
+	}
+}
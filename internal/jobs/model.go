@@ -24,20 +24,69 @@ const (
 	JobStateExecuting        JobState = "EXECUTING"
 	JobStateVerifying        JobState = "VERIFYING"
 	JobStateFailed           JobState = "FAILED"
+	JobStateCancelled        JobState = "CANCELLED" // stopped before any destructive step; container untouched
 )
 
+// Checkpoint is the last upgrade phase a job completed, so an upgrade the
+// updater died in can be resumed where that is safe.
+type Checkpoint string
+
+const (
+	CheckpointPreflight Checkpoint = "PREFLIGHT" // pre-flight checks passed, pre-upgrade snapshot saved
+	CheckpointPulled    Checkpoint = "PULLED"    // image pulled
+	CheckpointBackedUp  Checkpoint = "BACKED_UP" // pre-upgrade backup created
+	CheckpointStopped   Checkpoint = "STOPPED"   // container stopped; from here on the upgrade is destructive
+)
+
+// Resumable reports whether an upgrade interrupted after c can be run again
+// from the start of its pull: nothing destructive has happened yet.
+func (c Checkpoint) Resumable() bool {
+	switch c {
+	case CheckpointPreflight, CheckpointPulled, CheckpointBackedUp:
+		return true
+	}
+	return false
+}
+
+// MessageJobCreated is the message of a job that has been saved in READY
+// state but has not started executing yet; a READY job with any other
+// message has finished.
+const MessageJobCreated = "Upgrade job created"
+
+// AllStates returns every job state in lifecycle order.
+func AllStates() []JobState {
+	return []JobState{
+		JobStateIdle,
+		JobStatePolicyFetching,
+		JobStateManifestFetching,
+		JobStateReady,
+		JobStateBackingUp,
+		JobStateExecuting,
+		JobStateVerifying,
+		JobStateFailed,
+		JobStateCancelled,
+	}
+}
+
 // Job represents an update job with its current state.
 type Job struct {
-	JobID           string    `json:"jobId"`
-	Mode            JobMode   `json:"mode"`
-	RequestedTarget string    `json:"requestedTarget"`
-	ResolvedTarget  string    `json:"resolvedTarget"`
-	State           JobState  `json:"state"`
-	FailureCode     string    `json:"failureCode"`
-	Message         string    `json:"message"`
-	BackupPath      string    `json:"backupPath,omitempty"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	JobID             string     `json:"jobId"`
+	Mode              JobMode    `json:"mode"`
+	RequestedTarget   string     `json:"requestedTarget"`
+	ResolvedTarget    string     `json:"resolvedTarget"`
+	State             JobState   `json:"state"`
+	FailureCode       string     `json:"failureCode"`
+	Message           string     `json:"message"`
+	BackupPath        string     `json:"backupPath,omitempty"`
+	Warnings          []string   `json:"warnings,omitempty"`          // non-fatal issues, e.g. a failed image prune after a successful upgrade
+	RunCommand        string     `json:"runCommand,omitempty"`        // docker run command of the new container, env values redacted
+	ImageRepoOverride string     `json:"imageRepoOverride,omitempty"` // image repo used instead of the manifest's, set with --image-repo
+	Checkpoint        Checkpoint `json:"checkpoint,omitempty"`        // last completed upgrade phase
+	Step              string     `json:"step,omitempty"`              // upgrade step running or last run, one of the plan's steps
+	ContainerLogPath  string     `json:"containerLogPath,omitempty"`  // container logs captured when the job failed
+	SkipVerify        bool       `json:"skipVerify,omitempty"`        // manual mode only: post-upgrade verification is skipped, set with --skip-verify
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
 }
 
 // NewJob creates a new job with the given mode and requested target.
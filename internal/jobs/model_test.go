@@ -122,3 +122,21 @@ func TestJobFailedState(t *testing.T) {
 		t.Errorf("expected Message %q, got %q", "Failed to fetch policy", job.Message)
 	}
 }
+
+func TestCheckpointResumable(t *testing.T) {
+	tests := []struct {
+		checkpoint Checkpoint
+		want       bool
+	}{
+		{"", false},
+		{CheckpointPreflight, true},
+		{CheckpointPulled, true},
+		{CheckpointBackedUp, true},
+		{CheckpointStopped, false},
+	}
+	for _, tt := range tests {
+		if got := tt.checkpoint.Resumable(); got != tt.want {
+			t.Errorf("%q: expected Resumable()=%v, got %v", tt.checkpoint, tt.want, got)
+		}
+	}
+}
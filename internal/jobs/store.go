@@ -1,21 +1,54 @@
 package jobs
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Default log rotation limits used by NewStore.
+const (
+	DefaultLogMaxBytes = 10 * 1024 * 1024
+	DefaultLogMaxFiles = 3
+
+	DefaultContainerLogMaxBytes = 1024 * 1024
+	DefaultContainerLogMaxFiles = 10
 )
 
 // Store handles persistence of jobs and logs.
 type Store struct {
 	stateDir string
+
+	// LogMaxBytes is the size at which AppendLog rotates the log file to
+	// logs.txt.1, shifting older files up to logs.txt.<LogMaxFiles>, beyond
+	// which they are deleted. Zero disables rotation.
+	LogMaxBytes int64
+	LogMaxFiles int
+
+	// ContainerLogMaxBytes caps each file written by SaveContainerLog, which
+	// keeps the newest ContainerLogMaxFiles of them.
+	ContainerLogMaxBytes int64
+	ContainerLogMaxFiles int
+
+	subsMu sync.Mutex
+	subs   map[*LogSubscription]struct{} // see SubscribeLogs
 }
 
 // NewStore creates a new Store with the given state directory.
 func NewStore(stateDir string) *Store {
 	return &Store{
-		stateDir: stateDir,
+		stateDir:             stateDir,
+		LogMaxBytes:          DefaultLogMaxBytes,
+		LogMaxFiles:          DefaultLogMaxFiles,
+		ContainerLogMaxBytes: DefaultContainerLogMaxBytes,
+		ContainerLogMaxFiles: DefaultContainerLogMaxFiles,
 	}
 }
 
@@ -58,13 +91,38 @@ func (s *Store) Save(job *Job) error {
 	return nil
 }
 
-// AppendLog appends a log line to the job's log file.
+// Reconcile records a READY job for version so the tracked state matches a
+// container that was changed outside the upgrade flow, for example by an
+// external upgrade picked up by sync or by a full-recovery restore. The job
+// ID is prefixed with source.
+func (s *Store) Reconcile(source, version, message string) (*Job, error) {
+	job := NewJob(fmt.Sprintf("%s-%d", source, time.Now().UnixNano()), JobModeManual, version)
+	job.ResolvedTarget = version
+	job.State = JobStateReady
+	job.Message = message
+	if err := s.Save(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// AppendLog appends a log line to the job's log file, first rotating the
+// file if the line would take it past LogMaxBytes.
 func (s *Store) AppendLog(line string) error {
 	if err := s.ensureJobDir(); err != nil {
 		return err
 	}
 
+	unlock, err := s.lockLogs()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	logsPath := s.logsPath()
+	if err := s.rotateLogs(int64(len(line) + 1)); err != nil {
+		return err
+	}
 	f, err := os.OpenFile(logsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %w", err)
@@ -75,11 +133,12 @@ func (s *Store) AppendLog(line string) error {
 		return fmt.Errorf("failed to write log: %w", err)
 	}
 
+	s.publishLog(line)
 	return nil
 }
 
-// ReadLogs reads all logs from the job's log file.
-// Returns empty string if no logs exist.
+// ReadLogs reads all logs from the job's current log file; rotated files
+// are not included. Returns empty string if no logs exist.
 func (s *Store) ReadLogs() (string, error) {
 	logsPath := s.logsPath()
 	data, err := os.ReadFile(logsPath)
@@ -93,6 +152,79 @@ func (s *Store) ReadLogs() (string, error) {
 	return string(data), nil
 }
 
+// SaveContainerLog writes the container logs captured when job jobID failed
+// to jobs/container-logs/<jobID>.log and returns its path. Only the last
+// ContainerLogMaxBytes are kept, starting at a line, and the oldest files
+// beyond ContainerLogMaxFiles are removed.
+func (s *Store) SaveContainerLog(jobID string, logs []byte) (string, error) {
+	if jobID == "" || filepath.Base(jobID) != jobID {
+		return "", fmt.Errorf("invalid job ID %q", jobID)
+	}
+	dir := s.containerLogsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create container log directory: %w", err)
+	}
+
+	if max := s.ContainerLogMaxBytes; max > 0 && int64(len(logs)) > max {
+		logs = logs[int64(len(logs))-max:]
+		if i := bytes.IndexByte(logs, '\n'); i >= 0 && i < len(logs)-1 {
+			logs = logs[i+1:]
+		}
+		logs = append([]byte("[earlier output truncated]\n"), logs...)
+	}
+
+	path := filepath.Join(dir, jobID+".log")
+	if err := os.WriteFile(path, logs, 0644); err != nil {
+		return "", fmt.Errorf("failed to write container log: %w", err)
+	}
+	if err := s.pruneContainerLogs(); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// pruneContainerLogs removes the oldest container log files beyond
+// ContainerLogMaxFiles.
+func (s *Store) pruneContainerLogs() error {
+	if s.ContainerLogMaxFiles <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(s.containerLogsDir())
+	if err != nil {
+		return fmt.Errorf("failed to list container logs: %w", err)
+	}
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".log" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{filepath.Join(s.containerLogsDir(), entry.Name()), info.ModTime()})
+	}
+	if len(files) <= s.ContainerLogMaxFiles {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, file := range files[s.ContainerLogMaxFiles:] {
+		if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old container log: %w", err)
+		}
+	}
+	return nil
+}
+
+// containerLogsDir returns the directory holding per-job container logs.
+func (s *Store) containerLogsDir() string {
+	return filepath.Join(s.stateDir, "jobs", "container-logs")
+}
+
 // statusPath returns the path to the status.json file.
 func (s *Store) statusPath() string {
 	return filepath.Join(s.stateDir, "jobs", "latest", "status.json")
@@ -103,6 +235,64 @@ func (s *Store) logsPath() string {
 	return filepath.Join(s.stateDir, "jobs", "latest", "logs.txt")
 }
 
+// lockLogs takes an exclusive lock serializing appends and rotation across
+// goroutines and processes (the daemon and CLI commands share the log). The
+// lock is on a separate file because rotation renames logs.txt, and a lock
+// on a renamed file no longer excludes writers opening the new one.
+func (s *Store) lockLogs() (func(), error) {
+	f, err := os.OpenFile(s.logsPath()+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock log file: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// rotateLogs rotates the log file if appending incoming bytes would take it
+// past LogMaxBytes. The caller must hold the log lock.
+func (s *Store) rotateLogs(incoming int64) error {
+	if s.LogMaxBytes <= 0 {
+		return nil
+	}
+	logsPath := s.logsPath()
+	info, err := os.Stat(logsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	if info.Size() == 0 || info.Size()+incoming <= s.LogMaxBytes {
+		return nil
+	}
+
+	if s.LogMaxFiles <= 0 {
+		if err := os.Remove(logsPath); err != nil {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+		return nil
+	}
+	rotated := func(n int) string { return logsPath + "." + strconv.Itoa(n) }
+	if err := os.Remove(rotated(s.LogMaxFiles)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	for n := s.LogMaxFiles - 1; n >= 1; n-- {
+		if err := os.Rename(rotated(n), rotated(n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(logsPath, rotated(1)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return nil
+}
+
 // ensureJobDir creates the job directory if it doesn't exist.
 func (s *Store) ensureJobDir() error {
 	jobDir := filepath.Join(s.stateDir, "jobs", "latest")
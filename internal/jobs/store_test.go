@@ -2,10 +2,13 @@ package jobs
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewStore(t *testing.T) {
@@ -118,6 +121,174 @@ func TestStore_AppendLog(t *testing.T) {
 	}
 }
 
+func TestStore_AppendLog_RotatesPastSizeCap(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir)
+	store.LogMaxBytes = 80
+	store.LogMaxFiles = 2
+
+	// Each line is 20 bytes with its newline, so each file holds four lines.
+	for i := 0; i < 16; i++ {
+		if err := store.AppendLog(fmt.Sprintf("log line number %03d", i)); err != nil {
+			t.Fatalf("failed to append log: %v", err)
+		}
+	}
+
+	logsPath := filepath.Join(tmpDir, "jobs", "latest", "logs.txt")
+	for _, path := range []string{logsPath, logsPath + ".1", logsPath + ".2"} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", filepath.Base(path), err)
+		}
+		if info.Size() > store.LogMaxBytes {
+			t.Errorf("expected %s to be at most %d bytes, got %d", filepath.Base(path), store.LogMaxBytes, info.Size())
+		}
+	}
+	if _, err := os.Stat(logsPath + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only %d rotated files, found logs.txt.3", store.LogMaxFiles)
+	}
+
+	logs, err := store.ReadLogs()
+	if err != nil {
+		t.Fatalf("failed to read logs: %v", err)
+	}
+	want := "log line number 012\nlog line number 013\nlog line number 014\nlog line number 015\n"
+	if logs != want {
+		t.Errorf("expected the current log to hold the newest lines, got %q", logs)
+	}
+	rotated, _ := os.ReadFile(logsPath + ".1")
+	if !strings.HasPrefix(string(rotated), "log line number 008\n") {
+		t.Errorf("expected logs.txt.1 to hold the previous lines, got %q", rotated)
+	}
+}
+
+func TestStore_AppendLog_RotationDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir)
+	store.LogMaxBytes = 0
+
+	for i := 0; i < 10; i++ {
+		if err := store.AppendLog("a line that would exceed any small cap"); err != nil {
+			t.Fatalf("failed to append log: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "jobs", "latest", "logs.txt.1")); !os.IsNotExist(err) {
+		t.Error("expected no rotation when LogMaxBytes is 0")
+	}
+}
+
+func TestStore_AppendLog_ConcurrentRotationKeepsEveryLine(t *testing.T) {
+	tmpDir := t.TempDir()
+	const writers, perWriter = 8, 50
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// A store per writer, as separate processes would have
+			store := NewStore(tmpDir)
+			store.LogMaxBytes = 256
+			store.LogMaxFiles = writers * perWriter
+			for i := 0; i < perWriter; i++ {
+				if err := store.AppendLog(fmt.Sprintf("writer %d line %d", w, i)); err != nil {
+					t.Errorf("failed to append log: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	paths, err := filepath.Glob(filepath.Join(tmpDir, "jobs", "latest", "logs.txt*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".lock") {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if seen[line] {
+				t.Errorf("line %q written twice", line)
+			}
+			seen[line] = true
+		}
+	}
+	if len(seen) != writers*perWriter {
+		t.Errorf("expected %d distinct lines across the log files, got %d", writers*perWriter, len(seen))
+	}
+}
+
+func TestStore_SaveContainerLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir)
+
+	path, err := store.SaveContainerLog("job-1", []byte("starting\npanic: migration 42 failed\n"))
+	if err != nil {
+		t.Fatalf("SaveContainerLog failed: %v", err)
+	}
+	if path != filepath.Join(tmpDir, "jobs", "container-logs", "job-1.log") {
+		t.Errorf("unexpected path %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "starting\npanic: migration 42 failed\n" {
+		t.Errorf("unexpected container log %q (%v)", data, err)
+	}
+
+	if _, err := store.SaveContainerLog("../escape", []byte("x")); err == nil {
+		t.Error("expected a job ID with a path separator to be refused")
+	}
+}
+
+func TestStore_SaveContainerLog_TruncatesToLastLines(t *testing.T) {
+	store := NewStore(t.TempDir())
+	store.ContainerLogMaxBytes = 30
+
+	path, err := store.SaveContainerLog("job-1", []byte("line one is old\nline two\nline three\n"))
+	if err != nil {
+		t.Fatalf("SaveContainerLog failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "[earlier output truncated]\nline two\nline three\n" {
+		t.Errorf("expected the last whole lines to be kept, got %q", data)
+	}
+}
+
+func TestStore_SaveContainerLog_KeepsNewestFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir)
+	store.ContainerLogMaxFiles = 2
+
+	base := time.Now().Add(-time.Hour)
+	for i, jobID := range []string{"job-1", "job-2", "job-3"} {
+		path, err := store.SaveContainerLog(jobID, []byte(jobID))
+		if err != nil {
+			t.Fatalf("SaveContainerLog failed: %v", err)
+		}
+		// Distinct mtimes, oldest first
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, mtime, mtime)
+	}
+	if _, err := store.SaveContainerLog("job-4", []byte("job-4")); err != nil {
+		t.Fatalf("SaveContainerLog failed: %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "jobs", "container-logs"))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "job-3.log,job-4.log" {
+		t.Errorf("expected the two newest container logs kept, got %v", names)
+	}
+}
+
 func TestStore_ReadLogs_NoFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	store := NewStore(tmpDir)
@@ -214,3 +385,33 @@ func TestStore_JSONFormatting(t *testing.T) {
 		t.Error("expected formatted JSON with indentation")
 	}
 }
+
+func TestStore_ReconcileReplacesFailedJob(t *testing.T) {
+	store := NewStore(t.TempDir())
+	failed := NewJob("job-1", JobModeDashboard, "1.2.0")
+	failed.ResolvedTarget = "1.2.0"
+	failed.State = JobStateFailed
+	failed.FailureCode = "MIGRATION_FAILED"
+	if err := store.Save(failed); err != nil {
+		t.Fatalf("failed to save job: %v", err)
+	}
+
+	job, err := store.Reconcile("restore", "1.1.0", "Reconciled after full-recovery restore (was 1.2.0, now 1.1.0)")
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	latest, err := store.LoadLatest()
+	if err != nil {
+		t.Fatalf("failed to load job: %v", err)
+	}
+	if latest.JobID != job.JobID || !strings.HasPrefix(latest.JobID, "restore-") {
+		t.Errorf("expected the reconciled job to be latest with a restore- ID, got %q", latest.JobID)
+	}
+	if latest.State != JobStateReady || latest.FailureCode != "" {
+		t.Errorf("expected READY with no failure code, got %s/%s", latest.State, latest.FailureCode)
+	}
+	if latest.ResolvedTarget != "1.1.0" || latest.RequestedTarget != "1.1.0" {
+		t.Errorf("expected the restored version 1.1.0, got requested=%s resolved=%s", latest.RequestedTarget, latest.ResolvedTarget)
+	}
+}
@@ -50,6 +50,13 @@ func StdLogger() *log.Logger {
 	return log.New(base.WriterLevel(logrus.InfoLevel), "", 0)
 }
 
+// StdWarnLogger returns a stdlib logger that writes warnings into the shared
+// logrus logger.
+func StdWarnLogger() *log.Logger {
+	ensure()
+	return log.New(base.WriterLevel(logrus.WarnLevel), "", 0)
+}
+
 // Infof logs an informational message with class/method context.
 func Infof(className, methodName, format string, args ...interface{}) {
 	ensure()
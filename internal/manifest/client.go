@@ -10,6 +10,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/payram/payram-updater/internal/httpclient"
 )
 
 const maxResponseSize = 1 * 1024 * 1024 // 1MB
@@ -36,10 +38,19 @@ type Volume struct {
 
 // Defaults represents default container configuration.
 type Defaults struct {
-	ContainerName string   `json:"container_name"`
-	RestartPolicy string   `json:"restart_policy"`
-	Ports         []Port   `json:"ports"`
-	Volumes       []Volume `json:"volumes"`
+	ContainerName string     `json:"container_name"`
+	RestartPolicy string     `json:"restart_policy"`
+	Ports         []Port     `json:"ports"`
+	Volumes       []Volume   `json:"volumes"`
+	ExtraRunArgs  []string   `json:"extra_run_args,omitempty"` // Extra docker run flags, validated against an allowlist
+	Env           EnvChanges `json:"env,omitempty"`
+}
+
+// EnvChanges declares environment changes applied on top of the container's
+// preserved runtime env, for migrating settings across versions.
+type EnvChanges struct {
+	Additions map[string]string `json:"additions,omitempty"` // Set, replacing any runtime value
+	Removals  []string          `json:"removals,omitempty"`  // Dropped from the runtime env
 }
 
 // Override represents version-specific configuration overrides.
@@ -49,6 +60,9 @@ type Override struct {
 	RestartPolicy string   `json:"restart_policy,omitempty"`
 	Ports         []Port   `json:"ports,omitempty"`
 	Volumes       []Volume `json:"volumes,omitempty"`
+	// HotSwap marks the upgrade to Version as changing no database schema,
+	// allowing the hot-swap container replacement when HOT_SWAP_UPGRADES is on.
+	HotSwap bool `json:"hot_swap,omitempty"`
 }
 
 // Image represents container image information.
@@ -61,6 +75,9 @@ type Manifest struct {
 	Image     Image      `json:"image"`
 	Defaults  Defaults   `json:"defaults"`
 	Overrides []Override `json:"overrides,omitempty"`
+	// MinDockerVersion is the oldest Docker daemon the image and its run
+	// flags work with, e.g. "20.10.0". Empty accepts any version.
+	MinDockerVersion string `json:"min_docker_version,omitempty"`
 }
 
 // Client is an HTTP client for fetching manifest data.
@@ -72,10 +89,8 @@ type Client struct {
 // NewClient creates a new manifest client with the specified timeout.
 func NewClient(timeout time.Duration) *Client {
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
-		timeout: timeout,
+		httpClient: httpclient.New(timeout),
+		timeout:    timeout,
 	}
 }
 
@@ -4,26 +4,34 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
+// denialLogInterval is how often denied requests from one source IP are
+// logged; denials in between are counted and reported with the next line.
+const denialLogInterval = time.Minute
+
 // AllowedIPsMiddleware creates middleware that restricts access to specific IP addresses.
 // This ensures only localhost and the Payram container can access the updater API.
+// Entries may be exact IPs ("172.17.0.2") or CIDR ranges ("172.18.0.0/16");
+// CIDR ranges are useful when the dashboard container's IP is not stable
+// within a user-defined Docker network. Invalid entries are logged and ignored.
+//
+// Denied requests are logged with their source IP and path, at most once per
+// source IP per minute, so a probe or a misconfigured health check cannot
+// flood the log.
 func AllowedIPsMiddleware(allowedIPs []string, logger *log.Logger) func(http.Handler) http.Handler {
+	exactIPs, networks := parseAllowList(allowedIPs, logger)
+	denials := newDenialLog(logger, denialLogInterval)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			clientIP := getClientIP(r)
 
-			// Check if client IP is in the allowed list
-			allowed := false
-			for _, allowedIP := range allowedIPs {
-				if clientIP == allowedIP {
-					allowed = true
-					break
-				}
-			}
-
-			if !allowed {
-				logger.Printf("ACCESS DENIED: Request from unauthorized IP %s to %s %s", clientIP, r.Method, r.URL.Path)
+			if !isAllowed(clientIP, exactIPs, networks) {
+				denials.record(clientIP, r.Method, r.URL.Path)
 				http.Error(w, "Access forbidden: unauthorized source IP", http.StatusForbidden)
 				return
 			}
@@ -34,6 +42,114 @@ func AllowedIPsMiddleware(allowedIPs []string, logger *log.Logger) func(http.Han
 	}
 }
 
+// denialLog rate-limits the logging of denied requests per source IP.
+type denialLog struct {
+	logger   *log.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	sources map[string]*denialSource
+}
+
+type denialSource struct {
+	loggedAt   time.Time
+	suppressed int
+}
+
+// maxDenialSources bounds how many source IPs denialLog remembers; beyond
+// it, sources not logged within the interval are forgotten.
+const maxDenialSources = 1024
+
+func newDenialLog(logger *log.Logger, interval time.Duration) *denialLog {
+	return &denialLog{logger: logger, interval: interval, now: time.Now, sources: make(map[string]*denialSource)}
+}
+
+// record logs a denied request unless one from clientIP was logged less than
+// interval ago, in which case it is only counted.
+func (d *denialLog) record(clientIP, method, path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	source, ok := d.sources[clientIP]
+	if ok && now.Sub(source.loggedAt) < d.interval {
+		source.suppressed++
+		return
+	}
+	if !ok {
+		d.forgetExpired(now)
+		source = &denialSource{}
+		d.sources[clientIP] = source
+	}
+
+	if source.suppressed > 0 {
+		d.logger.Printf("ACCESS DENIED: Request from unauthorized IP %s to %s %s (%d more denied since the last message)", clientIP, method, path, source.suppressed)
+	} else {
+		d.logger.Printf("ACCESS DENIED: Request from unauthorized IP %s to %s %s", clientIP, method, path)
+	}
+	source.loggedAt = now
+	source.suppressed = 0
+}
+
+// forgetExpired drops sources outside their interval once maxDenialSources
+// is reached. Their suppressed count is lost.
+func (d *denialLog) forgetExpired(now time.Time) {
+	if len(d.sources) < maxDenialSources {
+		return
+	}
+	for ip, source := range d.sources {
+		if now.Sub(source.loggedAt) >= d.interval {
+			delete(d.sources, ip)
+		}
+	}
+}
+
+// parseAllowList splits allow-list entries into exact IPs and CIDR networks.
+func parseAllowList(entries []string, logger *log.Logger) ([]string, []*net.IPNet) {
+	var exactIPs []string
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				logger.Printf("Ignoring invalid allowed CIDR %q: %v", entry, err)
+				continue
+			}
+			networks = append(networks, ipNet)
+			continue
+		}
+		exactIPs = append(exactIPs, entry)
+	}
+	return exactIPs, networks
+}
+
+// isAllowed reports whether clientIP matches an exact IP or falls inside one of the networks.
+func isAllowed(clientIP string, exactIPs []string, networks []*net.IPNet) bool {
+	for _, allowedIP := range exactIPs {
+		if clientIP == allowedIP {
+			return true
+		}
+	}
+	if len(networks) == 0 {
+		return false
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range networks {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // getClientIP extracts the client's IP address from the request.
 // Only RemoteAddr is trusted to avoid spoofed proxy headers.
 func getClientIP(r *http.Request) string {
@@ -1,10 +1,13 @@
 package network
 
 import (
+	"bytes"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAllowedIPsMiddleware_AllowedIP(t *testing.T) {
@@ -119,3 +122,127 @@ func TestGetClientIP(t *testing.T) {
 		})
 	}
 }
+
+func TestAllowedIPsMiddleware_CIDR(t *testing.T) {
+	allowedIPs := []string{"127.0.0.1", "172.18.0.0/16"}
+	logger := log.Default()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	middleware := AllowedIPsMiddleware(allowedIPs, logger)(handler)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		expected   int
+	}{
+		{name: "inside CIDR", remoteAddr: "172.18.5.23:40000", expected: http.StatusOK},
+		{name: "CIDR network address", remoteAddr: "172.18.0.0:40000", expected: http.StatusOK},
+		{name: "outside CIDR", remoteAddr: "172.19.0.2:40000", expected: http.StatusForbidden},
+		{name: "exact IP still allowed", remoteAddr: "127.0.0.1:40000", expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.RemoteAddr = tt.remoteAddr
+
+			w := httptest.NewRecorder()
+			middleware.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestAllowedIPsMiddleware_IPv6CIDR(t *testing.T) {
+	middleware := AllowedIPsMiddleware([]string{"fd00:1::/64"}, log.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "[fd00:1::42]:12345"
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for IP inside IPv6 CIDR, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "[fd00:2::42]:12345"
+	w = httptest.NewRecorder()
+	middleware.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for IP outside IPv6 CIDR, got %d", w.Code)
+	}
+}
+
+func TestAllowedIPsMiddleware_InvalidCIDRIgnored(t *testing.T) {
+	middleware := AllowedIPsMiddleware([]string{"127.0.0.1", "not-a-cidr/99"}, log.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "127.0.0.1:12345"
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for exact IP alongside invalid CIDR, got %d", w.Code)
+	}
+}
+
+func TestAllowedIPsMiddleware_DenialLoggedOncePerIP(t *testing.T) {
+	var buf bytes.Buffer
+	middleware := AllowedIPsMiddleware([]string{"127.0.0.1"}, log.New(&buf, "", 0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, remote := range []string{"172.17.0.9:40000", "172.17.0.9:40001", "172.17.0.9:40002", "127.0.0.1:40003"} {
+		req := httptest.NewRequest(http.MethodGet, "/upgrade/status", nil)
+		req.RemoteAddr = remote
+		middleware.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	logs := strings.TrimSpace(buf.String())
+	if strings.Count(logs, "\n") != 0 {
+		t.Fatalf("expected exactly one denial line, got:\n%s", logs)
+	}
+	if !strings.Contains(logs, "172.17.0.9") || !strings.Contains(logs, "GET /upgrade/status") {
+		t.Errorf("expected the source IP and path to be logged, got %q", logs)
+	}
+}
+
+func TestDenialLog_ReportsSuppressedCount(t *testing.T) {
+	var buf bytes.Buffer
+	denials := newDenialLog(log.New(&buf, "", 0), time.Minute)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	denials.now = func() time.Time { return now }
+
+	denials.record("10.0.0.5", "GET", "/health")
+	denials.record("10.0.0.6", "GET", "/health")
+	for i := 0; i < 3; i++ {
+		now = now.Add(10 * time.Second)
+		denials.record("10.0.0.5", "GET", "/health")
+	}
+	now = now.Add(time.Minute)
+	denials.record("10.0.0.5", "POST", "/upgrade/run")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"ACCESS DENIED: Request from unauthorized IP 10.0.0.5 to GET /health",
+		"ACCESS DENIED: Request from unauthorized IP 10.0.0.6 to GET /health",
+		"ACCESS DENIED: Request from unauthorized IP 10.0.0.5 to POST /upgrade/run (3 more denied since the last message)",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got:\n%s", len(want), buf.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], lines[i])
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package orchestrator serializes the daemon's mutating operations
+// (upgrades, restarts, auto-updates, scheduled backups) behind one fair lock:
+// operations that wait get the lock in the order they asked for it, and a
+// background operation that only tries its luck (TryAcquire) never overtakes
+// one that waits.
+package orchestrator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Kind names a type of operation.
+type Kind string
+
+const (
+	KindUpgrade         Kind = "upgrade"
+	KindRestart         Kind = "restart"
+	KindAutoUpdate      Kind = "auto_update"
+	KindScheduledBackup Kind = "scheduled_backup"
+)
+
+// Operation is a holder of, or a waiter for, the queue.
+type Operation struct {
+	Kind  Kind      `json:"kind"`
+	ID    string    `json:"id,omitempty"` // e.g. the job ID
+	Since time.Time `json:"since"`        // when it took the lock, or started waiting
+}
+
+// Status is a snapshot of the queue.
+type Status struct {
+	Holder  *Operation  `json:"holder,omitempty"`
+	Waiting []Operation `json:"waiting"` // in the order they will get the lock
+}
+
+// Queue is a FIFO mutex over operations. A nil *Queue is a disabled queue:
+// every operation gets the lock at once and Status is empty.
+type Queue struct {
+	mu      sync.Mutex
+	holder  *Operation
+	waiting []*waiter
+	now     func() time.Time
+}
+
+type waiter struct {
+	op    Operation
+	ready chan struct{} // closed once the lock was handed to op
+}
+
+// New returns an empty queue.
+func New() *Queue {
+	return &Queue{now: time.Now}
+}
+
+// Acquire waits until op holds the lock, behind every operation already
+// waiting, and returns the function releasing it. If ctx ends first op
+// leaves the queue and ctx's error is returned.
+func (q *Queue) Acquire(ctx context.Context, op Operation) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+
+	q.mu.Lock()
+	op.Since = q.now().UTC()
+	if q.holder == nil && len(q.waiting) == 0 {
+		q.holder = &op
+		q.mu.Unlock()
+		return q.releaser(), nil
+	}
+	w := &waiter{op: op, ready: make(chan struct{})}
+	q.waiting = append(q.waiting, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return q.releaser(), nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	for i, other := range q.waiting {
+		if other == w {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	q.mu.Unlock()
+	// The lock was handed over while ctx ended: pass it on
+	q.releaser()()
+	return nil, ctx.Err()
+}
+
+// TryAcquire takes the lock for op only if nothing holds it and nothing
+// waits for it, so it never overtakes a waiting operation. ok is false when
+// the lock was not taken.
+func (q *Queue) TryAcquire(op Operation) (release func(), ok bool) {
+	if q == nil {
+		return func() {}, true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.holder != nil || len(q.waiting) > 0 {
+		return nil, false
+	}
+	op.Since = q.now().UTC()
+	q.holder = &op
+	return q.releaser(), true
+}
+
+// releaser returns the function releasing the lock, which hands it to the
+// first waiting operation. Calls after the first do nothing.
+func (q *Queue) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			if len(q.waiting) == 0 {
+				q.holder = nil
+				return
+			}
+			next := q.waiting[0]
+			q.waiting = q.waiting[1:]
+			next.op.Since = q.now().UTC()
+			q.holder = &next.op
+			close(next.ready)
+		})
+	}
+}
+
+// Status returns the current holder and the waiting operations.
+func (q *Queue) Status() Status {
+	status := Status{Waiting: []Operation{}}
+	if q == nil {
+		return status
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.holder != nil {
+		holder := *q.holder
+		status.Holder = &holder
+	}
+	for _, w := range q.waiting {
+		status.Waiting = append(status.Waiting, w.op)
+	}
+	return status
+}
+
+// Pending returns the first operation of one of kinds that holds or waits
+// for the lock, and false if there is none.
+func (q *Queue) Pending(kinds ...Kind) (*Operation, bool) {
+	status := q.Status()
+	ops := status.Waiting
+	if status.Holder != nil {
+		ops = append([]Operation{*status.Holder}, ops...)
+	}
+	for _, op := range ops {
+		for _, kind := range kinds {
+			if op.Kind == kind {
+				return &op, true
+			}
+		}
+	}
+	return nil, false
+}
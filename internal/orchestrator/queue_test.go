@@ -0,0 +1,163 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until n operations wait in q.
+func waitForWaiters(t *testing.T, q *Queue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(q.Status().Waiting) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiting operations, got %+v", n, q.Status())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueue_FIFOOrder(t *testing.T) {
+	q := New()
+	release, err := q.Acquire(context.Background(), Operation{Kind: KindScheduledBackup})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for i, op := range []Operation{{Kind: KindUpgrade, ID: "manual"}, {Kind: KindAutoUpdate, ID: "auto"}, {Kind: KindUpgrade, ID: "second"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := q.Acquire(context.Background(), op)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, op.ID)
+			mu.Unlock()
+			release()
+		}()
+		// Queue the waiters in a known order
+		waitForWaiters(t, q, i+1)
+	}
+
+	status := q.Status()
+	if status.Holder == nil || status.Holder.Kind != KindScheduledBackup || len(status.Waiting) != 3 || status.Waiting[0].ID != "manual" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	release()
+	wg.Wait()
+
+	if len(order) != 3 || order[0] != "manual" || order[1] != "auto" || order[2] != "second" {
+		t.Errorf("expected FIFO order manual, auto, second, got %v", order)
+	}
+	if status := q.Status(); status.Holder != nil || len(status.Waiting) != 0 {
+		t.Errorf("expected an empty queue, got %+v", status)
+	}
+}
+
+func TestQueue_MutualExclusionAcrossKinds(t *testing.T) {
+	q := New()
+	kinds := []Kind{KindUpgrade, KindAutoUpdate, KindScheduledBackup}
+	var inside, maxInside atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := q.Acquire(context.Background(), Operation{Kind: kinds[i%len(kinds)]})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if n := inside.Add(1); n > maxInside.Load() {
+				maxInside.Store(n)
+			}
+			time.Sleep(time.Millisecond)
+			inside.Add(-1)
+			release()
+		}()
+	}
+	wg.Wait()
+	if maxInside.Load() != 1 {
+		t.Errorf("expected one operation at a time, saw %d", maxInside.Load())
+	}
+}
+
+func TestQueue_TryAcquireNeverOvertakesAWaiter(t *testing.T) {
+	q := New()
+	release, ok := q.TryAcquire(Operation{Kind: KindScheduledBackup})
+	if !ok {
+		t.Fatal("expected an idle queue to be taken")
+	}
+	if _, ok := q.TryAcquire(Operation{Kind: KindAutoUpdate}); ok {
+		t.Fatal("expected a held queue not to be taken")
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, _ := q.Acquire(context.Background(), Operation{Kind: KindUpgrade, ID: "manual"})
+		acquired <- release
+	}()
+	waitForWaiters(t, q, 1)
+	release()
+
+	manualRelease := <-acquired
+	if _, ok := q.TryAcquire(Operation{Kind: KindAutoUpdate}); ok {
+		t.Fatal("expected the auto-update not to run while the manual upgrade holds the queue")
+	}
+	if op, ok := q.Pending(KindUpgrade); !ok || op.ID != "manual" {
+		t.Errorf("expected the manual upgrade to be pending, got %+v", op)
+	}
+	manualRelease()
+	manualRelease() // a second release is a no-op
+
+	if _, ok := q.TryAcquire(Operation{Kind: KindAutoUpdate}); !ok {
+		t.Error("expected the auto-update to run once the queue is idle")
+	}
+}
+
+func TestQueue_AcquireCancelledLeavesQueue(t *testing.T) {
+	q := New()
+	release, _ := q.TryAcquire(Operation{Kind: KindUpgrade})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := q.Acquire(ctx, Operation{Kind: KindAutoUpdate})
+		done <- err
+	}()
+	waitForWaiters(t, q, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(q.Status().Waiting) != 0 {
+		t.Errorf("expected the cancelled operation to leave the queue, got %+v", q.Status())
+	}
+	release()
+	if q.Status().Holder != nil {
+		t.Errorf("expected the lock to be free, got %+v", q.Status())
+	}
+}
+
+func TestQueue_NilIsDisabled(t *testing.T) {
+	var q *Queue
+	if _, ok := q.TryAcquire(Operation{Kind: KindUpgrade}); !ok {
+		t.Error("expected a disabled queue to always be taken")
+	}
+	if _, err := q.Acquire(context.Background(), Operation{Kind: KindUpgrade}); err != nil {
+		t.Error(err)
+	}
+	if _, ok := q.Pending(KindUpgrade); ok {
+		t.Error("expected nothing pending in a disabled queue")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/payram/payram-updater/internal/httpclient"
 )
 
 const maxResponseSize = 1 * 1024 * 1024 // 1MB
@@ -39,6 +41,14 @@ type StopPoint struct {
 	Docs    string `json:"docs"`
 }
 
+// Deprecation marks a release as deprecated or end-of-life. Hosts running it
+// are nudged to upgrade; nothing is blocked.
+type Deprecation struct {
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+	Docs    string `json:"docs,omitempty"`
+}
+
 // Policy represents the update policy fetched from GitHub.
 type Policy struct {
 	Latest                string            `json:"latest"`
@@ -47,6 +57,7 @@ type Policy struct {
 	Breakpoints           []Breakpoint      `json:"breakpoints"`
 	StopPoints            []StopPoint       `json:"stop_points"`
 	ArchSupport           map[string]string `json:"arch_support,omitempty"` // e.g. {"arm64": "1.9.1"}
+	Deprecated            []Deprecation     `json:"deprecated,omitempty"`
 }
 
 // Client is an HTTP client for fetching policy data.
@@ -58,10 +69,8 @@ type Client struct {
 // NewClient creates a new policy client with the specified timeout.
 func NewClient(timeout time.Duration) *Client {
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
-		timeout: timeout,
+		httpClient: httpclient.New(timeout),
+		timeout:    timeout,
 	}
 }
 
@@ -0,0 +1,135 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Strategies for resolving a "latest" target.
+const (
+	// StrategyLatest resolves to the policy's latest field.
+	StrategyLatest = "latest"
+	// StrategyLatestPatch resolves to the newest release in the running
+	// version's major.minor line, so only patch upgrades are taken.
+	StrategyLatestPatch = "latest-patch"
+	// StrategyLatestDashboard resolves to the newest release reachable
+	// without a manual upgrade: latest, capped below the first stop point
+	// above the running version. Breakpoints are crossed automatically by
+	// the dashboard, so they do not cap it.
+	StrategyLatestDashboard = "latest-dashboard"
+)
+
+// Strategies lists the strategy names accepted by ResolveLatest.
+var Strategies = []string{StrategyLatest, StrategyLatestPatch, StrategyLatestDashboard}
+
+// ErrLatestEmpty is returned when a strategy needs the policy's latest field
+// and it is empty.
+var ErrLatestEmpty = errors.New("policy latest field is empty")
+
+// IsStrategy reports whether name (case-insensitive) is a strategy name.
+func IsStrategy(name string) bool {
+	for _, strategy := range Strategies {
+		if strings.EqualFold(strings.TrimSpace(name), strategy) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveLatest resolves strategy (empty means StrategyLatest) to a release
+// version. currentVersion is the running version; latest-patch and
+// latest-dashboard need it and resolve to it when no newer release qualifies.
+func (p *Policy) ResolveLatest(strategy, currentVersion string) (string, error) {
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	if strategy == "" {
+		strategy = StrategyLatest
+	}
+	latest := strings.TrimSpace(p.Latest)
+
+	switch strategy {
+	case StrategyLatest:
+		if latest == "" {
+			return "", ErrLatestEmpty
+		}
+		return latest, nil
+	case StrategyLatestPatch, StrategyLatestDashboard:
+	default:
+		return "", fmt.Errorf("unknown strategy %q (want one of %s)", strategy, strings.Join(Strategies, ", "))
+	}
+
+	current, err := version.NewVersion(normalize(currentVersion))
+	if err != nil {
+		return "", fmt.Errorf("%s needs the running version, got %q", strategy, currentVersion)
+	}
+
+	if strategy == StrategyLatestPatch {
+		// Releases above latest are not published yet; cap at latest when known
+		var ceiling *version.Version
+		if latest != "" {
+			ceiling, _ = version.NewVersion(normalize(latest))
+		}
+		best, bestVersion := currentVersion, current
+		for _, release := range p.Releases {
+			rv, err := version.NewVersion(normalize(release))
+			if err != nil || rv.Prerelease() != "" || !sameMinor(rv, current) {
+				continue
+			}
+			if ceiling != nil && rv.GreaterThan(ceiling) {
+				continue
+			}
+			if rv.GreaterThan(bestVersion) {
+				best, bestVersion = strings.TrimSpace(release), rv
+			}
+		}
+		return best, nil
+	}
+
+	// latest-dashboard
+	if latest == "" {
+		return "", ErrLatestEmpty
+	}
+	target, err := version.NewVersion(normalize(latest))
+	if err != nil {
+		return "", fmt.Errorf("invalid latest version %q: %w", latest, err)
+	}
+
+	// Find the lowest stop point crossed on the way to latest
+	var stop *version.Version
+	for _, sp := range p.StopPoints {
+		spv, err := version.NewVersion(normalize(sp.Version))
+		if err != nil {
+			continue
+		}
+		if current.LessThan(spv) && target.GreaterThanOrEqual(spv) && (stop == nil || spv.LessThan(stop)) {
+			stop = spv
+		}
+	}
+	if stop == nil {
+		return latest, nil
+	}
+
+	// Stop at the highest release below it
+	best, bestVersion := currentVersion, current
+	for _, release := range p.Releases {
+		rv, err := version.NewVersion(normalize(release))
+		if err != nil || rv.Prerelease() != "" || !rv.LessThan(stop) {
+			continue
+		}
+		if rv.GreaterThan(bestVersion) {
+			best, bestVersion = strings.TrimSpace(release), rv
+		}
+	}
+	return best, nil
+}
+
+func normalize(v string) string {
+	return strings.TrimPrefix(strings.TrimSpace(v), "v")
+}
+
+func sameMinor(a, b *version.Version) bool {
+	as, bs := a.Segments(), b.Segments()
+	return as[0] == bs[0] && as[1] == bs[1]
+}
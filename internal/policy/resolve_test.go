@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+)
+
+func resolveTestPolicy() *Policy {
+	return &Policy{
+		Latest:   "2.1.0",
+		Releases: []string{"1.8.0", "1.8.1", "1.8.2", "1.9.0", "1.9.1", "1.9.2-rc.1", "2.0.0", "2.1.0", "2.1.1"},
+		Breakpoints: []Breakpoint{
+			{Version: "1.9.0", Reason: "schema migration"},
+		},
+		StopPoints: []StopPoint{
+			{Version: "2.0.0", Reason: "manual migration"},
+		},
+	}
+}
+
+func TestResolveLatest(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		current  string
+		want     string
+	}{
+		{"latest ignores the running version", StrategyLatest, "", "2.1.0"},
+		{"latest is case-insensitive", "LATEST", "1.8.0", "2.1.0"},
+		{"latest-patch stays in the minor line", StrategyLatestPatch, "1.8.0", "1.8.2"},
+		{"latest-patch accepts a v prefix", StrategyLatestPatch, "v1.8.1", "1.8.2"},
+		{"latest-patch skips pre-releases", StrategyLatestPatch, "1.9.0", "1.9.1"},
+		{"latest-patch is capped at latest", StrategyLatestPatch, "2.1.0", "2.1.0"},
+		{"latest-patch without a newer patch", StrategyLatestPatch, "1.9.1", "1.9.1"},
+		{"latest-dashboard stops below the stop point", StrategyLatestDashboard, "1.8.0", "1.9.1"},
+		{"latest-dashboard crosses breakpoints", StrategyLatestDashboard, "1.8.2", "1.9.1"},
+		{"latest-dashboard past the stop point", StrategyLatestDashboard, "2.0.0", "2.1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveTestPolicy().ResolveLatest(tt.strategy, tt.current)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveLatest_DashboardAtStoppingStone(t *testing.T) {
+	p := resolveTestPolicy()
+	p.Releases = []string{"1.8.0", "1.9.1", "2.0.0", "2.1.0"}
+
+	// Nothing is reachable without the manual upgrade through 2.0.0
+	got, err := p.ResolveLatest(StrategyLatestDashboard, "1.9.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "1.9.1" {
+		t.Errorf("expected to stay on 1.9.1, got %s", got)
+	}
+}
+
+func TestResolveLatest_Errors(t *testing.T) {
+	if _, err := (&Policy{}).ResolveLatest(StrategyLatest, "1.0.0"); !errors.Is(err, ErrLatestEmpty) {
+		t.Errorf("expected ErrLatestEmpty, got %v", err)
+	}
+	if _, err := (&Policy{}).ResolveLatest(StrategyLatestDashboard, "1.0.0"); !errors.Is(err, ErrLatestEmpty) {
+		t.Errorf("expected ErrLatestEmpty for latest-dashboard, got %v", err)
+	}
+	for _, strategy := range []string{StrategyLatestPatch, StrategyLatestDashboard} {
+		if _, err := resolveTestPolicy().ResolveLatest(strategy, ""); err == nil {
+			t.Errorf("expected %s to need the running version", strategy)
+		}
+	}
+	if _, err := resolveTestPolicy().ResolveLatest("newest", "1.0.0"); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+}
+
+func TestIsStrategy(t *testing.T) {
+	for _, name := range []string{"latest", "Latest-Patch", " latest-dashboard "} {
+		if !IsStrategy(name) {
+			t.Errorf("expected %q to be a strategy", name)
+		}
+	}
+	for _, name := range []string{"", "1.2.3", "latest-minor"} {
+		if IsStrategy(name) {
+			t.Errorf("expected %q not to be a strategy", name)
+		}
+	}
+}
@@ -4,7 +4,10 @@ package recover
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/payram/payram-updater/internal/coreclient"
+	"github.com/payram/payram-updater/internal/corecompat"
 	"github.com/payram/payram-updater/internal/dockerexec"
 	"github.com/payram/payram-updater/internal/jobs"
 	"github.com/payram/payram-updater/internal/recovery"
@@ -12,19 +15,46 @@ import (
 
 // RecoveryResult represents the outcome of a recovery attempt.
 type RecoveryResult struct {
-	Success  bool   `json:"success"`
-	Message  string `json:"message"`
-	Action   string `json:"action"`
-	Code     string `json:"code"`
-	Refusals string `json:"refusals,omitempty"`
+	Success  bool               `json:"success"`
+	Message  string             `json:"message"`
+	Action   string             `json:"action"`
+	Code     string             `json:"code"`
+	Refusals string             `json:"refusals,omitempty"`
+	Attempts []ContainerAttempt `json:"attempts,omitempty"`
+	DryRun   bool               `json:"dryRun,omitempty"` // Action and Message describe what recovery would do
 }
 
+// ContainerAttempt records one attempt to bring the container up and verify
+// its health.
+type ContainerAttempt struct {
+	Attempt int    `json:"attempt"`
+	Action  string `json:"action"` // "started" or "already_running"
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Container retry backoff: the first retry waits DefaultRetryBackoff, and the
+// wait doubles after each failed attempt up to MaxRetryBackoff.
+const (
+	DefaultRetryBackoff = 5 * time.Second
+	MaxRetryBackoff     = 30 * time.Second
+)
+
 // Recoverer performs automated recovery actions.
 type Recoverer struct {
 	jobStore      *jobs.Store
 	dockerRunner  *dockerexec.Runner
 	containerName string
 	coreBaseURL   string
+
+	// Retries is how many more times to bring the container up and verify
+	// its health after the first attempt fails.
+	Retries int
+	// RetryBackoff is the wait before the first retry.
+	RetryBackoff time.Duration
+	// DryRun reports the recovery action that would be taken without
+	// starting, stopping or removing the container.
+	DryRun bool
 }
 
 // NewRecoverer creates a new recoverer.
@@ -39,6 +69,7 @@ func NewRecoverer(
 		dockerRunner:  dockerRunner,
 		containerName: containerName,
 		coreBaseURL:   coreBaseURL,
+		RetryBackoff:  DefaultRetryBackoff,
 	}
 }
 
@@ -85,9 +116,12 @@ func (r *Recoverer) Run(ctx context.Context) (*RecoveryResult, error) {
 
 	failureCode := job.FailureCode
 
-	// Check if recovery is allowed
+	// Check if recovery is allowed. A failed health check needs an operator,
+	// unless retries were asked for: restarting the container and checking
+	// its health again touches no data, and covers a container that was only
+	// slow to become healthy.
 	canRecover, refusal := CanRecover(failureCode)
-	if !canRecover {
+	if !canRecover && !(failureCode == "HEALTHCHECK_FAILED" && r.Retries > 0) {
 		return &RecoveryResult{
 			Success:  false,
 			Message:  "Automated recovery refused",
@@ -96,15 +130,60 @@ func (r *Recoverer) Run(ctx context.Context) (*RecoveryResult, error) {
 		}, nil
 	}
 
+	if r.DryRun {
+		return r.planRecovery(ctx, failureCode, job), nil
+	}
+
 	// Perform recovery action based on failure code
 	result := r.performRecovery(ctx, failureCode, job)
 	return result, nil
 }
 
+// planRecovery describes the recovery action performRecovery would take for
+// failureCode without changing the container. Actions that would change it
+// are prefixed with "would_".
+func (r *Recoverer) planRecovery(ctx context.Context, failureCode string, job *jobs.Job) *RecoveryResult {
+	var result *RecoveryResult
+	switch failureCode {
+	case "DOCKER_PULL_FAILED", "REGISTRY_RATE_LIMITED":
+		action := "would_verify_container"
+		message := fmt.Sprintf("Would verify that container %s is healthy", r.containerName)
+		if running, err := r.dockerRunner.InspectRunning(ctx, r.containerName); err == nil && !running {
+			action = "would_start_container"
+			message = fmt.Sprintf("Would start container %s (currently stopped) and verify its health", r.containerName)
+		}
+		result = &RecoveryResult{
+			Success: true,
+			Message: fmt.Sprintf("%s, making up to %d attempt(s).", message, r.Retries+1),
+			Action:  action,
+			Code:    job.FailureCode,
+		}
+	case "DOCKER_ERROR":
+		result = &RecoveryResult{
+			Success: true,
+			Message: fmt.Sprintf("Would stop and remove container %s.", r.containerName),
+			Action:  "would_stop_and_remove_container",
+			Code:    failureCode,
+		}
+	case "HEALTHCHECK_FAILED":
+		result = &RecoveryResult{
+			Success: true,
+			Message: fmt.Sprintf("Would restart container %s and verify its health, making up to %d attempt(s), and stop it if it never becomes healthy.", r.containerName, r.Retries+1),
+			Action:  "would_restart_container",
+			Code:    failureCode,
+		}
+	default:
+		// The remaining recovery actions do not touch the container
+		result = r.performRecovery(ctx, failureCode, job)
+	}
+	result.DryRun = true
+	return result
+}
+
 // performRecovery executes the recovery action for the given failure code.
 func (r *Recoverer) performRecovery(ctx context.Context, failureCode string, job *jobs.Job) *RecoveryResult {
 	switch failureCode {
-	case "DOCKER_PULL_FAILED":
+	case "DOCKER_PULL_FAILED", "REGISTRY_RATE_LIMITED":
 		return r.recoverDockerPull(ctx, job)
 	case "DOCKER_ERROR":
 		return r.recoverDockerError(ctx)
@@ -114,6 +193,13 @@ func (r *Recoverer) performRecovery(ctx context.Context, failureCode string, job
 		return r.recoverFetchFailed(ctx, failureCode)
 	case "CONCURRENCY_BLOCKED":
 		return r.recoverConcurrencyBlocked(ctx)
+	case "RESTORE_IN_PROGRESS":
+		return &RecoveryResult{
+			Success: true,
+			Message: "Another process held the restore lock and the container was not modified. Retry once its restore or rollback has finished.",
+			Action:  "retry_after_restore",
+			Code:    failureCode,
+		}
 	case "DISK_SPACE_LOW":
 		return &RecoveryResult{
 			Success:  false,
@@ -121,6 +207,13 @@ func (r *Recoverer) performRecovery(ctx context.Context, failureCode string, job
 			Code:     failureCode,
 			Refusals: "Requires manual cleanup of disk space",
 		}
+	case "MISSING_VOLUME":
+		return &RecoveryResult{
+			Success:  false,
+			Message:  "A container volume is missing. Restore the volume before retrying; recreating the container would start with empty data.",
+			Code:     failureCode,
+			Refusals: "Requires manual restoration of the missing volume",
+		}
 	case "BACKUP_FAILED_AFTER_QUIESCE":
 		return &RecoveryResult{
 			Success:  false,
@@ -135,6 +228,13 @@ func (r *Recoverer) performRecovery(ctx context.Context, failureCode string, job
 			Code:     failureCode,
 			Refusals: "No automated recovery action defined",
 		}
+	case "PAUSED_PROGRAMS_NOT_RESUMED":
+		return &RecoveryResult{
+			Success:  false,
+			Message:  "The upgrade passed verification but the supervisor programs paused for it were not restarted. Start them with supervisorctl inside the container.",
+			Code:     failureCode,
+			Refusals: "No automated recovery action defined",
+		}
 	default:
 		return &RecoveryResult{
 			Success:  false,
@@ -146,16 +246,82 @@ func (r *Recoverer) performRecovery(ctx context.Context, failureCode string, job
 }
 
 func (r *Recoverer) recoverDockerPull(ctx context.Context, job *jobs.Job) *RecoveryResult {
-	// Stop the old container if it exists
-	_ = r.dockerRunner.Stop(ctx, r.containerName)
-
-	// Attempt to restart the container with the previous image
+	// The pull failed before the container was touched, so recovery only has
+	// to make sure the previous version is still up and healthy.
+	attempts, ok := r.ensureContainerHealthy(ctx, false)
+	if !ok {
+		return &RecoveryResult{
+			Success:  false,
+			Message:  fmt.Sprintf("Container %s did not become healthy after %d attempt(s). Check the container logs.", r.containerName, len(attempts)),
+			Action:   "container_unhealthy",
+			Code:     job.FailureCode,
+			Attempts: attempts,
+		}
+	}
 	return &RecoveryResult{
-		Success: true,
-		Message: "Docker pull failure recovery attempted. Container stopped. You may retry the upgrade or manually pull the image.",
-		Action:  "stopped_container",
-		Code:    "DOCKER_PULL_FAILED",
+		Success:  true,
+		Message:  "Docker pull failure recovered. The previous version is running and healthy. You may retry the upgrade once the registry is reachable.",
+		Action:   "verified_container",
+		Code:     job.FailureCode,
+		Attempts: attempts,
+	}
+}
+
+// ensureContainerHealthy starts the container if it is not running, or
+// restarts it if restart is set, and verifies its health, retrying with
+// backoff up to r.Retries more times.
+func (r *Recoverer) ensureContainerHealthy(ctx context.Context, restart bool) ([]ContainerAttempt, bool) {
+	var attempts []ContainerAttempt
+	backoff := r.RetryBackoff
+	for attempt := 1; attempt <= r.Retries+1; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return attempts, false
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, MaxRetryBackoff)
+		}
+
+		result := ContainerAttempt{Attempt: attempt, Action: "already_running"}
+		running, err := r.dockerRunner.InspectRunning(ctx, r.containerName)
+		if err == nil && !running {
+			result.Action = "started"
+			err = r.dockerRunner.Start(ctx, r.containerName)
+		} else if err == nil && restart {
+			result.Action = "restarted"
+			err = r.dockerRunner.Restart(ctx, r.containerName)
+		}
+		if err == nil {
+			err = r.checkHealth(ctx)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Healthy = true
+		}
+		attempts = append(attempts, result)
+		if result.Healthy {
+			return attempts, true
+		}
+	}
+	return attempts, false
+}
+
+// checkHealth verifies the Payram Core health endpoint, falling back to the
+// legacy endpoint for versions that predate it.
+func (r *Recoverer) checkHealth(ctx context.Context) error {
+	health, err := coreclient.NewClient(r.coreBaseURL).Health(ctx)
+	if err != nil {
+		if legacyErr := corecompat.LegacyHealth(ctx, r.coreBaseURL); legacyErr == nil {
+			return nil
+		}
+		return err
 	}
+	if health.Status != "ok" || (health.DB != "" && health.DB != "ok") {
+		return fmt.Errorf("health check not OK (status=%s, db=%s)", health.Status, health.DB)
+	}
+	return nil
 }
 
 func (r *Recoverer) recoverDockerError(ctx context.Context) *RecoveryResult {
@@ -171,15 +337,28 @@ func (r *Recoverer) recoverDockerError(ctx context.Context) *RecoveryResult {
 	}
 }
 
+// recoverHealthcheck restarts the container and verifies its health, with
+// r.Retries retries. A container that never becomes healthy is stopped, as
+// the upgraded version is not serving.
 func (r *Recoverer) recoverHealthcheck(ctx context.Context) *RecoveryResult {
-	// Restart the container
-	_ = r.dockerRunner.Stop(ctx, r.containerName)
+	attempts, ok := r.ensureContainerHealthy(ctx, true)
+	if ok {
+		return &RecoveryResult{
+			Success:  true,
+			Message:  fmt.Sprintf("Container %s became healthy after a restart. Check that it runs the expected version before relying on it.", r.containerName),
+			Action:   "restarted_container",
+			Code:     "HEALTHCHECK_FAILED",
+			Attempts: attempts,
+		}
+	}
 
+	_ = r.dockerRunner.Stop(ctx, r.containerName)
 	return &RecoveryResult{
-		Success: true,
-		Message: "Healthcheck failure recovery attempted. Container stopped. Check logs and manually start the container if appropriate.",
-		Action:  "stopped_container",
-		Code:    "HEALTHCHECK_FAILED",
+		Success:  false,
+		Message:  fmt.Sprintf("Container %s did not become healthy after %d attempt(s) and was stopped. Check the logs, then restore from backup and roll back to the previous version.", r.containerName, len(attempts)),
+		Action:   "stopped_container",
+		Code:     "HEALTHCHECK_FAILED",
+		Attempts: attempts,
 	}
 }
 
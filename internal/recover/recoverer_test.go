@@ -3,6 +3,12 @@ package recover
 import (
 	"context"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,6 +27,7 @@ func TestCanRecover_RetryableCodesAllowed(t *testing.T) {
 		"POLICY_FETCH_FAILED",
 		"MANIFEST_FETCH_FAILED",
 		"CONCURRENCY_BLOCKED",
+		"RESTORE_IN_PROGRESS",
 	}
 
 	for _, code := range retryableCodes {
@@ -136,7 +143,8 @@ func TestRecoverer_Run_DockerPullFailed(t *testing.T) {
 
 	// Use a mock runner that doesn't actually call docker
 	runner := &dockerexec.Runner{DockerBin: "echo", Logger: testLogger()}
-	recoverer := NewRecoverer(jobStore, runner, "payram-core", "http://localhost:8080")
+	core := newHealthServer(t, 1)
+	recoverer := NewRecoverer(jobStore, runner, "payram-core", core.URL)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -202,3 +210,245 @@ func TestRecoveryResult_Structure(t *testing.T) {
 		t.Errorf("expected action restart_container, got %s", result.Action)
 	}
 }
+
+// newHealthServer serves /api/v1/health, reporting healthy from the
+// healthyFrom-th request on.
+func newHealthServer(t *testing.T, healthyFrom int32) *httptest.Server {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/health" {
+			http.NotFound(w, r)
+			return
+		}
+		if requests.Add(1) < healthyFrom {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok","db":"ok"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newStoppedContainerRunner returns a runner whose container reports as
+// stopped, recording every docker call in the returned log file.
+func newStoppedContainerRunner(t *testing.T) (*dockerexec.Runner, string) {
+	t.Helper()
+	dir := t.TempDir()
+	callLog := filepath.Join(dir, "docker-calls.log")
+	dockerBin := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callLog + "\n" +
+		"[ \"$1\" = inspect ] && echo false\n" +
+		"exit 0\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &dockerexec.Runner{DockerBin: dockerBin, Logger: testLogger()}, callLog
+}
+
+func saveFailedJob(t *testing.T, jobStore *jobs.Store, failureCode string) {
+	t.Helper()
+	job := jobs.NewJob("test-job", jobs.JobModeDashboard, "v2.0.0")
+	job.State = jobs.JobStateFailed
+	job.FailureCode = failureCode
+	if err := jobStore.Save(job); err != nil {
+		t.Fatalf("failed to save job: %v", err)
+	}
+}
+
+func TestRecoverer_Run_RetriesUntilHealthy(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	saveFailedJob(t, jobStore, "DOCKER_PULL_FAILED")
+	runner, callLog := newStoppedContainerRunner(t)
+	core := newHealthServer(t, 3)
+
+	recoverer := NewRecoverer(jobStore, runner, "payram-core", core.URL)
+	recoverer.Retries = 3
+	recoverer.RetryBackoff = time.Millisecond
+
+	result, err := recoverer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !result.Success || result.Action != "verified_container" {
+		t.Fatalf("expected recovery to succeed once healthy, got %s: %s", result.Action, result.Message)
+	}
+	if len(result.Attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %+v", result.Attempts)
+	}
+	for i, attempt := range result.Attempts[:2] {
+		if attempt.Attempt != i+1 || attempt.Healthy || attempt.Error == "" || attempt.Action != "started" {
+			t.Errorf("expected attempt %d to start the container and fail health, got %+v", i+1, attempt)
+		}
+	}
+	if last := result.Attempts[2]; !last.Healthy || last.Error != "" {
+		t.Errorf("expected the final attempt to be healthy, got %+v", last)
+	}
+	data, _ := os.ReadFile(callLog)
+	if got := strings.Count(string(data), "start payram-core"); got != 3 {
+		t.Errorf("expected the stopped container to be started on every attempt, got %d start(s)", got)
+	}
+}
+
+func TestRecoverer_Run_GivesUpAfterRetries(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	saveFailedJob(t, jobStore, "DOCKER_PULL_FAILED")
+	runner, _ := newStoppedContainerRunner(t)
+	core := newHealthServer(t, 100)
+
+	recoverer := NewRecoverer(jobStore, runner, "payram-core", core.URL)
+	recoverer.Retries = 2
+	recoverer.RetryBackoff = time.Millisecond
+
+	result, err := recoverer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Success {
+		t.Fatal("expected recovery to fail when the container never becomes healthy")
+	}
+	if len(result.Attempts) != 3 {
+		t.Errorf("expected 1 attempt plus 2 retries, got %d", len(result.Attempts))
+	}
+	if !strings.Contains(result.Message, "3 attempt(s)") {
+		t.Errorf("expected the attempt count in the message, got %q", result.Message)
+	}
+}
+
+func TestRecoverer_Run_NoRetriesByDefault(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	saveFailedJob(t, jobStore, "DOCKER_PULL_FAILED")
+	runner, _ := newStoppedContainerRunner(t)
+	core := newHealthServer(t, 2)
+
+	result, err := NewRecoverer(jobStore, runner, "payram-core", core.URL).Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Success || len(result.Attempts) != 1 {
+		t.Errorf("expected a single failed attempt without --retries, got success=%v attempts=%+v", result.Success, result.Attempts)
+	}
+}
+
+func TestRecoverer_Run_HealthcheckRetriesRestartUntilHealthy(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	saveFailedJob(t, jobStore, "HEALTHCHECK_FAILED")
+	dir := t.TempDir()
+	callLog := filepath.Join(dir, "docker-calls.log")
+	dockerBin := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> " + callLog + "\n" +
+		"[ \"$1\" = inspect ] && echo true\n" +
+		"exit 0\n"
+	if err := os.WriteFile(dockerBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runner := &dockerexec.Runner{DockerBin: dockerBin, Logger: testLogger()}
+	core := newHealthServer(t, 2)
+
+	recoverer := NewRecoverer(jobStore, runner, "payram-core", core.URL)
+	recoverer.Retries = 2
+	recoverer.RetryBackoff = time.Millisecond
+
+	result, err := recoverer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success || result.Action != "restarted_container" || len(result.Attempts) != 2 {
+		t.Fatalf("expected the container to be healthy on the second restart, got %s: %s (%+v)", result.Action, result.Message, result.Attempts)
+	}
+	for _, attempt := range result.Attempts {
+		if attempt.Action != "restarted" {
+			t.Errorf("expected every attempt to restart the running container, got %+v", attempt)
+		}
+	}
+	data, _ := os.ReadFile(callLog)
+	if got := strings.Count(string(data), "restart payram-core"); got != 2 {
+		t.Errorf("expected 2 restarts, got %d", got)
+	}
+	if strings.Contains(string(data), "stop payram-core") {
+		t.Errorf("expected a healthy container not to be stopped, got calls:\n%s", data)
+	}
+
+	// Without retries the failed health check is left to an operator
+	saveFailedJob(t, jobStore, "HEALTHCHECK_FAILED")
+	recoverer.Retries = 0
+	if result, _ := recoverer.Run(context.Background()); result.Success || result.Refusals == "" {
+		t.Errorf("expected recovery to be refused without --retries, got %+v", result)
+	}
+}
+
+func TestRecoverer_Run_DryRunChangesNothing(t *testing.T) {
+	tests := []struct {
+		code   string
+		action string
+	}{
+		{"DOCKER_PULL_FAILED", "would_start_container"},
+		{"DOCKER_ERROR", "would_stop_and_remove_container"},
+		{"HEALTHCHECK_FAILED", "would_restart_container"},
+		{"CONCURRENCY_BLOCKED", "cleared_concurrency_block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			jobStore := jobs.NewStore(t.TempDir())
+			saveFailedJob(t, jobStore, tt.code)
+			runner, callLog := newStoppedContainerRunner(t)
+			core := newHealthServer(t, 1)
+
+			recoverer := NewRecoverer(jobStore, runner, "payram-core", core.URL)
+			recoverer.DryRun = true
+
+			// DOCKER_ERROR and HEALTHCHECK_FAILED are refused by Run, so plan
+			// them directly
+			job, _ := jobStore.LoadLatest()
+			result := recoverer.planRecovery(context.Background(), tt.code, job)
+			if tt.code == "DOCKER_PULL_FAILED" {
+				var err error
+				if result, err = recoverer.Run(context.Background()); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if !result.DryRun || result.Action != tt.action || !result.Success {
+				t.Errorf("expected a successful dry run planning %s, got %+v", tt.action, result)
+			}
+			if len(result.Attempts) != 0 {
+				t.Errorf("expected no attempts in a dry run, got %+v", result.Attempts)
+			}
+
+			data, _ := os.ReadFile(callLog)
+			for _, call := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				if call != "" && !strings.HasPrefix(call, "inspect ") {
+					t.Errorf("expected only read-only docker calls in a dry run, got %q", call)
+				}
+			}
+
+			job, _ = jobStore.LoadLatest()
+			if job.State != jobs.JobStateFailed || job.FailureCode != tt.code {
+				t.Errorf("expected the failed job to be left alone, got %s %s", job.State, job.FailureCode)
+			}
+		})
+	}
+}
+
+func TestRecoverer_Run_DryRunStillRefuses(t *testing.T) {
+	jobStore := jobs.NewStore(t.TempDir())
+	saveFailedJob(t, jobStore, "VERSION_MISMATCH")
+	runner, _ := newStoppedContainerRunner(t)
+
+	recoverer := NewRecoverer(jobStore, runner, "payram-core", "http://localhost:8080")
+	recoverer.DryRun = true
+
+	result, err := recoverer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Success || result.Refusals == "" {
+		t.Errorf("expected a dry run to report the refusal, got %+v", result)
+	}
+}
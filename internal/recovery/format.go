@@ -0,0 +1,42 @@
+package recovery
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteList writes one line per known failure code, sorted by code, with its
+// severity, data risk and title.
+func WriteList(w io.Writer) error {
+	codes := AllCodes()
+	sort.Strings(codes)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CODE\tSEVERITY\tDATA RISK\tTITLE")
+	for _, code := range codes {
+		playbook := GetPlaybook(code)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", playbook.Code, playbook.Severity, playbook.DataRisk, playbook.Title)
+	}
+	return tw.Flush()
+}
+
+// WritePlaybook writes a playbook as human-readable recovery instructions.
+func WritePlaybook(w io.Writer, playbook Playbook) {
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "⚠️  RECOVERY: %s\n", playbook.Title)
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "\nSeverity: %s\n", playbook.Severity)
+	fmt.Fprintf(w, "Data Risk: %s\n", playbook.DataRisk)
+	fmt.Fprintf(w, "\n%s\n", playbook.UserMessage)
+	fmt.Fprintln(w, "\n--- Recovery Steps (SSH) ---")
+	for _, step := range playbook.SSHSteps {
+		fmt.Fprintf(w, "  %s\n", step)
+	}
+	if playbook.DocsURL != "" {
+		fmt.Fprintf(w, "\nDocumentation: %s\n", playbook.DocsURL)
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+}
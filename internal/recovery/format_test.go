@@ -0,0 +1,74 @@
+package recovery
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWriteList_IncludesAllCodes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteList(&buf); err != nil {
+		t.Fatalf("WriteList failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.HasPrefix(lines[0], "CODE") {
+		t.Errorf("expected a header line, got %q", lines[0])
+	}
+
+	var listed []string
+	for _, line := range lines[1:] {
+		listed = append(listed, strings.Fields(line)[0])
+	}
+	codes := AllCodes()
+	sort.Strings(codes)
+	if strings.Join(listed, ",") != strings.Join(codes, ",") {
+		t.Errorf("expected codes %v in order, got %v", codes, listed)
+	}
+
+	for _, line := range lines[1:] {
+		code := strings.Fields(line)[0]
+		playbook := GetPlaybook(code)
+		for _, want := range []string{string(playbook.Severity), string(playbook.DataRisk), playbook.Title} {
+			if !strings.Contains(line, want) {
+				t.Errorf("expected line for %s to contain %q, got %q", code, want, line)
+			}
+		}
+	}
+}
+
+func TestWritePlaybook_RendersSteps(t *testing.T) {
+	playbook := RenderPlaybook("DOCKER_ERROR", PlaybookContext{ContainerName: "payram-core"})
+
+	var buf bytes.Buffer
+	WritePlaybook(&buf, playbook)
+	out := buf.String()
+
+	for _, want := range []string{playbook.Title, string(playbook.Severity), string(playbook.DataRisk), playbook.UserMessage, playbook.DocsURL} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	for _, step := range playbook.SSHSteps {
+		if !strings.Contains(out, step) {
+			t.Errorf("expected output to contain step %q", step)
+		}
+	}
+	if !strings.Contains(out, "docker logs payram-core") {
+		t.Errorf("expected container name substituted into steps, got:\n%s", out)
+	}
+	if strings.Contains(out, "<container_name>") {
+		t.Errorf("expected no unrendered <container_name> placeholder, got:\n%s", out)
+	}
+}
+
+func TestIsKnownCode(t *testing.T) {
+	if !IsKnownCode("DOCKER_ERROR") {
+		t.Error("expected DOCKER_ERROR to be known")
+	}
+	if IsKnownCode("NOT_A_CODE") {
+		t.Error("expected NOT_A_CODE to be unknown")
+	}
+}
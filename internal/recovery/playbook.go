@@ -37,6 +37,16 @@ const (
 	DataRiskUnknown  DataRisk = "UNKNOWN"
 )
 
+// AllSeverities returns every severity, least to most serious.
+func AllSeverities() []Severity {
+	return []Severity{SeverityInfo, SeverityRetryable, SeverityManual}
+}
+
+// AllDataRisks returns every data risk level, lowest first.
+func AllDataRisks() []DataRisk {
+	return []DataRisk{DataRiskNone, DataRiskPossible, DataRiskLikely, DataRiskUnknown}
+}
+
 // Playbook contains recovery instructions for a specific failure code.
 type Playbook struct {
 	Code        string   `json:"code"`
@@ -96,11 +106,43 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskNone,
 	},
 
+	"IMAGE_REPO_NOT_ALLOWED": {
+		Code:        "IMAGE_REPO_NOT_ALLOWED",
+		Severity:    SeverityManual,
+		Title:       "Image Repository Not Allowed",
+		UserMessage: "The runtime manifest points at an image repository that is not in ALLOWED_IMAGE_REPOS. No changes were made.",
+		SSHSteps: []string{
+			"1. Check the manifest image repo: curl -s $RUNTIME_MANIFEST_URL | jq .image.repo",
+			"2. Verify RUNTIME_MANIFEST_URL points at the official PayRam manifest",
+			"3. If the repo is expected, add it to ALLOWED_IMAGE_REPOS in /etc/payram/updater.env",
+			"4. Restart the updater: sudo systemctl restart payram-updater",
+			"5. Retry the upgrade",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/configuration",
+		DataRisk: DataRiskNone,
+	},
+
+	"DOCKER_TOO_OLD": {
+		Code:        "DOCKER_TOO_OLD",
+		Severity:    SeverityManual,
+		Title:       "Docker Version Too Old",
+		UserMessage: "The Docker daemon is older than the minimum the runtime manifest requires for this version. No changes were made.",
+		SSHSteps: []string{
+			"1. Check the Docker version: docker info --format '{{.ServerVersion}}'",
+			"2. Check the required version: curl -s $RUNTIME_MANIFEST_URL | jq .min_docker_version",
+			"3. Upgrade Docker Engine following https://docs.docker.com/engine/install/",
+			"4. Verify Payram Core came back up: docker ps",
+			"5. Retry the upgrade",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskNone,
+	},
+
 	"DOCKER_PULL_FAILED": {
 		Code:        "DOCKER_PULL_FAILED",
 		Severity:    SeverityRetryable,
 		Title:       "Docker Pull Failed",
-		UserMessage: "Failed to pull the new container image. Check network and disk space.",
+		UserMessage: "Failed to pull the new container image, including after retrying transient network errors. Check network, registry credentials and disk space.",
 		SSHSteps: []string{
 			"1. Check disk space: df -h",
 			"2. Check Docker daemon: docker info",
@@ -151,6 +193,40 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskPossible,
 	},
 
+	"CONTAINER_CRASHLOOP": {
+		Code:        "CONTAINER_CRASHLOOP",
+		Severity:    SeverityManual,
+		Title:       "Container Crash-Looping",
+		UserMessage: "The new container kept restarting during health verification. Restore from backup and rollback to previous version.",
+		SSHSteps: []string{
+			"1. Check the restart count: docker inspect -f '{{.RestartCount}}' <container_name>",
+			"2. Check why it exits: docker logs <container_name> --tail 200",
+			"3. Stop the crash-looping container: docker stop <container_name>",
+			"4. RESTORE FROM BACKUP:",
+			"   - List backups: payram-updater backup list",
+			"   - Restore: payram-updater backup restore --file <backup_path> --full-recovery",
+			"5. Verify health: curl <base_url>/api/v1/health",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/health",
+		DataRisk: DataRiskPossible,
+	},
+
+	"RUNTIME_DRIFT": {
+		Code:        "RUNTIME_DRIFT",
+		Severity:    SeverityManual,
+		Title:       "Runtime Configuration Drift",
+		UserMessage: "The new container is running, but an env var, mount or port the updater configured did not take effect. It may be using the wrong data directory or be unreachable.",
+		SSHSteps: []string{
+			"1. See which settings drifted: payram-updater logs",
+			"2. Compare the intended command (runCommand in payram-updater status) with the container: docker inspect <container_name>",
+			"3. Check that every mount source still exists on the host: ls -ld <mount_source>",
+			"4. Stop the container before it writes to the wrong place: docker stop <container_name>",
+			"5. Fix the host (missing directory, volume or port conflict), then run: payram-updater recover",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskPossible,
+	},
+
 	"VERSION_MISMATCH": {
 		Code:        "VERSION_MISMATCH",
 		Severity:    SeverityManual,
@@ -206,6 +282,23 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskNone,
 	},
 
+	"MISSING_VOLUME": {
+		Code:        "MISSING_VOLUME",
+		Severity:    SeverityManual,
+		Title:       "Container Volume Missing",
+		UserMessage: "A volume used by the Payram container no longer exists. The upgrade was stopped before the container was touched; recreating it now would start with empty data.",
+		SSHSteps: []string{
+			"1. Do NOT stop, restart or remove the container: it may be the only thing still holding the data",
+			"2. Find the missing volume in the job message: payram-updater status",
+			"3. Compare with existing volumes: docker volume ls and docker inspect <container_name> --format '{{json .Mounts}}'",
+			"4. Back up the database now: payram-updater backup create",
+			"5. Recreate or restore the volume (e.g. from a host-level snapshot) before retrying",
+			"6. Retry the upgrade once docker volume inspect succeeds for every volume",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskPossible,
+	},
+
 	"CONCURRENCY_BLOCKED": {
 		Code:        "CONCURRENCY_BLOCKED",
 		Severity:    SeverityRetryable,
@@ -221,6 +314,79 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskNone,
 	},
 
+	"RESTORE_IN_PROGRESS": {
+		Code:        "RESTORE_IN_PROGRESS",
+		Severity:    SeverityRetryable,
+		Title:       "Restore In Progress",
+		UserMessage: "Another process is restoring or rolling back the database. The operation was not started and the container was not modified.",
+		SSHSteps: []string{
+			"1. Check the job message for the process and the restore it is running: payram-updater status",
+			"2. Wait for that restore or rollback to finish",
+			"3. If its process is gone, the stale lock is cleared on the next attempt",
+			"4. Retry the operation",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/concurrency",
+		DataRisk: DataRiskNone,
+	},
+
+	"REGISTRY_RATE_LIMITED": {
+		Code:        "REGISTRY_RATE_LIMITED",
+		Severity:    SeverityRetryable,
+		Title:       "Registry Rate Limit Reached",
+		UserMessage: "The image registry refused the pull because this host reached its pull rate limit. The container was not modified.",
+		SSHSteps: []string{
+			"1. Check the job message for when the limit resets: payram-updater status",
+			"2. Authenticate to raise the limit: docker login",
+			"3. Or wait for the limit to reset",
+			"4. Retry the upgrade",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskNone,
+	},
+
+	"UNKNOWN_TARGET_VERSION": {
+		Code:        "UNKNOWN_TARGET_VERSION",
+		Severity:    SeverityManual,
+		Title:       "Target Version Not Released",
+		UserMessage: "The requested target version is not in the policy's list of releases, most likely a typo. No changes were made.",
+		SSHSteps: []string{
+			"1. Check the available versions in the job message, or: curl -s $POLICY_URL | jq .releases",
+			"2. Retry the upgrade with a listed version: payram-updater run --to <version>",
+			"3. To install an unlisted build deliberately, use manual mode, which only warns",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/configuration",
+		DataRisk: DataRiskNone,
+	},
+
+	"LATEST_UNRESOLVED": {
+		Code:        "LATEST_UNRESOLVED",
+		Severity:    SeverityManual,
+		Title:       "Latest Version Could Not Be Resolved",
+		UserMessage: "The latest-patch or latest-dashboard target could not be resolved, usually because the running version is unknown. No changes were made.",
+		SSHSteps: []string{
+			"1. Check the running version: payram-updater inspect",
+			"2. Make sure the Payram container is running and reports its version",
+			"3. Or retry with an exact version: payram-updater run --to <version>",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/configuration",
+		DataRisk: DataRiskNone,
+	},
+
+	"UPGRADE_TOO_SOON": {
+		Code:        "UPGRADE_TOO_SOON",
+		Severity:    SeverityRetryable,
+		Title:       "Upgrade Too Soon After The Last One",
+		UserMessage: "The last upgrade completed less than the configured minimum interval ago. No changes were made.",
+		SSHSteps: []string{
+			"1. Check when the last upgrade completed: payram-updater history export --type upgrade --status succeeded",
+			"2. Confirm the current version is healthy: payram-updater inspect",
+			"3. Retry once MIN_UPGRADE_INTERVAL_MINUTES has passed",
+			"4. To upgrade now anyway: payram-updater run --to <version> --force",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/concurrency",
+		DataRisk: DataRiskNone,
+	},
+
 	"BACKUP_FAILED": {
 		Code:        "BACKUP_FAILED",
 		Severity:    SeverityRetryable,
@@ -254,6 +420,69 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskNone,
 	},
 
+	"PRE_BACKUP_HOOK_FAILED": {
+		Code:        "PRE_BACKUP_HOOK_FAILED",
+		Severity:    SeverityRetryable,
+		Title:       "Pre-Backup Hook Failed",
+		UserMessage: "The configured pre-backup hook failed. The upgrade was aborted before the backup and no changes were made.",
+		SSHSteps: []string{
+			"1. Check the hook error in the upgrade logs: payram-updater logs",
+			"2. Review PRE_BACKUP_HOOK in /etc/payram/updater.env",
+			"3. Run the hook command (or call the hook URL) manually and confirm it succeeds",
+			"4. Confirm POST_BACKUP_HOOK resumed anything the pre-hook paused",
+			"5. Retry the upgrade once the hook succeeds",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/backup",
+		DataRisk: DataRiskNone,
+	},
+
+	"BACKUP_SELECTION_INVALID": {
+		Code:        "BACKUP_SELECTION_INVALID",
+		Severity:    SeverityManual,
+		Title:       "Backup Selection Invalid",
+		UserMessage: "The configured backup database or schemas do not exist, so no backup was taken. The upgrade was aborted before any changes.",
+		SSHSteps: []string{
+			"1. Check which database or schema is missing in the upgrade logs: payram-updater logs",
+			"2. Review BACKUP_DATABASE, BACKUP_INCLUDE_SCHEMAS and BACKUP_EXCLUDE_SCHEMAS in /etc/payram/updater.env",
+			"3. List the databases and schemas: docker exec <container_name> psql -U $POSTGRES_USERNAME -c '\\l' -c '\\dn'",
+			"4. Correct or clear the settings and restart the updater",
+			"5. Retry the upgrade",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/backup",
+		DataRisk: DataRiskNone,
+	},
+
+	"BACKUP_MOUNT_MISSING": {
+		Code:        "BACKUP_MOUNT_MISSING",
+		Severity:    SeverityManual,
+		Title:       "Backup Storage Not Mounted",
+		UserMessage: "The backup directory points at storage that is not mounted, so no backup was taken. The upgrade was aborted before any changes.",
+		SSHSteps: []string{
+			"1. Check which path is missing in the upgrade logs: payram-updater logs",
+			"2. Inspect the backup directory and its symlink target: ls -la <backup_dir> && readlink -f <backup_dir>",
+			"3. Mount the backup storage (e.g. sudo mount -a) and confirm with: findmnt --target <backup_dir>",
+			"4. Retry the upgrade",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/backup",
+		DataRisk: DataRiskNone,
+	},
+
+	"SNAPSHOT_FAILED": {
+		Code:        "SNAPSHOT_FAILED",
+		Severity:    SeverityRetryable,
+		Title:       "Volume Snapshot Failed",
+		UserMessage: "The configured volume snapshot command failed, so no backup was taken. The upgrade was aborted before any changes.",
+		SSHSteps: []string{
+			"1. Check the snapshot command error in the upgrade logs: payram-updater logs",
+			"2. Review BACKUP_STRATEGY and BACKUP_SNAPSHOT_COMMAND in /etc/payram/updater.env",
+			"3. Run the snapshot command manually and check free space in the volume group or filesystem (vgs / btrfs filesystem usage)",
+			"4. Remove any partial snapshot the failed command left behind",
+			"5. Retry the upgrade, or set BACKUP_STRATEGY=dump to fall back to pg_dump",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/backup",
+		DataRisk: DataRiskNone,
+	},
+
 	"SUPERVISORCTL_FAILED": {
 		Code:        "SUPERVISORCTL_FAILED",
 		Severity:    SeverityManual,
@@ -269,6 +498,21 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskNone,
 	},
 
+	"PAUSED_PROGRAMS_NOT_RESUMED": {
+		Code:        "PAUSED_PROGRAMS_NOT_RESUMED",
+		Severity:    SeverityManual,
+		Title:       "Paused Programs Not Resumed",
+		UserMessage: "The new version passed verification, but the supervisor programs paused during verification could not be restarted. Start them to resume normal operation; no restore is needed.",
+		SSHSteps: []string{
+			"1. Check which programs are stopped: docker exec <container_name> supervisorctl status",
+			"2. Start the programs named in the job message: docker exec <container_name> supervisorctl start <program> ...",
+			"3. Let them autostart after a restart again: docker exec <container_name> rm -f /etc/supervisor/conf.d/zz-payram-updater-hold.conf (or the VERIFY_PAUSE_HOLD_FILE path)",
+			"4. Verify health: curl <base_url>/api/v1/health",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/health",
+		DataRisk: DataRiskNone,
+	},
+
 	"DOCKER_DAEMON_DOWN": {
 		Code:        "DOCKER_DAEMON_DOWN",
 		Severity:    SeverityManual,
@@ -285,6 +529,86 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskNone,
 	},
 
+	"DOCKER_BINARY_INVALID": {
+		Code:        "DOCKER_BINARY_INVALID",
+		Severity:    SeverityManual,
+		Title:       "Docker CLI Not Found",
+		UserMessage: "The configured Docker binary (DOCKER_BIN) is missing or is not the Docker CLI. No changes were made.",
+		SSHSteps: []string{
+			"1. Find the Docker CLI: command -v docker",
+			"2. Verify it works: docker version",
+			"3. Set DOCKER_BIN to that path in the updater's environment",
+			"4. Restart the updater: sudo systemctl restart payram-updater",
+			"5. Retry the upgrade",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskNone,
+	},
+
+	"DOCKER_PERMISSION_DENIED": {
+		Code:        "DOCKER_PERMISSION_DENIED",
+		Severity:    SeverityManual,
+		Title:       "No Permission to Use Docker",
+		UserMessage: "Docker is running, but the updater's user is not allowed to use the Docker socket. No changes were made.",
+		SSHSteps: []string{
+			"1. Check which user the updater runs as: systemctl show payram-updater -p User",
+			"2. Check socket ownership: ls -l /var/run/docker.sock",
+			"3. Either run the updater as root, or add its user to the docker group: sudo usermod -aG docker <user>",
+			"4. Restart the updater so the new group membership applies: sudo systemctl restart payram-updater",
+			"5. Verify access as that user: sudo -u <user> docker info",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskNone,
+	},
+
+	"UPGRADE_TIMEOUT": {
+		Code:        "UPGRADE_TIMEOUT",
+		Severity:    SeverityManual,
+		Title:       "Upgrade Timed Out",
+		UserMessage: "The upgrade did not finish within UPGRADE_TIMEOUT_SECONDS. If it timed out before the container was stopped, no changes were made; otherwise the container may need to be recovered.",
+		SSHSteps: []string{
+			"1. Check the job message: payram-updater status (it says whether the point of no return was reached)",
+			"2. Check container status: docker ps -a | grep <container_name>",
+			"3. If the container is missing or stopped, run: payram-updater recover",
+			"4. Look for the slow step in the logs: payram-updater logs (commonly a stuck image pull)",
+			"5. Check registry reachability: docker pull <image_repo>:<target_version>",
+			"6. Retry the upgrade, raising UPGRADE_TIMEOUT_SECONDS if the network is just slow",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskPossible,
+	},
+
+	"UPGRADE_INTERRUPTED": {
+		Code:        "UPGRADE_INTERRUPTED",
+		Severity:    SeverityManual,
+		Title:       "Upgrade Interrupted",
+		UserMessage: "The updater stopped in the middle of an upgrade. On restart it brought the original container back from the pre-upgrade snapshot where it could; the job message says what it did.",
+		SSHSteps: []string{
+			"1. Read what the restart did: payram-updater status",
+			"2. Check container status and version: docker ps -a | grep <container_name>",
+			"3. Verify health: curl <base_url>/api/v1/health",
+			"4. If the database may have been migrated, restore the pre-upgrade backup: payram-updater backup restore --file <backup_path>",
+			"5. Find why the updater stopped: journalctl -u payram-updater",
+			"6. Retry the upgrade once the container is healthy",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/docker",
+		DataRisk: DataRiskPossible,
+	},
+
+	"STATE_PERSIST_FAILED": {
+		Code:        "STATE_PERSIST_FAILED",
+		Severity:    SeverityManual,
+		Title:       "Pre-Upgrade State Not Saved",
+		UserMessage: "The container's configuration could not be saved to the state directory before stopping it, so the upgrade was refused. The container was not modified.",
+		SSHSteps: []string{
+			"1. Check the error in the logs: payram-updater logs",
+			"2. Check the state directory is writable and has space: ls -ld <state_dir> && df -h <state_dir>",
+			"3. Retry the upgrade once fixed (no changes were made)",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/configuration",
+		DataRisk: DataRiskNone,
+	},
+
 	"CONTAINER_NOT_FOUND": {
 		Code:        "CONTAINER_NOT_FOUND",
 		Severity:    SeverityManual,
@@ -317,6 +641,69 @@ var playbooks = map[string]Playbook{
 		DataRisk: DataRiskNone,
 	},
 
+	"DB_UNREACHABLE": {
+		Code:        "DB_UNREACHABLE",
+		Severity:    SeverityRetryable,
+		Title:       "Database Unreachable",
+		UserMessage: "The database could not be reached with the container's credentials, so no backup was taken. The container was not modified.",
+		SSHSteps: []string{
+			"1. Check the connection error in the upgrade logs: payram-updater logs",
+			"2. Test connectivity: docker exec <container_name> psql -h $POSTGRES_HOST -p $POSTGRES_PORT -U $POSTGRES_USERNAME -d $POSTGRES_DATABASE -c 'SELECT 1'",
+			"3. For an external database, check firewall rules and that the database server is running",
+			"4. Verify the POSTGRES_PASSWORD in the container environment is still valid",
+			"5. Retry the upgrade once the database is reachable",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/database",
+		DataRisk: DataRiskNone,
+	},
+
+	"POST_RESTORE_CONNECT_FAILED": {
+		Code:        "POST_RESTORE_CONNECT_FAILED",
+		Severity:    SeverityManual,
+		Title:       "Container Cannot Connect After Restore",
+		UserMessage: "The database was restored, but the application container cannot connect to it with its own credentials. The credentials in the backup no longer match the container's.",
+		SSHSteps: []string{
+			"1. Check the connection error in the restore output or the upgrade logs: payram-updater logs",
+			"2. Compare the container's credentials with the restored roles: docker exec <container_name> env | grep POSTGRES",
+			"3. If the password changed after the backup was taken, set it on the restored role: ALTER ROLE <user> PASSWORD '<POSTGRES_PASSWORD>'",
+			"4. Test the connection: docker exec <container_name> psql -h $POSTGRES_HOST -p $POSTGRES_PORT -U $POSTGRES_USERNAME -d $POSTGRES_DATABASE -c 'SELECT 1'",
+			"5. Restart the container once it connects: docker restart <container_name>",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/database",
+		DataRisk: DataRiskNone,
+	},
+
+	"BACKUP_SUSPICIOUSLY_SMALL": {
+		Code:        "BACKUP_SUSPICIOUSLY_SMALL",
+		Severity:    SeverityRetryable,
+		Title:       "Backup Failed Verification",
+		UserMessage: "pg_dump reported success, but the backup file was stale or far smaller than the database. The file was discarded and the upgrade was aborted before any changes.",
+		SSHSteps: []string{
+			"1. Check the upgrade logs for the file and database sizes: payram-updater logs",
+			"2. Check the database size: docker exec <container_name> psql -U $POSTGRES_USERNAME -d $POSTGRES_DATABASE -c 'SELECT pg_size_pretty(pg_database_size(current_database()))'",
+			"3. Test pg_dump manually and compare the output size: docker exec <container_name> pg_dump -U $POSTGRES_USERNAME -d $POSTGRES_DATABASE | wc -c",
+			"4. Check that POSTGRES_DATABASE points at the database holding Payram data",
+			"5. Retry the upgrade once a manual dump looks complete",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/backup",
+		DataRisk: DataRiskNone,
+	},
+
+	"BACKUP_VERIFY_FAILED": {
+		Code:        "BACKUP_VERIFY_FAILED",
+		Severity:    SeverityRetryable,
+		Title:       "Backup Is Not Restorable",
+		UserMessage: "The pre-upgrade backup was written but would not restore: it is incomplete or not a pg_dump backup. The file was discarded and the upgrade was aborted before any changes.",
+		SSHSteps: []string{
+			"1. Check the upgrade logs for why the backup failed verification: payram-updater logs",
+			"2. Check free space in the backup directory: df -h $BACKUP_DIR",
+			"3. Test pg_dump manually and check the dump ends with '-- PostgreSQL database dump complete': docker exec <container_name> pg_dump -U $POSTGRES_USERNAME -d $POSTGRES_DATABASE | tail -n 3",
+			"4. Retry the upgrade once a manual dump completes",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/backup",
+		DataRisk: DataRiskNone,
+	},
+
 	"BACKUP_TIMEOUT": {
 		Code:        "BACKUP_TIMEOUT",
 		Severity:    SeverityRetryable,
@@ -399,6 +786,24 @@ var playbooks = map[string]Playbook{
 		DocsURL:  "https://docs.payram.com/troubleshooting/migrations",
 		DataRisk: DataRiskPossible,
 	},
+
+	"MIGRATION_STALLED": {
+		Code:        "MIGRATION_STALLED",
+		Severity:    SeverityManual,
+		Title:       "Database Migration Stalled",
+		UserMessage: "A database transaction of the new version stopped progressing during health verification: it was waiting on a lock or idle in its transaction. Find what holds it, then restore from backup or let the migration finish.",
+		SSHSteps: []string{
+			"1. Find the stalled session and what blocks it: docker exec <container_name> psql -U <db_user> -d <db_name> -c \"SELECT pid, state, wait_event_type, pg_blocking_pids(pid), now() - xact_start AS age, left(query, 80) FROM pg_stat_activity WHERE xact_start IS NOT NULL\"",
+			"2. Check container logs for migration progress: docker logs <container_name> --tail 200",
+			"3. If another session holds the lock, end it (SELECT pg_terminate_backend(<pid>)) and restart the container: docker restart <container_name>",
+			"4. Otherwise RESTORE FROM BACKUP:",
+			"   - List backups: payram-updater backup list",
+			"   - Restore: payram-updater backup restore --file <backup_path> --full-recovery",
+			"5. Verify health: curl <base_url>/api/v1/health",
+		},
+		DocsURL:  "https://docs.payram.com/troubleshooting/migrations",
+		DataRisk: DataRiskPossible,
+	},
 }
 
 // unknownPlaybook is returned when a failure code is not recognized.
@@ -439,6 +844,12 @@ func AllCodes() []string {
 	return codes
 }
 
+// IsKnownCode returns true if code has its own playbook.
+func IsKnownCode(code string) bool {
+	_, ok := playbooks[code]
+	return ok
+}
+
 // IsRetryable returns true if the failure can be safely retried.
 func IsRetryable(code string) bool {
 	playbook := GetPlaybook(code)
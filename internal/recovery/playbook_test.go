@@ -158,7 +158,10 @@ func TestIsRetryable(t *testing.T) {
 		"POLICY_FETCH_FAILED",
 		"MANIFEST_FETCH_FAILED",
 		"DOCKER_PULL_FAILED",
+		"REGISTRY_RATE_LIMITED",
 		"CONCURRENCY_BLOCKED",
+		"RESTORE_IN_PROGRESS",
+		"UPGRADE_TOO_SOON",
 	}
 
 	for _, code := range retryableCodes {
@@ -189,6 +192,8 @@ func TestRequiresManualIntervention(t *testing.T) {
 		"MIGRATION_FAILED",
 		"DISK_SPACE_LOW",
 		"MANUAL_UPGRADE_REQUIRED",
+		"UNKNOWN_TARGET_VERSION",
+		"LATEST_UNRESOLVED",
 	}
 
 	for _, code := range manualCodes {
@@ -216,6 +221,7 @@ func TestHasDataRisk(t *testing.T) {
 		"HEALTHCHECK_FAILED",
 		"VERSION_MISMATCH",
 		"MIGRATION_FAILED",
+		"MISSING_VOLUME",
 	}
 
 	for _, code := range riskyCodes {
@@ -484,19 +490,35 @@ func TestAllFailureCodesHavePlaybooks(t *testing.T) {
 		"RUNTIME_INSPECTION_FAILED",
 		"DOCKER_RUN_BUILD_FAILED",
 		"DOCKER_DAEMON_DOWN",
+		"DOCKER_BINARY_INVALID",
+		"DOCKER_TOO_OLD",
 		"DOCKER_PULL_FAILED",
+		"REGISTRY_RATE_LIMITED",
 		"DOCKER_ERROR",
+		"RUNTIME_DRIFT",
+		"UPGRADE_INTERRUPTED",
+		"STATE_PERSIST_FAILED",
 		"HEALTHCHECK_FAILED",
 		"VERSION_MISMATCH",
 		"MIGRATION_FAILED",
 		"MIGRATION_TIMEOUT",
+		"MIGRATION_STALLED",
+		"PAUSED_PROGRAMS_NOT_RESUMED",
 		"BACKUP_FAILED",
 		"CONTAINER_NOT_FOUND",
 		"INVALID_DB_CONFIG",
 		"BACKUP_TIMEOUT",
+		"BACKUP_SELECTION_INVALID",
+		"BACKUP_MOUNT_MISSING",
+		"BACKUP_VERIFY_FAILED",
+		"POST_RESTORE_CONNECT_FAILED",
 		"MANUAL_UPGRADE_REQUIRED",
 		"DISK_SPACE_LOW",
 		"CONCURRENCY_BLOCKED",
+		"RESTORE_IN_PROGRESS",
+		"MISSING_VOLUME",
+		"UNKNOWN_TARGET_VERSION",
+		"LATEST_UNRESOLVED",
 	}
 
 	for _, code := range requiredCodes {
@@ -572,20 +594,33 @@ func TestContainerSafetyZones(t *testing.T) {
 		{"RUNTIME_INSPECTION_FAILED", true, DataRiskNone, SeverityRetryable},
 		{"DOCKER_RUN_BUILD_FAILED", true, DataRiskNone, SeverityManual},
 		{"DOCKER_DAEMON_DOWN", true, DataRiskNone, SeverityManual},
+		{"DOCKER_BINARY_INVALID", true, DataRiskNone, SeverityManual},
+		{"DOCKER_TOO_OLD", true, DataRiskNone, SeverityManual},
 		{"DOCKER_PULL_FAILED", true, DataRiskNone, SeverityRetryable},
+		{"REGISTRY_RATE_LIMITED", true, DataRiskNone, SeverityRetryable},
 		{"BACKUP_FAILED", true, DataRiskNone, SeverityRetryable},
 		{"CONTAINER_NOT_FOUND", true, DataRiskNone, SeverityManual},
 		{"INVALID_DB_CONFIG", true, DataRiskNone, SeverityManual},
 		{"BACKUP_TIMEOUT", true, DataRiskNone, SeverityRetryable},
+		{"BACKUP_SELECTION_INVALID", true, DataRiskNone, SeverityManual},
+		{"BACKUP_MOUNT_MISSING", true, DataRiskNone, SeverityManual},
+		{"BACKUP_VERIFY_FAILED", true, DataRiskNone, SeverityRetryable},
 		{"SUPERVISORCTL_FAILED", true, DataRiskNone, SeverityManual},
+		{"STATE_PERSIST_FAILED", true, DataRiskNone, SeverityManual},
+		{"RESTORE_IN_PROGRESS", true, DataRiskNone, SeverityRetryable},
 
 		// Post-modification failures (container may be affected)
 		{"BACKUP_FAILED_AFTER_QUIESCE", false, DataRiskNone, SeverityRetryable},
 		{"DOCKER_ERROR", false, DataRiskPossible, SeverityManual},
+		{"RUNTIME_DRIFT", false, DataRiskPossible, SeverityManual},
+		{"UPGRADE_INTERRUPTED", false, DataRiskPossible, SeverityManual},
 		{"HEALTHCHECK_FAILED", false, DataRiskPossible, SeverityManual},
 		{"VERSION_MISMATCH", false, DataRiskPossible, SeverityManual},
 		{"MIGRATION_FAILED", false, DataRiskLikely, SeverityManual},
 		{"MIGRATION_TIMEOUT", false, DataRiskPossible, SeverityManual},
+		{"MIGRATION_STALLED", false, DataRiskPossible, SeverityManual},
+		{"PAUSED_PROGRAMS_NOT_RESUMED", false, DataRiskNone, SeverityManual},
+		{"POST_RESTORE_CONNECT_FAILED", false, DataRiskNone, SeverityManual},
 	}
 
 	for _, tc := range testCases {
@@ -642,7 +677,7 @@ func TestDataRiskClassification(t *testing.T) {
 			dataRisk: DataRiskNone,
 		},
 		{
-			codes:    []string{"HEALTHCHECK_FAILED", "VERSION_MISMATCH", "MIGRATION_TIMEOUT"},
+			codes:    []string{"HEALTHCHECK_FAILED", "VERSION_MISMATCH", "MIGRATION_TIMEOUT", "MISSING_VOLUME"},
 			dataRisk: DataRiskPossible,
 		},
 		{
@@ -0,0 +1,149 @@
+// Package report writes a per-upgrade summary file for operators' records:
+// what was upgraded, how long each phase took, which backup was taken and how
+// it ended.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Supported report formats.
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// Phase is the timing of one upgrade phase.
+type Phase struct {
+	Name            string    `json:"name"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+}
+
+// Report is the summary of one upgrade job.
+type Report struct {
+	JobID           string    `json:"jobId"`
+	Mode            string    `json:"mode"`
+	RequestedTarget string    `json:"requestedTarget"`
+	ResolvedTarget  string    `json:"resolvedTarget"`
+	SteppingStone   string    `json:"steppingStone,omitempty"`
+	FromVersion     string    `json:"fromVersion,omitempty"`
+	ExecutionMode   string    `json:"executionMode"`
+	Outcome         string    `json:"outcome"` // succeeded, validated, failed, cancelled
+	State           string    `json:"state"`
+	FailureCode     string    `json:"failureCode,omitempty"`
+	Message         string    `json:"message"`
+	StartedAt       time.Time `json:"startedAt"`
+	FinishedAt      time.Time `json:"finishedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Phases          []Phase   `json:"phases"`
+	BackupPath      string    `json:"backupPath,omitempty"`
+	BackupChecksum  string    `json:"backupChecksum,omitempty"` // SHA256 of the backup file
+	Warnings        []string  `json:"warnings,omitempty"`
+}
+
+// PhaseTimer records consecutive phases: starting a phase ends the previous
+// one. The zero value is ready to use.
+type PhaseTimer struct {
+	phases  []Phase
+	running bool
+}
+
+// Start ends the running phase, if any, and starts the named one.
+func (t *PhaseTimer) Start(name string) {
+	t.end()
+	t.phases = append(t.phases, Phase{Name: name, StartedAt: time.Now().UTC()})
+	t.running = true
+}
+
+// Stop ends the running phase, if any, and returns every recorded phase.
+func (t *PhaseTimer) Stop() []Phase {
+	t.end()
+	return t.phases
+}
+
+func (t *PhaseTimer) end() {
+	if !t.running {
+		return
+	}
+	last := &t.phases[len(t.phases)-1]
+	last.DurationSeconds = time.Since(last.StartedAt).Seconds()
+	t.running = false
+}
+
+// Write writes r to dir as upgrade-<jobId>.json or upgrade-<jobId>.md,
+// creating dir if needed, and returns the file path.
+func Write(dir, format string, r *Report) (string, error) {
+	var data []byte
+	ext := ".json"
+	switch format {
+	case FormatJSON:
+		var err error
+		if data, err = json.MarshalIndent(r, "", "  "); err != nil {
+			return "", fmt.Errorf("failed to encode report: %w", err)
+		}
+		data = append(data, '\n')
+	case FormatMarkdown:
+		data = []byte(Markdown(r))
+		ext = ".md"
+	default:
+		return "", fmt.Errorf("unsupported report format %q", format)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+	path := filepath.Join(dir, "upgrade-"+r.JobID+ext)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, nil
+}
+
+// Markdown renders r as a markdown document.
+func Markdown(r *Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Upgrade report: %s\n\n", r.JobID)
+
+	b.WriteString("| Field | Value |\n|---|---|\n")
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "| %s | %s |\n", name, strings.ReplaceAll(value, "|", "\\|"))
+		}
+	}
+	row("Outcome", r.Outcome)
+	row("State", r.State)
+	row("Failure code", r.FailureCode)
+	row("Message", r.Message)
+	row("Mode", r.Mode)
+	row("Execution mode", r.ExecutionMode)
+	row("Requested target", r.RequestedTarget)
+	row("Resolved target", r.ResolvedTarget)
+	row("Stepping stone", r.SteppingStone)
+	row("From version", r.FromVersion)
+	row("Started", r.StartedAt.Format(time.RFC3339))
+	row("Finished", r.FinishedAt.Format(time.RFC3339))
+	row("Duration", fmt.Sprintf("%.1fs", r.DurationSeconds))
+	row("Backup", r.BackupPath)
+	row("Backup SHA256", r.BackupChecksum)
+
+	if len(r.Phases) > 0 {
+		b.WriteString("\n## Phases\n\n| Phase | Started | Duration |\n|---|---|---|\n")
+		for _, p := range r.Phases {
+			fmt.Fprintf(&b, "| %s | %s | %.1fs |\n", p.Name, p.StartedAt.Format(time.RFC3339), p.DurationSeconds)
+		}
+	}
+
+	if len(r.Warnings) > 0 {
+		b.WriteString("\n## Warnings\n\n")
+		for _, w := range r.Warnings {
+			fmt.Fprintf(&b, "- %s\n", w)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleReport() *Report {
+	started := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	return &Report{
+		JobID:           "job-1",
+		Mode:            "MANUAL",
+		RequestedTarget: "latest",
+		ResolvedTarget:  "1.2.0",
+		FromVersion:     "1.1.0",
+		ExecutionMode:   "execute",
+		Outcome:         "succeeded",
+		State:           "READY",
+		Message:         "Upgrade completed",
+		StartedAt:       started,
+		FinishedAt:      started.Add(90 * time.Second),
+		DurationSeconds: 90,
+		Phases: []Phase{
+			{Name: "backup", StartedAt: started, DurationSeconds: 30},
+			{Name: "verify", StartedAt: started.Add(30 * time.Second), DurationSeconds: 60},
+		},
+		BackupPath:     "/backups/payram-backup.dump",
+		BackupChecksum: "abc123",
+	}
+}
+
+func TestPhaseTimer(t *testing.T) {
+	var timer PhaseTimer
+	timer.Start("pull")
+	time.Sleep(10 * time.Millisecond)
+	timer.Start("backup")
+	phases := timer.Stop()
+
+	if len(phases) != 2 || phases[0].Name != "pull" || phases[1].Name != "backup" {
+		t.Fatalf("expected phases [pull backup], got %+v", phases)
+	}
+	if phases[0].DurationSeconds < 0.01 {
+		t.Errorf("expected pull to last at least 10ms, got %fs", phases[0].DurationSeconds)
+	}
+	if !phases[1].StartedAt.After(phases[0].StartedAt) {
+		t.Errorf("expected backup to start after pull, got %v and %v", phases[0].StartedAt, phases[1].StartedAt)
+	}
+	if again := timer.Stop(); len(again) != 2 || again[1].DurationSeconds != phases[1].DurationSeconds {
+		t.Errorf("expected Stop to be idempotent, got %+v", again)
+	}
+}
+
+func TestWrite_JSON(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+
+	path, err := Write(dir, FormatJSON, sampleReport())
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if path != filepath.Join(dir, "upgrade-job-1.json") {
+		t.Errorf("unexpected report path %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if got.Outcome != "succeeded" || got.BackupChecksum != "abc123" || len(got.Phases) != 2 {
+		t.Errorf("unexpected report contents: %+v", got)
+	}
+}
+
+func TestWrite_Markdown(t *testing.T) {
+	dir := t.TempDir()
+	r := sampleReport()
+	r.Warnings = []string{"failed to prune Payram images"}
+
+	path, err := Write(dir, FormatMarkdown, r)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if filepath.Ext(path) != ".md" {
+		t.Errorf("expected a .md report, got %s", path)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"# Upgrade report: job-1", "| Outcome | succeeded |", "| Backup SHA256 | abc123 |", "| verify |", "- failed to prune Payram images"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in markdown report, got:\n%s", want, data)
+		}
+	}
+}
+
+func TestWrite_UnsupportedFormat(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Write(dir, "html", sampleReport()); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no report file, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,110 @@
+// Package rollback persists the "last known good" marker written after each
+// successful upgrade and rolls the Payram container back to it on demand.
+package rollback
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/payram/payram-updater/internal/container"
+)
+
+// Marker records the version that was running before the most recent
+// successful upgrade, together with enough state to bring it back.
+type Marker struct {
+	JobID           string                  `json:"jobId"`
+	PreviousVersion string                  `json:"previousVersion"`
+	UpgradedTo      string                  `json:"upgradedTo"`
+	BackupPath      string                  `json:"backupPath,omitempty"`
+	RuntimeState    *container.RuntimeState `json:"runtimeState"`
+	RecordedAt      time.Time               `json:"recordedAt"`
+}
+
+// Store handles persistence of the last known good marker.
+type Store struct {
+	stateDir string
+}
+
+// NewStore creates a marker store for the given state directory.
+func NewStore(stateDir string) *Store {
+	return &Store{stateDir: stateDir}
+}
+
+// Load reads the marker from disk.
+// Returns nil if no marker has been recorded.
+func (s *Store) Load() (*Marker, error) {
+	data, err := os.ReadFile(s.path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read last known good marker: %w", err)
+	}
+
+	var marker Marker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal last known good marker: %w", err)
+	}
+	return &marker, nil
+}
+
+// Save persists the marker atomically, replacing any previous marker.
+func (s *Store) Save(marker *Marker) error {
+	if marker == nil {
+		return fmt.Errorf("marker is required")
+	}
+	if marker.RecordedAt.IsZero() {
+		marker.RecordedAt = time.Now().UTC()
+	}
+
+	return s.writeFile(s.path(), ".last-known-good-*.tmp", "last known good marker", marker)
+}
+
+// writeFile marshals v and writes it to path atomically, through a temp
+// file named by pattern in the state directory.
+func (s *Store) writeFile(path, pattern, what string, v interface{}) error {
+	if err := os.MkdirAll(s.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", what, err)
+	}
+
+	tmpFile, err := os.CreateTemp(s.stateDir, pattern)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the marker. Clearing a missing marker is not an error.
+func (s *Store) Clear() error {
+	if err := os.Remove(s.path()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove last known good marker: %w", err)
+	}
+	return nil
+}
+
+// path returns the path to the marker file.
+func (s *Store) path() string {
+	return filepath.Join(s.stateDir, "last_known_good.json")
+}
@@ -0,0 +1,121 @@
+package rollback
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/container"
+)
+
+func TestStore_LoadNoMarker(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	marker, err := store.Load()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if marker != nil {
+		t.Errorf("expected nil marker, got %+v", marker)
+	}
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir)
+
+	marker := &Marker{
+		JobID:           "job-123",
+		PreviousVersion: "1.8.0",
+		UpgradedTo:      "1.9.0",
+		BackupPath:      "/backups/payram-backup-20250101-120000-1.8.0-to-1.9.0.dump",
+		RuntimeState: &container.RuntimeState{
+			Name:     "payram",
+			Image:    "payramapp/payram:1.8.0",
+			ImageTag: "1.8.0",
+			Ports:    []container.PortMapping{{HostPort: "8080", ContainerPort: "8080", Protocol: "tcp"}},
+			Env:      []string{"AES_KEY=secret"},
+		},
+	}
+	if err := store.Save(marker); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+	if marker.RecordedAt.IsZero() {
+		t.Error("expected RecordedAt to be set on save")
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "last_known_good.json")); err != nil {
+		t.Fatalf("expected marker file to exist: %v", err)
+	}
+
+	loaded, err := NewStore(tmpDir).Load()
+	if err != nil {
+		t.Fatalf("failed to load marker: %v", err)
+	}
+	if loaded == nil {
+		t.Fatal("expected marker, got nil")
+	}
+	if loaded.JobID != marker.JobID {
+		t.Errorf("expected JobID %q, got %q", marker.JobID, loaded.JobID)
+	}
+	if loaded.PreviousVersion != "1.8.0" {
+		t.Errorf("expected PreviousVersion 1.8.0, got %q", loaded.PreviousVersion)
+	}
+	if loaded.UpgradedTo != "1.9.0" {
+		t.Errorf("expected UpgradedTo 1.9.0, got %q", loaded.UpgradedTo)
+	}
+	if loaded.BackupPath != marker.BackupPath {
+		t.Errorf("expected BackupPath %q, got %q", marker.BackupPath, loaded.BackupPath)
+	}
+	if loaded.RuntimeState == nil {
+		t.Fatal("expected runtime state, got nil")
+	}
+	if len(loaded.RuntimeState.Ports) != 1 || loaded.RuntimeState.Ports[0].HostPort != "8080" {
+		t.Errorf("expected port mapping to round-trip, got %+v", loaded.RuntimeState.Ports)
+	}
+	if len(loaded.RuntimeState.Env) != 1 || loaded.RuntimeState.Env[0] != "AES_KEY=secret" {
+		t.Errorf("expected env to round-trip, got %+v", loaded.RuntimeState.Env)
+	}
+}
+
+func TestStore_SaveReplacesPreviousMarker(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if err := store.Save(&Marker{PreviousVersion: "1.7.0", UpgradedTo: "1.8.0"}); err != nil {
+		t.Fatalf("failed to save first marker: %v", err)
+	}
+	if err := store.Save(&Marker{PreviousVersion: "1.8.0", UpgradedTo: "1.9.0"}); err != nil {
+		t.Fatalf("failed to save second marker: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load marker: %v", err)
+	}
+	if loaded.PreviousVersion != "1.8.0" {
+		t.Errorf("expected latest marker to win, got PreviousVersion %q", loaded.PreviousVersion)
+	}
+}
+
+func TestStore_Clear(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	if err := store.Clear(); err != nil {
+		t.Errorf("expected clearing a missing marker to succeed, got %v", err)
+	}
+
+	if err := store.Save(&Marker{PreviousVersion: "1.8.0"}); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+	if err := store.Clear(); err != nil {
+		t.Fatalf("failed to clear marker: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load marker: %v", err)
+	}
+	if loaded != nil {
+		t.Errorf("expected marker to be cleared, got %+v", loaded)
+	}
+}
@@ -0,0 +1,150 @@
+package rollback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/payram/payram-updater/internal/backup"
+)
+
+// DockerRunner is the subset of dockerexec.Runner used for rollback.
+type DockerRunner interface {
+	Stop(ctx context.Context, container string) error
+	Remove(ctx context.Context, container string) error
+	Run(ctx context.Context, args []string) error
+	InspectRunning(ctx context.Context, container string) (bool, error)
+}
+
+// Restorer restores a database backup into a named container.
+type Restorer interface {
+	RestoreBackup(ctx context.Context, backupPath string, opts backup.RestoreOptions) (*backup.RestoreResult, error)
+}
+
+// Logger is the logging interface used by the rollbacker.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Result describes the outcome of a rollback.
+type Result struct {
+	ContainerName   string `json:"containerName"`
+	PreviousVersion string `json:"previousVersion"`
+	RolledBackFrom  string `json:"rolledBackFrom"`
+	BackupPath      string `json:"backupPath,omitempty"`
+	DBRestored      bool   `json:"dbRestored"`
+}
+
+// Rollbacker rolls the Payram container back to the last known good marker.
+type Rollbacker struct {
+	store    *Store
+	runner   DockerRunner
+	restorer Restorer
+	logger   Logger
+
+	// StartupWait is how long to wait after docker run before checking
+	// that the container is running and restoring the database.
+	StartupWait time.Duration
+
+	// RestoreLocked indicates the caller holds the restore lock for the
+	// whole rollback (see backup.Manager.LockOperation), so the restore does
+	// not take it again.
+	RestoreLocked bool
+}
+
+// NewRollbacker creates a new rollbacker.
+func NewRollbacker(store *Store, runner DockerRunner, restorer Restorer, logger Logger) *Rollbacker {
+	return &Rollbacker{
+		store:       store,
+		runner:      runner,
+		restorer:    restorer,
+		logger:      logger,
+		StartupWait: 5 * time.Second,
+	}
+}
+
+// Run replaces the running container with the marker's previous version,
+// restores the associated pre-upgrade backup, and clears the marker so the
+// same rollback cannot be applied twice.
+func (r *Rollbacker) Run(ctx context.Context, marker *Marker) (*Result, error) {
+	if marker == nil {
+		return nil, fmt.Errorf("no last known good marker recorded")
+	}
+	if marker.RuntimeState == nil {
+		return nil, fmt.Errorf("last known good marker has no runtime state")
+	}
+	if marker.PreviousVersion == "" {
+		return nil, fmt.Errorf("last known good marker has no previous version")
+	}
+
+	state := marker.RuntimeState
+	dockerArgs, err := RecreateArgs(state, marker.PreviousVersion, r.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build docker run args: %w", err)
+	}
+
+	r.logf("Stopping container: %s", state.Name)
+	if err := r.runner.Stop(ctx, state.Name); err != nil {
+		return nil, fmt.Errorf("failed to stop container: %w", err)
+	}
+	r.logf("Removing container: %s", state.Name)
+	if err := r.runner.Remove(ctx, state.Name); err != nil {
+		return nil, fmt.Errorf("failed to remove container: %w", err)
+	}
+
+	r.logf("Starting container with last known good version: %s", marker.PreviousVersion)
+	if err := r.runner.Run(ctx, dockerArgs); err != nil {
+		return nil, fmt.Errorf("failed to run container: %w", err)
+	}
+
+	if r.StartupWait > 0 {
+		time.Sleep(r.StartupWait)
+	}
+
+	running, err := r.runner.InspectRunning(ctx, state.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check container status: %w", err)
+	}
+	if !running {
+		return nil, fmt.Errorf("container is not running after rollback")
+	}
+
+	result := &Result{
+		ContainerName:   state.Name,
+		PreviousVersion: marker.PreviousVersion,
+		RolledBackFrom:  marker.UpgradedTo,
+		BackupPath:      marker.BackupPath,
+	}
+
+	if marker.BackupPath != "" {
+		r.logf("Restoring pre-upgrade backup: %s", marker.BackupPath)
+		restored, err := r.restorer.RestoreBackup(ctx, marker.BackupPath, backup.RestoreOptions{
+			Confirmed:     true,
+			ContainerName: state.Name,
+			FullRecovery:  true,
+			Locked:        r.RestoreLocked,
+		})
+		if err != nil {
+			if restored != nil && restored.DBRestored {
+				result.DBRestored = true
+				return result, fmt.Errorf("container rolled back to %s and database restored, but the container cannot use it: %w", marker.PreviousVersion, err)
+			}
+			return result, fmt.Errorf("container rolled back to %s but database restore failed: %w", marker.PreviousVersion, err)
+		}
+		result.DBRestored = true
+	} else {
+		r.logf("Warning: marker has no backup path; database left as-is")
+	}
+
+	if err := r.store.Clear(); err != nil {
+		r.logf("Warning: %v", err)
+	}
+
+	return result, nil
+}
+
+func (r *Rollbacker) logf(format string, v ...interface{}) {
+	if r.logger != nil {
+		r.logger.Printf(format, v...)
+	}
+}
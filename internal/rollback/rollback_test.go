@@ -0,0 +1,222 @@
+package rollback
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/backup"
+	"github.com/payram/payram-updater/internal/container"
+)
+
+type fakeRunner struct {
+	calls      []string
+	runArgs    []string
+	running    bool
+	runErr     error
+	inspectErr error
+}
+
+func (f *fakeRunner) Stop(ctx context.Context, name string) error {
+	f.calls = append(f.calls, "stop:"+name)
+	return nil
+}
+
+func (f *fakeRunner) Remove(ctx context.Context, name string) error {
+	f.calls = append(f.calls, "remove:"+name)
+	return nil
+}
+
+func (f *fakeRunner) Run(ctx context.Context, args []string) error {
+	f.calls = append(f.calls, "run")
+	f.runArgs = args
+	return f.runErr
+}
+
+func (f *fakeRunner) InspectRunning(ctx context.Context, name string) (bool, error) {
+	f.calls = append(f.calls, "inspect:"+name)
+	return f.running, f.inspectErr
+}
+
+type fakeRestorer struct {
+	path     string
+	opts     backup.RestoreOptions
+	err      error
+	restored bool // report the database as restored despite err
+}
+
+func (f *fakeRestorer) RestoreBackup(ctx context.Context, backupPath string, opts backup.RestoreOptions) (*backup.RestoreResult, error) {
+	f.path = backupPath
+	f.opts = opts
+	if f.err != nil {
+		if f.restored {
+			return &backup.RestoreResult{DBRestored: true}, f.err
+		}
+		return nil, f.err
+	}
+	return &backup.RestoreResult{DBRestored: true}, nil
+}
+
+func testMarker() *Marker {
+	return &Marker{
+		JobID:           "job-123",
+		PreviousVersion: "1.8.0",
+		UpgradedTo:      "1.9.0",
+		BackupPath:      "/backups/payram-backup-20250101-120000-1.8.0-to-1.9.0.dump",
+		RuntimeState: &container.RuntimeState{
+			Name:          "payram",
+			Image:         "payramapp/payram:1.9.0",
+			ImageTag:      "1.9.0",
+			RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
+		},
+	}
+}
+
+func newTestRollbacker(store *Store, runner DockerRunner, restorer Restorer) *Rollbacker {
+	r := NewRollbacker(store, runner, restorer, log.New(io.Discard, "", 0))
+	r.StartupWait = 0
+	return r
+}
+
+func TestRollbacker_Run_Success(t *testing.T) {
+	store := NewStore(t.TempDir())
+	marker := testMarker()
+	if err := store.Save(marker); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+	runner := &fakeRunner{running: true}
+	restorer := &fakeRestorer{}
+
+	result, err := newTestRollbacker(store, runner, restorer).Run(context.Background(), marker)
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	expectedCalls := []string{"stop:payram", "remove:payram", "run", "inspect:payram"}
+	if strings.Join(runner.calls, ",") != strings.Join(expectedCalls, ",") {
+		t.Errorf("expected calls %v, got %v", expectedCalls, runner.calls)
+	}
+	if got := runner.runArgs[len(runner.runArgs)-1]; got != "payramapp/payram:1.8.0" {
+		t.Errorf("expected rollback image payramapp/payram:1.8.0, got %s", got)
+	}
+
+	if restorer.path != marker.BackupPath {
+		t.Errorf("expected restore of %s, got %s", marker.BackupPath, restorer.path)
+	}
+	if !restorer.opts.Confirmed || restorer.opts.ContainerName != "payram" {
+		t.Errorf("expected confirmed restore into payram, got %+v", restorer.opts)
+	}
+
+	if !result.DBRestored {
+		t.Error("expected DBRestored to be true")
+	}
+	if result.PreviousVersion != "1.8.0" || result.RolledBackFrom != "1.9.0" {
+		t.Errorf("unexpected result versions: %+v", result)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load marker: %v", err)
+	}
+	if loaded != nil {
+		t.Error("expected marker to be cleared after successful rollback")
+	}
+}
+
+func TestRollbacker_Run_NoBackupSkipsRestore(t *testing.T) {
+	store := NewStore(t.TempDir())
+	marker := testMarker()
+	marker.BackupPath = ""
+	runner := &fakeRunner{running: true}
+	restorer := &fakeRestorer{}
+
+	result, err := newTestRollbacker(store, runner, restorer).Run(context.Background(), marker)
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if restorer.path != "" {
+		t.Errorf("expected no restore, got restore of %s", restorer.path)
+	}
+	if result.DBRestored {
+		t.Error("expected DBRestored to be false without a backup")
+	}
+}
+
+func TestRollbacker_Run_ContainerNotRunning(t *testing.T) {
+	store := NewStore(t.TempDir())
+	marker := testMarker()
+	if err := store.Save(marker); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+	restorer := &fakeRestorer{}
+
+	_, err := newTestRollbacker(store, &fakeRunner{running: false}, restorer).Run(context.Background(), marker)
+	if err == nil {
+		t.Fatal("expected error when container is not running")
+	}
+	if restorer.path != "" {
+		t.Error("expected no restore when container failed to start")
+	}
+	if loaded, _ := store.Load(); loaded == nil {
+		t.Error("expected marker to be kept after failed rollback")
+	}
+}
+
+func TestRollbacker_Run_RestoreFailureKeepsMarker(t *testing.T) {
+	store := NewStore(t.TempDir())
+	marker := testMarker()
+	if err := store.Save(marker); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+	restorer := &fakeRestorer{err: errors.New("pg_restore failed")}
+
+	result, err := newTestRollbacker(store, &fakeRunner{running: true}, restorer).Run(context.Background(), marker)
+	if err == nil {
+		t.Fatal("expected error when restore fails")
+	}
+	if result == nil || result.DBRestored {
+		t.Errorf("expected partial result without DB restore, got %+v", result)
+	}
+	if loaded, _ := store.Load(); loaded == nil {
+		t.Error("expected marker to be kept after failed restore")
+	}
+}
+
+func TestRollbacker_Run_RestoredButContainerCannotConnect(t *testing.T) {
+	store := NewStore(t.TempDir())
+	marker := testMarker()
+	if err := store.Save(marker); err != nil {
+		t.Fatalf("failed to save marker: %v", err)
+	}
+	restorer := &fakeRestorer{err: errors.New("POST_RESTORE_CONNECT_FAILED: password authentication failed"), restored: true}
+
+	result, err := newTestRollbacker(store, &fakeRunner{running: true}, restorer).Run(context.Background(), marker)
+	if err == nil || !strings.Contains(err.Error(), "database restored, but the container cannot use it") {
+		t.Fatalf("expected a connect failure after the restore, got %v", err)
+	}
+	if result == nil || !result.DBRestored {
+		t.Errorf("expected the result to report the database as restored, got %+v", result)
+	}
+	if loaded, _ := store.Load(); loaded == nil {
+		t.Error("expected marker to be kept when the container cannot connect")
+	}
+}
+
+func TestRollbacker_Run_InvalidMarker(t *testing.T) {
+	r := newTestRollbacker(NewStore(t.TempDir()), &fakeRunner{running: true}, &fakeRestorer{})
+
+	if _, err := r.Run(context.Background(), nil); err == nil {
+		t.Error("expected error for nil marker")
+	}
+	if _, err := r.Run(context.Background(), &Marker{PreviousVersion: "1.8.0"}); err == nil {
+		t.Error("expected error for marker without runtime state")
+	}
+	marker := testMarker()
+	marker.PreviousVersion = ""
+	if _, err := r.Run(context.Background(), marker); err == nil {
+		t.Error("expected error for marker without previous version")
+	}
+}
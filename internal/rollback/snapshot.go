@@ -0,0 +1,87 @@
+package rollback
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/payram/payram-updater/internal/container"
+	"github.com/payram/payram-updater/internal/manifest"
+)
+
+// SnapshotSchemaVersion is the schema version of snapshots written by this
+// build. Load refuses snapshots with any other version rather than recreate
+// a container from fields it may misread.
+const SnapshotSchemaVersion = 1
+
+// Snapshot is the full runtime state of the container, persisted right
+// before an upgrade stops it, so the original container can be recreated
+// exactly even if the updater dies after removing it.
+type Snapshot struct {
+	SchemaVersion int                     `json:"schemaVersion"`
+	JobID         string                  `json:"jobId"`
+	ContainerName string                  `json:"containerName"`
+	RuntimeState  *container.RuntimeState `json:"runtimeState"`
+	RecordedAt    time.Time               `json:"recordedAt"`
+}
+
+// SaveSnapshot persists the pre-upgrade snapshot atomically, replacing the
+// snapshot of any earlier upgrade.
+func (s *Store) SaveSnapshot(snapshot *Snapshot) error {
+	if snapshot == nil || snapshot.RuntimeState == nil {
+		return fmt.Errorf("snapshot runtime state is required")
+	}
+	snapshot.SchemaVersion = SnapshotSchemaVersion
+	if snapshot.RecordedAt.IsZero() {
+		snapshot.RecordedAt = time.Now().UTC()
+	}
+
+	return s.writeFile(s.snapshotPath(), ".pre-upgrade-state-*.tmp", "pre-upgrade snapshot", snapshot)
+}
+
+// LoadSnapshot reads the pre-upgrade snapshot from disk.
+// Returns nil if no snapshot has been recorded.
+func (s *Store) LoadSnapshot() (*Snapshot, error) {
+	data, err := os.ReadFile(s.snapshotPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read pre-upgrade snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pre-upgrade snapshot: %w", err)
+	}
+	if snapshot.SchemaVersion != SnapshotSchemaVersion {
+		return nil, fmt.Errorf("unsupported pre-upgrade snapshot schema version %d (expected %d)", snapshot.SchemaVersion, SnapshotSchemaVersion)
+	}
+	if snapshot.RuntimeState == nil {
+		return nil, fmt.Errorf("pre-upgrade snapshot has no runtime state")
+	}
+	return &snapshot, nil
+}
+
+// snapshotPath returns the path to the pre-upgrade snapshot file.
+func (s *Store) snapshotPath() string {
+	return filepath.Join(s.stateDir, "pre_upgrade_state.json")
+}
+
+// RecreateArgs builds the docker run args that recreate the container
+// described by state, running imageTag of its image repo.
+func RecreateArgs(state *container.RuntimeState, imageTag string, logger Logger) ([]string, error) {
+	manifestData := &manifest.Manifest{
+		Image: manifest.Image{
+			Repo: strings.TrimSuffix(state.Image, ":"+state.ImageTag),
+		},
+		Defaults: manifest.Defaults{
+			ContainerName: state.Name,
+			RestartPolicy: state.RestartPolicy.Name,
+		},
+	}
+	return container.NewDockerRunBuilder(logger).BuildUpgradeArgs(state, manifestData, imageTag)
+}
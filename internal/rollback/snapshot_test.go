@@ -0,0 +1,97 @@
+package rollback
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/container"
+)
+
+func testSnapshot() *Snapshot {
+	return &Snapshot{
+		JobID:         "job-123",
+		ContainerName: "payram",
+		RuntimeState: &container.RuntimeState{
+			Name:          "payram",
+			Image:         "payramapp/payram:1.8.0",
+			ImageTag:      "1.8.0",
+			Ports:         []container.PortMapping{{HostIP: "0.0.0.0", HostPort: "8080", ContainerPort: "8080", Protocol: "tcp"}},
+			Mounts:        []container.Mount{{Type: "bind", Source: "/srv/payram", Destination: "/root/payram", RW: true}},
+			Env:           []string{"POSTGRES_HOST=db"},
+			RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
+		},
+	}
+}
+
+func TestStore_LoadNoSnapshot(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	snapshot, err := store.LoadSnapshot()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshot != nil {
+		t.Errorf("expected nil snapshot, got %+v", snapshot)
+	}
+}
+
+func TestStore_SnapshotReloadsToSameDockerArgs(t *testing.T) {
+	store := NewStore(t.TempDir())
+	original := testSnapshot()
+	logger := log.New(io.Discard, "", 0)
+	want, err := RecreateArgs(original.RuntimeState, "1.8.0", logger)
+	if err != nil {
+		t.Fatalf("RecreateArgs failed: %v", err)
+	}
+
+	if err := store.SaveSnapshot(original); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	loaded, err := store.LoadSnapshot()
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+
+	if loaded.SchemaVersion != SnapshotSchemaVersion || loaded.JobID != "job-123" || loaded.ContainerName != "payram" {
+		t.Errorf("unexpected snapshot header: %+v", loaded)
+	}
+	if loaded.RecordedAt.IsZero() {
+		t.Error("expected RecordedAt to be set")
+	}
+	got, err := RecreateArgs(loaded.RuntimeState, loaded.RuntimeState.ImageTag, logger)
+	if err != nil {
+		t.Fatalf("RecreateArgs failed on the reloaded state: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected reloaded args %v, got %v", want, got)
+	}
+	for _, arg := range []string{"/srv/payram:/root/payram", "POSTGRES_HOST=db", "payramapp/payram:1.8.0"} {
+		if !strings.Contains(strings.Join(got, " "), arg) {
+			t.Errorf("expected args to contain %q, got %v", arg, got)
+		}
+	}
+}
+
+func TestStore_LoadSnapshotRejectsOtherSchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"schemaVersion": 2, "jobId": "job-123", "runtimeState": {"Name": "payram"}}`
+	if err := os.WriteFile(filepath.Join(dir, "pre_upgrade_state.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewStore(dir).LoadSnapshot()
+	if err == nil || !strings.Contains(err.Error(), "schema version 2") {
+		t.Errorf("expected a schema version error, got %v", err)
+	}
+}
+
+func TestStore_SaveSnapshotRequiresRuntimeState(t *testing.T) {
+	if err := NewStore(t.TempDir()).SaveSnapshot(&Snapshot{JobID: "job-123"}); err == nil {
+		t.Error("expected an error saving a snapshot without runtime state")
+	}
+}
@@ -0,0 +1,57 @@
+// Package sdnotify implements the systemd sd_notify protocol, so a daemon
+// run as a Type=notify unit can tell systemd when it is ready and when it is
+// stopping. Every call is a no-op when NOTIFY_SOCKET is not set.
+package sdnotify
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// SocketEnv is the environment variable systemd sets to the notify socket.
+const SocketEnv = "NOTIFY_SOCKET"
+
+// State assignments understood by systemd.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+)
+
+// Status returns a STATUS= assignment, shown by systemctl status.
+func Status(text string) string {
+	return "STATUS=" + strings.ReplaceAll(text, "\n", " ")
+}
+
+// Message joins state assignments into one notification datagram.
+func Message(states ...string) string {
+	return strings.Join(states, "\n") + "\n"
+}
+
+// socketAddr returns the unix address for a NOTIFY_SOCKET value. A leading
+// '@' names a socket in the abstract namespace.
+func socketAddr(socket string) *net.UnixAddr {
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	return &net.UnixAddr{Name: socket, Net: "unixgram"}
+}
+
+// Notify sends states to the socket in NOTIFY_SOCKET. It reports whether a
+// notification was sent: false with a nil error when there is no socket.
+func Notify(states ...string) (bool, error) {
+	socket := os.Getenv(SocketEnv)
+	if socket == "" {
+		return false, nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, socketAddr(socket))
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to %s %s: %w", SocketEnv, socket, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(Message(states...))); err != nil {
+		return false, fmt.Errorf("failed to notify %s: %w", socket, err)
+	}
+	return true, nil
+}
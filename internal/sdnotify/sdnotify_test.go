@@ -0,0 +1,100 @@
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenNotify binds a fake systemd notify socket and points NOTIFY_SOCKET
+// at it. The directory is kept short to stay within the unix path limit.
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "sdn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv(SocketEnv, path)
+	return conn
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		states []string
+		want   string
+	}{
+		{[]string{Ready}, "READY=1\n"},
+		{[]string{Stopping}, "STOPPING=1\n"},
+		{[]string{Ready, Status("Listening on\n127.0.0.1:2567")}, "READY=1\nSTATUS=Listening on 127.0.0.1:2567\n"},
+	}
+	for _, tt := range tests {
+		if got := Message(tt.states...); got != tt.want {
+			t.Errorf("Message(%q): expected %q, got %q", tt.states, tt.want, got)
+		}
+	}
+}
+
+func TestNotify_SendsToSocket(t *testing.T) {
+	conn := listenNotify(t)
+
+	sent, err := Notify(Ready, Status("ready"))
+	if err != nil || !sent {
+		t.Fatalf("expected the notification to be sent, got sent=%v err=%v", sent, err)
+	}
+	if got := readNotify(t, conn); got != "READY=1\nSTATUS=ready\n" {
+		t.Errorf("unexpected datagram %q", got)
+	}
+
+	if _, err := Notify(Stopping); err != nil {
+		t.Fatal(err)
+	}
+	if got := readNotify(t, conn); got != "STOPPING=1\n" {
+		t.Errorf("unexpected datagram %q", got)
+	}
+}
+
+func TestNotify_NoSocketIsNoOp(t *testing.T) {
+	t.Setenv(SocketEnv, "")
+
+	sent, err := Notify(Ready)
+	if sent || err != nil {
+		t.Errorf("expected a no-op without %s, got sent=%v err=%v", SocketEnv, sent, err)
+	}
+}
+
+func TestNotify_MissingSocketFails(t *testing.T) {
+	t.Setenv(SocketEnv, filepath.Join(t.TempDir(), "missing"))
+
+	if sent, err := Notify(Ready); sent || err == nil {
+		t.Errorf("expected an error for a missing socket, got sent=%v err=%v", sent, err)
+	}
+}
+
+func TestSocketAddr_Abstract(t *testing.T) {
+	if got := socketAddr("@payram/notify").Name; got != "\x00payram/notify" {
+		t.Errorf("expected an abstract address, got %q", got)
+	}
+	if got := socketAddr("/run/systemd/notify").Name; got != "/run/systemd/notify" {
+		t.Errorf("expected the path unchanged, got %q", got)
+	}
+}
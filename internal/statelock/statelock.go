@@ -0,0 +1,147 @@
+// Package statelock provides PID lock files, used to keep two updater
+// daemons from sharing a state directory and two restores from running at
+// once. The holder records its PID in the lock file; a lock left behind by a
+// process that no longer exists is taken over.
+package statelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// FileName is the lock file created in the state directory.
+const FileName = "updater.pid"
+
+// held records the lock files this process holds, so a lock recording our
+// own PID can be told apart from one left by an earlier process with the
+// same PID.
+var (
+	heldMu sync.Mutex
+	held   = make(map[string]bool)
+)
+
+// Lock is a held lock file.
+type Lock struct {
+	path     string
+	pid      int
+	released bool
+}
+
+// HeldError reports that a live process holds a lock.
+type HeldError struct {
+	Path   string
+	PID    int
+	Detail string // what the holder recorded about itself, if anything
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("lock file %s is held by running process %d", e.Path, e.PID)
+}
+
+// Acquire takes the daemon lock for stateDir, creating the directory if
+// needed. It fails with a *HeldError if another live daemon holds the lock.
+func Acquire(stateDir string) (*Lock, error) {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	lock, err := AcquireFile(filepath.Join(stateDir, FileName), "")
+	var held *HeldError
+	if errors.As(err, &held) {
+		return nil, fmt.Errorf("another payram-updater daemon (PID %d) is already using this state directory; stop it first, or remove %s if that process is not an updater: %w", held.PID, held.Path, err)
+	}
+	return lock, err
+}
+
+// AcquireFile takes the lock at path, recording this process's PID and
+// detail. It fails with a *HeldError if a live process holds the lock, and
+// replaces a stale lock whose process has exited.
+func AcquireFile(path, detail string) (*Lock, error) {
+	pid := os.Getpid()
+	contents := fmt.Sprintf("%d\n", pid)
+	if detail != "" {
+		contents += detail + "\n"
+	}
+
+	heldMu.Lock()
+	defer heldMu.Unlock()
+
+	// Two attempts: the second follows removal of a stale lock.
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, writeErr := f.WriteString(contents)
+			closeErr := f.Close()
+			if writeErr != nil || closeErr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file %s: %w", path, errors.Join(writeErr, closeErr))
+			}
+			held[path] = true
+			return &Lock{path: path, pid: pid}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", path, err)
+		}
+
+		// A lock recording our own PID that this process does not hold was
+		// left by an earlier run with the same PID, as happens to PID 1 in a
+		// restarted container.
+		holder, holderDetail, ok := readLock(path)
+		if ok && (holder == pid && held[path] || holder != pid && ProcessAlive(holder)) {
+			return nil, &HeldError{Path: path, PID: holder, Detail: holderDetail}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock file %s: %w", path, err)
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire lock file %s: another process is acquiring it", path)
+}
+
+// Release removes the lock file if it still belongs to this lock. It is safe
+// to call on a nil lock and more than once.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	if l.released {
+		return nil
+	}
+	if holder, _, ok := readLock(l.path); ok && holder == l.pid {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove lock file %s: %w", l.path, err)
+		}
+	}
+	l.released = true
+	delete(held, l.path)
+	return nil
+}
+
+// readLock returns the PID and detail recorded in a lock file. ok is false
+// when the file is missing or does not start with a PID, which is treated as
+// a stale lock.
+func readLock(path string) (int, string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", false
+	}
+	first, rest, _ := strings.Cut(string(data), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil || pid <= 0 {
+		return 0, "", false
+	}
+	return pid, strings.TrimSpace(rest), true
+}
+
+// ProcessAlive reports whether a process with the given PID exists. A
+// permission error means it exists but belongs to another user.
+func ProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,169 @@
+package statelock
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeLock writes a lock file for pid into dir.
+func writeLock(t *testing.T, dir string, pid int) string {
+	t.Helper()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startProcess starts a long-running process and returns its PID.
+func startProcess(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd.Process.Pid
+}
+
+// exitedPID returns the PID of a process that has already exited.
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestAcquire_CreatesLockWithPID(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer lock.Release()
+
+	pid, _, ok := readLock(filepath.Join(dir, FileName))
+	if !ok || pid != os.Getpid() {
+		t.Errorf("expected lock file to record PID %d, got %d", os.Getpid(), pid)
+	}
+}
+
+func TestAcquire_FailsWhenLiveProcessHoldsLock(t *testing.T) {
+	dir := t.TempDir()
+	holder := startProcess(t)
+	path := writeLock(t, dir, holder)
+
+	_, err := Acquire(dir)
+
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("expected a HeldError, got %v", err)
+	}
+	if held.PID != holder || held.Path != path {
+		t.Errorf("expected lock held by PID %d at %s, got %+v", holder, path, held)
+	}
+	if pid, _, _ := readLock(path); pid != holder {
+		t.Errorf("expected the live lock to be left alone, now records PID %d", pid)
+	}
+}
+
+func TestAcquire_TakesOverStaleLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"exited process", strconv.Itoa(exitedPID(t))},
+		{"own pid from earlier run", strconv.Itoa(os.Getpid())},
+		{"garbage", "not-a-pid"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, FileName), []byte(tt.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			lock, err := Acquire(dir)
+			if err != nil {
+				t.Fatalf("expected a stale lock to be taken over, got %v", err)
+			}
+			defer lock.Release()
+			if pid, _, _ := readLock(filepath.Join(dir, FileName)); pid != os.Getpid() {
+				t.Errorf("expected lock file to record PID %d, got %d", os.Getpid(), pid)
+			}
+		})
+	}
+}
+
+func TestRelease_RemovesLock(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, FileName)); !os.IsNotExist(err) {
+		t.Errorf("expected lock file to be removed, stat err=%v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("expected a second Release to be a no-op, got %v", err)
+	}
+}
+
+func TestRelease_LeavesLockTakenOverByAnotherDaemon(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	other := startProcess(t)
+	writeLock(t, dir, other)
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if pid, _, _ := readLock(filepath.Join(dir, FileName)); pid != other {
+		t.Errorf("expected the other daemon's lock to survive, got PID %d", pid)
+	}
+}
+
+func TestAcquireFile_FailsWhileHeldInThisProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restore.lock")
+	lock, err := AcquireFile(path, "first holder")
+	if err != nil {
+		t.Fatalf("AcquireFile failed: %v", err)
+	}
+
+	_, err = AcquireFile(path, "second holder")
+
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("expected a HeldError, got %v", err)
+	}
+	if held.PID != os.Getpid() || held.Detail != "first holder" {
+		t.Errorf("expected lock held by PID %d with the first holder's detail, got %+v", os.Getpid(), held)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	again, err := AcquireFile(path, "second holder")
+	if err != nil {
+		t.Fatalf("expected the lock to be free after Release, got %v", err)
+	}
+	again.Release()
+}
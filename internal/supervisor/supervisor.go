@@ -0,0 +1,136 @@
+// Package supervisor stops and starts the supervisord programs inside the
+// Payram container, so a database backup can be taken without the
+// application writing to the database.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
+)
+
+// Execer runs a command in a container, as dockerexec.Runner.Exec does.
+type Execer interface {
+	Exec(ctx context.Context, container string, command ...string) (string, error)
+}
+
+// ErrUnavailable is returned when the container has no supervisorctl.
+var ErrUnavailable = errors.New("supervisorctl not available")
+
+// Status returns the output of supervisorctl status in the container.
+func Status(ctx context.Context, e Execer, containerName string) (string, error) {
+	output, err := e.Exec(ctx, containerName, "supervisorctl", "status")
+	if err == nil {
+		return output, nil
+	}
+
+	// supervisorctl status exits 3 when some programs are not running
+	var cmdErr *dockerexec.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.ExitCode() == 3 {
+		return output, nil
+	}
+	if errors.Is(err, dockerexec.ErrExecutableNotFound) {
+		return "", ErrUnavailable
+	}
+
+	return "", fmt.Errorf("supervisorctl status failed: %w", err)
+}
+
+// ParseStatus maps each program in supervisorctl status output to its state.
+func ParseStatus(output string) map[string]string {
+	status := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		status[fields[0]] = fields[1]
+	}
+	return status
+}
+
+// SelectPrograms returns the programs of status to stop for a consistent
+// backup, sorted: those in include if it is set, otherwise all but those in
+// exclude (SUPERVISOR_INCLUDE and SUPERVISOR_EXCLUDE). running are the
+// selected programs that are RUNNING or STARTING, so the ones to restart.
+func SelectPrograms(status map[string]string, include, exclude []string) (selected, running []string) {
+	includeSet := make(map[string]struct{}, len(include))
+	for _, name := range include {
+		includeSet[name] = struct{}{}
+	}
+	excludeSet := make(map[string]struct{}, len(exclude))
+	for _, name := range exclude {
+		excludeSet[name] = struct{}{}
+	}
+
+	for name, state := range status {
+		if len(includeSet) > 0 {
+			if _, ok := includeSet[name]; !ok {
+				continue
+			}
+		} else if _, ok := excludeSet[name]; ok {
+			continue
+		}
+
+		selected = append(selected, name)
+		if state == "RUNNING" || state == "STARTING" {
+			running = append(running, name)
+		}
+	}
+	sort.Strings(selected)
+	sort.Strings(running)
+	return selected, running
+}
+
+// Stop stops programs in the container.
+func Stop(ctx context.Context, e Execer, containerName string, programs []string) error {
+	if len(programs) == 0 {
+		return nil
+	}
+	if _, err := e.Exec(ctx, containerName, append([]string{"supervisorctl", "stop"}, programs...)...); err != nil {
+		return fmt.Errorf("supervisorctl stop failed: %w", err)
+	}
+	return nil
+}
+
+// Start starts programs in the container.
+func Start(ctx context.Context, e Execer, containerName string, programs []string) error {
+	if len(programs) == 0 {
+		return nil
+	}
+	if _, err := e.Exec(ctx, containerName, append([]string{"supervisorctl", "start"}, programs...)...); err != nil {
+		return fmt.Errorf("supervisorctl start failed: %w", err)
+	}
+	return nil
+}
+
+// Around runs fn with the container quiesced as for the pre-upgrade backup:
+// the programs SelectPrograms picks are stopped, fn runs, and those that were
+// running are started again, even if fn fails or ctx ends. stopped are the
+// programs it stopped and restarted. Without supervisorctl in the container
+// fn runs unquiesced and quiesced is false.
+func Around(ctx context.Context, e Execer, containerName string, include, exclude []string, fn func() error) (stopped []string, quiesced bool, err error) {
+	output, err := Status(ctx, e, containerName)
+	if errors.Is(err, ErrUnavailable) {
+		return nil, false, fn()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	selected, running := SelectPrograms(ParseStatus(output), include, exclude)
+	if err := Stop(ctx, e, containerName, selected); err != nil {
+		// Programs stopped before the failure must not stay down
+		return nil, false, errors.Join(err, Start(context.WithoutCancel(ctx), e, containerName, running))
+	}
+
+	fnErr := fn()
+	if err := Start(context.WithoutCancel(ctx), e, containerName, running); err != nil {
+		return running, true, errors.Join(fnErr, fmt.Errorf("programs stopped for the backup are still down (%s): %w", strings.Join(running, ", "), err))
+	}
+	return running, true, fnErr
+}
@@ -0,0 +1,136 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/payram/payram-updater/internal/dockerexec"
+)
+
+const statusOutput = `nginx                            RUNNING   pid 10, uptime 1:00:00
+payram-core                      RUNNING   pid 11, uptime 1:00:00
+payram-worker                    STOPPED   Not started
+postgres                         RUNNING   pid 9, uptime 1:00:00`
+
+// fakeSupervisor answers supervisorctl commands for one container and tracks
+// which programs are running.
+type fakeSupervisor struct {
+	running   map[string]bool
+	calls     []string
+	startErr  error
+	available bool
+}
+
+func newFakeSupervisor() *fakeSupervisor {
+	return &fakeSupervisor{
+		running:   map[string]bool{"nginx": true, "payram-core": true, "postgres": true},
+		available: true,
+	}
+}
+
+func (f *fakeSupervisor) Exec(ctx context.Context, container string, command ...string) (string, error) {
+	f.calls = append(f.calls, strings.Join(command, " "))
+	if !f.available {
+		return "", fmt.Errorf("exec supervisorctl: %w", dockerexec.ErrExecutableNotFound)
+	}
+	switch command[1] {
+	case "status":
+		return statusOutput, nil
+	case "stop":
+		for _, program := range command[2:] {
+			f.running[program] = false
+		}
+	case "start":
+		if f.startErr != nil {
+			return "", f.startErr
+		}
+		for _, program := range command[2:] {
+			f.running[program] = true
+		}
+	}
+	return "", nil
+}
+
+func TestSelectPrograms(t *testing.T) {
+	status := ParseStatus(statusOutput)
+
+	selected, running := SelectPrograms(status, nil, []string{"postgres", "postgresql"})
+	if !reflect.DeepEqual(selected, []string{"nginx", "payram-core", "payram-worker"}) || !reflect.DeepEqual(running, []string{"nginx", "payram-core"}) {
+		t.Errorf("exclude: got selected %v, running %v", selected, running)
+	}
+
+	selected, running = SelectPrograms(status, []string{"payram-core", "payram-worker"}, []string{"payram-core"})
+	if !reflect.DeepEqual(selected, []string{"payram-core", "payram-worker"}) || !reflect.DeepEqual(running, []string{"payram-core"}) {
+		t.Errorf("include wins over exclude: got selected %v, running %v", selected, running)
+	}
+}
+
+func TestAround_StopsProgramsDuringBackupAndRestartsThem(t *testing.T) {
+	f := newFakeSupervisor()
+	backedUp := false
+	stopped, quiesced, err := Around(context.Background(), f, "payram", nil, []string{"postgres"}, func() error {
+		if f.running["nginx"] || f.running["payram-core"] {
+			t.Errorf("expected the programs to be stopped during the backup, got %v", f.running)
+		}
+		if !f.running["postgres"] {
+			t.Error("expected the excluded database to keep running during the backup")
+		}
+		backedUp = true
+		return nil
+	})
+	if err != nil || !quiesced || !backedUp {
+		t.Fatalf("expected a quiesced backup, got quiesced=%v backedUp=%v err=%v", quiesced, backedUp, err)
+	}
+	if !reflect.DeepEqual(stopped, []string{"nginx", "payram-core"}) {
+		t.Errorf("expected nginx and payram-core to be stopped, got %v", stopped)
+	}
+	if !f.running["nginx"] || !f.running["payram-core"] || f.running["payram-worker"] {
+		t.Errorf("expected the running programs, and only them, to be restarted, got %v", f.running)
+	}
+	want := []string{
+		"supervisorctl status",
+		"supervisorctl stop nginx payram-core payram-worker",
+		"supervisorctl start nginx payram-core",
+	}
+	if !reflect.DeepEqual(f.calls, want) {
+		t.Errorf("expected calls %v, got %v", want, f.calls)
+	}
+}
+
+func TestAround_RestartsProgramsWhenBackupFails(t *testing.T) {
+	f := newFakeSupervisor()
+	backupErr := errors.New("pg_dump failed")
+	_, _, err := Around(context.Background(), f, "payram", nil, []string{"postgres"}, func() error { return backupErr })
+	if !errors.Is(err, backupErr) {
+		t.Fatalf("expected the backup error, got %v", err)
+	}
+	if !f.running["nginx"] || !f.running["payram-core"] {
+		t.Errorf("expected the programs to be restarted after a failed backup, got %v", f.running)
+	}
+}
+
+func TestAround_ReportsProgramsLeftDown(t *testing.T) {
+	f := newFakeSupervisor()
+	f.startErr = errors.New("spawn error")
+	_, _, err := Around(context.Background(), f, "payram", nil, []string{"postgres"}, func() error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "still down (nginx, payram-core)") {
+		t.Errorf("expected an error naming the programs left down, got %v", err)
+	}
+}
+
+func TestAround_WithoutSupervisorctlRunsUnquiesced(t *testing.T) {
+	f := newFakeSupervisor()
+	f.available = false
+	backedUp := false
+	stopped, quiesced, err := Around(context.Background(), f, "payram", nil, nil, func() error {
+		backedUp = true
+		return nil
+	})
+	if err != nil || quiesced || len(stopped) != 0 || !backedUp {
+		t.Errorf("expected an unquiesced backup, got stopped=%v quiesced=%v backedUp=%v err=%v", stopped, quiesced, backedUp, err)
+	}
+}
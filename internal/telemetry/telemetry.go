@@ -0,0 +1,118 @@
+// Package telemetry reports anonymized upgrade outcomes to an operator-chosen
+// endpoint. It is off unless explicitly enabled, never blocks the upgrade, and
+// only ever sends the fields of Event after redaction.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/payram/payram-updater/internal/httpclient"
+	"github.com/payram/payram-updater/internal/logger"
+)
+
+// SendTimeout bounds one telemetry delivery.
+const SendTimeout = 5 * time.Second
+
+// Event is the anonymized outcome of one upgrade. It deliberately has no
+// job IDs, host or container names, paths, URLs or free-text messages, since
+// any of those can identify an installation or carry secrets.
+type Event struct {
+	FromVersion     string `json:"fromVersion,omitempty"`
+	ToVersion       string `json:"toVersion"`
+	Mode            string `json:"mode"`
+	Outcome         string `json:"outcome"` // succeeded, failed, cancelled
+	FailureCode     string `json:"failureCode,omitempty"`
+	SteppingStone   bool   `json:"steppingStone,omitempty"`
+	DurationSeconds int64  `json:"durationSeconds"`
+}
+
+var (
+	versionPattern = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+){0,3}(-[0-9A-Za-z.]+)?$`)
+	codePattern    = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	outcomes       = map[string]bool{"succeeded": true, "failed": true, "cancelled": true}
+	modes          = map[string]bool{"DASHBOARD": true, "MANUAL": true}
+)
+
+// redacted is sent in place of any value that does not match its expected
+// shape, e.g. a custom image tag that could embed a hostname.
+const redacted = "redacted"
+
+// Redact returns a copy of e in which every string field is either a value of
+// the expected shape or "redacted".
+func Redact(e Event) Event {
+	e.FromVersion = keepIf(e.FromVersion, versionPattern.MatchString)
+	e.ToVersion = keepIf(e.ToVersion, versionPattern.MatchString)
+	e.FailureCode = keepIf(e.FailureCode, codePattern.MatchString)
+	e.Outcome = keepIf(e.Outcome, func(s string) bool { return outcomes[s] })
+	e.Mode = keepIf(e.Mode, func(s string) bool { return modes[s] })
+	if e.DurationSeconds < 0 {
+		e.DurationSeconds = 0
+	}
+	return e
+}
+
+func keepIf(value string, ok func(string) bool) string {
+	if value == "" || ok(value) {
+		return value
+	}
+	return redacted
+}
+
+// Reporter delivers events. A nil Reporter (telemetry disabled) drops them.
+type Reporter struct {
+	url    string
+	client *http.Client
+}
+
+// New returns a Reporter posting to url, or nil when telemetry is disabled.
+func New(enabled bool, url string) *Reporter {
+	if !enabled || url == "" {
+		return nil
+	}
+	return &Reporter{url: url, client: httpclient.New(SendTimeout)}
+}
+
+// Report sends the redacted event in the background and returns immediately.
+// Delivery failures are logged and otherwise ignored.
+func (r *Reporter) Report(e Event) {
+	if r == nil {
+		return
+	}
+	body, err := json.Marshal(Redact(e))
+	if err != nil {
+		logger.Error("Telemetry", "Report", err)
+		return
+	}
+	go func() {
+		if err := r.send(body); err != nil {
+			logger.Warnf("Telemetry", "Report", "failed to send upgrade telemetry: %v", err)
+		}
+	}()
+}
+
+func (r *Reporter) send(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
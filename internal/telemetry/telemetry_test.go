@@ -0,0 +1,136 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCollector returns a server that forwards each request body on the
+// returned channel and counts requests.
+func newCollector(t *testing.T) (*httptest.Server, <-chan []byte, *atomic.Int32) {
+	t.Helper()
+	bodies := make(chan []byte, 4)
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies, &hits
+}
+
+func TestReport_SendsRedactedPayload(t *testing.T) {
+	server, bodies, _ := newCollector(t)
+	reporter := New(true, server.URL)
+
+	reporter.Report(Event{
+		FromVersion:     "1.9.2",
+		ToVersion:       "1.9.3-arm64",
+		Mode:            "DASHBOARD",
+		Outcome:         "failed",
+		FailureCode:     "HEALTHCHECK_FAILED",
+		SteppingStone:   true,
+		DurationSeconds: 42,
+	})
+
+	var body []byte
+	select {
+	case body = <-bodies:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a telemetry request, got none")
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("payload is not JSON: %v (%s)", err, body)
+	}
+	want := map[string]interface{}{
+		"fromVersion":     "1.9.2",
+		"toVersion":       "1.9.3-arm64",
+		"mode":            "DASHBOARD",
+		"outcome":         "failed",
+		"failureCode":     "HEALTHCHECK_FAILED",
+		"steppingStone":   true,
+		"durationSeconds": float64(42),
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected exactly %d fields, got %v", len(want), got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, got[key])
+		}
+	}
+}
+
+func TestRedact_ReplacesUnexpectedValues(t *testing.T) {
+	got := Redact(Event{
+		FromVersion:     "registry.internal.example.com/payram:custom",
+		ToVersion:       "1.9.3",
+		Mode:            "payram-prod-01",
+		Outcome:         "exploded",
+		FailureCode:     "failed to connect to 10.0.0.5:5432",
+		DurationSeconds: -5,
+	})
+
+	if got.FromVersion != redacted {
+		t.Errorf("expected FromVersion redacted, got %q", got.FromVersion)
+	}
+	if got.ToVersion != "1.9.3" {
+		t.Errorf("expected ToVersion kept, got %q", got.ToVersion)
+	}
+	if got.Mode != redacted || got.Outcome != redacted || got.FailureCode != redacted {
+		t.Errorf("expected mode, outcome and failure code redacted, got %+v", got)
+	}
+	if got.DurationSeconds != 0 {
+		t.Errorf("expected negative duration clamped to 0, got %d", got.DurationSeconds)
+	}
+
+	payload, _ := json.Marshal(got)
+	for _, secret := range []string{"registry.internal", "payram-prod-01", "10.0.0.5"} {
+		if strings.Contains(string(payload), secret) {
+			t.Errorf("payload leaks %q: %s", secret, payload)
+		}
+	}
+}
+
+func TestNew_DisabledSendsNothing(t *testing.T) {
+	server, _, hits := newCollector(t)
+
+	for _, reporter := range []*Reporter{New(false, server.URL), New(true, "")} {
+		if reporter != nil {
+			t.Fatalf("expected a nil reporter when disabled, got %+v", reporter)
+		}
+		reporter.Report(Event{ToVersion: "1.9.3", Outcome: "succeeded"})
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := hits.Load(); got != 0 {
+		t.Errorf("expected no telemetry requests when disabled, got %d", got)
+	}
+}
+
+func TestReport_DoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+
+	start := time.Now()
+	New(true, server.URL).Report(Event{ToVersion: "1.9.3", Outcome: "succeeded"})
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected Report to return immediately, took %s", elapsed)
+	}
+}
@@ -604,8 +604,8 @@ if [[ "$ENABLE_SERVICE" == "true" ]]; then
     if [[ -n "$TARGET_CONTAINER" ]] && docker ps --format "{{.Names}}" | grep -q "^${TARGET_CONTAINER}$"; then
       log "Testing container-to-updater connectivity..."
       # Try wget first, then curl
-      if docker exec "$TARGET_CONTAINER" timeout 3 wget -qO- http://172.17.0.1:${UPDATER_PORT:-2567}/health >/dev/null 2>&1 || \
-         docker exec "$TARGET_CONTAINER" timeout 3 curl -sf http://172.17.0.1:${UPDATER_PORT:-2567}/health >/dev/null 2>&1; then
+      if docker exec "$TARGET_CONTAINER" timeout 3 wget -qO- http://172.17.0.1:${UPDATER_PORT:-2567}/livez >/dev/null 2>&1 || \
+         docker exec "$TARGET_CONTAINER" timeout 3 curl -sf http://172.17.0.1:${UPDATER_PORT:-2567}/livez >/dev/null 2>&1; then
         log "Connectivity test passed"
       else
         log ""